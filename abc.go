@@ -2,10 +2,9 @@ package zksigma
 
 import (
 	"bytes"
-	"crypto/rand"
+	"encoding/json"
+	"io"
 	"math/big"
-
-	"github.com/mit-dci/zksigma/wire"
 )
 
 // ABCProof is a proof that generates a proof that the relationship between three
@@ -18,6 +17,7 @@ import (
 //  - B = inv(v)G + ubH //inv is multiplicative inverse, in the case of v = 0, inv(v) = 0
 //  - C = (v * inv(v))G + ucH // c = v * inv(v)
 //  - CMTok = uaPK = ua(skH) // ua is r from CM
+//  - CToken = ucPK // lets the holder of sk check C, bound by a ConsistencyProof
 //
 //  Prover									Verifier
 //  ======                                  ======
@@ -25,76 +25,299 @@ import (
 //  - commitment of inv(v), B
 //  - commitment of v * inv(v), C // either 0 or 1 ONLY
 //  - Disjunctive proof of v = 0 or c = 1
+//  - Consistency proof that C and CToken use the same uc
 //  select u1, u2, u3 at random
 //  select ub, uc at random // ua was before proof
 //  Compute:
 //  - T1 = u1G + u2CMTok
 //  - T2 = u1B + u3H
-//  - chal = HASH(G,H,CM,CMTok,B,C,T1,T2)
+//  - T3 = u2PK
+//  - chal = HASH(G,H,CM,CMTok,B,C,T1,T2,CToken,T3)
 //  Compute:
 //  - j = u1 + v * chal
 //  - k = u2 + inv(sk) * chal
 //  - l = u3 + (uc - v * ub) * chal
 //
-//  disjuncAC, B, C, T1, T2, c, j, k, l ------->
+//  disjuncAC, cTokenProof, B, C, CToken, T1, T2, T3, c, j, k, l ------->
+//         									chal ?= HASH(G,H,CM,CMTok,B,C,T1,T2,CToken,T3)
 //         									disjuncAC ?= true
-//         									chal ?= HASH(G,H,CM,CMTok,B,C,T1,T2)
+//         									cTokenProof ?= true for C, CToken, PK
 //         									chal*CM + T1 ?= jG + kCMTok
 //         									chal*C + T2 ?= jB + lH˜
+//         									chal*H + T3 ?= kPK // VerifyWithAuditor only
+//
+//  The last equation uses the same k = u2 + inv(sk) * chal as the first, so
+//  CM - vG = inv(sk)CMTok and H = inv(sk)PK for one sk, that is CMTok = uaPK.
+//
+//  A Delegated proof is made by the holder of ua instead of sk, see
+//  NewABCProofDelegated. It answers k for ua and the prover has to know ua
+//  in both branches of the disjunctive proof, which is of CM = uaH or c = 1:
+//  - T1 = u1G + u2H
+//  - k = u2 + ua * chal
+//         									chal*CM + T1 ?= jG + kH
+//         									chal*CMTok + T3 ?= kPK // Verify checks it too
+//
+//  so CM - vG = uaH and CMTok = uaPK for one ua.
 type ABCProof struct {
 	B         ECPoint  // commitment for b = 0 OR inv(v)
 	C         ECPoint  // commitment for c = 0 OR 1 ONLY
 	T1        ECPoint  // T1 = u1G + u2MTok
 	T2        ECPoint  // T2 = u1B + u3H
-	Challenge *big.Int // chal = HASH(G,H,CM,CMTok,B,C,T1,T2)
+	T3        ECPoint  // T3 = u2PK
+	Challenge *big.Int // chal = HASH(G,H,CM,CMTok,B,C,T1,T2,CToken,T3)
 	j         *big.Int // j = u1 + v * chal
 	k         *big.Int // k = u2 + inv(sk) * chal
 	l         *big.Int // l = u3 + (uc - v * ub) * chal
-	CToken    ECPoint
-	disjuncAC *DisjunctiveProof
+	CToken    ECPoint  // CToken = ucPK
+
+	disjuncAC   *DisjunctiveProof
+	cTokenProof *ConsistencyProof // C = cG + ucH and CToken = ucPK use the same uc
+
+	// Delegated is set for a proof of NewABCProofDelegated, which shows the
+	// relation for the randomness ua of CM instead of the inverse of sk
+	Delegated bool
+}
+
+// abcChallenge computes chal = HASH(msg,G,H,CM,CMTok,B,C,T1,T2,CToken,T3)
+func abcChallenge(zkpcp ZKPCurveParams, CM, CMTok, B, C, T1, T2, CToken, T3 ECPoint, msg []byte) *big.Int {
+	return abcTranscript(zkpcp, CM, CMTok, B, C, T1, T2, CToken, T3, msg, nil).ChallengeScalar("c")
+}
+
+// abcTranscript returns the transcript the challenge of an ABCProof is
+// computed from, which is traced in tr if it is not nil
+func abcTranscript(zkpcp ZKPCurveParams, CM, CMTok, B, C, T1, T2, CToken, T3 ECPoint, msg []byte, tr *VerifyTrace) *Transcript {
+	t := tr.transcript(zkpcp, "ABCProof")
+	t.bindMessage(msg)
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendPoint("CM", CM)
+	t.AppendPoint("CMTok", CMTok)
+	t.AppendPoint("B", B)
+	t.AppendPoint("C", C)
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	t.AppendPoint("CToken", CToken)
+	t.AppendPoint("T3", T3)
+	return t
+}
+
+// delegatedTranscript adds the relation a Delegated ABCProof shows to its
+// transcript t, so its challenge is never the one of a proof for sk
+func delegatedTranscript(t *Transcript) *Transcript {
+	t.AppendMessage("Relation", []byte("CM = vG + uaH"))
+	return t
+}
+
+// abcDisjunctiveBases returns Base1 and Result1 of the DisjunctiveProof of an
+// ABCProof, whose first branch is CMTok = skCM, or CM = uaH if delegated
+func abcDisjunctiveBases(zkpcp ZKPCurveParams, CM, CMTok ECPoint, delegated bool) (ECPoint, ECPoint) {
+	if delegated {
+		return zkpcp.H, CM
+	}
+	return CM, CMTok
 }
 
 // NewABCProof generates a proof that the relationship between three scalars a,b and c is ab = c,
 // in commitments A, B and C respectively.
 // Option Left is proving that A and C commit to zero and simulates that A, B and C commit to v, inv(v) and 1 respectively.
 // Option Right is proving that A, B and C commit to v, inv(v) and 1 respectively and simulating that A and C commit to 0.
+// Left only works for value 0 and Right for any other value, the wrong side fails with ErrInvalidSide.
 func NewABCProof(zkpcp ZKPCurveParams, CM, CMTok ECPoint, value, sk *big.Int, option Side) (*ABCProof, error) {
+	return NewABCProofWithRand(zkpcp, entropy(), CM, CMTok, value, sk, option)
+}
 
-	// We cannot check that CM log is actually the value, but the verification should catch that
+// NewABCProofWithRand is the same as NewABCProof, but reads its random
+// values from rnd instead of the EntropySource
+func NewABCProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader, CM, CMTok ECPoint, value, sk *big.Int, option Side) (*ABCProof, error) {
+	return newABCProof(zkpcp, rnd, CM, CMTok, value, sk, option, nil)
+}
+
+// NewABCProofWithMessage is the same as NewABCProof, but binds the proof to
+// the application message msg, e.g. the ID of the transaction it is part of,
+// so it can not be replayed for another one. It only verifies with
+// VerifyWithMessage and the same msg. An empty msg is the same as none.
+func NewABCProofWithMessage(zkpcp ZKPCurveParams, CM, CMTok ECPoint, value, sk *big.Int, option Side, msg []byte) (*ABCProof, error) {
+	return newABCProof(zkpcp, entropy(), CM, CMTok, value, sk, option, msg)
+}
 
-	u1, err := rand.Int(rand.Reader, zkpcp.C.Params().N)
+// newABCProof generates an ABCProof bound to msg, which may be nil. It runs
+// the ABCProver of the interactive protocol, but the nested proofs get
+// challenges of their own from their transcripts.
+func newABCProof(zkpcp ZKPCurveParams, rnd io.Reader, CM, CMTok ECPoint, value, sk *big.Int, option Side, msg []byte) (*ABCProof, error) {
+
+	prover, err := NewABCProverWithRand(zkpcp, rnd, CM, CMTok, value, sk, option)
 	if err != nil {
 		return nil, err
 	}
-	u2, err := rand.Int(rand.Reader, zkpcp.C.Params().N)
-	if err != nil {
+	return prover.p.prove(zkpcp, CM, CMTok, msg), nil
+}
+
+// NewABCProofDelegated is the same as NewABCProof, but for a prover that knows
+// the randomness ua of CM = vG + uaH instead of the auditor secret key, e.g.
+// the account holder when the tokens come from another service. The proof is
+// Delegated and shows CM = vG + uaH and CMTok = uaPK, so it needs PK for the
+// CToken too. It fails if CM and CMTok are not those for value and ua.
+func NewABCProofDelegated(zkpcp ZKPCurveParams, CM, CMTok, PK ECPoint, value, ua *big.Int, option Side) (*ABCProof, error) {
+	return NewABCProofDelegatedWithRand(zkpcp, entropy(), CM, CMTok, PK, value, ua, option)
+}
+
+// NewABCProofDelegatedWithRand is the same as NewABCProofDelegated, but reads
+// its random values from rnd instead of the EntropySource
+func NewABCProofDelegatedWithRand(zkpcp ZKPCurveParams, rnd io.Reader, CM, CMTok, PK ECPoint, value, ua *big.Int, option Side) (*ABCProof, error) {
+	if err := zkpcp.checkScalars("ABCProof", "value, ua", value, ua); err != nil {
 		return nil, err
 	}
+	// ua = 0 gives CMTok = Zero for every PK
+	if ua.Sign() == 0 {
+		return nil, rangeError("ABCProof", "ua is zero")
+	}
+	if !zkpcp.validPoints(CM, CMTok, PK) {
+		return nil, curveError("ABCProof")
+	}
+	if PK.IsZero() {
+		return nil, inputError("ABCProof", "PK must not be Zero")
+	}
+	if !PedCommitR(zkpcp, value, ua).Equal(CM) {
+		return nil, inputError("ABCProof", "CM is not value * G + ua * H")
+	}
+	if !zkpcp.Mult(PK, ua).Equal(CMTok) {
+		return nil, inputError("ABCProof", "CMTok is not ua * PK")
+	}
 
-	u3, err := rand.Int(rand.Reader, zkpcp.C.Params().N)
+	var inv *big.Int
+	if option == Right && value.Sign() != 0 {
+		inv = new(big.Int).ModInverse(value, zkpcp.C.Params().N)
+	}
+	prover, err := abcCommitKey(zkpcp, rnd, CM, CMTok, value, inv, abcKey{PK: PK, ua: ua}, option)
 	if err != nil {
 		return nil, err
 	}
+	return prover.prove(zkpcp, CM, CMTok, nil), nil
+}
 
-	ub, err := rand.Int(rand.Reader, zkpcp.C.Params().N)
-	if err != nil {
+// prove computes the challenges of the ABCProof of prover, bound to msg, and
+// responds to them
+func (prover *abcProver) prove(zkpcp ZKPCurveParams, CM, CMTok ECPoint, msg []byte) *ABCProof {
+	// the disjunctive and the consistency proof have challenges of their own
+	djT1, djT2 := prover.disjuncAC.commitments()
+	djBase, djResult := abcDisjunctiveBases(zkpcp, CM, CMTok, prover.delegated)
+	djChallenge := disjunctiveChallenge(zkpcp, djBase, djResult, zkpcp.H, zkpcp.Sub(prover.C, zkpcp.G), djT1, djT2, msg)
+	ctChallenge := prover.cTokenTranscript(zkpcp, msg).ChallengeScalar("c")
+
+	// chal = HASH(msg,G,H,CM,CMTok,B,C,T1,T2,CToken,T3)
+	t := abcTranscript(zkpcp, CM, CMTok, prover.B, prover.C, prover.T1, prover.T2, prover.CToken, prover.T3, msg, nil)
+	if prover.delegated {
+		t = delegatedTranscript(t)
+	}
+	Challenge := t.ChallengeScalar("c")
+
+	return prover.respond(zkpcp, Challenge, djChallenge, ctChallenge)
+}
+
+// NewABCProofAuto is the same as NewABCProof, but picks the side from value:
+// Left if value is 0 and Right otherwise
+func NewABCProofAuto(zkpcp ZKPCurveParams, CM, CMTok ECPoint, value, sk *big.Int) (*ABCProof, error) {
+	if err := zkpcp.checkScalars("ABCProof", "value", value); err != nil {
 		return nil, err
 	}
-	uc, err := rand.Int(rand.Reader, zkpcp.C.Params().N)
+	if value.Sign() == 0 {
+		return NewABCProof(zkpcp, CM, CMTok, value, sk, Left)
+	}
+	return NewABCProof(zkpcp, CM, CMTok, value, sk, Right)
+}
+
+// abcProver is an ABCProof between the commit and the response phase, it
+// keeps the witness and the random values around
+type abcProver struct {
+	B, C, T1, T2, T3, CToken ECPoint
+	PK                       ECPoint
+	disjuncAC                *disjunctiveProver
+	cTokenProof              *consistencyProver
+	value, sk, isk           *big.Int // isk = inv(sk)
+	ua                       *big.Int // instead of sk and isk if delegated
+	u1, u2, u3, ub, uc       *big.Int
+	delegated                bool
+}
+
+// abcKey is the auditor secret key sk of an ABCProof with its inverse and
+// PK = skH, so a batch of proofs for the same sk computes them only once. The
+// key of a delegated proof only has PK and the randomness ua of CM.
+type abcKey struct {
+	sk, isk *big.Int
+	PK      ECPoint
+	ua      *big.Int
+}
+
+// newABCKey returns the abcKey for sk, which must not be zero
+func newABCKey(zkpcp ZKPCurveParams, sk, isk *big.Int) abcKey {
+	return abcKey{sk: sk, isk: isk, PK: zkpcp.Mult(zkpcp.H, sk)}
+}
+
+// cTokenTranscript returns what the challenge of the nested ConsistencyProof
+// is computed from
+func (prover *abcProver) cTokenTranscript(zkpcp ZKPCurveParams, msg []byte) *Transcript {
+	return consistencyTranscript(zkpcp, prover.C, prover.CToken, prover.PK,
+		prover.cTokenProof.T1, prover.cTokenProof.T2, msg, nil)
+}
+
+// abcCommit computes B, C, T1, T2 and the commitments of the nested
+// DisjunctiveProof and ConsistencyProof of an ABCProof
+func abcCommit(zkpcp ZKPCurveParams, rnd io.Reader, CM, CMTok ECPoint, value, sk *big.Int, option Side) (*abcProver, error) {
+
+	// We cannot check that CM log is actually the value, but the verification should catch that
+	if err := zkpcp.checkScalars("ABCProof", "value, sk", value, sk); err != nil {
+		return nil, err
+	}
+	if sk.Sign() == 0 {
+		return nil, rangeError("ABCProof", "sk is zero")
+	}
+	if !zkpcp.validPoints(CM, CMTok) {
+		return nil, curveError("ABCProof")
+	}
+
+	var inv *big.Int
+	if option == Right && value.Sign() != 0 {
+		inv = new(big.Int).ModInverse(value, zkpcp.C.Params().N)
+	}
+	key := newABCKey(zkpcp, sk, new(big.Int).ModInverse(sk, zkpcp.C.Params().N))
+	return abcCommitKey(zkpcp, rnd, CM, CMTok, value, inv, key, option)
+}
+
+// abcCommitKey is abcCommit for checked scalars, with inv = inv(value) for
+// option Right, and the key already computed
+func abcCommitKey(zkpcp ZKPCurveParams, rnd io.Reader, CM, CMTok ECPoint, value, inv *big.Int, key abcKey, option Side) (*abcProver, error) {
+	// like ua = 0 for a delegated proof, CMTok = Zero is a token for every PK
+	if CM.IsZero() || CMTok.IsZero() {
+		return nil, rangeError("ABCProof", "CM or CMTok is Zero, the randomness of CM is zero")
+	}
+	sk := key.sk
+	delegated := key.ua != nil
+	// a delegated proof shows CM = vG + uaH instead of CM = vG + inv(sk)CMTok,
+	// and CM = uaH instead of CMTok = skCM in the first branch of disjuncAC
+	Ta, djX := CMTok, sk
+	djBase, djResult := abcDisjunctiveBases(zkpcp, CM, CMTok, delegated)
+	if delegated {
+		Ta, djX = zkpcp.H, key.ua
+	}
+
+	us, err := zkpcp.randScalars(rnd, 5)
 	if err != nil {
 		return nil, err
 	}
+	u1, u2, u3, ub, uc := us[0], us[1], us[2], us[3], us[4]
 
 	B := ECPoint{}
 	C := ECPoint{}
-	CToken := zkpcp.Mult(zkpcp.Mult(zkpcp.H, sk), uc)
+	c := big.NewInt(0)
+	PK := key.PK
+	CToken := zkpcp.Mult(PK, uc)
 
-	var disjuncAC *DisjunctiveProof
+	var disjuncAC *disjunctiveProver
 	var e error
 	// Disjunctive Proof of a = 0 or c = 1
 	if option == Left && value.Cmp(BigZero) == 0 {
 		// MUST: a = 0! ; side = left
-		// No inverse if value=0; set B to 0.  Do we confirm somewhere else that a=0?
+		// 0 has no inverse, so B = 0G + ubH commits to 0 directly. Do we confirm somewhere else that a=0?
 		B = PedCommitR(zkpcp, big.NewInt(0), ub)
 
 		// C = 0 + ucH
@@ -102,31 +325,38 @@ func NewABCProof(zkpcp ZKPCurveParams, CM, CMTok ECPoint, value, sk *big.Int, op
 
 		// CM is considered the "base" of CMTok since it would be only uaH and not ua sk H
 		// C - G is done regardless of the c = 0 or 1 because in the case c = 0 it does matter what that random number is
-		disjuncAC, e = NewDisjunctiveProof(zkpcp, CM, CMTok, zkpcp.H, zkpcp.Sub(C, zkpcp.G), sk, Left)
+		disjuncAC, e = disjunctiveCommit(zkpcp, rnd, djBase, djResult, zkpcp.H, zkpcp.Sub(C, zkpcp.G), djX, Left)
 	} else if option == Right && value.Cmp(BigZero) != 0 {
 		// MUST: c = 1! ; side = right
 
-		B = PedCommitR(zkpcp, new(big.Int).ModInverse(value, zkpcp.C.Params().N), ub)
+		B = PedCommitR(zkpcp, inv, ub)
 
 		// C = G + ucH
-		C = PedCommitR(zkpcp, big.NewInt(1), uc)
+		c = big.NewInt(1)
+		C = PedCommitR(zkpcp, c, uc)
 
 		// Look at notes a couple lines above on what the input is like this
-		disjuncAC, e = NewDisjunctiveProof(zkpcp, CM, CMTok, zkpcp.H, zkpcp.Sub(C, zkpcp.G), uc, Right)
+		disjuncAC, e = disjunctiveCommit(zkpcp, rnd, djBase, djResult, zkpcp.H, zkpcp.Sub(C, zkpcp.G), uc, Right)
 	} else {
-		return &ABCProof{}, &errorProof{"ABCProof", "invalid side-value pair passed"}
+		return nil, sideError("ABCProof", "invalid side-value pair passed")
+	}
+
+	if e != nil {
+		return nil, subProofError("ABCProof", "disjunctiveProve within ABCProve failed to generate", e)
 	}
 
+	// C = cG + ucH and CToken = ucPK
+	cTokenProof, e := consistencyCommit(zkpcp, rnd, C, CToken, PK, c, uc)
 	if e != nil {
-		return &ABCProof{}, &errorProof{"ABCProof", "disjunctiveProve within ABCProve failed to generate"}
+		return nil, subProofError("ABCProof", "consistencyProve within ABCProve failed to generate", e)
 	}
 
-	// CMTok is Ta for the rest of the proof
+	// CMTok, or H if delegated, is Ta for the rest of the proof
 	// T1 = u1G + u2Ta
 	// u1G
 	u1G := zkpcp.Mult(zkpcp.G, u1)
 	// u2Ta
-	u2Ta := zkpcp.Mult(CMTok, u2)
+	u2Ta := zkpcp.Mult(Ta, u2)
 	// Sum the above two
 	T1 := zkpcp.Add(u1G, u2Ta)
 
@@ -138,156 +368,416 @@ func NewABCProof(zkpcp ZKPCurveParams, CM, CMTok ECPoint, value, sk *big.Int, op
 	// Sum of the above two
 	T2 := zkpcp.Add(u1B, u3H)
 
-	// chal = HASH(G,H,CM,CMTok,B,C,T1,T2)
-	Challenge := GenerateChallenge(zkpcp, zkpcp.G.Bytes(), zkpcp.H.Bytes(),
-		CM.Bytes(), CMTok.Bytes(),
-		B.Bytes(), C.Bytes(),
-		T1.Bytes(), T2.Bytes())
+	// T3 = u2PK, answered by the same k as T1
+	T3 := zkpcp.Mult(PK, u2)
+
+	return &abcProver{
+		B: B, C: C, T1: T1, T2: T2, T3: T3, CToken: CToken, PK: PK,
+		disjuncAC:   disjuncAC,
+		cTokenProof: cTokenProof,
+		value:       value, sk: sk, isk: key.isk, ua: key.ua,
+		u1: u1, u2: u2, u3: u3, ub: ub, uc: uc,
+		delegated: delegated,
+	}, nil
+}
+
+// respond finishes the ABCProof for challenge Challenge, where djChallenge and
+// ctChallenge are the challenges of the nested DisjunctiveProof and
+// ConsistencyProof
+func (prover *abcProver) respond(zkpcp ZKPCurveParams, Challenge, djChallenge, ctChallenge *big.Int) *ABCProof {
+	value := prover.value
 
 	// j = u1 + v * chal
-	j := new(big.Int).Add(u1, new(big.Int).Mul(value, Challenge))
+	j := new(big.Int).Add(prover.u1, new(big.Int).Mul(value, Challenge))
 	j = new(big.Int).Mod(j, zkpcp.C.Params().N)
 
-	// k = u2 + inv(sk) * chal
-	// inv(sk)
-	isk := new(big.Int).ModInverse(sk, zkpcp.C.Params().N)
-	k := new(big.Int).Add(u2, new(big.Int).Mul(isk, Challenge))
+	// k = u2 + inv(sk) * chal, or u2 + ua * chal if delegated
+	kw := prover.isk
+	if prover.delegated {
+		kw = prover.ua
+	}
+	k := new(big.Int).Add(prover.u2, new(big.Int).Mul(kw, Challenge))
 	k = new(big.Int).Mod(k, zkpcp.C.Params().N)
 
 	// l = u3 + (uc - v * ub) * chal
-	temp1 := new(big.Int).Sub(uc, new(big.Int).Mul(value, ub))
-	l := new(big.Int).Add(u3, new(big.Int).Mul(temp1, Challenge))
+	temp1 := new(big.Int).Sub(prover.uc, new(big.Int).Mul(value, prover.ub))
+	l := new(big.Int).Add(prover.u3, new(big.Int).Mul(temp1, Challenge))
+	l = new(big.Int).Mod(l, zkpcp.C.Params().N)
 
 	return &ABCProof{
-		B,
-		C,
-		T1,
-		T2,
+		prover.B,
+		prover.C,
+		prover.T1,
+		prover.T2,
+		prover.T3,
 		Challenge,
-		j, k, l, CToken,
-		disjuncAC}, nil
+		j, k, l, prover.CToken,
+		prover.disjuncAC.respond(zkpcp, djChallenge),
+		prover.cTokenProof.respond(zkpcp, ctChallenge),
+		prover.delegated}
+}
 
+// Verify checks if ABCProof aProof with appropriate commits CM and CMTok is
+// correct, and that its CToken is ucPK for the auditor public key PK = skH and
+// the uc in C. It does not check that CMTok is a token for PK too, see
+// VerifyWithAuditor, unless the proof is Delegated. A Delegated proof shows the
+// relation for ua, so it checks CMTok = uaPK as well.
+func (aProof *ABCProof) Verify(zkpcp ZKPCurveParams, CM, CMTok, PK ECPoint) (bool, error) {
+	return aProof.verify(zkpcp, CM, CMTok, PK, nil, nil, nil)
 }
 
-// Verify checks if ABCProof aProof with appropriate commits CM and CMTok is correct
-func (aProof *ABCProof) Verify(zkpcp ZKPCurveParams, CM, CMTok ECPoint) (bool, error) {
+// VerifyWithTrace is the same as Verify and also returns a VerifyTrace of the
+// verification, with the traces of the nested DisjunctiveProof and
+// ConsistencyProof. The trace is returned if the proof does not verify too.
+func (aProof *ABCProof) VerifyWithTrace(zkpcp ZKPCurveParams, CM, CMTok, PK ECPoint) (*VerifyTrace, error) {
+	tr := newTrace("ABCProof")
+	_, err := aProof.verify(zkpcp, CM, CMTok, PK, nil, nil, tr)
+	tr.result(err)
+	return tr, err
+}
+
+// VerifyWithMessage is the same as Verify for an ABCProof generated by
+// NewABCProofWithMessage, which only verifies for the same msg
+func (aProof *ABCProof) VerifyWithMessage(zkpcp ZKPCurveParams, CM, CMTok, PK ECPoint, msg []byte) (bool, error) {
+	return aProof.verify(zkpcp, CM, CMTok, PK, msg, nil, nil)
+}
+
+// VerifyArgs implements Verifiable, args are CM, CMTok and PK like for Verify
+func (aProof *ABCProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("ABCProof.VerifyArgs", args)
+	CM, CMTok, PK := a.point(), a.point(), a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return aProof.Verify(zkpcp, CM, CMTok, PK)
+}
+
+// verify is Verify for the proof bound to msg with the challenges computed by
+// session s, recorded in trace tr, all of which may be nil
+func (aProof *ABCProof) verify(zkpcp ZKPCurveParams, CM, CMTok, PK ECPoint, msg []byte, s *VerifierSession, tr *VerifyTrace) (bool, error) {
+
+	if aProof == nil {
+		return false, malformedError("ABCVerify", "passed proof is nil")
+	}
+
+	if !zkpcp.validPoints(CM, CMTok, PK) || !zkpcp.onCurve(aProof.B, aProof.C, aProof.T1, aProof.T2, aProof.T3, aProof.CToken) ||
+		(aProof.disjuncAC != nil && !zkpcp.onCurve(aProof.disjuncAC.T1, aProof.disjuncAC.T2)) ||
+		(aProof.cTokenProof != nil && !zkpcp.onCurve(aProof.cTokenProof.T1, aProof.cTokenProof.T2)) {
+		return false, curveError("ABCVerify")
+	}
+	if CM.IsZero() || CMTok.IsZero() {
+		return false, inputError("ABCVerify", "CM and CMTok must not be Zero")
+	}
+	if err := zkpcp.canonicalScalars("ABCVerify", "Challenge, j, k, l", aProof.Challenge, aProof.j, aProof.k, aProof.l); err != nil {
+		return false, err
+	}
+	tr.points("CM, CMTok, PK, B, C, T1, T2, CToken, T3", CM, CMTok, PK, aProof.B, aProof.C, aProof.T1, aProof.T2, aProof.CToken, aProof.T3)
+	tr.scalars("Challenge, j, k, l", aProof.Challenge, aProof.j, aProof.k, aProof.l)
+
+	var Challenge *big.Int
+	if aProof.Delegated {
+		Challenge = tr.challenge(delegatedTranscript(
+			abcTranscript(zkpcp, CM, CMTok, aProof.B, aProof.C, aProof.T1, aProof.T2, aProof.CToken, aProof.T3, msg, tr)))
+	} else {
+		Challenge = s.abcChallenge(zkpcp, CM, CMTok, aProof.B, aProof.C, aProof.T1, aProof.T2, aProof.CToken, aProof.T3, msg, tr)
+	}
+
+	// chal = HASH(msg,G,H,CM,CMTok,B,C,T1,T2,CToken,T3)
+	if !scalarsEqual(Challenge, aProof.Challenge) {
+		return false, challengeError("ABCVerify", "challenge mismatch", Challenge, aProof.Challenge)
+	}
 
 	// Notes in ABCProof talk about why the Disjunc takes in this specific input even though it looks non-intuitive
 	// Here it is important that you subtract exactly 1 G from the aProof.C because that only allows for you to prove c = 1!
-	_, status := aProof.disjuncAC.Verify(zkpcp, CM, CMTok, zkpcp.H, zkpcp.Sub(aProof.C, zkpcp.G))
+	djTrace := tr.nest("DisjunctiveProof")
+	djBase, djResult := abcDisjunctiveBases(zkpcp, CM, CMTok, aProof.Delegated)
+	_, status := aProof.disjuncAC.verify(zkpcp, djBase, djResult, zkpcp.H, zkpcp.Sub(aProof.C, zkpcp.G), msg, s, djTrace)
+	djTrace.result(status)
 
 	if status != nil {
-		return false, &errorProof{"ABCVerify", "ABCProof for disjuncAC is false or not generated properly"}
+		return false, subProofError("ABCVerify", "ABCProof for disjuncAC is false or not generated properly", status)
 	}
 
-	Challenge := GenerateChallenge(zkpcp, zkpcp.G.Bytes(), zkpcp.H.Bytes(),
-		CM.Bytes(), CMTok.Bytes(),
-		aProof.B.Bytes(), aProof.C.Bytes(),
-		aProof.T1.Bytes(), aProof.T2.Bytes())
-
-	// chal = HASH(G,H,CM,CMTok,B,C,T1,T2)
-	if Challenge.Cmp(aProof.Challenge) != 0 {
-		return false, &errorProof{"ABCVerify", "proof contains incorrect challenge"}
+	// CToken = ucPK for the same uc as in C = cG + ucH
+	ctTrace := tr.nest("ConsistencyProof")
+	_, err := aProof.cTokenProof.verify(zkpcp, aProof.C, aProof.CToken, PK, msg, nil, ctTrace)
+	ctTrace.result(err)
+	if err != nil {
+		return false, subProofError("ABCVerify", "CToken does not match C and PK", err)
 	}
 
-	// chalCM + T1 ?= jG + kCMTok
-	// chalCM
-	chalA := zkpcp.Mult(CM, Challenge)
-	// + T1
-	lhs1 := zkpcp.Add(chalA, aProof.T1)
-	//jG
-	jG := zkpcp.Mult(zkpcp.G, aProof.j)
-	// kCMTok
-	kCMTok := zkpcp.Mult(CMTok, aProof.k)
-	// jG + kCMTok
-	rhs1 := zkpcp.Add(jG, kCMTok)
+	if err := aProof.verifyResponses(zkpcp, CM, CMTok, tr); err != nil {
+		return false, err
+	}
 
-	if !lhs1.Equal(rhs1) {
-		return false, &errorProof{"ABCProof", "cCM + T1 != jG + kCMTok"}
+	// chalCMTok + T3 ?= kPK, checked as kPK - chalCMTok ?= T3
+	if aProof.Delegated {
+		lhs3 := zkpcp.MultiMult([]ECPoint{PK, CMTok}, []*big.Int{aProof.k, new(big.Int).Neg(aProof.Challenge)})
+		if err := tr.equation("ABCVerify", 3, "kPK - cCMTok != T3", lhs3, aProof.T3); err != nil {
+			return false, err
+		}
 	}
 
-	// cC + T2 ?= jB + lH
-	chalC := zkpcp.Mult(aProof.C, Challenge)
-	lhs2 := zkpcp.Add(chalC, aProof.T2)
+	return true, nil
+}
 
-	jB := zkpcp.Mult(aProof.B, aProof.j)
-	lH := zkpcp.Mult(zkpcp.H, aProof.l)
-	rhs2 := zkpcp.Add(jB, lH)
+// VerifyWithAuditor does the checks of Verify and also checks that CMTok is
+// uaPK for the ua in CM, so the auditor can decrypt both CMTok and CToken with
+// the secret key of PK. PK is the auditor public key and has to come from the
+// verifier, e.g. from the list of registered auditors, never from the prover.
+func (aProof *ABCProof) VerifyWithAuditor(zkpcp ZKPCurveParams, CM, CMTok, PK ECPoint) (bool, error) {
+	if ok, err := aProof.Verify(zkpcp, CM, CMTok, PK); !ok || aProof.Delegated {
+		return ok, err
+	}
 
-	if !lhs2.Equal(rhs2) {
-		return false, &errorProof{"ABCVerify", "cC + T2 != jB + lH"}
+	// chalH + T3 ?= kPK, checked as kPK - chalH ?= T3
+	lhs := zkpcp.MultiMult([]ECPoint{PK, zkpcp.H}, []*big.Int{aProof.k, new(big.Int).Neg(aProof.Challenge)})
+	if !pointsEqual(lhs, aProof.T3) {
+		return false, equationError("ABCVerify", 3, "kPK - cH != T3", lhs, aProof.T3)
 	}
 
 	return true, nil
 }
 
-// Bytes returns a byte slice with a serialized representation of ABCProof proof
+// verifyResponses checks the verification equations of aProof for its
+// challenge, which has to be checked by the caller together with the nested
+// DisjunctiveProof, and records them in tr if it is not nil
+func (aProof *ABCProof) verifyResponses(zkpcp ZKPCurveParams, CM, CMTok ECPoint, tr *VerifyTrace) error {
+	negC := new(big.Int).Neg(aProof.Challenge)
+
+	// chalCM + T1 ?= jG + kCMTok, checked as jG + kCMTok - chalCM ?= T1,
+	// with H in place of CMTok if delegated
+	Ta, eq1 := CMTok, "jG + kCMTok - cCM != T1"
+	if aProof.Delegated {
+		Ta, eq1 = zkpcp.H, "jG + kH - cCM != T1"
+	}
+	lhs1 := zkpcp.MultiMult([]ECPoint{zkpcp.G, Ta, CM}, []*big.Int{aProof.j, aProof.k, negC})
+
+	if err := tr.equation("ABCProof", 1, eq1, lhs1, aProof.T1); err != nil {
+		return err
+	}
+
+	// cC + T2 ?= jB + lH, checked as jB + lH - cC ?= T2
+	lhs2 := zkpcp.MultiMult([]ECPoint{aProof.B, zkpcp.H, aProof.C}, []*big.Int{aProof.j, aProof.l, negC})
+
+	return tr.equation("ABCVerify", 2, "jB + lH - cC != T2", lhs2, aProof.T2)
+}
+
+// abcProofEncodingVersion is the first byte of a serialized ABCProof. It was
+// bumped from proofEncodingVersion when CToken was bound into the challenge
+// and cTokenProof was added, and again when T3 was added. Older proofs can not
+// be verified anymore.
+const abcProofEncodingVersion byte = 0x03
+
+// abcDelegatedEncodingVersion is the first byte of a serialized Delegated
+// ABCProof, which has the layout of abcProofEncodingVersion
+const abcDelegatedEncodingVersion byte = 0x04
+
+// abcProofLen is the length of a serialized ABCProof, which embeds a
+// serialized DisjunctiveProof and ConsistencyProof at the end
+var abcProofLen = 1 + 6*fixedPointLen + 4*fixedScalarLen + disjunctiveProofLen + consistencyProofLen
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *ABCProof) String() string {
+	if proof == nil {
+		return "ABCProof(nil)"
+	}
+	return proofString("ABCProof", "B", proof.B, "C", proof.C, "T1", proof.T1, "T2", proof.T2, "T3", proof.T3,
+		"Challenge", proof.Challenge, "j", proof.j, "k", proof.k, "l", proof.l,
+		"CToken", proof.CToken, "disjuncAC", proof.disjuncAC, "cTokenProof", proof.cTokenProof,
+		"Delegated", proof.Delegated)
+}
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// ABCProof proof
 func (proof *ABCProof) Bytes() []byte {
 	var buf bytes.Buffer
 
-	WriteECPoint(&buf, proof.B)
-	WriteECPoint(&buf, proof.C)
-	WriteECPoint(&buf, proof.T1)
-	WriteECPoint(&buf, proof.T2)
-	WriteBigInt(&buf, proof.Challenge)
-	WriteBigInt(&buf, proof.j)
-	WriteBigInt(&buf, proof.k)
-	WriteBigInt(&buf, proof.l)
-	WriteECPoint(&buf, proof.CToken)
-	wire.WriteVarBytes(&buf, proof.disjuncAC.Bytes())
+	if proof.Delegated {
+		buf.WriteByte(abcDelegatedEncodingVersion)
+	} else {
+		buf.WriteByte(abcProofEncodingVersion)
+	}
+	writeFixedPoint(&buf, proof.B)
+	writeFixedPoint(&buf, proof.C)
+	writeFixedPoint(&buf, proof.T1)
+	writeFixedPoint(&buf, proof.T2)
+	writeFixedPoint(&buf, proof.T3)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.j)
+	writeFixedScalar(&buf, proof.k)
+	writeFixedScalar(&buf, proof.l)
+	writeFixedPoint(&buf, proof.CToken)
+	buf.Write(proof.disjuncAC.Bytes())
+	buf.Write(proof.cTokenProof.Bytes())
 
 	return buf.Bytes()
 }
 
+// Size returns the length of Bytes of ABCProof proof, which has a fixed size
+func (proof *ABCProof) Size() int {
+	return abcProofLen
+}
+
+// Clone returns a deep copy of ABCProof proof that shares no big.Int with it
+func (proof *ABCProof) Clone() *ABCProof {
+	if proof == nil {
+		return nil
+	}
+	return &ABCProof{
+		B:           clonePoint(proof.B),
+		C:           clonePoint(proof.C),
+		T1:          clonePoint(proof.T1),
+		T2:          clonePoint(proof.T2),
+		T3:          clonePoint(proof.T3),
+		Challenge:   cloneScalar(proof.Challenge),
+		j:           cloneScalar(proof.j),
+		k:           cloneScalar(proof.k),
+		l:           cloneScalar(proof.l),
+		CToken:      clonePoint(proof.CToken),
+		disjuncAC:   proof.disjuncAC.Clone(),
+		cTokenProof: proof.cTokenProof.Clone(),
+		Delegated:   proof.Delegated,
+	}
+}
+
+// Equal returns true if ABCProof proof and other have the same values in all
+// their fields, nested proofs included. It is not constant time.
+func (proof *ABCProof) Equal(other *ABCProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.B, other.B) &&
+		samePoint(proof.C, other.C) &&
+		samePoint(proof.T1, other.T1) &&
+		samePoint(proof.T2, other.T2) &&
+		samePoint(proof.T3, other.T3) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.j, other.j) &&
+		sameScalar(proof.k, other.k) &&
+		sameScalar(proof.l, other.l) &&
+		samePoint(proof.CToken, other.CToken) &&
+		proof.disjuncAC.Equal(other.disjuncAC) &&
+		proof.cTokenProof.Equal(other.cTokenProof) &&
+		proof.Delegated == other.Delegated
+}
+
 // NewABCProofFromBytes returns an ABCProof generated from the deserialization of
 // byte slice b
 func NewABCProofFromBytes(b []byte) (*ABCProof, error) {
-	proof := new(ABCProof)
-	buf := bytes.NewBuffer(b)
-	var err error
-	proof.B, err = ReadECPoint(buf)
-	if err != nil {
-		return nil, err
+	version := abcProofEncodingVersion
+	if len(b) > 0 && b[0] == abcDelegatedEncodingVersion {
+		version = abcDelegatedEncodingVersion
 	}
-	proof.C, err = ReadECPoint(buf)
-	if err != nil {
+	if err := checkVersionedHeader(b, abcProofLen, version, "ABCProofFromBytes"); err != nil {
 		return nil, err
 	}
-	proof.T1, err = ReadECPoint(buf)
-	if err != nil {
-		return nil, err
+	proof := &ABCProof{Delegated: version == abcDelegatedEncodingVersion}
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	for _, p := range []*ECPoint{&proof.B, &proof.C, &proof.T1, &proof.T2, &proof.T3} {
+		if *p, err = readFixedPoint(buf, "ABCProofFromBytes"); err != nil {
+			return nil, err
+		}
 	}
-	proof.T2, err = ReadECPoint(buf)
-	if err != nil {
+	if err = readFixedScalars(buf, "ABCProofFromBytes", &proof.Challenge, &proof.j, &proof.k, &proof.l); err != nil {
 		return nil, err
 	}
-	proof.Challenge, err = ReadBigInt(buf)
-	if err != nil {
+	if proof.CToken, err = readFixedPoint(buf, "ABCProofFromBytes"); err != nil {
 		return nil, err
 	}
-	proof.j, err = ReadBigInt(buf)
+	proof.disjuncAC, err = NewDisjunctiveProofFromBytes(buf.Next(disjunctiveProofLen))
 	if err != nil {
 		return nil, err
 	}
-	proof.k, err = ReadBigInt(buf)
+	proof.cTokenProof, err = NewConsistencyProofFromBytes(buf.Bytes())
 	if err != nil {
 		return nil, err
 	}
-	proof.l, err = ReadBigInt(buf)
-	if err != nil {
-		return nil, err
+	return proof, nil
+}
+
+type abcProofJSON struct {
+	B           string            `json:"b"`
+	C           string            `json:"c"`
+	T1          string            `json:"t1"`
+	T2          string            `json:"t2"`
+	T3          string            `json:"t3"`
+	Challenge   string            `json:"challenge"`
+	J           string            `json:"j"`
+	K           string            `json:"k"`
+	L           string            `json:"l"`
+	CToken      string            `json:"cToken"`
+	DisjuncAC   *DisjunctiveProof `json:"disjuncAC"`
+	CTokenProof *ConsistencyProof `json:"cTokenProof"`
+	Delegated   bool              `json:"delegated,omitempty"`
+}
+
+// MarshalJSON encodes every field of ABCProof proof, including the
+// unexported ones, with points as compressed hex and scalars as big-endian hex
+func (proof *ABCProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(abcProofJSON{
+		B:           pointToHex(proof.B),
+		C:           pointToHex(proof.C),
+		T1:          pointToHex(proof.T1),
+		T2:          pointToHex(proof.T2),
+		T3:          pointToHex(proof.T3),
+		Challenge:   scalarToHex(proof.Challenge),
+		J:           scalarToHex(proof.j),
+		K:           scalarToHex(proof.k),
+		L:           scalarToHex(proof.l),
+		CToken:      pointToHex(proof.CToken),
+		DisjuncAC:   proof.disjuncAC,
+		CTokenProof: proof.cTokenProof,
+		Delegated:   proof.Delegated,
+	})
+}
+
+// UnmarshalJSON decodes an ABCProof encoded with MarshalJSON. It fails if a
+// point is not on the curve or a scalar is not in [0, N)
+func (proof *ABCProof) UnmarshalJSON(b []byte) error {
+	var aux abcProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
 	}
-	proof.CToken, err = ReadECPoint(buf)
-	if err != nil {
-		return nil, err
+	if aux.DisjuncAC == nil {
+		return malformedError("UnmarshalJSON", "disjuncAC is missing")
 	}
-	disjuncBytes, err := wire.ReadVarBytes(buf, 100000, "disjunctProof")
-	if err != nil {
-		return nil, err
+	if aux.CTokenProof == nil {
+		return malformedError("UnmarshalJSON", "cTokenProof is missing")
 	}
-	proof.disjuncAC, err = NewDisjunctiveProofFromBytes(disjuncBytes)
-	if err != nil {
-		return nil, err
+	var err error
+	p := ABCProof{disjuncAC: aux.DisjuncAC, cTokenProof: aux.CTokenProof, Delegated: aux.Delegated}
+	if p.B, err = pointFromHex(aux.B, "b"); err != nil {
+		return err
 	}
-	return proof, nil
+	if p.C, err = pointFromHex(aux.C, "c"); err != nil {
+		return err
+	}
+	if p.T1, err = pointFromHex(aux.T1, "t1"); err != nil {
+		return err
+	}
+	if p.T2, err = pointFromHex(aux.T2, "t2"); err != nil {
+		return err
+	}
+	if p.T3, err = pointFromHex(aux.T3, "t3"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.j, err = scalarFromHex(aux.J, "j"); err != nil {
+		return err
+	}
+	if p.k, err = scalarFromHex(aux.K, "k"); err != nil {
+		return err
+	}
+	if p.l, err = scalarFromHex(aux.L, "l"); err != nil {
+		return err
+	}
+	if p.CToken, err = pointFromHex(aux.CToken, "cToken"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
 }
@@ -1,8 +1,14 @@
 package zksigma
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math/big"
+	"strings"
 	"testing"
 )
 
@@ -23,12 +29,12 @@ func TestABCProof(t *testing.T) {
 	aProof, status := NewABCProof(TestCurve, A, AToken, value, sk, Right)
 
 	if status != nil {
-		proofStatus(status.(*errorProof))
+		proofStatus(status.(*ProofError))
 		t.Logf("ABCProof RIGHT failed to generate!\n")
 		t.Fatalf("ABCProof RIGHT failed\n")
 	}
 
-	check, err := aProof.Verify(TestCurve, A, AToken)
+	check, err := aProof.Verify(TestCurve, A, AToken, PK)
 	if !check || err != nil {
 		t.Logf("ABCProof RIGHT Failed to verify!\n")
 		t.Fatalf("ABCVerify RIGHT failed\n")
@@ -38,12 +44,12 @@ func TestABCProof(t *testing.T) {
 	aProof, status = NewABCProof(TestCurve, A, AToken, big.NewInt(0), sk, Left)
 
 	if status != nil {
-		proofStatus(status.(*errorProof))
+		proofStatus(status.(*ProofError))
 		t.Logf("ABCProof LEFT failed to generate!\n")
 		t.Fatalf("ABCProof LEFT failed\n")
 	}
 
-	check, err = aProof.Verify(TestCurve, A, AToken)
+	check, err = aProof.Verify(TestCurve, A, AToken, PK)
 	if !check || err != nil {
 		t.Logf("ABCProof LEFT Failed to verify!\n")
 		t.Fatalf("ABCVerify LEFT failed\n")
@@ -65,7 +71,7 @@ func TestABCProof(t *testing.T) {
 
 	t.Logf("Next ABCVerify should catch false proof\n")
 
-	check, err = aProof.Verify(TestCurve, A, AToken)
+	check, err = aProof.Verify(TestCurve, A, AToken, PK)
 	if check || err == nil {
 		t.Logf("ABCVerify: should have failed on false proof check!\n")
 		t.Fatalf("ABCVerify: not working...\n")
@@ -90,24 +96,623 @@ func TestABCProofSerialization(t *testing.T) {
 	aProof, status := NewABCProof(TestCurve, A, AToken, value, sk, Right)
 
 	if status != nil {
-		proofStatus(status.(*errorProof))
+		proofStatus(status.(*ProofError))
 		t.Logf("ABCProof RIGHT failed to generate!\n")
 		t.Fatalf("ABCProof RIGHT failed\n")
 	}
-	aProof, status = NewABCProofFromBytes(aProof.Bytes())
+	encoded := aProof.Bytes()
+	if len(encoded) != abcProofLen {
+		t.Fatalf("ABCProof serialized to %d bytes, expected %d\n", len(encoded), abcProofLen)
+	}
+	if !bytes.Equal(encoded, aProof.Bytes()) {
+		t.Fatalf("ABCProof serialization is not deterministic\n")
+	}
+
+	aProof, status = NewABCProofFromBytes(encoded)
 
 	if status != nil {
-		proofStatus(status.(*errorProof))
+		proofStatus(status.(*ProofError))
 		t.Fatalf("ABCProof failed to deserialize!\n")
 	}
+	if !bytes.Equal(encoded, aProof.Bytes()) {
+		t.Fatalf("ABCProof serialization changed after round trip\n")
+	}
 
-	check, err := aProof.Verify(TestCurve, A, AToken)
+	check, err := aProof.Verify(TestCurve, A, AToken, PK)
 	if !check || err != nil {
 		t.Fatalf("ABCVerify failed: %s\n", err.Error())
 	}
 }
 
+// TestABCProofJSON tests if the ABC Proof survives a JSON round trip and that
+// tampering with any single field of the encoding breaks verification.
+func TestABCProofJSON(t *testing.T) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	value, _ := rand.Int(rand.Reader, big.NewInt(10000000000))
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	CM, ua, err := PedCommit(TestCurve, value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	CMTok := TestCurve.Mult(PK, ua)
+
+	aProof, err := NewABCProof(TestCurve, CM, CMTok, value, sk, Right)
+	if err != nil {
+		t.Fatalf("ABCProof RIGHT failed to generate: %v\n", err)
+	}
+
+	b, err := json.Marshal(aProof)
+	if err != nil {
+		t.Fatalf("ABCProof failed to marshal: %v\n", err)
+	}
+	decoded := new(ABCProof)
+	if err := json.Unmarshal(b, decoded); err != nil {
+		t.Fatalf("ABCProof failed to unmarshal: %v\n", err)
+	}
+	if ok, err := decoded.Verify(TestCurve, CM, CMTok, PK); !ok || err != nil {
+		t.Fatalf("ABCVerify failed after JSON round trip: %v\n", err)
+	}
+
+	otherPoint := hex.EncodeToString(compressPoint(TestCurve.C, TestCurve.G))
+	tamper := func(fields map[string]interface{}, name string) {
+		v := fields[name].(string)
+		if len(v) == 66 { // compressed point
+			fields[name] = otherPoint
+			return
+		}
+		s, _ := new(big.Int).SetString(v, 16)
+		s.Add(s, big.NewInt(1))
+		s.Mod(s, TestCurve.C.Params().N)
+		fields[name] = hex.EncodeToString(s.Bytes())
+	}
+
+	for _, name := range []string{"b", "c", "t1", "t2", "t3", "challenge", "j", "k", "l", "cToken",
+		"disjuncAC.t1", "disjuncAC.t2", "disjuncAC.c", "disjuncAC.c1",
+		"disjuncAC.c2", "disjuncAC.s1", "disjuncAC.s2",
+		"cTokenProof.t1", "cTokenProof.t2", "cTokenProof.challenge",
+		"cTokenProof.s1", "cTokenProof.s2"} {
+		var fields map[string]interface{}
+		json.Unmarshal(b, &fields)
+		if i := strings.Index(name, "."); i >= 0 {
+			tamper(fields[name[:i]].(map[string]interface{}), name[i+1:])
+		} else {
+			tamper(fields, name)
+		}
+		tampered, _ := json.Marshal(fields)
+
+		proof := new(ABCProof)
+		if err := json.Unmarshal(tampered, proof); err != nil {
+			continue
+		}
+		if ok, err := proof.Verify(TestCurve, CM, CMTok, PK); ok || err == nil {
+			t.Fatalf("ABCVerify accepted proof with tampered field %s\n", name)
+		}
+	}
+
+	var fields map[string]interface{}
+	json.Unmarshal(b, &fields)
+	fields["t1"] = "02" + hex.EncodeToString(make([]byte, 32))
+	tampered, _ := json.Marshal(fields)
+	if err := json.Unmarshal(tampered, new(ABCProof)); err == nil {
+		t.Fatalf("ABCProof unmarshaled a point that is not on the curve\n")
+	}
+
+	json.Unmarshal(b, &fields)
+	fields["j"] = hex.EncodeToString(TestCurve.C.Params().N.Bytes())
+	tampered, _ = json.Marshal(fields)
+	if err := json.Unmarshal(tampered, new(ABCProof)); err == nil {
+		t.Fatalf("ABCProof unmarshaled a scalar that is not in [0, N)\n")
+	}
+}
+
+// TestABCProofFromBytesErrors tests that malformed ABC Proof encodings are rejected.
+func TestABCProofFromBytesErrors(t *testing.T) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	CM, ua, err := PedCommit(TestCurve, big.NewInt(10))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	aProof, err := NewABCProof(TestCurve, CM, TestCurve.Mult(PK, ua), big.NewInt(10), sk, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	encoded := aProof.Bytes()
+
+	if _, err := NewABCProofFromBytes(encoded[:len(encoded)-1]); err == nil ||
+		!strings.Contains(err.Error(), "truncated buffer") {
+		t.Fatalf("truncated ABCProof should fail with truncated buffer, got %v\n", err)
+	}
+
+	badVersion := append([]byte{}, encoded...)
+	badVersion[0] = 0xff
+	if _, err := NewABCProofFromBytes(badVersion); err == nil ||
+		!strings.Contains(err.Error(), "unsupported encoding version") {
+		t.Fatalf("ABCProof with wrong version should fail, got %v\n", err)
+	}
+
+	// x = 5 has no y on secp256k1
+	offCurve := append([]byte{}, encoded...)
+	copy(offCurve[1:1+fixedPointLen], append([]byte{0x02}, make([]byte, fixedScalarLen)...))
+	offCurve[fixedPointLen] = 5
+	if _, err := NewABCProofFromBytes(offCurve); err == nil ||
+		!strings.Contains(err.Error(), "point not on curve") {
+		t.Fatalf("ABCProof with off-curve point should fail, got %v\n", err)
+	}
+}
+
+// TestABCProofCToken tests that CToken is bound into the challenge of an ABC
+// Proof and checked against the auditor public key.
+func TestABCProofCToken(t *testing.T) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(1000)
+	CM, ua, err := PedCommit(TestCurve, value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	CMTok := TestCurve.Mult(PK, ua)
+
+	// a proof made the old way, with a challenge that leaves out CToken
+	prover, err := abcCommit(TestCurve, rand.Reader, CM, CMTok, value, sk, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	djT1, djT2 := prover.disjuncAC.commitments()
+	djChallenge := disjunctiveChallenge(TestCurve, CM, CMTok, TestCurve.H, TestCurve.Sub(prover.C, TestCurve.G), djT1, djT2, nil)
+	oldChallenge := GenerateChallenge(TestCurve, TestCurve.G.Bytes(), TestCurve.H.Bytes(),
+		CM.Bytes(), CMTok.Bytes(), prover.B.Bytes(), prover.C.Bytes(), prover.T1.Bytes(), prover.T2.Bytes())
+	oldProof := prover.respond(TestCurve, oldChallenge, djChallenge, big.NewInt(0))
+	oldProof.cTokenProof = nil
+	if ok, err := oldProof.Verify(TestCurve, CM, CMTok, PK); ok || err == nil ||
+		!strings.Contains(err.Error(), "challenge mismatch") {
+		t.Fatalf("ABCVerify should reject a proof without CToken in the challenge with challenge mismatch, got %v\n", err)
+	}
+
+	proof, err := NewABCProof(TestCurve, CM, CMTok, value, sk, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	other, err := NewABCProof(TestCurve, CM, CMTok, value, sk, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	// CToken of another valid proof for the same commitment
+	swapped := *proof
+	swapped.CToken = other.CToken
+	if ok, err := swapped.Verify(TestCurve, CM, CMTok, PK); ok || err == nil ||
+		!strings.Contains(err.Error(), "challenge mismatch") {
+		t.Fatalf("ABCVerify should reject a swapped CToken with challenge mismatch, got %v\n", err)
+	}
+
+	// and its whole consistency proof
+	swapped = *proof
+	swapped.CToken, swapped.cTokenProof = other.CToken, other.cTokenProof
+	if ok, err := swapped.Verify(TestCurve, CM, CMTok, PK); ok || err == nil {
+		t.Fatalf("ABCVerify accepted a swapped CToken and cTokenProof\n")
+	}
+
+	// CToken is only valid for the auditor key it was made for
+	sk2, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	if ok, err := proof.Verify(TestCurve, CM, CMTok, TestCurve.Mult(TestCurve.H, sk2)); ok || err == nil ||
+		!strings.Contains(err.Error(), "CToken does not match C and PK") {
+		t.Fatalf("ABCVerify should reject a CToken for another PK, got %v\n", err)
+	}
+
+	// a CToken moved by H is rejected by the batch too, which checks the
+	// challenges and the equations of cTokenProof on its own
+	tampered := *proof
+	tampered.CToken = TestCurve.Add(proof.CToken, TestCurve.H)
+	if ok, err := VerifyABCBatch(TestCurve, []*ABCProof{other, &tampered}, []ECPoint{CM, CM}, []ECPoint{CMTok, CMTok}, []ECPoint{PK, PK}); ok ||
+		!errors.Is(err, ErrChallengeMismatch) || !strings.Contains(err.Error(), "proof 1 failed") {
+		t.Fatalf("VerifyABCBatch should reject a tampered CToken with challenge mismatch, got %v\n", err)
+	}
+
+	// encodings from before CToken was bound are rejected
+	old := proof.Bytes()[:abcProofLen-consistencyProofLen]
+	old[0] = proofEncodingVersion
+	if _, err := NewABCProofFromBytes(old); err == nil ||
+		!strings.Contains(err.Error(), "unsupported encoding version") {
+		t.Fatalf("ABCProof with the old encoding should fail with unsupported encoding version, got %v\n", err)
+	}
+}
+
+// TestABCProofVerifyWithAuditor tests that VerifyWithAuditor ties both CMTok
+// and CToken to the auditor public key.
+func TestABCProofVerifyWithAuditor(t *testing.T) {
+	N := TestCurve.C.Params().N
+	sk, _ := rand.Int(rand.Reader, N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(1000)
+	CM, ua, err := PedCommit(TestCurve, value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	CMTok := TestCurve.Mult(PK, ua)
+
+	for _, side := range []Side{Left, Right} {
+		v := value
+		if side == Left {
+			v = big.NewInt(0)
+		}
+		CMv := PedCommitR(TestCurve, v, ua)
+		proof, err := NewABCProof(TestCurve, CMv, CMTok, v, sk, side)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if ok, err := proof.VerifyWithAuditor(TestCurve, CMv, CMTok, PK); !ok || err != nil {
+			t.Fatalf("VerifyWithAuditor rejected a valid ABCProof: %v\n", err)
+		}
+	}
+
+	// CMTok and CToken for another key pass Verify for that key, but not
+	// VerifyWithAuditor for PK
+	sk2, _ := rand.Int(rand.Reader, N)
+	PK2 := TestCurve.Mult(TestCurve.H, sk2)
+	CMTok2 := TestCurve.Mult(PK2, ua)
+	proof2, err := NewABCProof(TestCurve, CM, CMTok2, value, sk2, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof2.VerifyWithAuditor(TestCurve, CM, CMTok2, PK2); !ok || err != nil {
+		t.Fatalf("VerifyWithAuditor rejected a valid ABCProof for PK2: %v\n", err)
+	}
+	if ok, err := proof2.VerifyWithAuditor(TestCurve, CM, CMTok2, PK); ok || err == nil {
+		t.Fatalf("VerifyWithAuditor accepted a CToken generated under another PK: %v\n", err)
+	}
+
+	// CToken for PK, but CMTok for the key of the prover, which Verify does
+	// not catch: k answers for inv(sk2) on CMTok, so T3 = u2PK can not be met
+	prover, err := abcCommit(TestCurve, rand.Reader, CM, CMTok2, value, sk2, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	prover.PK = PK
+	prover.CToken = TestCurve.Mult(PK, prover.uc)
+	prover.cTokenProof, err = consistencyCommit(TestCurve, rand.Reader, prover.C, prover.CToken, PK, big.NewInt(1), prover.uc)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	prover.T3 = TestCurve.Mult(PK, prover.u2)
+	djT1, djT2 := prover.disjuncAC.commitments()
+	djChallenge := disjunctiveChallenge(TestCurve, CM, CMTok2, TestCurve.H, TestCurve.Sub(prover.C, TestCurve.G), djT1, djT2, nil)
+	ctChallenge := prover.cTokenTranscript(TestCurve, nil).ChallengeScalar("c")
+	Challenge := abcChallenge(TestCurve, CM, CMTok2, prover.B, prover.C, prover.T1, prover.T2, prover.CToken, prover.T3, nil)
+	mixed := prover.respond(TestCurve, Challenge, djChallenge, ctChallenge)
+	if ok, err := mixed.Verify(TestCurve, CM, CMTok2, PK); !ok || err != nil {
+		t.Fatalf("ABCProof with CToken for PK and CMTok for PK2 should pass Verify: %v\n", err)
+	}
+	ok, err := mixed.VerifyWithAuditor(TestCurve, CM, CMTok2, PK)
+	var pe *ProofError
+	if ok || !errors.As(err, &pe) || pe.Code != ErrCodeEquationFailed || pe.Equation != 3 {
+		t.Fatalf("VerifyWithAuditor accepted a CMTok for another PK: %v\n", err)
+	}
+
+	var nilProof *ABCProof
+	if ok, err := nilProof.VerifyWithAuditor(TestCurve, CM, CMTok, PK); ok || !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("VerifyWithAuditor accepted a nil proof: %v\n", err)
+	}
+}
+
+// TestABCProofAuto tests that NewABCProofAuto picks the side from the value
+// and that explicit wrong sides fail without returning a proof.
+func TestABCProofAuto(t *testing.T) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+
+	for _, c := range []struct {
+		value      *big.Int
+		side, flip Side
+	}{{big.NewInt(0), Left, Right}, {big.NewInt(1), Right, Left}, {big.NewInt(1000), Right, Left}} {
+		CM, ua, err := PedCommit(TestCurve, c.value)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		CMTok := TestCurve.Mult(PK, ua)
+
+		proof, err := NewABCProofAuto(TestCurve, CM, CMTok, c.value, sk)
+		if err != nil {
+			t.Fatalf("NewABCProofAuto failed for %v: %v\n", c.value, err)
+		}
+		if ok, err := proof.Verify(TestCurve, CM, CMTok, PK); !ok || err != nil {
+			t.Fatalf("ABCProof of NewABCProofAuto did not verify for %v: %v\n", c.value, err)
+		}
+		// C commits to 0 on the Left side and to 1 on the Right one, which the
+		// auditor can check with inv(sk)
+		expect := big.NewInt(0)
+		if c.side == Right {
+			expect = big.NewInt(1)
+		}
+		// ucH = inv(sk) * CToken
+		ucH := TestCurve.Mult(proof.CToken, new(big.Int).ModInverse(sk, TestCurve.C.Params().N))
+		if !TestCurve.Sub(proof.C, ucH).Equal(TestCurve.Mult(TestCurve.G, expect)) {
+			t.Fatalf("NewABCProofAuto picked the wrong side for %v\n", c.value)
+		}
+
+		for _, side := range []Side{c.flip, Side(2)} {
+			wrong, err := NewABCProof(TestCurve, CM, CMTok, c.value, sk, side)
+			if wrong != nil || !errors.Is(err, ErrInvalidSide) {
+				t.Fatalf("NewABCProof of %v on side %v gives %v, %v\n", c.value, side, wrong, err)
+			}
+		}
+	}
+
+	CM, ua, _ := PedCommit(TestCurve, big.NewInt(5))
+	if proof, err := NewABCProofAuto(TestCurve, CM, TestCurve.Mult(PK, ua), nil, sk); proof != nil || !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("NewABCProofAuto of a nil value gives %v, %v\n", proof, err)
+	}
+}
+
+// TestABCProofSideSizes checks that the encoding of an ABCProof does not tell
+// the side it was made on: a Left proof of 0 and Right proofs of small and
+// large values all have the same length, whatever the size of their scalars
+func TestABCProofSideSizes(t *testing.T) {
+	N := TestCurve.C.Params().N
+	sizes := make(map[int][]string)
+	for ii := 0; ii < 10; ii++ {
+		sk, _ := rand.Int(rand.Reader, N)
+		if ii == 0 {
+			sk = big.NewInt(2) // a key whose inverse is large
+		}
+		PK := TestCurve.Mult(TestCurve.H, sk)
+		for _, c := range []struct {
+			value *big.Int
+			side  Side
+		}{{big.NewInt(0), Left}, {big.NewInt(1), Right}, {big.NewInt(1000), Right}, {new(big.Int).Sub(N, big.NewInt(1)), Right}} {
+			CM, r, err := PedCommit(TestCurve, c.value)
+			if err != nil {
+				t.Fatalf("%v\n", err)
+			}
+			proof, err := NewABCProof(TestCurve, CM, TestCurve.Mult(PK, r), c.value, sk, c.side)
+			if err != nil {
+				t.Fatalf("NewABCProof of %v on side %v failed: %v\n", c.value, c.side, err)
+			}
+			n := len(proof.Bytes())
+			if n != proof.Size() {
+				t.Fatalf("ABCProof of %v on side %v has %d bytes, Size is %d\n", c.value, c.side, n, proof.Size())
+			}
+			sizes[n] = append(sizes[n], fmt.Sprintf("%v on side %v", c.value, c.side))
+		}
+	}
+	if len(sizes) != 1 {
+		t.Fatalf("ABCProofs have different sizes depending on the side: %v\n", sizes)
+	}
+}
+
+// TestABCProofZeroValue makes sure a commitment to 0 is proved on the Left
+// side with B = ubH and without inverting 0, and that 0 on the Right side is
+// an ErrInvalidSide instead of a panic, on curves with and without tables.
+func TestABCProofZeroValue(t *testing.T) {
+	for name, zkpcp := range map[string]ZKPCurveParams{"secp256k1": TestCurve, "P-256": P256Curve} {
+		sk, _ := rand.Int(rand.Reader, zkpcp.C.Params().N)
+		PK := zkpcp.Mult(zkpcp.H, sk)
+		zero := big.NewInt(0)
+		CM, ua, err := PedCommit(zkpcp, zero)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		CMTok := zkpcp.Mult(PK, ua)
+
+		prover, err := abcCommit(zkpcp, rand.Reader, CM, CMTok, zero, sk, Left)
+		if err != nil {
+			t.Fatalf("%s: %v\n", name, err)
+		}
+		if !prover.B.Equal(zkpcp.Mult(zkpcp.H, prover.ub)) {
+			t.Fatalf("%s: B of value 0 is not ubH\n", name)
+		}
+
+		proof, err := NewABCProof(zkpcp, CM, CMTok, zero, sk, Left)
+		if err != nil {
+			t.Fatalf("%s: %v\n", name, err)
+		}
+		if ok, err := proof.Verify(zkpcp, CM, CMTok, PK); !ok || err != nil {
+			t.Fatalf("%s: ABCProof of value 0 did not verify: %v\n", name, err)
+		}
+
+		if proof, err := NewABCProof(zkpcp, CM, CMTok, zero, sk, Right); proof != nil || !errors.Is(err, ErrInvalidSide) {
+			t.Fatalf("%s: ABCProof of value 0 on the Right side gives %v, %v\n", name, proof, err)
+		}
+	}
+}
+
+// TestABCProofIdentityCM makes sure a commitment with randomness 0 gets no
+// ABCProof: for value 0 it is CM = Zero with CMTok = Zero, a token for every PK,
+// so both the prover and the verifier reject it instead of failing on a nested
+// commitment that is Zero
+func TestABCProofIdentityCM(t *testing.T) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	zero := big.NewInt(0)
+	CM := PedCommitR(TestCurve, zero, zero)
+	if !CM.IsZero() {
+		t.Fatalf("commitment to 0 with randomness 0 is %v, not Zero\n", CM)
+	}
+
+	for _, side := range []Side{Left, Right} {
+		if _, err := NewABCProof(TestCurve, CM, Zero, zero, sk, side); !errors.Is(err, ErrValueOutOfRange) {
+			t.Fatalf("ABCProof for CM = Zero on side %v gives %v\n", side, err)
+		}
+	}
+	// the batch and the ProverKey prover share the check
+	if _, err := NewABCProofBatch(TestCurve, []ECPoint{Zero}, []ECPoint{Zero}, []*big.Int{zero}, sk); !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("NewABCProofBatch for CM = Zero gives %v\n", err)
+	}
+	key, err := NewProverKey(TestCurve, sk)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if _, err := key.NewABCProof(CM, Zero, zero, Left); !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("ProverKey.NewABCProof for CM = Zero gives %v\n", err)
+	}
+	value := big.NewInt(5)
+	CM5 := PedCommitR(TestCurve, value, zero)
+	if _, err := NewABCProof(TestCurve, CM5, Zero, value, sk, Right); !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("ABCProof for CMTok = Zero gives %v\n", err)
+	}
+
+	CM5, r, _ := PedCommit(TestCurve, value)
+	CMTok := TestCurve.Mult(PK, r)
+	proof, err := NewABCProof(TestCurve, CM5, CMTok, value, sk, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	for name, cms := range map[string][2]ECPoint{"CM": {Zero, CMTok}, "CMTok": {CM5, Zero}, "both": {Zero, Zero}} {
+		if ok, err := proof.Verify(TestCurve, cms[0], cms[1], PK); ok || !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("ABCProof verified with %s = Zero: %v\n", name, err)
+		}
+		if _, err := NewABCVerifierSession(TestCurve, cms[0], cms[1], PK); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("NewABCVerifierSession with %s = Zero gives %v\n", name, err)
+		}
+	}
+}
+
 // TestBreakABCProve tests if the ABC Proof can will catch invalid proofs.
+// inScalarRange returns true if s is in [0, N)
+func inScalarRange(s *big.Int) bool {
+	return s != nil && s.Sign() >= 0 && s.Cmp(TestCurve.C.Params().N) < 0
+}
+
+// TestABCProofScalarsReduced makes sure that every scalar in a generated
+// ABCProof is reduced mod N, also when uc - v * ub is negative.
+func TestABCProofScalarsReduced(t *testing.T) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	large := new(big.Int).Sub(TestCurve.C.Params().N, big.NewInt(1))
+
+	for ii := 0; ii < 10; ii++ {
+		for _, value := range []*big.Int{big.NewInt(0), big.NewInt(1), large} {
+			CM, ua, err := PedCommit(TestCurve, value)
+			if err != nil {
+				t.Fatalf("%v\n", err)
+			}
+			side := Right
+			if value.Sign() == 0 {
+				side = Left
+			}
+			proof, err := NewABCProof(TestCurve, CM, TestCurve.Mult(PK, ua), value, sk, side)
+			if err != nil {
+				t.Fatalf("%v\n", err)
+			}
+			dj := proof.disjuncAC
+			for name, s := range map[string]*big.Int{
+				"challenge": proof.Challenge, "j": proof.j, "k": proof.k, "l": proof.l,
+				"c": dj.C, "c1": dj.C1, "c2": dj.C2, "s1": dj.S1, "s2": dj.S2,
+			} {
+				if !inScalarRange(s) {
+					t.Fatalf("ABCProof for %v has %s = %v outside of [0, N)\n", value, name, s)
+				}
+			}
+		}
+	}
+}
+
+// TestABCProofNonCanonical makes sure j + N, which verifies like j, is
+// rejected by Verify, VerifyABCBatch, ComposedProof and every decoding, and
+// so are the hidden scalars of the sub proofs
+func TestABCProofNonCanonical(t *testing.T) {
+	N := TestCurve.C.Params().N
+	sk, _ := rand.Int(rand.Reader, N)
+	value := big.NewInt(10)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	CM, ua, err := PedCommit(TestCurve, value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	CMTok := TestCurve.Mult(PK, ua)
+	aProof, err := NewABCProof(TestCurve, CM, CMTok, value, sk, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	scalars := func(p *ABCProof) map[string]*big.Int {
+		dj, ct := p.disjuncAC, p.cTokenProof
+		return map[string]*big.Int{
+			"Challenge": p.Challenge, "j": p.j, "k": p.k, "l": p.l,
+			"disjuncAC.C": dj.C, "disjuncAC.C1": dj.C1, "disjuncAC.C2": dj.C2,
+			"disjuncAC.S1": dj.S1, "disjuncAC.S2": dj.S2,
+			"cTokenProof.Challenge": ct.Challenge, "cTokenProof.S1": ct.S1, "cTokenProof.S2": ct.S2,
+		}
+	}
+	// the prover reduces every response, also l = u3 + (uc - v * ub) * chal
+	// where uc - v * ub is negative about half the time
+	zeroCM, zeroR, _ := PedCommit(TestCurve, big.NewInt(0))
+	left, err := NewABCProof(TestCurve, zeroCM, TestCurve.Mult(PK, zeroR), big.NewInt(0), sk, Left)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	for _, p := range []*ABCProof{aProof, left} {
+		for name, s := range scalars(p) {
+			if s.Sign() < 0 || s.Cmp(N) >= 0 {
+				t.Fatalf("NewABCProof gives %s = %v, which is not in [0, N)\n", name, s)
+			}
+		}
+	}
+
+	for name := range scalars(aProof) {
+		shifted := aProof.Clone()
+		s := scalars(shifted)[name]
+		s.Add(s, N)
+		if ok, err := shifted.Verify(TestCurve, CM, CMTok, PK); ok || !errors.Is(err, ErrNonCanonicalScalar) {
+			t.Fatalf("ABCVerify with %s + N gives %v, %v\n", name, ok, err)
+		}
+	}
+
+	shifted := aProof.Clone()
+	shifted.j.Add(shifted.j, N)
+	if ok, err := VerifyABCBatch(TestCurve, []*ABCProof{aProof, shifted}, []ECPoint{CM, CM},
+		[]ECPoint{CMTok, CMTok}, []ECPoint{PK, PK}); ok || !errors.Is(err, ErrNonCanonicalScalar) {
+		t.Fatalf("VerifyABCBatch with j + N gives %v, %v\n", ok, err)
+	}
+
+	statement := ABCStatement{CM: CM, CMTok: CMTok, PubKey: PK}
+	cp, err := NewComposedProof(TestCurve, statement.WithWitness(ABCWitness{Value: value, SK: sk}, Right))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	sub := cp.Proofs[0].(*ABCProof)
+	sub.j.Add(sub.j, N)
+	if ok, err := cp.Verify(TestCurve, statement); ok || !errors.Is(err, ErrNonCanonicalScalar) {
+		t.Fatalf("ComposedProof with j + N gives %v, %v\n", ok, err)
+	}
+
+	// j comes after B, C, T1, T2, T3 and the challenge
+	encoded := aProof.Bytes()
+	at := 1 + 5*fixedPointLen + fixedScalarLen
+	if !bytes.Equal(encoded[at:at+fixedScalarLen], aProof.j.FillBytes(make([]byte, fixedScalarLen))) {
+		t.Fatalf("j is not encoded at offset %d\n", at)
+	}
+	N.FillBytes(encoded[at : at+fixedScalarLen])
+	if _, err := NewABCProofFromBytes(encoded); !errors.Is(err, ErrNonCanonicalScalar) {
+		t.Fatalf("NewABCProofFromBytes with j = N gives %v\n", err)
+	}
+
+	b, err := json.Marshal(aProof)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	for _, j := range []string{
+		hex.EncodeToString(new(big.Int).Add(aProof.j, N).Bytes()),
+		"00" + hex.EncodeToString(aProof.j.Bytes()),
+	} {
+		var fields map[string]interface{}
+		json.Unmarshal(b, &fields)
+		fields["j"] = j
+		tampered, _ := json.Marshal(fields)
+		if err := json.Unmarshal(tampered, new(ABCProof)); !errors.Is(err, ErrNonCanonicalScalar) {
+			t.Fatalf("ABCProof unmarshaled j = %s: %v\n", j, err)
+		}
+	}
+
+	var fields map[string]interface{}
+	json.Unmarshal(b, &fields)
+	fields["t1"] = hex.EncodeToString(nonCanonicalPoint(t))
+	tampered, _ := json.Marshal(fields)
+	if err := json.Unmarshal(tampered, new(ABCProof)); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("ABCProof unmarshaled a non-canonical t1: %v\n", err)
+	}
+}
+
 func TestBreakABCProve(t *testing.T) {
 	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
 	value, _ := rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic rarnge"
@@ -157,7 +762,7 @@ func TestBreakABCProve(t *testing.T) {
 	// Sum of the above two
 	T2X, T2Y := TestCurve.C.Add(u1B.X, u1B.Y, u3H.X, u3H.Y)
 
-	// c = HASH(G,H,CM,CMTok,B,C,T1,T2)
+	// c = HASH(G,H,CM,CMTok,B,C,T1,T2), without CToken
 	Challenge := GenerateChallenge(TestCurve, TestCurve.G.Bytes(), TestCurve.H.Bytes(),
 		CM.Bytes(), CMTok.Bytes(),
 		B.Bytes(), C.Bytes(),
@@ -183,20 +788,50 @@ func TestBreakABCProve(t *testing.T) {
 		C,
 		ECPoint{T1X, T1Y},
 		ECPoint{T2X, T2Y},
+		TestCurve.Mult(PK, u2),
 		Challenge,
 		j, k, l, CToken,
-		disjuncAC}
+		disjuncAC, nil, false}
 
 	t.Logf("Attempting to pass malicious true proof into verification function\n")
 	t.Logf("This test should throw a couple error messages in debug\n")
 
-	check, err := evilProof.Verify(TestCurve, CM, CMTok)
+	check, err := evilProof.Verify(TestCurve, CM, CMTok, PK)
 	if check || err == nil {
 		t.Logf("ABCVerify - EVIL: accepted attack input! c = 2, should fail...\n")
 		t.Fatalf("ABCVerify - EVIL: failed to catch attack!\n")
 	}
 }
 
+// abcVerifyAllocs is the allocation budget of verifying an ABCProof on
+// secp256k1. Most of it goes to the group checks of CM, CMTok and PK, the
+// points of the six verification equations and the challenges of the three
+// transcripts.
+const abcVerifyAllocs = 300
+
+func TestABCVerifyAllocs(t *testing.T) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	for _, value := range []*big.Int{big.NewInt(0), big.NewInt(5)} {
+		side := Right
+		if value.Sign() == 0 {
+			side = Left
+		}
+		CM, r, err := PedCommit(TestCurve, value)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		CMTok := TestCurve.Mult(PK, r)
+		proof, err := NewABCProof(TestCurve, CM, CMTok, value, sk, side)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if allocs := testing.AllocsPerRun(10, func() { proof.Verify(TestCurve, CM, CMTok, PK) }); allocs > abcVerifyAllocs {
+			t.Fatalf("TestABCVerifyAllocs - verifying %v allocates %v times, budget is %v\n", value, allocs, abcVerifyAllocs)
+		}
+	}
+}
+
 func BenchmarkABCProve_0(b *testing.B) {
 	value := big.NewInt(0)
 
@@ -248,9 +883,10 @@ func BenchmarkABCVerify_0(b *testing.B) {
 
 	CMTok := TestCurve.Mult(PK, randVal)
 	proof, _ := NewABCProof(TestCurve, CM, CMTok, value, sk, Left)
+	b.ReportAllocs()
 	b.ResetTimer()
 	for ii := 0; ii < b.N; ii++ {
-		proof.Verify(TestCurve, CM, CMTok)
+		proof.Verify(TestCurve, CM, CMTok, PK)
 	}
 }
 
@@ -267,8 +903,210 @@ func BenchmarkABCVerify_1(b *testing.B) {
 
 	CMTok := TestCurve.Mult(PK, randVal)
 	proof, _ := NewABCProof(TestCurve, CM, CMTok, value, sk, Right)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CM, CMTok, PK)
+	}
+}
+
+func BenchmarkABCVerify_1_VariableTime(b *testing.B) {
+	defer func(old bool) { ConstantTimeVerify = old }(ConstantTimeVerify)
+	ConstantTimeVerify = false
+
+	value, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+
+	CM, randVal, err := PedCommit(TestCurve, value)
+	if err != nil {
+		b.Fatalf("%v\n", err)
+	}
+
+	CMTok := TestCurve.Mult(PK, randVal)
+	proof, _ := NewABCProof(TestCurve, CM, CMTok, value, sk, Right)
+	b.ReportAllocs()
 	b.ResetTimer()
 	for ii := 0; ii < b.N; ii++ {
-		proof.Verify(TestCurve, CM, CMTok)
+		proof.Verify(TestCurve, CM, CMTok, PK)
+	}
+}
+
+func TestABCProofWithMessage(t *testing.T) {
+	value := big.NewInt(7)
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	CM, r, _ := PedCommit(TestCurve, value)
+	CMTok := TestCurve.Mult(PK, r)
+	msg := []byte("transaction 1")
+	defer func(old bool) { LegacyChallenges = old }(LegacyChallenges)
+
+	for _, legacy := range []bool{false, true} {
+		LegacyChallenges = legacy
+
+		proof, err := NewABCProofWithMessage(TestCurve, CM, CMTok, value, sk, Right, msg)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if ok, err := proof.VerifyWithMessage(TestCurve, CM, CMTok, PK, msg); !ok || err != nil {
+			t.Fatalf("ABCProof with a message failed to verify, legacy %v: %v\n", legacy, err)
+		}
+		for _, other := range [][]byte{[]byte("transaction 2"), nil} {
+			if ok, err := proof.VerifyWithMessage(TestCurve, CM, CMTok, PK, other); ok || !errors.Is(err, ErrChallengeMismatch) {
+				t.Fatalf("ABCProof for %q verified for %q, legacy %v: %v\n", msg, other, legacy, err)
+			}
+		}
+		if ok, _ := proof.Verify(TestCurve, CM, CMTok, PK); ok {
+			t.Fatalf("ABCProof with a message verified without it, legacy %v\n", legacy)
+		}
+		decoded, err := NewABCProofFromBytes(proof.Bytes())
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if ok, err := decoded.VerifyWithMessage(TestCurve, CM, CMTok, PK, msg); !ok || err != nil {
+			t.Fatalf("ABCProof with a message failed to verify after deserialization: %v\n", err)
+		}
+
+		// an empty message is the same as none
+		plain, _ := NewABCProofWithRand(TestCurve, newSeededReader("abc"), CM, CMTok, value, sk, Right)
+		empty, _ := newABCProof(TestCurve, newSeededReader("abc"), CM, CMTok, value, sk, Right, []byte{})
+		if !bytes.Equal(plain.Bytes(), empty.Bytes()) {
+			t.Fatalf("ABCProof with an empty message differs from one without, legacy %v\n", legacy)
+		}
+		if ok, err := plain.VerifyWithMessage(TestCurve, CM, CMTok, PK, nil); !ok || err != nil {
+			t.Fatalf("ABCProof failed to verify with a nil message: %v\n", err)
+		}
+	}
+}
+
+func TestABCProofDelegated(t *testing.T) {
+	sk := scalarFromSeed("abc delegated sk")
+	PK := TestCurve.Mult(TestCurve.H, sk)
+
+	for _, tc := range []struct {
+		value  *big.Int
+		option Side
+	}{
+		{big.NewInt(1000), Right},
+		{big.NewInt(0), Left},
+	} {
+		CM, ua, err := PedCommit(TestCurve, tc.value)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		CMTok := TestCurve.Mult(PK, ua)
+
+		delegated, err := NewABCProofDelegated(TestCurve, CM, CMTok, PK, tc.value, ua, tc.option)
+		if err != nil {
+			t.Fatalf("Delegated ABCProof for %v failed to generate: %v\n", tc.value, err)
+		}
+		if !delegated.Delegated {
+			t.Fatalf("ABCProof of NewABCProofDelegated is not Delegated\n")
+		}
+		if ok, err := delegated.Verify(TestCurve, CM, CMTok, PK); !ok || err != nil {
+			t.Fatalf("Delegated ABCProof for %v failed to verify: %v\n", tc.value, err)
+		}
+		if ok, err := delegated.VerifyWithAuditor(TestCurve, CM, CMTok, PK); !ok || err != nil {
+			t.Fatalf("Delegated ABCProof for %v failed to verify with the auditor: %v\n", tc.value, err)
+		}
+		if _, err := delegated.VerifyWithTrace(TestCurve, CM, CMTok, PK); err != nil {
+			t.Fatalf("Delegated ABCProof for %v failed to verify with a trace: %v\n", tc.value, err)
+		}
+		s, err := NewVerifierSession(TestCurve, CM, CMTok)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if ok, err := s.VerifyABC(delegated, PK); !ok || err != nil {
+			t.Fatalf("VerifierSession rejected a Delegated ABCProof: %v\n", err)
+		}
+
+		// the proof for sk still verifies, and neither passes for the other
+		proof, err := NewABCProof(TestCurve, CM, CMTok, tc.value, sk, tc.option)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if ok, err := proof.Verify(TestCurve, CM, CMTok, PK); !ok || err != nil {
+			t.Fatalf("ABCProof for %v failed to verify: %v\n", tc.value, err)
+		}
+		for _, p := range []*ABCProof{proof, delegated} {
+			flipped := p.Clone()
+			flipped.Delegated = !flipped.Delegated
+			if ok, err := flipped.Verify(TestCurve, CM, CMTok, PK); ok || !errors.Is(err, ErrChallengeMismatch) {
+				t.Fatalf("ABCProof with Delegated %v verified as %v: %v\n", p.Delegated, flipped.Delegated, err)
+			}
+		}
+
+		// CMTok of another auditor key
+		otherPK := TestCurve.Mult(TestCurve.H, scalarFromSeed("abc delegated other sk"))
+		if ok, _ := delegated.Verify(TestCurve, CM, TestCurve.Mult(otherPK, ua), PK); ok {
+			t.Fatalf("Delegated ABCProof verified for the CMTok of another PK\n")
+		}
+		if ok, _ := delegated.Verify(TestCurve, CM, CMTok, otherPK); ok {
+			t.Fatalf("Delegated ABCProof verified for another PK\n")
+		}
+
+		decoded, err := NewABCProofFromBytes(delegated.Bytes())
+		if err != nil {
+			t.Fatalf("Delegated ABCProof failed to deserialize: %v\n", err)
+		}
+		if delegated.Bytes()[0] != abcDelegatedEncodingVersion || !decoded.Equal(delegated) {
+			t.Fatalf("Delegated ABCProof changed in serialization\n")
+		}
+		js, err := json.Marshal(delegated)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		var fromJSON ABCProof
+		if err := json.Unmarshal(js, &fromJSON); err != nil || !fromJSON.Equal(delegated) {
+			t.Fatalf("Delegated ABCProof changed in JSON: %v\n", err)
+		}
+
+		// batches fall back to Verify for a Delegated proof
+		if ok, err := VerifyABCBatch(TestCurve, []*ABCProof{proof, delegated, proof},
+			[]ECPoint{CM, CM, CM}, []ECPoint{CMTok, CMTok, CMTok}, []ECPoint{PK, PK, PK}); !ok || err != nil {
+			t.Fatalf("VerifyABCBatch rejected a Delegated ABCProof: %v\n", err)
+		}
+		if ok, err := VerifyABCBatch(TestCurve, []*ABCProof{proof, delegated},
+			[]ECPoint{CM, CM}, []ECPoint{CMTok, CMTok}, []ECPoint{PK, otherPK}); ok || err == nil {
+			t.Fatalf("VerifyABCBatch accepted a Delegated ABCProof for another PK\n")
+		}
+	}
+}
+
+func TestABCProofDelegatedInputs(t *testing.T) {
+	PK := TestCurve.Mult(TestCurve.H, scalarFromSeed("abc delegated sk"))
+	value := big.NewInt(7)
+	CM, ua, err := PedCommit(TestCurve, value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	CMTok := TestCurve.Mult(PK, ua)
+	otherUA := scalarFromSeed("abc delegated ua")
+
+	if _, err := NewABCProofDelegated(TestCurve, TestCurve.Mult(TestCurve.G, value), Zero, PK, value, big.NewInt(0), Right); !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("Delegated ABCProof generated for ua = 0: %v\n", err)
+	}
+	if _, err := NewABCProofDelegated(TestCurve, CM, CMTok, PK, value, otherUA, Right); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Delegated ABCProof generated for a wrong ua: %v\n", err)
+	}
+	if _, err := NewABCProofDelegated(TestCurve, CM, CMTok, PK, big.NewInt(8), ua, Right); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Delegated ABCProof generated for a wrong value: %v\n", err)
+	}
+	if _, err := NewABCProofDelegated(TestCurve, CM, TestCurve.Mult(PK, otherUA), PK, value, ua, Right); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Delegated ABCProof generated for a wrong CMTok: %v\n", err)
+	}
+	if _, err := NewABCProofDelegated(TestCurve, CM, Zero, Zero, value, ua, Right); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Delegated ABCProof generated for PK = Zero: %v\n", err)
+	}
+	if _, err := NewABCProofDelegated(TestCurve, CM, ECPoint{big.NewInt(1), big.NewInt(1)}, PK, value, ua, Right); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("Delegated ABCProof generated for a CMTok off the curve: %v\n", err)
+	}
+
+	// the proof is the same for the same random values
+	a, _ := NewABCProofDelegatedWithRand(TestCurve, newSeededReader("abc delegated"), CM, CMTok, PK, value, ua, Right)
+	b, _ := NewABCProofDelegatedWithRand(TestCurve, newSeededReader("abc delegated"), CM, CMTok, PK, value, ua, Right)
+	if !a.Equal(b) {
+		t.Fatalf("Delegated ABCProofs of the same random values differ\n")
 	}
 }
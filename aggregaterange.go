@@ -0,0 +1,203 @@
+package zksigma
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// AggregateRangeProof is a proof that commitments CM_i = v_iG + r_iH hide
+// values whose sum is in [0, 2^bits), e.g. the balances of all accounts for a
+// solvency audit, with one BitRangeProof instead of one per commitment. The sum
+// S of the commitments commits to sum(v_i) with randomness sum(r_i), and the
+// verifier adds the commitments up itself, so the proof is for no other
+// aggregate.
+//
+//  Public: generator points G and H, CM_1 ... CM_n, bits
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows v_i, r_i with sum(v_i) in [0, 2^bits)
+//  S = sum(CM_i)                       S = sum(CM_i)
+//  BitRangeProof for S, sum(v_i), sum(r_i)
+//
+//  BitRangeProof --------------------->
+//                                      BitRangeProof ?= true for S, bits
+//
+// The sum is only known mod N. It says nothing about the single values: a
+// negative balance v_i = N - k is offset by the others, and only a sum that ends
+// up negative mod N, that is in [N - 2^bits, N), fails. The prover adds the
+// values up as integers in [0, N) and refuses a sum of 2^bits or more, so an
+// honest prover never wraps around.
+type AggregateRangeProof struct {
+	Sum *BitRangeProof // sum(v_i) is in [0, 2^bits)
+}
+
+// aggregateCommitment computes S = sum(CM_i)
+func aggregateCommitment(zkpcp ZKPCurveParams, CMs []ECPoint) ECPoint {
+	S := Zero
+	for _, CM := range CMs {
+		S = zkpcp.Add(S, CM)
+	}
+	return S
+}
+
+// NewAggregateRangeProof generates a proof that the values of CMs add up to a
+// value in [0, 2^bits), where values and randomnesses open CMs. The values have
+// to be in [0, N). It fails if their sum as integers is 2^bits or more.
+func NewAggregateRangeProof(zkpcp ZKPCurveParams, CMs []ECPoint, values, randomnesses []*big.Int, bits int) (*AggregateRangeProof, error) {
+	return NewAggregateRangeProofWithRand(zkpcp, entropy(), CMs, values, randomnesses, bits)
+}
+
+// NewAggregateRangeProofWithRand is the same as NewAggregateRangeProof, but
+// reads its random values from rnd instead of the EntropySource
+func NewAggregateRangeProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	CMs []ECPoint, values, randomnesses []*big.Int, bits int) (*AggregateRangeProof, error) {
+
+	if err := checkRangeBits(zkpcp, bits, "AggregateRangeProve"); err != nil {
+		return nil, err
+	}
+	if len(CMs) == 0 {
+		return nil, inputError("AggregateRangeProve", "no commitments to aggregate")
+	}
+	if len(values) != len(CMs) || len(randomnesses) != len(CMs) {
+		return nil, inputError("AggregateRangeProve", "CMs, values and randomnesses must have the same length")
+	}
+	for i := range values {
+		if err := zkpcp.checkScalars("AggregateRangeProve", fmt.Sprintf("value %d, randomness %d", i, i),
+			values[i], randomnesses[i]); err != nil {
+			return nil, err
+		}
+	}
+	if !zkpcp.validPoints(CMs...) {
+		return nil, curveError("AggregateRangeProve")
+	}
+
+	sum := new(big.Int)
+	R := new(big.Int)
+	for i, CM := range CMs {
+		if !Open(zkpcp, values[i], randomnesses[i], CM) {
+			return nil, inputError("AggregateRangeProve", fmt.Sprintf("value and randomness %d do not produce CM", i))
+		}
+		sum.Add(sum, values[i])
+		R.Add(R, randomnesses[i])
+	}
+	if sum.BitLen() > bits {
+		return nil, inputError("AggregateRangeProve", fmt.Sprintf("sum of the values is not in [0, 2^%d)", bits))
+	}
+	R.Mod(R, zkpcp.C.Params().N)
+
+	rp, err := NewBitRangeProofWithRand(zkpcp, rnd, aggregateCommitment(zkpcp, CMs), sum, R, bits)
+	if err != nil {
+		return nil, subProofError("AggregateRangeProve", "BitRangeProve within AggregateRangeProve failed to generate", err)
+	}
+	return &AggregateRangeProof{rp}, nil
+}
+
+// Verify checks if AggregateRangeProof proof shows that the values of CMs add
+// up to a value in [0, 2^bits)
+func (proof *AggregateRangeProof) Verify(zkpcp ZKPCurveParams, CMs []ECPoint, bits int) (bool, error) {
+	if proof == nil || proof.Sum == nil {
+		return false, malformedError("AggregateRangeProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if len(CMs) == 0 {
+		return false, inputError("AggregateRangeProof.Verify", "no commitments to aggregate")
+	}
+	if !zkpcp.validPoints(CMs...) {
+		return false, curveError("AggregateRangeProof.Verify")
+	}
+
+	if _, err := proof.Sum.Verify(zkpcp, aggregateCommitment(zkpcp, CMs), bits); err != nil {
+		return false, subProofError("AggregateRangeProof.Verify", "sum of CMs is not in range", err)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are CMs and bits as int like for
+// Verify
+func (proof *AggregateRangeProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("AggregateRangeProof.VerifyArgs", args)
+	CMs, bits := a.points(), a.int()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CMs, bits)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *AggregateRangeProof) String() string {
+	if proof == nil {
+		return "AggregateRangeProof(nil)"
+	}
+	return proofString("AggregateRangeProof", "Sum", proof.Sum)
+}
+
+// Bytes returns a byte slice with a serialized representation of
+// AggregateRangeProof proof, which is the one of its BitRangeProof
+func (proof *AggregateRangeProof) Bytes() []byte {
+	return proof.Sum.Bytes()
+}
+
+// Size returns the length of Bytes of AggregateRangeProof proof without serializing it
+func (proof *AggregateRangeProof) Size() int {
+	return proof.Sum.Size()
+}
+
+// Clone returns a deep copy of AggregateRangeProof proof that shares no big.Int with it
+func (proof *AggregateRangeProof) Clone() *AggregateRangeProof {
+	if proof == nil {
+		return nil
+	}
+	return &AggregateRangeProof{
+		Sum: proof.Sum.Clone(),
+	}
+}
+
+// Equal returns true if AggregateRangeProof proof and other have the same values in all
+// their fields, nested proofs included. It is not constant time.
+func (proof *AggregateRangeProof) Equal(other *AggregateRangeProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return proof.Sum.Equal(other.Sum)
+}
+
+// NewAggregateRangeProofFromBytes returns an AggregateRangeProof generated from
+// the deserialization of byte slice b
+func NewAggregateRangeProofFromBytes(b []byte) (*AggregateRangeProof, error) {
+	rp, err := NewBitRangeProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return &AggregateRangeProof{rp}, nil
+}
+
+type aggregateRangeProofJSON struct {
+	Sum *BitRangeProof `json:"sum"`
+}
+
+// MarshalJSON encodes AggregateRangeProof proof as its BitRangeProof Sum
+func (proof *AggregateRangeProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(aggregateRangeProofJSON{
+		Sum: proof.Sum,
+	})
+}
+
+// UnmarshalJSON decodes an AggregateRangeProof encoded with MarshalJSON. It
+// fails if a point is not on the curve, a scalar is not in [0, N) or a nested
+// proof is missing
+func (proof *AggregateRangeProof) UnmarshalJSON(b []byte) error {
+	var aux aggregateRangeProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	if aux.Sum == nil {
+		return malformedError("UnmarshalJSON", "sum is missing")
+	}
+	p := AggregateRangeProof{Sum: aux.Sum}
+	*proof = p
+	return nil
+}
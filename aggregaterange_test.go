@@ -0,0 +1,166 @@
+package zksigma
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// makeBalances returns commitments to values, with their randomnesses
+func makeBalances(t *testing.T, values []*big.Int) ([]ECPoint, []*big.Int) {
+	CMs := make([]ECPoint, len(values))
+	rs := make([]*big.Int, len(values))
+	for i, v := range values {
+		CM, r, err := PedCommit(TestCurve, v)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		CMs[i], rs[i] = CM, r
+	}
+	return CMs, rs
+}
+
+func TestAggregateRangeProof(t *testing.T) {
+	for _, n := range []int{1, 2, 1000} {
+		values := make([]*big.Int, n)
+		for i := range values {
+			values[i], _ = rand.Int(rand.Reader, big.NewInt(1<<20))
+		}
+		CMs, rs := makeBalances(t, values)
+
+		proof, err := NewAggregateRangeProof(TestCurve, CMs, values, rs, 32)
+		if err != nil {
+			t.Fatalf("AggregateRangeProof of %d commitments failed to generate: %v\n", n, err)
+		}
+		if ok, err := proof.Verify(TestCurve, CMs, 32); !ok || err != nil {
+			t.Fatalf("AggregateRangeProof of %d commitments failed to verify: %v\n", n, err)
+		}
+		decoded, err := NewAggregateRangeProofFromBytes(proof.Bytes())
+		if err != nil {
+			t.Fatalf("AggregateRangeProof of %d commitments failed to deserialize: %v\n", n, err)
+		}
+		if ok, err := decoded.Verify(TestCurve, CMs, 32); !ok || err != nil || !decoded.Equal(proof) {
+			t.Fatalf("AggregateRangeProof of %d commitments failed to verify after deserialization: %v\n", n, err)
+		}
+
+		// the verifier adds up the commitments it is given and no others
+		other, _, _ := PedCommit(TestCurve, big.NewInt(1))
+		if ok, err := proof.Verify(TestCurve, append(CMs[:n:n], other), 32); ok || err == nil {
+			t.Fatalf("AggregateRangeProof of %d commitments verified with one more\n", n)
+		}
+		if n > 1 {
+			if ok, err := proof.Verify(TestCurve, CMs[1:], 32); ok || err == nil {
+				t.Fatalf("AggregateRangeProof of %d commitments verified with one less\n", n)
+			}
+		}
+	}
+}
+
+func TestAggregateRangeProofZero(t *testing.T) {
+	values := []*big.Int{big.NewInt(0), big.NewInt(0), big.NewInt(0)}
+	CMs, rs := makeBalances(t, values)
+
+	proof, err := NewAggregateRangeProof(TestCurve, CMs, values, rs, 8)
+	if err != nil {
+		t.Fatalf("AggregateRangeProof of a sum of 0 failed to generate: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CMs, 8); !ok || err != nil {
+		t.Fatalf("AggregateRangeProof of a sum of 0 failed to verify: %v\n", err)
+	}
+}
+
+func TestAggregateRangeProofNegative(t *testing.T) {
+	N := TestCurve.C.Params().N
+
+	// -10 among balances that add up to 9 gives a sum of -1
+	values := []*big.Int{big.NewInt(4), new(big.Int).Sub(N, big.NewInt(10)), big.NewInt(5)}
+	CMs, rs := makeBalances(t, values)
+	if _, err := NewAggregateRangeProof(TestCurve, CMs, values, rs, 32); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("AggregateRangeProof generated for a sum of -1: %v\n", err)
+	}
+
+	// a BitRangeProof of the sum of the commitments cannot be made either
+	R := new(big.Int)
+	for _, r := range rs {
+		R.Add(R, r)
+	}
+	R.Mod(R, N)
+	S := aggregateCommitment(TestCurve, CMs)
+	if _, err := NewBitRangeProof(TestCurve, S, new(big.Int).Sub(N, big.NewInt(1)), R, 32); err == nil {
+		t.Fatalf("BitRangeProof generated for a sum of -1\n")
+	}
+
+	// nor does one of another commitment verify for it
+	fake, fakeR, _ := PedCommit(TestCurve, big.NewInt(9))
+	rp, err := NewBitRangeProof(TestCurve, fake, big.NewInt(9), fakeR, 32)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	forged := &AggregateRangeProof{rp}
+	if ok, err := forged.Verify(TestCurve, CMs, 32); ok || err == nil {
+		t.Fatalf("AggregateRangeProof of another aggregate verified for a sum of -1\n")
+	}
+}
+
+func TestAggregateRangeProofOverflow(t *testing.T) {
+	// 200 + 100 is not in [0, 2^8), though both values are
+	values := []*big.Int{big.NewInt(200), big.NewInt(100)}
+	CMs, rs := makeBalances(t, values)
+	if _, err := NewAggregateRangeProof(TestCurve, CMs, values, rs, 8); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("AggregateRangeProof generated for a sum of 300 in 8 bits: %v\n", err)
+	}
+
+	proof, err := NewAggregateRangeProof(TestCurve, CMs, values, rs, 9)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CMs, 8); ok || err == nil {
+		t.Fatalf("AggregateRangeProof of 9 bits verified for 8\n")
+	}
+}
+
+func TestAggregateRangeProofInputs(t *testing.T) {
+	values := []*big.Int{big.NewInt(3), big.NewInt(4)}
+	CMs, rs := makeBalances(t, values)
+
+	if _, err := NewAggregateRangeProof(TestCurve, nil, nil, nil, 8); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("AggregateRangeProof generated for no commitments: %v\n", err)
+	}
+	if _, err := NewAggregateRangeProof(TestCurve, CMs, values[:1], rs, 8); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("AggregateRangeProof generated for too few values: %v\n", err)
+	}
+	if _, err := NewAggregateRangeProof(TestCurve, CMs, values, []*big.Int{rs[1], rs[0]}, 8); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("AggregateRangeProof generated for swapped randomnesses: %v\n", err)
+	}
+	if _, err := NewAggregateRangeProof(TestCurve, CMs, values, rs, 0); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("AggregateRangeProof generated for 0 bits: %v\n", err)
+	}
+	off := ECPoint{big.NewInt(1), big.NewInt(1)}
+	if _, err := NewAggregateRangeProof(TestCurve, []ECPoint{CMs[0], off}, values, rs, 8); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("AggregateRangeProof generated for a commitment off the curve: %v\n", err)
+	}
+
+	proof, err := NewAggregateRangeProof(TestCurve, CMs, values, rs, 8)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, nil, 8); ok || !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("AggregateRangeProof verified for no commitments: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, []ECPoint{CMs[0], off}, 8); ok || !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("AggregateRangeProof verified for a commitment off the curve: %v\n", err)
+	}
+	for _, p := range []*AggregateRangeProof{nil, {}} {
+		if ok, err := p.Verify(TestCurve, CMs, 8); ok || !errors.Is(err, ErrMalformedProof) {
+			t.Fatalf("AggregateRangeProof %v gives %v\n", p, err)
+		}
+	}
+	if _, err := NewAggregateRangeProofFromBytes(proof.Bytes()[:proof.Size()-1]); err == nil {
+		t.Fatalf("AggregateRangeProof decoded from a short encoding\n")
+	}
+	if !bytes.Equal(proof.Clone().Bytes(), proof.Bytes()) {
+		t.Fatalf("AggregateRangeProof changed in Clone\n")
+	}
+}
@@ -0,0 +1,169 @@
+package zksigma
+
+import (
+	"crypto/elliptic"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/mit-dci/zksigma/btcec"
+)
+
+// CurveOps is the point arithmetic and encoding a ZKPCurveParams does on its
+// curve. The default backend is the elliptic.Curve of the ZKPCurveParams, on
+// secp256k1 with the fixed base tables of btcec, and WithBackend swaps in
+// another one, e.g. an optimized field implementation. Points are affine
+// coordinates as big.Int like everywhere else, so a backend converts them to
+// its own representation and back. The point at infinity is never passed in,
+// and a backend returns it as (0, 0) like elliptic.Curve.
+//
+// A backend has to compute exactly what the default one does, so proofs made
+// with one verify with the other and have the same bytes.
+type CurveOps interface {
+	// ScalarBaseMult returns k*B for the base point B of the curve and k
+	// in big-endian form
+	ScalarBaseMult(k []byte) (x, y *big.Int)
+	// ScalarMult returns k*(x1, y1) for k in big-endian form
+	ScalarMult(x1, y1 *big.Int, k []byte) (x, y *big.Int)
+	// Add returns the sum of (x1, y1) and (x2, y2)
+	Add(x1, y1, x2, y2 *big.Int) (x, y *big.Int)
+	// IsOnCurve returns true if (x, y) is on the curve, x and y are in [0, P)
+	IsOnCurve(x, y *big.Int) bool
+	// Compress returns the compressed SEC1 encoding of (x, y)
+	Compress(x, y *big.Int) []byte
+	// Decompress returns the point of compressed SEC1 encoding b, whose
+	// length and X are already checked, and false if X is not the x
+	// coordinate of a point on the curve
+	Decompress(b []byte) (x, y *big.Int, ok bool)
+}
+
+// arithmetic is the part of CurveOps an elliptic.Curve has too
+type arithmetic interface {
+	ScalarBaseMult(k []byte) (x, y *big.Int)
+	ScalarMult(x1, y1 *big.Int, k []byte) (x, y *big.Int)
+	Add(x1, y1, x2, y2 *big.Int) (x, y *big.Int)
+	IsOnCurve(x, y *big.Int) bool
+}
+
+// ops returns the arithmetic of zkpcp, which is its curve without a backend
+func (zkpcp ZKPCurveParams) ops() arithmetic {
+	if zkpcp.backend != nil {
+		return zkpcp.backend
+	}
+	return zkpcp.C
+}
+
+// compress is compressPoint with the encoding of the backend of zkpcp
+func (zkpcp ZKPCurveParams) compress(p ECPoint) []byte {
+	if zkpcp.backend == nil || p.X == nil || p.Y == nil || p.Equal(Zero) {
+		return compressPoint(zkpcp.C, p)
+	}
+	return zkpcp.backend.Compress(p.X, p.Y)
+}
+
+// decompress is decompressPoint with the decoding of the backend of zkpcp
+func (zkpcp ZKPCurveParams) decompress(b []byte) (ECPoint, error) {
+	if zkpcp.backend == nil {
+		return decompressPoint(zkpcp.C, b)
+	}
+	return decodePoint(zkpcp.C, zkpcp.backend, b)
+}
+
+// ellipticOps is the default backend, the arithmetic of curve and SEC1 point
+// compression
+type ellipticOps struct {
+	elliptic.Curve
+}
+
+// Compress implements CurveOps
+func (o ellipticOps) Compress(x, y *big.Int) []byte {
+	return compressPoint(o.Curve, ECPoint{x, y})
+}
+
+// Decompress implements CurveOps, on secp256k1 with the square root of btcec
+func (o ellipticOps) Decompress(b []byte) (*big.Int, *big.Int, bool) {
+	if kc, ok := o.Curve.(*btcec.KoblitzCurve); ok {
+		pk, err := btcec.ParsePubKey(b, kc)
+		if err != nil {
+			return nil, nil, false
+		}
+		return pk.X, pk.Y, true
+	}
+	X, Y := elliptic.UnmarshalCompressed(o.Curve, b)
+	return X, Y, X != nil
+}
+
+// Backend returns the CurveOps of a backend for curve, or nil if the backend
+// does not support curve
+type Backend func(curve elliptic.Curve) CurveOps
+
+// DefaultBackend is the name of the backend ZKPCurveParams use unless
+// WithBackend picks another one
+const DefaultBackend = "default"
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]Backend{
+		DefaultBackend: func(curve elliptic.Curve) CurveOps { return ellipticOps{curve} },
+	}
+)
+
+// RegisterBackend makes backend available to WithBackend under name. It is
+// meant to be called from the init function of the package implementing the
+// backend, and panics if name is already taken or backend is nil.
+func RegisterBackend(name string, backend Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if backend == nil {
+		panic("zksigma: RegisterBackend of a nil backend")
+	}
+	if _, ok := backends[name]; ok {
+		panic(fmt.Sprintf("zksigma: RegisterBackend called twice for %q", name))
+	}
+	backends[name] = backend
+}
+
+// Backends returns the sorted names of the registered backends, DefaultBackend
+// included
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithBackend returns a copy of zkpcp that does its point arithmetic with the
+// backend registered as name. zkpcp itself is not changed. It fails if there
+// is no such backend, if it does not support the curve of zkpcp or if G or H
+// are not on the curve for it.
+func (zkpcp ZKPCurveParams) WithBackend(name string) (ZKPCurveParams, error) {
+	backendsMu.RLock()
+	backend, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return ZKPCurveParams{}, inputError("WithBackend", fmt.Sprintf("no backend %q", name))
+	}
+
+	if name == DefaultBackend {
+		if zkpcp.backend != nil {
+			zkpcp.backend = nil
+			zkpcp.tables = newBaseTables(zkpcp.C)
+		}
+		return zkpcp, nil
+	}
+	ops := backend(zkpcp.C)
+	if ops == nil {
+		return ZKPCurveParams{}, inputError("WithBackend", fmt.Sprintf("backend %q does not support %s", name, zkpcp.C.Params().Name))
+	}
+	if !ops.IsOnCurve(zkpcp.G.X, zkpcp.G.Y) || !ops.IsOnCurve(zkpcp.H.X, zkpcp.H.Y) {
+		return ZKPCurveParams{}, inputError("WithBackend", fmt.Sprintf("generators are not on the curve of backend %q", name))
+	}
+	// the fixed base tables are a part of the default backend
+	zkpcp.backend, zkpcp.tables = ops, nil
+	return zkpcp, nil
+}
@@ -0,0 +1,283 @@
+package zksigma
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/mit-dci/zksigma/btcec"
+)
+
+// affineOps is a slow but simple backend for secp256k1 in affine coordinates,
+// written from the curve equation y^2 = x^3 + B, to check the default one
+// against
+type affineOps struct {
+	P, B, Gx, Gy *big.Int
+}
+
+func newAffineOps(curve elliptic.Curve) CurveOps {
+	kc, ok := curve.(*btcec.KoblitzCurve)
+	if !ok {
+		return nil
+	}
+	return affineOps{kc.P, kc.B, kc.Gx, kc.Gy}
+}
+
+func init() {
+	RegisterBackend("affine", newAffineOps)
+}
+
+func (o affineOps) mod(x *big.Int) *big.Int { return x.Mod(x, o.P) }
+
+func (o affineOps) IsOnCurve(x, y *big.Int) bool {
+	y2 := o.mod(new(big.Int).Mul(y, y))
+	x3 := new(big.Int).Mul(x, x)
+	x3.Mul(x3, x).Add(x3, o.B)
+	return y2.Cmp(o.mod(x3)) == 0
+}
+
+func (o affineOps) Add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	if x1.Sign() == 0 && y1.Sign() == 0 {
+		return new(big.Int).Set(x2), new(big.Int).Set(y2)
+	}
+	if x2.Sign() == 0 && y2.Sign() == 0 {
+		return new(big.Int).Set(x1), new(big.Int).Set(y1)
+	}
+	var l *big.Int
+	if x1.Cmp(x2) == 0 {
+		if y1.Cmp(y2) != 0 || y1.Sign() == 0 {
+			return new(big.Int), new(big.Int)
+		}
+		// l = 3x^2 / 2y
+		l = new(big.Int).Mul(x1, x1)
+		l.Mul(l, big.NewInt(3))
+		l.Mul(l, new(big.Int).ModInverse(new(big.Int).Lsh(y1, 1), o.P))
+	} else {
+		// l = (y2 - y1) / (x2 - x1)
+		l = new(big.Int).Sub(y2, y1)
+		l.Mul(l, new(big.Int).ModInverse(o.mod(new(big.Int).Sub(x2, x1)), o.P))
+	}
+	o.mod(l)
+	x3 := new(big.Int).Mul(l, l)
+	x3.Sub(x3, x1).Sub(x3, x2)
+	o.mod(x3)
+	y3 := new(big.Int).Sub(x1, x3)
+	y3.Mul(y3, l).Sub(y3, y1)
+	return x3, o.mod(y3)
+}
+
+func (o affineOps) ScalarMult(x1, y1 *big.Int, k []byte) (*big.Int, *big.Int) {
+	x, y := new(big.Int), new(big.Int)
+	for _, b := range k {
+		for bit := 7; bit >= 0; bit-- {
+			x, y = o.Add(x, y, x, y)
+			if b>>uint(bit)&1 == 1 {
+				x, y = o.Add(x, y, x1, y1)
+			}
+		}
+	}
+	return x, y
+}
+
+func (o affineOps) ScalarBaseMult(k []byte) (*big.Int, *big.Int) {
+	return o.ScalarMult(o.Gx, o.Gy, k)
+}
+
+func (o affineOps) Compress(x, y *big.Int) []byte {
+	b := make([]byte, 33)
+	b[0] = 0x02 | byte(y.Bit(0))
+	x.FillBytes(b[1:])
+	return b
+}
+
+func (o affineOps) Decompress(b []byte) (*big.Int, *big.Int, bool) {
+	x := new(big.Int).SetBytes(b[1:])
+	y2 := new(big.Int).Mul(x, x)
+	y2.Mul(y2, x).Add(y2, o.B)
+	y := new(big.Int).ModSqrt(o.mod(y2), o.P)
+	if y == nil {
+		return nil, nil, false
+	}
+	if y.Bit(0) != uint(b[0]&1) {
+		y.Sub(o.P, y)
+	}
+	return x, y, true
+}
+
+// fixedProofs generates proofs of a few types with the random values of
+// seeded readers, so the same zkpcp arithmetic gives the same bytes
+func fixedProofs(t *testing.T, zkpcp ZKPCurveParams) map[string][]byte {
+	value := big.NewInt(5)
+	r, r2 := scalarFromSeed("backend r"), scalarFromSeed("backend r2")
+	sk := scalarFromSeed("backend sk")
+	PK := zkpcp.Mult(zkpcp.H, sk)
+	CM, sameCM := PedCommitR(zkpcp, value, r), PedCommitR(zkpcp, value, r2)
+	CMTok := zkpcp.Mult(PK, r)
+	set := []*big.Int{big.NewInt(1), value, big.NewInt(10)}
+
+	type proof interface {
+		Bytes() []byte
+	}
+	gens := map[string]func() (proof, error){
+		"ABCProof": func() (proof, error) {
+			return NewABCProofWithRand(zkpcp, newSeededReader("abc"), CM, CMTok, value, sk, Right)
+		},
+		"BitRangeProof": func() (proof, error) {
+			return NewBitRangeProofWithRand(zkpcp, newSeededReader("bitrange"), CM, value, r, 4)
+		},
+		"OpenProof": func() (proof, error) {
+			return NewOpenProofWithRand(zkpcp, newSeededReader("open"), CM, value, r)
+		},
+		"SameValueProof": func() (proof, error) {
+			return NewSameValueProofWithRand(zkpcp, newSeededReader("samevalue"), CM, sameCM, value, r, r2)
+		},
+		"SetMembershipProof": func() (proof, error) {
+			return NewSetMembershipProofWithRand(zkpcp, newSeededReader("setmembership"), CM, value, r, set)
+		},
+	}
+	proofs := make(map[string][]byte)
+	for name, gen := range gens {
+		p, err := gen()
+		if err != nil {
+			t.Fatalf("%s fails to generate: %v\n", name, err)
+		}
+		proofs[name] = p.Bytes()
+	}
+	return proofs
+}
+
+// scalarFromSeed returns a fixed scalar read from a seeded reader
+func scalarFromSeed(seed string) *big.Int {
+	b := make([]byte, 32)
+	newSeededReader(seed).Read(b)
+	return new(big.Int).Mod(new(big.Int).SetBytes(b), TestCurve.C.Params().N)
+}
+
+func TestBackends(t *testing.T) {
+	affine, err := TestCurve.WithBackend("affine")
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	// the arithmetic agrees, on G and H and on any other point
+	k := scalarFromSeed("backend k")
+	A := TestCurve.Mult(TestCurve.G, scalarFromSeed("backend A"))
+	for _, p := range []ECPoint{TestCurve.G, TestCurve.H, A} {
+		if !affine.Mult(p, k).Equal(TestCurve.Mult(p, k)) {
+			t.Fatalf("backends multiply %v differently\n", p)
+		}
+		if !affine.Add(p, A).Equal(TestCurve.Add(p, A)) || !affine.Sub(p, A).Equal(TestCurve.Sub(p, A)) {
+			t.Fatalf("backends add %v differently\n", p)
+		}
+		mm := []*big.Int{k, big.NewInt(-3)}
+		if !affine.MultiMult([]ECPoint{p, A}, mm).Equal(TestCurve.MultiMult([]ECPoint{p, A}, mm)) {
+			t.Fatalf("backends MultiMult %v differently\n", p)
+		}
+		q, err := ECPointFromHex(affine, p.ToHex())
+		if err != nil || !q.Equal(p) {
+			t.Fatalf("affine backend decodes %v as %v, %v\n", p, q, err)
+		}
+	}
+	if (ECPoint{A.X, new(big.Int).Add(A.Y, big.NewInt(1))}).IsOnCurve(affine) {
+		t.Fatalf("affine backend accepts a point off the curve\n")
+	}
+
+	// proofs with the same random values are the same, and each verifies
+	// with the other backend
+	want := fixedProofs(t, TestCurve)
+	for name, b := range fixedProofs(t, affine) {
+		if !bytes.Equal(b, want[name]) {
+			t.Fatalf("%s differs between the backends\n", name)
+		}
+	}
+	for name, c := range verifiableProofs(t) {
+		for backend, zkpcp := range map[string]ZKPCurveParams{DefaultBackend: TestCurve, "affine": affine} {
+			if ok, err := c.proof.VerifyArgs(zkpcp, c.args...); !ok || err != nil {
+				t.Fatalf("%s does not verify with backend %s: %v\n", name, backend, err)
+			}
+		}
+		if len(c.args) == 0 {
+			continue
+		}
+		args := append([]interface{}{otherArg(c.args[0])}, c.args[1:]...)
+		ok, err := c.proof.VerifyArgs(TestCurve, args...)
+		okAffine, errAffine := c.proof.VerifyArgs(affine, args...)
+		if ok || okAffine || !errors.Is(errAffine, errorCode(err)) {
+			t.Fatalf("%s for another statement gives %v and %v with the affine backend\n", name, err, errAffine)
+		}
+	}
+
+	// back to the default backend, with its tables
+	plain, err := affine.WithBackend(DefaultBackend)
+	if err != nil || plain.backend != nil || plain.tables == nil {
+		t.Fatalf("WithBackend(DefaultBackend) gives %v\n", err)
+	}
+	if !plain.Mult(plain.H, k).Equal(TestCurve.Mult(TestCurve.H, k)) {
+		t.Fatalf("default backend multiplies H differently after WithBackend\n")
+	}
+}
+
+// errorCode returns the sentinel error matching the code of err, or err if it
+// has none
+func errorCode(err error) error {
+	var pe *ProofError
+	if !errors.As(err, &pe) {
+		return err
+	}
+	return &ProofError{Code: pe.Code}
+}
+
+func TestRegisterBackend(t *testing.T) {
+	names := Backends()
+	if len(names) != 2 || names[0] != "affine" || names[1] != DefaultBackend {
+		t.Fatalf("Backends is %v\n", names)
+	}
+	if _, err := TestCurve.WithBackend("nope"); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("WithBackend of an unknown backend gives %v\n", err)
+	}
+	if _, err := P256Curve.WithBackend("affine"); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("WithBackend of a backend without P-256 gives %v\n", err)
+	}
+
+	for name, register := range map[string]func(){
+		"twice": func() { RegisterBackend("affine", newAffineOps) },
+		"nil":   func() { RegisterBackend("nil", nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("RegisterBackend %s does not panic\n", name)
+				}
+			}()
+			register()
+		}()
+	}
+}
+
+func BenchmarkBackends(b *testing.B) {
+	k := scalarFromSeed("backend k")
+	CM, r := PedCommitR(TestCurve, big.NewInt(5), k), k
+	sameCM, sameR, _ := PedCommit(TestCurve, big.NewInt(5))
+	proof, err := NewSameValueProof(TestCurve, CM, sameCM, big.NewInt(5), r, sameR)
+	if err != nil {
+		b.Fatalf("%v\n", err)
+	}
+	for _, name := range Backends() {
+		zkpcp, err := TestCurve.WithBackend(name)
+		if err != nil {
+			b.Fatalf("%v\n", err)
+		}
+		b.Run(name+"/Mult", func(b *testing.B) {
+			for ii := 0; ii < b.N; ii++ {
+				zkpcp.Mult(CM, k)
+			}
+		})
+		b.Run(name+"/SameValueVerify", func(b *testing.B) {
+			for ii := 0; ii < b.N; ii++ {
+				proof.Verify(zkpcp, CM, sameCM)
+			}
+		})
+	}
+}
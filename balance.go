@@ -0,0 +1,345 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// A commitment equation is lhs = sign_1 * CM_1 + ... + sign_n * CM_n with
+// every sign +1 or -1, e.g. CM_out = CM_in1 + CM_in2 - CM_fee for a
+// transaction. It holds as a point equation only if both the values and the
+// randomness balance. If only the values do, lhs minus the terms is RH for the
+// residual randomness R, and a BalanceProof shows that.
+
+// commitmentSum returns sign_1 * CM_1 + ... + sign_n * CM_n for the terms and
+// signs of a commitment equation
+func commitmentSum(zkpcp ZKPCurveParams, t string, lhs ECPoint, terms []ECPoint, signs []int) (ECPoint, error) {
+	if len(terms) == 0 {
+		return Zero, inputError(t, "no terms")
+	}
+	if len(signs) != len(terms) {
+		return Zero, inputError(t, "terms and signs must have the same length")
+	}
+	if !zkpcp.validPoints(lhs) || !zkpcp.validPoints(terms...) {
+		return Zero, curveError(t)
+	}
+	sum := Zero
+	for i, CM := range terms {
+		switch signs[i] {
+		case 1:
+			sum = zkpcp.Add(sum, CM)
+		case -1:
+			sum = zkpcp.Sub(sum, CM)
+		default:
+			return Zero, inputError(t, fmt.Sprintf("sign %d is %d, not 1 or -1", i, signs[i]))
+		}
+	}
+	return sum, nil
+}
+
+// VerifyCommitmentEquation checks that lhs = sign_1 * terms_1 + ... +
+// sign_n * terms_n as points, with every sign 1 or -1. If the two sides
+// differ, the error matches ErrEquationFailed and shows both of them.
+func VerifyCommitmentEquation(zkpcp ZKPCurveParams, lhs ECPoint, terms []ECPoint, signs []int) (bool, error) {
+	rhs, err := commitmentSum(zkpcp, "VerifyCommitmentEquation", lhs, terms, signs)
+	if err != nil {
+		return false, err
+	}
+	if !lhs.Equal(rhs) {
+		return false, equationError("VerifyCommitmentEquation", 1, "lhs != sum of the terms", lhs, rhs)
+	}
+	return true, nil
+}
+
+// CommitmentEquation builds a commitment equation term by term, so the signs
+// are spelled out where the terms are given:
+//
+//	ok, err := Eq(zkpcp).Plus(CMin1).Plus(CMin2).Minus(CMfee).Equals(CMout)
+type CommitmentEquation struct {
+	zkpcp ZKPCurveParams
+	terms []ECPoint
+	signs []int
+}
+
+// Eq starts a CommitmentEquation without terms
+func Eq(zkpcp ZKPCurveParams) *CommitmentEquation {
+	return &CommitmentEquation{zkpcp: zkpcp}
+}
+
+// Plus adds CM to the terms of e and returns e
+func (e *CommitmentEquation) Plus(CM ECPoint) *CommitmentEquation {
+	e.terms = append(e.terms, CM)
+	e.signs = append(e.signs, 1)
+	return e
+}
+
+// Minus subtracts CM from the terms of e and returns e
+func (e *CommitmentEquation) Minus(CM ECPoint) *CommitmentEquation {
+	e.terms = append(e.terms, CM)
+	e.signs = append(e.signs, -1)
+	return e
+}
+
+// Terms returns the terms and signs of e for VerifyCommitmentEquation and
+// BalanceProof
+func (e *CommitmentEquation) Terms() ([]ECPoint, []int) {
+	return append([]ECPoint{}, e.terms...), append([]int{}, e.signs...)
+}
+
+// Equals is VerifyCommitmentEquation for lhs and the terms of e
+func (e *CommitmentEquation) Equals(lhs ECPoint) (bool, error) {
+	return VerifyCommitmentEquation(e.zkpcp, lhs, e.terms, e.signs)
+}
+
+// BalanceProof is a proof that the values of a commitment equation balance,
+// although its randomness does not cancel. With D = lhs - (sign_1 * CM_1 + ...
+// + sign_n * CM_n) and R the same combination of the randomness, D is RH
+// exactly if the values balance, and the prover shows that it knows the
+// discrete log of D with respect to H.
+//
+//  Public: generator points G and H, lhs, CM_1 ... CM_n, sign_1 ... sign_n
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows r_lhs, r_i with balanced values
+//  R = r_lhs - sum(sign_i * r_i)       D = lhs - sum(sign_i * CM_i)
+//  selects random u
+//  T = uH
+//  c = HASH(G, H, lhs, sign_1, CM_1, ..., sign_n, CM_n, T)
+//  s = u + c * R
+//
+//  T, c, s --------------------------->
+//                                      c ?= HASH(G, H, lhs, sign_1, CM_1, ..., sign_n, CM_n, T)
+//                                      sH ?= T + cD
+type BalanceProof struct {
+	T         ECPoint  // T = uH
+	Challenge *big.Int // c = HASH(G, H, lhs, sign_1, CM_1, ..., sign_n, CM_n, T)
+	S         *big.Int // s = u + c * R
+}
+
+// balanceChallenge computes c = HASH(G, H, lhs, sign_1, CM_1, ..., sign_n, CM_n, T)
+func balanceChallenge(zkpcp ZKPCurveParams, lhs ECPoint, terms []ECPoint, signs []int, T ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "BalanceProof")
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendPoint("lhs", lhs)
+	for i, CM := range terms {
+		sign := []byte{'+'}
+		if signs[i] < 0 {
+			sign = []byte{'-'}
+		}
+		t.AppendMessage("sign", sign)
+		t.AppendPoint("CM", CM)
+	}
+	t.AppendPoint("T", T)
+	return t.ChallengeScalar("c")
+}
+
+// NewBalanceProof generates a proof that the values of the commitment
+// equation lhs = sign_1 * terms_1 + ... + sign_n * terms_n balance, where lhsR
+// is the randomness of lhs and rs that of the terms
+func NewBalanceProof(zkpcp ZKPCurveParams, lhs ECPoint, terms []ECPoint, signs []int, lhsR *big.Int, rs []*big.Int) (*BalanceProof, error) {
+	return NewBalanceProofWithRand(zkpcp, entropy(), lhs, terms, signs, lhsR, rs)
+}
+
+// NewBalanceProofWithRand is the same as NewBalanceProof, but reads its
+// random value from rnd instead of the EntropySource
+func NewBalanceProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	lhs ECPoint, terms []ECPoint, signs []int, lhsR *big.Int, rs []*big.Int) (*BalanceProof, error) {
+
+	N := zkpcp.C.Params().N
+
+	rhs, err := commitmentSum(zkpcp, "BalanceProve", lhs, terms, signs)
+	if err != nil {
+		return nil, err
+	}
+	if len(rs) != len(terms) {
+		return nil, inputError("BalanceProve", "terms and rs must have the same length")
+	}
+	if err := zkpcp.checkScalars("BalanceProve", "lhsR", lhsR); err != nil {
+		return nil, err
+	}
+	R := new(big.Int).Set(lhsR)
+	for i, r := range rs {
+		if err := zkpcp.checkScalars("BalanceProve", fmt.Sprintf("r %d", i), r); err != nil {
+			return nil, err
+		}
+		if signs[i] > 0 {
+			R.Sub(R, r)
+		} else {
+			R.Add(R, r)
+		}
+	}
+	R.Mod(R, N)
+	if !zkpcp.Sub(lhs, rhs).Equal(zkpcp.Mult(zkpcp.H, R)) {
+		return nil, inputError("BalanceProve", "the values do not balance or the randomness does not open the commitments")
+	}
+
+	u, err := zkpcp.randScalar(rnd)
+	if err != nil {
+		return nil, err
+	}
+
+	T := zkpcp.Mult(zkpcp.H, u)
+	c := balanceChallenge(zkpcp, lhs, terms, signs, T)
+
+	s := new(big.Int).Add(u, new(big.Int).Mul(c, R))
+	s.Mod(s, N)
+
+	return &BalanceProof{T, c, s}, nil
+}
+
+// Verify checks if BalanceProof proof shows that the values of the commitment
+// equation lhs = sign_1 * terms_1 + ... + sign_n * terms_n balance
+func (proof *BalanceProof) Verify(zkpcp ZKPCurveParams, lhs ECPoint, terms []ECPoint, signs []int) (bool, error) {
+	if proof == nil {
+		return false, malformedError("BalanceProof.Verify", "passed proof is nil")
+	}
+
+	rhs, err := commitmentSum(zkpcp, "BalanceProof.Verify", lhs, terms, signs)
+	if err != nil {
+		return false, err
+	}
+	if !zkpcp.onCurve(proof.T) {
+		return false, curveError("BalanceProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("BalanceProof.Verify", "Challenge, S", proof.Challenge, proof.S); err != nil {
+		return false, err
+	}
+
+	c := balanceChallenge(zkpcp, lhs, terms, signs, proof.T)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("BalanceProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	// sH ?= T + cD, checked as sH - cD ?= T
+	D := zkpcp.Sub(lhs, rhs)
+	check := zkpcp.MultiMult([]ECPoint{zkpcp.H, D}, []*big.Int{proof.S, new(big.Int).Neg(c)})
+	if !pointsEqual(check, proof.T) {
+		return false, equationError("BalanceProof.Verify", 1, "sH - cD != T", check, proof.T)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are lhs, terms and signs like for
+// Verify
+func (proof *BalanceProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("BalanceProof.VerifyArgs", args)
+	lhs, terms, signs := a.point(), a.points(), a.ints()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, lhs, terms, signs)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *BalanceProof) String() string {
+	if proof == nil {
+		return "BalanceProof(nil)"
+	}
+	return proofString("BalanceProof", "T", proof.T, "Challenge", proof.Challenge, "S", proof.S)
+}
+
+// balanceProofLen is the length of a serialized BalanceProof
+var balanceProofLen = 1 + fixedPointLen + 2*fixedScalarLen
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// BalanceProof proof
+func (proof *BalanceProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.T)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.S)
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of BalanceProof proof, which has a fixed size
+func (proof *BalanceProof) Size() int {
+	return balanceProofLen
+}
+
+// Clone returns a deep copy of BalanceProof proof that shares no big.Int with it
+func (proof *BalanceProof) Clone() *BalanceProof {
+	if proof == nil {
+		return nil
+	}
+	return &BalanceProof{
+		T:         clonePoint(proof.T),
+		Challenge: cloneScalar(proof.Challenge),
+		S:         cloneScalar(proof.S),
+	}
+}
+
+// Equal returns true if BalanceProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *BalanceProof) Equal(other *BalanceProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.T, other.T) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.S, other.S)
+}
+
+// NewBalanceProofFromBytes returns a BalanceProof generated from the
+// deserialization of byte slice b
+func NewBalanceProofFromBytes(b []byte) (*BalanceProof, error) {
+	if err := checkFixedHeader(b, balanceProofLen, "BalanceProofFromBytes"); err != nil {
+		return nil, err
+	}
+	proof := new(BalanceProof)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.T, err = readFixedPoint(buf, "BalanceProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "BalanceProofFromBytes", &proof.Challenge, &proof.S); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+type balanceProofJSON struct {
+	T         string `json:"t"`
+	Challenge string `json:"challenge"`
+	S         string `json:"s"`
+}
+
+// MarshalJSON encodes BalanceProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *BalanceProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(balanceProofJSON{
+		T:         pointToHex(proof.T),
+		Challenge: scalarToHex(proof.Challenge),
+		S:         scalarToHex(proof.S),
+	})
+}
+
+// UnmarshalJSON decodes a BalanceProof encoded with MarshalJSON. It fails if a
+// point is not on the curve or a scalar is not in [0, N)
+func (proof *BalanceProof) UnmarshalJSON(b []byte) error {
+	var aux balanceProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := BalanceProof{}
+	if p.T, err = pointFromHex(aux.T, "t"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.S, err = scalarFromHex(aux.S, "s"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
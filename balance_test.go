@@ -0,0 +1,163 @@
+package zksigma
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// transaction returns the commitments and randomness of two inputs, a fee and
+// an output with in1 + in2 - fee = out. The output has its own randomness,
+// unless same is set, in which case it is r1 + r2 - rFee.
+func transaction(t testing.TB, out int64, same bool) ([]ECPoint, []*big.Int, ECPoint, *big.Int) {
+	N := TestCurve.C.Params().N
+	var CMs []ECPoint
+	var rs []*big.Int
+	for _, v := range []int64{700, 400, 100} {
+		CM, r, err := PedCommit(TestCurve, big.NewInt(v))
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		CMs, rs = append(CMs, CM), append(rs, r)
+	}
+	CMOut, rOut, err := PedCommit(TestCurve, big.NewInt(out))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if same {
+		rOut = new(big.Int).Add(rs[0], rs[1])
+		rOut.Sub(rOut, rs[2]).Mod(rOut, N)
+		CMOut = PedCommitR(TestCurve, big.NewInt(out), rOut)
+	}
+	return CMs, rs, CMOut, rOut
+}
+
+func TestVerifyCommitmentEquation(t *testing.T) {
+	CMs, _, CMOut, _ := transaction(t, 1000, true)
+	signs := []int{1, 1, -1}
+
+	if ok, err := VerifyCommitmentEquation(TestCurve, CMOut, CMs, signs); !ok || err != nil {
+		t.Fatalf("balanced commitment equation failed: %v\n", err)
+	}
+	if ok, err := Eq(TestCurve).Plus(CMs[0]).Plus(CMs[1]).Minus(CMs[2]).Equals(CMOut); !ok || err != nil {
+		t.Fatalf("balanced commitment equation failed with Eq: %v\n", err)
+	}
+	terms, termSigns := Eq(TestCurve).Plus(CMs[0]).Minus(CMs[1]).Terms()
+	if len(terms) != 2 || !terms[1].Equal(CMs[1]) || termSigns[0] != 1 || termSigns[1] != -1 {
+		t.Fatalf("Terms returns %v and %v\n", terms, termSigns)
+	}
+
+	// a flipped sign, or randomness that does not cancel, makes the sides differ
+	ok, err := Eq(TestCurve).Plus(CMs[0]).Plus(CMs[1]).Plus(CMs[2]).Equals(CMOut)
+	var pe *ProofError
+	if ok || !errors.As(err, &pe) || pe.Code != ErrCodeEquationFailed || pe.Equation != 1 {
+		t.Fatalf("commitment equation with a flipped sign gives %v\n", err)
+	}
+	CMs, _, CMOut, _ = transaction(t, 1000, false)
+	if ok, err := VerifyCommitmentEquation(TestCurve, CMOut, CMs, signs); ok || !errors.Is(err, ErrEquationFailed) {
+		t.Fatalf("commitment equation with randomness that does not cancel gives %v\n", err)
+	}
+
+	bad := map[string]struct {
+		terms []ECPoint
+		signs []int
+		err   error
+	}{
+		"no terms":    {nil, nil, ErrInvalidInput},
+		"short signs": {CMs, signs[:2], ErrInvalidInput},
+		"sign 2":      {CMs, []int{1, 2, -1}, ErrInvalidInput},
+		"sign 0":      {CMs, []int{1, 0, -1}, ErrInvalidInput},
+		"nil point":   {[]ECPoint{CMs[0], {}}, []int{1, 1}, ErrNotOnCurve},
+	}
+	for name, c := range bad {
+		if ok, err := VerifyCommitmentEquation(TestCurve, CMOut, c.terms, c.signs); ok || !errors.Is(err, c.err) {
+			t.Fatalf("commitment equation with %s gives %v\n", name, err)
+		}
+	}
+	if ok, err := Eq(TestCurve).Equals(CMOut); ok || !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Eq without terms gives %v\n", err)
+	}
+}
+
+func TestBalanceProof(t *testing.T) {
+	CMs, rs, CMOut, rOut := transaction(t, 1000, false)
+	signs := []int{1, 1, -1}
+
+	// the values balance, the randomness does not
+	if ok, _ := VerifyCommitmentEquation(TestCurve, CMOut, CMs, signs); ok {
+		t.Fatalf("randomness of the transaction cancels\n")
+	}
+	proof, err := NewBalanceProof(TestCurve, CMOut, CMs, signs, rOut, rs)
+	if err != nil {
+		t.Fatalf("BalanceProof failed to generate: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CMOut, CMs, signs); !ok || err != nil {
+		t.Fatalf("BalanceProof failed to verify: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CMOut, CMs, []int{1, -1, 1}); ok || err == nil {
+		t.Fatalf("BalanceProof verified with other signs\n")
+	}
+
+	// randomness that cancels is R = 0
+	CMs, rs, CMOut, rOut = transaction(t, 1000, true)
+	proof, err = NewBalanceProof(TestCurve, CMOut, CMs, signs, rOut, rs)
+	if err != nil {
+		t.Fatalf("BalanceProof failed to generate for randomness that cancels: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CMOut, CMs, signs); !ok || err != nil {
+		t.Fatalf("BalanceProof failed to verify for randomness that cancels: %v\n", err)
+	}
+}
+
+func TestBalanceProofUnbalanced(t *testing.T) {
+	CMs, rs, CMOut, rOut := transaction(t, 1001, false)
+	signs := []int{1, 1, -1}
+
+	if _, err := NewBalanceProof(TestCurve, CMOut, CMs, signs, rOut, rs); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("BalanceProof for an unbalanced amount gives %v\n", err)
+	}
+
+	// nor does a proof of a balanced transaction carry over to one paying out 1 more
+	balanced, rs, CMBalanced, rBalanced := transaction(t, 1000, false)
+	proof, err := NewBalanceProof(TestCurve, CMBalanced, balanced, signs, rBalanced, rs)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	more := TestCurve.Add(CMBalanced, TestCurve.G)
+	if ok, err := proof.Verify(TestCurve, more, balanced, signs); ok || err == nil {
+		t.Fatalf("BalanceProof verified for an unbalanced amount\n")
+	}
+
+	// an empty term list is an error, not a proof about Zero
+	if _, err := NewBalanceProof(TestCurve, CMOut, nil, nil, rOut, nil); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("BalanceProof without terms gives %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CMBalanced, nil, nil); ok || !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("BalanceProof verified without terms gives %v\n", err)
+	}
+	if _, err := NewBalanceProof(TestCurve, CMBalanced, balanced, signs, rBalanced, rs[:2]); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("BalanceProof with too few rs gives %v\n", err)
+	}
+}
+
+func BenchmarkBalanceProve(b *testing.B) {
+	CMs, rs, CMOut, rOut := transaction(b, 1000, false)
+	signs := []int{1, 1, -1}
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewBalanceProof(TestCurve, CMOut, CMs, signs, rOut, rs)
+	}
+}
+
+func BenchmarkBalanceVerify(b *testing.B) {
+	CMs, rs, CMOut, rOut := transaction(b, 1000, false)
+	signs := []int{1, 1, -1}
+	proof, err := NewBalanceProof(TestCurve, CMOut, CMs, signs, rOut, rs)
+	if err != nil {
+		b.Fatalf("%v\n", err)
+	}
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CMOut, CMs, signs)
+	}
+}
@@ -0,0 +1,202 @@
+package zksigma
+
+import (
+	"crypto/elliptic"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/mit-dci/zksigma/btcec"
+)
+
+// baseTables holds the fixed base tables of the generators G and H of a
+// ZKPCurveParams on secp256k1. ZKPCurveParams is passed around by value, so
+// it only keeps a pointer to them. They are built the first time they are
+// needed, for the default generators btcec already has them.
+type baseTables struct {
+	once   sync.Once
+	g, h   *btcec.FixedBaseTable
+	window uint // bits of the windows of the tables built, 0 for 8
+
+	// extra are tables of other points, see withTables
+	extra []pointTable
+}
+
+// pointTable is the fixed base table t of point p
+type pointTable struct {
+	p ECPoint
+	t *btcec.FixedBaseTable
+}
+
+// newBaseTables returns the (not yet built) baseTables for curve, or nil if
+// the curve can not use them
+func newBaseTables(curve elliptic.Curve) *baseTables {
+	if _, ok := curve.(*btcec.KoblitzCurve); !ok {
+		return nil
+	}
+	return new(baseTables)
+}
+
+// generatorTables returns the fixed base tables of G and H, building them if
+// this is the first call. It is safe for concurrent use. ok is false if zkpcp
+// has no tables, then Mult falls back to ScalarMult.
+func (zkpcp ZKPCurveParams) generatorTables() (kc *btcec.KoblitzCurve, g, h *btcec.FixedBaseTable, ok bool) {
+	kc, isKoblitz := zkpcp.C.(*btcec.KoblitzCurve)
+	if zkpcp.tables == nil || !isKoblitz {
+		return nil, nil, nil, false
+	}
+	zkpcp.tables.once.Do(func() {
+		zkpcp.tables.g = fixedBaseTable(kc, zkpcp.G, zkpcp.tables.window)
+		zkpcp.tables.h = fixedBaseTable(kc, zkpcp.H, zkpcp.tables.window)
+	})
+	return kc, zkpcp.tables.g, zkpcp.tables.h, true
+}
+
+// fixedBaseTable returns the table btcec keeps for p if it has one and
+// computes a new one with windows of window bits (8 if it is 0) otherwise
+func fixedBaseTable(kc *btcec.KoblitzCurve, p ECPoint, window uint) *btcec.FixedBaseTable {
+	if p.X.Cmp(kc.Gx) == 0 && p.Y.Cmp(kc.Gy) == 0 {
+		return kc.BaseTable()
+	}
+	if p.Equal(btcecH) {
+		return kc.BaseTableH()
+	}
+	if window == 0 {
+		window = btcec.MaxTableWindow
+	}
+	return kc.NewFixedBaseTableWindow(p.X, p.Y, window)
+}
+
+// WithTableWindow returns a copy of zkpcp whose fixed base tables for G and H
+// have windows of window bits, in [1, 8]. A table takes ceil(256 / window) *
+// 2^window points of 120 bytes, about 1 MB for the default of 8 bits and
+// 120 kB for 4, and a multiplication with it ceil(256 / window) additions.
+// The tables are built the first time they are needed. The base point of
+// secp256k1 and the H of btcec always use the 8-bit tables btcec has anyway,
+// so this only changes the tables of other generators. On curves without
+// tables zkpcp is returned as it is.
+func (zkpcp ZKPCurveParams) WithTableWindow(window int) (ZKPCurveParams, error) {
+	if window < 1 || window > btcec.MaxTableWindow {
+		return ZKPCurveParams{}, inputError("WithTableWindow", fmt.Sprintf("window of %d bits is not in [1, %d]", window, btcec.MaxTableWindow))
+	}
+	if zkpcp.tables == nil {
+		return zkpcp, nil
+	}
+	zkpcp.tables = &baseTables{window: uint(window), extra: zkpcp.tables.extra}
+	return zkpcp, nil
+}
+
+// TableWindow returns the number of bits of the windows of the fixed base
+// tables of zkpcp, see WithTableWindow, or 0 if it has none
+func (zkpcp ZKPCurveParams) TableWindow() int {
+	if zkpcp.tables == nil {
+		return 0
+	}
+	if zkpcp.tables.window == 0 {
+		return btcec.MaxTableWindow
+	}
+	return int(zkpcp.tables.window)
+}
+
+// FixedBase is a point with a precomputed table of its multiples, for a point
+// that is multiplied by many scalars, like the public key of an auditor many
+// tokens are made for. Its Mult gives the same points as Mult of zkpcp. It is
+// safe for concurrent use.
+type FixedBase struct {
+	zkpcp ZKPCurveParams
+	p     ECPoint
+	t     *btcec.FixedBaseTable // nil on curves without tables
+}
+
+// NewFixedBase builds the FixedBase of p with windows of window bits, in
+// [1, 8], see WithTableWindow for the memory they take. On curves without
+// fixed base tables Mult of the FixedBase is the same as Mult of zkpcp.
+func NewFixedBase(zkpcp ZKPCurveParams, p ECPoint, window int) (*FixedBase, error) {
+	if window < 1 || window > btcec.MaxTableWindow {
+		return nil, inputError("NewFixedBase", fmt.Sprintf("window of %d bits is not in [1, %d]", window, btcec.MaxTableWindow))
+	}
+	if !zkpcp.validPoints(p) {
+		return nil, curveError("NewFixedBase")
+	}
+	fb := &FixedBase{zkpcp: zkpcp, p: clonePoint(p)}
+	if kc, ok := zkpcp.C.(*btcec.KoblitzCurve); ok && zkpcp.tables != nil && !p.IsZero() {
+		fb.t = kc.NewFixedBaseTableWindow(p.X, p.Y, uint(window))
+	}
+	return fb, nil
+}
+
+// Point returns a copy of the point of fb
+func (fb *FixedBase) Point() ECPoint {
+	return clonePoint(fb.p)
+}
+
+// Mult returns s times the point of fb, like Mult(fb.Point(), s) of the
+// ZKPCurveParams fb was built for
+func (fb *FixedBase) Mult(s *big.Int) ECPoint {
+	if fb.t == nil {
+		return fb.zkpcp.Mult(fb.p, s)
+	}
+	if s == nil {
+		return newZero()
+	}
+	modS := new(big.Int).Mod(s, fb.zkpcp.C.Params().N)
+	if modS.Sign() == 0 {
+		return newZero()
+	}
+	X, Y := fb.zkpcp.C.(*btcec.KoblitzCurve).ScalarMultTables([]*btcec.FixedBaseTable{fb.t}, [][]byte{modS.Bytes()})
+	return ECPoint{X, Y}
+}
+
+// Size returns the number of bytes the table of fb takes, 0 if it has none
+func (fb *FixedBase) Size() int {
+	if fb.t == nil {
+		return 0
+	}
+	return fb.t.Size()
+}
+
+// withTables returns a copy of zkpcp that also has fixed base tables for ps,
+// which are built right away. zkpcp itself is not changed, so the copy should
+// be kept around for as long as ps are multiplied often. Without tables for
+// G and H zkpcp is returned as it is.
+func (zkpcp ZKPCurveParams) withTables(ps ...ECPoint) ZKPCurveParams {
+	kc, g, h, ok := zkpcp.generatorTables()
+	if !ok {
+		return zkpcp
+	}
+	tables := &baseTables{g: g, h: h, window: zkpcp.tables.window}
+	tables.once.Do(func() {}) // g and h are already built
+	tables.extra = append(tables.extra, zkpcp.tables.extra...)
+	for _, p := range ps {
+		if p.Equal(Zero) || p.Equal(zkpcp.G) || p.Equal(zkpcp.H) {
+			continue
+		}
+		tables.extra = append(tables.extra, pointTable{p, fixedBaseTable(kc, p, zkpcp.tables.window)})
+	}
+	zkpcp.tables = tables
+	return zkpcp
+}
+
+// tableFor returns the fixed base table of p if zkpcp has one. The tables of
+// G and H are only built if p is one of them.
+func (zkpcp ZKPCurveParams) tableFor(p ECPoint) (*btcec.KoblitzCurve, *btcec.FixedBaseTable, bool) {
+	if zkpcp.tables == nil {
+		return nil, nil, false
+	}
+	if p.Equal(zkpcp.G) || p.Equal(zkpcp.H) {
+		kc, g, h, ok := zkpcp.generatorTables()
+		if !ok {
+			return nil, nil, false
+		}
+		if p.Equal(zkpcp.H) {
+			return kc, h, true
+		}
+		return kc, g, true
+	}
+	for _, e := range zkpcp.tables.extra {
+		if p.Equal(e.p) {
+			return zkpcp.C.(*btcec.KoblitzCurve), e.t, true
+		}
+	}
+	return nil, nil, false
+}
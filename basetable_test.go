@@ -0,0 +1,276 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/mit-dci/zksigma/btcec"
+)
+
+// secp256k1Curve returns ZKPCurveParams for secp256k1 with an H btcec has no
+// table for, found by hashing a seed until the result is the x coordinate of
+// a point
+func secp256k1Curve(tb testing.TB) ZKPCurveParams {
+	curve := btcec.S256()
+	var H ECPoint
+	for i := byte(0); ; i++ {
+		x := sha256.Sum256([]byte{'z', 'k', 's', 'i', 'g', 'm', 'a', 'H', i})
+		if p, err := decompressPoint(curve, append([]byte{0x02}, x[:]...)); err == nil {
+			H = p
+			break
+		}
+	}
+	zkpcp, err := NewZKPCurveParams(curve, ECPoint{curve.Gx, curve.Gy}, H)
+	if err != nil {
+		tb.Fatalf("%v\n", err)
+	}
+	return zkpcp
+}
+
+// withoutTables returns zkpcp without its fixed base tables, so everything
+// goes through ScalarMult
+func withoutTables(zkpcp ZKPCurveParams) ZKPCurveParams {
+	zkpcp.tables = nil
+	return zkpcp
+}
+
+func TestFixedBaseTables(t *testing.T) {
+	N := TestCurve.C.Params().N
+	for _, zkpcp := range []ZKPCurveParams{TestCurve, secp256k1Curve(t)} {
+		plain := withoutTables(zkpcp)
+		for _, x := range []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(255), big.NewInt(256),
+			new(big.Int).Sub(N, big.NewInt(1)), N} {
+			for _, p := range []ECPoint{zkpcp.G, zkpcp.H} {
+				X, Y := zkpcp.C.ScalarMult(p.X, p.Y, new(big.Int).Mod(x, N).Bytes())
+				want := ECPoint{X, Y}
+				if x.Sign() == 0 || x.Cmp(N) == 0 {
+					want = Zero
+				}
+				if got := zkpcp.Mult(p, x); !got.Equal(want) {
+					t.Fatalf("Mult with tables gives %v for %v * %v, expected %v\n", got, x, p, want)
+				}
+			}
+		}
+		for ii := 0; ii < 10; ii++ {
+			v, _ := rand.Int(rand.Reader, N)
+			r, _ := rand.Int(rand.Reader, N)
+			if got, want := PedCommitR(zkpcp, v, r), PedCommitR(plain, v, r); !got.Equal(want) {
+				t.Fatalf("PedCommitR with tables gives %v, expected %v\n", got, want)
+			}
+		}
+		if !PedCommitR(zkpcp, big.NewInt(0), big.NewInt(0)).Equal(Zero) {
+			t.Fatalf("PedCommitR(0, 0) should be Zero\n")
+		}
+	}
+}
+
+// tableScalars are the scalars the fixed base tests multiply by, which cross
+// the window boundaries of every window width
+func tableScalars() []*big.Int {
+	N := TestCurve.C.Params().N
+	x, _ := rand.Int(rand.Reader, N)
+	return []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2), big.NewInt(7), big.NewInt(8),
+		big.NewInt(255), big.NewInt(256), new(big.Int).Lsh(big.NewInt(1), 255),
+		new(big.Int).Sub(N, big.NewInt(1)), N, big.NewInt(-3), x}
+}
+
+func TestFixedBase(t *testing.T) {
+	N := TestCurve.C.Params().N
+	zkpcp := secp256k1Curve(t)
+	p := zkpcp.Mult(zkpcp.G, big.NewInt(123456789))
+	for window := 1; window <= btcec.MaxTableWindow; window++ {
+		for _, base := range []ECPoint{p, zkpcp.G, zkpcp.H} {
+			fb, err := NewFixedBase(zkpcp, base, window)
+			if err != nil {
+				t.Fatalf("%v\n", err)
+			}
+			for _, x := range tableScalars() {
+				X, Y := zkpcp.C.ScalarMult(base.X, base.Y, new(big.Int).Mod(x, N).Bytes())
+				want := ECPoint{X, Y}
+				if new(big.Int).Mod(x, N).Sign() == 0 {
+					want = Zero
+				}
+				if got := fb.Mult(x); !got.Equal(want) {
+					t.Fatalf("FixedBase with %d-bit windows gives %v for %v * %v, expected %v\n", window, got, x, base, want)
+				}
+			}
+		}
+	}
+
+	small, _ := NewFixedBase(zkpcp, p, 4)
+	large, _ := NewFixedBase(zkpcp, p, 8)
+	if small.Size() == 0 || small.Size() >= large.Size() || small.Size() > 128*1024 {
+		t.Fatalf("FixedBase with 4-bit windows takes %d bytes, with 8-bit ones %d\n", small.Size(), large.Size())
+	}
+	if !large.Point().Equal(p) || !large.Mult(nil).IsZero() {
+		t.Fatalf("FixedBase does not keep its point or multiply by nil\n")
+	}
+	zero, err := NewFixedBase(zkpcp, Zero, 8)
+	if err != nil || !zero.Mult(big.NewInt(5)).IsZero() || zero.Size() != 0 {
+		t.Fatalf("FixedBase of Zero gives %v, %v\n", zero, err)
+	}
+
+	// without tables it is Mult
+	p256 := P256Curve.Mult(P256Curve.G, big.NewInt(42))
+	fb, err := NewFixedBase(P256Curve, p256, 4)
+	if err != nil || fb.Size() != 0 || !fb.Mult(big.NewInt(5)).Equal(P256Curve.Mult(p256, big.NewInt(5))) {
+		t.Fatalf("FixedBase on P-256 gives %v, %v\n", fb, err)
+	}
+
+	for _, window := range []int{0, -1, 9} {
+		if _, err := NewFixedBase(zkpcp, p, window); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("NewFixedBase with a window of %d bits gives %v\n", window, err)
+		}
+	}
+	if _, err := NewFixedBase(zkpcp, ECPoint{big.NewInt(1), big.NewInt(1)}, 8); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("NewFixedBase of a point off the curve gives %v\n", err)
+	}
+}
+
+func TestWithTableWindow(t *testing.T) {
+	for _, zkpcp := range []ZKPCurveParams{TestCurve, secp256k1Curve(t)} {
+		plain := withoutTables(zkpcp)
+		for _, window := range []int{1, 3, 4, 8} {
+			windowed, err := zkpcp.WithTableWindow(window)
+			if err != nil {
+				t.Fatalf("%v\n", err)
+			}
+			if windowed.TableWindow() != window || zkpcp.TableWindow() != btcec.MaxTableWindow {
+				t.Fatalf("TableWindow is %d after WithTableWindow(%d), %d before\n", windowed.TableWindow(), window, zkpcp.TableWindow())
+			}
+			for _, x := range tableScalars() {
+				if got, want := windowed.Mult(windowed.H, x), plain.Mult(plain.H, x); !got.Equal(want) {
+					t.Fatalf("Mult of H with %d-bit tables gives %v for %v, expected %v\n", window, got, x, want)
+				}
+				if got, want := PedCommitR(windowed, x, x), PedCommitR(plain, x, x); !got.Equal(want) {
+					t.Fatalf("PedCommitR with %d-bit tables gives %v for %v, expected %v\n", window, got, x, want)
+				}
+			}
+
+			// proofs made with narrow tables verify with the default ones
+			value := big.NewInt(77)
+			CM, r, err := PedCommit(windowed, value)
+			if err != nil {
+				t.Fatalf("%v\n", err)
+			}
+			proof, err := NewOpenProof(windowed, CM, value, r)
+			if err != nil {
+				t.Fatalf("%v\n", err)
+			}
+			if ok, err := proof.Verify(zkpcp, CM); !ok || err != nil {
+				t.Fatalf("OpenProof with %d-bit tables does not verify with 8-bit ones: %v\n", window, err)
+			}
+		}
+	}
+
+	for _, window := range []int{0, 9} {
+		if _, err := TestCurve.WithTableWindow(window); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("WithTableWindow(%d) gives %v\n", window, err)
+		}
+	}
+	if p256, err := P256Curve.WithTableWindow(4); err != nil || p256.TableWindow() != 0 {
+		t.Fatalf("WithTableWindow on P-256 gives %v, window %d\n", err, p256.TableWindow())
+	}
+}
+
+// TestConcurrentProofs// TestConcurrentProofs generates and verifies proofs from many goroutines at
+// once on a fresh context, whose tables get built by whichever goroutine gets
+// there first. Run it with -race.
+func TestConcurrentProofs(t *testing.T) {
+	for _, zkpcp := range []ZKPCurveParams{TestCurve, secp256k1Curve(t)} {
+		var wg sync.WaitGroup
+		errs := make(chan error, 16)
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sk, _ := rand.Int(rand.Reader, zkpcp.C.Params().N)
+				PK := zkpcp.Mult(zkpcp.H, sk)
+				value, _ := rand.Int(rand.Reader, big.NewInt(10000000000))
+				CM, r, err := PedCommit(zkpcp, value)
+				if err != nil {
+					errs <- err
+					return
+				}
+				CMTok := zkpcp.Mult(PK, r)
+				proof, err := NewABCProof(zkpcp, CM, CMTok, value, sk, Right)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if _, err := proof.Verify(zkpcp, CM, CMTok, PK); err != nil {
+					errs <- err
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatalf("concurrent ABCProof failed: %v\n", err)
+		}
+	}
+}
+
+func BenchmarkPedCommitRNoTables(b *testing.B) {
+	zkpcp := withoutTables(TestCurve)
+	value, _ := rand.Int(rand.Reader, zkpcp.C.Params().N)
+	randVal, _ := rand.Int(rand.Reader, zkpcp.C.Params().N)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		PedCommitR(zkpcp, value, randVal)
+	}
+}
+
+func benchmarkPedCommitCustomH(b *testing.B, zkpcp ZKPCurveParams) {
+	value, _ := rand.Int(rand.Reader, zkpcp.C.Params().N)
+	PedCommit(zkpcp, value) // builds the tables
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		PedCommit(zkpcp, value)
+	}
+}
+
+func BenchmarkPedCommitCustomH(b *testing.B) {
+	benchmarkPedCommitCustomH(b, secp256k1Curve(b))
+}
+
+func BenchmarkPedCommitCustomHNoTables(b *testing.B) {
+	benchmarkPedCommitCustomH(b, withoutTables(secp256k1Curve(b)))
+}
+
+func benchmarkABCProveCustomH(b *testing.B, zkpcp ZKPCurveParams) {
+	value, _ := rand.Int(rand.Reader, big.NewInt(10000000000))
+	sk, _ := rand.Int(rand.Reader, zkpcp.C.Params().N)
+	PK := zkpcp.Mult(zkpcp.H, sk)
+	CM, r, _ := PedCommit(zkpcp, value)
+	CMTok := zkpcp.Mult(PK, r)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewABCProof(zkpcp, CM, CMTok, value, sk, Right)
+	}
+}
+
+func BenchmarkABCProveCustomH(b *testing.B) {
+	benchmarkABCProveCustomH(b, secp256k1Curve(b))
+}
+
+func BenchmarkABCProveCustomHNoTables(b *testing.B) {
+	benchmarkABCProveCustomH(b, withoutTables(secp256k1Curve(b)))
+}
+
+func benchmarkFixedBaseMult(b *testing.B, window int) {
+	zkpcp := secp256k1Curve(b)
+	fb, _ := NewFixedBase(zkpcp, zkpcp.H, window)
+	x, _ := rand.Int(rand.Reader, zkpcp.C.Params().N)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		fb.Mult(x)
+	}
+}
+
+func BenchmarkFixedBaseMult_4(b *testing.B) { benchmarkFixedBaseMult(b, 4) }
+func BenchmarkFixedBaseMult_8(b *testing.B) { benchmarkFixedBaseMult(b, 8) }
@@ -0,0 +1,455 @@
+package zksigma
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+	"runtime"
+	"sync"
+)
+
+// batchWeightBits is the size of the random weights used to combine the
+// verification equations of a batch, a prover that wants a bad proof to slip
+// through has a 2^-batchWeightBits chance of guessing them.
+const batchWeightBits = 128
+
+// randomWeights returns n random scalars of batchWeightBits bits. They are
+// read from crypto/rand.Reader and not from the EntropySource, which may be
+// a seeded reader in tests, since a prover that can predict the weights can
+// make the equations of bad proofs cancel out.
+func randomWeights(n int) ([]*big.Int, error) {
+	max := new(big.Int).Lsh(big.NewInt(1), batchWeightBits)
+	ws := make([]*big.Int, n)
+	for i := range ws {
+		w, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return nil, err
+		}
+		ws[i] = w
+	}
+	return ws, nil
+}
+
+// NewABCProofBatch generates an ABCProof for each CMs[i], CMToks[i] and
+// values[i], all for the same auditor secret key sk. Like NewABCProofAuto zero
+// values are proven with Left and the others with Right. The inverses of the
+// values and of sk are computed at once with BatchModInverse, and PK = skH is
+// computed only once for the whole batch.
+func NewABCProofBatch(zkpcp ZKPCurveParams, CMs, CMToks []ECPoint, values []*big.Int, sk *big.Int) ([]*ABCProof, error) {
+	return NewABCProofBatchWithRand(zkpcp, entropy(), CMs, CMToks, values, sk)
+}
+
+// NewABCProofBatchWithRand is the same as NewABCProofBatch, but reads its
+// random values from rnd instead of the EntropySource
+func NewABCProofBatchWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	CMs, CMToks []ECPoint, values []*big.Int, sk *big.Int) ([]*ABCProof, error) {
+	return newABCProofBatch(context.Background(), zkpcp, rnd, CMs, CMToks, values, sk)
+}
+
+// NewABCProofBatchCtx is the same as NewABCProofBatch, but stops before the
+// next proof once ctx is done. It then returns the proofs for the values
+// before it, which are valid, and an ErrCanceled error wrapping ctx.Err(). The
+// rest of the batch can be proved later starting at index len(proofs).
+func NewABCProofBatchCtx(ctx context.Context, zkpcp ZKPCurveParams,
+	CMs, CMToks []ECPoint, values []*big.Int, sk *big.Int) ([]*ABCProof, error) {
+	return newABCProofBatch(ctx, zkpcp, entropy(), CMs, CMToks, values, sk)
+}
+
+func newABCProofBatch(ctx context.Context, zkpcp ZKPCurveParams, rnd io.Reader,
+	CMs, CMToks []ECPoint, values []*big.Int, sk *big.Int) ([]*ABCProof, error) {
+
+	if len(CMs) != len(values) || len(CMToks) != len(values) {
+		return nil, inputError("NewABCProofBatch", "CMs, CMToks and values must have the same length")
+	}
+	if err := zkpcp.checkScalars("NewABCProofBatch", "sk", sk); err != nil {
+		return nil, err
+	}
+	if sk.Sign() == 0 {
+		return nil, rangeError("NewABCProofBatch", "sk is zero")
+	}
+	if !zkpcp.validPoints(CMs...) || !zkpcp.validPoints(CMToks...) {
+		return nil, curveError("NewABCProofBatch")
+	}
+	for i, v := range values {
+		if err := zkpcp.checkScalars("NewABCProofBatch", fmt.Sprintf("values[%d]", i), v); err != nil {
+			return nil, err
+		}
+	}
+
+	// the last inverse is the one of sk, zero values get none
+	invs := BatchModInverse(append(values[:len(values):len(values)], sk), zkpcp.C.Params().N)
+	key := newABCKey(zkpcp, sk, invs[len(values)])
+
+	proofs := make([]*ABCProof, len(values))
+	for i, v := range values {
+		if err := ctx.Err(); err != nil {
+			return proofs[:i], canceledError("NewABCProofBatch", i, len(values), err)
+		}
+		option := Right
+		if v.Sign() == 0 {
+			option = Left
+		}
+		prover, err := abcCommitKey(zkpcp, rnd, CMs[i], CMToks[i], v, invs[i], key, option)
+		if err != nil {
+			return nil, wrapError("NewABCProofBatch", fmt.Sprintf("proof %d failed: %v", i, err), err)
+		}
+		proofs[i] = prover.prove(zkpcp, CMs[i], CMToks[i], nil)
+	}
+	return proofs, nil
+}
+
+// VerifyABCBatch checks if every ABCProof in proofs is valid for the matching
+// CMs, CMToks and auditor public keys PKs. All verification equations,
+// including the ones of the nested DisjunctiveProofs and ConsistencyProofs, are
+// combined with random weights into one multi-scalar multiplication:
+//
+//	w1(cCM + T1 - jG - kCMTok) + w2(cC + T2 - jB - lH)
+//	 + w3(T1' + c1CMTok - s1CM) + w4(T2' + c2(C - G) - s2H)
+//	 + w5(T1'' + c''C - s1''G - s2''H) + w6(T2'' + c''CToken - s2''PK) ?= 0
+//
+// If the batch does not check out every proof is verified on its own and the
+// error reports the index of the first bad one.
+func VerifyABCBatch(zkpcp ZKPCurveParams, proofs []*ABCProof, CMs, CMToks, PKs []ECPoint) (bool, error) {
+	if len(proofs) != len(CMs) || len(proofs) != len(CMToks) || len(proofs) != len(PKs) {
+		return false, inputError("VerifyABCBatch", "proofs, CMs, CMToks and PKs must have the same length")
+	}
+
+	if ok, _ := batchABCCheck(context.Background(), zkpcp, proofs, CMs, CMToks, PKs); !ok {
+		for i, p := range proofs {
+			if _, err := p.Verify(zkpcp, CMs[i], CMToks[i], PKs[i]); err != nil {
+				return false, wrapError("VerifyABCBatch", fmt.Sprintf("proof %d failed: %v", i, err), err)
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// abcBatchChunk is the number of proofs VerifyABCBatchCtx combines into one
+// check, the ones of the chunks checked before ctx is done stay verified
+const abcBatchChunk = 64
+
+// VerifyABCBatchCtx is the same as VerifyABCBatch, but checks proofs in chunks
+// of abcBatchChunk and stops between two proofs once ctx is done. It returns
+// the number of proofs at the start of proofs that are known to be valid,
+// which is len(proofs) with a nil error if all of them are. Otherwise the
+// error is the one of proofs[n], or an ErrCanceled error wrapping ctx.Err()
+// and the batch can be resumed at proofs[n:].
+func VerifyABCBatchCtx(ctx context.Context, zkpcp ZKPCurveParams, proofs []*ABCProof, CMs, CMToks, PKs []ECPoint) (int, error) {
+	if len(proofs) != len(CMs) || len(proofs) != len(CMToks) || len(proofs) != len(PKs) {
+		return 0, inputError("VerifyABCBatch", "proofs, CMs, CMToks and PKs must have the same length")
+	}
+
+	for start := 0; start < len(proofs); start += abcBatchChunk {
+		end := start + abcBatchChunk
+		if end > len(proofs) {
+			end = len(proofs)
+		}
+		ok, err := batchABCCheck(ctx, zkpcp, proofs[start:end], CMs[start:end], CMToks[start:end], PKs[start:end])
+		if err != nil {
+			return start, canceledError("VerifyABCBatch", start, len(proofs), err)
+		}
+		if ok {
+			continue
+		}
+		for i := start; i < end; i++ {
+			if err := ctx.Err(); err != nil {
+				return i, canceledError("VerifyABCBatch", i, len(proofs), err)
+			}
+			if _, err := proofs[i].Verify(zkpcp, CMs[i], CMToks[i], PKs[i]); err != nil {
+				return i, wrapError("VerifyABCBatch", fmt.Sprintf("proof %d failed: %v", i, err), err)
+			}
+		}
+	}
+
+	return len(proofs), nil
+}
+
+// batchABCCheck does the combined check for VerifyABCBatch, it returns false
+// if any proof is bad but does not tell which one. It returns the error of ctx
+// if ctx is done before the check is.
+func batchABCCheck(ctx context.Context, zkpcp ZKPCurveParams, proofs []*ABCProof, CMs, CMToks, PKs []ECPoint) (bool, error) {
+	N := zkpcp.C.Params().N
+	ws, err := randomWeights(6 * len(proofs))
+	if err != nil {
+		return false, nil
+	}
+
+	points := make([]ECPoint, 0, 12*len(proofs)+2)
+	scalars := make([]*big.Int, 0, 12*len(proofs)+2)
+	gScalar := new(big.Int)
+	hScalar := new(big.Int)
+
+	mul := func(a, b *big.Int) *big.Int {
+		return new(big.Int).Mul(a, b)
+	}
+
+	for i, p := range proofs {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		if p == nil || p.disjuncAC == nil || p.cTokenProof == nil {
+			return false, nil
+		}
+		// a proof with a bad point, or for CM or CMTok = Zero, fails the batch,
+		// Verify tells why
+		dj, ct := p.disjuncAC, p.cTokenProof
+		if !zkpcp.validPoints(CMs[i], CMToks[i], PKs[i]) ||
+			!zkpcp.onCurve(p.B, p.C, p.T1, p.T2, p.T3, p.CToken, dj.T1, dj.T2, ct.T1, ct.T2) ||
+			CMs[i].IsZero() || CMToks[i].IsZero() {
+			return false, nil
+		}
+		// the equations of a Delegated proof differ, it is checked on its own
+		if p.Delegated {
+			if ok, _ := p.Verify(zkpcp, CMs[i], CMToks[i], PKs[i]); !ok {
+				return false, nil
+			}
+			continue
+		}
+		// a proof with a non-canonical scalar fails the batch, Verify tells which
+		if zkpcp.canonicalScalars("VerifyABCBatch", "Challenge, j, k, l, C, C1, C2, S1, S2, Challenge, S1, S2",
+			p.Challenge, p.j, p.k, p.l, dj.C, dj.C1, dj.C2, dj.S1, dj.S2, ct.Challenge, ct.S1, ct.S2) != nil {
+			return false, nil
+		}
+		CM, CMTok, PK := CMs[i], CMToks[i], PKs[i]
+		CMinusG := zkpcp.Sub(p.C, zkpcp.G)
+
+		// the challenges can not be batched, check them on their own
+		c := abcChallenge(zkpcp, CM, CMTok, p.B, p.C, p.T1, p.T2, p.CToken, p.T3, nil)
+		if !scalarsEqual(c, p.Challenge) {
+			return false, nil
+		}
+		ctc := consistencyChallenge(zkpcp, p.C, p.CToken, PK, ct.T1, ct.T2, nil)
+		if !scalarsEqual(ctc, ct.Challenge) {
+			return false, nil
+		}
+		djc := disjunctiveChallenge(zkpcp, CM, CMTok, zkpcp.H, CMinusG, dj.T1, dj.T2, nil)
+		totalC := new(big.Int).Add(dj.C1, dj.C2)
+		if !scalarsEqual(djc, dj.C) || !scalarsEqual(totalC.Mod(totalC, N), dj.C) {
+			return false, nil
+		}
+
+		w1, w2, w3, w4, w5, w6 := ws[6*i], ws[6*i+1], ws[6*i+2], ws[6*i+3], ws[6*i+4], ws[6*i+5]
+		C := new(big.Int).Add(mul(w2, c), mul(w4, dj.C2))
+		points = append(points, CM, p.T1, CMTok, p.C, p.T2, p.B, dj.T1, dj.T2,
+			ct.T1, ct.T2, p.CToken, PK)
+		scalars = append(scalars,
+			new(big.Int).Sub(mul(w1, c), mul(w3, dj.S1)), // CM
+			w1, // T1
+			new(big.Int).Sub(mul(w3, dj.C1), mul(w1, p.k)), // CMTok
+			C.Add(C, mul(w5, ct.Challenge)),                // C
+			w2,                                             // T2
+			new(big.Int).Neg(mul(w2, p.j)),                 // B
+			w3,                                             // disjuncAC.T1
+			w4,                                             // disjuncAC.T2
+			w5,                                             // cTokenProof.T1
+			w6,                                             // cTokenProof.T2
+			mul(w6, ct.Challenge),                          // CToken
+			new(big.Int).Neg(mul(w6, ct.S2)),               // PK
+		)
+		gScalar.Sub(gScalar, mul(w1, p.j))
+		gScalar.Sub(gScalar, mul(w4, dj.C2))
+		gScalar.Sub(gScalar, mul(w5, ct.S1))
+		hScalar.Sub(hScalar, mul(w2, p.l))
+		hScalar.Sub(hScalar, mul(w4, dj.S2))
+		hScalar.Sub(hScalar, mul(w5, ct.S2))
+	}
+	points = append(points, zkpcp.G, zkpcp.H)
+	scalars = append(scalars, gScalar, hScalar)
+
+	return zkpcp.MultiMult(points, scalars).Equal(Zero), nil
+}
+
+// VerifyConsistencyBatch checks if every ConsistencyProof in proofs is valid
+// for the matching CMs, CMToks and public keys PKs. The verification equations
+// of all proofs are combined with random weights into one multi-scalar
+// multiplication:
+//
+//	w1(s1G + s2H - cCM - T1) + w2(s2PK - cCMTok - T2) ?= 0
+//
+// It returns true and -1 if all proofs are valid. Otherwise the batch is
+// bisected, by checking the first half of what is left on its own, and it
+// returns false, the index of the first bad proof and the error of its Verify.
+func VerifyConsistencyBatch(zkpcp ZKPCurveParams, proofs []*ConsistencyProof, CMs, CMToks, PKs []ECPoint) (bool, int, error) {
+	if len(proofs) != len(CMs) || len(proofs) != len(CMToks) || len(proofs) != len(PKs) {
+		return false, -1, inputError("VerifyConsistencyBatch", "proofs, CMs, CMToks and PKs must have the same length")
+	}
+	if batchConsistencyCheck(zkpcp, proofs, CMs, CMToks, PKs) {
+		return true, -1, nil
+	}
+
+	// [lo, hi) holds the first bad proof
+	lo, hi := 0, len(proofs)
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if batchConsistencyCheck(zkpcp, proofs[lo:mid], CMs[lo:mid], CMToks[lo:mid], PKs[lo:mid]) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	if _, err := proofs[lo].Verify(zkpcp, CMs[lo], CMToks[lo], PKs[lo]); err != nil {
+		return false, lo, wrapError("VerifyConsistencyBatch", fmt.Sprintf("proof %d failed: %v", lo, err), err)
+	}
+
+	// a failed check that is not for a bad proof, like one without weights
+	// because crypto/rand failed, leaves verifying them one by one
+	for i, p := range proofs {
+		if _, err := p.Verify(zkpcp, CMs[i], CMToks[i], PKs[i]); err != nil {
+			return false, i, wrapError("VerifyConsistencyBatch", fmt.Sprintf("proof %d failed: %v", i, err), err)
+		}
+	}
+	return true, -1, nil
+}
+
+// batchConsistencyCheck does the combined check for VerifyConsistencyBatch, it
+// returns false if any proof is bad but does not tell which one
+func batchConsistencyCheck(zkpcp ZKPCurveParams, proofs []*ConsistencyProof, CMs, CMToks, PKs []ECPoint) bool {
+	ws, err := randomWeights(2 * len(proofs))
+	if err != nil {
+		return false
+	}
+
+	points := make([]ECPoint, 0, 5*len(proofs)+2)
+	scalars := make([]*big.Int, 0, 5*len(proofs)+2)
+	gScalar := new(big.Int)
+	hScalar := new(big.Int)
+
+	for i, p := range proofs {
+		if p == nil {
+			return false
+		}
+		CM, CMTok, PK := CMs[i], CMToks[i], PKs[i]
+		// a proof with a bad point or scalar fails the batch, Verify tells why
+		if !zkpcp.validPoints(CM, CMTok, PK) || !zkpcp.onCurve(p.T1, p.T2) ||
+			zkpcp.canonicalScalars("VerifyConsistencyBatch", "Challenge, S1, S2", p.Challenge, p.S1, p.S2) != nil {
+			return false
+		}
+		// the challenges can not be batched, check them on their own
+		if !scalarsEqual(consistencyChallenge(zkpcp, CM, CMTok, PK, p.T1, p.T2, nil), p.Challenge) {
+			return false
+		}
+
+		w1, w2 := ws[2*i], ws[2*i+1]
+		points = append(points, CM, p.T1, PK, CMTok, p.T2)
+		scalars = append(scalars,
+			new(big.Int).Neg(new(big.Int).Mul(w1, p.Challenge)), // CM
+			new(big.Int).Neg(w1),                                // T1
+			new(big.Int).Mul(w2, p.S2),                          // PK
+			new(big.Int).Neg(new(big.Int).Mul(w2, p.Challenge)), // CMTok
+			new(big.Int).Neg(w2),                                // T2
+		)
+		gScalar.Add(gScalar, new(big.Int).Mul(w1, p.S1))
+		hScalar.Add(hScalar, new(big.Int).Mul(w1, p.S2))
+	}
+	points = append(points, zkpcp.G, zkpcp.H)
+	scalars = append(scalars, gScalar, hScalar)
+
+	return zkpcp.MultiMult(points, scalars).Equal(Zero)
+}
+
+// ProveAll generates the ABCProof of each of statements with the witness of
+// the same index, like NewABCProofFromStatement with the side picked from the
+// value like NewABCProofAuto, on a pool of workers goroutines, or GOMAXPROCS
+// of them if workers is 0. proofs[i] is the proof of statements[i].
+// If a proof fails no more are started and the error is the one of the failed
+// statement with the lowest index.
+//
+// Generating proofs only reads zkpcp and the generator tables it builds once,
+// and the EntropySource is safe for concurrent use, so the proofs do not need to be
+// serialized.
+func ProveAll(zkpcp ZKPCurveParams, statements []ABCStatement, witnesses []ABCWitness, workers int) ([]*ABCProof, error) {
+	if len(statements) != len(witnesses) {
+		return nil, inputError("ProveAll", "statements and witnesses must have the same length")
+	}
+	if workers < 0 {
+		return nil, inputError("ProveAll", fmt.Sprintf("negative number of workers %d", workers))
+	}
+	proofs := make([]*ABCProof, len(statements))
+	i, err := runAll(len(statements), workers, func(i int) error {
+		w := witnesses[i]
+		option := Right
+		if w.Value != nil && w.Value.Sign() == 0 {
+			option = Left
+		}
+		var err error
+		proofs[i], err = NewABCProofFromStatement(zkpcp, statements[i], w, option)
+		return err
+	})
+	if err != nil {
+		return nil, wrapError("ProveAll", fmt.Sprintf("statement %d failed: %v", i, err), err)
+	}
+	return proofs, nil
+}
+
+// VerifyAll checks if every proofs[i] is a valid ABCProof for the CM, CMTok
+// and PubKey of statements[i] on a pool of workers goroutines, or GOMAXPROCS
+// of them if workers is 0. If a proof fails no more are started and the error
+// is the one of the failed proof with the lowest index.
+func VerifyAll(zkpcp ZKPCurveParams, proofs []*ABCProof, statements []ABCStatement, workers int) (bool, error) {
+	if len(proofs) != len(statements) {
+		return false, inputError("VerifyAll", "proofs and statements must have the same length")
+	}
+	if workers < 0 {
+		return false, inputError("VerifyAll", fmt.Sprintf("negative number of workers %d", workers))
+	}
+	i, err := runAll(len(proofs), workers, func(i int) error {
+		_, err := proofs[i].VerifyStatement(zkpcp, statements[i])
+		return err
+	})
+	if err != nil {
+		return false, wrapError("VerifyAll", fmt.Sprintf("proof %d failed: %v", i, err), err)
+	}
+	return true, nil
+}
+
+// runAll calls f(i) for every i in [0, n) on a pool of workers goroutines, or
+// GOMAXPROCS of them if workers is 0. Indices are handed out in order and none
+// after the first failure, so every index below a failed one has been run and
+// the lowest i that failed is returned with its error.
+func runAll(n, workers int, f func(i int) error) (int, error) {
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > n {
+		workers = n
+	}
+
+	var (
+		mu       sync.Mutex
+		failed   int
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	jobs := make(chan int)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := f(i); err != nil {
+					mu.Lock()
+					if firstErr == nil || i < failed {
+						failed, firstErr = i, err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		mu.Lock()
+		stop := firstErr != nil
+		mu.Unlock()
+		if stop {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return failed, firstErr
+}
@@ -0,0 +1,625 @@
+package zksigma
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func makeABCBatch(tb testing.TB, n int) ([]*ABCProof, []ECPoint, []ECPoint, []ECPoint) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+
+	proofs := make([]*ABCProof, n)
+	CMs := make([]ECPoint, n)
+	CMToks := make([]ECPoint, n)
+	PKs := make([]ECPoint, n)
+	for ii := 0; ii < n; ii++ {
+		value := big.NewInt(int64(ii % 3)) // mix of Left and Right proofs
+		side := Right
+		if value.Sign() == 0 {
+			side = Left
+		}
+		CM, u, err := PedCommit(TestCurve, value)
+		if err != nil {
+			tb.Fatalf("%v\n", err)
+		}
+		CMs[ii] = CM
+		CMToks[ii] = TestCurve.Mult(PK, u)
+		PKs[ii] = PK
+		proofs[ii], err = NewABCProof(TestCurve, CMs[ii], CMToks[ii], value, sk, side)
+		if err != nil {
+			tb.Fatalf("%v\n", err)
+		}
+	}
+	return proofs, CMs, CMToks, PKs
+}
+
+func TestVerifyABCBatch(t *testing.T) {
+	proofs, CMs, CMToks, PKs := makeABCBatch(t, 10)
+
+	ok, err := VerifyABCBatch(TestCurve, proofs, CMs, CMToks, PKs)
+	if !ok || err != nil {
+		t.Fatalf("VerifyABCBatch rejected a valid batch: %v\n", err)
+	}
+
+	// corrupt a single proof in the middle of the batch
+	bad := *proofs[5]
+	bad.l = new(big.Int).Add(bad.l, big.NewInt(1))
+	proofs[5] = &bad
+
+	ok, err = VerifyABCBatch(TestCurve, proofs, CMs, CMToks, PKs)
+	if ok || err == nil {
+		t.Fatalf("VerifyABCBatch accepted a batch with a bad proof\n")
+	}
+	if !strings.Contains(err.Error(), "proof 5 failed") {
+		t.Fatalf("VerifyABCBatch reported the wrong proof: %v\n", err)
+	}
+
+	// CToken has to match the auditor public key of its proof
+	proofs, CMs, CMToks, PKs = makeABCBatch(t, 4)
+	PKs[2] = TestCurve.Mult(TestCurve.H, big.NewInt(3))
+	ok, err = VerifyABCBatch(TestCurve, proofs, CMs, CMToks, PKs)
+	if ok || err == nil || !strings.Contains(err.Error(), "proof 2 failed") {
+		t.Fatalf("VerifyABCBatch accepted a proof for the wrong PK: %v\n", err)
+	}
+
+	if _, err := VerifyABCBatch(TestCurve, proofs, CMs[1:], CMToks, PKs); err == nil {
+		t.Fatalf("VerifyABCBatch accepted slices of different lengths\n")
+	}
+
+	// the weights come from crypto/rand, not from an EntropySource that a
+	// prover might be able to predict
+	proofs, CMs, CMToks, PKs = makeABCBatch(t, 4)
+	old := SetEntropySource(&failingSource{})
+	defer SetEntropySource(old)
+	if ok, err := VerifyABCBatch(TestCurve, proofs, CMs, CMToks, PKs); !ok || err != nil {
+		t.Fatalf("VerifyABCBatch reads its weights from the EntropySource: %v\n", err)
+	}
+}
+
+// TestVerifyABCBatchZeroCM makes sure the batch rejects a proof for CM or
+// CMTok = Zero with the error of Verify
+func TestVerifyABCBatchZeroCM(t *testing.T) {
+	for name, zero := range map[string]func(CMs, CMToks []ECPoint){
+		"CM":    func(CMs, CMToks []ECPoint) { CMs[1] = Zero },
+		"CMTok": func(CMs, CMToks []ECPoint) { CMToks[1] = Zero },
+		"both":  func(CMs, CMToks []ECPoint) { CMs[1], CMToks[1] = Zero, Zero },
+	} {
+		proofs, CMs, CMToks, PKs := makeABCBatch(t, 3)
+		zero(CMs, CMToks)
+		if ok, err := proofs[1].Verify(TestCurve, CMs[1], CMToks[1], PKs[1]); ok || !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("Verify with %s = Zero gives %v\n", name, err)
+		}
+		if ok, err := VerifyABCBatch(TestCurve, proofs, CMs, CMToks, PKs); ok || !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("VerifyABCBatch with %s = Zero gives %v\n", name, err)
+		}
+		if n, err := VerifyABCBatchCtx(context.Background(), TestCurve, proofs, CMs, CMToks, PKs); n != 1 || !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("VerifyABCBatchCtx with %s = Zero gives %d, %v\n", name, n, err)
+		}
+	}
+}
+
+// TestVerifyABCBatchNotOnCurve makes sure the batch rejects a point not on the
+// curve, passed in or in a proof, with the error of Verify
+func TestVerifyABCBatchNotOnCurve(t *testing.T) {
+	off := ECPoint{big.NewInt(1), big.NewInt(1)}
+	for name, corrupt := range map[string]func(p *ABCProof, CMs, CMToks, PKs []ECPoint) *ABCProof{
+		"CM":    func(p *ABCProof, CMs, CMToks, PKs []ECPoint) *ABCProof { CMs[1] = off; return p },
+		"CMTok": func(p *ABCProof, CMs, CMToks, PKs []ECPoint) *ABCProof { CMToks[1] = off; return p },
+		"PK":    func(p *ABCProof, CMs, CMToks, PKs []ECPoint) *ABCProof { PKs[1] = off; return p },
+		"T1":    func(p *ABCProof, CMs, CMToks, PKs []ECPoint) *ABCProof { p = p.Clone(); p.T1 = off; return p },
+		"CToken": func(p *ABCProof, CMs, CMToks, PKs []ECPoint) *ABCProof {
+			p = p.Clone()
+			p.CToken = off
+			return p
+		},
+		"disjunctive T2": func(p *ABCProof, CMs, CMToks, PKs []ECPoint) *ABCProof {
+			p = p.Clone()
+			p.disjuncAC.T2 = off
+			return p
+		},
+		"consistency T1": func(p *ABCProof, CMs, CMToks, PKs []ECPoint) *ABCProof {
+			p = p.Clone()
+			p.cTokenProof.T1 = off
+			return p
+		},
+	} {
+		proofs, CMs, CMToks, PKs := makeABCBatch(t, 3)
+		proofs[1] = corrupt(proofs[1], CMs, CMToks, PKs)
+		if ok, err := proofs[1].Verify(TestCurve, CMs[1], CMToks[1], PKs[1]); ok || !errors.Is(err, ErrNotOnCurve) {
+			t.Fatalf("Verify with %s not on the curve gives %v\n", name, err)
+		}
+		if ok, err := VerifyABCBatch(TestCurve, proofs, CMs, CMToks, PKs); ok || !errors.Is(err, ErrNotOnCurve) {
+			t.Fatalf("VerifyABCBatch with %s not on the curve gives %v\n", name, err)
+		}
+		if n, err := VerifyABCBatchCtx(context.Background(), TestCurve, proofs, CMs, CMToks, PKs); n != 1 || !errors.Is(err, ErrNotOnCurve) {
+			t.Fatalf("VerifyABCBatchCtx with %s not on the curve gives %d, %v\n", name, n, err)
+		}
+	}
+}
+
+func BenchmarkVerifyABCBatch_100(b *testing.B) {
+	proofs, CMs, CMToks, PKs := makeABCBatch(b, 100)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		VerifyABCBatch(TestCurve, proofs, CMs, CMToks, PKs)
+	}
+}
+
+func BenchmarkVerifyABCLoop_100(b *testing.B) {
+	proofs, CMs, CMToks, PKs := makeABCBatch(b, 100)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		for jj := range proofs {
+			proofs[jj].Verify(TestCurve, CMs[jj], CMToks[jj], PKs[jj])
+		}
+	}
+}
+
+func TestNewABCProofBatch(t *testing.T) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+
+	n := 9
+	values := make([]*big.Int, n)
+	CMs := make([]ECPoint, n)
+	CMToks := make([]ECPoint, n)
+	PKs := make([]ECPoint, n)
+	for ii := range values {
+		values[ii] = big.NewInt(int64(ii % 3)) // zeros are proven with Left
+		CM, u, err := PedCommit(TestCurve, values[ii])
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		CMs[ii], CMToks[ii], PKs[ii] = CM, TestCurve.Mult(PK, u), PK
+	}
+
+	proofs, err := NewABCProofBatch(TestCurve, CMs, CMToks, values, sk)
+	if err != nil {
+		t.Fatalf("NewABCProofBatch failed: %v\n", err)
+	}
+	if len(proofs) != n {
+		t.Fatalf("NewABCProofBatch returned %d proofs for %d values\n", len(proofs), n)
+	}
+	for ii, p := range proofs {
+		if ok, err := p.Verify(TestCurve, CMs[ii], CMToks[ii], PK); !ok || err != nil {
+			t.Fatalf("proof %d of NewABCProofBatch failed to verify: %v\n", ii, err)
+		}
+	}
+	if ok, err := VerifyABCBatch(TestCurve, proofs, CMs, CMToks, PKs); !ok || err != nil {
+		t.Fatalf("VerifyABCBatch failed for NewABCProofBatch: %v\n", err)
+	}
+
+	// the same random values give the same proofs as NewABCProofAuto
+	batch, _ := NewABCProofBatchWithRand(TestCurve, newSeededReader("batch"), CMs[:2], CMToks[:2], values[:2], sk)
+	rnd := newSeededReader("batch")
+	for ii, option := range []Side{Left, Right} {
+		single, _ := NewABCProofWithRand(TestCurve, rnd, CMs[ii], CMToks[ii], values[ii], sk, option)
+		if !single.Equal(batch[ii]) {
+			t.Fatalf("proof %d of NewABCProofBatch differs from NewABCProof\n", ii)
+		}
+	}
+
+	if proofs, err := NewABCProofBatch(TestCurve, nil, nil, nil, sk); err != nil || len(proofs) != 0 {
+		t.Fatalf("NewABCProofBatch of nothing gives %v, %v\n", proofs, err)
+	}
+	if _, err := NewABCProofBatch(TestCurve, CMs, CMToks[:1], values, sk); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("NewABCProofBatch with different lengths gives %v\n", err)
+	}
+	if _, err := NewABCProofBatch(TestCurve, CMs, CMToks, values, big.NewInt(0)); !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("NewABCProofBatch with a zero sk gives %v\n", err)
+	}
+	bad := append([]*big.Int{}, values...)
+	bad[4] = TestCurve.C.Params().N
+	if _, err := NewABCProofBatch(TestCurve, CMs, CMToks, bad, sk); !errors.Is(err, ErrValueOutOfRange) || !strings.Contains(err.Error(), "values[4]") {
+		t.Fatalf("NewABCProofBatch with a value out of range gives %v\n", err)
+	}
+}
+
+// cancelAfter is a context whose Err returns nil for the first n calls and err
+// after that, which cancels a batch at a known point
+type cancelAfter struct {
+	context.Context
+	n   int32
+	err error
+}
+
+func newCancelAfter(n int, err error) *cancelAfter {
+	return &cancelAfter{context.Background(), int32(n), err}
+}
+
+func (c *cancelAfter) Err() error {
+	if atomic.AddInt32(&c.n, -1) < 0 {
+		return c.err
+	}
+	return nil
+}
+
+// checkGoroutines fails t if more goroutines than before are still running
+// once the ones that are done had some time to exit
+func checkGoroutines(t *testing.T, before int) {
+	for ii := 0; ii < 100 && runtime.NumGoroutine() > before; ii++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("%d goroutines are left over from a canceled batch\n", n-before)
+	}
+}
+
+// checkCanceled fails t unless err is an ErrCanceled error wrapping want
+func checkCanceled(t *testing.T, name string, err, want error) {
+	if !errors.Is(err, ErrCanceled) || !errors.Is(err, want) {
+		t.Fatalf("%s gives %v, expected ErrCanceled wrapping %v\n", name, err, want)
+	}
+}
+
+func TestABCProofBatchCtx(t *testing.T) {
+	before := runtime.NumGoroutine()
+	CMs, CMToks, values, sk := abcProveInputs(9)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	PKs := []ECPoint{PK, PK, PK, PK, PK, PK, PK, PK, PK}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	proofs, err := NewABCProofBatchCtx(canceled, TestCurve, CMs, CMToks, values, sk)
+	checkCanceled(t, "NewABCProofBatchCtx with a canceled context", err, context.Canceled)
+	if len(proofs) != 0 {
+		t.Fatalf("NewABCProofBatchCtx with a canceled context returned %d proofs\n", len(proofs))
+	}
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err = NewABCProofBatchCtx(expired, TestCurve, CMs, CMToks, values, sk)
+	checkCanceled(t, "NewABCProofBatchCtx after the deadline", err, context.DeadlineExceeded)
+
+	// canceled before the fifth proof, the rest is proved when resuming
+	proofs, err = NewABCProofBatchCtx(newCancelAfter(4, context.Canceled), TestCurve, CMs, CMToks, values, sk)
+	checkCanceled(t, "NewABCProofBatchCtx canceled in the middle", err, context.Canceled)
+	if len(proofs) != 4 || !strings.Contains(err.Error(), "after 4 of 9") {
+		t.Fatalf("NewABCProofBatchCtx canceled in the middle returned %d proofs: %v\n", len(proofs), err)
+	}
+	rest, err := NewABCProofBatchCtx(context.Background(), TestCurve, CMs[4:], CMToks[4:], values[4:], sk)
+	if err != nil {
+		t.Fatalf("NewABCProofBatchCtx failed to resume: %v\n", err)
+	}
+	proofs = append(proofs, rest...)
+	if n, err := VerifyABCBatchCtx(context.Background(), TestCurve, proofs, CMs, CMToks, PKs); n != 9 || err != nil {
+		t.Fatalf("VerifyABCBatchCtx of a resumed batch gives %d, %v\n", n, err)
+	}
+
+	for _, ctx := range []context.Context{canceled, expired} {
+		if n, err := VerifyABCBatchCtx(ctx, TestCurve, proofs, CMs, CMToks, PKs); n != 0 || !errors.Is(err, ErrCanceled) {
+			t.Fatalf("VerifyABCBatchCtx with a done context gives %d, %v\n", n, err)
+		}
+	}
+
+	bad := *proofs[5]
+	bad.l = new(big.Int).Add(bad.l, big.NewInt(1))
+	proofs[5] = &bad
+	if n, err := VerifyABCBatchCtx(context.Background(), TestCurve, proofs, CMs, CMToks, PKs); n != 5 || !errors.Is(err, ErrEquationFailed) {
+		t.Fatalf("VerifyABCBatchCtx with a bad proof 5 gives %d, %v\n", n, err)
+	}
+	if _, err := VerifyABCBatchCtx(context.Background(), TestCurve, proofs, CMs[1:], CMToks, PKs); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("VerifyABCBatchCtx accepted slices of different lengths: %v\n", err)
+	}
+	checkGoroutines(t, before)
+}
+
+func TestVerifyABCBatchCtxChunks(t *testing.T) {
+	n := abcBatchChunk + 6
+	proofs, CMs, CMToks, PKs := makeABCBatch(t, n)
+
+	// the first chunk is verified, the second one is canceled at its third proof
+	ctx := newCancelAfter(abcBatchChunk+2, context.DeadlineExceeded)
+	done, err := VerifyABCBatchCtx(ctx, TestCurve, proofs, CMs, CMToks, PKs)
+	checkCanceled(t, "VerifyABCBatchCtx canceled in the second chunk", err, context.DeadlineExceeded)
+	if done != abcBatchChunk {
+		t.Fatalf("VerifyABCBatchCtx canceled in the second chunk verified %d proofs\n", done)
+	}
+	if rest, err := VerifyABCBatchCtx(context.Background(), TestCurve,
+		proofs[done:], CMs[done:], CMToks[done:], PKs[done:]); rest != n-done || err != nil {
+		t.Fatalf("VerifyABCBatchCtx failed to resume: %d, %v\n", rest, err)
+	}
+}
+
+// makeConsistencyBatch returns n ConsistencyProofs of random values for the
+// keys of a few banks, with their commitments, tokens and keys
+func makeConsistencyBatch(tb testing.TB, n int) ([]*ConsistencyProof, []ECPoint, []ECPoint, []ECPoint) {
+	banks := make([]ECPoint, 3)
+	for i := range banks {
+		banks[i], _ = KeyGen(TestCurve.C, TestCurve.H)
+	}
+	proofs := make([]*ConsistencyProof, n)
+	CMs := make([]ECPoint, n)
+	CMToks := make([]ECPoint, n)
+	PKs := make([]ECPoint, n)
+	for ii := 0; ii < n; ii++ {
+		value, _ := rand.Int(rand.Reader, big.NewInt(1000))
+		CM, r, err := PedCommit(TestCurve, value)
+		if err != nil {
+			tb.Fatalf("%v\n", err)
+		}
+		CMs[ii], PKs[ii] = CM, banks[ii%len(banks)]
+		CMToks[ii] = TestCurve.Mult(PKs[ii], r)
+		if proofs[ii], err = NewConsistencyProof(TestCurve, CM, CMToks[ii], PKs[ii], value, r); err != nil {
+			tb.Fatalf("%v\n", err)
+		}
+	}
+	return proofs, CMs, CMToks, PKs
+}
+
+func TestVerifyConsistencyBatch(t *testing.T) {
+	proofs, CMs, CMToks, PKs := makeConsistencyBatch(t, 12)
+	if ok, bad, err := VerifyConsistencyBatch(TestCurve, proofs, CMs, CMToks, PKs); !ok || bad != -1 || err != nil {
+		t.Fatalf("VerifyConsistencyBatch rejected a valid batch: %d, %v\n", bad, err)
+	}
+	if ok, bad, err := VerifyConsistencyBatch(TestCurve, nil, nil, nil, nil); !ok || bad != -1 || err != nil {
+		t.Fatalf("VerifyConsistencyBatch rejected an empty batch: %d, %v\n", bad, err)
+	}
+
+	// the lengths are checked before any proof
+	for _, lens := range [][4]int{{12, 11, 12, 12}, {12, 12, 11, 12}, {12, 12, 12, 0}, {11, 12, 12, 12}} {
+		if ok, bad, err := VerifyConsistencyBatch(TestCurve, proofs[:lens[0]], CMs[:lens[1]],
+			CMToks[:lens[2]], PKs[:lens[3]]); ok || bad != -1 || !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("VerifyConsistencyBatch of slices of lengths %v gives %v, %d, %v\n", lens, ok, bad, err)
+		}
+	}
+
+	// corrupted entries, the result has to be the one of looping Verify
+	corruptions := []func(i int, proofs []*ConsistencyProof, CMs, CMToks, PKs []ECPoint){
+		func(i int, proofs []*ConsistencyProof, CMs, CMToks, PKs []ECPoint) {
+			p := *proofs[i]
+			p.S1 = new(big.Int).Add(p.S1, big.NewInt(1))
+			proofs[i] = &p
+		},
+		func(i int, proofs []*ConsistencyProof, CMs, CMToks, PKs []ECPoint) {
+			p := *proofs[i]
+			p.Challenge = new(big.Int).Add(p.Challenge, big.NewInt(1))
+			proofs[i] = &p
+		},
+		func(i int, proofs []*ConsistencyProof, CMs, CMToks, PKs []ECPoint) {
+			PKs[i] = TestCurve.Add(PKs[i], TestCurve.H)
+		},
+		func(i int, proofs []*ConsistencyProof, CMs, CMToks, PKs []ECPoint) {
+			CMToks[i] = CMToks[(i+1)%len(CMToks)]
+		},
+		func(i int, proofs []*ConsistencyProof, CMs, CMToks, PKs []ECPoint) {
+			p := *proofs[i]
+			p.T2 = ECPoint{big.NewInt(1), big.NewInt(1)}
+			proofs[i] = &p
+		},
+		func(i int, proofs []*ConsistencyProof, CMs, CMToks, PKs []ECPoint) {
+			proofs[i] = nil
+		},
+	}
+	for round := 0; round < 20; round++ {
+		size, _ := rand.Int(rand.Reader, big.NewInt(16))
+		n := int(size.Int64()) + 1
+		proofs, CMs, CMToks, PKs := makeConsistencyBatch(t, n)
+		count, _ := rand.Int(rand.Reader, big.NewInt(3))
+		for k := int64(0); k < count.Int64(); k++ {
+			i, _ := rand.Int(rand.Reader, big.NewInt(int64(n)))
+			which, _ := rand.Int(rand.Reader, big.NewInt(int64(len(corruptions))))
+			if proofs[i.Int64()] == nil {
+				continue
+			}
+			corruptions[which.Int64()](int(i.Int64()), proofs, CMs, CMToks, PKs)
+		}
+
+		want, wantErr := -1, error(nil)
+		for i, p := range proofs {
+			if _, err := p.Verify(TestCurve, CMs[i], CMToks[i], PKs[i]); err != nil {
+				want, wantErr = i, err
+				break
+			}
+		}
+		ok, bad, err := VerifyConsistencyBatch(TestCurve, proofs, CMs, CMToks, PKs)
+		if ok != (want == -1) || bad != want {
+			t.Fatalf("VerifyConsistencyBatch of round %d gives %v, %d, expected proof %d to fail: %v\n", round, ok, bad, want, err)
+		}
+		if want != -1 {
+			var pe, wantPE *ProofError
+			if !errors.As(err, &pe) || !errors.As(wantErr, &wantPE) || pe.Code != wantPE.Code ||
+				!strings.Contains(err.Error(), fmt.Sprintf("proof %d failed", want)) {
+				t.Fatalf("VerifyConsistencyBatch of round %d gives %v, Verify gives %v\n", round, err, wantErr)
+			}
+		}
+	}
+
+	// the weights come from crypto/rand, not from an EntropySource that a
+	// prover might be able to predict
+	proofs, CMs, CMToks, PKs = makeConsistencyBatch(t, 4)
+	old := SetEntropySource(&failingSource{})
+	defer SetEntropySource(old)
+	if ok, bad, err := VerifyConsistencyBatch(TestCurve, proofs, CMs, CMToks, PKs); !ok || bad != -1 || err != nil {
+		t.Fatalf("VerifyConsistencyBatch reads its weights from the EntropySource: %v\n", err)
+	}
+}
+
+func BenchmarkVerifyConsistencyBatch_100(b *testing.B) {
+	proofs, CMs, CMToks, PKs := makeConsistencyBatch(b, 100)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		VerifyConsistencyBatch(TestCurve, proofs, CMs, CMToks, PKs)
+	}
+}
+
+func BenchmarkVerifyConsistencyLoop_100(b *testing.B) {
+	proofs, CMs, CMToks, PKs := makeConsistencyBatch(b, 100)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		for jj := range proofs {
+			proofs[jj].Verify(TestCurve, CMs[jj], CMToks[jj], PKs[jj])
+		}
+	}
+}
+
+// abcProveInputs returns n commitments to values 1 to n with their tokens for sk
+func abcProveInputs(n int) ([]ECPoint, []ECPoint, []*big.Int, *big.Int) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	CMs := make([]ECPoint, n)
+	CMToks := make([]ECPoint, n)
+	values := make([]*big.Int, n)
+	for ii := range values {
+		values[ii] = big.NewInt(int64(ii + 1))
+		CM, u, _ := PedCommit(TestCurve, values[ii])
+		CMs[ii], CMToks[ii] = CM, TestCurve.Mult(PK, u)
+	}
+	return CMs, CMToks, values, sk
+}
+
+func benchmarkABCProve(b *testing.B, n int, batch bool) {
+	CMs, CMToks, values, sk := abcProveInputs(n)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		if batch {
+			NewABCProofBatch(TestCurve, CMs, CMToks, values, sk)
+		} else {
+			for jj := range values {
+				NewABCProof(TestCurve, CMs[jj], CMToks[jj], values[jj], sk, Right)
+			}
+		}
+	}
+}
+
+func BenchmarkNewABCProofBatch_10(b *testing.B)   { benchmarkABCProve(b, 10, true) }
+func BenchmarkNewABCProofBatch_1000(b *testing.B) { benchmarkABCProve(b, 1000, true) }
+func BenchmarkNewABCProofLoop_10(b *testing.B)    { benchmarkABCProve(b, 10, false) }
+func BenchmarkNewABCProofLoop_1000(b *testing.B)  { benchmarkABCProve(b, 1000, false) }
+
+// abcStatements returns n ABCStatements for one auditor key on zkpcp and
+// their witnesses, with values 0 to 3
+func abcStatements(zkpcp ZKPCurveParams, n int) ([]ABCStatement, []ABCWitness) {
+	sk, _ := rand.Int(rand.Reader, zkpcp.C.Params().N)
+	PK := zkpcp.Mult(zkpcp.H, sk)
+	statements := make([]ABCStatement, n)
+	witnesses := make([]ABCWitness, n)
+	for ii := range statements {
+		value := big.NewInt(int64(ii % 4))
+		CM, u, _ := PedCommit(zkpcp, value)
+		statements[ii] = ABCStatement{CM: CM, CMTok: zkpcp.Mult(PK, u), PubKey: PK}
+		witnesses[ii] = ABCWitness{Value: value, SK: sk}
+	}
+	return statements, witnesses
+}
+
+func TestProveAll(t *testing.T) {
+	statements, witnesses := abcStatements(TestCurve, 20)
+	for _, workers := range []int{0, 1, 3, 32} {
+		proofs, err := ProveAll(TestCurve, statements, witnesses, workers)
+		if err != nil {
+			t.Fatalf("ProveAll with %d workers failed: %v\n", workers, err)
+		}
+		if len(proofs) != len(statements) {
+			t.Fatalf("ProveAll with %d workers returned %d proofs\n", workers, len(proofs))
+		}
+		// every proof is in the place of its statement
+		for ii, p := range proofs {
+			if ok, err := p.VerifyStatement(TestCurve, statements[ii]); !ok || err != nil {
+				t.Fatalf("proof %d of ProveAll with %d workers failed to verify: %v\n", ii, workers, err)
+			}
+		}
+		if ok, err := VerifyAll(TestCurve, proofs, statements, workers); !ok || err != nil {
+			t.Fatalf("VerifyAll with %d workers failed: %v\n", workers, err)
+		}
+	}
+
+	if proofs, err := ProveAll(TestCurve, nil, nil, 0); err != nil || len(proofs) != 0 {
+		t.Fatalf("ProveAll of nothing gives %v, %v\n", proofs, err)
+	}
+	if ok, err := VerifyAll(TestCurve, nil, nil, 0); !ok || err != nil {
+		t.Fatalf("VerifyAll of nothing gives %v, %v\n", ok, err)
+	}
+}
+
+func TestProveAllErrors(t *testing.T) {
+	statements, witnesses := abcStatements(TestCurve, 16)
+	proofs, err := ProveAll(TestCurve, statements, witnesses, 4)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	// the error is the one of the first bad statement, whatever the workers
+	bad := append([]ABCWitness{}, witnesses...)
+	bad[12].SK = big.NewInt(0)
+	bad[7].SK = nil
+	for _, workers := range []int{1, 4, 16} {
+		if _, err := ProveAll(TestCurve, statements, bad, workers); !errors.Is(err, ErrValueOutOfRange) ||
+			!strings.Contains(err.Error(), "statement 7 failed") {
+			t.Fatalf("ProveAll with %d workers and bad statements 7 and 12 gives %v\n", workers, err)
+		}
+	}
+
+	swapped := append([]*ABCProof{}, proofs...)
+	swapped[5], swapped[9] = swapped[9], swapped[5]
+	for _, workers := range []int{1, 4, 16} {
+		if ok, err := VerifyAll(TestCurve, swapped, statements, workers); ok || !strings.Contains(err.Error(), "proof 5 failed") {
+			t.Fatalf("VerifyAll with %d workers and proofs 5 and 9 swapped gives %v\n", workers, err)
+		}
+	}
+
+	if _, err := ProveAll(TestCurve, statements, witnesses, -1); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("ProveAll with negative workers gives %v\n", err)
+	}
+	if _, err := ProveAll(TestCurve, statements, witnesses[1:], 0); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("ProveAll accepted slices of different lengths: %v\n", err)
+	}
+	if _, err := VerifyAll(TestCurve, proofs, statements, -1); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("VerifyAll with negative workers gives %v\n", err)
+	}
+	if _, err := VerifyAll(TestCurve, proofs[1:], statements, 0); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("VerifyAll accepted slices of different lengths: %v\n", err)
+	}
+}
+
+// TestProveAllConcurrent proves and verifies on more workers than proofs on a
+// fresh context, whose tables get built by whichever worker gets there first.
+// Run it with -race.
+func TestProveAllConcurrent(t *testing.T) {
+	before := runtime.NumGoroutine()
+	zkpcp := secp256k1Curve(t)
+	statements, witnesses := abcStatements(zkpcp, 12)
+	proofs, err := ProveAll(zkpcp, statements, witnesses, 16)
+	if err != nil {
+		t.Fatalf("ProveAll failed: %v\n", err)
+	}
+	if ok, err := VerifyAll(zkpcp, proofs, statements, 16); !ok || err != nil {
+		t.Fatalf("VerifyAll failed: %v\n", err)
+	}
+	checkGoroutines(t, before)
+}
+
+// benchmarkProveAll proves 64 statements on workers goroutines, which should
+// take about 1/workers of the time with 1 as long as there are enough cores
+func benchmarkProveAll(b *testing.B, workers int) {
+	statements, witnesses := abcStatements(TestCurve, 64)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		ProveAll(TestCurve, statements, witnesses, workers)
+	}
+}
+
+func BenchmarkProveAll_1(b *testing.B) { benchmarkProveAll(b, 1) }
+func BenchmarkProveAll_2(b *testing.B) { benchmarkProveAll(b, 2) }
+func BenchmarkProveAll_4(b *testing.B) { benchmarkProveAll(b, 4) }
+func BenchmarkProveAll_8(b *testing.B) { benchmarkProveAll(b, 8) }
+
+func benchmarkVerifyAll(b *testing.B, workers int) {
+	statements, witnesses := abcStatements(TestCurve, 64)
+	proofs, _ := ProveAll(TestCurve, statements, witnesses, 0)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		VerifyAll(TestCurve, proofs, statements, workers)
+	}
+}
+
+func BenchmarkVerifyAll_1(b *testing.B) { benchmarkVerifyAll(b, 1) }
+func BenchmarkVerifyAll_4(b *testing.B) { benchmarkVerifyAll(b, 4) }
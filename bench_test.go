@@ -0,0 +1,397 @@
+package zksigma
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// benchCase is a proof type at a realistic size, prove generates a new
+// proof that is valid for args
+type benchCase struct {
+	prove func() (Verifiable, error)
+	args  []interface{}
+}
+
+// benchCases returns a benchCase of every proof type, with 32 bit ranges and
+// 16 commitments where a proof takes a list of them. The sum of 16 values of
+// makeSum takes 40 bits. MultiProductProof is for MaxMultiProductFactors.
+func benchCases(tb testing.TB) map[string]benchCase {
+	sk := scalarFromSeed("bench sk")
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(1234567890)
+	CM, r, _ := PedCommit(TestCurve, value)
+	CMTok := TestCurve.Mult(PK, r)
+	CM2, r2, _ := PedCommit(TestCurve, big.NewInt(987654321))
+	x := scalarFromSeed("bench x")
+	A := TestCurve.Mult(TestCurve.G, x)
+	AH := TestCurve.Mult(TestCurve.H, x)
+	CMPrime, deltaR, _ := RerandomizeCommitment(TestCurve, CM)
+	newSK := scalarFromSeed("bench new sk")
+	newPK := TestCurve.Mult(TestCurve.H, newSK)
+	newCMTok, _ := RotateToken(TestCurve, CMTok, sk, newSK)
+	CMTok2 := TestCurve.Mult(newPK, r)
+	sameCM, sameR, _ := PedCommit(TestCurve, value)
+	V := TestCurve.Mult(TestCurve.G, value)
+	rc := scalarFromSeed("bench rc")
+	PA := PedCommitR(TestCurve, big.NewInt(3), r)
+	PB := PedCommitR(TestCurve, big.NewInt(4), r2)
+	PC := PedCommitR(TestCurve, big.NewInt(12), rc)
+	factors := make([]*big.Int, MaxMultiProductFactors)
+	factorRs := make([]*big.Int, 2*MaxMultiProductFactors-1)
+	for i := range factorRs {
+		if i < len(factors) {
+			factors[i] = big.NewInt(int64(i + 2))
+		}
+		factorRs[i] = scalarFromSeed(fmt.Sprintf("bench factor r %d", i))
+	}
+	factorCMs, _, _ := NewMultiProductProof(TestCurve, factors, factorRs)
+
+	CMs, values, rs, total := makeSum(tb, 16)
+	perm := []int{3, 14, 0, 9, 7, 12, 1, 5, 15, 2, 10, 6, 13, 8, 4, 11}
+	inputs, outputs, deltaRs := shuffled(tb, TestCurve, perm)
+	set := make([]*big.Int, 16)
+	for i := range set {
+		set[i] = big.NewInt(int64(1000 * (i + 1)))
+	}
+	setCM, setR, _ := PedCommit(TestCurve, set[9])
+	bases, results := denominations(TestCurve, setCM, set)
+	lhs, lhsR, _ := PedCommit(TestCurve, big.NewInt(100))
+	out1, outR1, _ := PedCommit(TestCurve, big.NewInt(60))
+	out2, outR2, _ := PedCommit(TestCurve, big.NewInt(40))
+	vec := make([]*big.Int, 64)
+	for i := range vec {
+		vec[i] = big.NewInt(int64(i))
+	}
+	vecCM, _ := VectorPedCommitR(TestCurve, vec, r)
+	_, rangeR, _ := NewRangeProofWithRand(TestCurve, newSeededReader("bench range"), big.NewInt(42))
+	abcStmt := ABCStatement{CM: CM, CMTok: CMTok, PubKey: PK}
+	gsStmt := GSPFSStatement{Base: TestCurve.G, A: A}
+
+	return map[string]benchCase{
+		"ABCProof": {func() (Verifiable, error) {
+			return NewABCProof(TestCurve, CM, CMTok, value, sk, Right)
+		}, []interface{}{CM, CMTok, PK}},
+		"AggregateRangeProof": {func() (Verifiable, error) {
+			return NewAggregateRangeProof(TestCurve, CMs, values, rs, 40)
+		}, []interface{}{CMs, 40}},
+		"BalanceProof": {func() (Verifiable, error) {
+			return NewBalanceProof(TestCurve, lhs, []ECPoint{out1, out2}, []int{1, 1}, lhsR, []*big.Int{outR1, outR2})
+		}, []interface{}{lhs, []ECPoint{out1, out2}, []int{1, 1}}},
+		"BitProof": {func() (Verifiable, error) {
+			p, _, err := NewBitProof(TestCurve, big.NewInt(1), r)
+			return p, err
+		}, []interface{}{PedCommitR(TestCurve, big.NewInt(1), r)}},
+		"BitRangeProof": {func() (Verifiable, error) {
+			return NewBitRangeProof(TestCurve, CM, value, r, 32)
+		}, []interface{}{CM, 32}},
+		"ComparisonProof": {func() (Verifiable, error) {
+			return NewComparisonProof(TestCurve, CM, CM2, value, big.NewInt(987654321), r, r2, 32)
+		}, []interface{}{CM, CM2, 32}},
+		"BoundedRangeProof": {func() (Verifiable, error) {
+			return NewBoundedRangeProof(TestCurve, CM, value, r, big.NewInt(1000), big.NewInt(1<<32-1))
+		}, []interface{}{CM, big.NewInt(1000), big.NewInt(1<<32 - 1)}},
+		"ComposedProof": {func() (Verifiable, error) {
+			return NewComposedProof(TestCurve,
+				abcStmt.WithWitness(ABCWitness{Value: value, SK: sk}, Right), gsStmt.WithWitness(GSPFSWitness{x}))
+		}, []interface{}{abcStmt, gsStmt}},
+		"ConsistencyProof": {func() (Verifiable, error) {
+			return NewConsistencyProof(TestCurve, CM, CMTok, PK, value, r)
+		}, []interface{}{CM, CMTok, PK}},
+		"DecryptionProof": {func() (Verifiable, error) {
+			p, _, err := NewDecryptionProof(TestCurve, CM, CMTok, sk)
+			return p, err
+		}, []interface{}{CM, CMTok, PK, V}},
+		"DisjunctiveProof": {func() (Verifiable, error) {
+			return NewDisjunctiveProof(TestCurve, TestCurve.G, A, TestCurve.H, AH, x, Left)
+		}, []interface{}{TestCurve.G, A, TestCurve.H, AH}},
+		"EquivOrZeroProof": {func() (Verifiable, error) {
+			return NewEquivOrZeroProof(TestCurve, CM, sameCM, r, sameR, Right)
+		}, []interface{}{CM, sameCM}},
+		"EquivalenceProof": {func() (Verifiable, error) {
+			return NewEquivalenceProof(TestCurve, TestCurve.G, A, TestCurve.H, AH, x)
+		}, []interface{}{TestCurve.G, A, TestCurve.H, AH}},
+		"GSPFSProof": {func() (Verifiable, error) {
+			return NewGSPFSProof(TestCurve, A, x)
+		}, []interface{}{A}},
+		"InequalityProof": {func() (Verifiable, error) {
+			return NewInequalityProof(TestCurve, CM, CM2, value, big.NewInt(987654321), r, r2)
+		}, []interface{}{CM, CM2}},
+		"KeyRotationProof": {func() (Verifiable, error) {
+			return NewKeyRotationProof(TestCurve, CMTok, newCMTok, PK, newPK, sk, newSK)
+		}, []interface{}{CMTok, newCMTok, PK, newPK}},
+		"NonZeroProof": {func() (Verifiable, error) {
+			return NewNonZeroProof(TestCurve, CM, value, r)
+		}, []interface{}{CM}},
+		"OneOfManyProof": {func() (Verifiable, error) {
+			return NewOneOfManyProof(TestCurve, bases, results, 9, setR)
+		}, []interface{}{bases, results}},
+		"OpenProof": {func() (Verifiable, error) {
+			return NewOpenProof(TestCurve, CM, value, r)
+		}, []interface{}{CM}},
+		"OpenToValueProof": {func() (Verifiable, error) {
+			return NewOpenToValueProof(TestCurve, CM, value, r)
+		}, []interface{}{CM, value}},
+		"ProductProof": {func() (Verifiable, error) {
+			p, _, _, _, err := NewProductProof(TestCurve, big.NewInt(3), big.NewInt(4), r, r2, rc)
+			return p, err
+		}, []interface{}{PA, PB, PC}},
+		"MultiProductProof": {func() (Verifiable, error) {
+			_, p, err := NewMultiProductProof(TestCurve, factors, factorRs)
+			return p, err
+		}, []interface{}{factorCMs[:MaxMultiProductFactors], factorCMs[len(factorCMs)-1]}},
+		"MultiRangeProof": {func() (Verifiable, error) {
+			return NewMultiRangeProof(TestCurve, CMs, values, rs, 40)
+		}, []interface{}{CMs, 40}},
+		"RangeProof": {func() (Verifiable, error) {
+			// the same random values every time, for the commitment of the args
+			p, _, err := NewRangeProofWithRand(TestCurve, newSeededReader("bench range"), big.NewInt(42))
+			return p, err
+		}, []interface{}{PedCommitR(TestCurve, big.NewInt(42), rangeR)}},
+		"RerandomizationProof": {func() (Verifiable, error) {
+			return NewRerandomizationProof(TestCurve, CM, CMPrime, deltaR)
+		}, []interface{}{CM, CMPrime}},
+		"SameBlindingProof": {func() (Verifiable, error) {
+			return NewSameBlindingProof(TestCurve, CMTok, CMTok2, PK, newPK, r)
+		}, []interface{}{CMTok, CMTok2, PK, newPK}},
+		"SameValueProof": {func() (Verifiable, error) {
+			return NewSameValueProof(TestCurve, CM, sameCM, value, r, sameR)
+		}, []interface{}{CM, sameCM}},
+		"SetMembershipProof": {func() (Verifiable, error) {
+			return NewSetMembershipProof(TestCurve, setCM, set[9], setR, set)
+		}, []interface{}{setCM, set}},
+		"ShuffleProof": {func() (Verifiable, error) {
+			return NewShuffleProof(TestCurve, inputs, outputs, perm, deltaRs)
+		}, []interface{}{inputs, outputs}},
+		"SumProof": {func() (Verifiable, error) {
+			return NewSumProof(TestCurve, CMs, values, rs, total)
+		}, []interface{}{CMs, total}},
+		"VectorOpenProof": {func() (Verifiable, error) {
+			return NewVectorOpenProof(TestCurve, vecCM, vec, r)
+		}, []interface{}{vecCM}},
+	}
+}
+
+// benchOps returns the operations of the benchmark suite by name, the
+// primitives and New and Verify of every benchCase, e.g. "ABCProof.Verify".
+// Each call of an operation runs it once.
+func benchOps(tb testing.TB) map[string]func() {
+	value := scalarFromSeed("bench value")
+	randVal := scalarFromSeed("bench randomness")
+	P := TestCurve.Mult(TestCurve.H, scalarFromSeed("bench point"))
+	Q := TestCurve.Mult(TestCurve.G, scalarFromSeed("bench other point"))
+	arr := make([][]byte, 8)
+	for i := range arr {
+		arr[i] = TestCurve.Mult(P, big.NewInt(int64(i+1))).Bytes()
+	}
+
+	ops := map[string]func(){
+		"PedCommit":         func() { PedCommit(TestCurve, value) },
+		"PedCommitR":        func() { PedCommitR(TestCurve, value, randVal) },
+		"ECPoint.Add":       func() { TestCurve.Add(P, Q) },
+		"ECPoint.Mult":      func() { TestCurve.Mult(P, value) },
+		"GenerateChallenge": func() { GenerateChallenge(TestCurve, arr...) },
+	}
+	for name, c := range benchCases(tb) {
+		c := c
+		proof, err := c.prove()
+		if err != nil {
+			tb.Fatalf("%s failed to generate: %v\n", name, err)
+		}
+		if ok, err := proof.VerifyArgs(TestCurve, c.args...); !ok || err != nil {
+			tb.Fatalf("%s failed to verify: %v\n", name, err)
+		}
+		ops[name+".New"] = func() { c.prove() }
+		ops[name+".Verify"] = func() { proof.VerifyArgs(TestCurve, c.args...) }
+	}
+	return ops
+}
+
+// sortedOps returns the names of ops in order
+func sortedOps(ops map[string]func()) []string {
+	names := make([]string, 0, len(ops))
+	for name := range ops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BenchmarkSuite runs every operation of benchOps as a sub-benchmark, e.g.
+// go test -bench 'Suite/ABCProof' for the ABCProof ones
+func BenchmarkSuite(b *testing.B) {
+	ops := benchOps(b)
+	for _, name := range sortedOps(ops) {
+		op := ops[name]
+		b.Run(name, func(b *testing.B) {
+			for ii := 0; ii < b.N; ii++ {
+				op()
+			}
+		})
+	}
+}
+
+// perfReference is what the times of a perfBudget are measured in: a raw
+// scalar multiplication of the curve, without the tables and backends of
+// ZKPCurveParams.Mult, so the budget holds for machines of any speed
+func perfReference() func() {
+	P := TestCurve.Mult(TestCurve.H, scalarFromSeed("bench point"))
+	k := scalarFromSeed("bench value").Bytes()
+	return func() { TestCurve.C.ScalarMult(P.X, P.Y, k) }
+}
+
+// perfBudget is the content of testdata/perfbudget.json. The Baseline of an
+// operation is its time in units of perfReference, an operation is over
+// budget if it takes more than Tolerance times that.
+type perfBudget struct {
+	Tolerance float64            `json:"tolerance"`
+	Baseline  map[string]float64 `json:"baseline"`
+}
+
+// perfBudgetFile is the budget TestPerfBudget checks against
+var perfBudgetFile = filepath.Join("testdata", "perfbudget.json")
+
+// perfUpdate makes TestPerfBudget rewrite the Baseline of perfBudgetFile from
+// this machine instead of checking it
+var perfUpdate = flag.Bool("update", false, "rewrite the baseline of testdata/perfbudget.json")
+
+// perfEnv turns TestPerfBudget on, timings are too noisy for every test run
+const perfEnv = "ZKSIGMA_PERF"
+
+const (
+	perfRuns    = 3                     // runs of an operation, the fastest counts
+	perfRunTime = 50 * time.Millisecond // minimum time of a run
+)
+
+// perfMeasure returns the time of one call of op in ns, the fastest of
+// perfRuns runs of at least perfRunTime each
+func perfMeasure(op func()) float64 {
+	best := math.Inf(1)
+	for run := 0; run < perfRuns; run++ {
+		n := 0
+		start := time.Now()
+		for time.Since(start) < perfRunTime {
+			op()
+			n++
+		}
+		if ns := float64(time.Since(start).Nanoseconds()) / float64(n); ns < best {
+			best = ns
+		}
+	}
+	return best
+}
+
+// over returns the names of the operations in measured that take more than
+// Tolerance times their Baseline, in order. measured is in units of
+// perfReference like the Baseline, operations without one are never over.
+func (pb *perfBudget) over(measured map[string]float64) []string {
+	var names []string
+	for name, t := range measured {
+		if base, ok := pb.Baseline[name]; ok && t > pb.Tolerance*base {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readPerfBudget reads perfBudgetFile
+func readPerfBudget(t *testing.T) *perfBudget {
+	in, err := ioutil.ReadFile(perfBudgetFile)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	pb := new(perfBudget)
+	if err := json.Unmarshal(in, pb); err != nil {
+		t.Fatalf("%s: %v\n", perfBudgetFile, err)
+	}
+	return pb
+}
+
+// TestPerfBudget fails if an operation of benchOps got slower than the budget
+// of testdata/perfbudget.json allows. It only runs with ZKSIGMA_PERF set and
+// without -short, ZKSIGMA_PERF=1 go test -run PerfBudget -update rewrites the
+// baseline.
+func TestPerfBudget(t *testing.T) {
+	if testing.Short() || os.Getenv(perfEnv) == "" {
+		t.Skipf("set %s to check %s\n", perfEnv, perfBudgetFile)
+	}
+	pb := readPerfBudget(t)
+	ops := benchOps(t)
+
+	ref := perfMeasure(perfReference())
+	measured := make(map[string]float64, len(ops))
+	for _, name := range sortedOps(ops) {
+		if _, ok := pb.Baseline[name]; ok || *perfUpdate {
+			measured[name] = perfMeasure(ops[name]) / ref
+		}
+	}
+	t.Logf("reference scalar multiplication: %.0f ns\n", ref)
+
+	if *perfUpdate {
+		for name, m := range measured {
+			measured[name] = math.Round(m*10000) / 10000
+		}
+		pb.Baseline = measured
+		out, err := json.MarshalIndent(pb, "", "  ")
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if err := ioutil.WriteFile(perfBudgetFile, append(out, '\n'), 0644); err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		return
+	}
+
+	for _, name := range pb.over(measured) {
+		t.Errorf("%s takes %.2f scalar multiplications, its baseline is %.2f and the budget %.2f\n",
+			name, measured[name], pb.Baseline[name], pb.Tolerance*pb.Baseline[name])
+	}
+}
+
+func TestPerfBudgetFile(t *testing.T) {
+	pb := readPerfBudget(t)
+	if pb.Tolerance < 1 {
+		t.Fatalf("%s has tolerance %v, which fails the baseline itself\n", perfBudgetFile, pb.Tolerance)
+	}
+	// the baseline has to cover the suite and nothing else, so no operation
+	// goes unchecked or is checked under an old name
+	ops := benchOps(t)
+	for name, base := range pb.Baseline {
+		if _, ok := ops[name]; !ok {
+			t.Fatalf("%s has a baseline for %s, which is not in the suite\n", perfBudgetFile, name)
+		}
+		if base <= 0 {
+			t.Fatalf("%s has a baseline of %v for %s\n", perfBudgetFile, base, name)
+		}
+	}
+	for name := range ops {
+		if _, ok := pb.Baseline[name]; !ok {
+			t.Fatalf("%s has no baseline for %s, run ZKSIGMA_PERF=1 go test -run PerfBudget -update\n", perfBudgetFile, name)
+		}
+	}
+}
+
+func TestPerfBudgetOver(t *testing.T) {
+	pb := &perfBudget{Tolerance: 1.5, Baseline: map[string]float64{"A": 2, "B": 10, "C": 0.5}}
+	measured := map[string]float64{
+		"A": 3,   // at the budget
+		"B": 16,  // over
+		"C": 0.9, // over
+		"D": 100, // no baseline
+	}
+	if over := pb.over(measured); len(over) != 2 || over[0] != "B" || over[1] != "C" {
+		t.Fatalf("over budget are %v, want [B C]\n", over)
+	}
+	if over := pb.over(map[string]float64{"A": 1, "B": 1}); len(over) != 0 {
+		t.Fatalf("over budget are %v for faster operations\n", over)
+	}
+}
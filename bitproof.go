@@ -0,0 +1,141 @@
+package zksigma
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// BitProof is a proof that a Pedersen commitment C = bG + rH commits to a bit
+// b, 0 or 1. It is the DisjunctiveProof that C or C - G is a multiple of H,
+// whose challenge hashes C and C - G, and BitRangeProof has one for every bit
+// of its value.
+//
+//  Public: generator points G and H, C
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows b in {0, 1} and r with
+//  C = bG + rH
+//  DisjunctiveProof of r with
+//  rH = C (Left) for b = 0 or
+//  rH = C - G (Right) for b = 1
+//
+//  T1, T2, c, c1, c2, s1, s2 -------->
+//                                      DisjunctiveProof.Verify(H, C, H, C - G)
+type BitProof DisjunctiveProof
+
+// NewBitProof commits to value with randomness and generates a proof that the
+// commitment hides 0 or 1. It returns the proof and the commitment, and fails
+// for any other value.
+func NewBitProof(zkpcp ZKPCurveParams, value, randomness *big.Int) (*BitProof, ECPoint, error) {
+	return NewBitProofWithRand(zkpcp, entropy(), value, randomness)
+}
+
+// NewBitProofWithRand is the same as NewBitProof, but reads its random values
+// from rnd instead of the EntropySource
+func NewBitProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader, value, randomness *big.Int) (*BitProof, ECPoint, error) {
+	if err := zkpcp.checkScalars("BitProve", "value, randomness", value, randomness); err != nil {
+		return nil, Zero, err
+	}
+	if value.Cmp(big.NewInt(1)) > 0 {
+		return nil, Zero, inputError("BitProve", "value is not 0 or 1")
+	}
+	C := PedCommitR(zkpcp, value, randomness)
+	proof, err := newBitProof(zkpcp, rnd, C, value.Bit(0), randomness)
+	if err != nil {
+		return nil, Zero, err
+	}
+	return proof, C, nil
+}
+
+// newBitProof generates the BitProof of C = bit * G + randomness * H
+func newBitProof(zkpcp ZKPCurveParams, rnd io.Reader, C ECPoint, bit uint, randomness *big.Int) (*BitProof, error) {
+	side := Left
+	if bit == 1 {
+		side = Right
+	}
+	dj, err := NewDisjunctiveProofWithRand(zkpcp, rnd, zkpcp.H, C, zkpcp.H, zkpcp.Sub(C, zkpcp.G), randomness, side)
+	return (*BitProof)(dj), err
+}
+
+// Verify checks if BitProof proof shows that C commits to 0 or 1
+func (proof *BitProof) Verify(zkpcp ZKPCurveParams, C ECPoint) (bool, error) {
+	if proof == nil {
+		return false, malformedError("BitProof.Verify", "passed proof is nil")
+	}
+	if !zkpcp.validPoints(C) {
+		return false, curveError("BitProof.Verify")
+	}
+	if _, err := (*DisjunctiveProof)(proof).Verify(zkpcp, zkpcp.H, C, zkpcp.H, zkpcp.Sub(C, zkpcp.G)); err != nil {
+		return false, wrapError("BitProof.Verify", fmt.Sprintf("C is not shown to commit to 0 or 1: %v", err), err)
+	}
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, the only arg is C like for Verify
+func (proof *BitProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("BitProof.VerifyArgs", args)
+	C := a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, C)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *BitProof) String() string {
+	if proof == nil {
+		return "BitProof(nil)"
+	}
+	return proofString("BitProof", "T1", proof.T1, "T2", proof.T2, "C", proof.C,
+		"C1", proof.C1, "C2", proof.C2, "S1", proof.S1, "S2", proof.S2)
+}
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// BitProof proof, the one of its DisjunctiveProof
+func (proof *BitProof) Bytes() []byte {
+	return (*DisjunctiveProof)(proof).Bytes()
+}
+
+// Size returns the length of Bytes of BitProof proof, which has a fixed size
+func (proof *BitProof) Size() int {
+	return disjunctiveProofLen
+}
+
+// Clone returns a deep copy of BitProof proof that shares no big.Int with it
+func (proof *BitProof) Clone() *BitProof {
+	return (*BitProof)((*DisjunctiveProof)(proof).Clone())
+}
+
+// Equal returns true if BitProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *BitProof) Equal(other *BitProof) bool {
+	return (*DisjunctiveProof)(proof).Equal((*DisjunctiveProof)(other))
+}
+
+// NewBitProofFromBytes returns a BitProof generated from the deserialization
+// of byte slice b
+func NewBitProofFromBytes(b []byte) (*BitProof, error) {
+	if err := checkFixedHeader(b, disjunctiveProofLen, "BitProofFromBytes"); err != nil {
+		return nil, err
+	}
+	dj, err := NewDisjunctiveProofFromBytes(b)
+	if err != nil {
+		return nil, wrapError("BitProofFromBytes", err.Error(), err)
+	}
+	return (*BitProof)(dj), nil
+}
+
+// MarshalJSON encodes BitProof proof like its DisjunctiveProof, with points
+// as compressed hex and scalars as big-endian hex
+func (proof *BitProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal((*DisjunctiveProof)(proof))
+}
+
+// UnmarshalJSON decodes a BitProof encoded with MarshalJSON. It fails if a
+// point is not on the curve or a scalar is not in [0, N)
+func (proof *BitProof) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, (*DisjunctiveProof)(proof))
+}
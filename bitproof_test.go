@@ -0,0 +1,106 @@
+package zksigma
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestBitProof(t *testing.T) {
+	r := scalarFromSeed("bit r")
+	for _, bit := range []int64{0, 1} {
+		proof, C, err := NewBitProof(TestCurve, big.NewInt(bit), r)
+		if err != nil {
+			t.Fatalf("NewBitProof failed for %d: %v\n", bit, err)
+		}
+		if !C.Equal(PedCommitR(TestCurve, big.NewInt(bit), r)) {
+			t.Fatalf("NewBitProof for %d returns another commitment\n", bit)
+		}
+		if ok, err := proof.Verify(TestCurve, C); !ok || err != nil {
+			t.Fatalf("BitProof of %d did not verify: %v\n", bit, err)
+		}
+
+		decoded, err := NewBitProofFromBytes(proof.Bytes())
+		if err != nil {
+			t.Fatalf("BitProof of %d failed to decode: %v\n", bit, err)
+		}
+		if !decoded.Equal(proof) || proof.Size() != len(proof.Bytes()) {
+			t.Fatalf("BitProof of %d does not round-trip\n", bit)
+		}
+		js, err := json.Marshal(proof)
+		if err != nil {
+			t.Fatalf("BitProof of %d failed to marshal: %v\n", bit, err)
+		}
+		decoded = new(BitProof)
+		if err := json.Unmarshal(js, decoded); err != nil {
+			t.Fatalf("BitProof of %d failed to unmarshal: %v\n", bit, err)
+		}
+		if ok, err := decoded.Verify(TestCurve, C); !ok || err != nil {
+			t.Fatalf("BitProof of %d did not verify after JSON round trip: %v\n", bit, err)
+		}
+
+		// the challenge hashes C, so the proof is for C only
+		for _, other := range []ECPoint{TestCurve.Add(C, TestCurve.G), TestCurve.Sub(C, TestCurve.G), TestCurve.Add(C, TestCurve.H)} {
+			if ok, err := proof.Verify(TestCurve, other); ok || !errors.Is(err, ErrChallengeMismatch) {
+				t.Fatalf("BitProof of %d verified for another commitment: %v\n", bit, err)
+			}
+		}
+	}
+
+	// the BitProofs of a BitRangeProof are BitProofs of its bit commitments
+	br, err := NewBitRangeProof(TestCurve, PedCommitR(TestCurve, big.NewInt(5), r), big.NewInt(5), r, 4)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	for i, bp := range br.BitProofs {
+		if ok, err := (*BitProof)(bp).Verify(TestCurve, br.BitCommits[i]); !ok || err != nil {
+			t.Fatalf("proof of bit %d of a BitRangeProof is not a BitProof: %v\n", i, err)
+		}
+	}
+}
+
+func TestBitProofErrors(t *testing.T) {
+	N := TestCurve.C.Params().N
+	r := scalarFromSeed("bit r")
+	cases := []struct {
+		name  string
+		value *big.Int
+		r     *big.Int
+		err   error
+	}{
+		{"2", big.NewInt(2), r, ErrInvalidInput},
+		{"N - 1", new(big.Int).Sub(N, big.NewInt(1)), r, ErrInvalidInput},
+		{"-1", big.NewInt(-1), r, ErrValueOutOfRange},
+		{"N", N, r, ErrValueOutOfRange},
+		{"nil randomness", big.NewInt(1), nil, ErrValueOutOfRange},
+	}
+	for _, c := range cases {
+		if p, C, err := NewBitProof(TestCurve, c.value, c.r); p != nil || !C.Equal(Zero) || !errors.Is(err, c.err) {
+			t.Fatalf("NewBitProof of %s gives %v\n", c.name, err)
+		}
+	}
+
+	proof, C, err := NewBitProof(TestCurve, big.NewInt(0), r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	var nilProof *BitProof
+	if _, err := nilProof.Verify(TestCurve, C); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("Verify of a nil BitProof gives %v\n", err)
+	}
+	if _, err := proof.Verify(TestCurve, ECPoint{big.NewInt(1), big.NewInt(1)}); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("Verify for a C not on the curve gives %v\n", err)
+	}
+	var pe *ProofError
+	if _, err := proof.Verify(TestCurve, TestCurve.Add(C, TestCurve.G)); !errors.As(err, &pe) || pe.Type != "BitProof.Verify" {
+		t.Fatalf("Verify of a BitProof for another C gives %v\n", err)
+	}
+
+	b := proof.Bytes()
+	for _, bad := range [][]byte{nil, b[:len(b)-1], append(b[:len(b):len(b)], 0), {proofEncodingVersion + 1}} {
+		if _, err := NewBitProofFromBytes(bad); !errors.Is(err, ErrMalformedProof) {
+			t.Fatalf("NewBitProofFromBytes of %d bytes gives %v\n", len(bad), err)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/mit-dci/zksigma/wire"
+)
+
+// BitRangeProof is a proof that a Pedersen commitment CM = vG + rH commits to
+// a value v in [0, 2^bits). Unlike RangeProof it is built from a BitProof
+// for every bit of v and works for any number of bits.
+//
+//  Public: generator points G and H, CM, bits
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows v, r with CM = vG + rH
+//  decompose v = sum(2^i * b_i)
+//  select random r_i for i < bits-1 and
+//  pick r_(bits-1) so sum(2^i * r_i) = r
+//  C_i = b_iG + r_iH
+//  D_i = BitProof, a DisjunctiveProof that
+//        C_i = r_iH or C_i - G = r_iH
+//
+//  C_i, D_i ------------------------->
+//                                      len(C) ?= bits
+//                                      D_i ?= true for every i
+//                                      sum(2^i * C_i) ?= CM
+type BitRangeProof struct {
+	BitCommits []ECPoint           // C_i = b_iG + r_iH
+	BitProofs  []*DisjunctiveProof // the BitProof that C_i commits to 0 or 1
+}
+
+// checkRangeBits makes sure that a sum of bits commitments can not wrap
+// around the order of the curve
+func checkRangeBits(zkpcp ZKPCurveParams, bits int, t string) error {
+	if bits < 1 || bits >= zkpcp.C.Params().N.BitLen()-1 {
+		return inputError(t, fmt.Sprintf("invalid number of bits %d", bits))
+	}
+	return nil
+}
+
+// bitRandomness returns the randomness r_i of the commitments to the bits of a
+// value with randomness r, random for i < bits-1 and r_(bits-1) picked so that
+// sum(2^i * r_i) = r
+func (zkpcp ZKPCurveParams) bitRandomness(rnd io.Reader, randomness *big.Int, bits int) ([]*big.Int, error) {
+	N := zkpcp.C.Params().N
+
+	// r_(bits-1) = (r - sum(2^i * r_i)) / 2^(bits-1)
+	rs, err := zkpcp.randScalars(rnd, bits-1)
+	if err != nil {
+		return nil, err
+	}
+	rest := new(big.Int).Mod(randomness, N)
+	for i, r := range rs {
+		rest.Sub(rest, new(big.Int).Lsh(r, uint(i)))
+	}
+	rs = append(rs, nil)
+	top := new(big.Int).ModInverse(new(big.Int).Lsh(big.NewInt(1), uint(bits-1)), N)
+	rs[bits-1] = rest.Mul(rest, top)
+	rs[bits-1].Mod(rs[bits-1], N)
+	return rs, nil
+}
+
+// NewBitRangeProof generates a proof that CM = value*G + randomness*H commits
+// to a value in [0, 2^bits). It fails if value is negative or not below 2^bits.
+func NewBitRangeProof(zkpcp ZKPCurveParams, CM ECPoint, value, randomness *big.Int, bits int) (*BitRangeProof, error) {
+	return NewBitRangeProofWithRand(zkpcp, entropy(), CM, value, randomness, bits)
+}
+
+// NewBitRangeProofWithRand is the same as NewBitRangeProof, but reads its random
+// values from rnd instead of the EntropySource
+func NewBitRangeProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader, CM ECPoint, value, randomness *big.Int, bits int) (*BitRangeProof, error) {
+	if err := checkRangeBits(zkpcp, bits, "BitRangeProve"); err != nil {
+		return nil, err
+	}
+	if err := zkpcp.checkScalars("BitRangeProve", "value, randomness", value, randomness); err != nil {
+		return nil, err
+	}
+	if value.Sign() < 0 || value.BitLen() > bits {
+		return nil, inputError("BitRangeProve", fmt.Sprintf("value is not in [0, 2^%d)", bits))
+	}
+	if !CM.Equal(PedCommitR(zkpcp, value, randomness)) {
+		return nil, inputError("BitRangeProve", "value and randomness do not produce CM")
+	}
+
+	proof := &BitRangeProof{
+		BitCommits: make([]ECPoint, bits),
+		BitProofs:  make([]*DisjunctiveProof, bits),
+	}
+
+	rs, err := zkpcp.bitRandomness(rnd, randomness, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < bits; i++ {
+		bit := value.Bit(i)
+		proof.BitCommits[i] = PedCommitR(zkpcp, big.NewInt(int64(bit)), rs[i])
+		bp, err := newBitProof(zkpcp, rnd, proof.BitCommits[i], bit, rs[i])
+		if err != nil {
+			return nil, err
+		}
+		proof.BitProofs[i] = (*DisjunctiveProof)(bp)
+	}
+
+	return proof, nil
+}
+
+// Verify checks if BitRangeProof proof shows that CM commits to a value in
+// [0, 2^bits). A proof generated for a different number of bits is rejected.
+func (proof *BitRangeProof) Verify(zkpcp ZKPCurveParams, CM ECPoint, bits int) (bool, error) {
+	if proof == nil {
+		return false, malformedError("BitRangeProof.Verify", "passed proof is nil")
+	}
+
+	if !zkpcp.validPoints(CM) || !zkpcp.onCurve(proof.BitCommits...) {
+		return false, curveError("BitRangeProof.Verify")
+	}
+	if err := checkRangeBits(zkpcp, bits, "BitRangeProof.Verify"); err != nil {
+		return false, err
+	}
+	if len(proof.BitCommits) != bits || len(proof.BitProofs) != bits {
+		return false, malformedError("BitRangeProof.Verify",
+			fmt.Sprintf("proof has %d bits, expected %d", len(proof.BitCommits), bits))
+	}
+
+	total := Zero
+	for i := 0; i < bits; i++ {
+		C := proof.BitCommits[i]
+		if _, err := (*BitProof)(proof.BitProofs[i]).Verify(zkpcp, C); err != nil {
+			return false, subProofError("BitRangeProof.Verify", fmt.Sprintf("bit %d is not 0 or 1", i), err)
+		}
+		total = zkpcp.Add(total, zkpcp.Mult(C, new(big.Int).Lsh(big.NewInt(1), uint(i))))
+	}
+
+	if !pointsEqual(total, CM) {
+		return false, equationError("BitRangeProof.Verify", 1, "sum(2^i * C_i) != CM", total, CM)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are CM and bits as int like for Verify
+func (proof *BitRangeProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("BitRangeProof.VerifyArgs", args)
+	CM, bits := a.point(), a.int()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CM, bits)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *BitRangeProof) String() string {
+	if proof == nil {
+		return "BitRangeProof(nil)"
+	}
+	var fields []interface{}
+	for i, C := range proof.BitCommits {
+		fields = append(fields, fmt.Sprintf("BitCommits[%d]", i), C)
+	}
+	for i, dj := range proof.BitProofs {
+		fields = append(fields, fmt.Sprintf("BitProofs[%d]", i), dj)
+	}
+	return proofString("BitRangeProof", fields...)
+}
+
+// Bytes returns a byte slice with a serialized representation of BitRangeProof proof
+func (proof *BitRangeProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	wire.WriteVarInt(&buf, uint64(len(proof.BitCommits)))
+	for i := range proof.BitCommits {
+		writeFixedPoint(&buf, proof.BitCommits[i])
+		buf.Write(proof.BitProofs[i].Bytes())
+	}
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of BitRangeProof proof without serializing it
+func (proof *BitRangeProof) Size() int {
+	n := len(proof.BitCommits)
+	return wire.VarIntSerializeSize(uint64(n)) + n*(fixedPointLen+disjunctiveProofLen)
+}
+
+// Clone returns a deep copy of BitRangeProof proof that shares no big.Int with it
+func (proof *BitRangeProof) Clone() *BitRangeProof {
+	if proof == nil {
+		return nil
+	}
+	c := &BitRangeProof{
+		BitCommits: clonePoints(proof.BitCommits),
+	}
+	if proof.BitProofs != nil {
+		c.BitProofs = make([]*DisjunctiveProof, len(proof.BitProofs))
+		for i, p := range proof.BitProofs {
+			c.BitProofs[i] = p.Clone()
+		}
+	}
+	return c
+}
+
+// Equal returns true if BitRangeProof proof and other have the same values in all
+// their fields, nested proofs included. It is not constant time.
+func (proof *BitRangeProof) Equal(other *BitRangeProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	if !samePoints(proof.BitCommits, other.BitCommits) || len(proof.BitProofs) != len(other.BitProofs) {
+		return false
+	}
+	for i, p := range proof.BitProofs {
+		if !p.Equal(other.BitProofs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewBitRangeProofFromBytes returns a BitRangeProof generated from the
+// deserialization of byte slice b
+func NewBitRangeProofFromBytes(b []byte) (*BitRangeProof, error) {
+	buf := bytes.NewBuffer(b)
+	bits, err := wire.ReadVarInt(buf)
+	if err != nil {
+		return nil, err
+	}
+	if bits*uint64(fixedPointLen+disjunctiveProofLen) != uint64(buf.Len()) {
+		return nil, malformedError("BitRangeProofFromBytes", "buffer length does not match number of bits")
+	}
+
+	proof := &BitRangeProof{
+		BitCommits: make([]ECPoint, bits),
+		BitProofs:  make([]*DisjunctiveProof, bits),
+	}
+	for i := range proof.BitCommits {
+		if proof.BitCommits[i], err = readFixedPoint(buf, "BitRangeProofFromBytes"); err != nil {
+			return nil, err
+		}
+		if proof.BitProofs[i], err = NewDisjunctiveProofFromBytes(buf.Next(disjunctiveProofLen)); err != nil {
+			return nil, err
+		}
+	}
+	return proof, nil
+}
+
+type bitRangeProofJSON struct {
+	BitCommits []string            `json:"bitCommits"`
+	BitProofs  []*DisjunctiveProof `json:"bitProofs"`
+}
+
+// MarshalJSON encodes BitRangeProof proof with points as compressed hex and
+// its DisjunctiveProofs with their own MarshalJSON
+func (proof *BitRangeProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bitRangeProofJSON{
+		BitCommits: pointsToHex(proof.BitCommits),
+		BitProofs:  proof.BitProofs,
+	})
+}
+
+// UnmarshalJSON decodes a BitRangeProof encoded with MarshalJSON. It fails if
+// a point is not on the curve, a scalar is not in [0, N) or a nested proof is
+// missing
+func (proof *BitRangeProof) UnmarshalJSON(b []byte) error {
+	var aux bitRangeProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	for i, p := range aux.BitProofs {
+		if p == nil {
+			return malformedError("UnmarshalJSON", fmt.Sprintf("bitProofs[%d] is missing", i))
+		}
+	}
+	var err error
+	p := BitRangeProof{BitProofs: aux.BitProofs}
+	if p.BitCommits, err = pointsFromHex(aux.BitCommits, "bitCommits"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
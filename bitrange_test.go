@@ -0,0 +1,85 @@
+package zksigma
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestBitRangeProof(t *testing.T) {
+	bits := 8
+	max := new(big.Int).Lsh(big.NewInt(1), uint(bits)) // 2^bits
+
+	for _, v := range []*big.Int{big.NewInt(0), big.NewInt(1), new(big.Int).Sub(max, big.NewInt(1))} {
+		CM, r, err := PedCommit(TestCurve, v)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		proof, err := NewBitRangeProof(TestCurve, CM, v, r, bits)
+		if err != nil {
+			t.Fatalf("BitRangeProof failed to generate for %v: %v\n", v, err)
+		}
+		ok, err := proof.Verify(TestCurve, CM, bits)
+		if !ok || err != nil {
+			t.Fatalf("BitRangeProof failed to verify for %v: %v\n", v, err)
+		}
+
+		proof, err = NewBitRangeProofFromBytes(proof.Bytes())
+		if err != nil {
+			t.Fatalf("BitRangeProof failed to deserialize: %v\n", err)
+		}
+		ok, err = proof.Verify(TestCurve, CM, bits)
+		if !ok || err != nil {
+			t.Fatalf("BitRangeProof failed to verify after deserialization: %v\n", err)
+		}
+	}
+
+	for _, v := range []*big.Int{max, big.NewInt(-1)} {
+		CM, r, err := PedCommit(TestCurve, v)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if _, err := NewBitRangeProof(TestCurve, CM, v, r, bits); err == nil {
+			t.Fatalf("BitRangeProof should not generate for %v\n", v)
+		}
+	}
+}
+
+func TestBitRangeProofWrongBits(t *testing.T) {
+	v := big.NewInt(5)
+	CM, r, err := PedCommit(TestCurve, v)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	proof, err := NewBitRangeProof(TestCurve, CM, v, r, 4)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, _ := proof.Verify(TestCurve, CM, 8); ok {
+		t.Fatalf("BitRangeProof for 4 bits should not verify for 8 bits\n")
+	}
+	if ok, _ := proof.Verify(TestCurve, CM, 3); ok {
+		t.Fatalf("BitRangeProof for 4 bits should not verify for 3 bits\n")
+	}
+	if ok, _ := proof.Verify(TestCurve, TestCurve.Add(CM, TestCurve.G), 4); ok {
+		t.Fatalf("BitRangeProof should not verify for a different commitment\n")
+	}
+}
+
+func BenchmarkBitRangeProve_32(b *testing.B) {
+	v := big.NewInt(123456789)
+	CM, r, _ := PedCommit(TestCurve, v)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewBitRangeProof(TestCurve, CM, v, r, 32)
+	}
+}
+
+func BenchmarkBitRangeVerify_32(b *testing.B) {
+	v := big.NewInt(123456789)
+	CM, r, _ := PedCommit(TestCurve, v)
+	proof, _ := NewBitRangeProof(TestCurve, CM, v, r, 32)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CM, 32)
+	}
+}
@@ -0,0 +1,260 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/mit-dci/zksigma/wire"
+)
+
+// BoundedRangeProof is a proof that a commitment CM = vG + rH hides a value v
+// in the public interval [lower, upper], both bounds included. The shifted
+// commitments CM - lower*G and upper*G - CM commit to v - lower and upper - v,
+// and a BitRangeProof shows for each of them that it is in [0, 2^bits) with
+// bits the length of upper - lower.
+//
+//  Public: generator points G and H, CM, lower, upper
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows v, r with lower <= v <= upper
+//  L = CM - lower*G                    L = CM - lower*G
+//  U = upper*G - CM                    U = upper*G - CM
+//  BitRangeProof for L, v - lower, r
+//  BitRangeProof for U, upper - v, -r
+//
+//  BitRangeProofs -------------------->
+//                                      BitRangeProof ?= true for L, bits
+//                                      BitRangeProof ?= true for U, bits
+//
+// Both differences are below 2^bits and so far below N, and they add up to
+// upper - lower, so neither of them can be a wrapped around negative value.
+type BoundedRangeProof struct {
+	Lower *BitRangeProof // v - lower is in [0, 2^bits)
+	Upper *BitRangeProof // upper - v is in [0, 2^bits)
+}
+
+// boundedRangeBits checks that lower <= upper are scalars and returns the
+// number of bits of upper - lower used by both BitRangeProofs, at least 1
+func boundedRangeBits(zkpcp ZKPCurveParams, lower, upper *big.Int, t string) (int, error) {
+	if err := zkpcp.checkScalars(t, "lower, upper", lower, upper); err != nil {
+		return 0, err
+	}
+	if upper.Cmp(lower) < 0 {
+		return 0, inputError(t, "upper is less than lower")
+	}
+	bits := new(big.Int).Sub(upper, lower).BitLen()
+	if bits == 0 {
+		bits = 1
+	}
+	if err := checkRangeBits(zkpcp, bits, t); err != nil {
+		return 0, err
+	}
+	return bits, nil
+}
+
+// boundedShifts returns the commitments CM - lower*G and upper*G - CM
+func boundedShifts(zkpcp ZKPCurveParams, CM ECPoint, lower, upper *big.Int) (ECPoint, ECPoint) {
+	return zkpcp.Sub(CM, zkpcp.Mult(zkpcp.G, lower)), zkpcp.Sub(zkpcp.Mult(zkpcp.G, upper), CM)
+}
+
+// NewBoundedRangeProof generates a proof that CM = value*G + randomness*H
+// commits to a value in [lower, upper]. It fails if upper < lower or value is
+// not in the interval.
+func NewBoundedRangeProof(zkpcp ZKPCurveParams, CM ECPoint, value, randomness, lower, upper *big.Int) (*BoundedRangeProof, error) {
+	return NewBoundedRangeProofWithRand(zkpcp, entropy(), CM, value, randomness, lower, upper)
+}
+
+// NewRangeProofBounded is the same as NewBoundedRangeProof for the commitment
+// value*G + randomness*H, which it returns for Verify with the proof
+func NewRangeProofBounded(zkpcp ZKPCurveParams, value, randomness, lower, upper *big.Int) (*BoundedRangeProof, ECPoint, error) {
+	if err := zkpcp.checkScalars("BoundedRangeProve", "value, randomness", value, randomness); err != nil {
+		return nil, Zero, err
+	}
+	CM := PedCommitR(zkpcp, value, randomness)
+	proof, err := NewBoundedRangeProof(zkpcp, CM, value, randomness, lower, upper)
+	if err != nil {
+		return nil, Zero, err
+	}
+	return proof, CM, nil
+}
+
+// NewBoundedRangeProofWithRand is the same as NewBoundedRangeProof, but reads
+// its random values from rnd instead of the EntropySource
+func NewBoundedRangeProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	CM ECPoint, value, randomness, lower, upper *big.Int) (*BoundedRangeProof, error) {
+
+	if err := zkpcp.checkScalars("BoundedRangeProve", "value, randomness", value, randomness); err != nil {
+		return nil, err
+	}
+	bits, err := boundedRangeBits(zkpcp, lower, upper, "BoundedRangeProve")
+	if err != nil {
+		return nil, err
+	}
+	if !zkpcp.validPoints(CM) {
+		return nil, curveError("BoundedRangeProve")
+	}
+	if !CM.Equal(PedCommitR(zkpcp, value, randomness)) {
+		return nil, inputError("BoundedRangeProve", "value and randomness do not produce CM")
+	}
+	if value.Cmp(lower) < 0 || value.Cmp(upper) > 0 {
+		return nil, rangeError("BoundedRangeProve", "value is not in [lower, upper]")
+	}
+
+	N := zkpcp.C.Params().N
+	L, U := boundedShifts(zkpcp, CM, lower, upper)
+
+	lp, err := NewBitRangeProofWithRand(zkpcp, rnd, L, new(big.Int).Sub(value, lower), randomness, bits)
+	if err != nil {
+		return nil, subProofError("BoundedRangeProve", "BitRangeProve for value - lower failed to generate", err)
+	}
+	ru := new(big.Int).Neg(randomness)
+	ru.Mod(ru, N)
+	up, err := NewBitRangeProofWithRand(zkpcp, rnd, U, new(big.Int).Sub(upper, value), ru, bits)
+	if err != nil {
+		return nil, subProofError("BoundedRangeProve", "BitRangeProve for upper - value failed to generate", err)
+	}
+	return &BoundedRangeProof{lp, up}, nil
+}
+
+// Verify checks if BoundedRangeProof proof shows that CM commits to a value in
+// [lower, upper]. It computes the shifted commitments from CM itself.
+func (proof *BoundedRangeProof) Verify(zkpcp ZKPCurveParams, CM ECPoint, lower, upper *big.Int) (bool, error) {
+	if proof == nil || proof.Lower == nil || proof.Upper == nil {
+		return false, malformedError("BoundedRangeProof.Verify", "passed proof is nil")
+	}
+
+	bits, err := boundedRangeBits(zkpcp, lower, upper, "BoundedRangeProof.Verify")
+	if err != nil {
+		return false, err
+	}
+	if !zkpcp.validPoints(CM) {
+		return false, curveError("BoundedRangeProof.Verify")
+	}
+
+	L, U := boundedShifts(zkpcp, CM, lower, upper)
+	if _, err := proof.Lower.Verify(zkpcp, L, bits); err != nil {
+		return false, subProofError("BoundedRangeProof.Verify", "CM - lower*G is not in range", err)
+	}
+	if _, err := proof.Upper.Verify(zkpcp, U, bits); err != nil {
+		return false, subProofError("BoundedRangeProof.Verify", "upper*G - CM is not in range", err)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are CM, lower and upper like for
+// Verify
+func (proof *BoundedRangeProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("BoundedRangeProof.VerifyArgs", args)
+	CM, lower, upper := a.point(), a.scalar(), a.scalar()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CM, lower, upper)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *BoundedRangeProof) String() string {
+	if proof == nil {
+		return "BoundedRangeProof(nil)"
+	}
+	return proofString("BoundedRangeProof", "Lower", proof.Lower, "Upper", proof.Upper)
+}
+
+// Bytes returns a byte slice with a serialized representation of
+// BoundedRangeProof proof, which are the ones of its two BitRangeProofs. Each
+// of them starts with its number of bits, so no length is needed in between.
+func (proof *BoundedRangeProof) Bytes() []byte {
+	return append(proof.Lower.Bytes(), proof.Upper.Bytes()...)
+}
+
+// Size returns the length of Bytes of BoundedRangeProof proof without serializing it
+func (proof *BoundedRangeProof) Size() int {
+	return proof.Lower.Size() + proof.Upper.Size()
+}
+
+// Clone returns a deep copy of BoundedRangeProof proof that shares no big.Int with it
+func (proof *BoundedRangeProof) Clone() *BoundedRangeProof {
+	if proof == nil {
+		return nil
+	}
+	return &BoundedRangeProof{
+		Lower: proof.Lower.Clone(),
+		Upper: proof.Upper.Clone(),
+	}
+}
+
+// Equal returns true if BoundedRangeProof proof and other have the same values in all
+// their fields, nested proofs included. It is not constant time.
+func (proof *BoundedRangeProof) Equal(other *BoundedRangeProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return proof.Lower.Equal(other.Lower) && proof.Upper.Equal(other.Upper)
+}
+
+// NewBoundedRangeProofFromBytes returns a BoundedRangeProof generated from the
+// deserialization of byte slice b
+func NewBoundedRangeProofFromBytes(b []byte) (*BoundedRangeProof, error) {
+	bits, err := wire.ReadVarInt(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if bits > uint64(len(b)) {
+		return nil, malformedError("BoundedRangeProofFromBytes", "buffer length does not match number of bits")
+	}
+	n := wire.VarIntSerializeSize(bits) + int(bits)*(fixedPointLen+disjunctiveProofLen)
+	if n > len(b) {
+		return nil, malformedError("BoundedRangeProofFromBytes", "buffer length does not match number of bits")
+	}
+
+	lp, err := NewBitRangeProofFromBytes(b[:n])
+	if err != nil {
+		return nil, err
+	}
+	up, err := NewBitRangeProofFromBytes(b[n:])
+	if err != nil {
+		return nil, err
+	}
+	if len(up.BitCommits) != len(lp.BitCommits) {
+		return nil, malformedError("BoundedRangeProofFromBytes",
+			fmt.Sprintf("upper proof has %d bits, lower proof %d", len(up.BitCommits), len(lp.BitCommits)))
+	}
+	return &BoundedRangeProof{lp, up}, nil
+}
+
+type boundedRangeProofJSON struct {
+	Lower *BitRangeProof `json:"lower"`
+	Upper *BitRangeProof `json:"upper"`
+}
+
+// MarshalJSON encodes BoundedRangeProof proof as its two BitRangeProofs
+func (proof *BoundedRangeProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(boundedRangeProofJSON{
+		Lower: proof.Lower,
+		Upper: proof.Upper,
+	})
+}
+
+// UnmarshalJSON decodes a BoundedRangeProof encoded with MarshalJSON. It fails
+// if a point is not on the curve, a scalar is not in [0, N) or a nested proof
+// is missing
+func (proof *BoundedRangeProof) UnmarshalJSON(b []byte) error {
+	var aux boundedRangeProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	if aux.Lower == nil {
+		return malformedError("UnmarshalJSON", "lower is missing")
+	}
+	if aux.Upper == nil {
+		return malformedError("UnmarshalJSON", "upper is missing")
+	}
+	p := BoundedRangeProof{Lower: aux.Lower, Upper: aux.Upper}
+	*proof = p
+	return nil
+}
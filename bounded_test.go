@@ -0,0 +1,174 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestBoundedRangeProof(t *testing.T) {
+	cases := []struct{ v, lower, upper int64 }{
+		{100, 100, 50000},
+		{50000, 100, 50000},
+		{1234, 100, 50000},
+		{0, 0, 0},
+		{7, 7, 7},
+		{0, 0, 1},
+		{1, 0, 1},
+		{255, 0, 255},
+		{256, 256, 511},
+	}
+	for _, c := range cases {
+		v, lower, upper := big.NewInt(c.v), big.NewInt(c.lower), big.NewInt(c.upper)
+		CM, r, err := PedCommit(TestCurve, v)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		proof, err := NewBoundedRangeProof(TestCurve, CM, v, r, lower, upper)
+		if err != nil {
+			t.Fatalf("BoundedRangeProof failed to generate for %v in [%v, %v]: %v\n", v, lower, upper, err)
+		}
+		if ok, err := proof.Verify(TestCurve, CM, lower, upper); !ok || err != nil {
+			t.Fatalf("BoundedRangeProof failed to verify for %v in [%v, %v]: %v\n", v, lower, upper, err)
+		}
+
+		proof, err = NewBoundedRangeProofFromBytes(proof.Bytes())
+		if err != nil {
+			t.Fatalf("BoundedRangeProof failed to deserialize: %v\n", err)
+		}
+		if ok, err := proof.Verify(TestCurve, CM, lower, upper); !ok || err != nil {
+			t.Fatalf("BoundedRangeProof failed to verify after deserialization: %v\n", err)
+		}
+	}
+}
+
+func TestBoundedRangeProofFails(t *testing.T) {
+	lower, upper := big.NewInt(100), big.NewInt(50000)
+	for _, v := range []int64{0, 99, 50001, 1 << 20} {
+		value := big.NewInt(v)
+		CM, r, _ := PedCommit(TestCurve, value)
+		if _, err := NewBoundedRangeProof(TestCurve, CM, value, r, lower, upper); !errors.Is(err, ErrValueOutOfRange) {
+			t.Fatalf("BoundedRangeProof for %v in [%v, %v] should not generate, got %v\n", v, lower, upper, err)
+		}
+	}
+
+	v := big.NewInt(1234)
+	CM, r, _ := PedCommit(TestCurve, v)
+	if _, err := NewBoundedRangeProof(TestCurve, CM, v, r, upper, lower); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("BoundedRangeProof generated for upper < lower: %v\n", err)
+	}
+	if _, err := NewBoundedRangeProof(TestCurve, CM, v, new(big.Int).Add(r, big.NewInt(1)), lower, upper); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("BoundedRangeProof generated for the wrong randomness: %v\n", err)
+	}
+
+	proof, err := NewBoundedRangeProof(TestCurve, CM, v, r, lower, upper)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	for _, b := range []struct{ lower, upper int64 }{
+		{1235, 50000},
+		{100, 1233},
+		{0, 50000},
+		{100, 70000},
+	} {
+		if ok, _ := proof.Verify(TestCurve, CM, big.NewInt(b.lower), big.NewInt(b.upper)); ok {
+			t.Fatalf("BoundedRangeProof verified for the wrong bounds [%d, %d]\n", b.lower, b.upper)
+		}
+	}
+	if _, err := proof.Verify(TestCurve, CM, upper, lower); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("BoundedRangeProof.Verify with upper < lower gives %v\n", err)
+	}
+	if ok, _ := proof.Verify(TestCurve, TestCurve.Add(CM, TestCurve.G), lower, upper); ok {
+		t.Fatalf("BoundedRangeProof verified for a different CM\n")
+	}
+	swapped := &BoundedRangeProof{proof.Upper, proof.Lower}
+	if ok, _ := swapped.Verify(TestCurve, CM, lower, upper); ok {
+		t.Fatalf("BoundedRangeProof verified with Lower and Upper swapped\n")
+	}
+	var nilProof *BoundedRangeProof
+	if _, err := nilProof.Verify(TestCurve, CM, lower, upper); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("Verify of a nil BoundedRangeProof gives %v\n", err)
+	}
+}
+
+func TestNewRangeProofBounded(t *testing.T) {
+	lower, upper := big.NewInt(100), big.NewInt(50000)
+	r, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	for _, v := range []int64{100, 1234, 50000} {
+		value := big.NewInt(v)
+		proof, CM, err := ZKCurve.NewRangeProofBounded(value, r, lower, upper)
+		if err != nil {
+			t.Fatalf("NewRangeProofBounded failed for %v in [%v, %v]: %v\n", v, lower, upper, err)
+		}
+		if !CM.Equal(PedCommitR(TestCurve, value, r)) {
+			t.Fatalf("NewRangeProofBounded returns the wrong commitment for %v\n", v)
+		}
+		if ok, err := proof.Verify(ZKCurve, CM, lower, upper); !ok || err != nil {
+			t.Fatalf("proof of NewRangeProofBounded failed to verify for %v: %v\n", v, err)
+		}
+	}
+
+	for _, c := range []struct {
+		value, lower, upper *big.Int
+		err                 error
+	}{
+		{big.NewInt(99), lower, upper, ErrValueOutOfRange},
+		{big.NewInt(50001), lower, upper, ErrValueOutOfRange},
+		{big.NewInt(1234), upper, lower, ErrInvalidInput},
+		{nil, lower, upper, ErrValueOutOfRange},
+	} {
+		if proof, CM, err := NewRangeProofBounded(TestCurve, c.value, r, c.lower, c.upper); proof != nil || !CM.IsZero() || !errors.Is(err, c.err) {
+			t.Fatalf("NewRangeProofBounded for %v in [%v, %v] gives %v\n", c.value, c.lower, c.upper, err)
+		}
+	}
+}
+
+func TestBoundedRangeProofFromBytes(t *testing.T) {
+	v := big.NewInt(20)
+	CM, r, _ := PedCommit(TestCurve, v)
+	proof, err := NewBoundedRangeProof(TestCurve, CM, v, r, big.NewInt(10), big.NewInt(30))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	b := proof.Bytes()
+	if len(b) != proof.Size() {
+		t.Fatalf("Size is %d, Bytes has length %d\n", proof.Size(), len(b))
+	}
+	for _, bad := range [][]byte{nil, b[:len(b)-1], append(b[:len(b):len(b)], 0), b[:proof.Lower.Size()]} {
+		if _, err := NewBoundedRangeProofFromBytes(bad); err == nil {
+			t.Fatalf("NewBoundedRangeProofFromBytes accepted %d bytes\n", len(bad))
+		}
+	}
+
+	// an Upper proof with a different number of bits than Lower
+	v2 := big.NewInt(5)
+	CM2, r2, _ := PedCommit(TestCurve, v2)
+	other, err := NewBitRangeProof(TestCurve, CM2, v2, r2, 3)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	mixed := append(proof.Lower.Bytes(), other.Bytes()...)
+	if _, err := NewBoundedRangeProofFromBytes(mixed); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("NewBoundedRangeProofFromBytes with mismatched bits gives %v\n", err)
+	}
+}
+
+func BenchmarkBoundedRangeProve_32(b *testing.B) {
+	v, lower, upper := big.NewInt(123456789), big.NewInt(1000), big.NewInt(1<<32-1)
+	CM, r, _ := PedCommit(TestCurve, v)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewBoundedRangeProof(TestCurve, CM, v, r, lower, upper)
+	}
+}
+
+func BenchmarkBoundedRangeVerify_32(b *testing.B) {
+	v, lower, upper := big.NewInt(123456789), big.NewInt(1000), big.NewInt(1<<32-1)
+	CM, r, _ := PedCommit(TestCurve, v)
+	proof, _ := NewBoundedRangeProof(TestCurve, CM, v, r, lower, upper)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CM, lower, upper)
+	}
+}
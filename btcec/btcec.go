@@ -22,6 +22,7 @@ package btcec
 import (
 	"crypto/elliptic"
 	"math/big"
+	"math/bits"
 	"sync"
 )
 
@@ -77,12 +78,23 @@ func (curve *KoblitzCurve) Params() *elliptic.CurveParams {
 // it to an affine point as field values.
 func (curve *KoblitzCurve) bigAffineToField(x, y *big.Int) (*fieldVal, *fieldVal) {
 	x3, y3 := new(fieldVal), new(fieldVal)
-	x3.SetByteSlice(x.Bytes())
-	y3.SetByteSlice(y.Bytes())
+	setBig(x3, x)
+	setBig(y3, y)
 
 	return x3, y3
 }
 
+// setBig sets f to the big integer v like f.SetByteSlice(v.Bytes()), but
+// without allocating the byte slice when v fits in 32 bytes.
+func setBig(f *fieldVal, v *big.Int) *fieldVal {
+	if v.BitLen() > 256 {
+		return f.SetByteSlice(v.Bytes())
+	}
+	var b [32]byte
+	v.FillBytes(b[:])
+	return f.SetBytes(&b)
+}
+
 // fieldJacobianToBigAffine takes a Jacobian point (x, y, z) as field values and
 // converts it to an affine point as big integers.
 func (curve *KoblitzCurve) fieldJacobianToBigAffine(x, y, z *fieldVal) (*big.Int, *big.Int) {
@@ -102,10 +114,36 @@ func (curve *KoblitzCurve) fieldJacobianToBigAffine(x, y, z *fieldVal) (*big.Int
 	y.Normalize()
 
 	// Convert the field values for the now affine point to big.Ints.
-	x3, y3 := new(big.Int), new(big.Int)
-	x3.SetBytes(x.Bytes()[:])
-	y3.SetBytes(y.Bytes()[:])
-	return x3, y3
+	r := new(bigAffine)
+	r.x.SetBits(putWords(r.w[:bigWords:bigWords], x))
+	r.y.SetBits(putWords(r.w[bigWords:], y))
+	return &r.x, &r.y
+}
+
+// bigWords is the number of big.Words of a 256-bit field value.
+const bigWords = 256 / bits.UintSize
+
+// bigAffine holds the coordinates of an affine point together with the words
+// backing them, so converting a point to big integers takes one allocation.
+type bigAffine struct {
+	x, y big.Int
+	w    [2 * bigWords]big.Word
+}
+
+// putWords stores the normalized field value f as little-endian words in w,
+// the layout big.Int.SetBits expects, and returns w.
+func putWords(w []big.Word, f *fieldVal) []big.Word {
+	const wordBytes = bits.UintSize / 8
+	var b [32]byte
+	f.PutBytes(&b)
+	for i := range w {
+		var v big.Word
+		for j := 0; j < wordBytes; j++ {
+			v |= big.Word(b[31-i*wordBytes-j]) << (8 * uint(j))
+		}
+		w[i] = v
+	}
+	return w
 }
 
 // IsOnCurve returns boolean if the point (x,y) is on the curve.
@@ -113,12 +151,14 @@ func (curve *KoblitzCurve) fieldJacobianToBigAffine(x, y, z *fieldVal) (*big.Int
 // crypto/elliptic algorithm since a = 0 not -3.
 func (curve *KoblitzCurve) IsOnCurve(x, y *big.Int) bool {
 	// Convert big ints to field values for faster arithmetic.
-	fx, fy := curve.bigAffineToField(x, y)
+	var fx, fy, y2, result fieldVal
+	setBig(&fx, x)
+	setBig(&fy, y)
 
 	// Elliptic curve equation for secp256k1 is: y^2 = x^3 + 7
-	y2 := new(fieldVal).SquareVal(fy).Normalize()
-	result := new(fieldVal).SquareVal(fx).Mul(fx).AddInt(7).Normalize()
-	return y2.Equals(result)
+	y2.SquareVal(&fy).Normalize()
+	result.SquareVal(&fx).Mul(&fx).AddInt(7).Normalize()
+	return y2.Equals(&result)
 }
 
 // addZ1AndZ2EqualsOne adds two Jacobian points that are already known to have
@@ -466,15 +506,17 @@ func (curve *KoblitzCurve) Add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
 
 	// Convert the affine coordinates from big integers to field values
 	// and do the point addition in Jacobian projective space.
-	fx1, fy1 := curve.bigAffineToField(x1, y1)
-	fx2, fy2 := curve.bigAffineToField(x2, y2)
-	fx3, fy3, fz3 := new(fieldVal), new(fieldVal), new(fieldVal)
-	fOne := new(fieldVal).SetInt(1)
-	curve.addJacobian(fx1, fy1, fOne, fx2, fy2, fOne, fx3, fy3, fz3)
+	var fx1, fy1, fx2, fy2, fx3, fy3, fz3, fOne fieldVal
+	setBig(&fx1, x1)
+	setBig(&fy1, y1)
+	setBig(&fx2, x2)
+	setBig(&fy2, y2)
+	fOne.SetInt(1)
+	curve.addJacobian(&fx1, &fy1, &fOne, &fx2, &fy2, &fOne, &fx3, &fy3, &fz3)
 
 	// Convert the Jacobian coordinate field values back to affine big
 	// integers.
-	return curve.fieldJacobianToBigAffine(fx3, fy3, fz3)
+	return curve.fieldJacobianToBigAffine(&fx3, &fy3, &fz3)
 }
 
 // doubleZ1EqualsOne performs point doubling on the passed Jacobian point
@@ -770,7 +812,8 @@ func (curve *KoblitzCurve) ScalarMult(Bx, By *big.Int, k []byte) (*big.Int, *big
 	//   k * P = k1 * P + k2 * ϕ(P)
 	//
 	// P1 below is P in the equation, P2 below is ϕ(P) in the equation
-	p1x, p1y := curve.bigAffineToField(Bx, By)
+	var p1xVal, p1yVal fieldVal
+	p1x, p1y := setBig(&p1xVal, Bx), setBig(&p1yVal, By)
 	p1yNeg := new(fieldVal).NegateVal(p1y, 1)
 	p1z := new(fieldVal).SetInt(1)
 
@@ -904,6 +947,59 @@ func (curve *KoblitzCurve) ScalarBaseMultH(k []byte) (*big.Int, *big.Int) {
 	return curve.fieldJacobianToBigAffine(qx, qy, qz)
 }
 
+// MultiScalarMult returns the sum of ks[i]*(xs[i], ys[i]) where each ks[i] is
+// a big endian integer. It uses Straus' method with 4-bit windows so the
+// doublings are shared between all the points, which is a lot faster than
+// calling ScalarMult for every point and adding the results.
+// NOT part of the elliptic.Curve interface.
+func (curve *KoblitzCurve) MultiScalarMult(xs, ys []*big.Int, ks [][]byte) (*big.Int, *big.Int) {
+	const window = 4
+	n := len(ks)
+
+	// terms[i].table[d] holds d*P_i in Jacobian coordinates for d in
+	// [0, 16), and terms[i].k the scalar of P_i.
+	terms := make([]struct {
+		table [1 << window][3]fieldVal
+		k     [32]byte
+	}, n)
+	for i := 0; i < n; i++ {
+		table := &terms[i].table
+		setBig(&table[1][0], xs[i])
+		setBig(&table[1][1], ys[i])
+		table[1][2].SetInt(1)
+		for d := 2; d < 1<<window; d++ {
+			p, q := &table[d-1], &table[1]
+			curve.addJacobian(&p[0], &p[1], &p[2], &q[0], &q[1], &q[2],
+				&table[d][0], &table[d][1], &table[d][2])
+		}
+
+		// Left pad the reduced scalar to 32 bytes so all windows line up.
+		k := curve.moduloReduce(ks[i])
+		copy(terms[i].k[32-len(k):], k)
+	}
+
+	// Point Q = ∞ (point at infinity).
+	qx, qy, qz := new(fieldVal), new(fieldVal), new(fieldVal)
+	for w := 0; w < 256/window; w++ {
+		for j := 0; j < window; j++ {
+			curve.doubleJacobian(qx, qy, qz, qx, qy, qz)
+		}
+		for i := 0; i < n; i++ {
+			digit := terms[i].k[w/2]
+			if w%2 == 0 {
+				digit >>= window
+			}
+			digit &= 1<<window - 1
+			if digit == 0 {
+				continue
+			}
+			p := &terms[i].table[digit]
+			curve.addJacobian(qx, qy, qz, &p[0], &p[1], &p[2], qx, qy, qz)
+		}
+	}
+	return curve.fieldJacobianToBigAffine(qx, qy, qz)
+}
+
 // QPlus1Div4 returns the Q+1/4 constant for the curve for use in calculating
 // square roots via exponention.
 func (curve *KoblitzCurve) QPlus1Div4() *big.Int {
@@ -6,6 +6,7 @@
 package btcec
 
 import (
+	"math/big"
 	"reflect"
 	"testing"
 )
@@ -820,3 +821,49 @@ func TestInverse(t *testing.T) {
 		}
 	}
 }
+
+// TestBigConversion ensures that converting big integers to field values and
+// back with setBig and putWords gives the same values as going through their
+// byte encodings.
+func TestBigConversion(t *testing.T) {
+	tests := []string{
+		"0",
+		"1",
+		"ff",
+		"1000000000000000000000000",
+		"fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2e",
+		"16fb970147a9acc73654d4be233cc48b875ce20a2122d24f073d29bd28805aca",
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+	}
+
+	for i, test := range tests {
+		v, _ := new(big.Int).SetString(test, 16)
+		f := setBig(new(fieldVal), v)
+		expected := new(fieldVal).SetByteSlice(v.Bytes())
+		if !reflect.DeepEqual(f.n, expected.n) {
+			t.Errorf("setBig #%d wrong result\ngot: %v\nwant: %v", i, f, expected)
+			continue
+		}
+
+		f.Normalize()
+		w := putWords(make([]big.Word, bigWords), f)
+		result := new(big.Int).SetBits(w)
+		want := new(big.Int).SetBytes(f.Bytes()[:])
+		if result.Cmp(want) != 0 {
+			t.Errorf("putWords #%d wrong result\ngot: %x\nwant: %x", i, result, want)
+		}
+	}
+}
+
+// TestCurveAllocs ensures that IsOnCurve does not allocate and that Add only
+// allocates its result.
+func TestCurveAllocs(t *testing.T) {
+	curve := S256()
+	x, y := curve.ScalarBaseMult([]byte{7})
+	if allocs := testing.AllocsPerRun(10, func() { curve.IsOnCurve(x, y) }); allocs > 0 {
+		t.Errorf("IsOnCurve allocates %v times", allocs)
+	}
+	if allocs := testing.AllocsPerRun(10, func() { curve.Add(x, y, curve.Gx, curve.Gy) }); allocs > 1 {
+		t.Errorf("Add allocates %v times", allocs)
+	}
+}
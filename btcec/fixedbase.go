@@ -0,0 +1,129 @@
+package btcec
+
+import "math/big"
+
+// MaxTableWindow is the widest window a FixedBaseTable can have, the one of
+// the tables ScalarBaseMult and ScalarBaseMultH use
+const MaxTableWindow = 8
+
+// FixedBaseTable holds all 2^w multiples of a point for every w-bit window of
+// a scalar, laid out like the tables ScalarBaseMult and ScalarBaseMultH use
+// for w = 8: entry [i][d] is d * 2^(w*(n-1-i)) * P in Jacobian coordinates,
+// with n = ceil(256 / w) windows. With it a scalar multiplication of P costs n
+// point additions and no doublings. A narrower window takes less memory,
+// n * 2^w entries, for more additions.
+type FixedBaseTable struct {
+	window uint
+	points [][][3]fieldVal
+}
+
+// NewFixedBaseTable computes the FixedBaseTable for the point (x, y) with
+// 8-bit windows. This takes a few thousand point additions, so the table
+// should be built once and reused. ScalarMultTables only reads it, so it can
+// be shared between goroutines.
+func (curve *KoblitzCurve) NewFixedBaseTable(x, y *big.Int) *FixedBaseTable {
+	return curve.NewFixedBaseTableWindow(x, y, MaxTableWindow)
+}
+
+// NewFixedBaseTableWindow is NewFixedBaseTable with windows of the given
+// number of bits. It panics if window is not in [1, MaxTableWindow].
+func (curve *KoblitzCurve) NewFixedBaseTableWindow(x, y *big.Int, window uint) *FixedBaseTable {
+	if window < 1 || window > MaxTableWindow {
+		panic("btcec: table window out of range")
+	}
+	n := (curve.BitSize + int(window) - 1) / int(window)
+	t := &FixedBaseTable{window: window, points: make([][][3]fieldVal, n)}
+
+	// (bx, by, bz) = 2^(w*(n-1-i)) * P for the window being filled
+	bx, by := curve.bigAffineToField(x, y)
+	bz := new(fieldVal).SetInt(1)
+	for i := n - 1; i >= 0; i-- {
+		row := make([][3]fieldVal, 1<<window)
+		row[1][0].Set(bx)
+		row[1][1].Set(by)
+		row[1][2].Set(bz)
+		for d := 2; d < len(row); d++ {
+			p := &row[d-1]
+			curve.addJacobian(&p[0], &p[1], &p[2], bx, by, bz,
+				&row[d][0], &row[d][1], &row[d][2])
+		}
+		for j := uint(0); j < window; j++ {
+			curve.doubleJacobian(bx, by, bz, bx, by, bz)
+		}
+		t.points[i] = row
+	}
+	return t
+}
+
+// byteTable returns the FixedBaseTable of 8-bit windows made of the rows of
+// bytePoints
+func byteTable(bytePoints *[32][256][3]fieldVal) *FixedBaseTable {
+	t := &FixedBaseTable{window: 8, points: make([][][3]fieldVal, len(bytePoints))}
+	for i := range bytePoints {
+		t.points[i] = bytePoints[i][:]
+	}
+	return t
+}
+
+// BaseTable returns the FixedBaseTable of G that ScalarBaseMult uses.
+func (curve *KoblitzCurve) BaseTable() *FixedBaseTable {
+	return byteTable(curve.bytePoints)
+}
+
+// BaseTableH returns the FixedBaseTable of H that ScalarBaseMultH uses.
+func (curve *KoblitzCurve) BaseTableH() *FixedBaseTable {
+	return byteTable(curve.bytePointsH)
+}
+
+// Window returns the number of bits of the windows of t
+func (t *FixedBaseTable) Window() int {
+	return int(t.window)
+}
+
+// Size returns the number of bytes the points of t take
+func (t *FixedBaseTable) Size() int {
+	var p [3]fieldVal
+	return len(t.points) * len(t.points[0]) * len(p) * len(p[0].n) * 4
+}
+
+// digit returns window i of t, counted from the most significant one, of the
+// 32 byte big endian integer k
+func (t *FixedBaseTable) digit(k *[32]byte, i int) int {
+	if t.window == 8 {
+		return int(k[len(k)-len(t.points)+i])
+	}
+	// bits [lo, lo + window) counted from the least significant one
+	lo := int(t.window) * (len(t.points) - 1 - i)
+	d := 0
+	for b := int(t.window) - 1; b >= 0; b-- {
+		bit := lo + b
+		d <<= 1
+		if bit < 8*len(k) {
+			d |= int(k[len(k)-1-bit/8]>>uint(bit%8)) & 1
+		}
+	}
+	return d
+}
+
+// ScalarMultTables returns the sum of ks[i]*P_i where every P_i is given by
+// its FixedBaseTable ts[i] and each ks[i] is a big endian integer. The tables
+// may have different windows. The sum is kept in Jacobian coordinates, so
+// only the result is converted back.
+// NOT part of the elliptic.Curve interface.
+func (curve *KoblitzCurve) ScalarMultTables(ts []*FixedBaseTable, ks [][]byte) (*big.Int, *big.Int) {
+	// Point Q = ∞ (point at infinity).
+	qx, qy, qz := new(fieldVal), new(fieldVal), new(fieldVal)
+
+	for n, t := range ts {
+		var k [32]byte
+		reduced := curve.moduloReduce(ks[n])
+		copy(k[len(k)-len(reduced):], reduced)
+		for i, row := range t.points {
+			// addJacobian normalizes its inputs, so work on a copy to keep
+			// the table read-only
+			p := row[t.digit(&k, i)]
+			curve.addJacobian(qx, qy, qz, &p[0], &p[1], &p[2], qx, qy, qz)
+		}
+	}
+	return curve.fieldJacobianToBigAffine(qx, qy, qz)
+}
@@ -0,0 +1,143 @@
+package zksigma
+
+import (
+	"math/big"
+)
+
+// OpenCommitment is a Pedersen commitment CM = Value * G + Randomness * H
+// kept together with its opening, so the randomness of a commitment can not
+// be lost or mixed up with the randomness of another one. Value and Randomness
+// are reduced mod N, which is what the proofs expect.
+//
+// An OpenCommitment remembers the ZKPCurveParams it was made with, Add and Sub
+// only combine commitments made with the same ones.
+type OpenCommitment struct {
+	CM         ECPoint
+	Value      *big.Int
+	Randomness *big.Int
+
+	zkpcp ZKPCurveParams
+}
+
+// PedCommitOpen is the same as PedCommit, but returns the commitment together
+// with its opening
+func PedCommitOpen(zkpcp ZKPCurveParams, value *big.Int) (*OpenCommitment, error) {
+	CM, r, err := PedCommit(zkpcp, value)
+	if err != nil {
+		return nil, err
+	}
+	return &OpenCommitment{CM, new(big.Int).Mod(value, zkpcp.C.Params().N), r, zkpcp}, nil
+}
+
+// NewOpenCommitment returns the OpenCommitment to value with randomness, which
+// has the same CM as PedCommitR(zkpcp, value, randomness)
+func NewOpenCommitment(zkpcp ZKPCurveParams, value, randomness *big.Int) *OpenCommitment {
+	N := zkpcp.C.Params().N
+	return &OpenCommitment{
+		CM:         PedCommitR(zkpcp, value, randomness),
+		Value:      new(big.Int).Mod(value, N),
+		Randomness: new(big.Int).Mod(randomness, N),
+		zkpcp:      zkpcp,
+	}
+}
+
+// Open returns copies of the value and randomness of oc
+func (oc *OpenCommitment) Open() (*big.Int, *big.Int) {
+	return new(big.Int).Set(oc.Value), new(big.Int).Set(oc.Randomness)
+}
+
+// Verify recomputes the commitment from the value and randomness of oc and
+// checks that it is CM
+func (oc *OpenCommitment) Verify() bool {
+	if oc == nil || oc.zkpcp.C == nil || oc.Value == nil || oc.Randomness == nil {
+		return false
+	}
+	return Open(oc.zkpcp, oc.Value, oc.Randomness, oc.CM)
+}
+
+// Add returns the commitment oc + other to the sum of their values, with the
+// sum of their randomness
+func (oc *OpenCommitment) Add(other *OpenCommitment) *OpenCommitment {
+	N := oc.zkpcp.C.Params().N
+	value := new(big.Int).Add(oc.Value, other.Value)
+	r := new(big.Int).Add(oc.Randomness, other.Randomness)
+	return &OpenCommitment{oc.zkpcp.Add(oc.CM, other.CM), value.Mod(value, N), r.Mod(r, N), oc.zkpcp}
+}
+
+// Sub returns the commitment oc - other to the difference of their values,
+// with the difference of their randomness
+func (oc *OpenCommitment) Sub(other *OpenCommitment) *OpenCommitment {
+	N := oc.zkpcp.C.Params().N
+	value := new(big.Int).Sub(oc.Value, other.Value)
+	r := new(big.Int).Sub(oc.Randomness, other.Randomness)
+	return &OpenCommitment{oc.zkpcp.Sub(oc.CM, other.CM), value.Mod(value, N), r.Mod(r, N), oc.zkpcp}
+}
+
+// String returns a labeled dump of the fields of oc for debugging, which
+// includes the opening
+func (oc *OpenCommitment) String() string {
+	if oc == nil {
+		return "OpenCommitment(nil)"
+	}
+	return proofString("OpenCommitment", "CM", oc.CM, "Value", oc.Value, "Randomness", oc.Randomness)
+}
+
+// NewOpenProof is the same as NewOpenProof(zkpcp, oc.CM, oc.Value, oc.Randomness)
+func (oc *OpenCommitment) NewOpenProof() (*OpenProof, error) {
+	return NewOpenProof(oc.zkpcp, oc.CM, oc.Value, oc.Randomness)
+}
+
+// NewOpenToValueProof is the same as NewOpenToValueProof(zkpcp, oc.CM, oc.Value, oc.Randomness)
+func (oc *OpenCommitment) NewOpenToValueProof() (*OpenToValueProof, error) {
+	return NewOpenToValueProof(oc.zkpcp, oc.CM, oc.Value, oc.Randomness)
+}
+
+// NewNonZeroProof is the same as NewNonZeroProof(zkpcp, oc.CM, oc.Value, oc.Randomness)
+func (oc *OpenCommitment) NewNonZeroProof() (*NonZeroProof, error) {
+	return NewNonZeroProof(oc.zkpcp, oc.CM, oc.Value, oc.Randomness)
+}
+
+// NewBitRangeProof is the same as NewBitRangeProof(zkpcp, oc.CM, oc.Value, oc.Randomness, bits)
+func (oc *OpenCommitment) NewBitRangeProof(bits int) (*BitRangeProof, error) {
+	return NewBitRangeProof(oc.zkpcp, oc.CM, oc.Value, oc.Randomness, bits)
+}
+
+// NewInequalityProof is the same as NewInequalityProof(zkpcp, oc.CM, other.CM, ...)
+// with the openings of oc and other
+func (oc *OpenCommitment) NewInequalityProof(other *OpenCommitment) (*InequalityProof, error) {
+	return NewInequalityProof(oc.zkpcp, oc.CM, other.CM, oc.Value, other.Value, oc.Randomness, other.Randomness)
+}
+
+// NewComparisonProof is the same as NewComparisonProof(zkpcp, oc.CM, other.CM, ...)
+// with the openings of oc and other
+func (oc *OpenCommitment) NewComparisonProof(other *OpenCommitment, bits int) (*ComparisonProof, error) {
+	return NewComparisonProof(oc.zkpcp, oc.CM, other.CM, oc.Value, other.Value, oc.Randomness, other.Randomness, bits)
+}
+
+// NewBoundedRangeProof is the same as NewBoundedRangeProof(zkpcp, oc.CM, ...)
+// with the opening of oc
+func (oc *OpenCommitment) NewBoundedRangeProof(lower, upper *big.Int) (*BoundedRangeProof, error) {
+	return NewBoundedRangeProof(oc.zkpcp, oc.CM, oc.Value, oc.Randomness, lower, upper)
+}
+
+// NewConsistencyProof is the same as NewConsistencyProof(zkpcp, oc.CM, CMTok, PubKey, oc.Value, oc.Randomness)
+func (oc *OpenCommitment) NewConsistencyProof(CMTok, PubKey ECPoint) (*ConsistencyProof, error) {
+	return NewConsistencyProof(oc.zkpcp, oc.CM, CMTok, PubKey, oc.Value, oc.Randomness)
+}
+
+// NewABCProof is the same as NewABCProof(zkpcp, oc.CM, CMTok, oc.Value, sk, option)
+func (oc *OpenCommitment) NewABCProof(CMTok ECPoint, sk *big.Int, option Side) (*ABCProof, error) {
+	return NewABCProof(oc.zkpcp, oc.CM, CMTok, oc.Value, sk, option)
+}
+
+// NewSumProofFromCommitments is the same as NewSumProof with the commitments
+// and openings of OCs
+func NewSumProofFromCommitments(zkpcp ZKPCurveParams, OCs []*OpenCommitment, total *big.Int) (*SumProof, error) {
+	CMs := make([]ECPoint, len(OCs))
+	values := make([]*big.Int, len(OCs))
+	randomnesses := make([]*big.Int, len(OCs))
+	for i, oc := range OCs {
+		CMs[i], values[i], randomnesses[i] = oc.CM, oc.Value, oc.Randomness
+	}
+	return NewSumProof(zkpcp, CMs, values, randomnesses, total)
+}
@@ -0,0 +1,175 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestOpenCommitment(t *testing.T) {
+	N := TestCurve.C.Params().N
+
+	a, err := PedCommitOpen(TestCurve, big.NewInt(30))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if !a.Verify() {
+		t.Fatalf("OpenCommitment does not verify\n")
+	}
+
+	// round trip through PedCommitR
+	value, r := a.Open()
+	if !PedCommitR(TestCurve, value, r).Equal(a.CM) {
+		t.Fatalf("PedCommitR of the opening does not give CM\n")
+	}
+	if b := NewOpenCommitment(TestCurve, value, r); !b.CM.Equal(a.CM) || !b.Verify() {
+		t.Fatalf("NewOpenCommitment of the opening does not give CM\n")
+	}
+	value.SetInt64(31)
+	if a.Value.Int64() != 30 {
+		t.Fatalf("changing the result of Open changed the commitment\n")
+	}
+
+	// negative values and randomness are reduced
+	neg := NewOpenCommitment(TestCurve, big.NewInt(-5), new(big.Int).Neg(r))
+	if neg.Value.Cmp(new(big.Int).Sub(N, big.NewInt(5))) != 0 || neg.Randomness.Sign() < 0 || !neg.Verify() {
+		t.Fatalf("NewOpenCommitment does not reduce its opening mod N\n")
+	}
+
+	wrong := *a
+	wrong.Randomness = new(big.Int).Add(a.Randomness, big.NewInt(1))
+	if wrong.Verify() {
+		t.Fatalf("OpenCommitment with the wrong randomness verified\n")
+	}
+	if (&OpenCommitment{}).Verify() || (*OpenCommitment)(nil).Verify() {
+		t.Fatalf("zero value OpenCommitment verified\n")
+	}
+	if (&OpenCommitment{}).String() == "" || (*OpenCommitment)(nil).String() == "" {
+		t.Fatalf("String of a zero value OpenCommitment is empty\n")
+	}
+
+	if _, err := PedCommitOpen(TestCurve, nil); err == nil {
+		t.Fatalf("PedCommitOpen accepted a nil value\n")
+	}
+}
+
+func TestOpenCommitmentAddSub(t *testing.T) {
+	N := TestCurve.C.Params().N
+	a, _ := PedCommitOpen(TestCurve, big.NewInt(30))
+	b, _ := PedCommitOpen(TestCurve, big.NewInt(12))
+
+	sum := a.Add(b)
+	if !sum.Verify() || sum.Value.Int64() != 42 || !sum.CM.Equal(TestCurve.Add(a.CM, b.CM)) {
+		t.Fatalf("Add gives %v\n", sum)
+	}
+	expect := new(big.Int).Add(a.Randomness, b.Randomness)
+	if sum.Randomness.Cmp(expect.Mod(expect, N)) != 0 {
+		t.Fatalf("Add does not add the randomness\n")
+	}
+
+	diff := b.Sub(a)
+	if !diff.Verify() || diff.Value.Cmp(new(big.Int).Sub(N, big.NewInt(18))) != 0 ||
+		!diff.CM.Equal(TestCurve.Sub(b.CM, a.CM)) {
+		t.Fatalf("Sub gives %v\n", diff)
+	}
+	if back := sum.Sub(b); !back.CM.Equal(a.CM) || back.Value.Cmp(a.Value) != 0 || back.Randomness.Cmp(a.Randomness) != 0 {
+		t.Fatalf("(a + b) - b is not a\n")
+	}
+	if zero := a.Sub(a); !zero.CM.Equal(Zero) || zero.Value.Sign() != 0 || zero.Randomness.Sign() != 0 {
+		t.Fatalf("a - a is %v\n", zero)
+	}
+
+	// a chain of additions keeps track of the randomness
+	total := a
+	for ii := 0; ii < 10; ii++ {
+		x, _ := rand.Int(rand.Reader, big.NewInt(1000))
+		c, _ := PedCommitOpen(TestCurve, x)
+		total = total.Add(c)
+	}
+	if !total.Verify() {
+		t.Fatalf("sum of 11 commitments does not verify\n")
+	}
+}
+
+func TestOpenCommitmentProofs(t *testing.T) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	a, _ := PedCommitOpen(TestCurve, big.NewInt(30))
+	b, _ := PedCommitOpen(TestCurve, big.NewInt(12))
+
+	op, err := a.NewOpenProof()
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := op.Verify(TestCurve, a.CM); !ok || err != nil {
+		t.Fatalf("OpenProof of an OpenCommitment did not verify: %v\n", err)
+	}
+	ov, err := a.NewOpenToValueProof()
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := ov.Verify(TestCurve, a.CM, big.NewInt(30)); !ok || err != nil {
+		t.Fatalf("OpenToValueProof of an OpenCommitment did not verify: %v\n", err)
+	}
+	nz, err := a.NewNonZeroProof()
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := nz.Verify(TestCurve, a.CM); !ok || err != nil {
+		t.Fatalf("NonZeroProof of an OpenCommitment did not verify: %v\n", err)
+	}
+	br, err := a.NewBitRangeProof(8)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := br.Verify(TestCurve, a.CM, 8); !ok || err != nil {
+		t.Fatalf("BitRangeProof of an OpenCommitment did not verify: %v\n", err)
+	}
+	ie, err := a.NewInequalityProof(b)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := ie.Verify(TestCurve, a.CM, b.CM); !ok || err != nil {
+		t.Fatalf("InequalityProof of OpenCommitments did not verify: %v\n", err)
+	}
+	cmp, err := a.NewComparisonProof(b, 8)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := cmp.Verify(TestCurve, a.CM, b.CM, 8); !ok || err != nil {
+		t.Fatalf("ComparisonProof of OpenCommitments did not verify: %v\n", err)
+	}
+	bd, err := a.NewBoundedRangeProof(big.NewInt(10), big.NewInt(30))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := bd.Verify(TestCurve, a.CM, big.NewInt(10), big.NewInt(30)); !ok || err != nil {
+		t.Fatalf("BoundedRangeProof of an OpenCommitment did not verify: %v\n", err)
+	}
+	CMTok := TestCurve.Mult(PK, a.Randomness)
+	con, err := a.NewConsistencyProof(CMTok, PK)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := con.Verify(TestCurve, a.CM, CMTok, PK); !ok || err != nil {
+		t.Fatalf("ConsistencyProof of an OpenCommitment did not verify: %v\n", err)
+	}
+	abc, err := a.NewABCProof(CMTok, sk, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := abc.Verify(TestCurve, a.CM, CMTok, PK); !ok || err != nil {
+		t.Fatalf("ABCProof of an OpenCommitment did not verify: %v\n", err)
+	}
+
+	sp, err := NewSumProofFromCommitments(TestCurve, []*OpenCommitment{a, b}, big.NewInt(42))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := sp.Verify(TestCurve, []ECPoint{a.CM, b.CM}, big.NewInt(42)); !ok || err != nil {
+		t.Fatalf("SumProof of OpenCommitments did not verify: %v\n", err)
+	}
+	if _, err := NewSumProofFromCommitments(TestCurve, []*OpenCommitment{a, b}, big.NewInt(43)); err == nil {
+		t.Fatalf("SumProof generated for the wrong total\n")
+	}
+}
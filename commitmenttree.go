@@ -0,0 +1,136 @@
+package zksigma
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+// CommitmentTree is a Merkle tree over commitments, e.g. over all account
+// commitments of an audit snapshot, whose Root is published so that a user can
+// show with a MerkleProof that a commitment is in the snapshot.
+//
+// The hash is SHA-256 like for GenerateChallenge, with a prefix byte to tell
+// leaves from interior nodes, so no interior node can be passed off as a leaf:
+//
+//	leaf = SHA-256(0x00 || compressed SEC1 encoding of the commitment)
+//	node = SHA-256(0x01 || left || right)
+//
+// A level with an odd number of nodes carries its last node up to the next
+// level unchanged, it is neither duplicated nor padded. This is the tree of
+// RFC 6962, so a tree of n leaves has a root no tree of other leaves has.
+type CommitmentTree struct {
+	levels [][][]byte // levels[0] are the leaf hashes, the last level is the root
+}
+
+// MerkleProof shows that a leaf is at Index of a CommitmentTree of Size
+// leaves. Siblings are the hashes the leaf is combined with from the bottom
+// up, with none for a level that carries the node up.
+type MerkleProof struct {
+	Index    int
+	Size     int
+	Siblings [][]byte
+}
+
+const (
+	merkleLeafPrefix byte = 0x00
+	merkleNodePrefix byte = 0x01
+)
+
+// merkleLeaf returns the hash of leaf p
+func merkleLeaf(p ECPoint) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write(compressPoint(encodingCurves[0], p))
+	return h.Sum(nil)
+}
+
+// merkleNode returns the hash of the interior node with children left and right
+func merkleNode(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// NewCommitmentTree returns the CommitmentTree of leaves in their order. It
+// fails if there are no leaves or one of them is not on the curve.
+func NewCommitmentTree(leaves []ECPoint) (*CommitmentTree, error) {
+	if len(leaves) == 0 {
+		return nil, inputError("NewCommitmentTree", "no leaves")
+	}
+	level := make([][]byte, len(leaves))
+	for i, p := range leaves {
+		if p.X == nil || p.Y == nil || !encodingCurves[0].IsOnCurve(p.X, p.Y) {
+			return nil, newError(ErrCodeNotOnCurve, "NewCommitmentTree", fmt.Sprintf("leaves[%d] not on curve", i))
+		}
+		level[i] = merkleLeaf(p)
+	}
+
+	tree := &CommitmentTree{levels: [][][]byte{level}}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i+1 < len(level); i += 2 {
+			next = append(next, merkleNode(level[i], level[i+1]))
+		}
+		if len(level)%2 == 1 {
+			next = append(next, level[len(level)-1])
+		}
+		tree.levels = append(tree.levels, next)
+		level = next
+	}
+	return tree, nil
+}
+
+// Root returns the root hash of tree
+func (tree *CommitmentTree) Root() []byte {
+	return append([]byte(nil), tree.levels[len(tree.levels)-1][0]...)
+}
+
+// Size returns the number of leaves of tree
+func (tree *CommitmentTree) Size() int {
+	return len(tree.levels[0])
+}
+
+// Prove returns the MerkleProof that the leaf at index is in tree
+func (tree *CommitmentTree) Prove(index int) (*MerkleProof, error) {
+	if index < 0 || index >= tree.Size() {
+		return nil, inputError("CommitmentTree.Prove", fmt.Sprintf("index %d is not in [0, %d)", index, tree.Size()))
+	}
+	proof := &MerkleProof{Index: index, Size: tree.Size()}
+	for _, level := range tree.levels[:len(tree.levels)-1] {
+		if sibling := index ^ 1; sibling < len(level) {
+			proof.Siblings = append(proof.Siblings, append([]byte(nil), level[sibling]...))
+		}
+		index /= 2
+	}
+	return proof, nil
+}
+
+// VerifyMembership returns true if proof shows that leaf is at proof.Index of
+// the CommitmentTree with the given root. Check that proof.Size is the size of
+// the snapshot too if it matters, a root only has a proof for its own size.
+func VerifyMembership(root []byte, leaf ECPoint, proof *MerkleProof) bool {
+	if proof == nil || proof.Index < 0 || proof.Index >= proof.Size ||
+		leaf.X == nil || leaf.Y == nil || !encodingCurves[0].IsOnCurve(leaf.X, leaf.Y) {
+		return false
+	}
+
+	h, siblings := merkleLeaf(leaf), proof.Siblings
+	for index, n := proof.Index, proof.Size; n > 1; index, n = index/2, (n+1)/2 {
+		if index == n-1 && n%2 == 1 {
+			continue // carried up
+		}
+		if len(siblings) == 0 || len(siblings[0]) != sha256.Size {
+			return false
+		}
+		if index%2 == 0 {
+			h = merkleNode(h, siblings[0])
+		} else {
+			h = merkleNode(siblings[0], h)
+		}
+		siblings = siblings[1:]
+	}
+	return len(siblings) == 0 && bytes.Equal(h, root)
+}
@@ -0,0 +1,136 @@
+package zksigma
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// treeLeaves returns n distinct commitments
+func treeLeaves(n int) []ECPoint {
+	leaves := make([]ECPoint, n)
+	for i := range leaves {
+		leaves[i] = PedCommitR(TestCurve, big.NewInt(int64(i)), scalarFromSeed(fmt.Sprintf("tree leaf %d", i)))
+	}
+	return leaves
+}
+
+func TestCommitmentTree(t *testing.T) {
+	for _, n := range []int{1, 2, 7, 1024} {
+		leaves := treeLeaves(n)
+		tree, err := NewCommitmentTree(leaves)
+		if err != nil {
+			t.Fatalf("%d leaves: %v\n", n, err)
+		}
+		if tree.Size() != n || len(tree.Root()) != 32 {
+			t.Fatalf("tree of %d leaves has size %d and root %x\n", n, tree.Size(), tree.Root())
+		}
+		root := tree.Root()
+		for i, leaf := range leaves {
+			proof, err := tree.Prove(i)
+			if err != nil {
+				t.Fatalf("%d leaves: leaf %d has no proof: %v\n", n, i, err)
+			}
+			if !VerifyMembership(root, leaf, proof) {
+				t.Fatalf("%d leaves: leaf %d is not a member\n", n, i)
+			}
+			if n > 1 && VerifyMembership(root, leaves[(i+1)%n], proof) {
+				t.Fatalf("%d leaves: leaf %d is a member at index %d\n", n, (i+1)%n, i)
+			}
+			if n > 1 {
+				moved := *proof
+				moved.Index = (i + 1) % n
+				if VerifyMembership(root, leaf, &moved) {
+					t.Fatalf("%d leaves: leaf %d is a member at index %d\n", n, i, moved.Index)
+				}
+			}
+		}
+		for _, i := range []int{-1, n} {
+			if _, err := tree.Prove(i); !errors.Is(err, ErrInvalidInput) {
+				t.Fatalf("%d leaves: Prove(%d) gives %v\n", n, i, err)
+			}
+		}
+	}
+}
+
+func TestCommitmentTreeShape(t *testing.T) {
+	leaves := treeLeaves(3)
+	tree, err := NewCommitmentTree(leaves)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	// the odd leaf is carried up, not hashed with itself
+	l0, l1, l2 := merkleLeaf(leaves[0]), merkleLeaf(leaves[1]), merkleLeaf(leaves[2])
+	if !bytes.Equal(tree.Root(), merkleNode(merkleNode(l0, l1), l2)) {
+		t.Fatalf("tree of 3 leaves has root %x\n", tree.Root())
+	}
+	proof, _ := tree.Prove(2)
+	if len(proof.Siblings) != 1 || !bytes.Equal(proof.Siblings[0], merkleNode(l0, l1)) {
+		t.Fatalf("proof of the odd leaf has siblings %x\n", proof.Siblings)
+	}
+
+	// a single leaf is its own root, still with the leaf prefix
+	single, _ := NewCommitmentTree(leaves[:1])
+	if !bytes.Equal(single.Root(), l0) {
+		t.Fatalf("tree of 1 leaf has root %x\n", single.Root())
+	}
+}
+
+func TestCommitmentTreeStaleRoot(t *testing.T) {
+	leaves := treeLeaves(7)
+	old, _ := NewCommitmentTree(leaves)
+	oldProof, _ := old.Prove(3)
+
+	// one balance changed since the snapshot
+	changed := append([]ECPoint(nil), leaves...)
+	changed[5] = TestCurve.Add(changed[5], TestCurve.G)
+	tree, _ := NewCommitmentTree(changed)
+	proof, _ := tree.Prove(3)
+	if VerifyMembership(tree.Root(), leaves[3], oldProof) {
+		t.Fatalf("proof against a stale root verifies against the new one\n")
+	}
+	if VerifyMembership(old.Root(), leaves[3], proof) {
+		t.Fatalf("proof against the new root verifies against a stale one\n")
+	}
+
+	// an account added since the snapshot
+	grown, _ := NewCommitmentTree(append(leaves, treeLeaves(8)[7]))
+	if VerifyMembership(grown.Root(), leaves[3], oldProof) {
+		t.Fatalf("proof of a tree of 7 leaves verifies against a tree of 8\n")
+	}
+}
+
+func TestCommitmentTreeInvalid(t *testing.T) {
+	if _, err := NewCommitmentTree(nil); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("empty tree gives %v\n", err)
+	}
+	leaves := treeLeaves(4)
+	leaves[2] = ECPoint{big.NewInt(1), big.NewInt(1)}
+	if _, err := NewCommitmentTree(leaves); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("tree with a leaf off the curve gives %v\n", err)
+	}
+
+	leaves = treeLeaves(4)
+	tree, _ := NewCommitmentTree(leaves)
+	root := tree.Root()
+	proof, _ := tree.Prove(1)
+	bad := []*MerkleProof{
+		nil,
+		{Index: 1, Size: 0, Siblings: proof.Siblings},
+		{Index: 4, Size: 4, Siblings: proof.Siblings},
+		{Index: -1, Size: 4, Siblings: proof.Siblings},
+		{Index: 1, Size: 4, Siblings: proof.Siblings[:1]},
+		{Index: 1, Size: 4, Siblings: append(proof.Siblings, root)},
+		{Index: 1, Size: 4, Siblings: [][]byte{proof.Siblings[0][:31], proof.Siblings[1]}},
+	}
+	for i, p := range bad {
+		if VerifyMembership(root, leaves[1], p) {
+			t.Fatalf("bad proof %d verifies\n", i)
+		}
+	}
+	if VerifyMembership(root, ECPoint{big.NewInt(1), big.NewInt(1)}, proof) || VerifyMembership(root, Zero, proof) {
+		t.Fatalf("leaf off the curve is a member\n")
+	}
+}
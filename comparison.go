@@ -0,0 +1,168 @@
+package zksigma
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// ComparisonProof is a proof that commitments CMA = aG + raH and CMB = bG + rbH
+// hide values with a >= b. The difference D = CMA - CMB commits to a - b with
+// randomness ra - rb, and a BitRangeProof shows that a - b is in [0, 2^bits).
+//
+//  Public: generator points G and H, CMA, CMB, bits
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows a, b, ra, rb with a >= b
+//  D = CMA - CMB                       D = CMA - CMB
+//  BitRangeProof for D, a - b, ra - rb
+//
+//  BitRangeProof --------------------->
+//                                      BitRangeProof ?= true for D, bits
+//
+// The difference is only known mod N, so a and b have to be far below N, e.g.
+// in [0, 2^bits) themselves. Otherwise b > a could wrap around to a small a - b.
+type ComparisonProof struct {
+	Difference *BitRangeProof // a - b is in [0, 2^bits)
+}
+
+// NewComparisonProof generates a proof that CMA = a*G + ra*H and CMB = b*G +
+// rb*H commit to a >= b, with a - b < 2^bits. It fails if a < b.
+func NewComparisonProof(zkpcp ZKPCurveParams, CMA, CMB ECPoint, a, b, ra, rb *big.Int, bits int) (*ComparisonProof, error) {
+	return NewComparisonProofWithRand(zkpcp, entropy(), CMA, CMB, a, b, ra, rb, bits)
+}
+
+// NewComparisonProofWithRand is the same as NewComparisonProof, but reads its
+// random values from rnd instead of the EntropySource
+func NewComparisonProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	CMA, CMB ECPoint, a, b, ra, rb *big.Int, bits int) (*ComparisonProof, error) {
+
+	if err := zkpcp.checkScalars("ComparisonProve", "a, b, ra, rb", a, b, ra, rb); err != nil {
+		return nil, err
+	}
+	if !zkpcp.validPoints(CMA, CMB) {
+		return nil, curveError("ComparisonProve")
+	}
+	if !CMA.Equal(PedCommitR(zkpcp, a, ra)) || !CMB.Equal(PedCommitR(zkpcp, b, rb)) {
+		return nil, inputError("ComparisonProve", "values and randomness do not produce CMA and CMB")
+	}
+	if a.Cmp(b) < 0 {
+		return nil, inputError("ComparisonProve", "a is less than b")
+	}
+
+	N := zkpcp.C.Params().N
+	rd := new(big.Int).Sub(ra, rb)
+	rd.Mod(rd, N)
+
+	diff, err := NewBitRangeProofWithRand(zkpcp, rnd, zkpcp.Sub(CMA, CMB), new(big.Int).Sub(a, b), rd, bits)
+	if err != nil {
+		return nil, subProofError("ComparisonProve", "BitRangeProve within ComparisonProve failed to generate", err)
+	}
+	return &ComparisonProof{diff}, nil
+}
+
+// Verify checks if ComparisonProof proof shows that CMA and CMB commit to a >= b
+// with a - b in [0, 2^bits)
+func (proof *ComparisonProof) Verify(zkpcp ZKPCurveParams, CMA, CMB ECPoint, bits int) (bool, error) {
+	if proof == nil || proof.Difference == nil {
+		return false, malformedError("ComparisonProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(CMA, CMB) {
+		return false, curveError("ComparisonProof.Verify")
+	}
+
+	if _, err := proof.Difference.Verify(zkpcp, zkpcp.Sub(CMA, CMB), bits); err != nil {
+		return false, subProofError("ComparisonProof.Verify", "CMA - CMB is not in range", err)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are CMA, CMB and bits as int like for
+// Verify
+func (proof *ComparisonProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("ComparisonProof.VerifyArgs", args)
+	CMA, CMB, bits := a.point(), a.point(), a.int()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CMA, CMB, bits)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *ComparisonProof) String() string {
+	if proof == nil {
+		return "ComparisonProof(nil)"
+	}
+	return proofString("ComparisonProof", "Difference", proof.Difference)
+}
+
+// Bytes returns a byte slice with a serialized representation of
+// ComparisonProof proof, which is the one of its BitRangeProof
+func (proof *ComparisonProof) Bytes() []byte {
+	return proof.Difference.Bytes()
+}
+
+// Size returns the length of Bytes of ComparisonProof proof without serializing it
+func (proof *ComparisonProof) Size() int {
+	return proof.Difference.Size()
+}
+
+// Clone returns a deep copy of ComparisonProof proof that shares no big.Int with it
+func (proof *ComparisonProof) Clone() *ComparisonProof {
+	if proof == nil {
+		return nil
+	}
+	return &ComparisonProof{
+		Difference: proof.Difference.Clone(),
+	}
+}
+
+// Equal returns true if ComparisonProof proof and other have the same values in all
+// their fields, nested proofs included. It is not constant time.
+func (proof *ComparisonProof) Equal(other *ComparisonProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return proof.Difference.Equal(other.Difference)
+}
+
+// NewComparisonProofFromBytes returns a ComparisonProof generated from the
+// deserialization of byte slice b
+func NewComparisonProofFromBytes(b []byte) (*ComparisonProof, error) {
+	diff, err := NewBitRangeProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return &ComparisonProof{diff}, nil
+}
+
+type comparisonProofJSON struct {
+	Difference *BitRangeProof `json:"difference"`
+}
+
+// MarshalJSON encodes ComparisonProof proof as its BitRangeProof Difference
+func (proof *ComparisonProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(comparisonProofJSON{
+		Difference: proof.Difference,
+	})
+}
+
+// UnmarshalJSON decodes a ComparisonProof encoded with MarshalJSON. It fails
+// if a point is not on the curve, a scalar is not in [0, N) or a nested proof
+// is missing
+func (proof *ComparisonProof) UnmarshalJSON(b []byte) error {
+	var aux comparisonProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	if aux.Difference == nil {
+		return malformedError("UnmarshalJSON", "difference is missing")
+	}
+	p := ComparisonProof{Difference: aux.Difference}
+	*proof = p
+	return nil
+}
@@ -0,0 +1,124 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestComparisonProof(t *testing.T) {
+	bits := 8
+	max := new(big.Int).Lsh(big.NewInt(1), uint(bits)) // 2^bits
+
+	cases := []struct{ a, b *big.Int }{
+		{big.NewInt(30), big.NewInt(12)},
+		{big.NewInt(12), big.NewInt(12)},
+		{big.NewInt(0), big.NewInt(0)},
+		{new(big.Int).Sub(max, big.NewInt(1)), big.NewInt(0)},
+		{new(big.Int).Add(max, big.NewInt(4)), big.NewInt(5)},
+	}
+	for _, c := range cases {
+		CMA, ra, err := PedCommit(TestCurve, c.a)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		CMB, rb, err := PedCommit(TestCurve, c.b)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		proof, err := NewComparisonProof(TestCurve, CMA, CMB, c.a, c.b, ra, rb, bits)
+		if err != nil {
+			t.Fatalf("ComparisonProof failed to generate for %v >= %v: %v\n", c.a, c.b, err)
+		}
+		if ok, err := proof.Verify(TestCurve, CMA, CMB, bits); !ok || err != nil {
+			t.Fatalf("ComparisonProof failed to verify for %v >= %v: %v\n", c.a, c.b, err)
+		}
+		if ok, _ := proof.Verify(TestCurve, CMB, CMA, bits); ok && c.a.Cmp(c.b) != 0 {
+			t.Fatalf("ComparisonProof for %v >= %v verified with CMA and CMB swapped\n", c.a, c.b)
+		}
+
+		proof, err = NewComparisonProofFromBytes(proof.Bytes())
+		if err != nil {
+			t.Fatalf("ComparisonProof failed to deserialize: %v\n", err)
+		}
+		if ok, err := proof.Verify(TestCurve, CMA, CMB, bits); !ok || err != nil {
+			t.Fatalf("ComparisonProof failed to verify after deserialization: %v\n", err)
+		}
+	}
+}
+
+func TestComparisonProofEqualCommitments(t *testing.T) {
+	// with equal values and randomness CMA - CMB is the identity
+	v := big.NewInt(7)
+	r, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	CM := PedCommitR(TestCurve, v, r)
+	proof, err := NewComparisonProof(TestCurve, CM, CM, v, v, r, r, 4)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CM, CM, 4); !ok || err != nil {
+		t.Fatalf("ComparisonProof failed to verify for a commitment against itself: %v\n", err)
+	}
+}
+
+func TestComparisonProofFails(t *testing.T) {
+	bits := 8
+	max := new(big.Int).Lsh(big.NewInt(1), uint(bits)) // 2^bits
+
+	for _, c := range []struct{ a, b *big.Int }{
+		{big.NewInt(12), big.NewInt(30)},
+		{big.NewInt(0), big.NewInt(1)},
+		{max, big.NewInt(0)},
+		{new(big.Int).Add(max, big.NewInt(5)), big.NewInt(5)},
+	} {
+		CMA, ra, _ := PedCommit(TestCurve, c.a)
+		CMB, rb, _ := PedCommit(TestCurve, c.b)
+		if _, err := NewComparisonProof(TestCurve, CMA, CMB, c.a, c.b, ra, rb, bits); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("ComparisonProof for %v >= %v should not generate, got %v\n", c.a, c.b, err)
+		}
+	}
+
+	a, b := big.NewInt(30), big.NewInt(12)
+	CMA, ra, _ := PedCommit(TestCurve, a)
+	CMB, rb, _ := PedCommit(TestCurve, b)
+	if _, err := NewComparisonProof(TestCurve, CMA, CMB, a, b, rb, ra, bits); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("ComparisonProof generated for the wrong randomness: %v\n", err)
+	}
+
+	proof, err := NewComparisonProof(TestCurve, CMA, CMB, a, b, ra, rb, bits)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, _ := proof.Verify(TestCurve, CMA, CMB, bits-1); ok {
+		t.Fatalf("ComparisonProof verified for the wrong number of bits\n")
+	}
+	if ok, _ := proof.Verify(TestCurve, TestCurve.Add(CMA, TestCurve.G), CMB, bits); ok {
+		t.Fatalf("ComparisonProof verified for a different CMA\n")
+	}
+	var nilProof *ComparisonProof
+	if _, err := nilProof.Verify(TestCurve, CMA, CMB, bits); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("Verify of a nil ComparisonProof gives %v\n", err)
+	}
+}
+
+func BenchmarkComparisonProve_32(b *testing.B) {
+	va, vb := big.NewInt(123456789), big.NewInt(1234567)
+	CMA, ra, _ := PedCommit(TestCurve, va)
+	CMB, rb, _ := PedCommit(TestCurve, vb)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewComparisonProof(TestCurve, CMA, CMB, va, vb, ra, rb, 32)
+	}
+}
+
+func BenchmarkComparisonVerify_32(b *testing.B) {
+	va, vb := big.NewInt(123456789), big.NewInt(1234567)
+	CMA, ra, _ := PedCommit(TestCurve, va)
+	CMB, rb, _ := PedCommit(TestCurve, vb)
+	proof, _ := NewComparisonProof(TestCurve, CMA, CMB, va, vb, ra, rb, 32)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CMA, CMB, 32)
+	}
+}
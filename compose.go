@@ -0,0 +1,708 @@
+package zksigma
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+)
+
+// ComposedProof is a proof of several statements at once (AND-composition).
+// The commitments of all statements are computed first, then one challenge is
+// derived from all of them and every statement is answered with it, so the
+// proofs are bound to each other and can not be mixed with other proofs.
+//
+//  Public: generator points G and H, statements S_1 ... S_n
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  commitments T_i for every S_i
+//  c = HASH(S_1, T_1, ..., S_n, T_n)
+//  responses s_i for every S_i under c
+//
+//  c, (T_i, s_i) --------------------->
+//                                      c ?= HASH(S_1, T_1, ..., S_n, T_n)
+//                                      s_i ?= valid for S_i, T_i, c
+type ComposedProof struct {
+	Challenge *big.Int   // the challenge shared by all proofs
+	Proofs    []SubProof // one proof for every statement, all using Challenge
+}
+
+// SubProof is one of the proofs in a ComposedProof. It is an *ABCProof,
+// *ConsistencyProof, *DisjunctiveProof, *EquivalenceProof or *GSPFSProof
+// matching the type of its statement.
+type SubProof interface {
+	Bytes() []byte
+}
+
+// Statement is something that can be proved as part of a ComposedProof. The
+// statements are ABCStatement, ConsistencyStatement, DisjunctiveStatement,
+// EquivalenceStatement and GSPFSStatement, which only hold the public values
+// of a proof and are enough to verify it. To prove them add their witness
+// with WithWitness.
+type Statement interface {
+	// commit checks the witness and runs the commit phase of the statement
+	commit(zkpcp ZKPCurveParams, rnd io.Reader) (*sigmaProver, error)
+	// transcript returns what the statement and proof add to the challenge
+	transcript(zkpcp ZKPCurveParams, proof SubProof) ([]*Transcript, error)
+	// verify checks proof for the statement under the shared challenge c
+	verify(zkpcp ZKPCurveParams, proof SubProof, c *big.Int) error
+}
+
+// sigmaProver is a statement between the commit and the response phase
+type sigmaProver struct {
+	transcript []*Transcript
+	respond    func(c *big.Int) SubProof
+}
+
+// provableStatement is a Statement with the witness to prove it, as returned
+// by WithWitness. The witness is only reachable from commit, so printing the
+// statement does not show it.
+type provableStatement struct {
+	Statement
+	prove func(zkpcp ZKPCurveParams, rnd io.Reader) (*sigmaProver, error)
+}
+
+func (s provableStatement) commit(zkpcp ZKPCurveParams, rnd io.Reader) (*sigmaProver, error) {
+	return s.prove(zkpcp, rnd)
+}
+
+// String returns the public part of the statement, the witness is left out
+func (s provableStatement) String() string {
+	return fmt.Sprintf("%v with witness", s.Statement)
+}
+
+// noWitness is returned when a statement without witness is proved
+func noWitness(t string) error {
+	return inputError(t, "statement has no witness, add it with WithWitness")
+}
+
+// ABCStatement is the statement of an ABCProof, see NewABCProof. PubKey is the
+// auditor public key skH that CToken is checked against.
+type ABCStatement struct {
+	CM, CMTok ECPoint
+	PubKey    ECPoint
+}
+
+// ConsistencyStatement is the statement of a ConsistencyProof, see
+// NewConsistencyProof
+type ConsistencyStatement struct {
+	CM, CMTok, PubKey ECPoint
+}
+
+// DisjunctiveStatement is the statement of a DisjunctiveProof, see
+// NewDisjunctiveProof
+type DisjunctiveStatement struct {
+	Base1, Result1, Base2, Result2 ECPoint
+}
+
+// EquivalenceStatement is the statement of an EquivalenceProof, see
+// NewEquivalenceProof
+type EquivalenceStatement struct {
+	Base1, Result1, Base2, Result2 ECPoint
+}
+
+// GSPFSStatement is the statement of a GSPFSProof, see NewGSPFSProofBase
+type GSPFSStatement struct {
+	Base, A ECPoint
+}
+
+// NewComposedProof generates a ComposedProof for all statements
+func NewComposedProof(zkpcp ZKPCurveParams, statements ...Statement) (*ComposedProof, error) {
+	return NewComposedProofWithRand(zkpcp, entropy(), statements...)
+}
+
+// NewComposedProofWithRand is the same as NewComposedProof, but reads its
+// random values from rnd instead of the EntropySource
+func NewComposedProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader, statements ...Statement) (*ComposedProof, error) {
+	if len(statements) == 0 {
+		return nil, inputError("ComposedProve", "no statements to prove")
+	}
+
+	provers := make([]*sigmaProver, len(statements))
+	transcripts := make([][]*Transcript, len(statements))
+	for i, s := range statements {
+		p, err := s.commit(zkpcp, rnd)
+		if err != nil {
+			return nil, subProofError("ComposedProve", fmt.Sprintf("statement %d: %v", i, err), err)
+		}
+		provers[i] = p
+		transcripts[i] = p.transcript
+	}
+
+	c := composedChallenge(zkpcp, transcripts)
+
+	proof := &ComposedProof{Challenge: c, Proofs: make([]SubProof, len(statements))}
+	for i, p := range provers {
+		proof.Proofs[i] = p.respond(c)
+	}
+	return proof, nil
+}
+
+// Verify checks if ComposedProof proof is valid for all statements, which have
+// to be given in the same order as they were proved
+func (proof *ComposedProof) Verify(zkpcp ZKPCurveParams, statements ...Statement) (bool, error) {
+	if proof == nil {
+		return false, malformedError("ComposedProof.Verify", "passed proof is nil")
+	}
+	if len(statements) == 0 || len(proof.Proofs) != len(statements) {
+		return false, malformedError("ComposedProof.Verify",
+			fmt.Sprintf("proof has %d proofs for %d statements", len(proof.Proofs), len(statements)))
+	}
+	if err := zkpcp.canonicalScalars("ComposedProof.Verify", "Challenge", proof.Challenge); err != nil {
+		return false, err
+	}
+
+	transcripts := make([][]*Transcript, len(statements))
+	for i, s := range statements {
+		t, err := s.transcript(zkpcp, proof.Proofs[i])
+		if err != nil {
+			return false, subProofError("ComposedProof.Verify", fmt.Sprintf("statement %d: %v", i, err), err)
+		}
+		transcripts[i] = t
+	}
+
+	c := composedChallenge(zkpcp, transcripts)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("ComposedProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	for i, s := range statements {
+		if err := s.verify(zkpcp, proof.Proofs[i], c); err != nil {
+			return false, subProofError("ComposedProof.Verify", fmt.Sprintf("statement %d: %v", i, err), err)
+		}
+	}
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are the Statements like for Verify
+func (proof *ComposedProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("ComposedProof.VerifyArgs", args)
+	statements := a.statements()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, statements...)
+}
+
+// String returns a labeled dump of proof and its sub proofs for debugging
+func (proof *ComposedProof) String() string {
+	if proof == nil {
+		return "ComposedProof(nil)"
+	}
+	fields := []interface{}{"Challenge", proof.Challenge}
+	for i, p := range proof.Proofs {
+		var v interface{} = p
+		if _, ok := p.(fmt.Stringer); !ok && p != nil {
+			v = hex.EncodeToString(p.Bytes())
+		}
+		fields = append(fields, fmt.Sprintf("Proofs[%d]", i), v)
+	}
+	return proofString("ComposedProof", fields...)
+}
+
+// Clone returns a deep copy of ComposedProof proof that shares no big.Int
+// with it, its sub proofs included
+func (proof *ComposedProof) Clone() *ComposedProof {
+	if proof == nil {
+		return nil
+	}
+	c := &ComposedProof{Challenge: cloneScalar(proof.Challenge)}
+	if proof.Proofs != nil {
+		c.Proofs = make([]SubProof, len(proof.Proofs))
+		for i, p := range proof.Proofs {
+			c.Proofs[i] = cloneSubProof(p)
+		}
+	}
+	return c
+}
+
+// Equal returns true if ComposedProof proof and other have the same challenge
+// and equal sub proofs of the same types. It is not constant time.
+func (proof *ComposedProof) Equal(other *ComposedProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	if !sameScalar(proof.Challenge, other.Challenge) || len(proof.Proofs) != len(other.Proofs) {
+		return false
+	}
+	for i, p := range proof.Proofs {
+		if !sameSubProof(p, other.Proofs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// cloneSubProof returns a deep copy of p, which keeps its type even if it is
+// a nil pointer. Sub proofs of an unknown type are returned as they are.
+func cloneSubProof(p SubProof) SubProof {
+	switch sub := p.(type) {
+	case *ABCProof:
+		return sub.Clone()
+	case *ConsistencyProof:
+		return sub.Clone()
+	case *DisjunctiveProof:
+		return sub.Clone()
+	case *EquivalenceProof:
+		return sub.Clone()
+	case *GSPFSProof:
+		return sub.Clone()
+	}
+	return p
+}
+
+// sameSubProof compares sub proofs p and p2 with the Equal of their type
+func sameSubProof(p, p2 SubProof) bool {
+	switch sub := p.(type) {
+	case *ABCProof:
+		sub2, ok := p2.(*ABCProof)
+		return ok && sub.Equal(sub2)
+	case *ConsistencyProof:
+		sub2, ok := p2.(*ConsistencyProof)
+		return ok && sub.Equal(sub2)
+	case *DisjunctiveProof:
+		sub2, ok := p2.(*DisjunctiveProof)
+		return ok && sub.Equal(sub2)
+	case *EquivalenceProof:
+		sub2, ok := p2.(*EquivalenceProof)
+		return ok && sub.Equal(sub2)
+	case *GSPFSProof:
+		sub2, ok := p2.(*GSPFSProof)
+		return ok && sub.Equal(sub2)
+	}
+	return p == p2
+}
+
+// newSubProof returns a new sub proof of the type named t, or nil for a name
+// that is not one of a SubProof
+func newSubProof(t string) SubProof {
+	switch t {
+	case "ABCProof":
+		return new(ABCProof)
+	case "ConsistencyProof":
+		return new(ConsistencyProof)
+	case "DisjunctiveProof":
+		return new(DisjunctiveProof)
+	case "EquivalenceProof":
+		return new(EquivalenceProof)
+	case "GSPFSProof":
+		return new(GSPFSProof)
+	}
+	return nil
+}
+
+type subProofJSON struct {
+	Type  string          `json:"type"`
+	Proof json.RawMessage `json:"proof"`
+}
+
+type composedProofJSON struct {
+	Challenge string         `json:"challenge"`
+	Proofs    []subProofJSON `json:"proofs"`
+}
+
+// MarshalJSON encodes ComposedProof proof with its challenge as big-endian
+// hex and every sub proof with its own MarshalJSON, next to the name of its
+// type. It fails for a nil sub proof or one of an unknown type.
+func (proof *ComposedProof) MarshalJSON() ([]byte, error) {
+	proofs := make([]subProofJSON, len(proof.Proofs))
+	for i, p := range proof.Proofs {
+		v := reflect.ValueOf(p)
+		if !v.IsValid() || v.Kind() != reflect.Ptr || v.IsNil() || newSubProof(v.Elem().Type().Name()) == nil {
+			return nil, inputError("MarshalJSON", fmt.Sprintf("Proofs[%d] is nil or not a SubProof", i))
+		}
+		b, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		proofs[i] = subProofJSON{Type: v.Elem().Type().Name(), Proof: b}
+	}
+	return json.Marshal(composedProofJSON{
+		Challenge: scalarToHex(proof.Challenge),
+		Proofs:    proofs,
+	})
+}
+
+// UnmarshalJSON decodes a ComposedProof encoded with MarshalJSON. It fails if
+// a sub proof is missing or of an unknown type, or if it fails to decode.
+func (proof *ComposedProof) UnmarshalJSON(b []byte) error {
+	var aux composedProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := ComposedProof{Proofs: make([]SubProof, len(aux.Proofs))}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	for i, sub := range aux.Proofs {
+		p.Proofs[i] = newSubProof(sub.Type)
+		if p.Proofs[i] == nil {
+			return malformedError("UnmarshalJSON", fmt.Sprintf("proofs[%d] has unknown type %q", i, sub.Type))
+		}
+		if len(sub.Proof) == 0 || string(sub.Proof) == "null" {
+			return malformedError("UnmarshalJSON", fmt.Sprintf("proofs[%d] is missing", i))
+		}
+		if err := json.Unmarshal(sub.Proof, p.Proofs[i]); err != nil {
+			return err
+		}
+	}
+	*proof = p
+	return nil
+}
+
+// composedChallenge hashes the transcripts of all statements into one
+// Transcript. The first transcript of a statement names its type.
+func composedChallenge(zkpcp ZKPCurveParams, transcripts [][]*Transcript) *big.Int {
+	if transcripts[0][0].h == nil {
+		return legacyComposedChallenge(zkpcp, transcripts)
+	}
+	t := newTranscript(zkpcp, "ComposedProof", false)
+	for _, ts := range transcripts {
+		t.AppendMessage("statement", []byte(ts[0].domain))
+		for _, sub := range ts {
+			t.appendTranscript(sub.domain, sub)
+		}
+	}
+	return t.ChallengeScalar("c")
+}
+
+// legacyComposedChallenge is composedChallenge for LegacyChallenges. The parts
+// of every statement follow its type and are prefixed with their length, so
+// parts of different statements can not be shifted into each other.
+func legacyComposedChallenge(zkpcp ZKPCurveParams, transcripts [][]*Transcript) *big.Int {
+	arr := [][]byte{[]byte("ComposedProof")}
+	var n [4]byte
+	for _, ts := range transcripts {
+		parts := [][]byte{[]byte(ts[0].domain)}
+		for _, sub := range ts {
+			parts = append(parts, sub.legacy...)
+		}
+		binary.BigEndian.PutUint32(n[:], uint32(len(parts)))
+		arr = append(arr, append([]byte{}, n[:]...))
+		for _, part := range parts {
+			binary.BigEndian.PutUint32(n[:], uint32(len(part)))
+			arr = append(arr, append(append([]byte{}, n[:]...), part...))
+		}
+	}
+	return GenerateChallenge(zkpcp, arr...)
+}
+
+// checkSharedChallenge makes sure a sub proof was answered with the shared
+// challenge c
+func checkSharedChallenge(proofC, c *big.Int) error {
+	if proofC == nil || !scalarsEqual(proofC, c) {
+		return malformedError("ComposedProof.Verify", "proof does not use the shared challenge")
+	}
+	return nil
+}
+
+// wrongProofType is returned when the proof for a statement has the wrong type
+func wrongProofType(proof SubProof, t string) error {
+	return malformedError("ComposedProof.Verify", fmt.Sprintf("expected %s, got %T", t, proof))
+}
+
+// ========== ABCStatement ==========
+
+// WithWitness returns statement s with witness w to prove it on option like
+// NewABCProofFromStatement does
+func (s ABCStatement) WithWitness(w ABCWitness, option Side) Statement {
+	return provableStatement{s, func(zkpcp ZKPCurveParams, rnd io.Reader) (*sigmaProver, error) {
+		return s.commitWith(zkpcp, rnd, w, option)
+	}}
+}
+
+func (s ABCStatement) commit(zkpcp ZKPCurveParams, rnd io.Reader) (*sigmaProver, error) {
+	return nil, noWitness("ABCProve")
+}
+
+func (s ABCStatement) commitWith(zkpcp ZKPCurveParams, rnd io.Reader, w ABCWitness, option Side) (*sigmaProver, error) {
+	if err := s.checkKey(zkpcp, w); err != nil {
+		return nil, err
+	}
+	p, err := abcCommit(zkpcp, rnd, s.CM, s.CMTok, w.Value, w.SK, option)
+	if err != nil {
+		return nil, err
+	}
+	djT1, djT2 := p.disjuncAC.commitments()
+	return &sigmaProver{
+		transcript: s.transcriptFor(zkpcp, p.B, p.C, p.T1, p.T2, p.T3, p.CToken, p.PK,
+			djT1, djT2, p.cTokenProof.T1, p.cTokenProof.T2),
+		respond: func(c *big.Int) SubProof {
+			return p.respond(zkpcp, c, c, c)
+		},
+	}, nil
+}
+
+func (s ABCStatement) transcriptFor(zkpcp ZKPCurveParams, B, C, T1, T2, T3, CToken, PK, djT1, djT2, ctT1, ctT2 ECPoint) []*Transcript {
+	return []*Transcript{
+		abcTranscript(zkpcp, s.CM, s.CMTok, B, C, T1, T2, CToken, T3, nil, nil),
+		disjunctiveTranscript(zkpcp, s.CM, s.CMTok, zkpcp.H, zkpcp.Sub(C, zkpcp.G), djT1, djT2, nil, nil),
+		consistencyTranscript(zkpcp, C, CToken, PK, ctT1, ctT2, nil, nil),
+	}
+}
+
+func (s ABCStatement) transcript(zkpcp ZKPCurveParams, proof SubProof) ([]*Transcript, error) {
+	p, ok := proof.(*ABCProof)
+	if !ok || p == nil || p.disjuncAC == nil || p.cTokenProof == nil {
+		return nil, wrongProofType(proof, "*ABCProof")
+	}
+	// a ComposedProof only has ABCProofs for sk
+	if p.Delegated {
+		return nil, malformedError("ABCVerify", "Delegated ABCProof in a ComposedProof")
+	}
+	if !zkpcp.validPoints(s.CM, s.CMTok, s.PubKey) ||
+		!zkpcp.onCurve(p.B, p.C, p.T1, p.T2, p.T3, p.CToken, p.disjuncAC.T1, p.disjuncAC.T2, p.cTokenProof.T1, p.cTokenProof.T2) {
+		return nil, curveError("ABCVerify")
+	}
+	if err := zkpcp.canonicalScalars("ABCVerify", "Challenge, j, k, l",
+		p.Challenge, p.j, p.k, p.l); err != nil {
+		return nil, err
+	}
+	if err := zkpcp.canonicalScalars("DisjunctiveProof.Verify", "C, C1, C2, S1, S2",
+		p.disjuncAC.C, p.disjuncAC.C1, p.disjuncAC.C2, p.disjuncAC.S1, p.disjuncAC.S2); err != nil {
+		return nil, err
+	}
+	if err := zkpcp.canonicalScalars("ConsistencyProof.Verify", "Challenge, S1, S2",
+		p.cTokenProof.Challenge, p.cTokenProof.S1, p.cTokenProof.S2); err != nil {
+		return nil, err
+	}
+	return s.transcriptFor(zkpcp, p.B, p.C, p.T1, p.T2, p.T3, p.CToken, s.PubKey,
+		p.disjuncAC.T1, p.disjuncAC.T2, p.cTokenProof.T1, p.cTokenProof.T2), nil
+}
+
+func (s ABCStatement) verify(zkpcp ZKPCurveParams, proof SubProof, c *big.Int) error {
+	p := proof.(*ABCProof)
+	if err := checkSharedChallenge(p.Challenge, c); err != nil {
+		return err
+	}
+	if err := checkSharedChallenge(p.disjuncAC.C, c); err != nil {
+		return err
+	}
+	if err := checkSharedChallenge(p.cTokenProof.Challenge, c); err != nil {
+		return err
+	}
+	if err := p.disjuncAC.verifyResponses(zkpcp, s.CM, s.CMTok, zkpcp.H, zkpcp.Sub(p.C, zkpcp.G), nil); err != nil {
+		return err
+	}
+	if err := p.cTokenProof.verifyResponses(zkpcp, p.C, p.CToken, s.PubKey, nil); err != nil {
+		return err
+	}
+	return p.verifyResponses(zkpcp, s.CM, s.CMTok, nil)
+}
+
+// ========== ConsistencyStatement ==========
+
+// WithWitness returns statement s with witness w to prove it
+func (s ConsistencyStatement) WithWitness(w ConsistencyWitness) Statement {
+	return provableStatement{s, func(zkpcp ZKPCurveParams, rnd io.Reader) (*sigmaProver, error) {
+		return s.commitWith(zkpcp, rnd, w)
+	}}
+}
+
+func (s ConsistencyStatement) commit(zkpcp ZKPCurveParams, rnd io.Reader) (*sigmaProver, error) {
+	return nil, noWitness("ConsistencyProve")
+}
+
+func (s ConsistencyStatement) commitWith(zkpcp ZKPCurveParams, rnd io.Reader, w ConsistencyWitness) (*sigmaProver, error) {
+	if !zkpcp.validPoints(s.CM, s.CMTok, s.PubKey) {
+		return nil, curveError("ConsistencyProve")
+	}
+	p, err := consistencyCommit(zkpcp, rnd, s.CM, s.CMTok, s.PubKey, w.Value, w.Randomness)
+	if err != nil {
+		return nil, err
+	}
+	return &sigmaProver{
+		transcript: []*Transcript{consistencyTranscript(zkpcp, s.CM, s.CMTok, s.PubKey, p.T1, p.T2, nil, nil)},
+		respond: func(c *big.Int) SubProof {
+			return p.respond(zkpcp, c)
+		},
+	}, nil
+}
+
+func (s ConsistencyStatement) transcript(zkpcp ZKPCurveParams, proof SubProof) ([]*Transcript, error) {
+	p, ok := proof.(*ConsistencyProof)
+	if !ok || p == nil {
+		return nil, wrongProofType(proof, "*ConsistencyProof")
+	}
+	if !zkpcp.validPoints(s.CM, s.CMTok, s.PubKey) || !zkpcp.onCurve(p.T1, p.T2) {
+		return nil, curveError("ConsistencyProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("ConsistencyProof.Verify", "Challenge, S1, S2", p.Challenge, p.S1, p.S2); err != nil {
+		return nil, err
+	}
+	return []*Transcript{consistencyTranscript(zkpcp, s.CM, s.CMTok, s.PubKey, p.T1, p.T2, nil, nil)}, nil
+}
+
+func (s ConsistencyStatement) verify(zkpcp ZKPCurveParams, proof SubProof, c *big.Int) error {
+	p := proof.(*ConsistencyProof)
+	if err := checkSharedChallenge(p.Challenge, c); err != nil {
+		return err
+	}
+	return p.verifyResponses(zkpcp, s.CM, s.CMTok, s.PubKey, nil)
+}
+
+// ========== DisjunctiveStatement ==========
+
+// WithWitness returns statement s with witness w to prove it on option like
+// NewDisjunctiveProofFromStatement does
+func (s DisjunctiveStatement) WithWitness(w DisjunctiveWitness, option Side) Statement {
+	return provableStatement{s, func(zkpcp ZKPCurveParams, rnd io.Reader) (*sigmaProver, error) {
+		return s.commitWith(zkpcp, rnd, w, option)
+	}}
+}
+
+func (s DisjunctiveStatement) commit(zkpcp ZKPCurveParams, rnd io.Reader) (*sigmaProver, error) {
+	return nil, noWitness("DisjunctiveProve")
+}
+
+func (s DisjunctiveStatement) commitWith(zkpcp ZKPCurveParams, rnd io.Reader, w DisjunctiveWitness, option Side) (*sigmaProver, error) {
+	if !zkpcp.validPoints(s.Base1, s.Result1, s.Base2, s.Result2) {
+		return nil, curveError("DisjunctiveProve")
+	}
+	p, err := disjunctiveCommit(zkpcp, rnd, s.Base1, s.Result1, s.Base2, s.Result2, w.X, option)
+	if err != nil {
+		return nil, err
+	}
+	T1, T2 := p.commitments()
+	return &sigmaProver{
+		transcript: []*Transcript{disjunctiveTranscript(zkpcp, s.Base1, s.Result1, s.Base2, s.Result2, T1, T2, nil, nil)},
+		respond: func(c *big.Int) SubProof {
+			return p.respond(zkpcp, c)
+		},
+	}, nil
+}
+
+func (s DisjunctiveStatement) transcript(zkpcp ZKPCurveParams, proof SubProof) ([]*Transcript, error) {
+	p, ok := proof.(*DisjunctiveProof)
+	if !ok || p == nil {
+		return nil, wrongProofType(proof, "*DisjunctiveProof")
+	}
+	if !zkpcp.validPoints(s.Base1, s.Result1, s.Base2, s.Result2) || !zkpcp.onCurve(p.T1, p.T2) {
+		return nil, curveError("DisjunctiveProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("DisjunctiveProof.Verify", "C, C1, C2, S1, S2", p.C, p.C1, p.C2, p.S1, p.S2); err != nil {
+		return nil, err
+	}
+	return []*Transcript{disjunctiveTranscript(zkpcp, s.Base1, s.Result1, s.Base2, s.Result2, p.T1, p.T2, nil, nil)}, nil
+}
+
+func (s DisjunctiveStatement) verify(zkpcp ZKPCurveParams, proof SubProof, c *big.Int) error {
+	p := proof.(*DisjunctiveProof)
+	if err := checkSharedChallenge(p.C, c); err != nil {
+		return err
+	}
+	return p.verifyResponses(zkpcp, s.Base1, s.Result1, s.Base2, s.Result2, nil)
+}
+
+// ========== EquivalenceStatement ==========
+
+// WithWitness returns statement s with witness w to prove it
+func (s EquivalenceStatement) WithWitness(w EquivalenceWitness) Statement {
+	return provableStatement{s, func(zkpcp ZKPCurveParams, rnd io.Reader) (*sigmaProver, error) {
+		return s.commitWith(zkpcp, rnd, w)
+	}}
+}
+
+func (s EquivalenceStatement) commit(zkpcp ZKPCurveParams, rnd io.Reader) (*sigmaProver, error) {
+	return nil, noWitness("EquivalenceProve")
+}
+
+func (s EquivalenceStatement) commitWith(zkpcp ZKPCurveParams, rnd io.Reader, w EquivalenceWitness) (*sigmaProver, error) {
+	if !zkpcp.validPoints(s.Base1, s.Result1, s.Base2, s.Result2) {
+		return nil, curveError("EquivalenceProve")
+	}
+	p, err := equivalenceCommit(zkpcp, rnd, s.Base1, s.Result1, s.Base2, s.Result2, w.X)
+	if err != nil {
+		return nil, err
+	}
+	return &sigmaProver{
+		transcript: []*Transcript{equivalenceTranscript(zkpcp, s.Base1, s.Result1, s.Base2, s.Result2, p.UG, p.UH, nil)},
+		respond: func(c *big.Int) SubProof {
+			return p.respond(zkpcp, c)
+		},
+	}, nil
+}
+
+func (s EquivalenceStatement) transcript(zkpcp ZKPCurveParams, proof SubProof) ([]*Transcript, error) {
+	p, ok := proof.(*EquivalenceProof)
+	if !ok || p == nil {
+		return nil, wrongProofType(proof, "*EquivalenceProof")
+	}
+	if !zkpcp.validPoints(s.Base1, s.Result1, s.Base2, s.Result2) || !zkpcp.onCurve(p.UG, p.UH) {
+		return nil, curveError("EquivalenceVerify")
+	}
+	if err := zkpcp.canonicalScalars("EquivalenceVerify", "Challenge, HiddenValue", p.Challenge, p.HiddenValue); err != nil {
+		return nil, err
+	}
+	return []*Transcript{equivalenceTranscript(zkpcp, s.Base1, s.Result1, s.Base2, s.Result2, p.UG, p.UH, nil)}, nil
+}
+
+func (s EquivalenceStatement) verify(zkpcp ZKPCurveParams, proof SubProof, c *big.Int) error {
+	p := proof.(*EquivalenceProof)
+	if err := checkSharedChallenge(p.Challenge, c); err != nil {
+		return err
+	}
+	return p.verifyResponses(zkpcp, s.Base1, s.Result1, s.Base2, s.Result2)
+}
+
+// ========== GSPFSStatement ==========
+
+// WithWitness returns statement s with witness w to prove it
+func (s GSPFSStatement) WithWitness(w GSPFSWitness) Statement {
+	return provableStatement{s, func(zkpcp ZKPCurveParams, rnd io.Reader) (*sigmaProver, error) {
+		return s.commitWith(zkpcp, rnd, w)
+	}}
+}
+
+func (s GSPFSStatement) commit(zkpcp ZKPCurveParams, rnd io.Reader) (*sigmaProver, error) {
+	return nil, noWitness("GSPFSProve")
+}
+
+func (s GSPFSStatement) commitWith(zkpcp ZKPCurveParams, rnd io.Reader, w GSPFSWitness) (*sigmaProver, error) {
+	p, err := gspfsCommit(zkpcp, rnd, s.Base, s.A, w.X)
+	if err != nil {
+		return nil, err
+	}
+	return &sigmaProver{
+		transcript: s.transcriptFor(zkpcp, p.uG),
+		respond: func(c *big.Int) SubProof {
+			return p.respond(zkpcp, c)
+		},
+	}, nil
+}
+
+// transcriptFor also binds the base into a transcript of its own, which
+// composed proofs had before gspfsTranscript hashed the base and keep, so their
+// LegacyChallenges stay the same
+func (s GSPFSStatement) transcriptFor(zkpcp ZKPCurveParams, RandCommit ECPoint) []*Transcript {
+	base := NewTranscript(zkpcp, "GSPFSProof")
+	base.AppendPoint("Base", s.Base)
+	return []*Transcript{base, gspfsTranscript(zkpcp, s.Base, s.A, RandCommit, nil)}
+}
+
+func (s GSPFSStatement) transcript(zkpcp ZKPCurveParams, proof SubProof) ([]*Transcript, error) {
+	p, ok := proof.(*GSPFSProof)
+	if !ok || p == nil {
+		return nil, wrongProofType(proof, "*GSPFSProof")
+	}
+	if !zkpcp.validPoints(s.A) || !zkpcp.onCurve(s.Base, p.Base, p.RandCommit) || !p.Base.Equal(s.Base) {
+		return nil, newError(ErrCodeNotOnCurve, "GSPFSProof.Verify", "point not on curve or wrong base")
+	}
+	if err := zkpcp.canonicalScalars("GSPFSProof.Verify", "HiddenValue, Challenge", p.HiddenValue, p.Challenge); err != nil {
+		return nil, err
+	}
+	return s.transcriptFor(zkpcp, p.RandCommit), nil
+}
+
+func (s GSPFSStatement) verify(zkpcp ZKPCurveParams, proof SubProof, c *big.Int) error {
+	p := proof.(*GSPFSProof)
+	if err := checkSharedChallenge(p.Challenge, c); err != nil {
+		return err
+	}
+	return p.verifyResponses(zkpcp, s.A)
+}
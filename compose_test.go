@@ -0,0 +1,168 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// composedStatements returns the statements of a transaction: the ABCProof,
+// ConsistencyProof and EquivalenceProof that are usually proved separately,
+// plus a DisjunctiveProof and a GSPFSProof. provable are the same statements
+// with their witnesses.
+func composedStatements(tb testing.TB) (statements, provable []Statement) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(1000)
+	CM, r, err := PedCommit(TestCurve, value)
+	if err != nil {
+		tb.Fatalf("%v\n", err)
+	}
+	CMTok := TestCurve.Mult(PK, r)
+	x := big.NewInt(77)
+
+	abc := ABCStatement{CM: CM, CMTok: CMTok, PubKey: PK}
+	con := ConsistencyStatement{CM: CM, CMTok: CMTok, PubKey: PK}
+	eq := EquivalenceStatement{TestCurve.G, TestCurve.Mult(TestCurve.G, x), TestCurve.H, TestCurve.Mult(TestCurve.H, x)}
+	dj := DisjunctiveStatement{TestCurve.G, TestCurve.Mult(TestCurve.G, x), TestCurve.H, CM}
+	gs := GSPFSStatement{TestCurve.H, TestCurve.Mult(TestCurve.H, x)}
+
+	statements = []Statement{abc, con, eq, dj, gs}
+	provable = []Statement{
+		abc.WithWitness(ABCWitness{Value: value, SK: sk}, Right),
+		con.WithWitness(ConsistencyWitness{Value: value, Randomness: r}),
+		eq.WithWitness(EquivalenceWitness{x}),
+		dj.WithWitness(DisjunctiveWitness{x}, Left),
+		gs.WithWitness(GSPFSWitness{x}),
+	}
+	return statements, provable
+}
+
+func TestComposedProof(t *testing.T) {
+	statements, provable := composedStatements(t)
+	proof, err := NewComposedProof(TestCurve, provable...)
+	if err != nil {
+		t.Fatalf("ComposedProof failed to generate: %v\n", err)
+	}
+
+	ok, err := proof.Verify(TestCurve, statements...)
+	if !ok || err != nil {
+		t.Fatalf("ComposedProof failed to verify: %v\n", err)
+	}
+
+	// The statements with witnesses verify like the public ones
+	ok, err = proof.Verify(TestCurve, provable...)
+	if !ok || err != nil {
+		t.Fatalf("ComposedProof failed to verify with witnesses: %v\n", err)
+	}
+
+	// Every sub proof uses the shared challenge, not its own
+	abc := proof.Proofs[0].(*ABCProof)
+	if ok, _ := abc.VerifyStatement(TestCurve, statements[0].(ABCStatement)); ok {
+		t.Fatalf("sub proof of a ComposedProof should not verify on its own\n")
+	}
+	if abc.Challenge.Cmp(proof.Challenge) != 0 || abc.disjuncAC.C.Cmp(proof.Challenge) != 0 {
+		t.Fatalf("sub proof does not use the shared challenge\n")
+	}
+}
+
+func TestComposedProofWitness(t *testing.T) {
+	statements, provable := composedStatements(t)
+
+	// A statement without its witness can not be proved
+	for ii := range statements {
+		withoutOne := append([]Statement{}, provable...)
+		withoutOne[ii] = statements[ii]
+		if _, err := NewComposedProof(TestCurve, withoutOne...); !errors.Is(err, ErrInvalidInput) ||
+			!strings.Contains(err.Error(), "no witness") {
+			t.Fatalf("ComposedProof for statement %d without witness gives %v\n", ii, err)
+		}
+	}
+
+	// The ABC witness has to hold the key of PubKey
+	abc := statements[0].(ABCStatement)
+	other, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	wrongKey := abc.WithWitness(ABCWitness{Value: big.NewInt(1000), SK: other}, Right)
+	if _, err := NewComposedProof(TestCurve, wrongKey); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("ComposedProof for a witness with the wrong key gives %v\n", err)
+	}
+
+	// Printing a statement with its witness does not show the witness
+	x := big.NewInt(0x5ec7e7)
+	gs := GSPFSStatement{TestCurve.G, TestCurve.Mult(TestCurve.G, x)}
+	for _, format := range []string{"%v", "%+v", "%s"} {
+		if out := fmt.Sprintf(format, gs.WithWitness(GSPFSWitness{x})); strings.Contains(out, "5ec7e7") ||
+			strings.Contains(out, x.String()) || !strings.Contains(out, "with witness") {
+			t.Fatalf("statement with witness printed with %s shows %s\n", format, out)
+		}
+	}
+}
+
+func TestComposedProofTampered(t *testing.T) {
+	statements, provable := composedStatements(t)
+	proof, err := NewComposedProof(TestCurve, provable...)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	if ok, _ := proof.Verify(TestCurve, statements[:4]...); ok {
+		t.Fatalf("ComposedProof verified with a statement missing\n")
+	}
+
+	swapped := append([]Statement{}, statements...)
+	swapped[1], swapped[2] = swapped[2], swapped[1]
+	if ok, err := proof.Verify(TestCurve, swapped...); ok || !strings.Contains(err.Error(), "expected") {
+		t.Fatalf("ComposedProof verified with statements in the wrong order: %v\n", err)
+	}
+
+	changed := append([]Statement{}, statements...)
+	gs := changed[4].(GSPFSStatement)
+	gs.A = TestCurve.Add(gs.A, TestCurve.H)
+	changed[4] = gs
+	if ok, _ := proof.Verify(TestCurve, changed...); ok {
+		t.Fatalf("ComposedProof verified for a different statement\n")
+	}
+
+	// A sub proof from another ComposedProof for the same statements can not
+	// be mixed in
+	other, err := NewComposedProof(TestCurve, provable...)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	mixed := &ComposedProof{proof.Challenge, append([]SubProof{}, proof.Proofs...)}
+	mixed.Proofs[2] = other.Proofs[2]
+	if ok, _ := mixed.Verify(TestCurve, statements...); ok {
+		t.Fatalf("ComposedProof verified with a sub proof of another ComposedProof\n")
+	}
+
+	// A bad witness fails at proving time
+	bad := append([]Statement{}, provable...)
+	bad[2] = statements[2].(EquivalenceStatement).WithWitness(EquivalenceWitness{big.NewInt(78)})
+	if _, err := NewComposedProof(TestCurve, bad...); err == nil {
+		t.Fatalf("ComposedProof generated for a false statement\n")
+	}
+
+	if _, err := NewComposedProof(TestCurve); err == nil {
+		t.Fatalf("ComposedProof generated without statements\n")
+	}
+}
+
+func BenchmarkComposedProve(b *testing.B) {
+	_, provable := composedStatements(b)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewComposedProof(TestCurve, provable...)
+	}
+}
+
+func BenchmarkComposedVerify(b *testing.B) {
+	statements, provable := composedStatements(b)
+	proof, _ := NewComposedProof(TestCurve, provable...)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, statements...)
+	}
+}
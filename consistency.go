@@ -2,8 +2,9 @@ package zksigma
 
 import (
 	"bytes"
-	"crypto/rand"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/big"
 )
 
@@ -44,116 +45,329 @@ type ConsistencyProof struct {
 // and CMTok(=r(sk*H)) are the same.
 func NewConsistencyProof(zkpcp ZKPCurveParams,
 	CM, CMTok, PubKey ECPoint, value, randomness *big.Int) (*ConsistencyProof, error) {
+	return NewConsistencyProofWithRand(zkpcp, entropy(), CM, CMTok, PubKey, value, randomness)
+}
+
+// NewConsistencyProofWithRand is the same as NewConsistencyProof, but reads its random
+// values from rnd instead of the EntropySource
+func NewConsistencyProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	CM, CMTok, PubKey ECPoint, value, randomness *big.Int) (*ConsistencyProof, error) {
+	return newConsistencyProof(zkpcp, rnd, CM, CMTok, PubKey, value, randomness, nil)
+}
+
+// NewConsistencyProofWithMessage is the same as NewConsistencyProof, but binds
+// the proof to the application message msg like NewABCProofWithMessage. It only
+// verifies with VerifyWithMessage and the same msg.
+func NewConsistencyProofWithMessage(zkpcp ZKPCurveParams,
+	CM, CMTok, PubKey ECPoint, value, randomness *big.Int, msg []byte) (*ConsistencyProof, error) {
+	return newConsistencyProof(zkpcp, entropy(), CM, CMTok, PubKey, value, randomness, msg)
+}
 
+// newConsistencyProof generates a ConsistencyProof bound to msg, which may be nil
+func newConsistencyProof(zkpcp ZKPCurveParams, rnd io.Reader,
+	CM, CMTok, PubKey ECPoint, value, randomness *big.Int, msg []byte) (*ConsistencyProof, error) {
+
+	if !zkpcp.validPoints(CM, CMTok, PubKey) {
+		return nil, curveError("ConsistencyProve")
+	}
+	prover, err := consistencyCommit(zkpcp, rnd, CM, CMTok, PubKey, value, randomness)
+	if err != nil {
+		return nil, err
+	}
+
+	Challenge := consistencyChallenge(zkpcp, CM, CMTok, PubKey, prover.T1, prover.T2, msg)
+
+	return prover.respond(zkpcp, Challenge), nil
+
+}
+
+// consistencyProver is a ConsistencyProof between the commit and the response
+// phase, it keeps the witness and the random values around
+type consistencyProver struct {
+	T1, T2            ECPoint
+	value, randomness *big.Int
+	u1, u2            *big.Int
+}
+
+// consistencyCommit checks value and randomness and computes the commitments
+// T1 and T2 of a ConsistencyProof
+func consistencyCommit(zkpcp ZKPCurveParams, rnd io.Reader,
+	CM, CMTok, PubKey ECPoint, value, randomness *big.Int) (*consistencyProver, error) {
+
+	if err := zkpcp.checkScalars("ConsistencyProve", "value, randomness", value, randomness); err != nil {
+		return nil, err
+	}
 	modValue := new(big.Int).Mod(value, zkpcp.C.Params().N)
 	//modRandom := new(big.Int).Mod(randomness, zkpcp.C.Params().N)
 
 	// do a quick correctness check to ensure the value we are testing and the
 	// randomness are correct
 	if !CM.Equal(PedCommitR(zkpcp, value, randomness)) {
-		return &ConsistencyProof{}, &errorProof{"ConsistencyProve", "value and randomVal does not produce CM"}
+		return nil, inputError("ConsistencyProve", "value and randomVal does not produce CM")
 	}
 
 	if !CMTok.Equal(zkpcp.Mult(PubKey, randomness)) {
-		return &ConsistencyProof{}, &errorProof{"ConsistencyProve", "Pubkey and randomVal does not produce CMTok"}
+		return nil, inputError("ConsistencyProve", "Pubkey and randomVal does not produce CMTok")
 	}
 
-	u1, err := rand.Int(rand.Reader, zkpcp.C.Params().N)
-	if err != nil {
-		return nil, err
-	}
-	u2, err := rand.Int(rand.Reader, zkpcp.C.Params().N)
+	us, err := zkpcp.randScalars(rnd, 2)
 	if err != nil {
 		return nil, err
 	}
+	u1, u2 := us[0], us[1]
 
-	T1 := PedCommitR(zkpcp, u1, u2)
-	T2 := zkpcp.Mult(PubKey, u2)
+	return &consistencyProver{
+		T1:         PedCommitR(zkpcp, u1, u2),
+		T2:         zkpcp.Mult(PubKey, u2),
+		value:      modValue,
+		randomness: randomness,
+		u1:         u1,
+		u2:         u2,
+	}, nil
+}
 
-	Challenge := GenerateChallenge(zkpcp, zkpcp.G.Bytes(), zkpcp.H.Bytes(),
-		CM.Bytes(), CMTok.Bytes(),
-		PubKey.Bytes(),
-		T1.Bytes(), T2.Bytes())
+// consistencyChallenge computes c = HASH(msg, G, H, CM, CMTok, PubKey, T1, T2)
+func consistencyChallenge(zkpcp ZKPCurveParams, CM, CMTok, PubKey, T1, T2 ECPoint, msg []byte) *big.Int {
+	return consistencyTranscript(zkpcp, CM, CMTok, PubKey, T1, T2, msg, nil).ChallengeScalar("c")
+}
 
-	s1 := new(big.Int).Add(u1, new(big.Int).Mul(modValue, Challenge))
-	s2 := new(big.Int).Add(u2, new(big.Int).Mul(randomness, Challenge))
+// consistencyTranscript returns the transcript the challenge of a
+// ConsistencyProof is computed from
+func consistencyTranscript(zkpcp ZKPCurveParams, CM, CMTok, PubKey, T1, T2 ECPoint, msg []byte, tr *VerifyTrace) *Transcript {
+	t := tr.transcript(zkpcp, "ConsistencyProof")
+	t.bindMessage(msg)
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendPoint("CM", CM)
+	t.AppendPoint("CMTok", CMTok)
+	t.AppendPoint("PubKey", PubKey)
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	return t
+}
+
+// respond finishes the ConsistencyProof for challenge c
+func (prover *consistencyProver) respond(zkpcp ZKPCurveParams, c *big.Int) *ConsistencyProof {
+	s1 := new(big.Int).Add(prover.u1, new(big.Int).Mul(prover.value, c))
+	s2 := new(big.Int).Add(prover.u2, new(big.Int).Mul(prover.randomness, c))
 
 	s1.Mod(s1, zkpcp.C.Params().N)
 	s2.Mod(s2, zkpcp.C.Params().N)
 
-	conProof := &ConsistencyProof{T1, T2, Challenge, s1, s2}
-
-	return conProof, nil
-
+	return &ConsistencyProof{prover.T1, prover.T2, c, s1, s2}
 }
 
 // Verify checks if a ConsistencyProof conProof is valid
 func (conProof *ConsistencyProof) Verify(
 	zkpcp ZKPCurveParams, CM, CMTok, PubKey ECPoint) (bool, error) {
+	return conProof.verify(zkpcp, CM, CMTok, PubKey, nil, nil, nil)
+}
+
+// VerifyWithTrace is the same as Verify and also returns a VerifyTrace of the
+// verification, which is returned if the proof does not verify too
+func (conProof *ConsistencyProof) VerifyWithTrace(
+	zkpcp ZKPCurveParams, CM, CMTok, PubKey ECPoint) (*VerifyTrace, error) {
+	tr := newTrace("ConsistencyProof")
+	_, err := conProof.verify(zkpcp, CM, CMTok, PubKey, nil, nil, tr)
+	tr.result(err)
+	return tr, err
+}
+
+// VerifyWithMessage is the same as Verify for a ConsistencyProof generated by
+// NewConsistencyProofWithMessage, which only verifies for the same msg
+func (conProof *ConsistencyProof) VerifyWithMessage(
+	zkpcp ZKPCurveParams, CM, CMTok, PubKey ECPoint, msg []byte) (bool, error) {
+	return conProof.verify(zkpcp, CM, CMTok, PubKey, msg, nil, nil)
+}
+
+// VerifyArgs implements Verifiable, args are CM, CMTok and PubKey like for
+// Verify
+func (conProof *ConsistencyProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("ConsistencyProof.VerifyArgs", args)
+	CM, CMTok, PubKey := a.point(), a.point(), a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return conProof.Verify(zkpcp, CM, CMTok, PubKey)
+}
+
+// verify is Verify for the proof bound to msg with the challenge computed by
+// session s, recorded in trace tr, all of which may be nil
+func (conProof *ConsistencyProof) verify(
+	zkpcp ZKPCurveParams, CM, CMTok, PubKey ECPoint, msg []byte, s *VerifierSession, tr *VerifyTrace) (bool, error) {
 
 	if conProof == nil {
-		return false, &errorProof{"ConsistencyProof.Verify", fmt.Sprintf("passed proof is nil")}
+		return false, malformedError("ConsistencyProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(CM, CMTok, PubKey) || !zkpcp.onCurve(conProof.T1, conProof.T2) {
+		return false, curveError("ConsistencyProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("ConsistencyProof.Verify", "Challenge, S1, S2", conProof.Challenge, conProof.S1, conProof.S2); err != nil {
+		return false, err
 	}
+	tr.points("CM, CMTok, PubKey, T1, T2", CM, CMTok, PubKey, conProof.T1, conProof.T2)
+	tr.scalars("Challenge, S1, S2", conProof.Challenge, conProof.S1, conProof.S2)
 
 	// Regenerate challenge string
-	Challenge := GenerateChallenge(zkpcp, zkpcp.G.Bytes(), zkpcp.H.Bytes(),
-		CM.Bytes(), CMTok.Bytes(),
-		PubKey.Bytes(),
-		conProof.T1.Bytes(), conProof.T2.Bytes())
+	Challenge := s.consistencyChallenge(zkpcp, CM, CMTok, PubKey, conProof.T1, conProof.T2, msg, tr)
 
 	// c ?= HASH(G, H, T1, T2, PK, CM, Y)
-	if Challenge.Cmp(conProof.Challenge) != 0 {
-		return false, &errorProof{"ConsistencyVerify", fmt.Sprintf("c comparison failed. proof: %v calculated: %v",
-			conProof.Challenge, Challenge)}
+	if !scalarsEqual(Challenge, conProof.Challenge) {
+		return false, challengeError("ConsistencyVerify", "c comparison failed", Challenge, conProof.Challenge)
 	}
-	// lhs :: left hand side, rhs :: right hand side
-	// s1G + s2H ?= T1 + cCM, CM should be point1
-	// s1G + s2H from how PedCommitR works
-	lhs := PedCommitR(zkpcp, conProof.S1, conProof.S2)
-	// cCM
-	temp1 := zkpcp.Mult(CM, Challenge)
-	// T1 + cCM
-	rhs := zkpcp.Add(conProof.T1, temp1)
 
-	if !lhs.Equal(rhs) {
-		return false, &errorProof{"ConsistencyVerify", "CM check is failing"}
+	if err := conProof.verifyResponses(zkpcp, CM, CMTok, PubKey, tr); err != nil {
+		return false, err
 	}
 
-	// s2PK ?= T2 + cY
-	lhs = zkpcp.Mult(PubKey, conProof.S2)
-	temp1 = zkpcp.Mult(CMTok, Challenge)
-	rhs = zkpcp.Add(conProof.T2, temp1)
+	// All three checks passed, proof must be correct
+	return true, nil
+}
 
-	if !lhs.Equal(rhs) {
-		return false, &errorProof{"ConsistencyVerify", "CMTok check is failing"}
+// verifyResponses checks the verification equations of conProof for its
+// challenge, which has to be checked by the caller, and records them in tr if
+// it is not nil
+func (conProof *ConsistencyProof) verifyResponses(zkpcp ZKPCurveParams, CM, CMTok, PubKey ECPoint, tr *VerifyTrace) error {
+	negC := new(big.Int).Neg(conProof.Challenge)
+
+	// s1G + s2H ?= T1 + cCM, checked as s1G + s2H - cCM ?= T1
+	lhs := zkpcp.MultiMult([]ECPoint{zkpcp.G, zkpcp.H, CM}, []*big.Int{conProof.S1, conProof.S2, negC})
+
+	if err := tr.equation("ConsistencyVerify", 1, "CM check is failing: s1G + s2H - cCM != T1", lhs, conProof.T1); err != nil {
+		return err
 	}
 
-	// All three checks passed, proof must be correct
-	return true, nil
+	// s2PK ?= T2 + cCMTok, checked as s2PK - cCMTok ?= T2
+	lhs = zkpcp.MultiMult([]ECPoint{PubKey, CMTok}, []*big.Int{conProof.S2, negC})
+
+	return tr.equation("ConsistencyVerify", 2, "CMTok check is failing: s2PK - cCMTok != T2", lhs, conProof.T2)
 }
 
-// Bytes returns a byte slice with a serialized representation of ConsistencyProof proof
+// consistencyProofLen is the length of a serialized ConsistencyProof
+var consistencyProofLen = 1 + 2*fixedPointLen + 3*fixedScalarLen
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *ConsistencyProof) String() string {
+	if proof == nil {
+		return "ConsistencyProof(nil)"
+	}
+	return proofString("ConsistencyProof", "T1", proof.T1, "T2", proof.T2,
+		"Challenge", proof.Challenge, "S1", proof.S1, "S2", proof.S2)
+}
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// ConsistencyProof proof
 func (proof *ConsistencyProof) Bytes() []byte {
 	var buf bytes.Buffer
 
-	WriteECPoint(&buf, proof.T1)
-	WriteECPoint(&buf, proof.T2)
-	WriteBigInt(&buf, proof.Challenge)
-	WriteBigInt(&buf, proof.S1)
-	WriteBigInt(&buf, proof.S2)
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.T1)
+	writeFixedPoint(&buf, proof.T2)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.S1)
+	writeFixedScalar(&buf, proof.S2)
 
 	return buf.Bytes()
 }
 
+// Size returns the length of Bytes of ConsistencyProof proof, which has a fixed size
+func (proof *ConsistencyProof) Size() int {
+	return consistencyProofLen
+}
+
+// Clone returns a deep copy of ConsistencyProof proof that shares no big.Int with it
+func (proof *ConsistencyProof) Clone() *ConsistencyProof {
+	if proof == nil {
+		return nil
+	}
+	return &ConsistencyProof{
+		T1:        clonePoint(proof.T1),
+		T2:        clonePoint(proof.T2),
+		Challenge: cloneScalar(proof.Challenge),
+		S1:        cloneScalar(proof.S1),
+		S2:        cloneScalar(proof.S2),
+	}
+}
+
+// Equal returns true if ConsistencyProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *ConsistencyProof) Equal(other *ConsistencyProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.T1, other.T1) &&
+		samePoint(proof.T2, other.T2) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.S1, other.S1) &&
+		sameScalar(proof.S2, other.S2)
+}
+
 // NewConsistencyProofFromBytes returns a ConsistencyProof generated from the
 // deserialization of byte slice b
 func NewConsistencyProofFromBytes(b []byte) (*ConsistencyProof, error) {
+	if err := checkFixedHeader(b, consistencyProofLen, "ConsistencyProofFromBytes"); err != nil {
+		return nil, err
+	}
 	proof := new(ConsistencyProof)
-	buf := bytes.NewBuffer(b)
-	proof.T1, _ = ReadECPoint(buf)
-	proof.T2, _ = ReadECPoint(buf)
-	proof.Challenge, _ = ReadBigInt(buf)
-	proof.S1, _ = ReadBigInt(buf)
-	proof.S2, _ = ReadBigInt(buf)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.T1, err = readFixedPoint(buf, "ConsistencyProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if proof.T2, err = readFixedPoint(buf, "ConsistencyProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "ConsistencyProofFromBytes", &proof.Challenge, &proof.S1, &proof.S2); err != nil {
+		return nil, err
+	}
 	return proof, nil
 }
+
+type consistencyProofJSON struct {
+	T1        string `json:"t1"`
+	T2        string `json:"t2"`
+	Challenge string `json:"challenge"`
+	S1        string `json:"s1"`
+	S2        string `json:"s2"`
+}
+
+// MarshalJSON encodes ConsistencyProof conProof with points as compressed hex
+// and scalars as big-endian hex
+func (conProof *ConsistencyProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(consistencyProofJSON{
+		T1:        pointToHex(conProof.T1),
+		T2:        pointToHex(conProof.T2),
+		Challenge: scalarToHex(conProof.Challenge),
+		S1:        scalarToHex(conProof.S1),
+		S2:        scalarToHex(conProof.S2),
+	})
+}
+
+// UnmarshalJSON decodes a ConsistencyProof encoded with MarshalJSON. It fails
+// if a point is not on the curve or a scalar is not in [0, N)
+func (conProof *ConsistencyProof) UnmarshalJSON(b []byte) error {
+	var aux consistencyProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	proof := ConsistencyProof{}
+	if proof.T1, err = pointFromHex(aux.T1, "t1"); err != nil {
+		return err
+	}
+	if proof.T2, err = pointFromHex(aux.T2, "t2"); err != nil {
+		return err
+	}
+	if proof.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if proof.S1, err = scalarFromHex(aux.S1, "s1"); err != nil {
+		return err
+	}
+	if proof.S2, err = scalarFromHex(aux.S2, "s2"); err != nil {
+		return err
+	}
+	*conProof = proof
+	return nil
+}
@@ -1,7 +1,9 @@
 package zksigma
 
 import (
+	"bytes"
 	"crypto/rand"
+	"errors"
 	"testing"
 )
 
@@ -72,7 +74,11 @@ func TestConsistencySerialization(t *testing.T) {
 		t.Fatalf("TestConsistency - incorrect error message for correct proof, case 1\n")
 	}
 
-	conProof, status1 = NewConsistencyProofFromBytes(conProof.Bytes())
+	encoded := conProof.Bytes()
+	if len(encoded) != consistencyProofLen || !bytes.Equal(encoded, conProof.Bytes()) {
+		t.Fatalf("TestConsistency - non-deterministic or wrongly sized encoding\n")
+	}
+	conProof, status1 = NewConsistencyProofFromBytes(encoded)
 	if status1 != nil {
 		t.Fatalf("TestConsistency - failed to deserialize \n")
 	}
@@ -120,3 +126,40 @@ func BenchmarkConsistencyVerify(b *testing.B) {
 		proof.Verify(TestCurve, CM, CMTok, PK)
 	}
 }
+
+func TestConsistencyProofWithMessage(t *testing.T) {
+	x, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	CM, r, _ := PedCommit(TestCurve, x)
+	CMTok := TestCurve.Mult(PK, r)
+	msg := []byte("transaction 1")
+	defer func(old bool) { LegacyChallenges = old }(LegacyChallenges)
+
+	for _, legacy := range []bool{false, true} {
+		LegacyChallenges = legacy
+
+		proof, err := NewConsistencyProofWithMessage(TestCurve, CM, CMTok, PK, x, r, msg)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if ok, err := proof.VerifyWithMessage(TestCurve, CM, CMTok, PK, msg); !ok || err != nil {
+			t.Fatalf("ConsistencyProof with a message failed to verify, legacy %v: %v\n", legacy, err)
+		}
+		if ok, err := proof.VerifyWithMessage(TestCurve, CM, CMTok, PK, []byte("transaction 2")); ok || !errors.Is(err, ErrChallengeMismatch) {
+			t.Fatalf("ConsistencyProof verified for another message, legacy %v: %v\n", legacy, err)
+		}
+		if ok, _ := proof.Verify(TestCurve, CM, CMTok, PK); ok {
+			t.Fatalf("ConsistencyProof with a message verified without it, legacy %v\n", legacy)
+		}
+
+		plain, _ := NewConsistencyProofWithRand(TestCurve, newSeededReader("con"), CM, CMTok, PK, x, r)
+		empty, _ := newConsistencyProof(TestCurve, newSeededReader("con"), CM, CMTok, PK, x, r, []byte{})
+		if !bytes.Equal(plain.Bytes(), empty.Bytes()) {
+			t.Fatalf("ConsistencyProof with an empty message differs from one without, legacy %v\n", legacy)
+		}
+		if ok, err := plain.VerifyWithMessage(TestCurve, CM, CMTok, PK, nil); !ok || err != nil {
+			t.Fatalf("ConsistencyProof failed to verify with a nil message: %v\n", err)
+		}
+	}
+}
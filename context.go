@@ -0,0 +1,423 @@
+package zksigma
+
+import (
+	"context"
+	"math/big"
+)
+
+// The methods in this file let a ZKPCurveParams be used as the context that
+// proofs are generated in, e.g. ZKCurve.NewABCProof(...) is the same as
+// NewABCProof(ZKCurve, ...). Proofs do not remember their ZKPCurveParams, so
+// the same one has to be passed to Verify.
+
+// PedCommit is the same as PedCommit(zkpcp, value)
+func (zkpcp ZKPCurveParams) PedCommit(value *big.Int) (ECPoint, *big.Int, error) {
+	return PedCommit(zkpcp, value)
+}
+
+// PedCommitR is the same as PedCommitR(zkpcp, value, randomValue)
+func (zkpcp ZKPCurveParams) PedCommitR(value, randomValue *big.Int) ECPoint {
+	return PedCommitR(zkpcp, value, randomValue)
+}
+
+// PedCommitOpen is the same as PedCommitOpen(zkpcp, value)
+func (zkpcp ZKPCurveParams) PedCommitOpen(value *big.Int) (*OpenCommitment, error) {
+	return PedCommitOpen(zkpcp, value)
+}
+
+// NewOpenCommitment is the same as NewOpenCommitment(zkpcp, value, randomness)
+func (zkpcp ZKPCurveParams) NewOpenCommitment(value, randomness *big.Int) *OpenCommitment {
+	return NewOpenCommitment(zkpcp, value, randomness)
+}
+
+// Open is the same as Open(zkpcp, value, randomValue, pcomm)
+func (zkpcp ZKPCurveParams) Open(value, randomValue *big.Int, pcomm ECPoint) bool {
+	return Open(zkpcp, value, randomValue, pcomm)
+}
+
+// NewABCProof is the same as NewABCProof(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewABCProof(CM, CMTok ECPoint, value, sk *big.Int, option Side) (*ABCProof, error) {
+	return NewABCProof(zkpcp, CM, CMTok, value, sk, option)
+}
+
+// NewABCProofDelegated is the same as NewABCProofDelegated(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewABCProofDelegated(CM, CMTok, PK ECPoint, value, ua *big.Int, option Side) (*ABCProof, error) {
+	return NewABCProofDelegated(zkpcp, CM, CMTok, PK, value, ua, option)
+}
+
+// NewABCProofWithMessage is the same as NewABCProofWithMessage(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewABCProofWithMessage(CM, CMTok ECPoint, value, sk *big.Int, option Side, msg []byte) (*ABCProof, error) {
+	return NewABCProofWithMessage(zkpcp, CM, CMTok, value, sk, option, msg)
+}
+
+// NewABCProofAuto is the same as NewABCProofAuto(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewABCProofAuto(CM, CMTok ECPoint, value, sk *big.Int) (*ABCProof, error) {
+	return NewABCProofAuto(zkpcp, CM, CMTok, value, sk)
+}
+
+// NewProverKey is the same as NewProverKey(zkpcp, sk)
+func (zkpcp ZKPCurveParams) NewProverKey(sk *big.Int) (*ProverKey, error) {
+	return NewProverKey(zkpcp, sk)
+}
+
+// NewDisjunctiveProof is the same as NewDisjunctiveProof(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewDisjunctiveProof(Base1, Result1, Base2, Result2 ECPoint, x *big.Int, option Side) (*DisjunctiveProof, error) {
+	return NewDisjunctiveProof(zkpcp, Base1, Result1, Base2, Result2, x, option)
+}
+
+// NewDisjunctiveProofWithMessage is the same as NewDisjunctiveProofWithMessage(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewDisjunctiveProofWithMessage(Base1, Result1, Base2, Result2 ECPoint, x *big.Int, option Side, msg []byte) (*DisjunctiveProof, error) {
+	return NewDisjunctiveProofWithMessage(zkpcp, Base1, Result1, Base2, Result2, x, option, msg)
+}
+
+// NewDisjunctiveProofAuto is the same as NewDisjunctiveProofAuto(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewDisjunctiveProofAuto(Base1, Result1, Base2, Result2 ECPoint, x *big.Int) (*DisjunctiveProof, error) {
+	return NewDisjunctiveProofAuto(zkpcp, Base1, Result1, Base2, Result2, x)
+}
+
+// DisjunctiveSide is the same as DisjunctiveSide(zkpcp, ...)
+func (zkpcp ZKPCurveParams) DisjunctiveSide(Base1, Result1, Base2, Result2 ECPoint, x *big.Int) (Side, error) {
+	return DisjunctiveSide(zkpcp, Base1, Result1, Base2, Result2, x)
+}
+
+// NewEquivalenceProof is the same as NewEquivalenceProof(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewEquivalenceProof(Base1, Result1, Base2, Result2 ECPoint, x *big.Int) (*EquivalenceProof, error) {
+	return NewEquivalenceProof(zkpcp, Base1, Result1, Base2, Result2, x)
+}
+
+// NewEquivalenceProofWithMessage is the same as NewEquivalenceProofWithMessage(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewEquivalenceProofWithMessage(Base1, Result1, Base2, Result2 ECPoint, x *big.Int, msg []byte) (*EquivalenceProof, error) {
+	return NewEquivalenceProofWithMessage(zkpcp, Base1, Result1, Base2, Result2, x, msg)
+}
+
+// NewConsistencyProof is the same as NewConsistencyProof(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewConsistencyProof(CM, CMTok, PubKey ECPoint, value, randomness *big.Int) (*ConsistencyProof, error) {
+	return NewConsistencyProof(zkpcp, CM, CMTok, PubKey, value, randomness)
+}
+
+// NewConsistencyProofWithMessage is the same as NewConsistencyProofWithMessage(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewConsistencyProofWithMessage(CM, CMTok, PubKey ECPoint, value, randomness *big.Int, msg []byte) (*ConsistencyProof, error) {
+	return NewConsistencyProofWithMessage(zkpcp, CM, CMTok, PubKey, value, randomness, msg)
+}
+
+// NewGSPFSProof is the same as NewGSPFSProof(zkpcp, A, x)
+func (zkpcp ZKPCurveParams) NewGSPFSProof(A ECPoint, x *big.Int) (*GSPFSProof, error) {
+	return NewGSPFSProof(zkpcp, A, x)
+}
+
+// NewGSPFSProofBase is the same as NewGSPFSProofBase(zkpcp, base, A, x)
+func (zkpcp ZKPCurveParams) NewGSPFSProofBase(base, A ECPoint, x *big.Int) (*GSPFSProof, error) {
+	return NewGSPFSProofBase(zkpcp, base, A, x)
+}
+
+// NewGSPFSProofWithMessage is the same as NewGSPFSProofWithMessage(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewGSPFSProofWithMessage(base, A ECPoint, x *big.Int, msg []byte) (*GSPFSProof, error) {
+	return NewGSPFSProofWithMessage(zkpcp, base, A, x, msg)
+}
+
+// NewInequalityProof is the same as NewInequalityProof(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewInequalityProof(CM1, CM2 ECPoint, v1, v2, r1, r2 *big.Int) (*InequalityProof, error) {
+	return NewInequalityProof(zkpcp, CM1, CM2, v1, v2, r1, r2)
+}
+
+// NewNonZeroProof is the same as NewNonZeroProof(zkpcp, CM, v, r)
+func (zkpcp ZKPCurveParams) NewNonZeroProof(CM ECPoint, v, r *big.Int) (*NonZeroProof, error) {
+	return NewNonZeroProof(zkpcp, CM, v, r)
+}
+
+// NewOpenToValueProof is the same as NewOpenToValueProof(zkpcp, CM, claimedValue, r)
+func (zkpcp ZKPCurveParams) NewOpenToValueProof(CM ECPoint, claimedValue, r *big.Int) (*OpenToValueProof, error) {
+	return NewOpenToValueProof(zkpcp, CM, claimedValue, r)
+}
+
+// NewRangeProof is the same as NewRangeProof(zkpcp, value)
+func (zkpcp ZKPCurveParams) NewRangeProof(value *big.Int) (*RangeProof, *big.Int, error) {
+	return NewRangeProof(zkpcp, value)
+}
+
+// NewDecryptionProof is the same as NewDecryptionProof(zkpcp, CM, CMTok, sk)
+func (zkpcp ZKPCurveParams) NewDecryptionProof(CM, CMTok ECPoint, sk *big.Int) (*DecryptionProof, ECPoint, error) {
+	return NewDecryptionProof(zkpcp, CM, CMTok, sk)
+}
+
+// NewBitProof is the same as NewBitProof(zkpcp, value, randomness)
+func (zkpcp ZKPCurveParams) NewBitProof(value, randomness *big.Int) (*BitProof, ECPoint, error) {
+	return NewBitProof(zkpcp, value, randomness)
+}
+
+// NewBitRangeProof is the same as NewBitRangeProof(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewBitRangeProof(CM ECPoint, value, randomness *big.Int, bits int) (*BitRangeProof, error) {
+	return NewBitRangeProof(zkpcp, CM, value, randomness, bits)
+}
+
+// NewComparisonProof is the same as NewComparisonProof(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewComparisonProof(CMA, CMB ECPoint, a, b, ra, rb *big.Int, bits int) (*ComparisonProof, error) {
+	return NewComparisonProof(zkpcp, CMA, CMB, a, b, ra, rb, bits)
+}
+
+// NewBoundedRangeProof is the same as NewBoundedRangeProof(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewBoundedRangeProof(CM ECPoint, value, randomness, lower, upper *big.Int) (*BoundedRangeProof, error) {
+	return NewBoundedRangeProof(zkpcp, CM, value, randomness, lower, upper)
+}
+
+// NewRangeProofBounded is the same as NewRangeProofBounded(zkpcp, ...)
+func (zkpcp ZKPCurveParams) NewRangeProofBounded(value, randomness, lower, upper *big.Int) (*BoundedRangeProof, ECPoint, error) {
+	return NewRangeProofBounded(zkpcp, value, randomness, lower, upper)
+}
+
+// NewMultiRangeProof is the same as NewMultiRangeProof(zkpcp, CMs, values, randomnesses, bits)
+func (zkpcp ZKPCurveParams) NewMultiRangeProof(CMs []ECPoint, values, randomnesses []*big.Int, bits int) (*MultiRangeProof, error) {
+	return NewMultiRangeProof(zkpcp, CMs, values, randomnesses, bits)
+}
+
+// NewAggregateRangeProof is the same as NewAggregateRangeProof(zkpcp, CMs, values, randomnesses, bits)
+func (zkpcp ZKPCurveParams) NewAggregateRangeProof(CMs []ECPoint, values, randomnesses []*big.Int, bits int) (*AggregateRangeProof, error) {
+	return NewAggregateRangeProof(zkpcp, CMs, values, randomnesses, bits)
+}
+
+// NewABCProofBatch is the same as NewABCProofBatch(zkpcp, CMs, CMToks, values, sk)
+func (zkpcp ZKPCurveParams) NewABCProofBatch(CMs, CMToks []ECPoint, values []*big.Int, sk *big.Int) ([]*ABCProof, error) {
+	return NewABCProofBatch(zkpcp, CMs, CMToks, values, sk)
+}
+
+// VerifyABCBatch is the same as VerifyABCBatch(zkpcp, proofs, CMs, CMToks, PKs)
+func (zkpcp ZKPCurveParams) VerifyABCBatch(proofs []*ABCProof, CMs, CMToks, PKs []ECPoint) (bool, error) {
+	return VerifyABCBatch(zkpcp, proofs, CMs, CMToks, PKs)
+}
+
+// VerifyConsistencyBatch is the same as VerifyConsistencyBatch(zkpcp, proofs, CMs, CMToks, PKs)
+func (zkpcp ZKPCurveParams) VerifyConsistencyBatch(proofs []*ConsistencyProof, CMs, CMToks, PKs []ECPoint) (bool, int, error) {
+	return VerifyConsistencyBatch(zkpcp, proofs, CMs, CMToks, PKs)
+}
+
+// NewABCProofBatchCtx is the same as NewABCProofBatchCtx(ctx, zkpcp, CMs, CMToks, values, sk)
+func (zkpcp ZKPCurveParams) NewABCProofBatchCtx(ctx context.Context,
+	CMs, CMToks []ECPoint, values []*big.Int, sk *big.Int) ([]*ABCProof, error) {
+	return NewABCProofBatchCtx(ctx, zkpcp, CMs, CMToks, values, sk)
+}
+
+// VerifyABCBatchCtx is the same as VerifyABCBatchCtx(ctx, zkpcp, proofs, CMs, CMToks, PKs)
+func (zkpcp ZKPCurveParams) VerifyABCBatchCtx(ctx context.Context, proofs []*ABCProof, CMs, CMToks, PKs []ECPoint) (int, error) {
+	return VerifyABCBatchCtx(ctx, zkpcp, proofs, CMs, CMToks, PKs)
+}
+
+// ProveAll is the same as ProveAll(zkpcp, statements, witnesses, workers)
+func (zkpcp ZKPCurveParams) ProveAll(statements []ABCStatement, witnesses []ABCWitness, workers int) ([]*ABCProof, error) {
+	return ProveAll(zkpcp, statements, witnesses, workers)
+}
+
+// VerifyAll is the same as VerifyAll(zkpcp, proofs, statements, workers)
+func (zkpcp ZKPCurveParams) VerifyAll(proofs []*ABCProof, statements []ABCStatement, workers int) (bool, error) {
+	return VerifyAll(zkpcp, proofs, statements, workers)
+}
+
+// NewRangeProofBatchCtx is the same as NewRangeProofBatchCtx(ctx, zkpcp, values)
+func (zkpcp ZKPCurveParams) NewRangeProofBatchCtx(ctx context.Context, values []*big.Int) ([]*RangeProof, []*big.Int, error) {
+	return NewRangeProofBatchCtx(ctx, zkpcp, values)
+}
+
+// VerifyProof is the same as VerifyProof(zkpcp, p, args...)
+func (zkpcp ZKPCurveParams) VerifyProof(p Verifiable, args ...interface{}) error {
+	return VerifyProof(zkpcp, p, args...)
+}
+
+// VerifyEncoded is the same as VerifyEncoded(zkpcp, data, statement)
+func (zkpcp ZKPCurveParams) VerifyEncoded(data []byte, statement map[string]ECPoint) error {
+	return VerifyEncoded(zkpcp, data, statement)
+}
+
+// NewComposedProof is the same as NewComposedProof(zkpcp, statements...)
+func (zkpcp ZKPCurveParams) NewComposedProof(statements ...Statement) (*ComposedProof, error) {
+	return NewComposedProof(zkpcp, statements...)
+}
+
+// NewOpenProof is the same as NewOpenProof(zkpcp, CM, v, r)
+func (zkpcp ZKPCurveParams) NewOpenProof(CM ECPoint, v, r *big.Int) (*OpenProof, error) {
+	return NewOpenProof(zkpcp, CM, v, r)
+}
+
+// ECPointFromHex is the same as ECPointFromHex(zkpcp, s)
+func (zkpcp ZKPCurveParams) ECPointFromHex(s string) (ECPoint, error) {
+	return ECPointFromHex(zkpcp, s)
+}
+
+// DecompressPoint is the same as DecompressPoint(zkpcp, b)
+func (zkpcp ZKPCurveParams) DecompressPoint(b []byte) (ECPoint, error) {
+	return DecompressPoint(zkpcp, b)
+}
+
+// NewProductProof is the same as NewProductProof(zkpcp, a, b, ra, rb, rc)
+func (zkpcp ZKPCurveParams) NewProductProof(a, b, ra, rb, rc *big.Int) (*ProductProof, ECPoint, ECPoint, ECPoint, error) {
+	return NewProductProof(zkpcp, a, b, ra, rb, rc)
+}
+
+// NewMultiProductProof is the same as NewMultiProductProof(zkpcp, factors, randomnesses)
+func (zkpcp ZKPCurveParams) NewMultiProductProof(factors, randomnesses []*big.Int) ([]ECPoint, *MultiProductProof, error) {
+	return NewMultiProductProof(zkpcp, factors, randomnesses)
+}
+
+// NewABCProver is the same as NewABCProver(zkpcp, CM, CMTok, value, sk, option)
+func (zkpcp ZKPCurveParams) NewABCProver(CM, CMTok ECPoint, value, sk *big.Int, option Side) (*ABCProver, error) {
+	return NewABCProver(zkpcp, CM, CMTok, value, sk, option)
+}
+
+// NewABCVerifierSession is the same as NewABCVerifierSession(zkpcp, CM, CMTok, PK)
+func (zkpcp ZKPCurveParams) NewABCVerifierSession(CM, CMTok, PK ECPoint) (*ABCVerifierSession, error) {
+	return NewABCVerifierSession(zkpcp, CM, CMTok, PK)
+}
+
+// NewDisjunctiveProver is the same as NewDisjunctiveProver(zkpcp, Base1, Result1, Base2, Result2, x, option)
+func (zkpcp ZKPCurveParams) NewDisjunctiveProver(Base1, Result1, Base2, Result2 ECPoint, x *big.Int, option Side) (*DisjunctiveProver, error) {
+	return NewDisjunctiveProver(zkpcp, Base1, Result1, Base2, Result2, x, option)
+}
+
+// NewDisjunctiveVerifierSession is the same as NewDisjunctiveVerifierSession(zkpcp, Base1, Result1, Base2, Result2)
+func (zkpcp ZKPCurveParams) NewDisjunctiveVerifierSession(Base1, Result1, Base2, Result2 ECPoint) (*DisjunctiveVerifierSession, error) {
+	return NewDisjunctiveVerifierSession(zkpcp, Base1, Result1, Base2, Result2)
+}
+
+// NewVerifierSession is the same as NewVerifierSession(zkpcp, CM, CMTok)
+func (zkpcp ZKPCurveParams) NewVerifierSession(CM, CMTok ECPoint) (*VerifierSession, error) {
+	return NewVerifierSession(zkpcp, CM, CMTok)
+}
+
+// NewDLEqualityProof is the same as NewDLEqualityProof(zkpcp, Base1, Result1, Base2, Result2, x)
+func (zkpcp ZKPCurveParams) NewDLEqualityProof(Base1, Result1, Base2, Result2 ECPoint, x *big.Int) (*DLEqualityProof, error) {
+	return NewDLEqualityProof(zkpcp, Base1, Result1, Base2, Result2, x)
+}
+
+// NewSumProof is the same as NewSumProof(zkpcp, CMs, values, randomnesses, total)
+func (zkpcp ZKPCurveParams) NewSumProof(CMs []ECPoint, values, randomnesses []*big.Int, total *big.Int) (*SumProof, error) {
+	return NewSumProof(zkpcp, CMs, values, randomnesses, total)
+}
+
+// NewSumZeroProof is the same as NewSumZeroProof(zkpcp, CMs, totalRandomness)
+func (zkpcp ZKPCurveParams) NewSumZeroProof(CMs []ECPoint, totalRandomness *big.Int) (*SumProof, error) {
+	return NewSumZeroProof(zkpcp, CMs, totalRandomness)
+}
+
+// NewOneOfManyProof is the same as NewOneOfManyProof(zkpcp, bases, results, knownIndex, witness)
+func (zkpcp ZKPCurveParams) NewOneOfManyProof(bases, results []ECPoint, knownIndex int, witness *big.Int) (*OneOfManyProof, error) {
+	return NewOneOfManyProof(zkpcp, bases, results, knownIndex, witness)
+}
+
+// NewSetMembershipProof is the same as NewSetMembershipProof(zkpcp, CM, value, r, set)
+func (zkpcp ZKPCurveParams) NewSetMembershipProof(CM ECPoint, value, r *big.Int, set []*big.Int) (*SetMembershipProof, error) {
+	return NewSetMembershipProof(zkpcp, CM, value, r, set)
+}
+
+// NewTranscript is the same as NewTranscript(zkpcp, domain)
+func (zkpcp ZKPCurveParams) NewTranscript(domain string) *Transcript {
+	return NewTranscript(zkpcp, domain)
+}
+
+// RerandomizeCommitment is the same as RerandomizeCommitment(zkpcp, CM)
+func (zkpcp ZKPCurveParams) RerandomizeCommitment(CM ECPoint) (ECPoint, *big.Int, error) {
+	return RerandomizeCommitment(zkpcp, CM)
+}
+
+// RerandomizeCommitmentR is the same as RerandomizeCommitmentR(zkpcp, CM, extraRandomness)
+func (zkpcp ZKPCurveParams) RerandomizeCommitmentR(CM ECPoint, extraRandomness *big.Int) ECPoint {
+	return RerandomizeCommitmentR(zkpcp, CM, extraRandomness)
+}
+
+// RerandomizeWithProof is the same as RerandomizeWithProof(zkpcp, CM)
+func (zkpcp ZKPCurveParams) RerandomizeWithProof(CM ECPoint) (ECPoint, *big.Int, *RerandomizationProof, error) {
+	return RerandomizeWithProof(zkpcp, CM)
+}
+
+// NewRerandomizationProof is the same as NewRerandomizationProof(zkpcp, CM, CMPrime, deltaR)
+func (zkpcp ZKPCurveParams) NewRerandomizationProof(CM, CMPrime ECPoint, deltaR *big.Int) (*RerandomizationProof, error) {
+	return NewRerandomizationProof(zkpcp, CM, CMPrime, deltaR)
+}
+
+// NewShuffleProof is the same as NewShuffleProof(zkpcp, inputs, outputs, perm, deltaRs)
+func (zkpcp ZKPCurveParams) NewShuffleProof(inputs, outputs []ECPoint, perm []int, deltaRs []*big.Int) (*ShuffleProof, error) {
+	return NewShuffleProof(zkpcp, inputs, outputs, perm, deltaRs)
+}
+
+// GeneratorVector is the same as GeneratorVector(zkpcp, n)
+func (zkpcp ZKPCurveParams) GeneratorVector(n int) ([]ECPoint, error) {
+	return GeneratorVector(zkpcp, n)
+}
+
+// ExtendGeneratorVector is the same as ExtendGeneratorVector(zkpcp, n)
+func (zkpcp ZKPCurveParams) ExtendGeneratorVector(n int) error {
+	return ExtendGeneratorVector(zkpcp, n)
+}
+
+// VectorPedCommit is the same as VectorPedCommit(zkpcp, values)
+func (zkpcp ZKPCurveParams) VectorPedCommit(values []*big.Int) (ECPoint, *big.Int, error) {
+	return VectorPedCommit(zkpcp, values)
+}
+
+// VectorPedCommitR is the same as VectorPedCommitR(zkpcp, values, randomValue)
+func (zkpcp ZKPCurveParams) VectorPedCommitR(values []*big.Int, randomValue *big.Int) (ECPoint, error) {
+	return VectorPedCommitR(zkpcp, values, randomValue)
+}
+
+// NewVectorOpenProof is the same as NewVectorOpenProof(zkpcp, C, values, r)
+func (zkpcp ZKPCurveParams) NewVectorOpenProof(C ECPoint, values []*big.Int, r *big.Int) (*VectorOpenProof, error) {
+	return NewVectorOpenProof(zkpcp, C, values, r)
+}
+
+// NewAuditableTransfer is the same as NewAuditableTransfer(zkpcp, senderSK, receiverPK, auditorPK, amount)
+func (zkpcp ZKPCurveParams) NewAuditableTransfer(senderSK *big.Int, receiverPK, auditorPK ECPoint, amount *big.Int) (*AuditableTransfer, error) {
+	return NewAuditableTransfer(zkpcp, senderSK, receiverPK, auditorPK, amount)
+}
+
+// VerifyTransfer is the same as VerifyTransfer(zkpcp, tx, senderPK, receiverPK, auditorPK)
+func (zkpcp ZKPCurveParams) VerifyTransfer(tx *AuditableTransfer, senderPK, receiverPK, auditorPK ECPoint) (bool, error) {
+	return VerifyTransfer(zkpcp, tx, senderPK, receiverPK, auditorPK)
+}
+
+// NewEntryProof is the same as NewEntryProof(zkpcp, value, r, sk, pk, isSender)
+func (zkpcp ZKPCurveParams) NewEntryProof(value, r, sk *big.Int, pk ECPoint, isSender bool) (*EntryProof, error) {
+	return NewEntryProof(zkpcp, value, r, sk, pk, isSender)
+}
+
+// NewSameBlindingProof is the same as NewSameBlindingProof(zkpcp, CMTok1, CMTok2, PK1, PK2, r)
+func (zkpcp ZKPCurveParams) NewSameBlindingProof(CMTok1, CMTok2, PK1, PK2 ECPoint, r *big.Int) (*SameBlindingProof, error) {
+	return NewSameBlindingProof(zkpcp, CMTok1, CMTok2, PK1, PK2, r)
+}
+
+// NewSameBlindingProofWithMessage is the same as NewSameBlindingProofWithMessage(zkpcp, CMTok1, CMTok2, PK1, PK2, r, msg)
+func (zkpcp ZKPCurveParams) NewSameBlindingProofWithMessage(CMTok1, CMTok2, PK1, PK2 ECPoint, r *big.Int, msg []byte) (*SameBlindingProof, error) {
+	return NewSameBlindingProofWithMessage(zkpcp, CMTok1, CMTok2, PK1, PK2, r, msg)
+}
+
+// NewEquivOrZeroProof is the same as NewEquivOrZeroProof(zkpcp, CMChange, CMDelta, rChange, rDelta, option)
+func (zkpcp ZKPCurveParams) NewEquivOrZeroProof(CMChange, CMDelta ECPoint, rChange, rDelta *big.Int, option Side) (*EquivOrZeroProof, error) {
+	return NewEquivOrZeroProof(zkpcp, CMChange, CMDelta, rChange, rDelta, option)
+}
+
+// NewSameValueProof is the same as NewSameValueProof(zkpcp, CM1, CM2, v, r1, r2)
+func (zkpcp ZKPCurveParams) NewSameValueProof(CM1, CM2 ECPoint, v, r1, r2 *big.Int) (*SameValueProof, error) {
+	return NewSameValueProof(zkpcp, CM1, CM2, v, r1, r2)
+}
+
+// RotateToken is the same as RotateToken(zkpcp, CMTok, oldSK, newSK)
+func (zkpcp ZKPCurveParams) RotateToken(CMTok ECPoint, oldSK, newSK *big.Int) (ECPoint, error) {
+	return RotateToken(zkpcp, CMTok, oldSK, newSK)
+}
+
+// NewKeyRotationProof is the same as NewKeyRotationProof(zkpcp, oldCMTok, newCMTok, oldPK, newPK, oldSK, newSK)
+func (zkpcp ZKPCurveParams) NewKeyRotationProof(oldCMTok, newCMTok, oldPK, newPK ECPoint,
+	oldSK, newSK *big.Int) (*KeyRotationProof, error) {
+	return NewKeyRotationProof(zkpcp, oldCMTok, newCMTok, oldPK, newPK, oldSK, newSK)
+}
+
+// VerifyCommitmentEquation is the same as VerifyCommitmentEquation(zkpcp, lhs, terms, signs)
+func (zkpcp ZKPCurveParams) VerifyCommitmentEquation(lhs ECPoint, terms []ECPoint, signs []int) (bool, error) {
+	return VerifyCommitmentEquation(zkpcp, lhs, terms, signs)
+}
+
+// Eq is the same as Eq(zkpcp)
+func (zkpcp ZKPCurveParams) Eq() *CommitmentEquation {
+	return Eq(zkpcp)
+}
+
+// NewBalanceProof is the same as NewBalanceProof(zkpcp, lhs, terms, signs, lhsR, rs)
+func (zkpcp ZKPCurveParams) NewBalanceProof(lhs ECPoint, terms []ECPoint, signs []int, lhsR *big.Int, rs []*big.Int) (*BalanceProof, error) {
+	return NewBalanceProof(zkpcp, lhs, terms, signs, lhsR, rs)
+}
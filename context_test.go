@@ -0,0 +1,240 @@
+package zksigma
+
+import (
+	"crypto/elliptic"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestNewZKPCurveParams(t *testing.T) {
+	if _, err := NewZKPCurveParams(TestCurve.C, TestCurve.G, TestCurve.G); err == nil {
+		t.Fatalf("G and H should not be allowed to be equal\n")
+	}
+	if _, err := NewZKPCurveParams(TestCurve.C, TestCurve.G, Zero); err == nil {
+		t.Fatalf("H should not be allowed to be Zero\n")
+	}
+	if _, err := NewZKPCurveParams(elliptic.P256(), TestCurve.G, TestCurve.H); err == nil {
+		t.Fatalf("secp256k1 generators should not be on P-256\n")
+	}
+	if ZKCurve.N.Cmp(TestCurve.C.Params().N) != 0 {
+		t.Fatalf("ZKCurve.N is not the order of the curve\n")
+	}
+}
+
+func TestDeriveH(t *testing.T) {
+	// x is SHA-256 of Gx + 3 on P-256 and SHA-256 of Gx + 2 on secp256k1
+	vectors := []struct {
+		curve elliptic.Curve
+		H     string
+	}{
+		{TestCurve.C, "02891df6526b0a564e83e6399b469a6b1f3a60d14f4845ab365a294868998e3daf"},
+		{elliptic.P224(), "021ca3334e5f9cda147a0632202ba78e659d832e382a298049c040f1f5"},
+		{elliptic.P256(), "02b1005348c0ff1dc6ed16cc0ecb652a6d99c707cfab352422f24d2973431c67a8"},
+		{elliptic.P384(), "02b82533c227a65452d7851435384434bfc91bb14ba390680ce029cdc1283e16bd1d6e553358e6344a3cb6066e5245e15b"},
+		{elliptic.P521(), "0200fa3bf538776e1b7f099d253415015f16abea25ab3d086eafa8fdabf65534fec6141eaa72676fb9bfa4d081be31ef5e42b4892621833008bad36215a3d12a061f03"},
+	}
+	for _, v := range vectors {
+		name := v.curve.Params().Name
+		H, err := DeriveH(v.curve)
+		if err != nil {
+			t.Fatalf("DeriveH failed on %s: %v\n", name, err)
+		}
+		if got := hex.EncodeToString(compressPoint(v.curve, H)); got != v.H {
+			t.Fatalf("DeriveH on %s gives %s, expected %s\n", name, got, v.H)
+		}
+		if H.Equal(Zero) || !v.curve.IsOnCurve(H.X, H.Y) {
+			t.Fatalf("DeriveH on %s gives a point that is not on the curve\n", name)
+		}
+
+		// H is none of -1000G, ..., 1000G
+		G := ECPoint{v.curve.Params().Gx, v.curve.Params().Gy}
+		kG := G
+		for k := 1; k <= 1000; k++ {
+			if H.X.Cmp(kG.X) == 0 {
+				t.Fatalf("DeriveH on %s gives %dG or -%dG\n", name, k, k)
+			}
+			x, y := v.curve.Add(kG.X, kG.Y, G.X, G.Y)
+			kG = ECPoint{x, y}
+		}
+
+		zkpcp, err := NewZKCurve(v.curve)
+		if err != nil {
+			t.Fatalf("NewZKCurve failed on %s: %v\n", name, err)
+		}
+		if !zkpcp.H.Equal(H) || !zkpcp.G.Equal(G) {
+			t.Fatalf("NewZKCurve on %s does not use the base point and DeriveH\n", name)
+		}
+	}
+
+	if !ZKCurve.H.Equal(btcecH) || !Secp256k1Curve.H.Equal(btcecH) || !TestCurve.G.Equal(Secp256k1Curve.G) {
+		t.Fatalf("the secp256k1 preset does not have the H of btcec\n")
+	}
+	if !P256Curve.H.Equal(vectorPoint(t, elliptic.P256(), vectors[2].H)) {
+		t.Fatalf("P256Curve does not have the derived H\n")
+	}
+}
+
+func TestGenerateH(t *testing.T) {
+	// these pin the algorithm, a change to any of them breaks every H users
+	// have derived
+	vectors := []struct {
+		curve elliptic.Curve
+		seed  string
+		H     string
+	}{
+		{TestCurve.C, "", "0271da57a96b7176de844713f736b8d735a3f54bcc68f4a1a62e560782e1253ed7"},
+		{TestCurve.C, "zksigma", "026b66dd4d98d61daceb658a46119bc138505902c64bbdde7c49e4e905d60ca026"},
+		{TestCurve.C, "\x00\x01\x02", "02811eaf03b256e597b49b8f259988af2387751a3539f60fa2ef761351ba4f1ce4"},
+		{elliptic.P256(), "", "022f2ddc9f436a5e8fe536ac9a72ef78c8e3ca12068dd2ffc15430660d6011ed58"},
+		{elliptic.P256(), "zksigma", "02f54d8e0ab44c2cbdbbaf474cfbdbf0cfe1b299d6d00c66ec6a0e745fd3ca5ee3"},
+		{elliptic.P256(), "\x00\x01\x02", "0200b4462738c5ca9d11149edbfbba7566778798795c09996df0f6b82bc15bd660"},
+	}
+	for _, v := range vectors {
+		name := v.curve.Params().Name
+		H, err := GenerateH(v.curve, []byte(v.seed))
+		if err != nil {
+			t.Fatalf("GenerateH failed on %s for %q: %v\n", name, v.seed, err)
+		}
+		if got := hex.EncodeToString(compressPoint(v.curve, H)); got != v.H {
+			t.Fatalf("GenerateH on %s for %q gives %s, expected %s\n", name, v.seed, got, v.H)
+		}
+		if !VerifyHDerivation(v.curve, H, []byte(v.seed)) {
+			t.Fatalf("VerifyHDerivation rejects the H of %q on %s\n", v.seed, name)
+		}
+		if !VerifyHDerivation(v.curve, vectorPoint(t, v.curve, v.H), []byte(v.seed)) {
+			t.Fatalf("VerifyHDerivation rejects the vector for %q on %s\n", v.seed, name)
+		}
+		if VerifyHDerivation(v.curve, H, []byte(v.seed+"x")) {
+			t.Fatalf("VerifyHDerivation accepts the H of %q on %s for another seed\n", v.seed, name)
+		}
+
+		G := ECPoint{v.curve.Params().Gx, v.curve.Params().Gy}
+		zkpcp, err := NewZKPCurveParams(v.curve, G, H)
+		if err != nil {
+			t.Fatalf("GenerateH on %s gives an H NewZKPCurveParams rejects: %v\n", name, err)
+		}
+		negH := zkpcp.Neg(H)
+		if VerifyHDerivation(v.curve, negH, []byte(v.seed)) {
+			t.Fatalf("VerifyHDerivation accepts -H on %s\n", name)
+		}
+	}
+
+	// the H of DeriveH does not come from any seed
+	H, _ := GenerateH(TestCurve.C, nil)
+	if H.Equal(TestCurve.H) {
+		t.Fatalf("GenerateH with an empty seed gives the H of DeriveH\n")
+	}
+	for _, bad := range []ECPoint{Zero, {}, TestCurve.G, {H.X, new(big.Int).Add(H.Y, big.NewInt(1))}} {
+		if VerifyHDerivation(TestCurve.C, bad, nil) {
+			t.Fatalf("VerifyHDerivation accepts %v\n", bad)
+		}
+	}
+}
+
+// vectorPoint decodes a compressed point from hex for the vectors
+func vectorPoint(t *testing.T, curve elliptic.Curve, h string) ECPoint {
+	b, _ := hex.DecodeString(h)
+	p, err := decompressPoint(curve, b)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	return p
+}
+
+func TestTwoCurveContexts(t *testing.T) {
+	ctxs := []ZKPCurveParams{ZKCurve, P256Curve}
+	value := big.NewInt(1)
+
+	proofs := make([]*ABCProof, len(ctxs))
+	CMs := make([]ECPoint, len(ctxs))
+	CMToks := make([]ECPoint, len(ctxs))
+	PKs := make([]ECPoint, len(ctxs))
+	for i, ctx := range ctxs {
+		PK, sk := KeyGen(ctx.C, ctx.H)
+		PKs[i] = PK
+		CM, r, err := ctx.PedCommit(value)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		CMToks[i] = ctx.Mult(PK, r)
+		CMs[i] = CM
+		proofs[i], err = ctx.NewABCProof(CM, CMToks[i], value, sk, Right)
+		if err != nil {
+			t.Fatalf("ABCProof failed to generate on curve %d: %v\n", i, err)
+		}
+	}
+
+	for i, ctx := range ctxs {
+		for j := range proofs {
+			ok, err := proofs[j].Verify(ctx, CMs[j], CMToks[j], PKs[j])
+			if i == j && (!ok || err != nil) {
+				t.Fatalf("ABCProof from curve %d did not verify on its own curve: %v\n", j, err)
+			}
+			if i != j && (ok || err == nil) {
+				t.Fatalf("ABCProof from curve %d verified on curve %d\n", j, i)
+			}
+		}
+	}
+}
+
+// TestTwoCurveEncodings makes sure a proof on either preset curve decodes to
+// points on that curve, so it verifies on its own curve after a round trip and
+// still fails on the other one
+func TestTwoCurveEncodings(t *testing.T) {
+	ctxs := []ZKPCurveParams{ZKCurve, P256Curve}
+	value := big.NewInt(1)
+
+	proofs := make([]*ABCProof, len(ctxs))
+	CMs := make([]ECPoint, len(ctxs))
+	CMToks := make([]ECPoint, len(ctxs))
+	PKs := make([]ECPoint, len(ctxs))
+	for i, ctx := range ctxs {
+		PK, sk := KeyGen(ctx.C, ctx.H)
+		PKs[i] = PK
+		CM, r, err := ctx.PedCommit(value)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		CMToks[i] = ctx.Mult(PK, r)
+		CMs[i] = CM
+
+		proof, err := ctx.NewABCProof(CM, CMToks[i], value, sk, Right)
+		if err != nil {
+			t.Fatalf("ABCProof failed to generate on curve %d: %v\n", i, err)
+		}
+		if proofs[i], err = NewABCProofFromBytes(proof.Bytes()); err != nil {
+			t.Fatalf("ABCProof from curve %d failed to decode: %v\n", i, err)
+		}
+		if !proofs[i].Equal(proof) || !ctx.C.IsOnCurve(proofs[i].B.X, proofs[i].B.Y) {
+			t.Fatalf("ABCProof from curve %d decodes to another proof\n", i)
+		}
+
+		js, err := json.Marshal(proof)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		fromJSON := new(ABCProof)
+		if err := json.Unmarshal(js, fromJSON); err != nil || !fromJSON.Equal(proof) {
+			t.Fatalf("ABCProof from curve %d does not round-trip through JSON: %v\n", i, err)
+		}
+
+		var q ECPoint
+		if b, _ := CM.MarshalBinary(); q.UnmarshalBinary(b) != nil || !q.Equal(CM) {
+			t.Fatalf("commitment on curve %d does not round-trip\n", i)
+		}
+	}
+
+	for i, ctx := range ctxs {
+		for j := range proofs {
+			ok, err := proofs[j].Verify(ctx, CMs[j], CMToks[j], PKs[j])
+			if i == j && (!ok || err != nil) {
+				t.Fatalf("decoded ABCProof from curve %d did not verify on its own curve: %v\n", j, err)
+			}
+			if i != j && (ok || err == nil) {
+				t.Fatalf("decoded ABCProof from curve %d verified on curve %d\n", j, i)
+			}
+		}
+	}
+}
@@ -1,40 +1,260 @@
 package zksigma
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math/big"
+	"strings"
 
 	"github.com/mit-dci/zksigma/btcec"
 	"github.com/mit-dci/zksigma/wire"
 )
 
-// ZKPCurveParams is zero knowledge proof curve and params struct, only one instance should be used
+// ZKPCurveParams is zero knowledge proof curve and params struct. Every proof
+// is generated and verified against one ZKPCurveParams, so several of them for
+// different curves can be used side by side, see NewZKPCurveParams.
 type ZKPCurveParams struct {
 	C       elliptic.Curve // Curve
 	G       ECPoint        // generator 1
 	H       ECPoint        // generator 2
+	N       *big.Int       // order of G and H
 	HPoints []ECPoint      // HPoints should be initialized with a pre-populated array of the ZKCurve's generator point H multiplied by 2^x where x = [0...63]
+
+	// NarrowChallenges makes Transcript reduce a single SHA-256 digest mod N
+	// for its challenges instead of using GenerateChallengeModN, the way it
+	// did before. Set it to generate or verify proofs made by those versions.
+	NarrowChallenges bool
+
+	tables        *baseTables // fixed base tables for G and H, see generatorTables
+	backend       CurveOps    // point arithmetic, nil for the default one, see WithBackend
+	challengeHash crypto.Hash // hash of the challenges, 0 for SHA-256, see WithChallengeHash
+	noGroupCheck  bool        // validPoints does not check IsInGroup, see WithoutGroupCheck
 }
 
 // DEBUG Indicates whether we output debug information while running the tests. Default off.
 var DEBUG = flag.Bool("debug1", false, "Debug output")
 
-type errorProof struct {
-	t string // proof type that failed
-	s string // error message
+// ErrorCode tells what kind of check a proof failed, see ProofError
+type ErrorCode int
+
+const (
+	// ErrCodeInvalidInput is for inputs that do not fit together, like a
+	// value and randomness that do not produce the commitment to prove
+	ErrCodeInvalidInput ErrorCode = iota + 1
+	// ErrCodeNotOnCurve is for points that are nil or not on the curve, or
+	// points of a statement that are not in the group of order N
+	ErrCodeNotOnCurve
+	// ErrCodeChallengeMismatch is for a proof whose challenge is not the one
+	// calculated from its transcript
+	ErrCodeChallengeMismatch
+	// ErrCodeEquationFailed is for a proof that fails one of its
+	// verification equations
+	ErrCodeEquationFailed
+	// ErrCodeMalformedProof is for proofs that are nil, incomplete or can
+	// not be decoded
+	ErrCodeMalformedProof
+	// ErrCodeValueOutOfRange is for scalars that are nil or not in [0, N)
+	ErrCodeValueOutOfRange
+	// ErrCodeInvalidSide is for a Side that is not Left or Right, or does not
+	// fit the witness that was passed with it
+	ErrCodeInvalidSide
+	// ErrCodeNonCanonicalScalar is for a proof with a scalar that is not in
+	// [0, N), which verifies like the reduced scalar but encodes differently
+	ErrCodeNonCanonicalScalar
+	// ErrCodeCanceled is for a batch that stopped before it was done because
+	// its context was canceled or its deadline passed
+	ErrCodeCanceled
+)
+
+// ProofError is the error returned when a proof fails to generate, verify or
+// decode. Use errors.Is with the sentinels below to check its Code, or
+// errors.As to get at Type and Equation. An error that a proof got from a
+// nested proof is wrapped, so the error of the innermost check is found too.
+type ProofError struct {
+	Code     ErrorCode
+	Type     string // proof type that failed
+	Equation int    // verification equation that failed counted from 1, 0 if none
+
+	msg string // error message
+	err error  // wrapped error, if any
+	sub bool   // failed because a proof it is made of failed
+}
+
+func (e *ProofError) Error() string {
+	if e.Type == "" {
+		return e.msg
+	}
+	return fmt.Sprintf("%v - %v\n", e.Type, e.msg)
+}
+
+// Unwrap returns the error of the nested proof that e wraps, if any
+func (e *ProofError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether target is the sentinel for the Code of e, or
+// ErrSubProofFailed for an e of a proof that failed in a nested proof
+func (e *ProofError) Is(target error) bool {
+	t, ok := target.(*ProofError)
+	if !ok || t.Type != "" {
+		return false
+	}
+	if t.sub {
+		return e.sub
+	}
+	return t.Code == e.Code
+}
+
+// The sentinels for the codes of ProofError, errors.Is(err, ErrNotOnCurve)
+// reports whether err has code ErrCodeNotOnCurve.
+var (
+	ErrInvalidInput      = &ProofError{Code: ErrCodeInvalidInput, msg: "invalid input"}
+	ErrNotOnCurve        = &ProofError{Code: ErrCodeNotOnCurve, msg: "point not on curve"}
+	ErrChallengeMismatch = &ProofError{Code: ErrCodeChallengeMismatch, msg: "challenge mismatch"}
+	ErrEquationFailed    = &ProofError{Code: ErrCodeEquationFailed, msg: "verification equation failed"}
+	ErrMalformedProof    = &ProofError{Code: ErrCodeMalformedProof, msg: "malformed proof"}
+
+	// ErrValueOutOfRange is matched by the errors the proof constructors
+	// return for a scalar that is nil or not in [0, N), or a secret key that
+	// is zero. Callers can tell them apart from failures of the random
+	// source with errors.Is.
+	ErrValueOutOfRange = &ProofError{Code: ErrCodeValueOutOfRange, msg: "value out of range"}
+
+	// ErrInvalidSide is matched by the errors NewABCProof and
+	// NewDisjunctiveProof return when the Side they are given can not be
+	// proved with the witness, see NewABCProofAuto and NewDisjunctiveProofAuto.
+	ErrInvalidSide = &ProofError{Code: ErrCodeInvalidSide, msg: "invalid side"}
+
+	// ErrNonCanonicalScalar is matched by the errors Verify and the decoding
+	// functions return for a scalar of a proof that is negative or not less
+	// than N. Adding N to a response would give another valid proof of the
+	// same statement, so a proof has a single accepted encoding.
+	ErrNonCanonicalScalar = &ProofError{Code: ErrCodeNonCanonicalScalar, msg: "non-canonical scalar"}
+
+	// ErrCanceled is matched by the errors of the batch functions taking a
+	// context.Context when the context is done before the batch is. The error
+	// wraps the one of the context, so errors.Is(err, context.Canceled) and
+	// errors.Is(err, context.DeadlineExceeded) work as well.
+	ErrCanceled = &ProofError{Code: ErrCodeCanceled, msg: "canceled"}
+
+	// ErrSubProofFailed is matched by the errors of a proof that failed to
+	// generate or verify because a proof it is made of did, like the
+	// DisjunctiveProof of an ABCProof or a statement of a ComposedProof. It
+	// has no code of its own: the error also matches the sentinel for the
+	// code of the nested error, e.g. ErrChallengeMismatch.
+	ErrSubProofFailed = &ProofError{msg: "sub-proof failed", sub: true}
+)
+
+// newError returns a ProofError with code for proof type t
+func newError(code ErrorCode, t, msg string) *ProofError {
+	return &ProofError{Code: code, Type: t, msg: msg}
+}
+
+func inputError(t, msg string) *ProofError {
+	return newError(ErrCodeInvalidInput, t, msg)
+}
+
+func curveError(t string) *ProofError {
+	return newError(ErrCodeNotOnCurve, t, "point not on curve")
+}
+
+func malformedError(t, msg string) *ProofError {
+	return newError(ErrCodeMalformedProof, t, msg)
+}
+
+func rangeError(t, msg string) *ProofError {
+	return newError(ErrCodeValueOutOfRange, t, msg)
+}
+
+func sideError(t, msg string) *ProofError {
+	return newError(ErrCodeInvalidSide, t, msg)
+}
+
+func nonCanonicalError(t, msg string) *ProofError {
+	return newError(ErrCodeNonCanonicalScalar, t, msg)
+}
+
+// canceledError returns an ErrCodeCanceled error wrapping err, the error of the
+// context of a batch that stopped after done of its total items
+func canceledError(t string, done, total int, err error) *ProofError {
+	return &ProofError{Code: ErrCodeCanceled, Type: t,
+		msg: fmt.Sprintf("canceled after %d of %d items: %v", done, total, err), err: err}
+}
+
+// wrapError returns a ProofError for proof type t that wraps err. It takes the
+// Code and Equation of err if err is a ProofError, otherwise it is an
+// ErrCodeInvalidInput.
+func wrapError(t, msg string, err error) *ProofError {
+	e := &ProofError{Code: ErrCodeInvalidInput, Type: t, msg: msg, err: err}
+	var inner *ProofError
+	if errors.As(err, &inner) {
+		e.Code = inner.Code
+		e.Equation = inner.Equation
+	}
+	return e
+}
+
+// subProofError is wrapError for err of a nested proof of proof type t, which
+// also matches ErrSubProofFailed
+func subProofError(t, msg string, err error) *ProofError {
+	e := wrapError(t, msg, err)
+	e.sub = true
+	return e
+}
+
+// checkScalars returns an ErrCodeValueOutOfRange error if one of ss is nil or not in [0, N).
+// names are the comma separated names of ss and t is the proof type, both are
+// only used in the error message.
+func (zkpcp ZKPCurveParams) checkScalars(t, names string, ss ...*big.Int) error {
+	for i, s := range ss {
+		name := strings.Split(names, ", ")[i]
+		if s == nil {
+			return rangeError(t, fmt.Sprintf("%s is nil", name))
+		}
+		if s.Sign() < 0 || s.Cmp(zkpcp.C.Params().N) >= 0 {
+			return rangeError(t, fmt.Sprintf("%s is not in [0, N)", name))
+		}
+	}
+	return nil
 }
 
-func (e *errorProof) Error() string {
-	return fmt.Sprintf("%v - %v\n", e.t, e.s)
+// canonicalScalars returns an ErrCodeNonCanonicalScalar error if one of the
+// scalars ss of a proof is not in [0, N), and an ErrCodeMalformedProof error if
+// one is nil. names are the comma separated names of ss like for
+// checkScalars, a single name ending in "[]" names the elements of a slice.
+func (zkpcp ZKPCurveParams) canonicalScalars(t, names string, ss ...*big.Int) error {
+	N := zkpcp.C.Params().N
+	for i, s := range ss {
+		if s != nil && s.Sign() >= 0 && s.Cmp(N) < 0 {
+			continue
+		}
+		var name string
+		if strings.HasSuffix(names, "[]") {
+			name = fmt.Sprintf("%s%d]", names[:len(names)-1], i)
+		} else {
+			name = strings.Split(names, ", ")[i]
+		}
+		if s == nil {
+			return malformedError(t, fmt.Sprintf("%s is nil", name))
+		}
+		return nonCanonicalError(t, fmt.Sprintf("%s is not in [0, N)", name))
+	}
+	return nil
 }
 
-func proofStatus(e *errorProof) int {
+func proofStatus(e *ProofError) int {
 	if *DEBUG && e != nil {
 		fmt.Printf("ERROR: %v \n", e.Error())
 		return -1
@@ -53,7 +273,7 @@ func logStuff(format string, args ...interface{}) {
 
 func KeyGen(curve elliptic.Curve, base ECPoint) (ECPoint, *big.Int) {
 
-	sk, err := rand.Int(rand.Reader, curve.Params().N)
+	sk, err := rand.Int(entropy(), curve.Params().N)
 	if err != nil {
 		panic(err)
 	}
@@ -71,84 +291,519 @@ type ECPoint struct {
 	X, Y *big.Int
 }
 
-// Zero is a cached variable containing ECPoint{big.NewInt(0), big.NewInt(0)}
+// Zero is a cached variable containing ECPoint{big.NewInt(0), big.NewInt(0)},
+// which stands for the identity of the curve group, the point at infinity. Add,
+// Sub and Mult treat it as the identity on every curve: p + Zero = p and
+// 0 * p = Zero.
 var Zero ECPoint // initialized in init()
 
-// Equal returns true if points p (self) and p2 (arg) are the same.
+// The point arithmetic of ZKPCurveParams (Add, Sub, Neg, Mult and MultiMult)
+// never changes its arguments and always returns a point with coordinates of
+// its own, not shared with an argument, the generators or Zero, so changing
+// them with the methods of big.Int affects nothing else. A point with a nil
+// coordinate, like the zero value ECPoint{}, is not a point at all, the
+// arithmetic returns ECPoint{nil, nil} for it and Bytes returns nil.
+
+// newZero returns the point at infinity with coordinates of its own
+func newZero() ECPoint {
+	return ECPoint{new(big.Int), new(big.Int)}
+}
+
+// isNil returns true if p has a nil coordinate
+func (p ECPoint) isNil() bool {
+	return p.X == nil || p.Y == nil
+}
+
+// IsZero returns true if p is Zero, the identity. The zero value ECPoint{},
+// which has nil coordinates, is not a point at all and not Zero.
+func (p ECPoint) IsZero() bool {
+	return p.X != nil && p.Y != nil && p.X.Sign() == 0 && p.Y.Sign() == 0
+}
+
+// Equal returns true if points p (self) and p2 (arg) are the same. It compares
+// the coordinates in constant time like EqualCT, since provers compare points
+// that depend on their witness. A point with a nil coordinate, which is not a
+// point at all, is only equal to one with the same coordinates nil.
 func (p ECPoint) Equal(p2 ECPoint) bool {
-	if p.X.Cmp(p2.X) == 0 && p2.Y.Cmp(p2.Y) == 0 {
-		return true
+	if p.X == nil || p.Y == nil || p2.X == nil || p2.Y == nil {
+		return samePoint(p, p2)
+	}
+	return p.EqualCT(p2)
+}
+
+// ConstantTimeVerify makes the Verify functions compare challenges and points
+// in constant time. Turn it off to use the faster variable-time comparisons
+// when verifying proofs that do not come from an attacker.
+var ConstantTimeVerify = true
+
+// fixedWidth returns the minimal big-endian encoding of v left padded with
+// zeros to width bytes, or just the minimal encoding if it is wider.
+func fixedWidth(v *big.Int, width int) []byte {
+	return appendFixedWidth(nil, v, width)
+}
+
+// appendFixedWidth appends fixedWidth(v, width) to dst. It only allocates if
+// dst has no room for it, so a buffer on the stack keeps the hot paths of the
+// Verify functions free of garbage.
+func appendFixedWidth(dst []byte, v *big.Int, width int) []byte {
+	if v != nil && v.BitLen() > 8*width {
+		return append(dst, v.Bytes()...)
+	}
+	n := len(dst)
+	dst = append(dst, make([]byte, width)...)
+	if v != nil {
+		v.FillBytes(dst[n:])
+	}
+	return dst
+}
+
+// signOf is v.Sign(), with 0 for a nil v like appendFixedWidth
+func signOf(v *big.Int) int {
+	if v == nil {
+		return 0
+	}
+	return v.Sign()
+}
+
+// ctWidths are the byte lengths of the fields and orders of the curves
+// zksigma runs on: 256 bit curves like secp256k1 and P-256, P-384 and P-521
+var ctWidths = [...]int{32, 48, 66}
+
+// ctWidth returns the first of ctWidths that holds all of vs, so the values
+// of one curve are compared at the width of that curve and not at the length
+// of the widest one. Only values wider than any curve get their own length.
+func ctWidth(vs ...*big.Int) int {
+	bits := 0
+	for _, v := range vs {
+		if v != nil && v.BitLen() > bits {
+			bits = v.BitLen()
+		}
+	}
+	for _, w := range ctWidths {
+		if bits <= 8*w {
+			return w
+		}
+	}
+	return (bits + 7) / 8
+}
+
+// EqualCT compares fixed-width encodings of the coordinates of p and p2 in
+// constant time, padded to the byte length of the curve they are on (see
+// ctWidth). It is Equal for points whose coordinates are not nil.
+func (p ECPoint) EqualCT(p2 ECPoint) bool {
+	width := ctWidth(p.X, p.Y, p2.X, p2.Y)
+	var bx1, bx2, by1, by2 [66]byte
+	x1, x2 := appendFixedWidth(bx1[:0], p.X, width), appendFixedWidth(bx2[:0], p2.X, width)
+	y1, y2 := appendFixedWidth(by1[:0], p.Y, width), appendFixedWidth(by2[:0], p2.Y, width)
+	// the encodings drop the sign, so -X encodes like X
+	if len(x1) != len(x2) || len(y1) != len(y2) ||
+		signOf(p.X) != signOf(p2.X) || signOf(p.Y) != signOf(p2.Y) {
+		return false
+	}
+	return subtle.ConstantTimeCompare(x1, x2)&subtle.ConstantTimeCompare(y1, y2) == 1
+}
+
+// pointsEqual compares two points the way ConstantTimeVerify asks us to
+func pointsEqual(p, p2 ECPoint) bool {
+	if ConstantTimeVerify {
+		return p.EqualCT(p2)
+	}
+	return samePoint(p, p2)
+}
+
+// scalarsEqual compares two scalars the way ConstantTimeVerify asks us to. A
+// nil scalar, as in a partially decoded proof, is only equal to nil.
+func scalarsEqual(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
-	return false
+	if !ConstantTimeVerify {
+		return a.Cmp(b) == 0
+	}
+	width := ctWidth(a, b)
+	var ba, bb [66]byte
+	a1, b1 := appendFixedWidth(ba[:0], a, width), appendFixedWidth(bb[:0], b, width)
+	if len(a1) != len(b1) || a.Sign() != b.Sign() {
+		return false
+	}
+	return subtle.ConstantTimeCompare(a1, b1) == 1
+}
+
+// cloneScalar returns a fresh copy of s, or nil for nil, for the Clone
+// methods of the proofs
+func cloneScalar(s *big.Int) *big.Int {
+	if s == nil {
+		return nil
+	}
+	return new(big.Int).Set(s)
+}
+
+// clonePoint returns a copy of p with fresh coordinates
+func clonePoint(p ECPoint) ECPoint {
+	return ECPoint{cloneScalar(p.X), cloneScalar(p.Y)}
+}
+
+// clonePoints returns a copy of ps with fresh coordinates
+func clonePoints(ps []ECPoint) []ECPoint {
+	if ps == nil {
+		return nil
+	}
+	c := make([]ECPoint, len(ps))
+	for i, p := range ps {
+		c[i] = clonePoint(p)
+	}
+	return c
+}
+
+// cloneScalars returns a copy of ss with fresh scalars
+func cloneScalars(ss []*big.Int) []*big.Int {
+	if ss == nil {
+		return nil
+	}
+	c := make([]*big.Int, len(ss))
+	for i, s := range ss {
+		c[i] = cloneScalar(s)
+	}
+	return c
+}
+
+// sameScalar is a variable time comparison of a and b for the Equal methods
+// of the proofs, unlike scalarsEqual it takes nil to be equal only to nil
+func sameScalar(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0
+}
+
+// samePoint compares the coordinates of p and p2 with sameScalar
+func samePoint(p, p2 ECPoint) bool {
+	return sameScalar(p.X, p2.X) && sameScalar(p.Y, p2.Y)
+}
+
+// samePoints compares ps and ps2 point by point with samePoint
+func samePoints(ps, ps2 []ECPoint) bool {
+	if len(ps) != len(ps2) {
+		return false
+	}
+	for i := range ps {
+		if !samePoint(ps[i], ps2[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameScalars compares ss and ss2 scalar by scalar with sameScalar
+func sameScalars(ss, ss2 []*big.Int) bool {
+	if len(ss) != len(ss2) {
+		return false
+	}
+	for i := range ss {
+		if !sameScalar(ss[i], ss2[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// BatchModInverse returns the inverses of values mod mod, like calling
+// ModInverse for each of them, but with Montgomery's trick: the values are
+// multiplied up, the product is inverted once and the single inverses are
+// peeled off it, one inversion and 3(n-1) multiplications in all. A value
+// without an inverse, such as 0, gets nil and does not spoil the others.
+func BatchModInverse(values []*big.Int, mod *big.Int) []*big.Int {
+	invs := make([]*big.Int, len(values))
+
+	// prefix[i] is the product of the invertible values before i, nil if
+	// values[i] is 0 mod mod
+	prefix := make([]*big.Int, len(values))
+	acc := big.NewInt(1)
+	for i, v := range values {
+		if new(big.Int).Mod(v, mod).Sign() == 0 {
+			continue
+		}
+		prefix[i] = acc
+		acc = new(big.Int).Mul(acc, v)
+		acc.Mod(acc, mod)
+	}
+
+	accInv := new(big.Int).ModInverse(acc, mod)
+	if accInv == nil {
+		// some value shares a factor with mod, which can only happen if mod
+		// is not prime, so find out which one by one
+		for i, v := range values {
+			if prefix[i] != nil {
+				invs[i] = new(big.Int).ModInverse(v, mod)
+			}
+		}
+		return invs
+	}
+
+	for i := len(values) - 1; i >= 0; i-- {
+		if prefix[i] == nil {
+			continue
+		}
+		// accInv is the inverse of the product of the values up to i
+		invs[i] = new(big.Int).Mul(accInv, prefix[i])
+		invs[i].Mod(invs[i], mod)
+		accInv.Mul(accInv, values[i])
+		accInv.Mod(accInv, mod)
+	}
+	return invs
 }
 
 // Mult multiplies point p by scalar s and returns the resulting point
 func (zkpcp ZKPCurveParams) Mult(p ECPoint, s *big.Int) ECPoint {
 
-	if p.X == nil && p.Y == nil { // Multiplying a nil point is "pointless". ha.
+	if p.isNil() { // Multiplying a nil point is "pointless". ha.
 		return ECPoint{nil, nil}
 	}
 
-	modS := new(big.Int).Mod(s, zkpcp.C.Params().N)
+	// a nil scalar, as from ModInverse(0), is 0 like a multiple of N, and
+	// any multiple of the identity is the identity
+	if s == nil || p.IsZero() {
+		return newZero()
+	}
+	modS := s
+	if N := zkpcp.C.Params().N; s.Sign() < 0 || s.Cmp(N) >= 0 {
+		modS = new(big.Int).Mod(s, N)
+	}
+	if modS.Sign() == 0 {
+		return newZero()
+	}
+
+	if p.X.Cmp(zkpcp.C.Params().Gx) == 0 && p.Y.Cmp(zkpcp.C.Params().Gy) == 0 {
+		X, Y := zkpcp.ops().ScalarBaseMult(modS.Bytes())
+		return ECPoint{X, Y}
+	}
 
-	// if p.Equal(Zero) {
-	// 	logStuff("Mult: Trying to multiple with zero-point!\n")
-	// 	return p
-	// } else
-	if p.Equal(zkpcp.G) {
-		X, Y := zkpcp.C.ScalarBaseMult(modS.Bytes())
+	if kc, ok := zkpcp.C.(*btcec.KoblitzCurve); ok && zkpcp.backend == nil && p.Equal(btcecH) {
+		X, Y := kc.ScalarBaseMultH(modS.Bytes())
 		return ECPoint{X, Y}
 	}
 
-	if p.Equal(zkpcp.H) {
-		X, Y := zkpcp.C.(*btcec.KoblitzCurve).ScalarBaseMultH(modS.Bytes())
+	if kc, t, ok := zkpcp.tableFor(p); ok {
+		X, Y := kc.ScalarMultTables([]*btcec.FixedBaseTable{t}, [][]byte{modS.Bytes()})
 		return ECPoint{X, Y}
 	}
 
-	X, Y := zkpcp.C.ScalarMult(p.X, p.Y, modS.Bytes())
+	X, Y := zkpcp.ops().ScalarMult(p.X, p.Y, modS.Bytes())
 	return ECPoint{X, Y}
 }
 
 // Add adds points p and p2 and returns the resulting point
 func (zkpcp ZKPCurveParams) Add(p, p2 ECPoint) ECPoint {
-	// if p.Equal(Zero) && p2.Equal(Zero) {
-	// 	return Zero
-	// } else
-	if p.Equal(Zero) && zkpcp.C.IsOnCurve(p2.X, p2.Y) {
+	if p.isNil() || p2.isNil() {
+		return ECPoint{nil, nil}
+	}
+	if p.IsZero() {
+		return clonePoint(p2)
+	} else if p2.IsZero() {
+		return clonePoint(p)
+	}
+	return zkpcp.add(p, p2)
+}
+
+// add is Add for the partial sums of MultiMult, which returns p2 for p = Zero
+// and p for p2 = Zero
+func (zkpcp ZKPCurveParams) add(p, p2 ECPoint) ECPoint {
+	if p.IsZero() {
 		return p2
-	} else if p2.Equal(Zero) && zkpcp.C.IsOnCurve(p.X, p.Y) {
+	} else if p2.IsZero() {
 		return p
 	}
 
-	X, Y := zkpcp.C.Add(p.X, p.Y, p2.X, p2.Y)
+	X, Y := zkpcp.ops().Add(p.X, p.Y, p2.X, p2.Y)
 
 	return ECPoint{X, Y}
 }
 
+// Sub subtracts point p2 from p and returns the resulting point
 func (zkpcp ZKPCurveParams) Sub(p, p2 ECPoint) ECPoint {
-	// if p.Equal(Zero) && p2.Equal(Zero) {
-	// 	return Zero
-	// } else
-	if p.Equal(Zero) && zkpcp.C.IsOnCurve(p2.X, p2.Y) {
+	if p.isNil() || p2.isNil() {
+		return ECPoint{nil, nil}
+	}
+	if p.IsZero() {
 		return zkpcp.Neg(p2)
-	} else if p2.Equal(Zero) && zkpcp.C.IsOnCurve(p.X, p.Y) {
-		return p
+	} else if p2.IsZero() {
+		return clonePoint(p)
 	}
 
-	temp := zkpcp.Neg(p2)
-	X, Y := zkpcp.C.Add(p.X, p.Y, temp.X, temp.Y)
+	temp := zkpcp.neg(p2)
+	X, Y := zkpcp.ops().Add(p.X, p.Y, temp.X, temp.Y)
 
 	return ECPoint{X, Y}
 }
 
+// multiMultBuf is the number of terms MultiMult collects without allocating
+// buffers for them
+const multiMultBuf = 8
+
+// MultiMult returns the sum of scalars[i] * points[i], which is faster than
+// calling Mult for every point and adding the results up. On secp256k1 the
+// points with fixed base tables, like G and H, are multiplied with their tables
+// and all others at once by Straus' method, which shares the doublings between
+// them. Other curves and backends fall back to Mult and Add.
+//
+// Terms with the point at infinity or a zero scalar are skipped, a nil scalar
+// counts as zero. Like Mult it returns ECPoint{nil, nil} if one of points is
+// nil. It panics if points and scalars have different lengths.
+func (zkpcp ZKPCurveParams) MultiMult(points []ECPoint, scalars []*big.Int) ECPoint {
+	if len(points) != len(scalars) {
+		panic(fmt.Sprintf("zksigma: MultiMult of %d points and %d scalars", len(points), len(scalars)))
+	}
+
+	kc, isKoblitz := zkpcp.C.(*btcec.KoblitzCurve)
+	isKoblitz = isKoblitz && zkpcp.backend == nil
+	total := Zero
+
+	// the terms are collected in buffers on the stack, which are big enough
+	// for the proofs of this package, the encodings of the scalars share one
+	// allocation and reduced is reused for every scalar not in [0, N)
+	var (
+		tsBuf        [multiMultBuf]*btcec.FixedBaseTable
+		xsBuf, ysBuf [multiMultBuf]*big.Int
+		tksBuf       [multiMultBuf][]byte
+		ksBuf        [multiMultBuf][]byte
+		reduced      big.Int
+	)
+	ts, xs, ys := tsBuf[:0], xsBuf[:0], ysBuf[:0]
+	tks, ks := tksBuf[:0], ksBuf[:0]
+	N := zkpcp.C.Params().N
+	var kBytes []byte
+	if isKoblitz {
+		kBytes = make([]byte, 32*len(points))
+	}
+	for i, p := range points {
+		if p.X == nil || p.Y == nil {
+			return ECPoint{nil, nil}
+		}
+		if scalars[i] == nil || p.Equal(Zero) {
+			continue
+		}
+		// negative scalars down to -N, like the negated challenges of
+		// the verification equations, are reduced without a division
+		k := scalars[i]
+		switch {
+		case k.Sign() >= 0 && k.Cmp(N) < 0:
+		case k.Sign() < 0 && k.CmpAbs(N) <= 0:
+			k = reduced.Add(k, N)
+		default:
+			k = reduced.Mod(k, N)
+		}
+		if k.Sign() == 0 {
+			continue
+		}
+		if !isKoblitz {
+			total = zkpcp.add(total, zkpcp.Mult(p, k))
+			continue
+		}
+		// the minimal big-endian encoding of k, like k.Bytes()
+		kb := k.FillBytes(kBytes[32*i : 32*(i+1)])[32-(k.BitLen()+7)/8:]
+		if _, t, ok := zkpcp.tableFor(p); ok {
+			ts = append(ts, t)
+			tks = append(tks, kb)
+			continue
+		}
+		xs, ys, ks = append(xs, p.X), append(ys, p.Y), append(ks, kb)
+	}
+
+	if len(ts) > 0 {
+		X, Y := kc.ScalarMultTables(ts, tks)
+		total = zkpcp.add(total, ECPoint{X, Y})
+	}
+	if len(xs) == 1 {
+		X, Y := kc.ScalarMult(xs[0], ys[0], ks[0])
+		total = zkpcp.add(total, ECPoint{X, Y})
+	} else if len(xs) > 1 {
+		X, Y := kc.MultiScalarMult(xs, ys, ks)
+		total = zkpcp.add(total, ECPoint{X, Y})
+	}
+	if total.IsZero() {
+		return newZero()
+	}
+	return total
+}
+
+// IsOnCurve returns true if p is a point on the curve of zkpcp. Both
+// coordinates have to be in [0, P). The point at infinity (Zero) is not on
+// the curve, see IsValid.
+func (p ECPoint) IsOnCurve(zkpcp ZKPCurveParams) bool {
+	if p.X == nil || p.Y == nil || p.Equal(Zero) {
+		return false
+	}
+	P := zkpcp.C.Params().P
+	if p.X.Sign() < 0 || p.X.Cmp(P) >= 0 || p.Y.Sign() < 0 || p.Y.Cmp(P) >= 0 {
+		return false
+	}
+	return zkpcp.ops().IsOnCurve(p.X, p.Y)
+}
+
+// IsValid returns true if p is a point on the curve of zkpcp or the point at
+// infinity (Zero).
+func (p ECPoint) IsValid(zkpcp ZKPCurveParams) bool {
+	if p.X == nil || p.Y == nil {
+		return false
+	}
+	return p.Equal(Zero) || p.IsOnCurve(zkpcp)
+}
+
+// The Verify functions check every point before doing any arithmetic with it.
+// Points passed in by the caller (commitments, public keys, bases) have to be
+// valid and in the group of G and H (see IsInGroup): the point at infinity is
+// a legal group element and can be part of a statement. Points carried inside a proof have to be on the curve. An
+// honest prover only produces the point at infinity there with negligible
+// probability, and accepting it would let a forged proof cancel out terms of
+// the verification equations.
+
+// validPoints returns true if all points ps are valid (see IsValid) and in the
+// group of order N, unless zkpcp is WithoutGroupCheck
+func (zkpcp ZKPCurveParams) validPoints(ps ...ECPoint) bool {
+	for _, p := range ps {
+		if !p.IsValid(zkpcp) || !(zkpcp.noGroupCheck || zkpcp.inGroup(p)) {
+			return false
+		}
+	}
+	return true
+}
+
+// onCurve returns true if all points ps are on the curve of zkpcp (see IsOnCurve)
+func (zkpcp ZKPCurveParams) onCurve(ps ...ECPoint) bool {
+	for _, p := range ps {
+		if !p.IsOnCurve(zkpcp) {
+			return false
+		}
+	}
+	return true
+}
+
 // Neg returns the additive inverse of point p
 func (zkpcp ZKPCurveParams) Neg(p ECPoint) ECPoint {
+	if p.isNil() {
+		return ECPoint{nil, nil}
+	}
+	neg := zkpcp.neg(p)
+	neg.X = new(big.Int).Set(p.X)
+	return neg
+}
+
+// neg is Neg for the points Sub only passes on to Add, which shares X with p
+func (zkpcp ZKPCurveParams) neg(p ECPoint) ECPoint {
+	P := zkpcp.C.Params().P
+	if p.Y.Sign() > 0 && p.Y.Cmp(P) < 0 {
+		return ECPoint{p.X, new(big.Int).Sub(P, p.Y)}
+	}
 	negY := new(big.Int).Neg(p.Y)
-	modValue := new(big.Int).Mod(negY, zkpcp.C.Params().P)
-	return ECPoint{p.X, modValue}
+	return ECPoint{p.X, negY.Mod(negY, P)}
 }
 
+// Bytes returns the big-endian bytes of X followed by those of Y, or nil if p
+// has a nil coordinate
 func (p ECPoint) Bytes() []byte {
+	if p.isNil() {
+		return nil
+	}
 	return append(p.X.Bytes(), p.Y.Bytes()...)
 }
 
@@ -187,10 +842,14 @@ func WriteBigInt(w io.Writer, b *big.Int) error {
 
 // ReadBigInt reads a big.Int from io.Reader r
 func ReadBigInt(r io.Reader) (*big.Int, error) {
-	bBytes, err := wire.ReadVarBytes(r, 32, "")
+	// the sign byte and up to 32 bytes of the value
+	bBytes, err := wire.ReadVarBytes(r, 33, "big.Int")
 	if err != nil {
 		return nil, err
 	}
+	if len(bBytes) == 0 || bBytes[0] > 0x01 {
+		return nil, malformedError("ReadBigInt", "missing or invalid sign byte")
+	}
 	newInt := big.NewInt(0).SetBytes(bBytes[1:])
 	if bBytes[0] == 0x01 {
 		newInt.Neg(newInt)
@@ -198,11 +857,454 @@ func ReadBigInt(r io.Reader) (*big.Int, error) {
 	return newInt, nil
 }
 
+// compressPoint returns the SEC1 compressed encoding of p. The point at
+// infinity (Zero) is encoded as the single byte 0x00.
+func compressPoint(curve elliptic.Curve, p ECPoint) []byte {
+	if p.X == nil || p.Y == nil || p.Equal(Zero) {
+		return []byte{0x00}
+	}
+	byteLen := (curve.Params().BitSize + 7) / 8
+	b := make([]byte, 1+byteLen)
+	b[0] = 0x02 | byte(p.Y.Bit(0))
+	xBytes := p.X.Bytes()
+	copy(b[1+byteLen-len(xBytes):], xBytes)
+	return b
+}
+
+// decompressPoint decodes a point produced by compressPoint and makes sure
+// it lies on curve.
+func decompressPoint(curve elliptic.Curve, b []byte) (ECPoint, error) {
+	return decodePoint(curve, ellipticOps{curve}, b)
+}
+
+// decodePoint is decompressPoint with the point decoded by ops
+func decodePoint(curve elliptic.Curve, ops CurveOps, b []byte) (ECPoint, error) {
+	if len(b) == 1 && b[0] == 0x00 {
+		return newZero(), nil
+	}
+	byteLen := (curve.Params().BitSize + 7) / 8
+	if len(b) != 1+byteLen || (b[0] != 0x02 && b[0] != 0x03) {
+		return Zero, malformedError("decompressPoint", "invalid compressed point encoding")
+	}
+	// X + P may fit in byteLen bytes too, but only X is the encoding
+	if new(big.Int).SetBytes(b[1:]).Cmp(curve.Params().P) >= 0 {
+		return Zero, malformedError("decompressPoint", "non-canonical point encoding, X is not less than P")
+	}
+	X, Y, ok := ops.Decompress(b)
+	if !ok {
+		return Zero, curveError("decompressPoint")
+	}
+	return ECPoint{X, Y}, nil
+}
+
+// ====== Proof encoding helpers =========
+
+// encodingCurves are the curves whose points a proof encoding can carry, since
+// json.Unmarshaler and the FromBytes functions give us no way to pass in
+// ZKPCurveParams. An encoded point has the index of its curve in the upper
+// half of its prefix byte: 0x02 and 0x03 like in SEC1 for secp256k1, 0x12 and
+// 0x13 for P-256. All of them have coordinates and scalars of 32 bytes.
+var encodingCurves = []elliptic.Curve{btcec.S256(), elliptic.P256()}
+
+// encodingN is the largest order of encodingCurves. A decoded scalar is
+// checked against it, Verify checks it against the order of its curve.
+var encodingN = func() *big.Int {
+	N := new(big.Int)
+	for _, c := range encodingCurves {
+		if c.Params().N.Cmp(N) > 0 {
+			N = c.Params().N
+		}
+	}
+	return N
+}()
+
+// encodePoint returns the compressed form of p with the index of the first of
+// encodingCurves it is on in the prefix byte. A point on none of them is
+// encoded for secp256k1 and fails to decode.
+func encodePoint(p ECPoint) []byte {
+	id := 0
+	if p.X != nil && p.Y != nil && p.X.Sign() >= 0 && p.Y.Sign() >= 0 {
+		for i, c := range encodingCurves {
+			if c.IsOnCurve(p.X, p.Y) {
+				id = i
+				break
+			}
+		}
+	}
+	b := compressPoint(encodingCurves[id], p)
+	if len(b) > 1 {
+		b[0] |= byte(id) << 4
+	}
+	return b
+}
+
+// decodePointAny decodes a point encoded with encodePoint and makes sure it
+// lies on the curve its prefix byte names
+func decodePointAny(b []byte) (ECPoint, error) {
+	if len(b) == 0 || b[0] == 0x00 {
+		return decompressPoint(encodingCurves[0], b)
+	}
+	id := int(b[0] >> 4)
+	if id >= len(encodingCurves) {
+		return Zero, malformedError("decompressPoint", fmt.Sprintf("unknown curve %d in point encoding", id))
+	}
+	sec1 := append([]byte{b[0] & 0x0f}, b[1:]...)
+	return decompressPoint(encodingCurves[id], sec1)
+}
+
+// pointToHex encodes p as hex of encodePoint
+func pointToHex(p ECPoint) string {
+	return hex.EncodeToString(encodePoint(p))
+}
+
+// pointFromHex decodes a point encoded with pointToHex, field is only
+// used for the error message
+func pointFromHex(s, field string) (ECPoint, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return Zero, malformedError("UnmarshalJSON", fmt.Sprintf("%s is not valid hex", field))
+	}
+	p, err := decodePointAny(b)
+	if err != nil {
+		return Zero, wrapError("UnmarshalJSON", fmt.Sprintf("%s: %v", field, err.(*ProofError).msg), err)
+	}
+	return p, nil
+}
+
+// ToHex returns the hex encoding of the compressed form of p, the point at
+// infinity is "00". Points with nil or out of range coordinates have no
+// encoding and give "".
+func (p ECPoint) ToHex() string {
+	if p.X == nil || p.Y == nil {
+		return ""
+	}
+	if p.X.Sign() < 0 || p.Y.Sign() < 0 || p.X.BitLen() > 8*fixedScalarLen {
+		return ""
+	}
+	return hex.EncodeToString(compressPoint(encodingCurves[0], p))
+}
+
+// ECPointFromHex decodes a point encoded with ToHex and makes sure it lies on
+// the curve of zkpcp
+func ECPointFromHex(zkpcp ZKPCurveParams, s string) (ECPoint, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return Zero, malformedError("ECPointFromHex", "not valid hex")
+	}
+	p, err := zkpcp.decompress(b)
+	if err != nil {
+		return Zero, wrapError("ECPointFromHex", err.(*ProofError).msg, err)
+	}
+	return p, nil
+}
+
+// CompressedBytes returns the SEC1 compressed encoding of p on the curve of
+// zkpcp: 0x02 or 0x03 for the parity of Y, then X padded to the size of the
+// field, 33 bytes on secp256k1 and P-256. The point at infinity is the single
+// byte 0x00. Points with nil, negative or out of range coordinates have no
+// encoding and give nil.
+func (p ECPoint) CompressedBytes(zkpcp ZKPCurveParams) []byte {
+	if p.X == nil || p.Y == nil || p.X.Sign() < 0 || p.Y.Sign() < 0 ||
+		p.X.BitLen() > zkpcp.C.Params().BitSize {
+		return nil
+	}
+	return zkpcp.compress(p)
+}
+
+// DecompressPoint decodes a point encoded with CompressedBytes and recovers
+// its Y. It fails with ErrMalformedProof for an encoding of the wrong length,
+// with a bad parity byte or with an X that is not less than the field prime,
+// and with ErrNotOnCurve if no point on the curve of zkpcp has that X.
+func DecompressPoint(zkpcp ZKPCurveParams, b []byte) (ECPoint, error) {
+	p, err := zkpcp.decompress(b)
+	if err != nil {
+		return Zero, wrapError("DecompressPoint", err.(*ProofError).msg, err)
+	}
+	return p, nil
+}
+
+// String returns the hex encoding of p (see ToHex), or its raw coordinates if
+// it has none
+func (p ECPoint) String() string {
+	if h := p.ToHex(); h != "" {
+		return h
+	}
+	return fmt.Sprintf("ECPoint{%x, %x}", p.X, p.Y)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, p is encoded in
+// compressed SEC1 form with the index of its curve in the prefix byte like in
+// the proof encodings, the point at infinity is 0x00. This is also the
+// encoding gob uses for ECPoint.
+func (p ECPoint) MarshalBinary() ([]byte, error) {
+	if p.X == nil || p.Y == nil || p.X.Sign() < 0 || p.Y.Sign() < 0 ||
+		p.X.BitLen() > 8*fixedScalarLen {
+		return nil, inputError("ECPoint.MarshalBinary", "point has no encoding")
+	}
+	return encodePoint(p), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It fails for an
+// encoding of the wrong length, with a bad parity byte or an unknown curve,
+// and if the point is not on the curve its prefix byte names.
+func (p *ECPoint) UnmarshalBinary(b []byte) error {
+	q, err := decodePointAny(b)
+	if err != nil {
+		return wrapError("ECPoint.UnmarshalBinary", err.(*ProofError).msg, err)
+	}
+	*p = q
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, p is encoded as the hex of
+// MarshalBinary, so ECPoint can be used as a key in JSON maps
+func (p ECPoint) MarshalText() ([]byte, error) {
+	b, err := p.MarshalBinary()
+	if err != nil {
+		return nil, wrapError("ECPoint.MarshalText", err.(*ProofError).msg, err)
+	}
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for points encoded with
+// MarshalText, with the same checks as UnmarshalBinary
+func (p *ECPoint) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(string(text))
+	if err != nil {
+		return malformedError("ECPoint.UnmarshalText", "not valid hex")
+	}
+	q, err := decodePointAny(b)
+	if err != nil {
+		return wrapError("ECPoint.UnmarshalText", err.(*ProofError).msg, err)
+	}
+	*p = q
+	return nil
+}
+
+// scalarString formats s as hex for String and error messages
+func scalarString(s *big.Int) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%x", s)
+}
+
+// proofString formats a proof of type t with one labeled field per line,
+// fields holds pairs of field name and value. Nested proofs are indented.
+func proofString(t string, fields ...interface{}) string {
+	var b strings.Builder
+	b.WriteString(t + "{\n")
+	for i := 0; i+1 < len(fields); i += 2 {
+		var v string
+		switch f := fields[i+1].(type) {
+		case *big.Int:
+			v = scalarString(f)
+		case fmt.Stringer:
+			v = strings.Replace(f.String(), "\n", "\n  ", -1)
+		default:
+			v = fmt.Sprint(f)
+		}
+		fmt.Fprintf(&b, "  %v: %s\n", fields[i], v)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// shortHex truncates hex string h to a few characters for error messages
+func shortHex(h string) string {
+	if len(h) > 10 {
+		return h[:10] + ".."
+	}
+	return h
+}
+
+// equationError is the error for verification equation n of proof type t,
+// written as eq ("lhs != rhs"), failing. It shows the start of both sides.
+func equationError(t string, n int, eq string, lhs, rhs ECPoint) *ProofError {
+	e := newError(ErrCodeEquationFailed, t, fmt.Sprintf("%s (%s != %s)", eq, shortHex(lhs.String()), shortHex(rhs.String())))
+	e.Equation = n
+	return e
+}
+
+// challengeError is the error for a proof challenge that does not agree
+// with the calculated one, it shows the start of both
+func challengeError(t, msg string, calculated, proof *big.Int) *ProofError {
+	return newError(ErrCodeChallengeMismatch, t, fmt.Sprintf("%s (calculated %s, proof %s)", msg,
+		shortHex(scalarString(calculated)), shortHex(scalarString(proof))))
+}
+
+// scalarToHex encodes s mod encodingN as big-endian hex
+func scalarToHex(s *big.Int) string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(new(big.Int).Mod(s, encodingN).Bytes())
+}
+
+// scalarFromHex decodes a scalar encoded with scalarToHex and checks that
+// it lies in [0, encodingN) and has no leading zero bytes, like scalarToHex
+// writes it
+func scalarFromHex(s, field string) (*big.Int, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, malformedError("UnmarshalJSON", fmt.Sprintf("%s is not valid hex", field))
+	}
+	if len(b) > 0 && b[0] == 0 {
+		return nil, nonCanonicalError("UnmarshalJSON", fmt.Sprintf("%s has leading zero bytes", field))
+	}
+	v := new(big.Int).SetBytes(b)
+	if v.Cmp(encodingN) >= 0 {
+		return nil, nonCanonicalError("UnmarshalJSON", fmt.Sprintf("%s is not in [0, N)", field))
+	}
+	return v, nil
+}
+
+// pointsToHex encodes every point of ps with pointToHex
+func pointsToHex(ps []ECPoint) []string {
+	hs := make([]string, len(ps))
+	for i, p := range ps {
+		hs[i] = pointToHex(p)
+	}
+	return hs
+}
+
+// pointsFromHex decodes points encoded with pointsToHex, the error for a bad
+// one names it as field[i]
+func pointsFromHex(hs []string, field string) ([]ECPoint, error) {
+	ps := make([]ECPoint, len(hs))
+	for i, h := range hs {
+		p, err := pointFromHex(h, fmt.Sprintf("%s[%d]", field, i))
+		if err != nil {
+			return nil, err
+		}
+		ps[i] = p
+	}
+	return ps, nil
+}
+
+// scalarsToHex encodes every scalar of ss with scalarToHex
+func scalarsToHex(ss []*big.Int) []string {
+	hs := make([]string, len(ss))
+	for i, s := range ss {
+		hs[i] = scalarToHex(s)
+	}
+	return hs
+}
+
+// scalarsFromHex decodes scalars encoded with scalarsToHex, the error for a
+// bad one names it as field[i]
+func scalarsFromHex(hs []string, field string) ([]*big.Int, error) {
+	ss := make([]*big.Int, len(hs))
+	for i, h := range hs {
+		s, err := scalarFromHex(h, fmt.Sprintf("%s[%d]", field, i))
+		if err != nil {
+			return nil, err
+		}
+		ss[i] = s
+	}
+	return ss, nil
+}
+
+// proofEncodingVersion is the first byte of every fixed-size proof encoding
+const proofEncodingVersion byte = 0x01
+
+// fixedPointLen and fixedScalarLen are the widths of points and scalars in
+// the fixed-size proof encoding
+var (
+	fixedScalarLen = (encodingCurves[0].Params().BitSize + 7) / 8
+	fixedPointLen  = 1 + fixedScalarLen
+)
+
+// writeFixedPoint writes p encoded with encodePoint, the point at infinity is
+// written as fixedPointLen zero bytes
+func writeFixedPoint(buf *bytes.Buffer, p ECPoint) {
+	b := encodePoint(p)
+	if len(b) == 1 {
+		b = make([]byte, fixedPointLen)
+	}
+	buf.Write(b)
+}
+
+// writeFixedScalar writes s mod encodingN as a big-endian number of
+// fixedScalarLen bytes
+func writeFixedScalar(buf *bytes.Buffer, s *big.Int) {
+	b := make([]byte, fixedScalarLen)
+	if s != nil {
+		v := new(big.Int).Mod(s, encodingN).Bytes()
+		copy(b[fixedScalarLen-len(v):], v)
+	}
+	buf.Write(b)
+}
+
+// checkFixedHeader checks that b is exactly size bytes long and starts with
+// proofEncodingVersion, t is the proof type used in the error message
+func checkFixedHeader(b []byte, size int, t string) error {
+	return checkVersionedHeader(b, size, proofEncodingVersion, t)
+}
+
+// checkVersionedHeader is checkFixedHeader for proofs with an encoding version
+// of their own. The version is checked first, encodings of another version
+// usually have another size too.
+func checkVersionedHeader(b []byte, size int, version byte, t string) error {
+	if len(b) > 0 && b[0] != version {
+		return malformedError(t, fmt.Sprintf("unsupported encoding version %d", b[0]))
+	}
+	if len(b) < size {
+		return malformedError(t, fmt.Sprintf("truncated buffer: got %d bytes, expected %d", len(b), size))
+	}
+	if len(b) > size {
+		return malformedError(t, fmt.Sprintf("trailing bytes: got %d bytes, expected %d", len(b), size))
+	}
+	return nil
+}
+
+// readFixedPoint reads a point written by writeFixedPoint
+func readFixedPoint(buf *bytes.Buffer, t string) (ECPoint, error) {
+	b := buf.Next(fixedPointLen)
+	if len(b) != fixedPointLen {
+		return Zero, malformedError(t, "truncated buffer")
+	}
+	if bytes.Equal(b, make([]byte, fixedPointLen)) {
+		return Zero, nil
+	}
+	p, err := decodePointAny(b)
+	if err != nil {
+		return Zero, wrapError(t, err.(*ProofError).msg, err)
+	}
+	return p, nil
+}
+
+// readFixedScalar reads a scalar written by writeFixedScalar, which is always
+// less than encodingN
+func readFixedScalar(buf *bytes.Buffer, t string) (*big.Int, error) {
+	b := buf.Next(fixedScalarLen)
+	if len(b) != fixedScalarLen {
+		return nil, malformedError(t, "truncated buffer")
+	}
+	s := new(big.Int).SetBytes(b)
+	if s.Cmp(encodingN) >= 0 {
+		return nil, nonCanonicalError(t, "scalar is not in [0, N)")
+	}
+	return s, nil
+}
+
+// readFixedScalars reads the scalars ss in order with readFixedScalar and
+// returns the first error
+func readFixedScalars(buf *bytes.Buffer, t string, ss ...**big.Int) error {
+	for _, s := range ss {
+		var err error
+		if *s, err = readFixedScalar(buf, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // CommitR uses the Public Key (pk) and a random number (r) to
 // generate a commitment of r as an ECPoint
 func CommitR(zkpcp ZKPCurveParams, pk ECPoint, r *big.Int) ECPoint {
 	newR := new(big.Int).Mod(r, zkpcp.C.Params().N)
-	X, Y := zkpcp.C.ScalarMult(pk.X, pk.Y, newR.Bytes()) // {commitR.X,commitR.Y} = newR * {pk.X, pk.Y}
+	X, Y := zkpcp.ops().ScalarMult(pk.X, pk.Y, newR.Bytes()) // {commitR.X,commitR.Y} = newR * {pk.X, pk.Y}
 	return ECPoint{X, Y}
 }
 
@@ -216,10 +1318,16 @@ func VerifyR(zkpcp ZKPCurveParams, rt ECPoint, pk ECPoint, r *big.Int) bool {
 // =============== PEDERSEN COMMITMENTS ================
 // PedCommit generates a pedersen commitment of value using the
 // generators of zkpcp.  It returns the randomness generated for the
-// commitment.
+// commitment. The commitment is to value mod N, so a negative value v commits
+// to N + v the way zkLedger records spending; the proofs on the commitment
+// then need the reduced value.
 func PedCommit(zkpcp ZKPCurveParams, value *big.Int) (ECPoint, *big.Int, error) {
+	if value == nil {
+		return Zero, nil, rangeError("PedCommit", "value is nil")
+	}
+
 	// randomValue = rand() mod N
-	randomValue, err := rand.Int(rand.Reader, zkpcp.C.Params().N)
+	randomValue, err := zkpcp.randScalar(entropy())
 	if err != nil {
 		return Zero, nil, err
 	}
@@ -233,6 +1341,14 @@ func PedCommitR(zkpcp ZKPCurveParams, value, randomValue *big.Int) ECPoint {
 	modValue := new(big.Int).Mod(value, zkpcp.C.Params().N)
 	modRandom := new(big.Int).Mod(randomValue, zkpcp.C.Params().N)
 
+	// mG + rH with the fixed base tables, without converting mG and rH to
+	// affine coordinates on the way
+	if kc, g, h, ok := zkpcp.generatorTables(); ok {
+		X, Y := kc.ScalarMultTables([]*btcec.FixedBaseTable{g, h},
+			[][]byte{modValue.Bytes(), modRandom.Bytes()})
+		return ECPoint{X, Y}
+	}
+
 	// mG, rH :: lhs, rhs
 	lhs := zkpcp.Mult(zkpcp.G, modValue)
 	rhs := zkpcp.Mult(zkpcp.H, modRandom)
@@ -249,16 +1365,68 @@ func Open(zkpcp ZKPCurveParams, value, randomValue *big.Int, pcomm ECPoint) bool
 
 // ====== Generalized Hash Function =========
 
-// GenerateChallenge hashes the passed byte arrays using SHA-256, and then returns
-// the resulting hash as a big.Int modulo the order of the curve base point
+// GenerateChallenge hashes the passed byte arrays using SHA-256, or the
+// ChallengeHash of zkpcp, and then returns the resulting hash as a big.Int
+// modulo the order of the curve base point.
+// The result is biased towards small values, and on curves with an order
+// above 2^256 it never reaches most of [0, N). It is kept for
+// LegacyChallenges, new code should use GenerateChallengeModN.
 func GenerateChallenge(zkpcp ZKPCurveParams, arr ...[]byte) *big.Int {
-	hasher := sha256.New()
+	hasher := zkpcp.newHash()
 	for _, v := range arr {
 		hasher.Write(v)
 	}
 	c := new(big.Int).SetBytes(hasher.Sum(nil))
-	c = new(big.Int).Mod(c, zkpcp.C.Params().N)
-	return c
+	return c.Mod(c, zkpcp.C.Params().N)
+}
+
+// GenerateChallengeModN hashes the passed byte arrays using SHA-256, or the
+// ChallengeHash of zkpcp, like GenerateChallenge, but then expands the hash to
+// 128 bits more than the size of N and reduces that mod N, so the challenge is
+// uniform in [0, N) up to a statistical distance of 2^-128 on any curve
+func GenerateChallengeModN(zkpcp ZKPCurveParams, arr ...[]byte) *big.Int {
+	hasher := zkpcp.newHash()
+	for _, v := range arr {
+		hasher.Write(v)
+	}
+	return reduceDigest(zkpcp, hasher.Sum(nil))
+}
+
+// reduceDigest expands digest to bitlen(N) + 128 bits with the blocks
+// SHA-256(digest || i) for a 4 byte big-endian counter i = 0, 1, ..., or
+// blocks of the ChallengeHash of zkpcp, and returns the result mod N
+func reduceDigest(zkpcp ZKPCurveParams, digest []byte) *big.Int {
+	N := zkpcp.C.Params().N
+	n := (N.BitLen() + 128 + 7) / 8
+	if zkpcp.challengeHash != 0 {
+		h := zkpcp.challengeHash.New()
+		var i [4]byte
+		wide := make([]byte, 0, n+h.Size())
+		for len(wide) < n {
+			h.Reset()
+			h.Write(digest)
+			h.Write(i[:])
+			wide = h.Sum(wide)
+			binary.BigEndian.PutUint32(i[:], binary.BigEndian.Uint32(i[:])+1)
+		}
+		c := new(big.Int).SetBytes(wide[:n])
+		return c.Mod(c, N)
+	}
+
+	// digest || i is hashed with sha256.Sum256, so neither it nor wide has
+	// to be allocated for an N of up to 640 bits
+	var msgBuf [sha256.Size + 4]byte
+	var wideBuf [3 * sha256.Size]byte
+	msg := append(msgBuf[:0], digest...)
+	msg = append(msg, 0, 0, 0, 0)
+	wide := wideBuf[:0]
+	for i := uint32(0); len(wide) < n; i++ {
+		binary.BigEndian.PutUint32(msg[len(digest):], i)
+		block := sha256.Sum256(msg)
+		wide = append(wide, block[:]...)
+	}
+	c := new(big.Int).SetBytes(wide[:n])
+	return c.Mod(c, N)
 }
 
 // ====== init =========
@@ -268,4 +1436,6 @@ func init() {
 	BigZero = big.NewInt(0)
 	Zero = ECPoint{BigZero, BigZero}
 
+	gob.Register(ECPoint{})
+
 }
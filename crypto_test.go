@@ -1,9 +1,25 @@
 package zksigma
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"math/big"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/mit-dci/zksigma/btcec"
 )
 
 func TestECPointMethods(t *testing.T) {
@@ -31,6 +47,1530 @@ func TestECPointMethods(t *testing.T) {
 	}
 }
 
+// TestIdentity checks that Zero behaves as the identity in Add, Sub and Mult,
+// on secp256k1 with its fixed base tables and on P-256 without them
+func TestIdentity(t *testing.T) {
+	if !Zero.IsZero() || !(ECPoint{big.NewInt(0), big.NewInt(0)}).IsZero() {
+		t.Fatalf("Zero should be zero\n")
+	}
+	if (ECPoint{}).IsZero() || TestCurve.G.IsZero() || (ECPoint{big.NewInt(0), big.NewInt(1)}).IsZero() {
+		t.Fatalf("only Zero should be zero\n")
+	}
+
+	for name, zkpcp := range map[string]ZKPCurveParams{"secp256k1": TestCurve, "P-256": P256Curve} {
+		N := zkpcp.C.Params().N
+		x, _ := rand.Int(rand.Reader, N)
+		p := zkpcp.Mult(zkpcp.G, x)
+
+		// every way of multiplying by 0, for the tables of G and H and
+		// for a point without one
+		for _, base := range []ECPoint{zkpcp.G, zkpcp.H, p} {
+			for _, s := range []*big.Int{nil, big.NewInt(0), N, new(big.Int).Neg(N),
+				new(big.Int).ModInverse(big.NewInt(0), N)} {
+				if q := zkpcp.Mult(base, s); !q.IsZero() {
+					t.Fatalf("%s: %v * %v should be Zero, got %v\n", name, s, base, q)
+				}
+			}
+		}
+		if q := zkpcp.Mult(Zero, x); !q.IsZero() {
+			t.Fatalf("%s: x * Zero should be Zero, got %v\n", name, q)
+		}
+
+		identities := map[string][2]ECPoint{
+			"p + 0":       {zkpcp.Add(p, Zero), p},
+			"0 + p":       {zkpcp.Add(Zero, p), p},
+			"0 + 0":       {zkpcp.Add(Zero, Zero), Zero},
+			"p - 0":       {zkpcp.Sub(p, Zero), p},
+			"0 - p":       {zkpcp.Sub(Zero, p), zkpcp.Neg(p)},
+			"0 - 0":       {zkpcp.Sub(Zero, Zero), Zero},
+			"p - p":       {zkpcp.Sub(p, p), Zero},
+			"p + -p":      {zkpcp.Add(p, zkpcp.Neg(p)), Zero},
+			"-0":          {zkpcp.Neg(Zero), Zero},
+			"(N-1)p + p":  {zkpcp.Add(zkpcp.Mult(p, new(big.Int).Sub(N, big.NewInt(1))), p), Zero},
+			"0G + 0H":     {PedCommitR(zkpcp, big.NewInt(0), big.NewInt(0)), Zero},
+			"0G + xH":     {PedCommitR(zkpcp, big.NewInt(0), x), zkpcp.Mult(zkpcp.H, x)},
+			"0p + 0H":     {zkpcp.MultiMult([]ECPoint{p, zkpcp.H}, []*big.Int{big.NewInt(0), N}), Zero},
+			"xp + 0 + 0G": {zkpcp.MultiMult([]ECPoint{p, Zero, zkpcp.G}, []*big.Int{x, x, big.NewInt(0)}), zkpcp.Mult(p, x)},
+		}
+		for eq, sides := range identities {
+			if !sides[0].Equal(sides[1]) {
+				t.Fatalf("%s: %s should be %v, got %v\n", name, eq, sides[1], sides[0])
+			}
+		}
+	}
+}
+
+// sharesCoordinate returns true if p has a coordinate that is the same big.Int
+// as one of those of ps
+func sharesCoordinate(p ECPoint, ps ...ECPoint) bool {
+	for _, q := range ps {
+		for _, c := range []*big.Int{q.X, q.Y} {
+			if c != nil && (p.X == c || p.Y == c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TestPointArithmeticCopies checks on every curve that Add, Sub, Neg, Mult,
+// MultiMult and Bytes leave their arguments bit for bit as they were and return
+// coordinates of their own, for the generators, Zero and other points alike
+func TestPointArithmeticCopies(t *testing.T) {
+	for name, zkpcp := range map[string]ZKPCurveParams{"secp256k1": TestCurve, "no tables": withoutTables(TestCurve), "P-256": P256Curve} {
+		x := scalarFromSeed("copies " + name)
+		p := zkpcp.Mult(zkpcp.G, x)
+		points := []ECPoint{zkpcp.G, zkpcp.H, Zero, p, zkpcp.Neg(p)}
+		fixed := []ECPoint{zkpcp.G, zkpcp.H, Zero}
+		scalars := []*big.Int{nil, big.NewInt(0), big.NewInt(1), x, new(big.Int).Neg(x), zkpcp.C.Params().N}
+
+		for _, a := range points {
+			for _, b := range points {
+				for _, s := range scalars {
+					before := fmt.Sprint(a.X, a.Y, b.X, b.Y, s)
+					results := map[string]ECPoint{
+						"Add":       zkpcp.Add(a, b),
+						"Sub":       zkpcp.Sub(a, b),
+						"Neg":       zkpcp.Neg(a),
+						"Mult":      zkpcp.Mult(a, s),
+						"MultiMult": zkpcp.MultiMult([]ECPoint{a, b}, []*big.Int{s, s}),
+					}
+					a.Bytes()
+					if after := fmt.Sprint(a.X, a.Y, b.X, b.Y, s); after != before {
+						t.Fatalf("%s: the arithmetic of %v and %v with %v changed them\n", name, a, b, s)
+					}
+					for op, r := range results {
+						if sharesCoordinate(r, append([]ECPoint{a, b}, fixed...)...) {
+							t.Fatalf("%s: %s of %v and %v with %v shares a coordinate with its arguments, the generators or Zero\n", name, op, a, b, s)
+						}
+					}
+				}
+			}
+		}
+
+		// changing a result changes nothing else
+		for _, r := range []ECPoint{zkpcp.Add(Zero, zkpcp.G), zkpcp.Sub(zkpcp.H, Zero), zkpcp.Mult(zkpcp.G, nil), zkpcp.Neg(zkpcp.G)} {
+			r.X.Add(r.X, big.NewInt(1))
+			r.Y.SetInt64(7)
+		}
+		G, H := zkpcp.Generators()
+		G.X.SetInt64(1)
+		H.Y.SetInt64(1)
+		if zkpcp.G.X.Cmp(zkpcp.C.Params().Gx) != 0 || zkpcp.G.Y.Cmp(zkpcp.C.Params().Gy) != 0 || !zkpcp.H.IsOnCurve(zkpcp) || !Zero.IsZero() {
+			t.Fatalf("%s: changing the results of the arithmetic changed the generators or Zero\n", name)
+		}
+	}
+
+	// the generators of a ZKPCurveParams are copies of those passed in
+	G := ECPoint{new(big.Int).Set(TestCurve.G.X), new(big.Int).Set(TestCurve.G.Y)}
+	zkpcp, err := NewZKPCurveParams(TestCurve.C, G, TestCurve.H)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if sharesCoordinate(zkpcp.G, G) || sharesCoordinate(zkpcp.H, TestCurve.H) || sharesCoordinate(TestCurve.G, ECPoint{TestCurve.C.Params().Gx, TestCurve.C.Params().Gy}) {
+		t.Fatalf("NewZKPCurveParams shares the coordinates of the generators passed in\n")
+	}
+	gens, _ := GeneratorVector(TestCurve, 2)
+	again, _ := GeneratorVector(TestCurve, 2)
+	if sharesCoordinate(gens[0], again...) || sharesCoordinate(gens[1], again...) {
+		t.Fatalf("GeneratorVector returns the same coordinates twice\n")
+	}
+}
+
+// TestPointArithmeticNil checks that a point with a nil coordinate is not a
+// point for any of the arithmetic, which returns ECPoint{nil, nil} for it
+func TestPointArithmeticNil(t *testing.T) {
+	p := TestCurve.Mult(TestCurve.G, big.NewInt(5))
+	for i, n := range []ECPoint{{}, {X: big.NewInt(1)}, {Y: big.NewInt(1)}} {
+		results := map[string]ECPoint{
+			"nil + p":   TestCurve.Add(n, p),
+			"p + nil":   TestCurve.Add(p, n),
+			"nil + 0":   TestCurve.Add(n, Zero),
+			"nil - p":   TestCurve.Sub(n, p),
+			"0 - nil":   TestCurve.Sub(Zero, n),
+			"-nil":      TestCurve.Neg(n),
+			"x * nil":   TestCurve.Mult(n, big.NewInt(3)),
+			"0 * nil":   TestCurve.Mult(n, big.NewInt(0)),
+			"MultiMult": TestCurve.MultiMult([]ECPoint{p, n}, []*big.Int{big.NewInt(1), big.NewInt(1)}),
+		}
+		for op, r := range results {
+			if r.X != nil || r.Y != nil {
+				t.Fatalf("point %d: %s should be ECPoint{nil, nil}, got %v\n", i, op, r)
+			}
+		}
+		if n.Bytes() != nil || n.IsValid(TestCurve) {
+			t.Fatalf("point %d should have no bytes and not be valid\n", i)
+		}
+	}
+}
+
+// TestGeneratorsConcurrent does arithmetic with the generators of ZKCurve and
+// changes the results from many goroutines, while others read the generators.
+// Run it with -race.
+func TestGeneratorsConcurrent(t *testing.T) {
+	Gx, Gy := ZKCurve.C.Params().Gx, ZKCurve.C.Params().Gy
+	H := clonePoint(ZKCurve.H)
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if i%2 == 0 {
+					for _, r := range []ECPoint{ZKCurve.Sub(ZKCurve.G, ZKCurve.H), ZKCurve.Sub(ZKCurve.G, Zero),
+						ZKCurve.Add(Zero, ZKCurve.H), ZKCurve.Neg(ZKCurve.G), ZKCurve.Mult(ZKCurve.H, big.NewInt(int64(j)))} {
+						r.X.Add(r.X, big.NewInt(1))
+						r.Y.Neg(r.Y)
+					}
+					continue
+				}
+				if ZKCurve.G.X.Cmp(Gx) != 0 || ZKCurve.G.Y.Cmp(Gy) != 0 || !ZKCurve.H.Equal(H) || !Zero.IsZero() {
+					errs <- fmt.Errorf("the generators changed in round %d", j)
+					return
+				}
+				if q := ZKCurve.Sub(ZKCurve.Add(ZKCurve.G, ZKCurve.H), ZKCurve.H); !q.Equal(ZKCurve.G) {
+					errs <- fmt.Errorf("G + H - H = %v in round %d", q, j)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent arithmetic with the generators failed: %v\n", err)
+	}
+}
+
+func TestEqualCT(t *testing.T) {
+	for ii := 0; ii < 20; ii++ {
+		x, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+		p := TestCurve.Mult(TestCurve.G, x)
+		cases := []ECPoint{p, TestCurve.Neg(p), TestCurve.Add(p, TestCurve.G), Zero,
+			{new(big.Int).Set(p.X), new(big.Int).Set(p.Y)},
+			{new(big.Int).Neg(p.X), p.Y}, {p.X, new(big.Int).Neg(p.Y)}}
+		for _, q := range cases {
+			if p.Equal(q) != p.EqualCT(q) || p.EqualCT(q) != samePoint(p, q) {
+				t.Fatalf("Equal and EqualCT disagree on p: %v, q: %v\n", p, q)
+			}
+		}
+	}
+	// FillBytes drops the sign of a coordinate
+	G := TestCurve.G
+	if G.Equal(ECPoint{new(big.Int).Neg(G.X), G.Y}) || G.EqualCT(ECPoint{G.X, new(big.Int).Neg(G.Y)}) {
+		t.Fatalf("a point is equal to the one with a negated coordinate\n")
+	}
+	if !Zero.EqualCT(Zero) {
+		t.Fatalf("Zero should be equal to itself\n")
+	}
+
+	// coordinates wider than 256 bits are padded to the length of their
+	// curve, and not compared at their own minimal length
+	for _, c := range []struct {
+		curve elliptic.Curve
+		width int
+	}{{elliptic.P256(), 32}, {elliptic.P384(), 48}, {elliptic.P521(), 66}} {
+		params := c.curve.Params()
+		for ii := 0; ii < 20; ii++ {
+			x, _ := rand.Int(rand.Reader, params.N)
+			var p ECPoint
+			p.X, p.Y = c.curve.ScalarBaseMult(x.Bytes())
+			q := ECPoint{new(big.Int).Set(p.X), new(big.Int).Set(p.Y)}
+			if w := ctWidth(p.X, p.Y); w != c.width {
+				t.Fatalf("ctWidth of a point on %s is %d, expected %d\n", params.Name, w, c.width)
+			}
+			if !p.EqualCT(q) || p.EqualCT(ECPoint{p.X, new(big.Int).Sub(params.P, p.Y)}) || !p.EqualCT(p) {
+				t.Fatalf("EqualCT disagrees with Equal on %s for %v\n", params.Name, p)
+			}
+		}
+		// a coordinate with leading zero bytes has the width of its curve
+		small := ECPoint{big.NewInt(1), new(big.Int).Lsh(big.NewInt(1), uint(8*c.width-1))}
+		if w := ctWidth(small.X, small.Y); w != c.width {
+			t.Fatalf("ctWidth of %v is %d, expected %d\n", small, w, c.width)
+		}
+	}
+
+	// a point with nil coordinates is not Zero, nor a panic
+	for _, c := range []struct {
+		p, q  ECPoint
+		equal bool
+	}{{ECPoint{}, ECPoint{}, true}, {ECPoint{}, Zero, false}, {Zero, ECPoint{}, false},
+		{ECPoint{big.NewInt(0), nil}, Zero, false}, {ECPoint{nil, big.NewInt(1)}, ECPoint{nil, big.NewInt(1)}, true}} {
+		if c.p.Equal(c.q) != c.equal {
+			t.Fatalf("%v.Equal(%v) should be %v\n", c.p, c.q, c.equal)
+		}
+	}
+}
+
+func TestIsOnCurve(t *testing.T) {
+	P := TestCurve.C.Params().P
+	G := TestCurve.G
+	cases := []struct {
+		p                ECPoint
+		onCurve, isValid bool
+	}{
+		{G, true, true},
+		{TestCurve.Neg(G), true, true},
+		{Zero, false, true},
+		{ECPoint{}, false, false},
+		{ECPoint{G.X, nil}, false, false},
+		{ECPoint{G.X, new(big.Int).Add(G.Y, big.NewInt(1))}, false, false},
+		{ECPoint{new(big.Int).Add(G.X, P), G.Y}, false, false},
+		{ECPoint{G.X, new(big.Int).Sub(G.Y, P)}, false, false},
+		{ECPoint{new(big.Int).Lsh(G.X, 64), G.Y}, false, false},
+	}
+	for _, c := range cases {
+		if c.p.IsOnCurve(TestCurve) != c.onCurve || c.p.IsValid(TestCurve) != c.isValid {
+			t.Fatalf("IsOnCurve/IsValid of %v should be %v/%v\n", c.p, c.onCurve, c.isValid)
+		}
+	}
+}
+
+func TestECPointHex(t *testing.T) {
+	for ii := 0; ii < 20; ii++ {
+		x, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+		p := TestCurve.Mult(TestCurve.G, x)
+		q, err := ECPointFromHex(TestCurve, p.ToHex())
+		if err != nil || !q.Equal(p) {
+			t.Fatalf("hex round trip failed for %v: %v\n", p, err)
+		}
+		if p.String() != p.ToHex() || len(p.ToHex()) != 2*fixedPointLen {
+			t.Fatalf("String of %v should be its compressed hex\n", p)
+		}
+	}
+
+	if q, err := ECPointFromHex(TestCurve, Zero.ToHex()); err != nil || !q.Equal(Zero) {
+		t.Fatalf("hex round trip failed for Zero: %v\n", err)
+	}
+
+	// x = 5 is not the x coordinate of any point on secp256k1
+	offCurve := "02" + strings.Repeat("00", fixedScalarLen-1) + "05"
+	for _, h := range []string{offCurve, "zz", "04", TestCurve.G.ToHex()[:20], "00" + TestCurve.G.ToHex()} {
+		if _, err := ECPointFromHex(TestCurve, h); err == nil {
+			t.Fatalf("ECPointFromHex should reject %q\n", h)
+		}
+	}
+
+	if (ECPoint{}).ToHex() != "" || (ECPoint{big.NewInt(-1), big.NewInt(1)}).ToHex() != "" {
+		t.Fatalf("points without an encoding should give an empty hex string\n")
+	}
+}
+
+func TestCompressedBytes(t *testing.T) {
+	// the generators in the SEC 2 and FIPS 186 form
+	for _, c := range []struct {
+		zkpcp ZKPCurveParams
+		G     string
+	}{
+		{TestCurve, "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+		{P256Curve, "036b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c296"},
+	} {
+		G := ECPoint{c.zkpcp.C.Params().Gx, c.zkpcp.C.Params().Gy}
+		if h := hex.EncodeToString(G.CompressedBytes(c.zkpcp)); h != c.G {
+			t.Fatalf("CompressedBytes of G on %s is %s, expected %s\n", c.zkpcp.C.Params().Name, h, c.G)
+		}
+		b, _ := hex.DecodeString(c.G)
+		if q, err := DecompressPoint(c.zkpcp, b); err != nil || !q.Equal(G) {
+			t.Fatalf("DecompressPoint of G on %s gives %v, %v\n", c.zkpcp.C.Params().Name, q, err)
+		}
+	}
+
+	// the encodings of btcec and crypto/elliptic are the same
+	for ii := 0; ii < 20; ii++ {
+		x, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+		p := TestCurve.Mult(TestCurve.G, x)
+		other := (*btcec.PublicKey)(&ecdsa.PublicKey{Curve: btcec.S256(), X: p.X, Y: p.Y}).SerializeCompressed()
+		if !bytes.Equal(p.CompressedBytes(TestCurve), other) {
+			t.Fatalf("CompressedBytes of %v is not the same as btcec's %x\n", p, other)
+		}
+		if q, err := TestCurve.DecompressPoint(other); err != nil || !q.Equal(p) {
+			t.Fatalf("DecompressPoint of btcec's %x gives %v, %v\n", other, q, err)
+		}
+
+		p = P256Curve.Mult(P256Curve.G, x)
+		other = elliptic.MarshalCompressed(elliptic.P256(), p.X, p.Y)
+		if !bytes.Equal(p.CompressedBytes(P256Curve), other) {
+			t.Fatalf("CompressedBytes of %v is not the same as crypto/elliptic's %x\n", p, other)
+		}
+		if q, err := P256Curve.DecompressPoint(other); err != nil || !q.Equal(p) {
+			t.Fatalf("DecompressPoint of crypto/elliptic's %x gives %v, %v\n", other, q, err)
+		}
+	}
+
+	if b := Zero.CompressedBytes(TestCurve); !bytes.Equal(b, []byte{0x00}) {
+		t.Fatalf("CompressedBytes of Zero is %x\n", b)
+	}
+	if q, err := DecompressPoint(P256Curve, []byte{0x00}); err != nil || !q.Equal(Zero) {
+		t.Fatalf("DecompressPoint of 0x00 gives %v, %v\n", q, err)
+	}
+	for _, p := range []ECPoint{{}, {big.NewInt(-1), big.NewInt(1)}} {
+		if b := p.CompressedBytes(TestCurve); b != nil {
+			t.Fatalf("CompressedBytes of %v is %x, expected nil\n", p, b)
+		}
+	}
+
+	// x = 5 is not the x coordinate of any point on secp256k1
+	offCurve := append([]byte{0x02}, make([]byte, fixedScalarLen)...)
+	offCurve[fixedScalarLen] = 5
+	G := TestCurve.G.CompressedBytes(TestCurve)
+	for _, c := range []struct {
+		b   []byte
+		err error
+	}{
+		{nil, ErrMalformedProof},
+		{G[:20], ErrMalformedProof},
+		{append([]byte{0x04}, G[1:]...), ErrMalformedProof},
+		{nonCanonicalPoint(t), ErrMalformedProof},
+		{offCurve, ErrNotOnCurve},
+	} {
+		if _, err := DecompressPoint(TestCurve, c.b); !errors.Is(err, c.err) {
+			t.Fatalf("DecompressPoint of %x gives %v, expected %v\n", c.b, err, c.err)
+		}
+	}
+}
+
+// TestEncodePoint checks that the proof encodings carry the curve of a point,
+// so a proof made on P256Curve decodes and verifies on it, while the points of
+// secp256k1 keep their SEC1 encoding
+func TestEncodePoint(t *testing.T) {
+	x, _ := rand.Int(rand.Reader, P256Curve.C.Params().N)
+	for _, c := range []struct {
+		zkpcp  ZKPCurveParams
+		prefix byte
+	}{{TestCurve, 0x02}, {P256Curve, 0x12}} {
+		p := c.zkpcp.Mult(c.zkpcp.G, x)
+		b := encodePoint(p)
+		if b[0]&0xfe != c.prefix || !bytes.Equal(b[1:], p.CompressedBytes(c.zkpcp)[1:]) {
+			t.Fatalf("encodePoint on %s gives %x\n", c.zkpcp.C.Params().Name, b)
+		}
+		if q, err := decodePointAny(b); err != nil || !q.Equal(p) {
+			t.Fatalf("decodePointAny on %s gives %v, %v\n", c.zkpcp.C.Params().Name, q, err)
+		}
+		if q, err := pointFromHex(pointToHex(p), "p"); err != nil || !q.Equal(p) {
+			t.Fatalf("pointFromHex on %s gives %v, %v\n", c.zkpcp.C.Params().Name, q, err)
+		}
+
+		proof, err := NewGSPFSProof(c.zkpcp, p, x)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		decoded, err := NewGSPFSProofFromBytes(proof.Bytes())
+		if err != nil {
+			t.Fatalf("GSPFSProof on %s failed to decode: %v\n", c.zkpcp.C.Params().Name, err)
+		}
+		if ok, err := decoded.Verify(c.zkpcp, p); !ok || err != nil {
+			t.Fatalf("decoded GSPFSProof on %s did not verify: %v\n", c.zkpcp.C.Params().Name, err)
+		}
+	}
+
+	G := encodePoint(TestCurve.G)
+	for _, prefix := range []byte{0x22, 0xf3} {
+		bad := append([]byte{prefix}, G[1:]...)
+		if _, err := decodePointAny(bad); !errors.Is(err, ErrMalformedProof) {
+			t.Fatalf("decodePointAny with prefix %x gives %v\n", prefix, err)
+		}
+	}
+	if b := encodePoint(Zero); !bytes.Equal(b, []byte{0x00}) {
+		t.Fatalf("encodePoint of Zero is %x\n", b)
+	}
+}
+
+func TestECPointMarshal(t *testing.T) {
+	CM, _, err := PedCommit(TestCurve, big.NewInt(30))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	points := []ECPoint{TestCurve.G, TestCurve.H, Zero, CM, P256Curve.G, P256Curve.H}
+
+	for _, p := range points {
+		b, err := p.MarshalBinary()
+		if err != nil || len(b) > fixedPointLen {
+			t.Fatalf("MarshalBinary of %v gives %x, %v\n", p, b, err)
+		}
+		var q ECPoint
+		if err := q.UnmarshalBinary(b); err != nil || !q.Equal(p) {
+			t.Fatalf("binary round trip failed for %v: %v\n", p, err)
+		}
+
+		text, err := p.MarshalText()
+		if err != nil || string(text) != hex.EncodeToString(b) {
+			t.Fatalf("MarshalText of %v gives %s, %v\n", p, text, err)
+		}
+		q = ECPoint{}
+		if err := q.UnmarshalText(text); err != nil || !q.Equal(p) {
+			t.Fatalf("text round trip failed for %v: %v\n", p, err)
+		}
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(p); err != nil {
+			t.Fatalf("gob encoding of %v failed: %v\n", p, err)
+		}
+		q = ECPoint{}
+		if err := gob.NewDecoder(&buf).Decode(&q); err != nil || !q.Equal(p) {
+			t.Fatalf("gob round trip failed for %v: %v\n", p, err)
+		}
+	}
+
+	// gob through an interface, which needs the type to be registered
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(struct{ P interface{} }{CM}); err != nil {
+		t.Fatalf("gob encoding of ECPoint as interface failed: %v\n", err)
+	}
+	var iface struct{ P interface{} }
+	if err := gob.NewDecoder(&buf).Decode(&iface); err != nil {
+		t.Fatalf("gob decoding of ECPoint as interface failed: %v\n", err)
+	}
+	if q, ok := iface.P.(ECPoint); !ok || !q.Equal(CM) {
+		t.Fatalf("gob round trip through an interface gives %v\n", iface.P)
+	}
+
+	// JSON, as a value and as a map key
+	type wrapper struct {
+		Points []ECPoint
+		Names  map[ECPoint]string
+	}
+	in := wrapper{points, map[ECPoint]string{TestCurve.G: "G", TestCurve.H: "H", CM: "CM"}}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("json encoding failed: %v\n", err)
+	}
+	var out wrapper
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("json decoding failed: %v\n", err)
+	}
+	for i, p := range points {
+		if !out.Points[i].Equal(p) {
+			t.Fatalf("json round trip gives %v instead of %v\n", out.Points[i], p)
+		}
+	}
+	if len(out.Names) != len(in.Names) {
+		t.Fatalf("json round trip of the map gives %v\n", out.Names)
+	}
+	for p, name := range out.Names {
+		if !p.Equal(map[string]ECPoint{"G": TestCurve.G, "H": TestCurve.H, "CM": CM}[name]) {
+			t.Fatalf("json round trip maps %v to %s\n", p, name)
+		}
+	}
+
+	// x = 5 is not the x coordinate of any point on secp256k1
+	offCurve := append([]byte{0x02}, make([]byte, fixedScalarLen)...)
+	offCurve[fixedScalarLen] = 5
+	G, _ := TestCurve.G.MarshalBinary()
+	badParity := append([]byte{0x05}, G[1:]...)
+	nonCanonical := nonCanonicalPoint(t)
+	for _, c := range []struct {
+		b    []byte
+		code ErrorCode
+	}{
+		{nil, ErrCodeMalformedProof},
+		{G[:20], ErrCodeMalformedProof},
+		{append(G, 0x00), ErrCodeMalformedProof},
+		{badParity, ErrCodeMalformedProof},
+		{offCurve, ErrCodeNotOnCurve},
+		{nonCanonical, ErrCodeMalformedProof},
+		{append([]byte{0x22}, G[1:]...), ErrCodeMalformedProof},
+	} {
+		var q ECPoint
+		err := q.UnmarshalBinary(c.b)
+		var pe *ProofError
+		if !errors.As(err, &pe) || pe.Code != c.code {
+			t.Fatalf("UnmarshalBinary of %x gives %v, expected code %d\n", c.b, err, c.code)
+		}
+		if err := q.UnmarshalText([]byte(hex.EncodeToString(c.b))); !errors.As(err, &pe) || pe.Code != c.code {
+			t.Fatalf("UnmarshalText of %x gives %v, expected code %d\n", c.b, err, c.code)
+		}
+		if err := gob.NewDecoder(bytes.NewReader(gobBytes(t, c.b))).Decode(&q); err == nil {
+			t.Fatalf("gob decoded %x\n", c.b)
+		}
+	}
+	var q ECPoint
+	if err := q.UnmarshalText([]byte("zz")); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("UnmarshalText of bad hex gives %v\n", err)
+	}
+	if err := json.Unmarshal([]byte(`"`+hex.EncodeToString(offCurve)+`"`), &q); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("json decoded a point that is not on the curve: %v\n", err)
+	}
+
+	for _, p := range []ECPoint{{}, {big.NewInt(-1), big.NewInt(1)}} {
+		if _, err := p.MarshalBinary(); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("MarshalBinary of %v gives %v\n", p, err)
+		}
+		if _, err := p.MarshalText(); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("MarshalText of %v gives %v\n", p, err)
+		}
+	}
+}
+
+// nonCanonicalPoint returns the encoding of a point with X + P in place of its
+// X, which still fits in fixedScalarLen bytes for a small X
+func nonCanonicalPoint(t testing.TB) []byte {
+	P := TestCurve.C.Params().P
+	for x := int64(1); ; x++ {
+		b := append([]byte{0x02}, big.NewInt(x).FillBytes(make([]byte, fixedScalarLen))...)
+		if _, err := decompressPoint(TestCurve.C, b); err == nil {
+			new(big.Int).Add(big.NewInt(x), P).FillBytes(b[1:])
+			return b
+		}
+		if x == 1000 {
+			t.Fatalf("no point with a small X\n")
+		}
+	}
+}
+
+// gobBytes returns the gob encoding of an ECPoint with binary encoding b
+func gobBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(rawPoint(b)); err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	return buf.Bytes()
+}
+
+// rawPoint gob encodes as an ECPoint with whatever encoding it holds
+type rawPoint []byte
+
+func (r rawPoint) MarshalBinary() ([]byte, error) {
+	return r, nil
+}
+
+// TestStringZeroValue makes sure String works on zero value and nil proofs,
+// which is what you get when printing a half-decoded proof
+func TestStringZeroValue(t *testing.T) {
+	var nilABC *ABCProof
+	values := []fmt.Stringer{
+		ECPoint{}, ECPoint{big.NewInt(-1), nil}, Zero,
+		&ABCProof{}, &DisjunctiveProof{}, &EquivalenceProof{}, &ConsistencyProof{},
+		&GSPFSProof{}, &InequalityProof{}, &OpenProof{}, &ProductProof{}, &BitRangeProof{}, &BitProof{},
+		&SumProof{}, &RerandomizationProof{}, &NonZeroProof{}, &OpenCommitment{}, &OpenToValueProof{}, &ComparisonProof{}, &BoundedRangeProof{}, &AggregateRangeProof{}, &DecryptionProof{}, &OneOfManyProof{}, &OneOfManyProof{T: []ECPoint{{}}, Cs: []*big.Int{nil}, S: []*big.Int{nil}},
+		&ShuffleProof{}, &ShuffleProof{C: []ECPoint{{}}, SHat: []*big.Int{nil}},
+		&VectorOpenProof{}, &VectorOpenProof{S: []*big.Int{nil}}, &KeyRotationProof{}, &SameBlindingProof{}, &SameValueProof{}, &EquivOrZeroProof{}, &BalanceProof{},
+		&SetMembershipProof{}, &SetMembershipProof{Branches: &OneOfManyProof{}}, &EntryProof{},
+		&BitRangeProof{BitCommits: []ECPoint{{}}, BitProofs: []*DisjunctiveProof{nil}},
+		&RangeProof{}, &RangeProof{ProofTuples: []rangeProofTuple{{}}},
+		&MultiProductProof{}, &MultiProductProof{Partials: []ECPoint{{}}, Links: []MultiProductLink{{}}},
+		&MultiRangeProof{}, &MultiRangeProof{Values: [][]MultiRangeBit{nil, {{}}}},
+		&ComposedProof{}, &ComposedProof{Proofs: []SubProof{nil, nilABC, &OpenProof{}}},
+		nilABC, (*DisjunctiveProof)(nil), (*EquivalenceProof)(nil), (*ConsistencyProof)(nil),
+		(*GSPFSProof)(nil), (*InequalityProof)(nil), (*OpenProof)(nil), (*ProductProof)(nil), (*BitRangeProof)(nil),
+		(*RangeProof)(nil), (*ComposedProof)(nil), (*SumProof)(nil), (*OneOfManyProof)(nil), (*RerandomizationProof)(nil),
+		(*NonZeroProof)(nil), (*OpenCommitment)(nil), (*OpenToValueProof)(nil), (*ComparisonProof)(nil), (*BoundedRangeProof)(nil), (*AggregateRangeProof)(nil), (*DecryptionProof)(nil), (*ShuffleProof)(nil),
+		(*VectorOpenProof)(nil), (*KeyRotationProof)(nil), (*SameBlindingProof)(nil), (*SameValueProof)(nil), (*EquivOrZeroProof)(nil), (*BalanceProof)(nil), (*SetMembershipProof)(nil),
+		(*MultiProductProof)(nil), (*MultiRangeProof)(nil), (*BitProof)(nil), (*EntryProof)(nil),
+	}
+	for _, v := range values {
+		if v.String() == "" {
+			t.Fatalf("String of %T should not be empty\n", v)
+		}
+	}
+
+	v := big.NewInt(3)
+	CM, r, _ := PedCommit(TestCurve, v)
+	proof, _ := NewOpenProof(TestCurve, CM, v, r)
+	str := proof.String()
+	for _, label := range []string{"OpenProof{", "T: " + proof.T.ToHex(), "S1: " + scalarString(proof.S1)} {
+		if !strings.Contains(str, label) {
+			t.Fatalf("String of OpenProof should contain %q, got:\n%s\n", label, str)
+		}
+	}
+}
+
+// randomPoint returns a point with random coordinates of random length and
+// sign, which is almost never on the curve
+func randomPoint() ECPoint {
+	coord := func() *big.Int {
+		var l [1]byte
+		rand.Read(l[:])
+		b := make([]byte, int(l[0])%41)
+		rand.Read(b)
+		x := new(big.Int).SetBytes(b)
+		if l[0]&0x80 != 0 {
+			x.Neg(x)
+		}
+		return x
+	}
+	return ECPoint{coord(), coord()}
+}
+
+// TestVerifyInvalidPoints feeds random, identity and nil points into the
+// Verify functions in place of proof and caller points. None of them may
+// panic or accept.
+func TestVerifyInvalidPoints(t *testing.T) {
+	N := TestCurve.C.Params().N
+	sk, _ := rand.Int(rand.Reader, N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(5)
+	CM, r, _ := PedCommit(TestCurve, value)
+	CMTok := TestCurve.Mult(PK, r)
+	CM2, r2, _ := PedCommit(TestCurve, big.NewInt(6))
+	x, _ := rand.Int(rand.Reader, N)
+	A := TestCurve.Mult(TestCurve.G, x)
+	AH := TestCurve.Mult(TestCurve.H, x)
+
+	abc, err := NewABCProof(TestCurve, CM, CMTok, value, sk, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	dj, err := NewDisjunctiveProof(TestCurve, TestCurve.G, A, TestCurve.H, AH, x, Left)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	eq, err := NewEquivalenceProof(TestCurve, TestCurve.G, A, TestCurve.H, AH, x)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	con, err := NewConsistencyProof(TestCurve, CM, CMTok, PK, value, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	gs, err := NewGSPFSProof(TestCurve, A, x)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	ie, err := NewInequalityProof(TestCurve, CM, CM2, value, big.NewInt(6), r, r2)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	op, err := NewOpenProof(TestCurve, CM, value, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	pp, pA, pB, pC, err := NewProductProof(TestCurve, value, big.NewInt(6), r, r2, x)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	mpCMs, mp, err := NewMultiProductProof(TestCurve, []*big.Int{value, big.NewInt(6), big.NewInt(7)}, []*big.Int{r, r2, x, r2, r})
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	br, err := NewBitRangeProof(TestCurve, CM, value, r, 4)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	bit, bitC, err := NewBitProof(TestCurve, big.NewInt(1), r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	rp, rpR, err := NewRangeProof(TestCurve, value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	rpCM := PedCommitR(TestCurve, value, rpR)
+	sp, err := NewSumProof(TestCurve, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(6)}, []*big.Int{r, r2}, big.NewInt(11))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	rCM, deltaR, err := RerandomizeCommitment(TestCurve, CM)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	rr, err := NewRerandomizationProof(TestCurve, CM, rCM, deltaR)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	om, err := NewOneOfManyProof(TestCurve, []ECPoint{TestCurve.G, TestCurve.H}, []ECPoint{A, AH}, 1, x)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	nz, err := NewNonZeroProof(TestCurve, CM, value, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	ov, err := NewOpenToValueProof(TestCurve, CM, value, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	cmp, err := NewComparisonProof(TestCurve, CM2, CM, big.NewInt(6), value, r2, r, 4)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	bd, err := NewBoundedRangeProof(TestCurve, CM, value, r, big.NewInt(2), big.NewInt(9))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	mr, err := NewMultiRangeProof(TestCurve, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(6)}, []*big.Int{r, r2}, 4)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	agg, err := NewAggregateRangeProof(TestCurve, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(6)}, []*big.Int{r, r2}, 4)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	dec, V, err := NewDecryptionProof(TestCurve, CM, CMTok, sk)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	shIn, shOut := []ECPoint{CM, CM2}, []ECPoint{TestCurve.Add(CM2, TestCurve.Mult(TestCurve.H, r)), rCM}
+	sh, err := NewShuffleProof(TestCurve, shIn, shOut, []int{1, 0}, []*big.Int{r, deltaR})
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	vecCM, _ := VectorPedCommitR(TestCurve, []*big.Int{value, r2}, r)
+	vo, err := NewVectorOpenProof(TestCurve, vecCM, []*big.Int{value, r2}, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	newSK, newPK := r2, TestCurve.Mult(TestCurve.H, r2)
+	newCMTok, _ := RotateToken(TestCurve, CMTok, sk, newSK)
+	kr, err := NewKeyRotationProof(TestCurve, CMTok, newCMTok, PK, newPK, sk, newSK)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	CMTok2 := TestCurve.Mult(newPK, r)
+	sb, err := NewSameBlindingProof(TestCurve, CMTok, CMTok2, PK, newPK, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	sameCM, sameR, _ := PedCommit(TestCurve, value)
+	sv, err := NewSameValueProof(TestCurve, CM, sameCM, value, r, sameR)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	ez, err := NewEquivOrZeroProof(TestCurve, CM, sameCM, r, sameR, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	bp, err := NewBalanceProof(TestCurve, CM, []ECPoint{sameCM}, []int{1}, r, []*big.Int{sameR})
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	set := []*big.Int{big.NewInt(1), value, big.NewInt(10)}
+	sm, err := NewSetMembershipProof(TestCurve, CM, value, r, set)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	verifiers := map[string]func(p ECPoint) (bool, error){
+		"ABC.B": func(p ECPoint) (bool, error) {
+			q := *abc
+			q.B = p
+			return q.Verify(TestCurve, CM, CMTok, PK)
+		},
+		"ABC.C": func(p ECPoint) (bool, error) {
+			q := *abc
+			q.C = p
+			return q.Verify(TestCurve, CM, CMTok, PK)
+		},
+		"ABC.T1": func(p ECPoint) (bool, error) {
+			q := *abc
+			q.T1 = p
+			return q.Verify(TestCurve, CM, CMTok, PK)
+		},
+		"ABC.T2": func(p ECPoint) (bool, error) {
+			q := *abc
+			q.T2 = p
+			return q.Verify(TestCurve, CM, CMTok, PK)
+		},
+		"ABC.CToken": func(p ECPoint) (bool, error) {
+			q := *abc
+			q.CToken = p
+			return q.Verify(TestCurve, CM, CMTok, PK)
+		},
+		"ABC.cTokenProof.T1": func(p ECPoint) (bool, error) {
+			q, c := *abc, *abc.cTokenProof
+			c.T1 = p
+			q.cTokenProof = &c
+			return q.Verify(TestCurve, CM, CMTok, PK)
+		},
+		"ABC.cTokenProof.T2": func(p ECPoint) (bool, error) {
+			q, c := *abc, *abc.cTokenProof
+			c.T2 = p
+			q.cTokenProof = &c
+			return q.Verify(TestCurve, CM, CMTok, PK)
+		},
+		"ABC.disjuncAC.T1": func(p ECPoint) (bool, error) {
+			q, d := *abc, *abc.disjuncAC
+			d.T1 = p
+			q.disjuncAC = &d
+			return q.Verify(TestCurve, CM, CMTok, PK)
+		},
+		"ABC.disjuncAC.T2": func(p ECPoint) (bool, error) {
+			q, d := *abc, *abc.disjuncAC
+			d.T2 = p
+			q.disjuncAC = &d
+			return q.Verify(TestCurve, CM, CMTok, PK)
+		},
+		"ABC CM": func(p ECPoint) (bool, error) {
+			return abc.Verify(TestCurve, p, CMTok, PK)
+		},
+		"ABC CMTok": func(p ECPoint) (bool, error) {
+			return abc.Verify(TestCurve, CM, p, PK)
+		},
+		"ABC PK": func(p ECPoint) (bool, error) {
+			return abc.Verify(TestCurve, CM, CMTok, p)
+		},
+		"Disjunctive.T1": func(p ECPoint) (bool, error) {
+			q := *dj
+			q.T1 = p
+			return q.Verify(TestCurve, TestCurve.G, A, TestCurve.H, AH)
+		},
+		"Disjunctive Result2": func(p ECPoint) (bool, error) {
+			return dj.Verify(TestCurve, TestCurve.G, A, TestCurve.H, p)
+		},
+		"Equivalence.UG": func(p ECPoint) (bool, error) {
+			q := *eq
+			q.UG = p
+			return q.Verify(TestCurve, TestCurve.G, A, TestCurve.H, AH)
+		},
+		"Equivalence Base1": func(p ECPoint) (bool, error) {
+			return eq.Verify(TestCurve, p, A, TestCurve.H, AH)
+		},
+		"Consistency.T2": func(p ECPoint) (bool, error) {
+			q := *con
+			q.T2 = p
+			return q.Verify(TestCurve, CM, CMTok, PK)
+		},
+		"Consistency PubKey": func(p ECPoint) (bool, error) {
+			return con.Verify(TestCurve, CM, CMTok, p)
+		},
+		"GSPFS.Base": func(p ECPoint) (bool, error) {
+			q := *gs
+			q.Base = p
+			return q.Verify(TestCurve, A)
+		},
+		"GSPFS.RandCommit": func(p ECPoint) (bool, error) {
+			q := *gs
+			q.RandCommit = p
+			return q.Verify(TestCurve, A)
+		},
+		"GSPFS A": func(p ECPoint) (bool, error) {
+			return gs.Verify(TestCurve, p)
+		},
+		"Inequality.B": func(p ECPoint) (bool, error) {
+			q := *ie
+			q.B = p
+			return q.Verify(TestCurve, CM, CM2)
+		},
+		"Inequality CM2": func(p ECPoint) (bool, error) {
+			return ie.Verify(TestCurve, CM, p)
+		},
+		"Open.T": func(p ECPoint) (bool, error) {
+			q := *op
+			q.T = p
+			return q.Verify(TestCurve, CM)
+		},
+		"Open CM": func(p ECPoint) (bool, error) {
+			return op.Verify(TestCurve, p)
+		},
+		"Product.T3": func(p ECPoint) (bool, error) {
+			q := *pp
+			q.T3 = p
+			return q.Verify(TestCurve, pA, pB, pC)
+		},
+		"Product B": func(p ECPoint) (bool, error) {
+			return pp.Verify(TestCurve, pA, p, pC)
+		},
+		"MultiProduct.Partials": func(p ECPoint) (bool, error) {
+			q := *mp
+			q.Partials = []ECPoint{p}
+			return q.Verify(TestCurve, mpCMs[:3], mpCMs[4])
+		},
+		"MultiProduct.Links.T3": func(p ECPoint) (bool, error) {
+			q := *mp
+			q.Links = []MultiProductLink{mp.Links[0], mp.Links[1]}
+			q.Links[1].T3 = p
+			return q.Verify(TestCurve, mpCMs[:3], mpCMs[4])
+		},
+		"MultiProduct factorCMs": func(p ECPoint) (bool, error) {
+			return mp.Verify(TestCurve, []ECPoint{mpCMs[0], p, mpCMs[2]}, mpCMs[4])
+		},
+		"MultiProduct resultCM": func(p ECPoint) (bool, error) {
+			return mp.Verify(TestCurve, mpCMs[:3], p)
+		},
+		"Sum.T": func(p ECPoint) (bool, error) {
+			q := *sp
+			q.T = p
+			return q.Verify(TestCurve, []ECPoint{CM, CM2}, big.NewInt(11))
+		},
+		"Sum CMs": func(p ECPoint) (bool, error) {
+			return sp.Verify(TestCurve, []ECPoint{CM, p}, big.NewInt(11))
+		},
+		"Rerandomization.T": func(p ECPoint) (bool, error) {
+			q := *rr
+			q.T = p
+			return q.Verify(TestCurve, CM, rCM)
+		},
+		"Rerandomization CMPrime": func(p ECPoint) (bool, error) {
+			return rr.Verify(TestCurve, CM, p)
+		},
+		"NonZero.B": func(p ECPoint) (bool, error) {
+			q := *nz
+			q.B = p
+			return q.Verify(TestCurve, CM)
+		},
+		"NonZero CM": func(p ECPoint) (bool, error) {
+			return nz.Verify(TestCurve, p)
+		},
+		"OpenToValue.T": func(p ECPoint) (bool, error) {
+			q := *ov
+			q.T = p
+			return q.Verify(TestCurve, CM, value)
+		},
+		"OpenToValue CM": func(p ECPoint) (bool, error) {
+			return ov.Verify(TestCurve, p, value)
+		},
+		"Comparison.Difference": func(p ECPoint) (bool, error) {
+			q, d := *cmp, *cmp.Difference
+			d.BitCommits = []ECPoint{p, d.BitCommits[1], d.BitCommits[2], d.BitCommits[3]}
+			q.Difference = &d
+			return q.Verify(TestCurve, CM2, CM, 4)
+		},
+		"Comparison CMA": func(p ECPoint) (bool, error) {
+			return cmp.Verify(TestCurve, p, CM, 4)
+		},
+		"Comparison CMB": func(p ECPoint) (bool, error) {
+			return cmp.Verify(TestCurve, CM2, p, 4)
+		},
+		"BoundedRange.Lower": func(p ECPoint) (bool, error) {
+			q, d := *bd, *bd.Lower
+			d.BitCommits = []ECPoint{p, d.BitCommits[1], d.BitCommits[2]}
+			q.Lower = &d
+			return q.Verify(TestCurve, CM, big.NewInt(2), big.NewInt(9))
+		},
+		"BoundedRange.Upper": func(p ECPoint) (bool, error) {
+			q, d := *bd, *bd.Upper
+			d.BitCommits = []ECPoint{d.BitCommits[0], d.BitCommits[1], p}
+			q.Upper = &d
+			return q.Verify(TestCurve, CM, big.NewInt(2), big.NewInt(9))
+		},
+		"BoundedRange CM": func(p ECPoint) (bool, error) {
+			return bd.Verify(TestCurve, p, big.NewInt(2), big.NewInt(9))
+		},
+		"MultiRange.Values.C": func(p ECPoint) (bool, error) {
+			q := *mr
+			q.Values = [][]MultiRangeBit{mr.Values[0], append([]MultiRangeBit{}, mr.Values[1]...)}
+			q.Values[1][2].C = p
+			return q.Verify(TestCurve, []ECPoint{CM, CM2}, 4)
+		},
+		"MultiRange CMs": func(p ECPoint) (bool, error) {
+			return mr.Verify(TestCurve, []ECPoint{CM, p}, 4)
+		},
+		"AggregateRange.Sum": func(p ECPoint) (bool, error) {
+			q, d := *agg, *agg.Sum
+			d.BitCommits = []ECPoint{d.BitCommits[0], p, d.BitCommits[2], d.BitCommits[3]}
+			q.Sum = &d
+			return q.Verify(TestCurve, []ECPoint{CM, CM2}, 4)
+		},
+		"AggregateRange CMs": func(p ECPoint) (bool, error) {
+			return agg.Verify(TestCurve, []ECPoint{CM, p}, 4)
+		},
+		"Decryption.T1": func(p ECPoint) (bool, error) {
+			q := *dec
+			q.T1 = p
+			return q.Verify(TestCurve, CM, CMTok, PK, V)
+		},
+		"Decryption.T2": func(p ECPoint) (bool, error) {
+			q := *dec
+			q.T2 = p
+			return q.Verify(TestCurve, CM, CMTok, PK, V)
+		},
+		"Decryption CM": func(p ECPoint) (bool, error) {
+			return dec.Verify(TestCurve, p, CMTok, PK, V)
+		},
+		"Decryption CMTok": func(p ECPoint) (bool, error) {
+			return dec.Verify(TestCurve, CM, p, PK, V)
+		},
+		"Decryption PK": func(p ECPoint) (bool, error) {
+			return dec.Verify(TestCurve, CM, CMTok, p, V)
+		},
+		"Decryption V": func(p ECPoint) (bool, error) {
+			return dec.Verify(TestCurve, CM, CMTok, PK, p)
+		},
+		"OneOfMany.T": func(p ECPoint) (bool, error) {
+			q := *om
+			q.T = []ECPoint{om.T[0], p}
+			return q.Verify(TestCurve, []ECPoint{TestCurve.G, TestCurve.H}, []ECPoint{A, AH})
+		},
+		"OneOfMany results": func(p ECPoint) (bool, error) {
+			return om.Verify(TestCurve, []ECPoint{TestCurve.G, TestCurve.H}, []ECPoint{p, AH})
+		},
+		"SetMembership.T": func(p ECPoint) (bool, error) {
+			q, b := *sm, *sm.Branches
+			b.T = []ECPoint{b.T[0], p, b.T[2]}
+			q.Branches = &b
+			return q.Verify(TestCurve, CM, set)
+		},
+		"SetMembership CM": func(p ECPoint) (bool, error) {
+			return sm.Verify(TestCurve, p, set)
+		},
+		"Shuffle.C": func(p ECPoint) (bool, error) {
+			q := *sh
+			q.C = []ECPoint{p, sh.C[1]}
+			return q.Verify(TestCurve, shIn, shOut)
+		},
+		"Shuffle.T4": func(p ECPoint) (bool, error) {
+			q := *sh
+			q.T4 = p
+			return q.Verify(TestCurve, shIn, shOut)
+		},
+		"Shuffle outputs": func(p ECPoint) (bool, error) {
+			return sh.Verify(TestCurve, shIn, []ECPoint{shOut[0], p})
+		},
+		"VectorOpen.T": func(p ECPoint) (bool, error) {
+			q := *vo
+			q.T = p
+			return q.Verify(TestCurve, vecCM)
+		},
+		"VectorOpen C": func(p ECPoint) (bool, error) {
+			return vo.Verify(TestCurve, p)
+		},
+		"KeyRotation.T1": func(p ECPoint) (bool, error) {
+			q := *kr
+			q.T1 = p
+			return q.Verify(TestCurve, CMTok, newCMTok, PK, newPK)
+		},
+		"KeyRotation.T2": func(p ECPoint) (bool, error) {
+			q := *kr
+			q.T2 = p
+			return q.Verify(TestCurve, CMTok, newCMTok, PK, newPK)
+		},
+		"KeyRotation newCMTok": func(p ECPoint) (bool, error) {
+			return kr.Verify(TestCurve, CMTok, p, PK, newPK)
+		},
+		"KeyRotation newPK": func(p ECPoint) (bool, error) {
+			return kr.Verify(TestCurve, CMTok, newCMTok, PK, p)
+		},
+		"SameBlinding.T1": func(p ECPoint) (bool, error) {
+			q := *sb
+			q.T1 = p
+			return q.Verify(TestCurve, CMTok, CMTok2, PK, newPK)
+		},
+		"SameBlinding.T2": func(p ECPoint) (bool, error) {
+			q := *sb
+			q.T2 = p
+			return q.Verify(TestCurve, CMTok, CMTok2, PK, newPK)
+		},
+		"SameBlinding CMTok2": func(p ECPoint) (bool, error) {
+			return sb.Verify(TestCurve, CMTok, p, PK, newPK)
+		},
+		"SameBlinding PK2": func(p ECPoint) (bool, error) {
+			return sb.Verify(TestCurve, CMTok, CMTok2, PK, p)
+		},
+		"SameValue.T": func(p ECPoint) (bool, error) {
+			q := *sv
+			q.T = p
+			return q.Verify(TestCurve, CM, sameCM)
+		},
+		"SameValue CM2": func(p ECPoint) (bool, error) {
+			return sv.Verify(TestCurve, CM, p)
+		},
+		"EquivOrZero.T1": func(p ECPoint) (bool, error) {
+			q := *ez
+			q.T1 = p
+			return q.Verify(TestCurve, CM, sameCM)
+		},
+		"EquivOrZero CMDelta": func(p ECPoint) (bool, error) {
+			return ez.Verify(TestCurve, CM, p)
+		},
+		"Balance.T": func(p ECPoint) (bool, error) {
+			q := *bp
+			q.T = p
+			return q.Verify(TestCurve, CM, []ECPoint{sameCM}, []int{1})
+		},
+		"Balance lhs": func(p ECPoint) (bool, error) {
+			return bp.Verify(TestCurve, p, []ECPoint{sameCM}, []int{1})
+		},
+		"Balance terms": func(p ECPoint) (bool, error) {
+			return bp.Verify(TestCurve, CM, []ECPoint{p}, []int{1})
+		},
+		"BitRange.BitCommits": func(p ECPoint) (bool, error) {
+			q := *br
+			q.BitCommits = append([]ECPoint{p}, br.BitCommits[1:]...)
+			return q.Verify(TestCurve, CM, 4)
+		},
+		"BitRange CM": func(p ECPoint) (bool, error) {
+			return br.Verify(TestCurve, p, 4)
+		},
+		"Bit.T1": func(p ECPoint) (bool, error) {
+			q := *bit
+			q.T1 = p
+			return q.Verify(TestCurve, bitC)
+		},
+		"Bit C": func(p ECPoint) (bool, error) {
+			return bit.Verify(TestCurve, p)
+		},
+		"Range.ProofAggregate": func(p ECPoint) (bool, error) {
+			q := *rp
+			q.ProofAggregate = p
+			return q.Verify(TestCurve, rpCM)
+		},
+		"Range.ProofTuples": func(p ECPoint) (bool, error) {
+			q := *rp
+			q.ProofTuples = append([]rangeProofTuple{{p, rp.ProofTuples[0].S}}, rp.ProofTuples[1:]...)
+			return q.Verify(TestCurve, rpCM)
+		},
+		"Range comm": func(p ECPoint) (bool, error) {
+			return rp.Verify(TestCurve, p)
+		},
+	}
+
+	points := []ECPoint{Zero, {}, {TestCurve.G.X, nil}}
+	for ii := 0; ii < 50; ii++ {
+		points = append(points, randomPoint())
+	}
+	for name, verify := range verifiers {
+		for _, p := range points {
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Fatalf("%s panicked on point %v: %v\n", name, p, r)
+					}
+				}()
+				if ok, err := verify(p); ok || err == nil {
+					t.Fatalf("%s accepted point %v\n", name, p)
+				}
+			}()
+		}
+	}
+}
+
+// proofFields returns the points and the scalars other than coordinates
+// reachable from v through exported fields, pointers, slices and interfaces
+func proofFields(v reflect.Value) (points []ECPoint, scalars []*big.Int) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if s, ok := v.Interface().(*big.Int); ok {
+			return nil, []*big.Int{s}
+		}
+		return proofFields(v.Elem())
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			ps, ss := proofFields(v.Index(i))
+			points, scalars = append(points, ps...), append(scalars, ss...)
+		}
+	case reflect.Struct:
+		if p, ok := v.Interface().(ECPoint); ok {
+			return []ECPoint{p}, nil
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				ps, ss := proofFields(v.Field(i))
+				points, scalars = append(points, ps...), append(scalars, ss...)
+			}
+		}
+	}
+	return points, scalars
+}
+
+// reachableScalars returns every *big.Int reachable from v through exported
+// fields, pointers, slices and interfaces, the coordinates of points included
+func reachableScalars(v reflect.Value) []*big.Int {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		if s, ok := v.Interface().(*big.Int); ok {
+			return []*big.Int{s}
+		}
+		return reachableScalars(v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return reachableScalars(v.Elem())
+	case reflect.Slice:
+		var ss []*big.Int
+		for i := 0; i < v.Len(); i++ {
+			ss = append(ss, reachableScalars(v.Index(i))...)
+		}
+		return ss
+	case reflect.Struct:
+		var ss []*big.Int
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				ss = append(ss, reachableScalars(v.Field(i))...)
+			}
+		}
+		return ss
+	}
+	return nil
+}
+
+// TestCloneEqual clones every proof and changes the scalars of the clone one
+// at a time. The original has to keep verifying and Equal has to tell the two
+// apart for every single change.
+func TestCloneEqual(t *testing.T) {
+	N := TestCurve.C.Params().N
+	sk, _ := rand.Int(rand.Reader, N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(5)
+	CM, r, _ := PedCommit(TestCurve, value)
+	CMTok := TestCurve.Mult(PK, r)
+	CM2, r2, _ := PedCommit(TestCurve, big.NewInt(6))
+	x, _ := rand.Int(rand.Reader, N)
+	A := TestCurve.Mult(TestCurve.G, x)
+	AH := TestCurve.Mult(TestCurve.H, x)
+	CMPrime, deltaR, _ := RerandomizeCommitment(TestCurve, CM)
+
+	type proofCase struct {
+		proof  interface{}
+		verify func() (bool, error)
+	}
+	must := func(err error) {
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+	}
+	abc, err := NewABCProof(TestCurve, CM, CMTok, value, sk, Right)
+	must(err)
+	con, err := NewConsistencyProof(TestCurve, CM, CMTok, PK, value, r)
+	must(err)
+	dj, err := NewDisjunctiveProof(TestCurve, TestCurve.G, A, TestCurve.H, AH, x, Left)
+	must(err)
+	eq, err := NewEquivalenceProof(TestCurve, TestCurve.G, A, TestCurve.H, AH, x)
+	must(err)
+	gs, err := NewGSPFSProof(TestCurve, A, x)
+	must(err)
+	ie, err := NewInequalityProof(TestCurve, CM, CM2, value, big.NewInt(6), r, r2)
+	must(err)
+	nz, err := NewNonZeroProof(TestCurve, CM, value, r)
+	must(err)
+	op, err := NewOpenProof(TestCurve, CM, value, r)
+	must(err)
+	ov, err := NewOpenToValueProof(TestCurve, CM, value, r)
+	must(err)
+	pp, PA, PB, PC, err := NewProductProof(TestCurve, big.NewInt(3), big.NewInt(4), r, r2, sk)
+	must(err)
+	mpCMs, mp, err := NewMultiProductProof(TestCurve, []*big.Int{big.NewInt(3), big.NewInt(4), big.NewInt(5)}, []*big.Int{r, r2, sk, r2, r})
+	must(err)
+	rr, err := NewRerandomizationProof(TestCurve, CM, CMPrime, deltaR)
+	must(err)
+	sp, err := NewSumProof(TestCurve, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(6)}, []*big.Int{r, r2}, big.NewInt(11))
+	must(err)
+	br, err := NewBitRangeProof(TestCurve, CM, value, r, 4)
+	must(err)
+	bit, bitC, err := NewBitProof(TestCurve, big.NewInt(1), r)
+	must(err)
+	om, err := NewOneOfManyProof(TestCurve, []ECPoint{TestCurve.G, TestCurve.H}, []ECPoint{CM, AH}, 1, x)
+	must(err)
+	rp, rpR, err := NewRangeProof(TestCurve, value)
+	must(err)
+	rpCM := PedCommitR(TestCurve, value, rpR)
+	cmp, err := NewComparisonProof(TestCurve, CM2, CM, big.NewInt(6), value, r2, r, 4)
+	must(err)
+	bd, err := NewBoundedRangeProof(TestCurve, CM, value, r, big.NewInt(2), big.NewInt(9))
+	must(err)
+	mr, err := NewMultiRangeProof(TestCurve, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(6)}, []*big.Int{r, r2}, 4)
+	must(err)
+	agg, err := NewAggregateRangeProof(TestCurve, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(6)}, []*big.Int{r, r2}, 4)
+	must(err)
+	dec, V, err := NewDecryptionProof(TestCurve, CM, CMTok, sk)
+	must(err)
+	shOut := []ECPoint{TestCurve.Add(CM2, TestCurve.Mult(TestCurve.H, r)), CMPrime}
+	sh, err := NewShuffleProof(TestCurve, []ECPoint{CM, CM2}, shOut, []int{1, 0}, []*big.Int{r, deltaR})
+	must(err)
+	vecCM, _ := VectorPedCommitR(TestCurve, []*big.Int{value, x}, r)
+	vo, err := NewVectorOpenProof(TestCurve, vecCM, []*big.Int{value, x}, r)
+	must(err)
+	newPK := TestCurve.Mult(TestCurve.H, x)
+	newCMTok, _ := RotateToken(TestCurve, CMTok, sk, x)
+	kr, err := NewKeyRotationProof(TestCurve, CMTok, newCMTok, PK, newPK, sk, x)
+	must(err)
+	CMTok2 := TestCurve.Mult(newPK, r)
+	sb, err := NewSameBlindingProof(TestCurve, CMTok, CMTok2, PK, newPK, r)
+	must(err)
+	sameCM, sameR, _ := PedCommit(TestCurve, value)
+	sv, err := NewSameValueProof(TestCurve, CM, sameCM, value, r, sameR)
+	must(err)
+	ez, err := NewEquivOrZeroProof(TestCurve, CM, sameCM, r, sameR, Right)
+	must(err)
+	bp, err := NewBalanceProof(TestCurve, CM, []ECPoint{sameCM}, []int{1}, r, []*big.Int{sameR})
+	must(err)
+	set := []*big.Int{big.NewInt(1), value, big.NewInt(10)}
+	sm, err := NewSetMembershipProof(TestCurve, CM, value, r, set)
+	must(err)
+	ent, err := NewEntryProof(TestCurve, value, r, nil, PK, false)
+	must(err)
+	abcStmt := ABCStatement{CM: CM, CMTok: CMTok, PubKey: PK}
+	gsStmt := GSPFSStatement{Base: TestCurve.G, A: A}
+	statements := []Statement{abcStmt, gsStmt}
+	cp, err := NewComposedProof(TestCurve,
+		abcStmt.WithWitness(ABCWitness{Value: value, SK: sk}, Right), gsStmt.WithWitness(GSPFSWitness{x}))
+	must(err)
+
+	cases := map[string]proofCase{
+		"ABCProof":             {abc, func() (bool, error) { return abc.Verify(TestCurve, CM, CMTok, PK) }},
+		"ConsistencyProof":     {con, func() (bool, error) { return con.Verify(TestCurve, CM, CMTok, PK) }},
+		"DisjunctiveProof":     {dj, func() (bool, error) { return dj.Verify(TestCurve, TestCurve.G, A, TestCurve.H, AH) }},
+		"EquivalenceProof":     {eq, func() (bool, error) { return eq.Verify(TestCurve, TestCurve.G, A, TestCurve.H, AH) }},
+		"GSPFSProof":           {gs, func() (bool, error) { return gs.Verify(TestCurve, A) }},
+		"InequalityProof":      {ie, func() (bool, error) { return ie.Verify(TestCurve, CM, CM2) }},
+		"NonZeroProof":         {nz, func() (bool, error) { return nz.Verify(TestCurve, CM) }},
+		"OpenProof":            {op, func() (bool, error) { return op.Verify(TestCurve, CM) }},
+		"OpenToValueProof":     {ov, func() (bool, error) { return ov.Verify(TestCurve, CM, value) }},
+		"ProductProof":         {pp, func() (bool, error) { return pp.Verify(TestCurve, PA, PB, PC) }},
+		"MultiProductProof":    {mp, func() (bool, error) { return mp.Verify(TestCurve, mpCMs[:3], mpCMs[4]) }},
+		"RerandomizationProof": {rr, func() (bool, error) { return rr.Verify(TestCurve, CM, CMPrime) }},
+		"SumProof":             {sp, func() (bool, error) { return sp.Verify(TestCurve, []ECPoint{CM, CM2}, big.NewInt(11)) }},
+		"BitRangeProof":        {br, func() (bool, error) { return br.Verify(TestCurve, CM, 4) }},
+		"BitProof":             {bit, func() (bool, error) { return bit.Verify(TestCurve, bitC) }},
+		"OneOfManyProof": {om, func() (bool, error) {
+			return om.Verify(TestCurve, []ECPoint{TestCurve.G, TestCurve.H}, []ECPoint{CM, AH})
+		}},
+		"RangeProof":         {rp, func() (bool, error) { return rp.Verify(TestCurve, rpCM) }},
+		"ComparisonProof":    {cmp, func() (bool, error) { return cmp.Verify(TestCurve, CM2, CM, 4) }},
+		"DecryptionProof":    {dec, func() (bool, error) { return dec.Verify(TestCurve, CM, CMTok, PK, V) }},
+		"ComposedProof":      {cp, func() (bool, error) { return cp.Verify(TestCurve, statements...) }},
+		"ShuffleProof":       {sh, func() (bool, error) { return sh.Verify(TestCurve, []ECPoint{CM, CM2}, shOut) }},
+		"VectorOpenProof":    {vo, func() (bool, error) { return vo.Verify(TestCurve, vecCM) }},
+		"KeyRotationProof":   {kr, func() (bool, error) { return kr.Verify(TestCurve, CMTok, newCMTok, PK, newPK) }},
+		"SameBlindingProof":  {sb, func() (bool, error) { return sb.Verify(TestCurve, CMTok, CMTok2, PK, newPK) }},
+		"SameValueProof":     {sv, func() (bool, error) { return sv.Verify(TestCurve, CM, sameCM) }},
+		"EquivOrZeroProof":   {ez, func() (bool, error) { return ez.Verify(TestCurve, CM, sameCM) }},
+		"BalanceProof":       {bp, func() (bool, error) { return bp.Verify(TestCurve, CM, []ECPoint{sameCM}, []int{1}) }},
+		"SetMembershipProof": {sm, func() (bool, error) { return sm.Verify(TestCurve, CM, set) }},
+		"EntryProof":         {ent, func() (bool, error) { return ent.Verify(TestCurve, CM, CMTok, PK) }},
+		"AggregateRangeProof": {agg, func() (bool, error) {
+			return agg.Verify(TestCurve, []ECPoint{CM, CM2}, 4)
+		}},
+		"MultiRangeProof": {mr, func() (bool, error) {
+			return mr.Verify(TestCurve, []ECPoint{CM, CM2}, 4)
+		}},
+		"BoundedRangeProof": {bd, func() (bool, error) {
+			return bd.Verify(TestCurve, CM, big.NewInt(2), big.NewInt(9))
+		}},
+	}
+
+	one := big.NewInt(1)
+	for name, c := range cases {
+		orig := reflect.ValueOf(c.proof)
+		clone := orig.MethodByName("Clone").Call(nil)[0]
+		equal := func() bool {
+			return orig.MethodByName("Equal").Call([]reflect.Value{clone})[0].Bool()
+		}
+		if !equal() {
+			t.Fatalf("%s is not Equal to its Clone\n", name)
+		}
+
+		origScalars, cloneScalars := reachableScalars(orig), reachableScalars(clone)
+		if len(origScalars) == 0 || len(origScalars) != len(cloneScalars) {
+			t.Fatalf("%s has %d scalars, its Clone %d\n", name, len(origScalars), len(cloneScalars))
+		}
+		for i, s := range cloneScalars {
+			if s == origScalars[i] {
+				t.Fatalf("%s shares scalar %d with its Clone\n", name, i)
+			}
+			s.Add(s, one)
+			if equal() {
+				t.Fatalf("%s is Equal to its Clone with scalar %d changed\n", name, i)
+			}
+			if ok, err := c.verify(); !ok || err != nil {
+				t.Fatalf("%s does not verify after changing scalar %d of its Clone: %v\n", name, i, err)
+			}
+			s.Sub(s, one)
+		}
+		if !equal() {
+			t.Fatalf("%s is not Equal to its Clone after undoing the changes\n", name)
+		}
+	}
+
+	// the unexported responses and nested proofs of an ABCProof
+	clone := abc.Clone()
+	for i, s := range []*big.Int{clone.j, clone.k, clone.l, clone.disjuncAC.S1, clone.cTokenProof.S2} {
+		s.Add(s, one)
+		if abc.Equal(clone) {
+			t.Fatalf("ABCProof is Equal to its Clone with hidden scalar %d changed\n", i)
+		}
+		if ok, err := abc.Verify(TestCurve, CM, CMTok, PK); !ok || err != nil {
+			t.Fatalf("ABCProof does not verify after changing hidden scalar %d of its Clone: %v\n", i, err)
+		}
+		s.Sub(s, one)
+	}
+	clone.disjuncAC = nil
+	if abc.Equal(clone) || clone.Equal(abc) {
+		t.Fatalf("ABCProof is Equal to its Clone without disjuncAC\n")
+	}
+
+	var nilABC *ABCProof
+	if nilABC.Clone() != nil || !nilABC.Equal(nil) || nilABC.Equal(abc) || abc.Equal(nil) {
+		t.Fatalf("Clone and Equal do not handle nil proofs\n")
+	}
+	if (&ComposedProof{Proofs: []SubProof{nilABC}}).Equal(&ComposedProof{Proofs: []SubProof{(*GSPFSProof)(nil)}}) {
+		t.Fatalf("ComposedProofs with sub proofs of different types are Equal\n")
+	}
+}
+
+// TestConstantTimeVerify checks that the constant-time and variable-time
+// paths accept and reject the same proofs.
+func TestConstantTimeVerify(t *testing.T) {
+	defer func(old bool) { ConstantTimeVerify = old }(ConstantTimeVerify)
+
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(42)
+	CM, ua, err := PedCommit(TestCurve, value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	CMTok := TestCurve.Mult(PK, ua)
+	good, err := NewABCProof(TestCurve, CM, CMTok, value, sk, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	badChallenge := *good
+	badChallenge.Challenge = new(big.Int).Add(good.Challenge, big.NewInt(1))
+	badEquation := *good
+	badEquation.j = new(big.Int).Add(good.j, big.NewInt(1))
+
+	for _, ct := range []bool{true, false} {
+		ConstantTimeVerify = ct
+		if ok, err := good.Verify(TestCurve, CM, CMTok, PK); !ok || err != nil {
+			t.Fatalf("ConstantTimeVerify=%v: valid proof rejected: %v\n", ct, err)
+		}
+		if ok, _ := badChallenge.Verify(TestCurve, CM, CMTok, PK); ok {
+			t.Fatalf("ConstantTimeVerify=%v: proof with bad challenge accepted\n", ct)
+		}
+		if ok, _ := badEquation.Verify(TestCurve, CM, CMTok, PK); ok {
+			t.Fatalf("ConstantTimeVerify=%v: proof with bad response accepted\n", ct)
+		}
+		// a negated coordinate has the same fixed-width encoding
+		G := TestCurve.G
+		for _, p := range []ECPoint{{new(big.Int).Neg(G.X), G.Y}, {G.X, new(big.Int).Neg(G.Y)}} {
+			if pointsEqual(G, p) || pointsEqual(p, G) {
+				t.Fatalf("ConstantTimeVerify=%v: G equal to %v\n", ct, p)
+			}
+		}
+		if scalarsEqual(good.j, new(big.Int).Neg(good.j)) {
+			t.Fatalf("ConstantTimeVerify=%v: j equal to -j\n", ct)
+		}
+	}
+
+	// on a curve wider than 256 bits the comparisons pad to its length
+	p384, err := NewZKCurve(elliptic.P384())
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	sk384, _ := rand.Int(rand.Reader, p384.C.Params().N)
+	PK384 := p384.Mult(p384.H, sk384)
+	CM384, ua384, err := PedCommit(p384, value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	CMTok384 := p384.Mult(PK384, ua384)
+	proof384, err := NewABCProof(p384, CM384, CMTok384, value, sk384, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	bad384 := *proof384
+	bad384.Challenge = new(big.Int).Add(proof384.Challenge, big.NewInt(1))
+	for _, ct := range []bool{true, false} {
+		ConstantTimeVerify = ct
+		if ok, err := proof384.Verify(p384, CM384, CMTok384, PK384); !ok || err != nil {
+			t.Fatalf("ConstantTimeVerify=%v: valid proof on P-384 rejected: %v\n", ct, err)
+		}
+		if ok, _ := bad384.Verify(p384, CM384, CMTok384, PK384); ok {
+			t.Fatalf("ConstantTimeVerify=%v: proof on P-384 with bad challenge accepted\n", ct)
+		}
+		if !scalarsEqual(proof384.Challenge, new(big.Int).Set(proof384.Challenge)) || scalarsEqual(proof384.Challenge, bad384.Challenge) {
+			t.Fatalf("ConstantTimeVerify=%v: scalarsEqual is wrong for a P-384 challenge\n", ct)
+		}
+	}
+}
+
 func TestZkpCryptoStuff(t *testing.T) {
 	value := big.NewInt(-100)
 
@@ -120,6 +1660,477 @@ func TestPedersenCommit(t *testing.T) {
 
 }
 
+// naiveMultiMult is MultiMult done with Mult and Add
+func naiveMultiMult(zkpcp ZKPCurveParams, points []ECPoint, scalars []*big.Int) ECPoint {
+	total := Zero
+	for i := range points {
+		total = zkpcp.Add(total, zkpcp.Mult(points[i], scalars[i]))
+	}
+	return total
+}
+
+func TestMultiMult(t *testing.T) {
+	for _, zkpcp := range []ZKPCurveParams{TestCurve, withoutTables(TestCurve), secp256k1Curve(t), P256Curve} {
+		N := zkpcp.C.Params().N
+		for _, n := range []int{0, 1, 2, 3, 4, 7, 64} {
+			for round := 0; round < 4; round++ {
+				points := make([]ECPoint, n)
+				scalars := make([]*big.Int, n)
+				for i := range points {
+					x, _ := rand.Int(rand.Reader, N)
+					points[i] = zkpcp.Mult(zkpcp.G, x)
+					scalars[i], _ = rand.Int(rand.Reader, new(big.Int).Lsh(N, 1))
+					if i%2 == 1 {
+						scalars[i].Neg(scalars[i]) // reduced mod N like in Mult
+					}
+				}
+				// the generators, the identity and zero scalars in between
+				if n > 2 {
+					points[0], points[1] = zkpcp.G, zkpcp.H
+					points[n-1] = Zero
+					scalars[2] = big.NewInt(0)
+				}
+				if got, expect := zkpcp.MultiMult(points, scalars), naiveMultiMult(zkpcp, points, scalars); !got.Equal(expect) {
+					t.Fatalf("MultiMult of %d points gives %v, expected %v\n", n, got, expect)
+				}
+			}
+		}
+
+		// terms that cancel out add up to the identity
+		x, _ := rand.Int(rand.Reader, N)
+		A := zkpcp.Mult(zkpcp.G, x)
+		if got := zkpcp.MultiMult([]ECPoint{A, zkpcp.G, zkpcp.H, A}, []*big.Int{big.NewInt(1), new(big.Int).Neg(x), N, new(big.Int).Neg(N)}); !got.Equal(Zero) {
+			t.Fatalf("MultiMult of terms that cancel out gives %v\n", got)
+		}
+		if got := zkpcp.MultiMult([]ECPoint{A}, []*big.Int{nil}); !got.Equal(Zero) {
+			t.Fatalf("MultiMult with a nil scalar gives %v\n", got)
+		}
+		if got := zkpcp.MultiMult([]ECPoint{A, {}}, []*big.Int{x, x}); got.X != nil || got.Y != nil {
+			t.Fatalf("MultiMult with a nil point gives %v\n", got)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("MultiMult accepted 2 points and 1 scalar\n")
+		}
+	}()
+	TestCurve.MultiMult([]ECPoint{TestCurve.G, TestCurve.H}, []*big.Int{big.NewInt(1)})
+}
+
+// TestArithmeticAllocs checks that the point arithmetic of the verification
+// equations keeps to its allocation budget, mostly the result points
+func TestArithmeticAllocs(t *testing.T) {
+	P := TestCurve.Mult(TestCurve.G, big.NewInt(7))
+	Q := TestCurve.Mult(TestCurve.H, big.NewInt(9))
+	s, negS := big.NewInt(12345), big.NewInt(-12345)
+	var P521 ECPoint
+	P521.X, P521.Y = elliptic.P521().ScalarBaseMult(s.Bytes())
+	cases := []struct {
+		name   string
+		budget float64
+		f      func()
+	}{
+		{"Add", 1, func() { TestCurve.Add(P, Q) }},
+		{"Sub", 3, func() { TestCurve.Sub(P, Q) }},
+		{"Neg", 4, func() { TestCurve.Neg(P) }},
+		{"Mult of G", 2, func() { TestCurve.Mult(TestCurve.G, s) }},
+		{"MultiMult", 6, func() {
+			TestCurve.MultiMult([]ECPoint{TestCurve.G, TestCurve.H, P, Q}, []*big.Int{s, s, s, negS})
+		}},
+		{"Equal", 0, func() { P.Equal(Q) }},
+		{"EqualCT", 0, func() { P.EqualCT(Q) }},
+		{"EqualCT on P-521", 0, func() { P521.EqualCT(P521) }},
+		{"scalarsEqual", 0, func() { scalarsEqual(s, negS) }},
+		{"IsOnCurve", 0, func() { P.IsOnCurve(TestCurve) }},
+	}
+	for _, c := range cases {
+		if allocs := testing.AllocsPerRun(20, c.f); allocs > c.budget {
+			t.Fatalf("TestArithmeticAllocs - %s allocates %v times, budget is %v\n", c.name, allocs, c.budget)
+		}
+	}
+}
+
+// TestConstructorInputs passes nil, negative and too large scalars to every
+// constructor in place of each of its scalar inputs, which all have to be
+// rejected with ErrValueOutOfRange, and zero, which must not panic.
+func TestConstructorInputs(t *testing.T) {
+	zkpcp := TestCurve
+	N := zkpcp.C.Params().N
+
+	value := big.NewInt(5)
+	r, _ := rand.Int(rand.Reader, N)
+	r2, _ := rand.Int(rand.Reader, N)
+	sk, _ := rand.Int(rand.Reader, N)
+	PK := zkpcp.Mult(zkpcp.H, sk)
+	CM := PedCommitR(zkpcp, value, r)
+	CM2 := PedCommitR(zkpcp, big.NewInt(6), r2)
+	CMTok := zkpcp.Mult(PK, r)
+
+	constructors := []struct {
+		name    string
+		scalars []*big.Int
+		prove   func(ss []*big.Int) error
+	}{
+		{"PedCommit", []*big.Int{value}, func(ss []*big.Int) error {
+			_, _, err := PedCommit(zkpcp, ss[0])
+			return err
+		}},
+		{"ABCProof", []*big.Int{value, sk}, func(ss []*big.Int) error {
+			_, err := NewABCProof(zkpcp, CM, CMTok, ss[0], ss[1], Right)
+			return err
+		}},
+		{"ConsistencyProof", []*big.Int{value, r}, func(ss []*big.Int) error {
+			_, err := NewConsistencyProof(zkpcp, CM, CMTok, PK, ss[0], ss[1])
+			return err
+		}},
+		{"DisjunctiveProof", []*big.Int{sk}, func(ss []*big.Int) error {
+			_, err := NewDisjunctiveProof(zkpcp, zkpcp.H, PK, zkpcp.G, CM, ss[0], Left)
+			return err
+		}},
+		{"EquivalenceProof", []*big.Int{sk}, func(ss []*big.Int) error {
+			_, err := NewEquivalenceProof(zkpcp, zkpcp.H, PK, CM, zkpcp.Mult(CM, sk), ss[0])
+			return err
+		}},
+		{"GSPFSProof", []*big.Int{sk}, func(ss []*big.Int) error {
+			_, err := NewGSPFSProof(zkpcp, zkpcp.Mult(zkpcp.G, sk), ss[0])
+			return err
+		}},
+		{"InequalityProof", []*big.Int{value, big.NewInt(6), r, r2}, func(ss []*big.Int) error {
+			_, err := NewInequalityProof(zkpcp, CM, CM2, ss[0], ss[1], ss[2], ss[3])
+			return err
+		}},
+		{"OpenProof", []*big.Int{value, r}, func(ss []*big.Int) error {
+			_, err := NewOpenProof(zkpcp, CM, ss[0], ss[1])
+			return err
+		}},
+		{"ProductProof", []*big.Int{value, big.NewInt(6), r, r2, sk}, func(ss []*big.Int) error {
+			_, _, _, _, err := NewProductProof(zkpcp, ss[0], ss[1], ss[2], ss[3], ss[4])
+			return err
+		}},
+		{"MultiProductProof", []*big.Int{value, big.NewInt(6), r, r2, sk}, func(ss []*big.Int) error {
+			_, _, err := NewMultiProductProof(zkpcp, ss[:2], ss[2:])
+			return err
+		}},
+		{"BitProof", []*big.Int{big.NewInt(1), r}, func(ss []*big.Int) error {
+			_, _, err := NewBitProof(zkpcp, ss[0], ss[1])
+			return err
+		}},
+		{"BitRangeProof", []*big.Int{value, r}, func(ss []*big.Int) error {
+			_, err := NewBitRangeProof(zkpcp, CM, ss[0], ss[1], 8)
+			return err
+		}},
+		{"RangeProof", []*big.Int{value}, func(ss []*big.Int) error {
+			_, _, err := NewRangeProof(zkpcp, ss[0])
+			return err
+		}},
+		{"OneOfManyProof", []*big.Int{sk}, func(ss []*big.Int) error {
+			_, err := NewOneOfManyProof(zkpcp, []ECPoint{zkpcp.G, zkpcp.H}, []ECPoint{CM, PK}, 1, ss[0])
+			return err
+		}},
+		{"RerandomizationProof", []*big.Int{r2}, func(ss []*big.Int) error {
+			_, err := NewRerandomizationProof(zkpcp, CM, zkpcp.Add(CM, zkpcp.Mult(zkpcp.H, r2)), ss[0])
+			return err
+		}},
+		{"SameValueProof", []*big.Int{value, r, r}, func(ss []*big.Int) error {
+			_, err := NewSameValueProof(zkpcp, CM, CM, ss[0], ss[1], ss[2])
+			return err
+		}},
+		{"EquivOrZeroProof", []*big.Int{r, r}, func(ss []*big.Int) error {
+			_, err := NewEquivOrZeroProof(zkpcp, CM, CM, ss[0], ss[1], Right)
+			return err
+		}},
+		{"BalanceProof", []*big.Int{r, r}, func(ss []*big.Int) error {
+			_, err := NewBalanceProof(zkpcp, CM, []ECPoint{CM}, []int{1}, ss[0], []*big.Int{ss[1]})
+			return err
+		}},
+		{"SetMembershipProof", []*big.Int{value, r, big.NewInt(1), big.NewInt(10)}, func(ss []*big.Int) error {
+			_, err := NewSetMembershipProof(zkpcp, CM, ss[0], ss[1], []*big.Int{ss[2], big.NewInt(5), ss[3]})
+			return err
+		}},
+		{"NonZeroProof", []*big.Int{value, r}, func(ss []*big.Int) error {
+			_, err := NewNonZeroProof(zkpcp, CM, ss[0], ss[1])
+			return err
+		}},
+		{"OpenToValueProof", []*big.Int{value, r}, func(ss []*big.Int) error {
+			_, err := NewOpenToValueProof(zkpcp, CM, ss[0], ss[1])
+			return err
+		}},
+		{"ComparisonProof", []*big.Int{big.NewInt(6), value, r2, r}, func(ss []*big.Int) error {
+			_, err := NewComparisonProof(zkpcp, CM2, CM, ss[0], ss[1], ss[2], ss[3], 8)
+			return err
+		}},
+		{"BoundedRangeProof", []*big.Int{value, r, big.NewInt(2), big.NewInt(9)}, func(ss []*big.Int) error {
+			_, err := NewBoundedRangeProof(zkpcp, CM, ss[0], ss[1], ss[2], ss[3])
+			return err
+		}},
+		{"MultiRangeProof", []*big.Int{value, big.NewInt(6), r, r2}, func(ss []*big.Int) error {
+			_, err := NewMultiRangeProof(zkpcp, []ECPoint{CM, CM2}, ss[:2], ss[2:], 8)
+			return err
+		}},
+		{"AggregateRangeProof", []*big.Int{value, big.NewInt(6), r, r2}, func(ss []*big.Int) error {
+			_, err := NewAggregateRangeProof(zkpcp, []ECPoint{CM, CM2}, ss[:2], ss[2:], 8)
+			return err
+		}},
+		{"DecryptionProof", []*big.Int{sk}, func(ss []*big.Int) error {
+			_, _, err := NewDecryptionProof(zkpcp, CM, CMTok, ss[0])
+			return err
+		}},
+		{"ShuffleProof", []*big.Int{r2}, func(ss []*big.Int) error {
+			_, err := NewShuffleProof(zkpcp, []ECPoint{CM}, []ECPoint{zkpcp.Add(CM, zkpcp.Mult(zkpcp.H, r2))}, []int{0}, ss)
+			return err
+		}},
+		{"VectorOpenProof", []*big.Int{value, r2, r}, func(ss []*big.Int) error {
+			vecCM, _ := VectorPedCommitR(zkpcp, []*big.Int{value, r2}, r)
+			_, err := NewVectorOpenProof(zkpcp, vecCM, ss[:2], ss[2])
+			return err
+		}},
+		{"KeyRotationProof", []*big.Int{sk, r2}, func(ss []*big.Int) error {
+			newPK := zkpcp.Mult(zkpcp.H, r2)
+			newCMTok, _ := RotateToken(zkpcp, CMTok, sk, r2)
+			_, err := NewKeyRotationProof(zkpcp, CMTok, newCMTok, PK, newPK, ss[0], ss[1])
+			return err
+		}},
+		{"SameBlindingProof", []*big.Int{r}, func(ss []*big.Int) error {
+			PK2 := zkpcp.Mult(zkpcp.H, r2)
+			_, err := NewSameBlindingProof(zkpcp, CMTok, zkpcp.Mult(PK2, r), PK, PK2, ss[0])
+			return err
+		}},
+		{"SumProof", []*big.Int{value, r, big.NewInt(5)}, func(ss []*big.Int) error {
+			_, err := NewSumProof(zkpcp, []ECPoint{CM}, []*big.Int{ss[0]}, []*big.Int{ss[1]}, ss[2])
+			return err
+		}},
+	}
+
+	// prove calls c.prove with the ith scalar replaced by bad and turns a
+	// panic into an error
+	prove := func(c int, i int, bad *big.Int) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				t.Fatalf("%s panics with scalar %d = %v: %v\n", constructors[c].name, i, bad, p)
+			}
+		}()
+		ss := append([]*big.Int{}, constructors[c].scalars...)
+		ss[i] = bad
+		return constructors[c].prove(ss)
+	}
+
+	for c, constructor := range constructors {
+		if err := constructor.prove(constructor.scalars); err != nil {
+			t.Fatalf("%s fails with valid inputs: %v\n", constructor.name, err)
+		}
+		for i := range constructor.scalars {
+			bads := []*big.Int{nil, big.NewInt(-1), N, new(big.Int).Add(N, big.NewInt(5))}
+			if constructor.name == "PedCommit" || (constructor.name == "SumProof" && i == 2) {
+				bads = bads[:1] // commits to value mod N, and any total is reduced mod N
+			}
+			for _, bad := range bads {
+				if err := prove(c, i, bad); !errors.Is(err, ErrValueOutOfRange) {
+					t.Fatalf("%s with scalar %d = %v gives %v, expected ErrValueOutOfRange\n", constructor.name, i, bad, err)
+				}
+			}
+			prove(c, i, big.NewInt(0))
+		}
+	}
+
+	if _, err := NewABCProof(zkpcp, CM, CMTok, value, big.NewInt(0), Right); !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("ABCProof with a zero sk gives %v, expected ErrValueOutOfRange\n", err)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	value := big.NewInt(5)
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	CM, r, _ := PedCommit(TestCurve, value)
+	CMTok := TestCurve.Mult(PK, r)
+	proof, err := NewABCProof(TestCurve, CM, CMTok, value, sk, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	tampered := *proof
+	tampered.Challenge = new(big.Int).Add(proof.Challenge, big.NewInt(1))
+	_, err = tampered.Verify(TestCurve, CM, CMTok, PK)
+	if !errors.Is(err, ErrChallengeMismatch) || errors.Is(err, ErrEquationFailed) {
+		t.Fatalf("tampered challenge gives %v, expected ErrChallengeMismatch\n", err)
+	}
+	if errors.Is(err, ErrSubProofFailed) {
+		t.Fatalf("tampered challenge of the ABCProof itself matches ErrSubProofFailed\n")
+	}
+	var perr *ProofError
+	if !errors.As(err, &perr) || perr.Code != ErrCodeChallengeMismatch || perr.Type != "ABCVerify" || perr.Equation != 0 {
+		t.Fatalf("tampered challenge gives %#v\n", perr)
+	}
+	if wrapped := fmt.Errorf("payment 3: %w", err); !errors.Is(wrapped, ErrChallengeMismatch) {
+		t.Fatalf("wrapping loses ErrChallengeMismatch\n")
+	}
+
+	tampered = *proof
+	tampered.j = new(big.Int).Add(proof.j, big.NewInt(1))
+	_, err = tampered.Verify(TestCurve, CM, CMTok, PK)
+	if !errors.As(err, &perr) || !errors.Is(err, ErrEquationFailed) || perr.Equation != 1 {
+		t.Fatalf("tampered response gives %v, expected equation 1 to fail\n", err)
+	}
+
+	// errors of nested and batched proofs keep the error they wrap
+	_, err = VerifyABCBatch(TestCurve, []*ABCProof{proof, &tampered}, []ECPoint{CM, CM},
+		[]ECPoint{CMTok, CMTok}, []ECPoint{PK, PK})
+	if !errors.As(err, &perr) || perr.Type != "VerifyABCBatch" || !errors.Is(err, ErrEquationFailed) || perr.Equation != 1 {
+		t.Fatalf("batch with a tampered proof gives %v\n", err)
+	}
+	if errors.Is(err, ErrSubProofFailed) {
+		t.Fatalf("batch with a tampered proof matches ErrSubProofFailed: %v\n", err)
+	}
+	abcStmt := ABCStatement{CM: CM, CMTok: CMTok, PubKey: PK}
+	composed, err := NewComposedProof(TestCurve, abcStmt.WithWitness(ABCWitness{Value: value, SK: sk}, Right))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	composed.Proofs[0].(*ABCProof).j.Add(composed.Proofs[0].(*ABCProof).j, big.NewInt(1))
+	if _, err := composed.Verify(TestCurve, abcStmt); !errors.Is(err, ErrEquationFailed) || !errors.Is(err, ErrSubProofFailed) {
+		t.Fatalf("composed proof with a tampered response gives %v\n", err)
+	}
+
+	// a failed nested proof matches ErrSubProofFailed as well as its own code
+	nested := proof.Clone()
+	nested.disjuncAC.S1 = new(big.Int).Add(nested.disjuncAC.S1, big.NewInt(1))
+	_, err = nested.Verify(TestCurve, CM, CMTok, PK)
+	if !errors.Is(err, ErrSubProofFailed) || !errors.Is(err, ErrEquationFailed) || !errors.As(err, &perr) || perr.Type != "ABCVerify" {
+		t.Fatalf("tampered DisjunctiveProof of an ABCProof gives %v\n", err)
+	}
+	if wrapped := fmt.Errorf("payment 3: %w", err); !errors.Is(wrapped, ErrSubProofFailed) {
+		t.Fatalf("wrapping loses ErrSubProofFailed\n")
+	}
+	bounded, err := NewBoundedRangeProof(TestCurve, CM, value, r, big.NewInt(1), big.NewInt(10))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if _, err := bounded.Verify(TestCurve, CM, big.NewInt(6), big.NewInt(10)); !errors.Is(err, ErrSubProofFailed) {
+		t.Fatalf("BoundedRangeProof for the wrong bounds gives %v\n", err)
+	}
+	for _, sentinel := range []error{ErrInvalidInput, ErrNotOnCurve, ErrChallengeMismatch, ErrEquationFailed, ErrMalformedProof} {
+		if errors.Is(ErrSubProofFailed, sentinel) || errors.Is(sentinel, ErrSubProofFailed) {
+			t.Fatalf("ErrSubProofFailed and %v match each other\n", sentinel)
+		}
+	}
+
+	var nilProof *ABCProof
+	for _, c := range []struct {
+		name   string
+		err    error
+		expect error
+	}{
+		{"nil proof", func() error { _, err := nilProof.Verify(TestCurve, CM, CMTok, PK); return err }(), ErrMalformedProof},
+		{"invalid point", func() error { _, err := proof.Verify(TestCurve, randomPoint(), CMTok, PK); return err }(), ErrNotOnCurve},
+		{"truncated encoding", func() error { _, err := NewGSPFSProofFromBytes([]byte{proofEncodingVersion}); return err }(), ErrMalformedProof},
+		{"wrong value", func() error { _, err := NewOpenProof(TestCurve, CM, big.NewInt(6), r); return err }(), ErrInvalidInput},
+		{"nil value", func() error { _, err := NewABCProof(TestCurve, CM, CMTok, nil, sk, Right); return err }(), ErrValueOutOfRange},
+	} {
+		if !errors.Is(c.err, c.expect) {
+			t.Fatalf("%s gives %v, expected %v\n", c.name, c.err, c.expect)
+		}
+	}
+}
+
+// TestVerifyNonCanonicalScalars adds N to and subtracts N from each scalar of
+// every proof. That is the same scalar mod N, which would verify but encode
+// differently, so Verify has to reject it.
+func TestVerifyNonCanonicalScalars(t *testing.T) {
+	N := TestCurve.C.Params().N
+	for name, c := range verifiableProofs(t) {
+		clone := reflect.ValueOf(c.proof).MethodByName("Clone").Call(nil)[0]
+		proof := clone.Interface().(Verifiable)
+		_, scalars := proofFields(clone)
+		for i, s := range scalars {
+			orig := new(big.Int).Set(s)
+			for _, shifted := range []*big.Int{new(big.Int).Add(orig, N), new(big.Int).Sub(orig, N)} {
+				s.Set(shifted)
+				if ok, err := proof.VerifyArgs(TestCurve, c.args...); ok || !errors.Is(err, ErrNonCanonicalScalar) {
+					t.Fatalf("%s with scalar %d = %v gives %v, %v, expected ErrNonCanonicalScalar\n", name, i, s, ok, err)
+				}
+			}
+			s.Set(orig)
+		}
+		if ok, err := proof.VerifyArgs(TestCurve, c.args...); !ok || err != nil {
+			t.Fatalf("%s does not verify after undoing the changes: %v\n", name, err)
+		}
+	}
+}
+
+// TestDecodeNonCanonical writes N in place of each scalar, and a point with X
+// not less than P in place of the first point, of the encoding of every proof
+// with fixed width scalars, which decoding has to reject
+func TestDecodeNonCanonical(t *testing.T) {
+	decoders := map[string]func([]byte) error{
+		"ABCProof":             func(b []byte) error { _, err := NewABCProofFromBytes(b); return err },
+		"AggregateRangeProof":  func(b []byte) error { _, err := NewAggregateRangeProofFromBytes(b); return err },
+		"BalanceProof":         func(b []byte) error { _, err := NewBalanceProofFromBytes(b); return err },
+		"BitProof":             func(b []byte) error { _, err := NewBitProofFromBytes(b); return err },
+		"BitRangeProof":        func(b []byte) error { _, err := NewBitRangeProofFromBytes(b); return err },
+		"BoundedRangeProof":    func(b []byte) error { _, err := NewBoundedRangeProofFromBytes(b); return err },
+		"ComparisonProof":      func(b []byte) error { _, err := NewComparisonProofFromBytes(b); return err },
+		"ConsistencyProof":     func(b []byte) error { _, err := NewConsistencyProofFromBytes(b); return err },
+		"DecryptionProof":      func(b []byte) error { _, err := NewDecryptionProofFromBytes(b); return err },
+		"DisjunctiveProof":     func(b []byte) error { _, err := NewDisjunctiveProofFromBytes(b); return err },
+		"EquivOrZeroProof":     func(b []byte) error { _, err := NewEquivOrZeroProofFromBytes(b); return err },
+		"EquivalenceProof":     func(b []byte) error { _, err := NewEquivalenceProofFromBytes(b); return err },
+		"GSPFSProof":           func(b []byte) error { _, err := NewGSPFSProofFromBytes(b); return err },
+		"InequalityProof":      func(b []byte) error { _, err := NewInequalityProofFromBytes(b); return err },
+		"KeyRotationProof":     func(b []byte) error { _, err := NewKeyRotationProofFromBytes(b); return err },
+		"NonZeroProof":         func(b []byte) error { _, err := NewNonZeroProofFromBytes(b); return err },
+		"OneOfManyProof":       func(b []byte) error { _, err := NewOneOfManyProofFromBytes(b); return err },
+		"OpenProof":            func(b []byte) error { _, err := NewOpenProofFromBytes(b); return err },
+		"OpenToValueProof":     func(b []byte) error { _, err := NewOpenToValueProofFromBytes(b); return err },
+		"ProductProof":         func(b []byte) error { _, err := NewProductProofFromBytes(b); return err },
+		"MultiProductProof":    func(b []byte) error { _, err := NewMultiProductProofFromBytes(b); return err },
+		"MultiRangeProof":      func(b []byte) error { _, err := NewMultiRangeProofFromBytes(b); return err },
+		"RerandomizationProof": func(b []byte) error { _, err := NewRerandomizationProofFromBytes(b); return err },
+		"SameBlindingProof":    func(b []byte) error { _, err := NewSameBlindingProofFromBytes(b); return err },
+		"SameValueProof":       func(b []byte) error { _, err := NewSameValueProofFromBytes(b); return err },
+		"SetMembershipProof":   func(b []byte) error { _, err := NewSetMembershipProofFromBytes(b); return err },
+		"ShuffleProof":         func(b []byte) error { _, err := NewShuffleProofFromBytes(b); return err },
+		"SumProof":             func(b []byte) error { _, err := NewSumProofFromBytes(b); return err },
+		"VectorOpenProof":      func(b []byte) error { _, err := NewVectorOpenProofFromBytes(b); return err },
+	}
+	N := TestCurve.C.Params().N.FillBytes(make([]byte, fixedScalarLen))
+	nonCanonical := nonCanonicalPoint(t)
+
+	cases := verifiableProofs(t)
+	for name, decode := range decoders {
+		proof := cases[name].proof
+		encoded := proof.(interface{ Bytes() []byte }).Bytes()
+		if err := decode(encoded); err != nil {
+			t.Fatalf("%s fails to decode: %v\n", name, err)
+		}
+		points, scalars := proofFields(reflect.ValueOf(proof))
+		if len(scalars) == 0 {
+			t.Fatalf("%s has no scalars\n", name)
+		}
+		for i, s := range scalars {
+			at := bytes.Index(encoded, s.FillBytes(make([]byte, fixedScalarLen)))
+			if at < 0 {
+				t.Fatalf("%s does not encode scalar %d\n", name, i)
+			}
+			b := append([]byte{}, encoded...)
+			copy(b[at:], N)
+			if err := decode(b); !errors.Is(err, ErrNonCanonicalScalar) {
+				t.Fatalf("%s with N for scalar %d gives %v, expected ErrNonCanonicalScalar\n", name, i, err)
+			}
+		}
+
+		at := bytes.Index(encoded, compressPoint(TestCurve.C, points[0]))
+		if at < 0 {
+			t.Fatalf("%s does not encode its first point\n", name)
+		}
+		b := append([]byte{}, encoded...)
+		copy(b[at:], nonCanonical)
+		if err := decode(b); !errors.Is(err, ErrMalformedProof) {
+			t.Fatalf("%s with a non-canonical point gives %v, expected ErrMalformedProof\n", name, err)
+		}
+	}
+}
+
 // TODO: make a ton more test cases
 
 type etx struct {
@@ -128,7 +2139,7 @@ type etx struct {
 	ABCP  *ABCProof
 }
 
-//TODO: make a sk-pk that is consistant across all test cases
+// TODO: make a sk-pk that is consistant across all test cases
 func TestAverages_Basic(t *testing.T) {
 
 	// remember to change both number here...
@@ -190,7 +2201,7 @@ func TestAverages_Basic(t *testing.T) {
 	eProofNumTx, status := NewEquivalenceProof(TestCurve, B1, R1, B2, R2, sk)
 
 	if status != nil {
-		proofStatus(status.(*errorProof))
+		proofStatus(status.(*ProofError))
 		t.Logf("Average Test: equivalence proof failed to generate for numTx\n")
 		t.Fatalf("Averages did not generate correct NUMTX equivalence proof\n")
 	}
@@ -201,7 +2212,7 @@ func TestAverages_Basic(t *testing.T) {
 	eProofValue, status1 := NewEquivalenceProof(TestCurve, B1, R1, B2, R2, sk)
 
 	if status1 != nil {
-		proofStatus(status1.(*errorProof))
+		proofStatus(status1.(*ProofError))
 		t.Logf("Average Test: equivalence proof failed to generate for value sum\n")
 		t.Fatalf("Averages did not generate correct VALUE equivalence proof\n")
 	}
@@ -292,6 +2303,117 @@ func BenchmarkPedCommitR(b *testing.B) {
 	}
 }
 
+func BenchmarkEqual(b *testing.B) {
+	value, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	p := TestCurve.Mult(TestCurve.G, value)
+	q := ECPoint{new(big.Int).Set(p.X), new(big.Int).Set(p.Y)}
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		p.Equal(q)
+	}
+}
+
+func BenchmarkEqualCT(b *testing.B) {
+	value, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	p := TestCurve.Mult(TestCurve.G, value)
+	q := ECPoint{new(big.Int).Set(p.X), new(big.Int).Set(p.Y)}
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		p.EqualCT(q)
+	}
+}
+
+// multiMultTerms returns n random points and scalars, the first two points
+// are G and H like in the verification equations
+func multiMultTerms(n int) ([]ECPoint, []*big.Int) {
+	N := TestCurve.C.Params().N
+	points := make([]ECPoint, n)
+	scalars := make([]*big.Int, n)
+	for i := range points {
+		x, _ := rand.Int(rand.Reader, N)
+		points[i] = TestCurve.Mult(TestCurve.G, x)
+		scalars[i], _ = rand.Int(rand.Reader, N)
+	}
+	points[0], points[1] = TestCurve.G, TestCurve.H
+	return points, scalars
+}
+
+func benchmarkMultiMult(b *testing.B, n int, naive bool) {
+	points, scalars := multiMultTerms(n)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		if naive {
+			naiveMultiMult(TestCurve, points, scalars)
+		} else {
+			TestCurve.MultiMult(points, scalars)
+		}
+	}
+}
+
+func BenchmarkMultiMult2(b *testing.B)       { benchmarkMultiMult(b, 2, false) }
+func BenchmarkMultiMult4(b *testing.B)       { benchmarkMultiMult(b, 4, false) }
+func BenchmarkMultiMult64(b *testing.B)      { benchmarkMultiMult(b, 64, false) }
+func BenchmarkNaiveMultiMult2(b *testing.B)  { benchmarkMultiMult(b, 2, true) }
+func BenchmarkNaiveMultiMult4(b *testing.B)  { benchmarkMultiMult(b, 4, true) }
+func BenchmarkNaiveMultiMult64(b *testing.B) { benchmarkMultiMult(b, 64, true) }
+
+func TestBatchModInverse(t *testing.T) {
+	N := TestCurve.C.Params().N
+	values := []*big.Int{big.NewInt(1), big.NewInt(0), new(big.Int).Sub(N, big.NewInt(1)),
+		big.NewInt(0), new(big.Int).Add(N, big.NewInt(2)), new(big.Int).Set(N)}
+	for ii := 0; ii < 20; ii++ {
+		v, _ := rand.Int(rand.Reader, N)
+		values = append(values, v)
+	}
+
+	invs := BatchModInverse(values, N)
+	if len(invs) != len(values) {
+		t.Fatalf("BatchModInverse returned %d inverses for %d values\n", len(invs), len(values))
+	}
+	for i, v := range values {
+		expect := new(big.Int).ModInverse(v, N)
+		if (expect == nil) != (invs[i] == nil) || (expect != nil && expect.Cmp(invs[i]) != 0) {
+			t.Fatalf("BatchModInverse of %v is %v, expected %v\n", v, invs[i], expect)
+		}
+	}
+
+	// a modulus that is not prime, where 3 and 5 have no inverse
+	mod := big.NewInt(15)
+	invs = BatchModInverse([]*big.Int{big.NewInt(3), big.NewInt(4), big.NewInt(0), big.NewInt(7), big.NewInt(10)}, mod)
+	for i, expect := range []int64{-1, 4, -1, 13, -1} {
+		if (expect < 0) != (invs[i] == nil) || (expect >= 0 && invs[i].Int64() != expect) {
+			t.Fatalf("BatchModInverse mod 15 gives %v at %d, expected %d\n", invs[i], i, expect)
+		}
+	}
+
+	if invs := BatchModInverse(nil, N); len(invs) != 0 {
+		t.Fatalf("BatchModInverse of no values gives %v\n", invs)
+	}
+}
+
+func benchmarkModInverse(b *testing.B, n int, batch bool) {
+	N := TestCurve.C.Params().N
+	values := make([]*big.Int, n)
+	for i := range values {
+		values[i], _ = rand.Int(rand.Reader, N)
+	}
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		if batch {
+			BatchModInverse(values, N)
+		} else {
+			for _, v := range values {
+				new(big.Int).ModInverse(v, N)
+			}
+		}
+	}
+}
+
+func BenchmarkBatchModInverse10(b *testing.B)   { benchmarkModInverse(b, 10, true) }
+func BenchmarkBatchModInverse1000(b *testing.B) { benchmarkModInverse(b, 1000, true) }
+func BenchmarkModInverse10(b *testing.B)        { benchmarkModInverse(b, 10, false) }
+func BenchmarkModInverse1000(b *testing.B)      { benchmarkModInverse(b, 1000, false) }
+
 func BenchmarkOpen(b *testing.B) {
 	value, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
 	randVal, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
@@ -301,3 +2423,158 @@ func BenchmarkOpen(b *testing.B) {
 		Open(TestCurve, value, randVal, CM)
 	}
 }
+
+// seededReader is a deterministic io.Reader that returns the blocks
+// SHA-256(seed || counter), it lets the WithRand functions generate the same
+// proof every time
+type seededReader struct {
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+func newSeededReader(seed string) *seededReader {
+	return &seededReader{seed: []byte(seed)}
+}
+
+func (r *seededReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.buf) == 0 {
+			var ctr [8]byte
+			binary.BigEndian.PutUint64(ctr[:], r.counter)
+			r.counter++
+			block := sha256.Sum256(append(append([]byte{}, r.seed...), ctr[:]...))
+			r.buf = block[:]
+		}
+		c := copy(p[n:], r.buf)
+		r.buf = r.buf[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// TestKnownAnswers generates every proof from a fixed seed and compares the
+// SHA-256 of its serialization with a known answer, so any change to the
+// challenges, responses or encodings shows up here. If a change is intended
+// the new answers are printed by the failing test.
+func TestKnownAnswers(t *testing.T) {
+	sk := big.NewInt(123456789)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(1000)
+	r := big.NewInt(987654321)
+	CM := PedCommitR(TestCurve, value, r)
+	CMTok := TestCurve.Mult(PK, r)
+	value2 := big.NewInt(1001)
+	r2 := big.NewInt(192837465)
+	CM2 := PedCommitR(TestCurve, value2, r2)
+	A := TestCurve.Mult(TestCurve.G, value)
+
+	cases := []struct {
+		name   string
+		expect string // the hash with LegacyChallenges off
+		narrow string // with NarrowChallenges
+		legacy string // and with LegacyChallenges
+		gen    func(rnd io.Reader) ([]byte, error)
+	}{
+		{"ABCProof", "e106caca23432deb8565d09e698a8a6655b6906c071358dcacb332ed0fc94704", "ec24cec7e3b97bfb853c22d931f29da36bafc8191b36e9f43b4e6b0178eb5147", "518d1dccd59ac3015f835f43b166eca0de38b837fc153afdef60d6714b6b16d9", func(rnd io.Reader) ([]byte, error) {
+			p, err := NewABCProofWithRand(TestCurve, rnd, CM, CMTok, value, sk, Right)
+			if err != nil {
+				return nil, err
+			}
+			return p.Bytes(), nil
+		}},
+		{"DisjunctiveProof", "ede8a81a535cb2223b027cce1ed77042ced14dd426ffe0b9dda2597fd215260d", "23e025d604639d5fa5904bed534042ac6b431e82dd120a6db6014c2b68646098", "7100201ffbb3f49d4f692663d8c4d3be6a49ba31666541d7de45ab8a3be42303", func(rnd io.Reader) ([]byte, error) {
+			p, err := NewDisjunctiveProofWithRand(TestCurve, rnd, TestCurve.G, A, TestCurve.H, CM, value, Left)
+			if err != nil {
+				return nil, err
+			}
+			return p.Bytes(), nil
+		}},
+		{"EquivalenceProof", "2da9ded399a322d9453369bdf4276bad0b2297517d4783be70dd9abf06f77dd3", "368803de91ed8a2511c826ef3f92ee8027c117d5917fbc6f106185e6341e7fb9", "cba9809a5227dd948f0f6e07180d35ab3df0f177cdc0998ffbea298a18ea1bf7", func(rnd io.Reader) ([]byte, error) {
+			p, err := NewEquivalenceProofWithRand(TestCurve, rnd, TestCurve.G, A, TestCurve.H, TestCurve.Mult(TestCurve.H, value), value)
+			if err != nil {
+				return nil, err
+			}
+			return p.Bytes(), nil
+		}},
+		{"ConsistencyProof", "e2a9c584167d63bd78323c875f5bf69c4b89f2167bd5dd559e909f9cbc82c7ca", "898516865eee19cec1311b9809efa8ee8567d1d3fd75f434c82d64177d358e20", "fbd53461c29884f6c2f70b4d951b4f675b3c00483a732e5027e8a21a37f02308", func(rnd io.Reader) ([]byte, error) {
+			p, err := NewConsistencyProofWithRand(TestCurve, rnd, CM, CMTok, PK, value, r)
+			if err != nil {
+				return nil, err
+			}
+			return p.Bytes(), nil
+		}},
+		{"GSPFSProof", "5511f4a3d4dbdb68bac951f10f3e6ff7b10f610617bba1c43e387e7a1881c414", "0e4058304324a8564f8d3a291a18f609b3c1609456ba39666d378af79254ec4a", "300cabb88593777c52cd0d2b576de931b2276dde68ab1b156f8488b431b6f509", func(rnd io.Reader) ([]byte, error) {
+			p, err := NewGSPFSProofWithRand(TestCurve, rnd, A, value)
+			if err != nil {
+				return nil, err
+			}
+			return p.Bytes(), nil
+		}},
+		{"InequalityProof", "080daf3aacec35a234780f12034fd4f572bfb5a676543a8ab7864a0dcc3f9163", "7de4f116d88451306f05b3bfc0bf5077335a206289c468d14c9438f7de6d6315", "0d88c1b955f5d01cbd360a8f3b33a0d6d5c4c318c87be500383689462f9db3cf", func(rnd io.Reader) ([]byte, error) {
+			p, err := NewInequalityProofWithRand(TestCurve, rnd, CM, CM2, value, value2, r, r2)
+			if err != nil {
+				return nil, err
+			}
+			return p.Bytes(), nil
+		}},
+		{"BitRangeProof", "e84b069e5104cc68428c9837980b3ecaf2c7a8e0267dbba4d6c952e75b200e48", "fc2dbd2c5dbc9f966cb12b1327c5ad654f54ea69c3b95639d2edca807429440f", "de00f9db0a69535d2f009c3f6cf50df2912441b2444544f6f356c3deef44fbf6", func(rnd io.Reader) ([]byte, error) {
+			p, err := NewBitRangeProofWithRand(TestCurve, rnd, CM, value, r, 16)
+			if err != nil {
+				return nil, err
+			}
+			return p.Bytes(), nil
+		}},
+		{"RangeProof", "6ca3e73f948142240dce4c79935b5aa21b19444a2594fa96896cebff32df9cf3", "fd27f533b0d5d629874c50a6f0c4c65d7fd89e4bbca2c27c395bb1932b522989", "9b79216a182589ab900d5252d7bedb06a340b6a48acc06a344367ca074100e65", func(rnd io.Reader) ([]byte, error) {
+			p, _, err := NewRangeProofWithRand(TestCurve, rnd, value)
+			if err != nil {
+				return nil, err
+			}
+			return p.Bytes(), nil
+		}},
+	}
+
+	defer func(old bool) { LegacyChallenges = old }(LegacyChallenges)
+	for _, c := range cases {
+		LegacyChallenges = false
+		b, err := c.gen(newSeededReader(c.name))
+		if err != nil {
+			t.Fatalf("%s failed to generate: %v\n", c.name, err)
+		}
+		b2, err := c.gen(newSeededReader(c.name))
+		if err != nil {
+			t.Fatalf("%s failed to generate: %v\n", c.name, err)
+		}
+		if !bytes.Equal(b, b2) {
+			t.Fatalf("%s is not deterministic for a fixed seed\n", c.name)
+		}
+		sum := sha256.Sum256(b)
+		if got := hex.EncodeToString(sum[:]); got != c.expect {
+			t.Errorf("%s known answer mismatch: got %s, expected %s\n", c.name, got, c.expect)
+		}
+
+		// proofs from before GenerateChallengeModN still come out the same
+		TestCurve.NarrowChallenges = true
+		b, err = c.gen(newSeededReader(c.name))
+		TestCurve.NarrowChallenges = false
+		if err != nil {
+			t.Fatalf("%s failed to generate with NarrowChallenges: %v\n", c.name, err)
+		}
+		sum = sha256.Sum256(b)
+		if got := hex.EncodeToString(sum[:]); got != c.narrow {
+			t.Errorf("%s narrow known answer mismatch: got %s, expected %s\n", c.name, got, c.narrow)
+		}
+
+		// proofs from before Transcript still come out the same
+		LegacyChallenges = true
+		b, err = c.gen(newSeededReader(c.name))
+		if err != nil {
+			t.Fatalf("%s failed to generate with LegacyChallenges: %v\n", c.name, err)
+		}
+		sum = sha256.Sum256(b)
+		if got := hex.EncodeToString(sum[:]); got != c.legacy {
+			t.Errorf("%s legacy known answer mismatch: got %s, expected %s\n", c.name, got, c.legacy)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// DecryptionProof is a proof by the auditor with secret key sk and PK = skH
+// that it decrypted a commitment CM = vG + rH with token CMTok = rPK correctly.
+// The auditor unblinds CM to V = CM - inv(sk)CMTok = vG and proves with a
+// Chaum-Pedersen proof that the same sk gives PK = skH and CMTok = skR for
+// R = CM - V. Anyone knowing CM, CMTok and PK can then check that V is the
+// value of CM. V only reveals v to someone who can find the discrete log of
+// vG, e.g. by trying all small values.
+//
+//  Public: generator points G and H, CM, CMTok, PK, V
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows sk with PK = skH
+//  V = CM - inv(sk)CMTok               R = CM - V
+//  selects random u
+//  T1 = uH
+//  T2 = uR
+//  c = HASH(G, H, CM, CMTok, PK, V, T1, T2)
+//  s = u + c * sk
+//
+//  T1, T2, c, s ---------------------->
+//                                      c ?= HASH(G, H, CM, CMTok, PK, V, T1, T2)
+//                                      sH ?= T1 + cPK
+//                                      sR ?= T2 + cCMTok
+type DecryptionProof struct {
+	T1        ECPoint  // T1 = uH
+	T2        ECPoint  // T2 = u(CM - V)
+	Challenge *big.Int // c = HASH(G, H, CM, CMTok, PK, V, T1, T2)
+	S         *big.Int // s = u + c * sk
+}
+
+// decryptionChallenge computes c = HASH(G, H, CM, CMTok, PK, V, T1, T2)
+func decryptionChallenge(zkpcp ZKPCurveParams, CM, CMTok, PK, V, T1, T2 ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "DecryptionProof")
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendPoint("CM", CM)
+	t.AppendPoint("CMTok", CMTok)
+	t.AppendPoint("PK", PK)
+	t.AppendPoint("V", V)
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	return t.ChallengeScalar("c")
+}
+
+// NewDecryptionProof decrypts CM with its token CMTok and the auditor secret
+// key sk. It returns a proof that the recovered V = vG is the value of CM
+// together with V. A zero sk is rejected with ErrValueOutOfRange.
+func NewDecryptionProof(zkpcp ZKPCurveParams, CM, CMTok ECPoint, sk *big.Int) (*DecryptionProof, ECPoint, error) {
+	return NewDecryptionProofWithRand(zkpcp, entropy(), CM, CMTok, sk)
+}
+
+// NewDecryptionProofWithRand is the same as NewDecryptionProof, but reads its
+// random value from rnd instead of the EntropySource
+func NewDecryptionProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	CM, CMTok ECPoint, sk *big.Int) (*DecryptionProof, ECPoint, error) {
+
+	N := zkpcp.C.Params().N
+
+	if err := zkpcp.checkScalars("DecryptionProve", "sk", sk); err != nil {
+		return nil, Zero, err
+	}
+	if sk.Sign() == 0 {
+		return nil, Zero, rangeError("DecryptionProve", "sk is zero")
+	}
+	if !zkpcp.validPoints(CM, CMTok) {
+		return nil, Zero, curveError("DecryptionProve")
+	}
+
+	// R = inv(sk)CMTok = rH, V = CM - R = vG
+	R := zkpcp.Mult(CMTok, new(big.Int).ModInverse(sk, N))
+	V := zkpcp.Sub(CM, R)
+	PK := zkpcp.Mult(zkpcp.H, sk)
+
+	u, err := zkpcp.randScalar(rnd)
+	if err != nil {
+		return nil, Zero, err
+	}
+
+	T1 := zkpcp.Mult(zkpcp.H, u)
+	T2 := zkpcp.Mult(R, u)
+	c := decryptionChallenge(zkpcp, CM, CMTok, PK, V, T1, T2)
+
+	s := new(big.Int).Add(u, new(big.Int).Mul(c, sk))
+	s.Mod(s, N)
+
+	return &DecryptionProof{T1, T2, c, s}, V, nil
+}
+
+// Verify checks if DecryptionProof proof shows that the auditor with public
+// key PK decrypted CM with token CMTok to claimedValueG = vG. PK must not be
+// Zero.
+func (proof *DecryptionProof) Verify(zkpcp ZKPCurveParams, CM, CMTok, PK, claimedValueG ECPoint) (bool, error) {
+	if proof == nil {
+		return false, malformedError("DecryptionProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(CM, CMTok, PK, claimedValueG) || !zkpcp.onCurve(proof.T1, proof.T2) {
+		return false, curveError("DecryptionProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("DecryptionProof.Verify", "Challenge, S", proof.Challenge, proof.S); err != nil {
+		return false, err
+	}
+	// with PK = Zero, CMTok = Zero is 0R for any claimedValueG
+	if PK.Equal(Zero) {
+		return false, inputError("DecryptionProof.Verify", "PK must not be Zero")
+	}
+
+	c := decryptionChallenge(zkpcp, CM, CMTok, PK, claimedValueG, proof.T1, proof.T2)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("DecryptionProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	negC := new(big.Int).Neg(c)
+
+	// sH ?= T1 + cPK, checked as sH - cPK ?= T1
+	lhs1 := zkpcp.MultiMult([]ECPoint{zkpcp.H, PK}, []*big.Int{proof.S, negC})
+	if !pointsEqual(lhs1, proof.T1) {
+		return false, equationError("DecryptionProof.Verify", 1, "sH - cPK != T1", lhs1, proof.T1)
+	}
+
+	// sR ?= T2 + cCMTok with R = CM - V, checked as sR - cCMTok ?= T2
+	R := zkpcp.Sub(CM, claimedValueG)
+	lhs2 := zkpcp.MultiMult([]ECPoint{R, CMTok}, []*big.Int{proof.S, negC})
+	if !pointsEqual(lhs2, proof.T2) {
+		return false, equationError("DecryptionProof.Verify", 2, "sR - cCMTok != T2", lhs2, proof.T2)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are CM, CMTok, PK and claimedValueG
+// like for Verify
+func (proof *DecryptionProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("DecryptionProof.VerifyArgs", args)
+	CM, CMTok, PK, claimedValueG := a.point(), a.point(), a.point(), a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CM, CMTok, PK, claimedValueG)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *DecryptionProof) String() string {
+	if proof == nil {
+		return "DecryptionProof(nil)"
+	}
+	return proofString("DecryptionProof", "T1", proof.T1, "T2", proof.T2,
+		"Challenge", proof.Challenge, "S", proof.S)
+}
+
+// decryptionProofLen is the length of a serialized DecryptionProof
+var decryptionProofLen = 1 + 2*fixedPointLen + 2*fixedScalarLen
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// DecryptionProof proof
+func (proof *DecryptionProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.T1)
+	writeFixedPoint(&buf, proof.T2)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.S)
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of DecryptionProof proof, which has a fixed size
+func (proof *DecryptionProof) Size() int {
+	return decryptionProofLen
+}
+
+// Clone returns a deep copy of DecryptionProof proof that shares no big.Int with it
+func (proof *DecryptionProof) Clone() *DecryptionProof {
+	if proof == nil {
+		return nil
+	}
+	return &DecryptionProof{
+		T1:        clonePoint(proof.T1),
+		T2:        clonePoint(proof.T2),
+		Challenge: cloneScalar(proof.Challenge),
+		S:         cloneScalar(proof.S),
+	}
+}
+
+// Equal returns true if DecryptionProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *DecryptionProof) Equal(other *DecryptionProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.T1, other.T1) &&
+		samePoint(proof.T2, other.T2) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.S, other.S)
+}
+
+// NewDecryptionProofFromBytes returns a DecryptionProof generated from the
+// deserialization of byte slice b
+func NewDecryptionProofFromBytes(b []byte) (*DecryptionProof, error) {
+	if err := checkFixedHeader(b, decryptionProofLen, "DecryptionProofFromBytes"); err != nil {
+		return nil, err
+	}
+	proof := new(DecryptionProof)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.T1, err = readFixedPoint(buf, "DecryptionProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if proof.T2, err = readFixedPoint(buf, "DecryptionProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "DecryptionProofFromBytes", &proof.Challenge, &proof.S); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+type decryptionProofJSON struct {
+	T1        string `json:"t1"`
+	T2        string `json:"t2"`
+	Challenge string `json:"challenge"`
+	S         string `json:"s"`
+}
+
+// MarshalJSON encodes DecryptionProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *DecryptionProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(decryptionProofJSON{
+		T1:        pointToHex(proof.T1),
+		T2:        pointToHex(proof.T2),
+		Challenge: scalarToHex(proof.Challenge),
+		S:         scalarToHex(proof.S),
+	})
+}
+
+// UnmarshalJSON decodes a DecryptionProof encoded with MarshalJSON. It fails
+// if a point is not on the curve or a scalar is not in [0, N)
+func (proof *DecryptionProof) UnmarshalJSON(b []byte) error {
+	var aux decryptionProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := DecryptionProof{}
+	if p.T1, err = pointFromHex(aux.T1, "t1"); err != nil {
+		return err
+	}
+	if p.T2, err = pointFromHex(aux.T2, "t2"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.S, err = scalarFromHex(aux.S, "s"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
@@ -0,0 +1,128 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestDecryptionProof(t *testing.T) {
+	N := TestCurve.C.Params().N
+	sk, _ := rand.Int(rand.Reader, N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+
+	for _, value := range []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(1000),
+		new(big.Int).Sub(N, big.NewInt(1))} {
+		CM, r, err := PedCommit(TestCurve, value)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		CMTok := TestCurve.Mult(PK, r)
+
+		proof, V, err := NewDecryptionProof(TestCurve, CM, CMTok, sk)
+		if err != nil {
+			t.Fatalf("DecryptionProof failed to generate for %v: %v\n", value, err)
+		}
+		if !V.Equal(TestCurve.Mult(TestCurve.G, value)) {
+			t.Fatalf("DecryptionProof recovered %v instead of %vG\n", V, value)
+		}
+		if ok, err := proof.Verify(TestCurve, CM, CMTok, PK, V); !ok || err != nil {
+			t.Fatalf("DecryptionProof failed to verify for %v: %v\n", value, err)
+		}
+
+		// a claim of a different value
+		other := TestCurve.Add(V, TestCurve.G)
+		if ok, err := proof.Verify(TestCurve, CM, CMTok, PK, other); ok || err == nil {
+			t.Fatalf("DecryptionProof for %v verified for another value\n", value)
+		}
+	}
+}
+
+func TestDecryptionProofWrongKey(t *testing.T) {
+	N := TestCurve.C.Params().N
+	sk, _ := rand.Int(rand.Reader, N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(42)
+	CM, r, _ := PedCommit(TestCurve, value)
+	CMTok := TestCurve.Mult(PK, r)
+
+	// a wrong key decrypts to something else and its proof is for its own
+	// public key, not for PK
+	sk2, _ := rand.Int(rand.Reader, N)
+	proof, V, err := NewDecryptionProof(TestCurve, CM, CMTok, sk2)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if V.Equal(TestCurve.Mult(TestCurve.G, value)) {
+		t.Fatalf("DecryptionProof recovered the value with the wrong sk\n")
+	}
+	if ok, err := proof.Verify(TestCurve, CM, CMTok, PK, V); ok || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("DecryptionProof with the wrong sk verified for PK: %v\n", err)
+	}
+	PK2 := TestCurve.Mult(TestCurve.H, sk2)
+	if ok, err := proof.Verify(TestCurve, CM, CMTok, PK2, V); !ok || err != nil {
+		t.Fatalf("DecryptionProof did not verify for the key it was made with: %v\n", err)
+	}
+	if ok, _ := proof.Verify(TestCurve, CM, CMTok, PK2, TestCurve.Mult(TestCurve.G, value)); ok {
+		t.Fatalf("DecryptionProof with the wrong sk verified for the real value\n")
+	}
+
+	// the right value claimed with a proof for a different token
+	proof, V, _ = NewDecryptionProof(TestCurve, CM, CMTok, sk)
+	if ok, _ := proof.Verify(TestCurve, CM, TestCurve.Add(CMTok, PK), PK, V); ok {
+		t.Fatalf("DecryptionProof verified for a different token\n")
+	}
+
+	tampered := *proof
+	tampered.S = new(big.Int).Add(proof.S, big.NewInt(1))
+	if ok, err := tampered.Verify(TestCurve, CM, CMTok, PK, V); ok || !errors.Is(err, ErrEquationFailed) {
+		t.Fatalf("DecryptionProof with a tampered response gives %v\n", err)
+	}
+}
+
+func TestDecryptionProofZeroKey(t *testing.T) {
+	CM, r, _ := PedCommit(TestCurve, big.NewInt(42))
+	if _, _, err := NewDecryptionProof(TestCurve, CM, Zero, big.NewInt(0)); !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("DecryptionProof with a zero sk gives %v, expected ErrValueOutOfRange\n", err)
+	}
+
+	// with PK = Zero the token is Zero and says nothing about CM
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	proof, V, err := NewDecryptionProof(TestCurve, CM, TestCurve.Mult(PK, r), sk)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CM, Zero, Zero, V); ok || !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("DecryptionProof verified for PK = Zero: %v\n", err)
+	}
+
+	var nilProof *DecryptionProof
+	if ok, err := nilProof.Verify(TestCurve, CM, Zero, PK, V); ok || !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("nil DecryptionProof verified: %v\n", err)
+	}
+}
+
+func BenchmarkDecryptionProve(b *testing.B) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	CM, r, _ := PedCommit(TestCurve, big.NewInt(5))
+	CMTok := TestCurve.Mult(PK, r)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewDecryptionProof(TestCurve, CM, CMTok, sk)
+	}
+}
+
+func BenchmarkDecryptionVerify(b *testing.B) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	CM, r, _ := PedCommit(TestCurve, big.NewInt(5))
+	CMTok := TestCurve.Mult(PK, r)
+	proof, V, _ := NewDecryptionProof(TestCurve, CM, CMTok, sk)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CM, CMTok, PK, V)
+	}
+}
@@ -2,8 +2,9 @@ package zksigma
 
 import (
 	"bytes"
-	"crypto/rand"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/big"
 )
 
@@ -52,13 +53,114 @@ type DisjunctiveProof struct {
 	S2 *big.Int
 }
 
+// disjunctiveChallenge computes c = HASH(msg, Base1, Result1, Base2, Result2, T1, T2)
+func disjunctiveChallenge(zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2, T1, T2 ECPoint, msg []byte) *big.Int {
+	return disjunctiveTranscript(zkpcp, Base1, Result1, Base2, Result2, T1, T2, msg, nil).ChallengeScalar("c")
+}
+
+// disjunctiveTranscript returns the transcript the challenge of a
+// DisjunctiveProof is computed from
+func disjunctiveTranscript(zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2, T1, T2 ECPoint, msg []byte, tr *VerifyTrace) *Transcript {
+	t := tr.transcript(zkpcp, "DisjunctiveProof")
+	t.bindMessage(msg)
+	t.AppendPoint("Base1", Base1)
+	t.AppendPoint("Result1", Result1)
+	t.AppendPoint("Base2", Base2)
+	t.AppendPoint("Result2", Result2)
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	return t
+}
+
 // NewDisjunctiveProof generates a disjunctive proof. Base1 and Base2 are our chosen base points.
 // Result1 is Base1 multiplied by x or y, and Result2 is Base2 multiplied by x or y. x is the value to
 // prove, if option is Left, we use Base1 and Result1 - if option is Right we use Base2 and Result2. The
-// verifier will not learn what side is being proved and should not be able to tell.
+// verifier will not learn what side is being proved and should not be able to tell. If x is a witness
+// for the other side only it fails with ErrInvalidSide.
 func NewDisjunctiveProof(
 	zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint, x *big.Int, option Side) (*DisjunctiveProof, error) {
+	return NewDisjunctiveProofWithRand(zkpcp, entropy(), Base1, Result1, Base2, Result2, x, option)
+}
+
+// NewDisjunctiveProofWithRand is the same as NewDisjunctiveProof, but reads its random
+// values from rnd instead of the EntropySource
+func NewDisjunctiveProofWithRand(
+	zkpcp ZKPCurveParams, rnd io.Reader, Base1, Result1, Base2, Result2 ECPoint, x *big.Int, option Side) (*DisjunctiveProof, error) {
+	return newDisjunctiveProof(zkpcp, rnd, Base1, Result1, Base2, Result2, x, option, nil)
+}
+
+// NewDisjunctiveProofWithMessage is the same as NewDisjunctiveProof, but binds
+// the proof to the application message msg like NewABCProofWithMessage. It only
+// verifies with VerifyWithMessage and the same msg.
+func NewDisjunctiveProofWithMessage(zkpcp ZKPCurveParams,
+	Base1, Result1, Base2, Result2 ECPoint, x *big.Int, option Side, msg []byte) (*DisjunctiveProof, error) {
+	return newDisjunctiveProof(zkpcp, entropy(), Base1, Result1, Base2, Result2, x, option, msg)
+}
+
+// newDisjunctiveProof generates a DisjunctiveProof bound to msg, which may be
+// nil. It runs the DisjunctiveProver of the interactive protocol with the
+// challenge derived from its commitment.
+func newDisjunctiveProof(zkpcp ZKPCurveParams, rnd io.Reader,
+	Base1, Result1, Base2, Result2 ECPoint, x *big.Int, option Side, msg []byte) (*DisjunctiveProof, error) {
+
+	prover, err := NewDisjunctiveProverWithRand(zkpcp, rnd, Base1, Result1, Base2, Result2, x, option)
+	if err != nil {
+		return nil, err
+	}
 
+	commit := prover.Commit()
+	Challenge := disjunctiveChallenge(zkpcp, Base1, Result1, Base2, Result2, commit.T1, commit.T2, msg)
+	resp, err := prover.Respond(Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DisjunctiveProof{commit.T1, commit.T2, Challenge, resp.C1, resp.C2, resp.S1, resp.S2}, nil
+}
+
+// NewDisjunctiveProofAuto is the same as NewDisjunctiveProof, but picks the
+// side with DisjunctiveSide
+func NewDisjunctiveProofAuto(zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint, x *big.Int) (*DisjunctiveProof, error) {
+	option, err := DisjunctiveSide(zkpcp, Base1, Result1, Base2, Result2, x)
+	if err != nil {
+		return nil, err
+	}
+	return NewDisjunctiveProof(zkpcp, Base1, Result1, Base2, Result2, x, option)
+}
+
+// DisjunctiveSide returns the side x is a witness for, Left if Result1 = xBase1
+// and Right if Result2 = xBase2. Left wins if x is a witness for both. It fails
+// with an error matching ErrInvalidSide if x is a witness for neither.
+func DisjunctiveSide(zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint, x *big.Int) (Side, error) {
+	if err := zkpcp.checkScalars("DisjunctiveSide", "x", x); err != nil {
+		return Left, err
+	}
+	if zkpcp.Mult(Base1, x).Equal(Result1) {
+		return Left, nil
+	}
+	if zkpcp.Mult(Base2, x).Equal(Result2) {
+		return Right, nil
+	}
+	return Left, sideError("DisjunctiveSide", "x relates neither Base and Result")
+}
+
+// disjunctiveProver is a DisjunctiveProof between the commit and the response
+// phase, it keeps the witness and the random values around
+type disjunctiveProver struct {
+	option     Side
+	T1, T2     ECPoint // T1 for the proved side, T2 for the simulated one
+	x          *big.Int
+	u1, u2, u3 *big.Int
+}
+
+// disjunctiveCommit checks x and computes the commitments T1 and T2 of a
+// DisjunctiveProof
+func disjunctiveCommit(
+	zkpcp ZKPCurveParams, rnd io.Reader, Base1, Result1, Base2, Result2 ECPoint, x *big.Int, option Side) (*disjunctiveProver, error) {
+
+	if err := zkpcp.checkScalars("DisjunctiveProve", "x", x); err != nil {
+		return nil, err
+	}
 	modValue := new(big.Int).Mod(x, zkpcp.C.Params().N)
 
 	// Declaring them like this because Golang crys otherwise
@@ -76,24 +178,20 @@ func NewDisjunctiveProof(
 		OtherBase = Base1
 		OtherResult = Result1
 	} else { // number for option is not correct
-		return &DisjunctiveProof{}, &errorProof{"DisjunctiveProve", "invalid side provided"}
+		return nil, sideError("DisjunctiveProve", "invalid side provided")
 	}
 
 	if !zkpcp.Mult(ProveBase, x).Equal(ProveResult) {
-		return &DisjunctiveProof{}, &errorProof{"DisjunctiveProve", "Base and Result to be proved not related by x"}
-	}
-	u1, err := rand.Int(rand.Reader, zkpcp.C.Params().N)
-	if err != nil {
-		return nil, err
-	}
-	u2, err := rand.Int(rand.Reader, zkpcp.C.Params().N)
-	if err != nil {
-		return nil, err
+		if zkpcp.Mult(OtherBase, x).Equal(OtherResult) {
+			return nil, sideError("DisjunctiveProve", "x relates the Base and Result of the other side")
+		}
+		return nil, inputError("DisjunctiveProve", "Base and Result to be proved not related by x")
 	}
-	u3, err := rand.Int(rand.Reader, zkpcp.C.Params().N)
+	us, err := zkpcp.randScalars(rnd, 3)
 	if err != nil {
 		return nil, err
 	}
+	u1, u2, u3 := us[0], us[1], us[2]
 
 	// for (-u3)yH
 	u3Neg := new(big.Int).Neg(u3)
@@ -109,56 +207,122 @@ func NewDisjunctiveProof(
 	// T2 = u2H + (-u3)yH (yH is OtherResult)
 	T2 := zkpcp.Add(temp, temp2)
 
-	var Challenge *big.Int
-	if option == 0 {
-		// String for proving Base1 and Result1
-		Challenge = GenerateChallenge(zkpcp, Base1.Bytes(), Result1.Bytes(),
-			Base2.Bytes(), Result2.Bytes(),
-			T1.Bytes(), T2.Bytes())
-	} else {
+	return &disjunctiveProver{option, T1, T2, modValue, u1, u2, u3}, nil
+}
 
-		// If we are proving Base2 and Result2 then we must switch T1 and
-		// T2 in this string, look at mapping in proof for clarification
-		Challenge = GenerateChallenge(zkpcp, Base1.Bytes(), Result1.Bytes(),
-			Base2.Bytes(), Result2.Bytes(),
-			T2.Bytes(), T1.Bytes()) //T2 and T1 SWAPPED!
+// commitments returns T1 and T2 in the order they appear in the proof
+func (prover *disjunctiveProver) commitments() (ECPoint, ECPoint) {
+	if prover.option == Left {
+		return prover.T1, prover.T2
 	}
+	// If we are proving Base2 and Result2 then we must switch T1 and
+	// T2, look at mapping in proof for clarification
+	return prover.T2, prover.T1
+}
+
+// respond finishes the DisjunctiveProof for challenge c
+func (prover *disjunctiveProver) respond(zkpcp ZKPCurveParams, Challenge *big.Int) *DisjunctiveProof {
+	u2, u3 := prover.u2, prover.u3
 
 	deltaC := new(big.Int).Sub(Challenge, u3)
 	deltaC.Mod(deltaC, zkpcp.C.Params().N)
 
-	s := new(big.Int).Add(u1, new(big.Int).Mul(deltaC, modValue))
+	s := new(big.Int).Add(prover.u1, new(big.Int).Mul(deltaC, prover.x))
+	s.Mod(s, zkpcp.C.Params().N)
 
 	// Look at mapping given in block comment above
-	if option == Left {
+	if prover.option == Left {
 		return &DisjunctiveProof{
-			T1,
-			T2,
+			prover.T1,
+			prover.T2,
 			Challenge,
 			deltaC,
 			u3,
 			s,
-			u2}, nil
+			u2}
 	}
 
 	return &DisjunctiveProof{
-		T2,
-		T1,
+		prover.T2,
+		prover.T1,
 		Challenge,
 		u3,
 		deltaC,
 		u2,
-		s}, nil
+		s}
 }
 
 // Verify checks if DisjunctiveProof djProof is valid for the given bases and results
 func (djProof *DisjunctiveProof) Verify(
 	zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint) (bool, error) {
+	return djProof.verify(zkpcp, Base1, Result1, Base2, Result2, nil, nil, nil)
+}
+
+// VerifyWithTrace is the same as Verify and also returns a VerifyTrace of the
+// verification, which is returned if the proof does not verify too
+func (djProof *DisjunctiveProof) VerifyWithTrace(
+	zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint) (*VerifyTrace, error) {
+	tr := newTrace("DisjunctiveProof")
+	_, err := djProof.verify(zkpcp, Base1, Result1, Base2, Result2, nil, nil, tr)
+	tr.result(err)
+	return tr, err
+}
+
+// VerifyWithMessage is the same as Verify for a DisjunctiveProof generated by
+// NewDisjunctiveProofWithMessage, which only verifies for the same msg
+func (djProof *DisjunctiveProof) VerifyWithMessage(
+	zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint, msg []byte) (bool, error) {
+	return djProof.verify(zkpcp, Base1, Result1, Base2, Result2, msg, nil, nil)
+}
+
+// VerifyArgs implements Verifiable, args are Base1, Result1, Base2 and Result2
+// like for Verify
+func (djProof *DisjunctiveProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("DisjunctiveProof.VerifyArgs", args)
+	Base1, Result1, Base2, Result2 := a.point(), a.point(), a.point(), a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return djProof.Verify(zkpcp, Base1, Result1, Base2, Result2)
+}
+
+// verify is Verify for the proof bound to msg with the challenge computed by
+// session s, recorded in trace tr, all of which may be nil
+func (djProof *DisjunctiveProof) verify(
+	zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint, msg []byte, s *VerifierSession, tr *VerifyTrace) (bool, error) {
 
 	if djProof == nil {
-		return false, &errorProof{"DisjunctiveProof.Verify", fmt.Sprintf("passed proof is nil")}
+		return false, malformedError("DisjunctiveProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(Base1, Result1, Base2, Result2) || !zkpcp.onCurve(djProof.T1, djProof.T2) {
+		return false, curveError("DisjunctiveProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("DisjunctiveProof.Verify", "C, C1, C2, S1, S2", djProof.C, djProof.C1, djProof.C2, djProof.S1, djProof.S2); err != nil {
+		return false, err
+	}
+	tr.points("Base1, Result1, Base2, Result2, T1, T2", Base1, Result1, Base2, Result2, djProof.T1, djProof.T2)
+	tr.scalars("C, C1, C2, S1, S2", djProof.C, djProof.C1, djProof.C2, djProof.S1, djProof.S2)
+
+	checkC := s.disjunctiveChallenge(zkpcp, Base1, Result1, Base2, Result2, djProof.T1, djProof.T2, msg, tr)
+
+	if !scalarsEqual(checkC, djProof.C) {
+		return false, challengeError("DisjunctiveVerify", "checkC does not agree with proofC", checkC, djProof.C)
 	}
 
+	if err := djProof.verifyResponses(zkpcp, Base1, Result1, Base2, Result2, tr); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// verifyResponses checks that the challenges of djProof add up to its
+// challenge and the verification equations, the challenge itself has to be
+// checked by the caller. The equations are recorded in tr if it is not nil.
+func (djProof *DisjunctiveProof) verifyResponses(
+	zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint, tr *VerifyTrace) error {
+
 	T1 := djProof.T1
 	T2 := djProof.T2
 	C := djProof.C
@@ -167,68 +331,171 @@ func (djProof *DisjunctiveProof) Verify(
 	S1 := djProof.S1
 	S2 := djProof.S2
 
-	checkC := GenerateChallenge(zkpcp, Base1.Bytes(), Result1.Bytes(),
-		Base2.Bytes(), Result2.Bytes(),
-		T1.Bytes(), T2.Bytes())
-
-	if checkC.Cmp(C) != 0 {
-		return false, &errorProof{"DisjunctiveVerify", "checkC does not agree with proofC"}
+	if C1 == nil || C2 == nil || S1 == nil || S2 == nil {
+		return malformedError("DisjunctiveVerify", "proof is missing a scalar")
 	}
 
 	// C1 + C2
 	totalC := new(big.Int).Add(C1, C2)
 	totalC.Mod(totalC, zkpcp.C.Params().N)
-	if totalC.Cmp(C) != 0 {
-		return false, &errorProof{"DisjunctiveVerify", "totalC does not agree with proofC"}
+	tr.scalars("C1 + C2", totalC)
+	if !scalarsEqual(totalC, C) {
+		return challengeError("DisjunctiveVerify", "totalC does not agree with proofC", totalC, C)
 	}
 
-	// T1 + c1A
-	c1A := zkpcp.Mult(Result1, C1)
-	checks1G := zkpcp.Add(T1, c1A)
-	s1G := zkpcp.Mult(Base1, S1)
+	// T1 + c1A ?= s1G, checked as s1G - c1A ?= T1
+	s1G := zkpcp.MultiMult([]ECPoint{Base1, Result1}, []*big.Int{S1, new(big.Int).Neg(C1)})
 
-	if !checks1G.Equal(s1G) {
-		return false, &errorProof{"DisjunctiveVerify", "s1G not equal to T1 + c1A"}
+	if err := tr.equation("DisjunctiveVerify", 1, "s1G - c1A != T1", s1G, T1); err != nil {
+		return err
 	}
 
-	// T2 + c2B
-	c2A := zkpcp.Mult(Result2, C2)
-	checks2G := zkpcp.Add(c2A, T2)
-	s2G := zkpcp.Mult(Base2, S2)
+	// T2 + c2B ?= s2G, checked as s2G - c2B ?= T2
+	s2G := zkpcp.MultiMult([]ECPoint{Base2, Result2}, []*big.Int{S2, new(big.Int).Neg(C2)})
 
-	if !checks2G.Equal(s2G) {
-		return false, &errorProof{"DisjunctiveVerify", "s2G not equal to T2 + c2B"}
-	}
+	return tr.equation("DisjunctiveVerify", 2, "s2G - c2B != T2", s2G, T2)
+}
 
-	return true, nil
+// disjunctiveProofLen is the length of a serialized DisjunctiveProof
+var disjunctiveProofLen = 1 + 2*fixedPointLen + 5*fixedScalarLen
+
+// String returns a labeled dump of the fields of djProof for debugging
+func (djProof *DisjunctiveProof) String() string {
+	if djProof == nil {
+		return "DisjunctiveProof(nil)"
+	}
+	return proofString("DisjunctiveProof", "T1", djProof.T1, "T2", djProof.T2, "C", djProof.C,
+		"C1", djProof.C1, "C2", djProof.C2, "S1", djProof.S1, "S2", djProof.S2)
 }
 
-// Bytes returns a byte slice with a serialized representation of DisjunctiveProof proof
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// DisjunctiveProof proof
 func (djProof *DisjunctiveProof) Bytes() []byte {
 	var buf bytes.Buffer
 
-	WriteECPoint(&buf, djProof.T1)
-	WriteECPoint(&buf, djProof.T2)
-	WriteBigInt(&buf, djProof.C)
-	WriteBigInt(&buf, djProof.C1)
-	WriteBigInt(&buf, djProof.C2)
-	WriteBigInt(&buf, djProof.S1)
-	WriteBigInt(&buf, djProof.S2)
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, djProof.T1)
+	writeFixedPoint(&buf, djProof.T2)
+	writeFixedScalar(&buf, djProof.C)
+	writeFixedScalar(&buf, djProof.C1)
+	writeFixedScalar(&buf, djProof.C2)
+	writeFixedScalar(&buf, djProof.S1)
+	writeFixedScalar(&buf, djProof.S2)
 
 	return buf.Bytes()
 }
 
+// Size returns the length of Bytes of DisjunctiveProof djProof, which has a fixed size
+func (djProof *DisjunctiveProof) Size() int {
+	return disjunctiveProofLen
+}
+
+// Clone returns a deep copy of DisjunctiveProof djProof that shares no big.Int with it
+func (djProof *DisjunctiveProof) Clone() *DisjunctiveProof {
+	if djProof == nil {
+		return nil
+	}
+	return &DisjunctiveProof{
+		T1: clonePoint(djProof.T1),
+		T2: clonePoint(djProof.T2),
+		C:  cloneScalar(djProof.C),
+		C1: cloneScalar(djProof.C1),
+		C2: cloneScalar(djProof.C2),
+		S1: cloneScalar(djProof.S1),
+		S2: cloneScalar(djProof.S2),
+	}
+}
+
+// Equal returns true if DisjunctiveProof djProof and other have the same values in all
+// their fields. It is not constant time.
+func (djProof *DisjunctiveProof) Equal(other *DisjunctiveProof) bool {
+	if djProof == nil || other == nil {
+		return djProof == other
+	}
+	return samePoint(djProof.T1, other.T1) &&
+		samePoint(djProof.T2, other.T2) &&
+		sameScalar(djProof.C, other.C) &&
+		sameScalar(djProof.C1, other.C1) &&
+		sameScalar(djProof.C2, other.C2) &&
+		sameScalar(djProof.S1, other.S1) &&
+		sameScalar(djProof.S2, other.S2)
+}
+
 // NewDisjunctiveProofFromBytes returns a DisjunctiveProof generated from the
 // deserialization of byte slice b
 func NewDisjunctiveProofFromBytes(b []byte) (*DisjunctiveProof, error) {
+	if err := checkFixedHeader(b, disjunctiveProofLen, "DisjunctiveProofFromBytes"); err != nil {
+		return nil, err
+	}
 	proof := new(DisjunctiveProof)
-	buf := bytes.NewBuffer(b)
-	proof.T1, _ = ReadECPoint(buf)
-	proof.T2, _ = ReadECPoint(buf)
-	proof.C, _ = ReadBigInt(buf)
-	proof.C1, _ = ReadBigInt(buf)
-	proof.C2, _ = ReadBigInt(buf)
-	proof.S1, _ = ReadBigInt(buf)
-	proof.S2, _ = ReadBigInt(buf)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.T1, err = readFixedPoint(buf, "DisjunctiveProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if proof.T2, err = readFixedPoint(buf, "DisjunctiveProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "DisjunctiveProofFromBytes", &proof.C, &proof.C1, &proof.C2, &proof.S1, &proof.S2); err != nil {
+		return nil, err
+	}
 	return proof, nil
 }
+
+type disjunctiveProofJSON struct {
+	T1 string `json:"t1"`
+	T2 string `json:"t2"`
+	C  string `json:"c"`
+	C1 string `json:"c1"`
+	C2 string `json:"c2"`
+	S1 string `json:"s1"`
+	S2 string `json:"s2"`
+}
+
+// MarshalJSON encodes DisjunctiveProof djProof with points as compressed hex
+// and scalars as big-endian hex
+func (djProof *DisjunctiveProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(disjunctiveProofJSON{
+		T1: pointToHex(djProof.T1),
+		T2: pointToHex(djProof.T2),
+		C:  scalarToHex(djProof.C),
+		C1: scalarToHex(djProof.C1),
+		C2: scalarToHex(djProof.C2),
+		S1: scalarToHex(djProof.S1),
+		S2: scalarToHex(djProof.S2),
+	})
+}
+
+// UnmarshalJSON decodes a DisjunctiveProof encoded with MarshalJSON. It fails
+// if a point is not on the curve or a scalar is not in [0, N)
+func (djProof *DisjunctiveProof) UnmarshalJSON(b []byte) error {
+	var aux disjunctiveProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	proof := DisjunctiveProof{}
+	if proof.T1, err = pointFromHex(aux.T1, "t1"); err != nil {
+		return err
+	}
+	if proof.T2, err = pointFromHex(aux.T2, "t2"); err != nil {
+		return err
+	}
+	if proof.C, err = scalarFromHex(aux.C, "c"); err != nil {
+		return err
+	}
+	if proof.C1, err = scalarFromHex(aux.C1, "c1"); err != nil {
+		return err
+	}
+	if proof.C2, err = scalarFromHex(aux.C2, "c2"); err != nil {
+		return err
+	}
+	if proof.S1, err = scalarFromHex(aux.S1, "s1"); err != nil {
+		return err
+	}
+	if proof.S2, err = scalarFromHex(aux.S2, "s2"); err != nil {
+		return err
+	}
+	*djProof = proof
+	return nil
+}
@@ -1,7 +1,9 @@
 package zksigma
 
 import (
+	"bytes"
 	"crypto/rand"
+	"errors"
 	"math/big"
 	"testing"
 )
@@ -19,14 +21,14 @@ func TestDisjunctive(t *testing.T) {
 	djProofLEFT, status1 := NewDisjunctiveProof(TestCurve, Base1, Result1, Base2, Result2, x, Left)
 
 	if status1 != nil {
-		proofStatus(status1.(*errorProof))
+		proofStatus(status1.(*ProofError))
 		t.Fatalf("TestDisjunctive - incorrect error message for correct proof, case 1\n")
 	}
 
 	djProofRIGHT, status2 := NewDisjunctiveProof(TestCurve, Base1, Result1, Base2, Result2, y, Right)
 
 	if status2 != nil {
-		proofStatus(status2.(*errorProof))
+		proofStatus(status2.(*ProofError))
 		t.Fatalf("TestDisjunctive - incorrect error message for correct proof, case 2\n")
 	}
 
@@ -52,6 +54,78 @@ func TestDisjunctive(t *testing.T) {
 
 }
 
+func TestDisjunctiveProofAuto(t *testing.T) {
+	x := big.NewInt(100)
+	y := big.NewInt(101)
+	Base1, Base2 := TestCurve.G, TestCurve.H
+	Result1, Result2 := TestCurve.Mult(Base1, x), TestCurve.Mult(Base2, y)
+
+	for _, c := range []struct {
+		w    *big.Int
+		side Side
+	}{{x, Left}, {y, Right}} {
+		if side, err := DisjunctiveSide(TestCurve, Base1, Result1, Base2, Result2, c.w); err != nil || side != c.side {
+			t.Fatalf("DisjunctiveSide of %v gives %v, %v, expected %v\n", c.w, side, err, c.side)
+		}
+		proof, err := NewDisjunctiveProofAuto(TestCurve, Base1, Result1, Base2, Result2, c.w)
+		if err != nil {
+			t.Fatalf("NewDisjunctiveProofAuto failed for %v: %v\n", c.w, err)
+		}
+		if ok, err := proof.Verify(TestCurve, Base1, Result1, Base2, Result2); !ok || err != nil {
+			t.Fatalf("DisjunctiveProof of NewDisjunctiveProofAuto did not verify: %v\n", err)
+		}
+	}
+
+	// a witness for neither side
+	if _, err := DisjunctiveSide(TestCurve, Base1, Result1, Base2, Result2, big.NewInt(7)); !errors.Is(err, ErrInvalidSide) {
+		t.Fatalf("DisjunctiveSide of a witness for neither side gives %v\n", err)
+	}
+	if proof, err := NewDisjunctiveProofAuto(TestCurve, Base1, Result1, Base2, Result2, big.NewInt(7)); proof != nil || !errors.Is(err, ErrInvalidSide) {
+		t.Fatalf("NewDisjunctiveProofAuto of a witness for neither side gives %v, %v\n", proof, err)
+	}
+	if _, err := NewDisjunctiveProofAuto(TestCurve, Base1, Result1, Base2, Result2, nil); !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("NewDisjunctiveProofAuto of a nil witness gives %v\n", err)
+	}
+
+	// explicit wrong or unknown sides
+	for _, c := range []struct {
+		w    *big.Int
+		side Side
+		err  error
+	}{{y, Left, ErrInvalidSide}, {x, Right, ErrInvalidSide}, {x, Side(2), ErrInvalidSide}, {big.NewInt(7), Left, ErrInvalidInput}} {
+		proof, err := NewDisjunctiveProof(TestCurve, Base1, Result1, Base2, Result2, c.w, c.side)
+		if proof != nil || !errors.Is(err, c.err) {
+			t.Fatalf("NewDisjunctiveProof of %v on side %v gives %v, %v, expected %v\n", c.w, c.side, proof, err, c.err)
+		}
+	}
+}
+
+// TestDisjunctiveSideSizes checks that the simulated and the real half of a
+// DisjunctiveProof encode to the same size on either side, so the length of a
+// proof does not tell which statement the prover knows
+func TestDisjunctiveSideSizes(t *testing.T) {
+	N := TestCurve.C.Params().N
+	for ii := 0; ii < 20; ii++ {
+		x, _ := rand.Int(rand.Reader, N)
+		if ii < 2 {
+			x = big.NewInt(int64(ii + 1)) // small witnesses, for encodings that are not padded
+		}
+		A := TestCurve.Mult(TestCurve.G, x)
+		B := TestCurve.Mult(TestCurve.H, new(big.Int).Add(x, big.NewInt(1)))
+		left, err := NewDisjunctiveProof(TestCurve, TestCurve.G, A, TestCurve.H, B, x, Left)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		right, err := NewDisjunctiveProof(TestCurve, TestCurve.H, B, TestCurve.G, A, x, Right)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if len(left.Bytes()) != len(right.Bytes()) || len(left.Bytes()) != left.Size() {
+			t.Fatalf("DisjunctiveProofs of the Left and Right side encode to %d and %d bytes\n", len(left.Bytes()), len(right.Bytes()))
+		}
+	}
+}
+
 func TestDisjuncSerialization(t *testing.T) {
 	value, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
 	randVal, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
@@ -60,7 +134,11 @@ func TestDisjuncSerialization(t *testing.T) {
 	Base2 := TestCurve.H
 	Result2 := TestCurve.Mult(Base2, randVal)
 	proof, _ := NewDisjunctiveProof(TestCurve, Base1, Result1, Base2, Result2, value, Left)
-	proof, err := NewDisjunctiveProofFromBytes(proof.Bytes())
+	encoded := proof.Bytes()
+	if len(encoded) != disjunctiveProofLen || !bytes.Equal(encoded, proof.Bytes()) {
+		t.Fatalf("TestDisjuncSerialization produced a non-deterministic or wrongly sized encoding\n")
+	}
+	proof, err := NewDisjunctiveProofFromBytes(encoded)
 	if err != nil {
 		t.Fatalf("TestDisjuncSerialization failed to deserialize\n")
 	}
@@ -124,3 +202,38 @@ func BenchmarkDisjuncVerify_RIGHT(b *testing.B) {
 		proof.Verify(TestCurve, Base1, Result1, Base2, Result2)
 	}
 }
+
+func TestDisjunctiveProofWithMessage(t *testing.T) {
+	x := big.NewInt(100)
+	Base1, Result1 := TestCurve.G, TestCurve.Mult(TestCurve.G, x)
+	Base2, Result2 := TestCurve.H, TestCurve.Mult(TestCurve.H, big.NewInt(101))
+	msg := []byte("transaction 1")
+	defer func(old bool) { LegacyChallenges = old }(LegacyChallenges)
+
+	for _, legacy := range []bool{false, true} {
+		LegacyChallenges = legacy
+
+		proof, err := NewDisjunctiveProofWithMessage(TestCurve, Base1, Result1, Base2, Result2, x, Left, msg)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if ok, err := proof.VerifyWithMessage(TestCurve, Base1, Result1, Base2, Result2, msg); !ok || err != nil {
+			t.Fatalf("DisjunctiveProof with a message failed to verify, legacy %v: %v\n", legacy, err)
+		}
+		if ok, err := proof.VerifyWithMessage(TestCurve, Base1, Result1, Base2, Result2, []byte("transaction 2")); ok || !errors.Is(err, ErrChallengeMismatch) {
+			t.Fatalf("DisjunctiveProof verified for another message, legacy %v: %v\n", legacy, err)
+		}
+		if ok, _ := proof.Verify(TestCurve, Base1, Result1, Base2, Result2); ok {
+			t.Fatalf("DisjunctiveProof with a message verified without it, legacy %v\n", legacy)
+		}
+
+		plain, _ := NewDisjunctiveProofWithRand(TestCurve, newSeededReader("dj"), Base1, Result1, Base2, Result2, x, Left)
+		empty, _ := newDisjunctiveProof(TestCurve, newSeededReader("dj"), Base1, Result1, Base2, Result2, x, Left, []byte{})
+		if !bytes.Equal(plain.Bytes(), empty.Bytes()) {
+			t.Fatalf("DisjunctiveProof with an empty message differs from one without, legacy %v\n", legacy)
+		}
+		if ok, err := plain.VerifyWithMessage(TestCurve, Base1, Result1, Base2, Result2, nil); !ok || err != nil {
+			t.Fatalf("DisjunctiveProof failed to verify with a nil message: %v\n", err)
+		}
+	}
+}
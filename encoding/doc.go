@@ -0,0 +1,125 @@
+/*
+Package encoding is the canonical wire format of the proofs of zksigma, for
+implementations in other languages that have to read or write them. Every
+proof has exactly one encoding: EncodeX refuses a proof whose encoding would
+decode to something else, and DecodeX refuses every byte string that is not
+the encoding of the proof it decodes to, trailing bytes and alternate point or
+scalar encodings included. The errors match zksigma.ErrMalformedProof,
+zksigma.ErrNotOnCurve or zksigma.ErrNonCanonicalScalar with errors.Is.
+
+The format is the one of Bytes and NewXFromBytes of every proof type, this
+package only pins it down. It is defined for secp256k1 with the generators of
+zksigma.ZKCurve, proofs on zksigma.P256Curve differ only in the prefix byte of
+their points.
+
+# Scalars
+
+A scalar is 32 bytes, big-endian, and has to be less than the order N of the
+curve.
+
+# Points
+
+A point is 33 bytes in compressed SEC1 form: 0x02 for an even and 0x03 for an
+odd Y, followed by X as 32 bytes big-endian, which has to be less than the
+field prime P. The point at infinity is 33 zero bytes. There is no
+uncompressed form. A point on P-256 has 1 in the upper half of its prefix
+byte, 0x12 or 0x13, and is decoded on that curve.
+
+# Counts
+
+Proofs with a variable number of entries start with their count as a Bitcoin
+CompactSize varint: one byte below 0xfd, else 0xfd, 0xfe or 0xff followed by a
+2, 4 or 8 byte little-endian count. The shortest form has to be used.
+
+# Proofs
+
+Every proof but AggregateRangeProof, BitRangeProof, BoundedRangeProof,
+ComparisonProof and RangeProof starts with one version byte, 0x03 for ABCProof
+(0x04 for a Delegated one) and 0x01 for the others. The fields follow in this order, P is a
+point, S a scalar and n a count:
+
+	ABCProof              0x03 B C T1 T2 T3:P Challenge j k l:S CToken:P DisjunctiveProof ConsistencyProof
+	AggregateRangeProof   the BitRangeProof of the sum
+	BalanceProof          0x01 T:P Challenge S:S
+	BitProof              0x01 T1 T2:P C C1 C2 S1 S2:S
+	BitRangeProof         n, then n times BitCommit:P DisjunctiveProof
+	BoundedRangeProof     the BitRangeProofs of value - lower and upper - value,
+	                      both with the same n
+	ComparisonProof       the BitRangeProof of the difference
+	ConsistencyProof      0x01 T1 T2:P Challenge S1 S2:S
+	DecryptionProof       0x01 T1 T2:P Challenge S:S
+	DisjunctiveProof      0x01 T1 T2:P C C1 C2 S1 S2:S
+	EquivOrZeroProof      0x01 T1 T2:P C C1 C2 S1 S2:S
+	EquivalenceProof      0x01 UG UH:P Challenge HiddenValue:S
+	GSPFSProof            0x01 Base RandCommit:P HiddenValue Challenge:S
+	InequalityProof       0x01 B T1 T2:P Challenge S1 S2 S3:S
+	KeyRotationProof      0x01 T1 T2:P Challenge S:S
+	MultiProductProof     0x01 n Challenge:S, then n - 2 times Partial:P,
+	                      then n - 1 times T1 T2 T3:P S1 S2 S3 S4 S5:S
+	MultiRangeProof       0x01 n bits Challenge:S, then n * bits times C:P C0 S0 S1:S
+	NonZeroProof          0x01 B T1 T2:P Challenge S1 S2 S3:S
+	OneOfManyProof        0x01 n C:S, then n times T:P C:S S:S
+	OpenProof             0x01 T:P Challenge S1 S2:S
+	OpenToValueProof      0x01 T:P Challenge S:S
+	ProductProof          0x01 T1 T2 T3:P Challenge S1 S2 S3 S4 S5:S
+	RerandomizationProof  0x01 T:P Challenge S:S
+	SameBlindingProof     0x01 T1 T2:P Challenge S:S
+	SameValueProof        0x01 T:P Challenge S:S
+	SetMembershipProof    the OneOfManyProof of its branches
+	ShuffleProof          0x01 n, n times C:P, n times CHat:P, T1 T2 T3 T4:P,
+	                      n times THat:P, Challenge S1 S2 S3 S4:S, n times SHat:S, n times SPrime:S
+	SumProof              0x01 T:P Challenge S:S
+	VectorOpenProof       0x01 n T:P Challenge SR:S, then n times S:S
+
+The embedded DisjunctiveProof and ConsistencyProof of ABCProof and BitRangeProof
+have their own version byte. RangeProof keeps its older layout: a point is the
+count and bytes of X then of Y, a scalar the count and bytes of a sign byte
+0x00 followed by its value, all without leading zeros, and the proof is
+ProofAggregate, ProofE, the number of tuples and the C and S of every tuple.
+
+# Typed proofs
+
+The encoding of a proof does not say which type of proof it is. The typed
+encoding of zksigma.EncodeProof is one tag byte for the type followed by the
+encoding of the proof. The tags start at 0x10, zksigma.ProofTypes lists them,
+e.g. 0x10 for ABCProof and 0x28 for SumProof.
+
+# Challenges
+
+The challenges are derived with a zksigma.Transcript. It runs SHA-256 over a
+sequence of messages, each hashed as
+
+	len(label) || label || len(data) || data
+
+with 4 byte big-endian lengths. The first message has label "zksigma" and the
+proof type as data, e.g. "SameValueProof". A point is added as X || Y with both
+coordinates 32 bytes big-endian, the point at infinity as 64 zero bytes, and a
+scalar as 32 bytes big-endian. To draw challenge label, the message (label,
+empty) is added, d is the SHA-256 digest of everything so far and (label, d) is
+added, so a second challenge differs. The challenge is
+
+	int(first 48 bytes of SHA-256(d || 0x00000000) || SHA-256(d || 0x00000001)) mod N
+
+with 48 = (bits(N) + 128) / 8 bytes, so the challenge is close to uniform mod
+N. Which messages a proof adds, and in which order, is in the challenge
+function of its type, e.g. sameValueChallenge adds G, H, CM1, CM2 and T and
+draws "c".
+
+# Test vectors
+
+testdata/vectors.json has one proof of every type with a statement, with the
+statement, the witness and the expected encoding of the proof. Scalars are hex
+of 32 bytes and points the hex of their encoding. The random values of a
+vector are read from the stream
+
+	SHA-256(seed || 0x00000000) || SHA-256(seed || 0x00000001) || ...
+
+where seed is the seed of the vector. A random scalar takes the next 32 bytes
+of the stream as a big-endian number and draws again if it is not less than N,
+like Go's crypto/rand.Int. Every implementation should decode and verify the
+vectors, and one that draws its random values in the same order as the Go code
+reproduces the proofs byte for byte. Regenerate the file with
+
+	go test ./encoding -run TestVectors -update
+*/
+package encoding
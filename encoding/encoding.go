@@ -0,0 +1,755 @@
+package encoding
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mit-dci/zksigma"
+)
+
+// nilProof is the error for encoding a nil proof
+func nilProof(t string) error {
+	return fmt.Errorf("%s: passed proof is nil: %w", t, zksigma.ErrMalformedProof)
+}
+
+// encoded returns b, the encoding of a proof, if decoding it gave err and a
+// proof that is the same as the encoded one. Otherwise the proof holds a
+// scalar or point that its encoding changes.
+func encoded(t string, b []byte, err error, same bool) ([]byte, error) {
+	if err != nil {
+		return nil, fmt.Errorf("%s: proof has no canonical encoding: %w", t, err)
+	}
+	if !same {
+		return nil, fmt.Errorf("%s: proof has no canonical encoding: %w", t, zksigma.ErrMalformedProof)
+	}
+	return b, nil
+}
+
+// canonical checks that b, which decoded to a proof without error, is the
+// encoding of that proof
+func canonical(t string, b, encoding []byte) error {
+	if !bytes.Equal(b, encoding) {
+		return fmt.Errorf("%s: non-canonical encoding: %w", t, zksigma.ErrMalformedProof)
+	}
+	return nil
+}
+
+// EncodeABCProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeABCProof(p *zksigma.ABCProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeABCProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeABCProof(b)
+	return encoded("EncodeABCProof", b, err, q.Equal(p))
+}
+
+// DecodeABCProof decodes an ABCProof from b, which has to be its encoding and
+// nothing else
+func DecodeABCProof(b []byte) (*zksigma.ABCProof, error) {
+	p, err := zksigma.NewABCProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeABCProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeAggregateRangeProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeAggregateRangeProof(p *zksigma.AggregateRangeProof) ([]byte, error) {
+	if p == nil || p.Sum == nil {
+		return nil, nilProof("EncodeAggregateRangeProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeAggregateRangeProof(b)
+	return encoded("EncodeAggregateRangeProof", b, err, q.Equal(p))
+}
+
+// DecodeAggregateRangeProof decodes an AggregateRangeProof from b, which has to
+// be its encoding and nothing else
+func DecodeAggregateRangeProof(b []byte) (*zksigma.AggregateRangeProof, error) {
+	p, err := zksigma.NewAggregateRangeProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeAggregateRangeProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeBalanceProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeBalanceProof(p *zksigma.BalanceProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeBalanceProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeBalanceProof(b)
+	return encoded("EncodeBalanceProof", b, err, q.Equal(p))
+}
+
+// DecodeBalanceProof decodes a BalanceProof from b, which has to be its
+// encoding and nothing else
+func DecodeBalanceProof(b []byte) (*zksigma.BalanceProof, error) {
+	p, err := zksigma.NewBalanceProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeBalanceProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeBitProof returns the encoding of p. It fails if p is nil or does not
+// decode back from its encoding.
+func EncodeBitProof(p *zksigma.BitProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeBitProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeBitProof(b)
+	return encoded("EncodeBitProof", b, err, q.Equal(p))
+}
+
+// DecodeBitProof decodes a BitProof from b, which has to be its encoding and
+// nothing else
+func DecodeBitProof(b []byte) (*zksigma.BitProof, error) {
+	p, err := zksigma.NewBitProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeBitProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeBitRangeProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeBitRangeProof(p *zksigma.BitRangeProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeBitRangeProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeBitRangeProof(b)
+	return encoded("EncodeBitRangeProof", b, err, q.Equal(p))
+}
+
+// DecodeBitRangeProof decodes a BitRangeProof from b, which has to be its
+// encoding and nothing else
+func DecodeBitRangeProof(b []byte) (*zksigma.BitRangeProof, error) {
+	p, err := zksigma.NewBitRangeProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeBitRangeProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeBoundedRangeProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeBoundedRangeProof(p *zksigma.BoundedRangeProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeBoundedRangeProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeBoundedRangeProof(b)
+	return encoded("EncodeBoundedRangeProof", b, err, q.Equal(p))
+}
+
+// DecodeBoundedRangeProof decodes a BoundedRangeProof from b, which has to be
+// its encoding and nothing else
+func DecodeBoundedRangeProof(b []byte) (*zksigma.BoundedRangeProof, error) {
+	p, err := zksigma.NewBoundedRangeProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeBoundedRangeProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeComparisonProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeComparisonProof(p *zksigma.ComparisonProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeComparisonProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeComparisonProof(b)
+	return encoded("EncodeComparisonProof", b, err, q.Equal(p))
+}
+
+// DecodeComparisonProof decodes a ComparisonProof from b, which has to be its
+// encoding and nothing else
+func DecodeComparisonProof(b []byte) (*zksigma.ComparisonProof, error) {
+	p, err := zksigma.NewComparisonProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeComparisonProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeConsistencyProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeConsistencyProof(p *zksigma.ConsistencyProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeConsistencyProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeConsistencyProof(b)
+	return encoded("EncodeConsistencyProof", b, err, q.Equal(p))
+}
+
+// DecodeConsistencyProof decodes a ConsistencyProof from b, which has to be its
+// encoding and nothing else
+func DecodeConsistencyProof(b []byte) (*zksigma.ConsistencyProof, error) {
+	p, err := zksigma.NewConsistencyProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeConsistencyProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeDecryptionProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeDecryptionProof(p *zksigma.DecryptionProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeDecryptionProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeDecryptionProof(b)
+	return encoded("EncodeDecryptionProof", b, err, q.Equal(p))
+}
+
+// DecodeDecryptionProof decodes a DecryptionProof from b, which has to be its
+// encoding and nothing else
+func DecodeDecryptionProof(b []byte) (*zksigma.DecryptionProof, error) {
+	p, err := zksigma.NewDecryptionProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeDecryptionProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeDisjunctiveProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeDisjunctiveProof(p *zksigma.DisjunctiveProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeDisjunctiveProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeDisjunctiveProof(b)
+	return encoded("EncodeDisjunctiveProof", b, err, q.Equal(p))
+}
+
+// DecodeDisjunctiveProof decodes a DisjunctiveProof from b, which has to be its
+// encoding and nothing else
+func DecodeDisjunctiveProof(b []byte) (*zksigma.DisjunctiveProof, error) {
+	p, err := zksigma.NewDisjunctiveProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeDisjunctiveProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeEquivOrZeroProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeEquivOrZeroProof(p *zksigma.EquivOrZeroProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeEquivOrZeroProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeEquivOrZeroProof(b)
+	return encoded("EncodeEquivOrZeroProof", b, err, q.Equal(p))
+}
+
+// DecodeEquivOrZeroProof decodes an EquivOrZeroProof from b, which has to be
+// its encoding and nothing else
+func DecodeEquivOrZeroProof(b []byte) (*zksigma.EquivOrZeroProof, error) {
+	p, err := zksigma.NewEquivOrZeroProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeEquivOrZeroProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeEquivalenceProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeEquivalenceProof(p *zksigma.EquivalenceProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeEquivalenceProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeEquivalenceProof(b)
+	return encoded("EncodeEquivalenceProof", b, err, q.Equal(p))
+}
+
+// DecodeEquivalenceProof decodes an EquivalenceProof from b, which has to be its
+// encoding and nothing else
+func DecodeEquivalenceProof(b []byte) (*zksigma.EquivalenceProof, error) {
+	p, err := zksigma.NewEquivalenceProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeEquivalenceProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeGSPFSProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeGSPFSProof(p *zksigma.GSPFSProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeGSPFSProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeGSPFSProof(b)
+	return encoded("EncodeGSPFSProof", b, err, q.Equal(p))
+}
+
+// DecodeGSPFSProof decodes a GSPFSProof from b, which has to be its
+// encoding and nothing else
+func DecodeGSPFSProof(b []byte) (*zksigma.GSPFSProof, error) {
+	p, err := zksigma.NewGSPFSProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeGSPFSProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeInequalityProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeInequalityProof(p *zksigma.InequalityProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeInequalityProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeInequalityProof(b)
+	return encoded("EncodeInequalityProof", b, err, q.Equal(p))
+}
+
+// DecodeInequalityProof decodes an InequalityProof from b, which has to be its
+// encoding and nothing else
+func DecodeInequalityProof(b []byte) (*zksigma.InequalityProof, error) {
+	p, err := zksigma.NewInequalityProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeInequalityProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeKeyRotationProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeKeyRotationProof(p *zksigma.KeyRotationProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeKeyRotationProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeKeyRotationProof(b)
+	return encoded("EncodeKeyRotationProof", b, err, q.Equal(p))
+}
+
+// DecodeKeyRotationProof decodes a KeyRotationProof from b, which has to be its
+// encoding and nothing else
+func DecodeKeyRotationProof(b []byte) (*zksigma.KeyRotationProof, error) {
+	p, err := zksigma.NewKeyRotationProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeKeyRotationProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeNonZeroProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeNonZeroProof(p *zksigma.NonZeroProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeNonZeroProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeNonZeroProof(b)
+	return encoded("EncodeNonZeroProof", b, err, q.Equal(p))
+}
+
+// DecodeNonZeroProof decodes a NonZeroProof from b, which has to be its
+// encoding and nothing else
+func DecodeNonZeroProof(b []byte) (*zksigma.NonZeroProof, error) {
+	p, err := zksigma.NewNonZeroProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeNonZeroProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeOneOfManyProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeOneOfManyProof(p *zksigma.OneOfManyProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeOneOfManyProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeOneOfManyProof(b)
+	return encoded("EncodeOneOfManyProof", b, err, q.Equal(p))
+}
+
+// DecodeOneOfManyProof decodes an OneOfManyProof from b, which has to be its
+// encoding and nothing else
+func DecodeOneOfManyProof(b []byte) (*zksigma.OneOfManyProof, error) {
+	p, err := zksigma.NewOneOfManyProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeOneOfManyProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeOpenProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeOpenProof(p *zksigma.OpenProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeOpenProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeOpenProof(b)
+	return encoded("EncodeOpenProof", b, err, q.Equal(p))
+}
+
+// DecodeOpenProof decodes an OpenProof from b, which has to be its encoding and
+// nothing else
+func DecodeOpenProof(b []byte) (*zksigma.OpenProof, error) {
+	p, err := zksigma.NewOpenProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeOpenProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeOpenToValueProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeOpenToValueProof(p *zksigma.OpenToValueProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeOpenToValueProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeOpenToValueProof(b)
+	return encoded("EncodeOpenToValueProof", b, err, q.Equal(p))
+}
+
+// DecodeOpenToValueProof decodes an OpenToValueProof from b, which has to be its
+// encoding and nothing else
+func DecodeOpenToValueProof(b []byte) (*zksigma.OpenToValueProof, error) {
+	p, err := zksigma.NewOpenToValueProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeOpenToValueProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeProductProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeProductProof(p *zksigma.ProductProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeProductProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeProductProof(b)
+	return encoded("EncodeProductProof", b, err, q.Equal(p))
+}
+
+// DecodeProductProof decodes a ProductProof from b, which has to be its
+// encoding and nothing else
+func DecodeProductProof(b []byte) (*zksigma.ProductProof, error) {
+	p, err := zksigma.NewProductProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeProductProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeMultiProductProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeMultiProductProof(p *zksigma.MultiProductProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeMultiProductProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeMultiProductProof(b)
+	return encoded("EncodeMultiProductProof", b, err, q.Equal(p))
+}
+
+// DecodeMultiProductProof decodes a MultiProductProof from b, which has to be
+// its encoding and nothing else
+func DecodeMultiProductProof(b []byte) (*zksigma.MultiProductProof, error) {
+	p, err := zksigma.NewMultiProductProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeMultiProductProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeMultiRangeProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeMultiRangeProof(p *zksigma.MultiRangeProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeMultiRangeProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeMultiRangeProof(b)
+	return encoded("EncodeMultiRangeProof", b, err, q.Equal(p))
+}
+
+// DecodeMultiRangeProof decodes a MultiRangeProof from b, which has to be its
+// encoding and nothing else
+func DecodeMultiRangeProof(b []byte) (*zksigma.MultiRangeProof, error) {
+	p, err := zksigma.NewMultiRangeProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeMultiRangeProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeRangeProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeRangeProof(p *zksigma.RangeProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeRangeProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeRangeProof(b)
+	return encoded("EncodeRangeProof", b, err, q.Equal(p))
+}
+
+// DecodeRangeProof decodes a RangeProof from b, which has to be its
+// encoding and nothing else
+func DecodeRangeProof(b []byte) (*zksigma.RangeProof, error) {
+	p, err := zksigma.NewRangeProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeRangeProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeRerandomizationProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeRerandomizationProof(p *zksigma.RerandomizationProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeRerandomizationProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeRerandomizationProof(b)
+	return encoded("EncodeRerandomizationProof", b, err, q.Equal(p))
+}
+
+// DecodeRerandomizationProof decodes a RerandomizationProof from b, which has to be its
+// encoding and nothing else
+func DecodeRerandomizationProof(b []byte) (*zksigma.RerandomizationProof, error) {
+	p, err := zksigma.NewRerandomizationProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeRerandomizationProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeSameBlindingProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeSameBlindingProof(p *zksigma.SameBlindingProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeSameBlindingProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeSameBlindingProof(b)
+	return encoded("EncodeSameBlindingProof", b, err, q.Equal(p))
+}
+
+// DecodeSameBlindingProof decodes a SameBlindingProof from b, which has to be
+// its encoding and nothing else
+func DecodeSameBlindingProof(b []byte) (*zksigma.SameBlindingProof, error) {
+	p, err := zksigma.NewSameBlindingProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeSameBlindingProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeSameValueProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeSameValueProof(p *zksigma.SameValueProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeSameValueProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeSameValueProof(b)
+	return encoded("EncodeSameValueProof", b, err, q.Equal(p))
+}
+
+// DecodeSameValueProof decodes a SameValueProof from b, which has to be its
+// encoding and nothing else
+func DecodeSameValueProof(b []byte) (*zksigma.SameValueProof, error) {
+	p, err := zksigma.NewSameValueProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeSameValueProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeSetMembershipProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeSetMembershipProof(p *zksigma.SetMembershipProof) ([]byte, error) {
+	if p == nil || p.Branches == nil {
+		return nil, nilProof("EncodeSetMembershipProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeSetMembershipProof(b)
+	return encoded("EncodeSetMembershipProof", b, err, q.Equal(p))
+}
+
+// DecodeSetMembershipProof decodes a SetMembershipProof from b, which has to be
+// its encoding and nothing else
+func DecodeSetMembershipProof(b []byte) (*zksigma.SetMembershipProof, error) {
+	p, err := zksigma.NewSetMembershipProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeSetMembershipProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeShuffleProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeShuffleProof(p *zksigma.ShuffleProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeShuffleProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeShuffleProof(b)
+	return encoded("EncodeShuffleProof", b, err, q.Equal(p))
+}
+
+// DecodeShuffleProof decodes a ShuffleProof from b, which has to be its
+// encoding and nothing else
+func DecodeShuffleProof(b []byte) (*zksigma.ShuffleProof, error) {
+	p, err := zksigma.NewShuffleProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeShuffleProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeSumProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeSumProof(p *zksigma.SumProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeSumProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeSumProof(b)
+	return encoded("EncodeSumProof", b, err, q.Equal(p))
+}
+
+// DecodeSumProof decodes a SumProof from b, which has to be its encoding and
+// nothing else
+func DecodeSumProof(b []byte) (*zksigma.SumProof, error) {
+	p, err := zksigma.NewSumProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeSumProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodeVectorOpenProof returns the encoding of p. It fails if p is nil or
+// does not decode back from its encoding.
+func EncodeVectorOpenProof(p *zksigma.VectorOpenProof) ([]byte, error) {
+	if p == nil {
+		return nil, nilProof("EncodeVectorOpenProof")
+	}
+	b := p.Bytes()
+	q, err := DecodeVectorOpenProof(b)
+	return encoded("EncodeVectorOpenProof", b, err, q.Equal(p))
+}
+
+// DecodeVectorOpenProof decodes a VectorOpenProof from b, which has to be its
+// encoding and nothing else
+func DecodeVectorOpenProof(b []byte) (*zksigma.VectorOpenProof, error) {
+	p, err := zksigma.NewVectorOpenProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonical("DecodeVectorOpenProof", b, p.Bytes()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
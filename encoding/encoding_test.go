@@ -0,0 +1,698 @@
+package encoding
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/mit-dci/zksigma"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/vectors.json")
+
+var zk = zksigma.ZKCurve
+
+var vectorsFile = filepath.Join("testdata", "vectors.json")
+
+// stream is the random stream of a test vector, see the package doc
+type stream struct {
+	seed    []byte
+	counter uint32
+	buf     []byte
+}
+
+func newStream(seed []byte) *stream {
+	return &stream{seed: seed}
+}
+
+func (s *stream) Read(p []byte) (int, error) {
+	for len(s.buf) < len(p) {
+		var counter [4]byte
+		binary.BigEndian.PutUint32(counter[:], s.counter)
+		s.counter++
+		block := sha256.Sum256(append(append([]byte{}, s.seed...), counter[:]...))
+		s.buf = append(s.buf, block[:]...)
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// vectorFile is the content of testdata/vectors.json
+type vectorFile struct {
+	Curve   string   `json:"curve"`
+	G       string   `json:"g"`
+	H       string   `json:"h"`
+	Vectors []vector `json:"vectors"`
+}
+
+// vector is one proof with everything needed to generate and check it
+type vector struct {
+	Type      string      `json:"type"`
+	Seed      string      `json:"seed"`
+	Statement interface{} `json:"statement"`
+	Witness   interface{} `json:"witness"`
+	Option    string      `json:"option,omitempty"`
+	Proof     string      `json:"proof"`
+}
+
+// vectorCase is the statement and witness of the vector of one proof type,
+// with the functions of that type. encode and decode are the EncodeX and
+// DecodeX of the type, the proof they return has a VerifyStatement for stmt.
+type vectorCase struct {
+	stmt, wit interface{}
+	option    string
+	proveWith func(rnd io.Reader, stmt, wit interface{}, option zksigma.Side) (interface{}, error)
+	encode    interface{}
+	decode    interface{}
+}
+
+// scalar returns a fixed scalar for label, the secrets of the vectors are
+// fixed so that regenerating them gives the same file
+func scalar(label string) *big.Int {
+	h := sha256.Sum256([]byte("zksigma test vector " + label))
+	return new(big.Int).Mod(new(big.Int).SetBytes(h[:]), zk.C.Params().N)
+}
+
+func vectorCases(t *testing.T) map[string]vectorCase {
+	must := func(p zksigma.ECPoint, err error) zksigma.ECPoint {
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		return p
+	}
+	value, low := big.NewInt(1000), big.NewInt(300)
+	sk, newSK := scalar("sk"), scalar("new sk")
+	r, r2, sameR := scalar("r"), scalar("r2"), scalar("same r")
+	x, deltaR := scalar("x"), scalar("delta r")
+	PK, newPK := zk.Mult(zk.H, sk), zk.Mult(zk.H, newSK)
+	CM, CMLow := zksigma.PedCommitR(zk, value, r), zksigma.PedCommitR(zk, low, r2)
+	CMTok := zk.Mult(PK, r)
+	// BitRangeProof, ComparisonProof and AggregateRangeProof are large, keep
+	// them to 4 or 5 bits
+	small, smaller := big.NewInt(12), big.NewInt(5)
+	CMSmall, CMSmaller := zksigma.PedCommitR(zk, small, r), zksigma.PedCommitR(zk, smaller, r2)
+	newCMTok := must(zksigma.RotateToken(zk, CMTok, sk, newSK))
+	// 1000 + 300 - 100 balances, the randomness r + r2 - sameR does not cancel
+	// out against the r of CMSum
+	CMFee, CMSum := zksigma.PedCommitR(zk, big.NewInt(100), sameR), zksigma.PedCommitR(zk, big.NewInt(1200), r)
+
+	denominations := []*big.Int{big.NewInt(10), value, big.NewInt(100000)}
+	bases := make([]zksigma.ECPoint, len(denominations))
+	results := make([]zksigma.ECPoint, len(denominations))
+	for i, v := range denominations {
+		bases[i], results[i] = zk.H, zk.Sub(CM, zk.Mult(zk.G, v))
+	}
+
+	perm := []int{2, 0, 1}
+	inputs := make([]zksigma.ECPoint, len(perm))
+	outputs := make([]zksigma.ECPoint, len(perm))
+	deltaRs := make([]*big.Int, len(perm))
+	for i := range perm {
+		inputs[i] = zksigma.PedCommitR(zk, big.NewInt(int64(i+1)), scalar(fmt.Sprintf("input %d", i)))
+		deltaRs[i] = scalar(fmt.Sprintf("delta r %d", i))
+	}
+	for i, k := range perm {
+		outputs[i] = zk.Add(inputs[k], zk.Mult(zk.H, deltaRs[i]))
+	}
+
+	return map[string]vectorCase{
+		"ABCProof": {
+			&zksigma.ABCStatement{CM: CM, CMTok: CMTok, PubKey: PK}, &zksigma.ABCWitness{Value: value, SK: sk}, "Right",
+			func(rnd io.Reader, s, w interface{}, option zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.ABCStatement), w.(*zksigma.ABCWitness)
+				return zksigma.NewABCProofWithRand(zk, rnd, stmt.CM, stmt.CMTok, wit.Value, wit.SK, option)
+			},
+			EncodeABCProof, DecodeABCProof,
+		},
+		"AggregateRangeProof": {
+			&zksigma.AggregateRangeStatement{CMs: []zksigma.ECPoint{CMSmall, CMSmaller}, Bits: 5},
+			&zksigma.AggregateRangeWitness{Values: []*big.Int{small, smaller}, Randomnesses: []*big.Int{r, r2}}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.AggregateRangeStatement), w.(*zksigma.AggregateRangeWitness)
+				return zksigma.NewAggregateRangeProofWithRand(zk, rnd, stmt.CMs, wit.Values, wit.Randomnesses, stmt.Bits)
+			},
+			EncodeAggregateRangeProof, DecodeAggregateRangeProof,
+		},
+		"BalanceProof": {
+			&zksigma.BalanceStatement{LHS: CMSum, Terms: []zksigma.ECPoint{CM, CMLow, CMFee}, Signs: []int{1, 1, -1}},
+			&zksigma.BalanceWitness{LHSR: r, Rs: []*big.Int{r, r2, sameR}}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.BalanceStatement), w.(*zksigma.BalanceWitness)
+				return zksigma.NewBalanceProofWithRand(zk, rnd, stmt.LHS, stmt.Terms, stmt.Signs, wit.LHSR, wit.Rs)
+			},
+			EncodeBalanceProof, DecodeBalanceProof,
+		},
+		"BitRangeProof": {
+			&zksigma.BitRangeStatement{CM: CMSmall, Bits: 4}, &zksigma.BitRangeWitness{Value: small, Randomness: r}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.BitRangeStatement), w.(*zksigma.BitRangeWitness)
+				return zksigma.NewBitRangeProofWithRand(zk, rnd, stmt.CM, wit.Value, wit.Randomness, stmt.Bits)
+			},
+			EncodeBitRangeProof, DecodeBitRangeProof,
+		},
+		"ComparisonProof": {
+			&zksigma.ComparisonStatement{CMA: CMSmall, CMB: CMSmaller, Bits: 4},
+			&zksigma.ComparisonWitness{A: small, B: smaller, RA: r, RB: r2}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.ComparisonStatement), w.(*zksigma.ComparisonWitness)
+				return zksigma.NewComparisonProofWithRand(zk, rnd, stmt.CMA, stmt.CMB, wit.A, wit.B, wit.RA, wit.RB, stmt.Bits)
+			},
+			EncodeComparisonProof, DecodeComparisonProof,
+		},
+		"ConsistencyProof": {
+			&zksigma.ConsistencyStatement{CM: CM, CMTok: CMTok, PubKey: PK}, &zksigma.ConsistencyWitness{Value: value, Randomness: r}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.ConsistencyStatement), w.(*zksigma.ConsistencyWitness)
+				return zksigma.NewConsistencyProofWithRand(zk, rnd, stmt.CM, stmt.CMTok, stmt.PubKey, wit.Value, wit.Randomness)
+			},
+			EncodeConsistencyProof, DecodeConsistencyProof,
+		},
+		"DisjunctiveProof": {
+			&zksigma.DisjunctiveStatement{Base1: zk.G, Result1: zk.Mult(zk.G, x), Base2: zk.H, Result2: CM},
+			&zksigma.DisjunctiveWitness{X: x}, "Left",
+			func(rnd io.Reader, s, w interface{}, option zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.DisjunctiveStatement), w.(*zksigma.DisjunctiveWitness)
+				return zksigma.NewDisjunctiveProofWithRand(zk, rnd, stmt.Base1, stmt.Result1, stmt.Base2, stmt.Result2, wit.X, option)
+			},
+			EncodeDisjunctiveProof, DecodeDisjunctiveProof,
+		},
+		"EquivOrZeroProof": {
+			&zksigma.EquivOrZeroStatement{CMChange: CM, CMDelta: zksigma.PedCommitR(zk, value, sameR)},
+			&zksigma.EquivOrZeroWitness{RChange: r, RDelta: sameR}, "Right",
+			func(rnd io.Reader, s, w interface{}, option zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.EquivOrZeroStatement), w.(*zksigma.EquivOrZeroWitness)
+				return zksigma.NewEquivOrZeroProofWithRand(zk, rnd, stmt.CMChange, stmt.CMDelta, wit.RChange, wit.RDelta, option)
+			},
+			EncodeEquivOrZeroProof, DecodeEquivOrZeroProof,
+		},
+		"EquivalenceProof": {
+			&zksigma.EquivalenceStatement{Base1: zk.G, Result1: zk.Mult(zk.G, x), Base2: zk.H, Result2: zk.Mult(zk.H, x)},
+			&zksigma.EquivalenceWitness{X: x}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.EquivalenceStatement), w.(*zksigma.EquivalenceWitness)
+				return zksigma.NewEquivalenceProofWithRand(zk, rnd, stmt.Base1, stmt.Result1, stmt.Base2, stmt.Result2, wit.X)
+			},
+			EncodeEquivalenceProof, DecodeEquivalenceProof,
+		},
+		"GSPFSProof": {
+			&zksigma.GSPFSStatement{Base: zk.H, A: zk.Mult(zk.H, x)}, &zksigma.GSPFSWitness{X: x}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.GSPFSStatement), w.(*zksigma.GSPFSWitness)
+				return zksigma.NewGSPFSProofBaseWithRand(zk, rnd, stmt.Base, stmt.A, wit.X)
+			},
+			EncodeGSPFSProof, DecodeGSPFSProof,
+		},
+		"InequalityProof": {
+			&zksigma.InequalityStatement{CM1: CM, CM2: CMLow}, &zksigma.InequalityWitness{V1: value, V2: low, R1: r, R2: r2}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.InequalityStatement), w.(*zksigma.InequalityWitness)
+				return zksigma.NewInequalityProofWithRand(zk, rnd, stmt.CM1, stmt.CM2, wit.V1, wit.V2, wit.R1, wit.R2)
+			},
+			EncodeInequalityProof, DecodeInequalityProof,
+		},
+		"KeyRotationProof": {
+			&zksigma.KeyRotationStatement{OldCMTok: CMTok, NewCMTok: newCMTok, OldPK: PK, NewPK: newPK},
+			&zksigma.KeyRotationWitness{OldSK: sk, NewSK: newSK}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.KeyRotationStatement), w.(*zksigma.KeyRotationWitness)
+				return zksigma.NewKeyRotationProofWithRand(zk, rnd, stmt.OldCMTok, stmt.NewCMTok, stmt.OldPK, stmt.NewPK, wit.OldSK, wit.NewSK)
+			},
+			EncodeKeyRotationProof, DecodeKeyRotationProof,
+		},
+		"NonZeroProof": {
+			&zksigma.NonZeroStatement{CM: CM}, &zksigma.NonZeroWitness{V: value, R: r}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.NonZeroStatement), w.(*zksigma.NonZeroWitness)
+				return zksigma.NewNonZeroProofWithRand(zk, rnd, stmt.CM, wit.V, wit.R)
+			},
+			EncodeNonZeroProof, DecodeNonZeroProof,
+		},
+		"OneOfManyProof": {
+			&zksigma.OneOfManyStatement{Bases: bases, Results: results}, &zksigma.OneOfManyWitness{KnownIndex: 1, X: r}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.OneOfManyStatement), w.(*zksigma.OneOfManyWitness)
+				return zksigma.NewOneOfManyProofWithRand(zk, rnd, stmt.Bases, stmt.Results, wit.KnownIndex, wit.X)
+			},
+			EncodeOneOfManyProof, DecodeOneOfManyProof,
+		},
+		"OpenProof": {
+			&zksigma.OpenStatement{CM: CM}, &zksigma.OpenWitness{V: value, R: r}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.OpenStatement), w.(*zksigma.OpenWitness)
+				return zksigma.NewOpenProofWithRand(zk, rnd, stmt.CM, wit.V, wit.R)
+			},
+			EncodeOpenProof, DecodeOpenProof,
+		},
+		"OpenToValueProof": {
+			&zksigma.OpenToValueStatement{CM: CM, Value: value}, &zksigma.OpenToValueWitness{R: r}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.OpenToValueStatement), w.(*zksigma.OpenToValueWitness)
+				return zksigma.NewOpenToValueProofWithRand(zk, rnd, stmt.CM, stmt.Value, wit.R)
+			},
+			EncodeOpenToValueProof, DecodeOpenToValueProof,
+		},
+		"RerandomizationProof": {
+			&zksigma.RerandomizationStatement{CM: CM, CMPrime: zk.Add(CM, zk.Mult(zk.H, deltaR))},
+			&zksigma.RerandomizationWitness{DeltaR: deltaR}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.RerandomizationStatement), w.(*zksigma.RerandomizationWitness)
+				return zksigma.NewRerandomizationProofWithRand(zk, rnd, stmt.CM, stmt.CMPrime, wit.DeltaR)
+			},
+			EncodeRerandomizationProof, DecodeRerandomizationProof,
+		},
+		"SameBlindingProof": {
+			&zksigma.SameBlindingStatement{CMTok1: CMTok, CMTok2: zk.Mult(newPK, r), PK1: PK, PK2: newPK},
+			&zksigma.SameBlindingWitness{R: r}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.SameBlindingStatement), w.(*zksigma.SameBlindingWitness)
+				return zksigma.NewSameBlindingProofWithRand(zk, rnd, stmt.CMTok1, stmt.CMTok2, stmt.PK1, stmt.PK2, wit.R)
+			},
+			EncodeSameBlindingProof, DecodeSameBlindingProof,
+		},
+		"SameValueProof": {
+			&zksigma.SameValueStatement{CM1: CM, CM2: zksigma.PedCommitR(zk, value, sameR)},
+			&zksigma.SameValueWitness{V: value, R1: r, R2: sameR}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.SameValueStatement), w.(*zksigma.SameValueWitness)
+				return zksigma.NewSameValueProofWithRand(zk, rnd, stmt.CM1, stmt.CM2, wit.V, wit.R1, wit.R2)
+			},
+			EncodeSameValueProof, DecodeSameValueProof,
+		},
+		"SetMembershipProof": {
+			// out of order, the proof is for the sorted set
+			&zksigma.SetMembershipStatement{CM: CM, Set: []*big.Int{denominations[2], denominations[0], denominations[1]}},
+			&zksigma.SetMembershipWitness{Value: value, R: r}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.SetMembershipStatement), w.(*zksigma.SetMembershipWitness)
+				return zksigma.NewSetMembershipProofWithRand(zk, rnd, stmt.CM, wit.Value, wit.R, stmt.Set)
+			},
+			EncodeSetMembershipProof, DecodeSetMembershipProof,
+		},
+		"ShuffleProof": {
+			&zksigma.ShuffleStatement{Inputs: inputs, Outputs: outputs}, &zksigma.ShuffleWitness{Perm: perm, DeltaRs: deltaRs}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.ShuffleStatement), w.(*zksigma.ShuffleWitness)
+				return zksigma.NewShuffleProofWithRand(zk, rnd, stmt.Inputs, stmt.Outputs, wit.Perm, wit.DeltaRs)
+			},
+			EncodeShuffleProof, DecodeShuffleProof,
+		},
+		"SumProof": {
+			&zksigma.SumStatement{CMs: []zksigma.ECPoint{CM, CMLow}, Total: new(big.Int).Add(value, low)},
+			&zksigma.SumWitness{Values: []*big.Int{value, low}, Randomnesses: []*big.Int{r, r2}}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.SumStatement), w.(*zksigma.SumWitness)
+				return zksigma.NewSumProofWithRand(zk, rnd, stmt.CMs, wit.Values, wit.Randomnesses, stmt.Total)
+			},
+			EncodeSumProof, DecodeSumProof,
+		},
+		"VectorOpenProof": {
+			&zksigma.VectorOpenStatement{C: must(zksigma.VectorPedCommitR(zk, []*big.Int{value, x}, r))},
+			&zksigma.VectorOpenWitness{Values: []*big.Int{value, x}, R: r}, "",
+			func(rnd io.Reader, s, w interface{}, _ zksigma.Side) (interface{}, error) {
+				stmt, wit := s.(*zksigma.VectorOpenStatement), w.(*zksigma.VectorOpenWitness)
+				return zksigma.NewVectorOpenProofWithRand(zk, rnd, stmt.C, wit.Values, wit.R)
+			},
+			EncodeVectorOpenProof, DecodeVectorOpenProof,
+		},
+	}
+}
+
+var (
+	pointType  = reflect.TypeOf(zksigma.ECPoint{})
+	scalarType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// toJSON converts a statement or witness to the values json.Marshal writes
+// for it, scalars and points as hex
+func toJSON(v reflect.Value) interface{} {
+	switch v.Type() {
+	case pointType:
+		return v.Interface().(zksigma.ECPoint).ToHex()
+	case scalarType:
+		return fmt.Sprintf("%064x", v.Interface().(*big.Int))
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		return toJSON(v.Elem())
+	case reflect.Int:
+		return v.Int()
+	case reflect.Slice:
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = toJSON(v.Index(i))
+		}
+		return out
+	case reflect.Struct:
+		out := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			out[v.Type().Field(i).Name] = toJSON(v.Field(i))
+		}
+		return out
+	}
+	panic("no JSON for " + v.Type().String())
+}
+
+// fromJSON sets v to j, a value written by toJSON and read back by
+// json.Unmarshal
+func fromJSON(j interface{}, v reflect.Value) error {
+	switch v.Type() {
+	case pointType:
+		s, _ := j.(string)
+		p, err := zksigma.ECPointFromHex(zk, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(p))
+		return nil
+	case scalarType:
+		s, _ := j.(string)
+		b, err := hex.DecodeString(s)
+		if err != nil || len(b) != 32 {
+			return fmt.Errorf("%q is not a scalar", s)
+		}
+		v.Set(reflect.ValueOf(new(big.Int).SetBytes(b)))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		return fromJSON(j, v.Elem())
+	case reflect.Int:
+		n, ok := j.(float64)
+		if !ok {
+			return fmt.Errorf("%v is not a number", j)
+		}
+		v.SetInt(int64(n))
+		return nil
+	case reflect.Slice:
+		list, ok := j.([]interface{})
+		if !ok {
+			return fmt.Errorf("%v is not a list", j)
+		}
+		v.Set(reflect.MakeSlice(v.Type(), len(list), len(list)))
+		for i, e := range list {
+			if err := fromJSON(e, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+		fields, ok := j.(map[string]interface{})
+		if !ok || len(fields) != v.NumField() {
+			return fmt.Errorf("%v is not a %s", j, v.Type())
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if err := fromJSON(fields[v.Type().Field(i).Name], v.Field(i)); err != nil {
+				return fmt.Errorf("%s: %v", v.Type().Field(i).Name, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("no JSON for %s", v.Type())
+}
+
+// call calls function or method f, which returns a value and an error
+func call(f reflect.Value, args ...interface{}) (interface{}, error) {
+	in := make([]reflect.Value, len(args))
+	for i, a := range args {
+		in[i] = reflect.ValueOf(a)
+	}
+	out := f.Call(in)
+	err, _ := out[1].Interface().(error)
+	return out[0].Interface(), err
+}
+
+// check decodes b as a proof of case c, verifies it for stmt and returns its
+// encoding
+func (c vectorCase) check(b []byte, stmt interface{}) ([]byte, bool, error) {
+	proof, err := call(reflect.ValueOf(c.decode), b)
+	if err != nil {
+		return nil, false, err
+	}
+	ok, err := call(reflect.ValueOf(proof).MethodByName("VerifyStatement"), zk, reflect.ValueOf(stmt).Elem().Interface())
+	if err != nil || !ok.(bool) {
+		return nil, false, err
+	}
+	encoding, err := call(reflect.ValueOf(c.encode), proof)
+	if err != nil {
+		return nil, false, err
+	}
+	return encoding.([]byte), true, nil
+}
+
+func side(option string) zksigma.Side {
+	if option == "Right" {
+		return zksigma.Right
+	}
+	return zksigma.Left
+}
+
+// prove generates the proof of case c for stmt and wit from seed
+func (c vectorCase) prove(seed []byte, stmt, wit interface{}) ([]byte, error) {
+	proof, err := c.proveWith(newStream(seed), stmt, wit, side(c.option))
+	if err != nil {
+		return nil, err
+	}
+	b, err := call(reflect.ValueOf(c.encode), proof)
+	if err != nil {
+		return nil, err
+	}
+	return b.([]byte), nil
+}
+
+func generateVectors(t *testing.T) vectorFile {
+	cases := vectorCases(t)
+	names := make([]string, 0, len(cases))
+	for name := range cases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	file := vectorFile{Curve: "secp256k1", G: zk.G.ToHex(), H: zk.H.ToHex()}
+	for _, name := range names {
+		c := cases[name]
+		seed := sha256.Sum256([]byte("zksigma test vector seed " + name))
+		b, err := c.prove(seed[:], c.stmt, c.wit)
+		if err != nil {
+			t.Fatalf("%s failed to generate: %v\n", name, err)
+		}
+		file.Vectors = append(file.Vectors, vector{
+			Type:      name,
+			Seed:      hex.EncodeToString(seed[:]),
+			Statement: toJSON(reflect.ValueOf(c.stmt)),
+			Witness:   toJSON(reflect.ValueOf(c.wit)),
+			Option:    c.option,
+			Proof:     hex.EncodeToString(b),
+		})
+	}
+	return file
+}
+
+func TestVectors(t *testing.T) {
+	if *update {
+		out, err := json.MarshalIndent(generateVectors(t), "", "  ")
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if err := ioutil.WriteFile(vectorsFile, append(out, '\n'), 0644); err != nil {
+			t.Fatalf("%v\n", err)
+		}
+	}
+
+	in, err := ioutil.ReadFile(vectorsFile)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	var file vectorFile
+	if err := json.Unmarshal(in, &file); err != nil {
+		t.Fatalf("%s: %v\n", vectorsFile, err)
+	}
+	if file.G != zk.G.ToHex() || file.H != zk.H.ToHex() {
+		t.Fatalf("the vectors are for other generators\n")
+	}
+
+	cases := vectorCases(t)
+	if len(file.Vectors) != len(cases) {
+		t.Fatalf("%d vectors for %d proof types, run with -update\n", len(file.Vectors), len(cases))
+	}
+	for _, v := range file.Vectors {
+		c, ok := cases[v.Type]
+		if !ok {
+			t.Fatalf("vector for unknown type %s\n", v.Type)
+		}
+		stmt := reflect.New(reflect.TypeOf(c.stmt).Elem()).Interface()
+		wit := reflect.New(reflect.TypeOf(c.wit).Elem()).Interface()
+		if err := fromJSON(v.Statement, reflect.ValueOf(stmt)); err != nil {
+			t.Fatalf("statement of %s: %v\n", v.Type, err)
+		}
+		if err := fromJSON(v.Witness, reflect.ValueOf(wit)); err != nil {
+			t.Fatalf("witness of %s: %v\n", v.Type, err)
+		}
+		seed, err := hex.DecodeString(v.Seed)
+		if err != nil {
+			t.Fatalf("seed of %s: %v\n", v.Type, err)
+		}
+		b, err := hex.DecodeString(v.Proof)
+		if err != nil {
+			t.Fatalf("proof of %s: %v\n", v.Type, err)
+		}
+
+		// the same inputs give the same bytes
+		c.option = v.Option
+		regenerated, err := c.prove(seed, stmt, wit)
+		if err != nil {
+			t.Fatalf("%s failed to generate: %v\n", v.Type, err)
+		}
+		if !bytes.Equal(regenerated, b) {
+			t.Fatalf("%s does not regenerate from its vector, run with -update\n", v.Type)
+		}
+
+		encoding, ok, err := c.check(b, stmt)
+		if !ok || err != nil {
+			t.Fatalf("vector of %s failed to verify: %v\n", v.Type, err)
+		}
+		if !bytes.Equal(encoding, b) {
+			t.Fatalf("vector of %s does not round-trip\n", v.Type)
+		}
+
+		for i := range b {
+			mutated := append([]byte{}, b...)
+			mutated[i] ^= 0x01
+			if _, ok, _ := c.check(mutated, stmt); ok {
+				t.Fatalf("vector of %s verifies with byte %d changed\n", v.Type, i)
+			}
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	// the proofs without a statement round-trip too
+	product, _, _, _, err := zksigma.NewProductProof(zk, big.NewInt(3), big.NewInt(4), scalar("ra"), scalar("rb"), scalar("rc"))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	CM, r := zksigma.PedCommitR(zk, big.NewInt(7), scalar("r")), scalar("r")
+	decryption, _, err := zksigma.NewDecryptionProof(zk, CM, zk.Mult(zk.Mult(zk.H, scalar("sk")), r), scalar("sk"))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	rangeProof, _, err := zksigma.NewRangeProof(zk, big.NewInt(7))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	_, multiProduct, err := zksigma.NewMultiProductProof(zk, []*big.Int{big.NewInt(3), big.NewInt(4), big.NewInt(5)},
+		[]*big.Int{scalar("r1"), scalar("r2"), scalar("r3"), scalar("p2"), scalar("p3")})
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	bounded, err := zksigma.NewBoundedRangeProof(zk, CM, big.NewInt(7), r, big.NewInt(3), big.NewInt(10))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	multiRange, err := zksigma.NewMultiRangeProof(zk, []zksigma.ECPoint{CM, zksigma.PedCommitR(zk, big.NewInt(2), scalar("r2"))},
+		[]*big.Int{big.NewInt(7), big.NewInt(2)}, []*big.Int{r, scalar("r2")}, 4)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	bit, _, err := zksigma.NewBitProof(zk, big.NewInt(1), r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	proofs := map[string]struct{ proof, encode, decode interface{} }{
+		"BitProof":          {bit, EncodeBitProof, DecodeBitProof},
+		"ProductProof":      {product, EncodeProductProof, DecodeProductProof},
+		"MultiProductProof": {multiProduct, EncodeMultiProductProof, DecodeMultiProductProof},
+		"BoundedRangeProof": {bounded, EncodeBoundedRangeProof, DecodeBoundedRangeProof},
+		"MultiRangeProof":   {multiRange, EncodeMultiRangeProof, DecodeMultiRangeProof},
+		"DecryptionProof":   {decryption, EncodeDecryptionProof, DecodeDecryptionProof},
+		"RangeProof":        {rangeProof, EncodeRangeProof, DecodeRangeProof},
+	}
+	for name, p := range proofs {
+		b, err := call(reflect.ValueOf(p.encode), p.proof)
+		if err != nil {
+			t.Fatalf("%s failed to encode: %v\n", name, err)
+		}
+		q, err := call(reflect.ValueOf(p.decode), b)
+		if err != nil {
+			t.Fatalf("%s failed to decode: %v\n", name, err)
+		}
+		if !reflect.ValueOf(q).MethodByName("Equal").Call([]reflect.Value{reflect.ValueOf(p.proof)})[0].Bool() {
+			t.Fatalf("%s does not round-trip\n", name)
+		}
+	}
+
+	// nil proofs have no encoding
+	encoders := []interface{}{EncodeProductProof, EncodeMultiProductProof, EncodeBoundedRangeProof, EncodeMultiRangeProof,
+		EncodeDecryptionProof, EncodeRangeProof, EncodeBitProof}
+	for _, c := range vectorCases(t) {
+		encoders = append(encoders, c.encode)
+	}
+	for _, encode := range encoders {
+		f := reflect.ValueOf(encode)
+		if _, err := call(f, reflect.Zero(f.Type().In(0)).Interface()); !errors.Is(err, zksigma.ErrMalformedProof) {
+			t.Fatalf("%s of nil gives %v\n", f.Type(), err)
+		}
+	}
+
+	// nor do proofs with a scalar out of range
+	p, err := zksigma.NewSameValueProof(zk, CM, zksigma.PedCommitR(zk, big.NewInt(7), scalar("r2")), big.NewInt(7), r, scalar("r2"))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	p.S = new(big.Int).Set(zk.C.Params().N)
+	if _, err := EncodeSameValueProof(p); !errors.Is(err, zksigma.ErrMalformedProof) {
+		t.Fatalf("encoding a proof with S = N gives %v\n", err)
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	CM1 := zksigma.PedCommitR(zk, big.NewInt(7), scalar("r1"))
+	CM2 := zksigma.PedCommitR(zk, big.NewInt(7), scalar("r2"))
+	p, err := zksigma.NewSameValueProof(zk, CM1, CM2, big.NewInt(7), scalar("r1"), scalar("r2"))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	b, err := EncodeSameValueProof(p)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if len(b) != 1+33+32+32 {
+		t.Fatalf("SameValueProof encodes to %d bytes\n", len(b))
+	}
+
+	// b is the version byte, T, Challenge and S
+	P := zk.C.Params().P.Bytes()
+	N := zk.C.Params().N.Bytes()
+	mutations := map[string]struct {
+		mutate func(b []byte) []byte
+		err    error
+	}{
+		"trailing byte":  {func(b []byte) []byte { return append(b, 0) }, zksigma.ErrMalformedProof},
+		"truncated":      {func(b []byte) []byte { return b[:len(b)-1] }, zksigma.ErrMalformedProof},
+		"empty":          {func(b []byte) []byte { return nil }, zksigma.ErrMalformedProof},
+		"version":        {func(b []byte) []byte { b[0] = 2; return b }, zksigma.ErrMalformedProof},
+		"uncompressed":   {func(b []byte) []byte { b[1] = 4; return b }, nil},
+		"hybrid":         {func(b []byte) []byte { b[1] = 6; return b }, nil},
+		"x is p":         {func(b []byte) []byte { copy(b[2:34], P); return b }, nil},
+		"challenge is n": {func(b []byte) []byte { copy(b[34:66], N); return b }, zksigma.ErrNonCanonicalScalar},
+		"s is n":         {func(b []byte) []byte { copy(b[66:98], N); return b }, zksigma.ErrNonCanonicalScalar},
+	}
+	for name, m := range mutations {
+		_, err := DecodeSameValueProof(m.mutate(append([]byte{}, b...)))
+		if err == nil || (m.err != nil && !errors.Is(err, m.err)) {
+			t.Fatalf("decoding with %s gives %v\n", name, err)
+		}
+	}
+}
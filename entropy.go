@@ -0,0 +1,101 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+)
+
+// EntropySource is where the random values of proofs, commitments and keys
+// come from, crypto/rand.Reader unless SetEntropySource installs another one.
+// The constructors taking an io.Reader read from that instead, or from the
+// EntropySource if it is nil. A Read that fails makes the constructor fail and
+// return no proof, and every Sigma protocol in a proof draws all its nonces at
+// once before it uses any of them, so none is ever used half initialized. A
+// source has to be safe for concurrent use, like crypto/rand.Reader, since
+// proofs are generated from several goroutines at once, e.g. by ProveAll.
+type EntropySource interface {
+	Read(b []byte) (n int, err error)
+}
+
+var (
+	entropyMu sync.RWMutex
+	source    EntropySource = rand.Reader
+)
+
+// SetEntropySource makes the package read its random values from src and
+// returns the source it used before. A nil src restores crypto/rand.Reader.
+// It is meant for startup, e.g. to plug in a hardware generator, and for
+// tests; proofs generated while it is called may use either source.
+func SetEntropySource(src EntropySource) EntropySource {
+	if src == nil {
+		src = rand.Reader
+	}
+	entropyMu.Lock()
+	defer entropyMu.Unlock()
+	old := source
+	source = src
+	return old
+}
+
+// entropy returns the EntropySource set with SetEntropySource
+func entropy() io.Reader {
+	entropyMu.RLock()
+	defer entropyMu.RUnlock()
+	return source
+}
+
+// randScalars returns n uniform scalars in [0, N) read from rnd, or the error
+// of rnd and no scalars if one of them can not be read. The scalars are read
+// one after the other like with rand.Int, so a seeded rnd gives the same
+// scalars as before. A nil rnd is the EntropySource.
+func (zkpcp ZKPCurveParams) randScalars(rnd io.Reader, n int) ([]*big.Int, error) {
+	if rnd == nil {
+		rnd = entropy()
+	}
+	ss := make([]*big.Int, n)
+	for i := range ss {
+		s, err := rand.Int(rnd, zkpcp.C.Params().N)
+		if err != nil {
+			return nil, err
+		}
+		ss[i] = s
+	}
+	return ss, nil
+}
+
+// selfTestScalars is how many scalars SelfTest draws
+const selfTestScalars = 8
+
+// SelfTest draws a few scalars from the EntropySource and checks that none is
+// zero and no two are the same, which a working source of 256 bit scalars
+// fails with negligible probability. Call it at startup to fail fast on a
+// platform whose entropy source is broken rather than on the first proof.
+func SelfTest() error {
+	ss, err := ZKCurve.randScalars(entropy(), selfTestScalars)
+	if err != nil {
+		return fmt.Errorf("zksigma: entropy source fails: %w", err)
+	}
+	for i, s := range ss {
+		if s.Sign() == 0 {
+			return fmt.Errorf("zksigma: entropy source gives a zero scalar")
+		}
+		for _, prev := range ss[:i] {
+			if s.Cmp(prev) == 0 {
+				return fmt.Errorf("zksigma: entropy source gives the same scalar twice")
+			}
+		}
+	}
+	return nil
+}
+
+// randScalar is randScalars for a single scalar
+func (zkpcp ZKPCurveParams) randScalar(rnd io.Reader) (*big.Int, error) {
+	ss, err := zkpcp.randScalars(rnd, 1)
+	if err != nil {
+		return nil, err
+	}
+	return ss[0], nil
+}
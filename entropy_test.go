@@ -0,0 +1,300 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+var errEntropy = errors.New("entropy source is broken")
+
+// countingSource reads from crypto/rand and counts the scalars read, each one
+// 32 bytes on secp256k1
+type countingSource struct {
+	bytes int
+}
+
+func (s *countingSource) Read(b []byte) (int, error) {
+	s.bytes += len(b)
+	return rand.Read(b)
+}
+
+// failingSource reads left scalars from crypto/rand and then fails
+type failingSource struct {
+	left int
+}
+
+func (s *failingSource) Read(b []byte) (int, error) {
+	if s.left == 0 {
+		return 0, errEntropy
+	}
+	s.left--
+	return rand.Read(b)
+}
+
+// constantSource returns b over and over, which gives the same scalar on every
+// read
+type constantSource struct {
+	b byte
+}
+
+func (s constantSource) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = s.b
+	}
+	return len(b), nil
+}
+
+// entropyConstructor is a constructor that draws the given number of scalars
+// from the EntropySource. prove returns the proof or other value the
+// constructor returns, which has to be nil if it fails.
+type entropyConstructor struct {
+	name    string
+	scalars int
+	prove   func() (interface{}, error)
+}
+
+// entropyConstructors returns all constructors reading from the
+// EntropySource, with valid inputs
+func entropyConstructors(t *testing.T) []entropyConstructor {
+	zkpcp := TestCurve
+	value := big.NewInt(5)
+	r, r2 := scalarFromSeed("entropy r"), scalarFromSeed("entropy r2")
+	sk, sk2 := scalarFromSeed("entropy sk"), scalarFromSeed("entropy sk2")
+	PK, PK2 := zkpcp.Mult(zkpcp.H, sk), zkpcp.Mult(zkpcp.H, sk2)
+	CM, CM2 := PedCommitR(zkpcp, value, r), PedCommitR(zkpcp, big.NewInt(6), r2)
+	CMTok := zkpcp.Mult(PK, r)
+	CMPrime := zkpcp.Add(CM, zkpcp.Mult(zkpcp.H, r2))
+	newCMTok, err := RotateToken(zkpcp, CMTok, sk, sk2)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	vecCM, err := VectorPedCommitR(zkpcp, []*big.Int{value, r2}, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	_, provable := composedStatements(t)
+
+	return []entropyConstructor{
+		{"PedCommit", 1, func() (interface{}, error) {
+			_, r, err := PedCommit(zkpcp, value)
+			return r, err
+		}},
+		{"VectorPedCommit", 1, func() (interface{}, error) {
+			_, r, err := VectorPedCommit(zkpcp, []*big.Int{value, r2})
+			return r, err
+		}},
+		{"RerandomizeCommitment", 1, func() (interface{}, error) {
+			_, deltaR, err := RerandomizeCommitment(zkpcp, CM)
+			return deltaR, err
+		}},
+		{"ABCProof", 10, func() (interface{}, error) {
+			return NewABCProof(zkpcp, CM, CMTok, value, sk, Right)
+		}},
+		{"ABCProofBatch", 20, func() (interface{}, error) {
+			return NewABCProofBatch(zkpcp, []ECPoint{CM, CM}, []ECPoint{CMTok, CMTok}, []*big.Int{value, value}, sk)
+		}},
+		{"AggregateRangeProof", 7 + 8*3, func() (interface{}, error) {
+			return NewAggregateRangeProof(zkpcp, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(6)}, []*big.Int{r, r2}, 8)
+		}},
+		{"AuditableTransfer", 1 + 10 + 2 + 1 + (TransferAmountBits - 1) + 3*TransferAmountBits, func() (interface{}, error) {
+			return NewAuditableTransfer(zkpcp, sk, PK2, PK, value)
+		}},
+		{"BalanceProof", 1, func() (interface{}, error) {
+			return NewBalanceProof(zkpcp, CM, []ECPoint{CM}, []int{1}, r, []*big.Int{r})
+		}},
+		{"BitProof", 3, func() (interface{}, error) {
+			p, _, err := NewBitProof(zkpcp, big.NewInt(1), r)
+			return p, err
+		}},
+		{"BitRangeProof", 7 + 8*3, func() (interface{}, error) {
+			return NewBitRangeProof(zkpcp, CM, value, r, 8)
+		}},
+		{"ComparisonProof", 7 + 8*3, func() (interface{}, error) {
+			return NewComparisonProof(zkpcp, CM2, CM, big.NewInt(6), value, r2, r, 8)
+		}},
+		{"BoundedRangeProof", 2 * (2 + 3*3), func() (interface{}, error) {
+			return NewBoundedRangeProof(zkpcp, CM, value, r, big.NewInt(1), big.NewInt(6))
+		}},
+		{"ComposedProof", 5 + 3 + 2 + 2 + 3 + 1 + 1, func() (interface{}, error) {
+			return NewComposedProof(zkpcp, provable...)
+		}},
+		{"ConsistencyProof", 2, func() (interface{}, error) {
+			return NewConsistencyProof(zkpcp, CM, CMTok, PK, value, r)
+		}},
+		{"DecryptionProof", 1, func() (interface{}, error) {
+			proof, _, err := NewDecryptionProof(zkpcp, CM, CMTok, sk)
+			return proof, err
+		}},
+		{"DisjunctiveProof", 3, func() (interface{}, error) {
+			return NewDisjunctiveProof(zkpcp, zkpcp.H, PK, zkpcp.G, CM, sk, Left)
+		}},
+		{"EntryProof", 2 + 3*entryRangeBits + 3, func() (interface{}, error) {
+			return NewEntryProof(zkpcp, value, r, nil, PK, false)
+		}},
+		{"EquivOrZeroProof", 3, func() (interface{}, error) {
+			return NewEquivOrZeroProof(zkpcp, CM, CM, r, r, Right)
+		}},
+		{"EquivalenceProof", 1, func() (interface{}, error) {
+			return NewEquivalenceProof(zkpcp, zkpcp.H, PK, CM, zkpcp.Mult(CM, sk), sk)
+		}},
+		{"GSPFSProof", 1, func() (interface{}, error) {
+			return NewGSPFSProof(zkpcp, zkpcp.Mult(zkpcp.G, sk), sk)
+		}},
+		{"InequalityProof", 4, func() (interface{}, error) {
+			return NewInequalityProof(zkpcp, CM, CM2, value, big.NewInt(6), r, r2)
+		}},
+		{"KeyRotationProof", 1, func() (interface{}, error) {
+			return NewKeyRotationProof(zkpcp, CMTok, newCMTok, PK, PK2, sk, sk2)
+		}},
+		{"NonZeroProof", 4, func() (interface{}, error) {
+			return NewNonZeroProof(zkpcp, CM, value, r)
+		}},
+		{"OneOfManyProof", 3, func() (interface{}, error) {
+			return NewOneOfManyProof(zkpcp, []ECPoint{zkpcp.G, zkpcp.H}, []ECPoint{CM, PK}, 1, sk)
+		}},
+		{"OpenProof", 2, func() (interface{}, error) {
+			return NewOpenProof(zkpcp, CM, value, r)
+		}},
+		{"OpenToValueProof", 1, func() (interface{}, error) {
+			return NewOpenToValueProof(zkpcp, CM, value, r)
+		}},
+		{"ProductProof", 5, func() (interface{}, error) {
+			proof, _, _, _, err := NewProductProof(zkpcp, value, big.NewInt(6), r, r2, sk)
+			return proof, err
+		}},
+		{"MultiProductProof", 5 * 2, func() (interface{}, error) {
+			_, proof, err := NewMultiProductProof(zkpcp, []*big.Int{value, big.NewInt(6), big.NewInt(7)}, []*big.Int{r, r2, sk, r2, r})
+			return proof, err
+		}},
+		{"MultiRangeProof", 2 * (7 + 8*3), func() (interface{}, error) {
+			return NewMultiRangeProof(zkpcp, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(6)}, []*big.Int{r, r2}, 8)
+		}},
+		{"RangeProof", 3 * 40, func() (interface{}, error) {
+			proof, _, err := NewRangeProof(zkpcp, value)
+			return proof, err
+		}},
+		{"RerandomizationProof", 1, func() (interface{}, error) {
+			return NewRerandomizationProof(zkpcp, CM, CMPrime, r2)
+		}},
+		{"RerandomizeWithProof", 2, func() (interface{}, error) {
+			_, _, proof, err := RerandomizeWithProof(zkpcp, CM)
+			return proof, err
+		}},
+		{"SameBlindingProof", 1, func() (interface{}, error) {
+			return NewSameBlindingProof(zkpcp, CMTok, zkpcp.Mult(PK2, r), PK, PK2, r)
+		}},
+		{"SameValueProof", 1, func() (interface{}, error) {
+			return NewSameValueProof(zkpcp, CM, CM, value, r, r)
+		}},
+		{"SetMembershipProof", 5, func() (interface{}, error) {
+			return NewSetMembershipProof(zkpcp, CM, value, r, coins(1, 5, 10))
+		}},
+		{"ShuffleProof", 4*2 + 4, func() (interface{}, error) {
+			return NewShuffleProof(zkpcp, []ECPoint{CM, CM2}, []ECPoint{zkpcp.Add(CM2, zkpcp.Mult(zkpcp.H, r)), CMPrime}, []int{1, 0}, []*big.Int{r, r2})
+		}},
+		{"SumProof", 1, func() (interface{}, error) {
+			return NewSumProof(zkpcp, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(6)}, []*big.Int{r, r2}, big.NewInt(11))
+		}},
+		{"SumZeroProof", 1, func() (interface{}, error) {
+			return NewSumZeroProof(zkpcp, []ECPoint{CM, zkpcp.Neg(CM)}, big.NewInt(0))
+		}},
+		{"VectorOpenProof", 3, func() (interface{}, error) {
+			return NewVectorOpenProof(zkpcp, vecCM, []*big.Int{value, r2}, r)
+		}},
+	}
+}
+
+// isNil returns true if v is nil or a nil pointer or slice
+func isNil(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return v == nil || (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Slice) && rv.IsNil()
+}
+
+func TestEntropySourceCounts(t *testing.T) {
+	defer SetEntropySource(nil)
+	for _, c := range entropyConstructors(t) {
+		src := &countingSource{}
+		SetEntropySource(src)
+		if _, err := c.prove(); err != nil {
+			t.Fatalf("%s fails with a counting source: %v\n", c.name, err)
+		}
+		if src.bytes != 32*c.scalars {
+			t.Fatalf("%s draws %d bytes, expected %d scalars\n", c.name, src.bytes, c.scalars)
+		}
+	}
+}
+
+func TestEntropySourceFailure(t *testing.T) {
+	defer SetEntropySource(nil)
+	for _, c := range entropyConstructors(t) {
+		// the source fails on the first scalar, the last one and every
+		// one in between
+		for left := 0; left < c.scalars; left++ {
+			SetEntropySource(&failingSource{left})
+			v, err := c.prove()
+			if !errors.Is(err, errEntropy) {
+				t.Fatalf("%s with a source failing after %d scalars gives %v\n", c.name, left, err)
+			}
+			if !isNil(v) {
+				t.Fatalf("%s with a source failing after %d scalars returns %v\n", c.name, left, v)
+			}
+		}
+	}
+
+	// the constructors taking a reader do not read from the EntropySource
+	SetEntropySource(&failingSource{})
+	CM := PedCommitR(TestCurve, big.NewInt(5), scalarFromSeed("entropy r"))
+	if _, err := NewOpenProofWithRand(TestCurve, rand.Reader, CM, big.NewInt(5), scalarFromSeed("entropy r")); err != nil {
+		t.Fatalf("NewOpenProofWithRand reads from the EntropySource: %v\n", err)
+	}
+
+	// but a nil reader is the EntropySource
+	if _, err := NewOpenProofWithRand(TestCurve, nil, CM, big.NewInt(5), scalarFromSeed("entropy r")); !errors.Is(err, errEntropy) {
+		t.Fatalf("NewOpenProofWithRand with a nil reader gives %v\n", err)
+	}
+	src := &countingSource{}
+	SetEntropySource(src)
+	if _, err := NewABCProofWithRand(TestCurve, nil, CM, TestCurve.Mult(TestCurve.Mult(TestCurve.H, big.NewInt(3)), scalarFromSeed("entropy r")),
+		big.NewInt(5), big.NewInt(3), Right); err != nil || src.bytes == 0 {
+		t.Fatalf("NewABCProofWithRand with a nil reader gives %v after %d bytes of the EntropySource\n", err, src.bytes)
+	}
+}
+
+func TestSetEntropySource(t *testing.T) {
+	src := &countingSource{}
+	if old := SetEntropySource(src); old != rand.Reader {
+		t.Fatalf("the default EntropySource is %v\n", old)
+	}
+	if old := SetEntropySource(nil); old != src {
+		t.Fatalf("SetEntropySource returns %v, expected the source set before\n", old)
+	}
+	if entropy() != rand.Reader {
+		t.Fatalf("SetEntropySource(nil) does not restore crypto/rand\n")
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	defer SetEntropySource(nil)
+	if err := SelfTest(); err != nil {
+		t.Fatalf("SelfTest fails with crypto/rand: %v\n", err)
+	}
+
+	broken := map[string]EntropySource{
+		"failing":  &failingSource{selfTestScalars - 1},
+		"zero":     constantSource{0},
+		"constant": constantSource{7},
+	}
+	for name, src := range broken {
+		SetEntropySource(src)
+		if err := SelfTest(); err == nil {
+			t.Fatalf("SelfTest passes with a %s source\n", name)
+		}
+	}
+	SetEntropySource(&failingSource{})
+	if err := SelfTest(); !errors.Is(err, errEntropy) {
+		t.Fatalf("SelfTest with a failing source gives %v\n", err)
+	}
+}
@@ -0,0 +1,332 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/mit-dci/zksigma/wire"
+)
+
+// entryRangeBits is the size of the values RangeProof proves, the value of a
+// non-sender's EntryProof is in [0, 2^entryRangeBits)
+const entryRangeBits = 40
+
+// EntryProof bundles the commitment and token of one cell of a zkLedger row,
+// the entry of the column of pk, with the proofs every entry carries:
+//
+//  CM    = value * G + rH      CMTok = r * pk
+//  Range.ProofAggregate = v' * G + r'H with v' in [0, 2^40)
+//
+// Consistency shows that CMTok uses the randomness of CM, so the owner of pk
+// can open it. Assets is the proof of assets of zkLedger: a DisjunctiveProof
+// that either the prover knows the secret key of pk = skH, so it is the
+// sender, or that CM - Range.ProofAggregate is a multiple of H, so CM commits
+// to the value the RangeProof is for. A receiver or a bystander thus has a
+// value in [0, 2^40), 0 for a bystander, and only the owner of pk can take
+// value out of its column. The sender proves the range of the amount it
+// spends, so its entry looks like any other and the verifier does not learn
+// who sent.
+//
+// Verify them all at once, for the same CM and CMTok, with Verify.
+type EntryProof struct {
+	CM    ECPoint // value * G + rH
+	CMTok ECPoint // r * pk
+
+	Consistency *ConsistencyProof // CMTok uses the randomness of CM
+	Assets      *DisjunctiveProof // pk = skH or CM - Range.ProofAggregate = xH
+	Range       *RangeProof       // Range.ProofAggregate commits to a value in [0, 2^40)
+}
+
+// NewEntryProof generates the commitment, token and proofs of the entry of
+// value with randomness r in the column of pk. value is reduced mod N like for
+// PedCommit, so the sender's entry of a payment v is N - v. If isSender is
+// true sk has to be the secret key of pk and the amount spent, N - value, in
+// [0, 2^40). Otherwise sk is not used and may be nil, and value has to be in
+// [0, 2^40).
+func NewEntryProof(zkpcp ZKPCurveParams, value, r, sk *big.Int, pk ECPoint, isSender bool) (*EntryProof, error) {
+	return NewEntryProofWithRand(zkpcp, entropy(), value, r, sk, pk, isSender)
+}
+
+// NewEntryProofWithRand is the same as NewEntryProof, but reads its random
+// values from rnd instead of the EntropySource
+func NewEntryProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	value, r, sk *big.Int, pk ECPoint, isSender bool) (*EntryProof, error) {
+
+	if err := zkpcp.checkScalars("NewEntryProof", "value, r", value, r); err != nil {
+		return nil, err
+	}
+	if !zkpcp.validPoints(pk) {
+		return nil, curveError("NewEntryProof")
+	}
+	N := zkpcp.C.Params().N
+
+	// the sender proves the range of what it spends, everybody else the range
+	// of the value of CM
+	rangeValue, name := value, "value"
+	if isSender {
+		if err := zkpcp.checkScalars("NewEntryProof", "sk", sk); err != nil {
+			return nil, err
+		}
+		if sk.Sign() == 0 {
+			return nil, rangeError("NewEntryProof", "sk is zero")
+		}
+		if !zkpcp.Mult(zkpcp.H, sk).Equal(pk) {
+			return nil, inputError("NewEntryProof", "sk is not the secret key of pk")
+		}
+		rangeValue, name = new(big.Int).Mod(new(big.Int).Neg(value), N), "amount spent"
+	}
+	if rangeValue.BitLen() > entryRangeBits {
+		return nil, rangeError("NewEntryProof", fmt.Sprintf("%s does not fit in %d bits", name, entryRangeBits))
+	}
+
+	entry := &EntryProof{
+		CM:    PedCommitR(zkpcp, value, r),
+		CMTok: zkpcp.Mult(pk, r),
+	}
+
+	var err error
+	if entry.Consistency, err = NewConsistencyProofWithRand(zkpcp, rnd, entry.CM, entry.CMTok, pk, value, r); err != nil {
+		return nil, subProofError("NewEntryProof", fmt.Sprintf("ConsistencyProof: %v", err), err)
+	}
+	var rangeR *big.Int
+	if entry.Range, rangeR, err = NewRangeProofWithRand(zkpcp, rnd, rangeValue); err != nil {
+		return nil, subProofError("NewEntryProof", fmt.Sprintf("RangeProof: %v", err), err)
+	}
+
+	// a receiver knows the discrete log r - r' of CM - Range.ProofAggregate
+	// relative to H, the sender the one of pk
+	x, side := new(big.Int).Mod(new(big.Int).Sub(r, rangeR), N), Right
+	if isSender {
+		x, side = sk, Left
+	}
+	diff := zkpcp.Sub(entry.CM, entry.Range.ProofAggregate)
+	if entry.Assets, err = NewDisjunctiveProofWithRand(zkpcp, rnd, zkpcp.H, pk, zkpcp.H, diff, x, side); err != nil {
+		return nil, subProofError("NewEntryProof", fmt.Sprintf("assets DisjunctiveProof: %v", err), err)
+	}
+
+	return entry, nil
+}
+
+// Verify checks every proof of EntryProof entry for the commitment CM and
+// token CMTok of the column of pk, in this order:
+//
+//  commitment   entry is for CM and CMTok
+//  consistency  Consistency for CM, CMTok and pk
+//  range        Range for Range.ProofAggregate
+//  assets       Assets for pk and CM - Range.ProofAggregate
+//
+// It returns true with a nil error, or false with an error for the first stage
+// that failed. The error message starts with the name of the stage, and the
+// error wraps the one of the failed proof, so it keeps its Code.
+func (entry *EntryProof) Verify(zkpcp ZKPCurveParams, CM, CMTok, pk ECPoint) (bool, error) {
+	if entry == nil {
+		return false, malformedError("EntryProof.Verify", "passed proof is nil")
+	}
+	if entry.Consistency == nil || entry.Assets == nil || entry.Range == nil {
+		return false, malformedError("EntryProof.Verify", "proof is missing a component")
+	}
+	if !zkpcp.validPoints(CM, CMTok, pk) || !zkpcp.onCurve(entry.CM, entry.CMTok) {
+		return false, curveError("EntryProof.Verify")
+	}
+
+	if !pointsEqual(entry.CM, CM) {
+		return false, equationError("EntryProof.Verify", 1, "commitment: proof is for another CM", entry.CM, CM)
+	}
+	if !pointsEqual(entry.CMTok, CMTok) {
+		return false, equationError("EntryProof.Verify", 2, "commitment: proof is for another CMTok", entry.CMTok, CMTok)
+	}
+
+	stages := []struct {
+		name   string
+		verify func() (bool, error)
+	}{
+		{"consistency", func() (bool, error) {
+			return entry.Consistency.Verify(zkpcp, CM, CMTok, pk)
+		}},
+		{"range", func() (bool, error) {
+			return entry.Range.Verify(zkpcp, entry.Range.ProofAggregate)
+		}},
+		{"assets", func() (bool, error) {
+			return entry.Assets.Verify(zkpcp, zkpcp.H, pk, zkpcp.H, zkpcp.Sub(CM, entry.Range.ProofAggregate))
+		}},
+	}
+	for _, s := range stages {
+		if ok, err := s.verify(); !ok {
+			return false, subProofError("EntryProof.Verify", fmt.Sprintf("%s: %v", s.name, err), err)
+		}
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are CM, CMTok and pk like for Verify
+func (entry *EntryProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("EntryProof.VerifyArgs", args)
+	CM, CMTok, pk := a.point(), a.point(), a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return entry.Verify(zkpcp, CM, CMTok, pk)
+}
+
+// String returns a labeled dump of the fields of entry for debugging
+func (entry *EntryProof) String() string {
+	if entry == nil {
+		return "EntryProof(nil)"
+	}
+	return proofString("EntryProof", "CM", entry.CM, "CMTok", entry.CMTok,
+		"Consistency", entry.Consistency, "Assets", entry.Assets, "Range", entry.Range)
+}
+
+// Bytes returns a byte slice with a serialized representation of EntryProof
+// entry: CM and CMTok followed by the encoding of each proof, prefixed by its
+// length
+func (entry *EntryProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, entry.CM)
+	writeFixedPoint(&buf, entry.CMTok)
+	wire.WriteVarBytes(&buf, entry.Consistency.Bytes())
+	wire.WriteVarBytes(&buf, entry.Assets.Bytes())
+	wire.WriteVarBytes(&buf, entry.Range.Bytes())
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of EntryProof entry
+func (entry *EntryProof) Size() int {
+	return len(entry.Bytes())
+}
+
+// Clone returns a deep copy of EntryProof entry that shares no big.Int with it
+func (entry *EntryProof) Clone() *EntryProof {
+	if entry == nil {
+		return nil
+	}
+	return &EntryProof{
+		CM:          clonePoint(entry.CM),
+		CMTok:       clonePoint(entry.CMTok),
+		Consistency: entry.Consistency.Clone(),
+		Assets:      entry.Assets.Clone(),
+		Range:       entry.Range.Clone(),
+	}
+}
+
+// Equal returns true if EntryProof entry and other have the same values in all
+// their fields, the ones of their proofs included. It is not constant time.
+func (entry *EntryProof) Equal(other *EntryProof) bool {
+	if entry == nil || other == nil {
+		return entry == other
+	}
+	return samePoint(entry.CM, other.CM) && samePoint(entry.CMTok, other.CMTok) &&
+		entry.Consistency.Equal(other.Consistency) &&
+		entry.Assets.Equal(other.Assets) &&
+		entry.Range.Equal(other.Range)
+}
+
+// NewEntryProofFromBytes returns an EntryProof generated from the
+// deserialization of byte slice b
+func NewEntryProofFromBytes(b []byte) (*EntryProof, error) {
+	if len(b) == 0 {
+		return nil, malformedError("EntryProofFromBytes", "truncated buffer")
+	}
+	if b[0] != proofEncodingVersion {
+		return nil, malformedError("EntryProofFromBytes", fmt.Sprintf("unsupported encoding version %d", b[0]))
+	}
+	buf := bytes.NewBuffer(b[1:])
+
+	entry := new(EntryProof)
+	var err error
+	if entry.CM, err = readFixedPoint(buf, "EntryProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if entry.CMTok, err = readFixedPoint(buf, "EntryProofFromBytes"); err != nil {
+		return nil, err
+	}
+
+	// next reads the encoding of the next proof
+	next := func(name string) ([]byte, error) {
+		pb, err := wire.ReadVarBytes(buf, uint32(buf.Len()), name)
+		if err != nil {
+			return nil, malformedError("EntryProofFromBytes", fmt.Sprintf("truncated buffer: %v", err))
+		}
+		return pb, nil
+	}
+	pb, err := next("Consistency")
+	if err != nil {
+		return nil, err
+	}
+	if entry.Consistency, err = NewConsistencyProofFromBytes(pb); err != nil {
+		return nil, wrapError("EntryProofFromBytes", fmt.Sprintf("Consistency: %v", err), err)
+	}
+	if pb, err = next("Assets"); err != nil {
+		return nil, err
+	}
+	if entry.Assets, err = NewDisjunctiveProofFromBytes(pb); err != nil {
+		return nil, wrapError("EntryProofFromBytes", fmt.Sprintf("Assets: %v", err), err)
+	}
+	if pb, err = next("Range"); err != nil {
+		return nil, err
+	}
+	if entry.Range, err = NewRangeProofFromBytes(pb); err != nil {
+		return nil, wrapError("EntryProofFromBytes", fmt.Sprintf("Range: %v", err), err)
+	}
+	if buf.Len() != 0 {
+		return nil, malformedError("EntryProofFromBytes", fmt.Sprintf("%d trailing bytes", buf.Len()))
+	}
+
+	return entry, nil
+}
+
+type entryProofJSON struct {
+	CM          string            `json:"cm"`
+	CMTok       string            `json:"cmTok"`
+	Consistency *ConsistencyProof `json:"consistency"`
+	Assets      *DisjunctiveProof `json:"assets"`
+	Range       *RangeProof       `json:"range"`
+}
+
+// MarshalJSON encodes EntryProof entry with points as compressed hex and its
+// proofs with their own MarshalJSON
+func (entry *EntryProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(entryProofJSON{
+		CM:          pointToHex(entry.CM),
+		CMTok:       pointToHex(entry.CMTok),
+		Consistency: entry.Consistency,
+		Assets:      entry.Assets,
+		Range:       entry.Range,
+	})
+}
+
+// UnmarshalJSON decodes an EntryProof encoded with MarshalJSON. It fails if a
+// point is not on the curve, a scalar is not in [0, N) or a nested proof is
+// missing
+func (entry *EntryProof) UnmarshalJSON(b []byte) error {
+	var aux entryProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	if aux.Consistency == nil {
+		return malformedError("UnmarshalJSON", "consistency is missing")
+	}
+	if aux.Assets == nil {
+		return malformedError("UnmarshalJSON", "assets is missing")
+	}
+	if aux.Range == nil {
+		return malformedError("UnmarshalJSON", "range is missing")
+	}
+	var err error
+	p := EntryProof{Consistency: aux.Consistency, Assets: aux.Assets, Range: aux.Range}
+	if p.CM, err = pointFromHex(aux.CM, "cm"); err != nil {
+		return err
+	}
+	if p.CMTok, err = pointFromHex(aux.CMTok, "cmTok"); err != nil {
+		return err
+	}
+	*entry = p
+	return nil
+}
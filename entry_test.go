@@ -0,0 +1,202 @@
+package zksigma
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// entryRow returns the entries of a zkLedger row paying amount from the
+// sender to the receiver of k, with the auditor as a bystander, and their
+// commitment randomness
+func entryRow(t *testing.T, k transferKeys, amount int64) ([]*EntryProof, []*big.Int) {
+	N := TestCurve.C.Params().N
+	values := []*big.Int{new(big.Int).Mod(big.NewInt(-amount), N), big.NewInt(amount), big.NewInt(0)}
+	pks := []ECPoint{k.senderPK, k.receiverPK, k.auditorPK}
+	entries, rs := make([]*EntryProof, len(values)), make([]*big.Int, len(values))
+	for i, v := range values {
+		rs[i] = scalarFromSeed(fmt.Sprintf("entry r%d", i))
+		var err error
+		if entries[i], err = NewEntryProof(TestCurve, v, rs[i], k.sk[0], pks[i], i == 0); err != nil {
+			t.Fatalf("NewEntryProof failed for entry %d of %d: %v\n", i, amount, err)
+		}
+	}
+	return entries, rs
+}
+
+func TestEntryProof(t *testing.T) {
+	k := newTransferKeys(t)
+	pks := []ECPoint{k.senderPK, k.receiverPK, k.auditorPK}
+	for _, amount := range []int64{0, 1, 1000, 1<<40 - 1} {
+		entries, _ := entryRow(t, k, amount)
+		sum := Zero
+		for i, e := range entries {
+			if ok, err := e.Verify(TestCurve, e.CM, e.CMTok, pks[i]); !ok || err != nil {
+				t.Fatalf("EntryProof %d of %d did not verify: %v\n", i, amount, err)
+			}
+			sum = TestCurve.Add(sum, e.CM)
+
+			decoded, err := NewEntryProofFromBytes(e.Bytes())
+			if err != nil {
+				t.Fatalf("EntryProof %d of %d failed to decode: %v\n", i, amount, err)
+			}
+			if ok, err := decoded.Verify(TestCurve, e.CM, e.CMTok, pks[i]); !ok || err != nil {
+				t.Fatalf("decoded EntryProof %d of %d did not verify: %v\n", i, amount, err)
+			}
+			if !decoded.Equal(e) || !e.Clone().Equal(e) || e.Size() != len(e.Bytes()) {
+				t.Fatalf("EntryProof %d of %d does not round-trip\n", i, amount)
+			}
+			if ok, err := e.VerifyArgs(TestCurve, e.CM, e.CMTok, pks[i]); !ok || err != nil {
+				t.Fatalf("EntryProof %d of %d did not verify through Verifiable: %v\n", i, amount, err)
+			}
+			js, err := json.Marshal(e)
+			if err != nil {
+				t.Fatalf("EntryProof %d of %d failed to marshal: %v\n", i, amount, err)
+			}
+			decoded = new(EntryProof)
+			if err := json.Unmarshal(js, decoded); err != nil {
+				t.Fatalf("EntryProof %d of %d failed to unmarshal: %v\n", i, amount, err)
+			}
+			if ok, err := decoded.Verify(TestCurve, e.CM, e.CMTok, pks[i]); !ok || err != nil {
+				t.Fatalf("EntryProof %d of %d did not verify after JSON round trip: %v\n", i, amount, err)
+			}
+		}
+
+		// the owner of a column can open its entry, and the row balances
+		// with randomness adding up to 0
+		amountG := TestCurve.Mult(TestCurve.G, big.NewInt(amount))
+		if !opened(entries[1].CM, entries[1].CMTok, k.sk[1]).Equal(amountG) {
+			t.Fatalf("receiver can not open its entry of %d\n", amount)
+		}
+		if !opened(entries[0].CM, entries[0].CMTok, k.sk[0]).Equal(TestCurve.Neg(amountG)) {
+			t.Fatalf("sender entry of %d does not open to -%d\n", amount, amount)
+		}
+		if !TestCurve.Sub(sum, TestCurve.Mult(TestCurve.H, new(big.Int).Add(
+			scalarFromSeed("entry r0"), new(big.Int).Add(scalarFromSeed("entry r1"), scalarFromSeed("entry r2"))))).IsZero() {
+			t.Fatalf("row of %d does not balance\n", amount)
+		}
+	}
+}
+
+// entryStage returns the stage of EntryProof.Verify that err is for
+func entryStage(err error) string {
+	msg := strings.TrimPrefix(err.Error(), "EntryProof.Verify - ")
+	return msg[:strings.Index(msg, ":")]
+}
+
+func TestEntryProofTampered(t *testing.T) {
+	k := newTransferKeys(t)
+	entries, _ := entryRow(t, k, 25)
+	other, _ := entryRow(t, k, 26)
+	sender, receiver := entries[0], entries[1]
+
+	cases := []struct {
+		name      string
+		entry     *EntryProof
+		tamper    func(e *EntryProof)
+		CM, CMTok ECPoint
+		pk        ECPoint
+		stage     string
+	}{
+		{"commitment", receiver, func(e *EntryProof) {}, TestCurve.Add(receiver.CM, TestCurve.G), receiver.CMTok, k.receiverPK, "commitment"},
+		{"token", receiver, func(e *EntryProof) {}, receiver.CM, TestCurve.Add(receiver.CMTok, TestCurve.H), k.receiverPK, "commitment"},
+		{"bundled commitment", receiver, func(e *EntryProof) { e.CM = other[1].CM }, receiver.CM, receiver.CMTok, k.receiverPK, "commitment"},
+		{"key", receiver, func(e *EntryProof) {}, receiver.CM, receiver.CMTok, k.auditorPK, "consistency"},
+		{"consistency proof of another entry", receiver, func(e *EntryProof) { e.Consistency = other[1].Consistency },
+			receiver.CM, receiver.CMTok, k.receiverPK, "consistency"},
+		{"range proof of another entry", receiver, func(e *EntryProof) { e.Range = other[1].Range },
+			receiver.CM, receiver.CMTok, k.receiverPK, "assets"},
+		{"assets proof of another entry", receiver, func(e *EntryProof) { e.Assets = other[1].Assets },
+			receiver.CM, receiver.CMTok, k.receiverPK, "assets"},
+		{"range proof", sender, func(e *EntryProof) {
+			e.Range = e.Range.Clone()
+			e.Range.ProofTuples[3].S = new(big.Int).Add(e.Range.ProofTuples[3].S, big.NewInt(1))
+		}, sender.CM, sender.CMTok, k.senderPK, "range"},
+	}
+	for _, c := range cases {
+		e := *c.entry
+		c.tamper(&e)
+		ok, err := e.Verify(TestCurve, c.CM, c.CMTok, c.pk)
+		if ok || err == nil {
+			t.Fatalf("EntryProof verified with %s changed\n", c.name)
+		}
+		if stage := entryStage(err); stage != c.stage {
+			t.Fatalf("EntryProof with %s changed fails at %s, expected %s: %v\n", c.name, stage, c.stage, err)
+		}
+		var pe *ProofError
+		if !errors.As(err, &pe) || pe.Type != "EntryProof.Verify" {
+			t.Fatalf("EntryProof with %s changed gives %v\n", c.name, err)
+		}
+		if c.stage != "commitment" && !errors.Is(err, ErrSubProofFailed) {
+			t.Fatalf("EntryProof with %s changed does not fail in a sub proof: %v\n", c.name, err)
+		}
+	}
+
+	// the proofs of the receiver do not take 25 out of the sender's column
+	forged := *receiver
+	forged.CM, forged.CMTok = sender.CM, sender.CMTok
+	if ok, err := forged.Verify(TestCurve, sender.CM, sender.CMTok, k.senderPK); ok || err == nil {
+		t.Fatalf("EntryProof of the receiver verified for the entry of the sender\n")
+	}
+
+	var nilEntry *EntryProof
+	if _, err := nilEntry.Verify(TestCurve, receiver.CM, receiver.CMTok, k.receiverPK); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("Verify of a nil EntryProof gives %v\n", err)
+	}
+	missing := *receiver
+	missing.Assets = nil
+	if _, err := missing.Verify(TestCurve, receiver.CM, receiver.CMTok, k.receiverPK); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("Verify of an EntryProof without Assets gives %v\n", err)
+	}
+	if _, err := receiver.Verify(TestCurve, ECPoint{big.NewInt(1), big.NewInt(1)}, receiver.CMTok, k.receiverPK); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("Verify for a CM not on the curve gives %v\n", err)
+	}
+
+	b := receiver.Bytes()
+	for _, bad := range [][]byte{nil, b[:len(b)-1], append(b[:len(b):len(b)], 0), {proofEncodingVersion + 1}} {
+		if _, err := NewEntryProofFromBytes(bad); err == nil {
+			t.Fatalf("NewEntryProofFromBytes accepted %d bytes\n", len(bad))
+		}
+	}
+}
+
+func TestEntryProofInputs(t *testing.T) {
+	k := newTransferKeys(t)
+	N := TestCurve.C.Params().N
+	r := scalarFromSeed("entry r")
+	big40 := new(big.Int).Lsh(big.NewInt(1), 40)
+	cases := []struct {
+		name     string
+		value    *big.Int
+		sk       *big.Int
+		pk       ECPoint
+		isSender bool
+		err      error
+	}{
+		{"value of 2^40", big40, nil, k.receiverPK, false, ErrValueOutOfRange},
+		{"negative value of a receiver", new(big.Int).Sub(N, big.NewInt(1)), nil, k.receiverPK, false, ErrValueOutOfRange},
+		{"spending 2^40", new(big.Int).Sub(N, big40), k.sk[0], k.senderPK, true, ErrValueOutOfRange},
+		{"receiving as the sender", big.NewInt(1), k.sk[0], k.senderPK, true, ErrValueOutOfRange},
+		{"sender without a key", new(big.Int).Sub(N, big.NewInt(1)), nil, k.senderPK, true, ErrValueOutOfRange},
+		{"sender with another key", new(big.Int).Sub(N, big.NewInt(1)), k.sk[1], k.senderPK, true, ErrInvalidInput},
+		{"value not in [0, N)", big.NewInt(-1), nil, k.receiverPK, false, ErrValueOutOfRange},
+		{"pk not on the curve", big.NewInt(1), nil, ECPoint{big.NewInt(1), big.NewInt(1)}, false, ErrNotOnCurve},
+	}
+	for _, c := range cases {
+		if e, err := NewEntryProof(TestCurve, c.value, r, c.sk, c.pk, c.isSender); e != nil || !errors.Is(err, c.err) {
+			t.Fatalf("NewEntryProof for %s gives %v\n", c.name, err)
+		}
+	}
+
+	// spending everything that fits is fine
+	e, err := NewEntryProof(TestCurve, new(big.Int).Sub(N, new(big.Int).Sub(big40, big.NewInt(1))), r, k.sk[0], k.senderPK, true)
+	if err != nil {
+		t.Fatalf("NewEntryProof spending 2^40 - 1 failed: %v\n", err)
+	}
+	if ok, err := e.Verify(TestCurve, e.CM, e.CMTok, k.senderPK); !ok || err != nil {
+		t.Fatalf("EntryProof spending 2^40 - 1 did not verify: %v\n", err)
+	}
+}
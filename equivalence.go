@@ -2,8 +2,9 @@ package zksigma
 
 import (
 	"bytes"
-	"crypto/rand"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/big"
 )
 
@@ -37,45 +38,121 @@ type EquivalenceProof struct {
 // and Result2 is the scalar multiple of base Base2 and that both results are using the same x as discrete log.
 func NewEquivalenceProof(
 	zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint, x *big.Int) (*EquivalenceProof, error) {
+	return NewEquivalenceProofWithRand(zkpcp, entropy(), Base1, Result1, Base2, Result2, x)
+}
+
+// DLEqualityProof is a Chaum-Pedersen proof of equality of discrete logs:
+// Result1 = xBase1 and Result2 = xBase2 for the same x and any two bases, e.g.
+// a commitment xH and a token xPK. It is the EquivalenceProof under the name
+// such proofs usually go by, with G and H replaced by Base1 and Base2.
+type DLEqualityProof = EquivalenceProof
+
+// NewDLEqualityProof generates a DLEqualityProof that Result1 = xBase1 and
+// Result2 = xBase2. It fails if x does not satisfy both.
+func NewDLEqualityProof(
+	zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint, x *big.Int) (*DLEqualityProof, error) {
+	return NewEquivalenceProofWithRand(zkpcp, entropy(), Base1, Result1, Base2, Result2, x)
+}
+
+// NewEquivalenceProofWithRand is the same as NewEquivalenceProof, but reads its random
+// values from rnd instead of the EntropySource
+func NewEquivalenceProofWithRand(
+	zkpcp ZKPCurveParams, rnd io.Reader, Base1, Result1, Base2, Result2 ECPoint, x *big.Int) (*EquivalenceProof, error) {
+	return newEquivalenceProof(zkpcp, rnd, Base1, Result1, Base2, Result2, x, nil)
+}
+
+// NewEquivalenceProofWithMessage is the same as NewEquivalenceProof, but binds
+// the proof to the application message msg like NewABCProofWithMessage. It only
+// verifies with VerifyWithMessage and the same msg.
+func NewEquivalenceProofWithMessage(
+	zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint, x *big.Int, msg []byte) (*EquivalenceProof, error) {
+	return newEquivalenceProof(zkpcp, entropy(), Base1, Result1, Base2, Result2, x, msg)
+}
+
+// newEquivalenceProof generates an EquivalenceProof bound to msg, which may be nil
+func newEquivalenceProof(zkpcp ZKPCurveParams, rnd io.Reader,
+	Base1, Result1, Base2, Result2 ECPoint, x *big.Int, msg []byte) (*EquivalenceProof, error) {
+
+	if !zkpcp.validPoints(Base1, Result1, Base2, Result2) {
+		return nil, curveError("EquivalenceProve")
+	}
+	prover, err := equivalenceCommit(zkpcp, rnd, Base1, Result1, Base2, Result2, x)
+	if err != nil {
+		return nil, err
+	}
+
+	// HASH(msg, G, H, xG, xH, uG, uH)
+	Challenge := equivalenceTranscript(zkpcp, Base1, Result1, Base2, Result2, prover.UG, prover.UH, msg).ChallengeScalar("c")
+
+	return prover.respond(zkpcp, Challenge), nil
+}
+
+// equivalenceProver is an EquivalenceProof between the commit and the
+// response phase, it keeps the witness and the random value around
+type equivalenceProver struct {
+	UG, UH ECPoint
+	x, u   *big.Int
+}
+
+// equivalenceCommit checks x and computes the commitments uG and uH of an
+// EquivalenceProof
+func equivalenceCommit(
+	zkpcp ZKPCurveParams, rnd io.Reader, Base1, Result1, Base2, Result2 ECPoint, x *big.Int) (*equivalenceProver, error) {
 
+	if err := zkpcp.checkScalars("EquivalenceProve", "x", x); err != nil {
+		return nil, err
+	}
 	modValue := new(big.Int).Mod(x, zkpcp.C.Params().N)
 	check1 := zkpcp.Mult(Base1, modValue)
 
 	if !check1.Equal(Result1) {
-		return nil, &errorProof{"EquivalenceProve", "Base1 and Result1 are not related by x"}
+		return nil, inputError("EquivalenceProve", "Base1 and Result1 are not related by x")
 	}
 
 	check2 := zkpcp.Mult(Base2, modValue)
 	if !check2.Equal(Result2) {
-		return nil, &errorProof{"EquivalenceProve", "Base2 and Result2 are not related by x"}
+		return nil, inputError("EquivalenceProve", "Base2 and Result2 are not related by x")
 	}
 
 	// random number
-	u, err := rand.Int(rand.Reader, zkpcp.C.Params().N) // random number to hide x later
+	u, err := zkpcp.randScalar(rnd) // random number to hide x later
 	if err != nil {
 		return nil, err
 	}
 
-	// uG
-	uBase1 := zkpcp.Mult(Base1, u)
-	// uH
-	uBase2 := zkpcp.Mult(Base2, u)
+	return &equivalenceProver{
+		UG: zkpcp.Mult(Base1, u), // uG
+		UH: zkpcp.Mult(Base2, u), // uH
+		x:  modValue,
+		u:  u,
+	}, nil
+}
 
-	// HASH(G, H, xG, xH, uG, uH)
-	Challenge := GenerateChallenge(zkpcp, Base1.Bytes(), Result1.Bytes(),
-		Base2.Bytes(), Result2.Bytes(),
-		uBase1.Bytes(), uBase2.Bytes())
+// equivalenceTranscript returns the transcript the challenge of an
+// EquivalenceProof is computed from
+func equivalenceTranscript(zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2, UG, UH ECPoint, msg []byte) *Transcript {
+	t := NewTranscript(zkpcp, "EquivalenceProof")
+	t.bindMessage(msg)
+	t.AppendPoint("Base1", Base1)
+	t.AppendPoint("Result1", Result1)
+	t.AppendPoint("Base2", Base2)
+	t.AppendPoint("Result2", Result2)
+	t.AppendPoint("UG", UG)
+	t.AppendPoint("UH", UH)
+	return t
+}
 
+// respond finishes the EquivalenceProof for challenge c
+func (prover *equivalenceProver) respond(zkpcp ZKPCurveParams, c *big.Int) *EquivalenceProof {
 	// s = u + c * x
-	HiddenValue := new(big.Int).Add(u, new(big.Int).Mul(Challenge, modValue))
+	HiddenValue := new(big.Int).Add(prover.u, new(big.Int).Mul(c, prover.x))
 	HiddenValue = HiddenValue.Mod(HiddenValue, zkpcp.C.Params().N)
 
 	return &EquivalenceProof{
-		uBase1, // uG
-		uBase2, // uH
-		Challenge,
-		HiddenValue}, nil
-
+		prover.UG, // uG
+		prover.UH, // uH
+		c,
+		HiddenValue}
 }
 
 // Verify checks if EquivalenceProof eqProof is a valid proof that Result1 is
@@ -83,64 +160,198 @@ func NewEquivalenceProof(
 // Base2. Both using the same x as discrete log.
 func (eqProof *EquivalenceProof) Verify(
 	zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint) (bool, error) {
+	return eqProof.verify(zkpcp, Base1, Result1, Base2, Result2, nil)
+}
+
+// VerifyWithMessage is the same as Verify for an EquivalenceProof generated by
+// NewEquivalenceProofWithMessage, which only verifies for the same msg
+func (eqProof *EquivalenceProof) VerifyWithMessage(
+	zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint, msg []byte) (bool, error) {
+	return eqProof.verify(zkpcp, Base1, Result1, Base2, Result2, msg)
+}
+
+// VerifyArgs implements Verifiable, args are Base1, Result1, Base2 and Result2
+// like for Verify
+func (eqProof *EquivalenceProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("EquivalenceProof.VerifyArgs", args)
+	Base1, Result1, Base2, Result2 := a.point(), a.point(), a.point(), a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return eqProof.Verify(zkpcp, Base1, Result1, Base2, Result2)
+}
+
+// verify is Verify for the proof bound to msg, which may be nil
+func (eqProof *EquivalenceProof) verify(
+	zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint, msg []byte) (bool, error) {
 
 	if eqProof == nil {
-		return false, &errorProof{"EquivalenceVerify", fmt.Sprintf("passed proof is nil")}
+		return false, malformedError("EquivalenceVerify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(Base1, Result1, Base2, Result2) || !zkpcp.onCurve(eqProof.UG, eqProof.UH) {
+		return false, curveError("EquivalenceVerify")
+	}
+	if err := zkpcp.canonicalScalars("EquivalenceVerify", "Challenge, HiddenValue", eqProof.Challenge, eqProof.HiddenValue); err != nil {
+		return false, err
 	}
 
 	// Regenerate challenge string
-	c := GenerateChallenge(zkpcp, Base1.Bytes(), Result1.Bytes(),
-		Base2.Bytes(), Result2.Bytes(),
-		eqProof.UG.Bytes(), eqProof.UH.Bytes())
+	c := equivalenceTranscript(zkpcp, Base1, Result1, Base2, Result2, eqProof.UG, eqProof.UH, msg).ChallengeScalar("c")
 
-	if c.Cmp(eqProof.Challenge) != 0 {
-		return false, &errorProof{"EquivalenceVerify", fmt.Sprintf("challenge comparison failed. proof: %v calculated: %v",
-			eqProof.Challenge, c)}
+	if !scalarsEqual(c, eqProof.Challenge) {
+		return false, challengeError("EquivalenceVerify", "challenge comparison failed", c, eqProof.Challenge)
 	}
 
-	// sG ?= uG + cA
-	sG := zkpcp.Mult(Base1, eqProof.HiddenValue)
-	cG := zkpcp.Mult(Result1, eqProof.Challenge)
-	test := zkpcp.Add(eqProof.UG, cG)
+	if err := eqProof.verifyResponses(zkpcp, Base1, Result1, Base2, Result2); err != nil {
+		return false, err
+	}
+
+	// All three checks passed, proof must be correct
+	return true, nil
+
+}
+
+// verifyResponses checks the verification equations of eqProof for its
+// challenge, which has to be checked by the caller
+func (eqProof *EquivalenceProof) verifyResponses(
+	zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint) error {
+
+	negC := new(big.Int).Neg(eqProof.Challenge)
+
+	// sG ?= uG + cA, checked as sG - cA ?= uG
+	sG := zkpcp.MultiMult([]ECPoint{Base1, Result1}, []*big.Int{eqProof.HiddenValue, negC})
 
-	if !sG.Equal(test) {
-		return false, &errorProof{"EquivalenceVerify", "sG comparison did not pass"}
+	if !pointsEqual(sG, eqProof.UG) {
+		return equationError("EquivalenceVerify", 1, "sG comparison did not pass: sG - cA != uG", sG, eqProof.UG)
 	}
 
-	// sH ?= uH + cB
-	sH := zkpcp.Mult(Base2, eqProof.HiddenValue)
-	cH := zkpcp.Mult(Result2, eqProof.Challenge)
-	test = zkpcp.Add(eqProof.UH, cH)
+	// sH ?= uH + cB, checked as sH - cB ?= uH
+	sH := zkpcp.MultiMult([]ECPoint{Base2, Result2}, []*big.Int{eqProof.HiddenValue, negC})
 
-	if !sH.Equal(test) {
-		return false, &errorProof{"EquivalenceVerify", "sH comparison did not pass"}
+	if !pointsEqual(sH, eqProof.UH) {
+		return equationError("EquivalenceVerify", 2, "sH comparison did not pass: sH - cB != uH", sH, eqProof.UH)
 	}
 
-	// All three checks passed, proof must be correct
-	return true, nil
+	return nil
+}
 
+// equivalenceProofLen is the length of a serialized EquivalenceProof
+var equivalenceProofLen = 1 + 2*fixedPointLen + 2*fixedScalarLen
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *EquivalenceProof) String() string {
+	if proof == nil {
+		return "EquivalenceProof(nil)"
+	}
+	return proofString("EquivalenceProof", "UG", proof.UG, "UH", proof.UH,
+		"Challenge", proof.Challenge, "HiddenValue", proof.HiddenValue)
 }
 
-// Bytes returns a byte slice with a serialized representation of EquivalenceProof proof
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// EquivalenceProof proof
 func (proof *EquivalenceProof) Bytes() []byte {
 	var buf bytes.Buffer
 
-	WriteECPoint(&buf, proof.UG)
-	WriteECPoint(&buf, proof.UH)
-	WriteBigInt(&buf, proof.Challenge)
-	WriteBigInt(&buf, proof.HiddenValue)
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.UG)
+	writeFixedPoint(&buf, proof.UH)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.HiddenValue)
 
 	return buf.Bytes()
 }
 
+// Size returns the length of Bytes of EquivalenceProof proof, which has a fixed size
+func (proof *EquivalenceProof) Size() int {
+	return equivalenceProofLen
+}
+
+// Clone returns a deep copy of EquivalenceProof proof that shares no big.Int with it
+func (proof *EquivalenceProof) Clone() *EquivalenceProof {
+	if proof == nil {
+		return nil
+	}
+	return &EquivalenceProof{
+		UG:          clonePoint(proof.UG),
+		UH:          clonePoint(proof.UH),
+		Challenge:   cloneScalar(proof.Challenge),
+		HiddenValue: cloneScalar(proof.HiddenValue),
+	}
+}
+
+// Equal returns true if EquivalenceProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *EquivalenceProof) Equal(other *EquivalenceProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.UG, other.UG) &&
+		samePoint(proof.UH, other.UH) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.HiddenValue, other.HiddenValue)
+}
+
 // NewEquivalenceProofFromBytes returns a EquivalenceProof generated from the
 // deserialization of byte slice b
 func NewEquivalenceProofFromBytes(b []byte) (*EquivalenceProof, error) {
+	if err := checkFixedHeader(b, equivalenceProofLen, "EquivalenceProofFromBytes"); err != nil {
+		return nil, err
+	}
 	proof := new(EquivalenceProof)
-	buf := bytes.NewBuffer(b)
-	proof.UG, _ = ReadECPoint(buf)
-	proof.UH, _ = ReadECPoint(buf)
-	proof.Challenge, _ = ReadBigInt(buf)
-	proof.HiddenValue, _ = ReadBigInt(buf)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.UG, err = readFixedPoint(buf, "EquivalenceProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if proof.UH, err = readFixedPoint(buf, "EquivalenceProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "EquivalenceProofFromBytes", &proof.Challenge, &proof.HiddenValue); err != nil {
+		return nil, err
+	}
 	return proof, nil
 }
+
+type equivalenceProofJSON struct {
+	UG          string `json:"ug"`
+	UH          string `json:"uh"`
+	Challenge   string `json:"challenge"`
+	HiddenValue string `json:"hiddenValue"`
+}
+
+// MarshalJSON encodes EquivalenceProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *EquivalenceProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(equivalenceProofJSON{
+		UG:          pointToHex(proof.UG),
+		UH:          pointToHex(proof.UH),
+		Challenge:   scalarToHex(proof.Challenge),
+		HiddenValue: scalarToHex(proof.HiddenValue),
+	})
+}
+
+// UnmarshalJSON decodes an EquivalenceProof encoded with MarshalJSON. It fails
+// if a point is not on the curve or a scalar is not in [0, N)
+func (proof *EquivalenceProof) UnmarshalJSON(b []byte) error {
+	var aux equivalenceProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := EquivalenceProof{}
+	if p.UG, err = pointFromHex(aux.UG, "ug"); err != nil {
+		return err
+	}
+	if p.UH, err = pointFromHex(aux.UH, "uh"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.HiddenValue, err = scalarFromHex(aux.HiddenValue, "hiddenValue"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
@@ -1,7 +1,9 @@
 package zksigma
 
 import (
+	"bytes"
 	"crypto/rand"
+	"errors"
 	"math/big"
 	"testing"
 )
@@ -18,7 +20,7 @@ func TestEquivalence(t *testing.T) {
 	eqProof, status1 := NewEquivalenceProof(TestCurve, Base1, Result1, Base2, Result2, x)
 
 	if status1 != nil {
-		proofStatus(status1.(*errorProof))
+		proofStatus(status1.(*ProofError))
 		t.Fatalf("error code should have indicated successful proof")
 	}
 
@@ -67,7 +69,7 @@ func TestEquivalence(t *testing.T) {
 	if status2 == nil {
 		t.Fatalf("error code should have indicated failed proof")
 	} else {
-		proofStatus(status2.(*errorProof))
+		proofStatus(status2.(*ProofError))
 	}
 
 }
@@ -81,7 +83,11 @@ func TestEquivSerialization(t *testing.T) {
 	Result2 := TestCurve.Mult(Base2, value)
 
 	proof, _ := NewEquivalenceProof(TestCurve, Base1, Result1, Base2, Result2, value)
-	proof, err := NewEquivalenceProofFromBytes(proof.Bytes())
+	encoded := proof.Bytes()
+	if len(encoded) != equivalenceProofLen || !bytes.Equal(encoded, proof.Bytes()) {
+		t.Fatalf("TestEquivSerialization produced a non-deterministic or wrongly sized encoding\n")
+	}
+	proof, err := NewEquivalenceProofFromBytes(encoded)
 	if err != nil {
 		t.Fatalf("TestEquivSerialization failed to deserialize\n")
 	}
@@ -92,6 +98,50 @@ func TestEquivSerialization(t *testing.T) {
 
 }
 
+// TestDLEqualityProof proves that a commitment under H and a token under an
+// arbitrary PK hide the same x
+func TestDLEqualityProof(t *testing.T) {
+	N := TestCurve.C.Params().N
+	sk, _ := rand.Int(rand.Reader, N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	x, _ := rand.Int(rand.Reader, N)
+
+	Result1 := TestCurve.Mult(TestCurve.H, x)
+	Result2 := TestCurve.Mult(PK, x)
+
+	proof, err := NewDLEqualityProof(TestCurve, TestCurve.H, Result1, PK, Result2, x)
+	if err != nil {
+		t.Fatalf("DLEqualityProof failed to generate: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, TestCurve.H, Result1, PK, Result2); !ok || err != nil {
+		t.Fatalf("DLEqualityProof failed to verify: %v\n", err)
+	}
+
+	// the same proof is not valid for another token
+	if ok, err := proof.Verify(TestCurve, TestCurve.H, Result1, PK, TestCurve.Mult(PK, big.NewInt(2))); ok || err == nil {
+		t.Fatalf("DLEqualityProof verified for another Result2\n")
+	}
+
+	// Result2 = yPK with y != x
+	y := new(big.Int).Add(x, big.NewInt(1))
+	wrong := TestCurve.Mult(PK, y)
+	if _, err := NewDLEqualityProof(TestCurve, TestCurve.H, Result1, PK, wrong, x); err == nil {
+		t.Fatalf("DLEqualityProof generated for Result2 with another exponent\n")
+	}
+	if _, err := NewDLEqualityProof(TestCurve, TestCurve.H, Result1, PK, wrong, y); err == nil {
+		t.Fatalf("DLEqualityProof generated for Result1 with another exponent\n")
+	}
+
+	// a proof made for y on both sides does not verify the mismatched pair
+	proof, err = NewDLEqualityProof(TestCurve, TestCurve.H, TestCurve.Mult(TestCurve.H, y), PK, wrong, y)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, TestCurve.H, Result1, PK, wrong); ok || err == nil {
+		t.Fatalf("DLEqualityProof verified for mismatched exponents\n")
+	}
+}
+
 func BenchmarkEquivProve(b *testing.B) {
 	value, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
 	Base1 := TestCurve.G
@@ -118,3 +168,38 @@ func BenchmarkEquivVerify(b *testing.B) {
 		proof.Verify(TestCurve, Base1, Result1, Base2, Result2)
 	}
 }
+
+func TestEquivalenceProofWithMessage(t *testing.T) {
+	x, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	Base2 := TestCurve.H
+	Result1, Result2 := TestCurve.Mult(TestCurve.G, x), TestCurve.Mult(Base2, x)
+	msg := []byte("transaction 1")
+	defer func(old bool) { LegacyChallenges = old }(LegacyChallenges)
+
+	for _, legacy := range []bool{false, true} {
+		LegacyChallenges = legacy
+
+		proof, err := NewEquivalenceProofWithMessage(TestCurve, TestCurve.G, Result1, Base2, Result2, x, msg)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if ok, err := proof.VerifyWithMessage(TestCurve, TestCurve.G, Result1, Base2, Result2, msg); !ok || err != nil {
+			t.Fatalf("EquivalenceProof with a message failed to verify, legacy %v: %v\n", legacy, err)
+		}
+		if ok, err := proof.VerifyWithMessage(TestCurve, TestCurve.G, Result1, Base2, Result2, []byte("transaction 2")); ok || !errors.Is(err, ErrChallengeMismatch) {
+			t.Fatalf("EquivalenceProof verified for another message, legacy %v: %v\n", legacy, err)
+		}
+		if ok, _ := proof.Verify(TestCurve, TestCurve.G, Result1, Base2, Result2); ok {
+			t.Fatalf("EquivalenceProof with a message verified without it, legacy %v\n", legacy)
+		}
+
+		plain, _ := NewEquivalenceProofWithRand(TestCurve, newSeededReader("eq"), TestCurve.G, Result1, Base2, Result2, x)
+		empty, _ := newEquivalenceProof(TestCurve, newSeededReader("eq"), TestCurve.G, Result1, Base2, Result2, x, []byte{})
+		if !bytes.Equal(plain.Bytes(), empty.Bytes()) {
+			t.Fatalf("EquivalenceProof with an empty message differs from one without, legacy %v\n", legacy)
+		}
+		if ok, err := plain.VerifyWithMessage(TestCurve, TestCurve.G, Result1, Base2, Result2, nil); !ok || err != nil {
+			t.Fatalf("EquivalenceProof failed to verify with a nil message: %v\n", err)
+		}
+	}
+}
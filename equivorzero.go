@@ -0,0 +1,258 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// EquivOrZeroProof is a proof that the change output CMChange of a
+// transaction either commits to 0 or to the same value as CMDelta, the
+// commitment to the inputs minus the other outputs, without revealing which.
+// Both cases are a discrete log to the base H, so it is a DisjunctiveProof
+// with a challenge of its own, one branch proved and the other simulated:
+//
+//  Public: generator point H, CMChange, CMDelta
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows rChange with CMChange = rChange * H (Left, no change)
+//  or rChange - rDelta with
+//  CMChange - CMDelta = (rChange - rDelta) * H (Right, the change)
+//  A = CMChange; B = CMChange - CMDelta
+//  proves the side it knows and simulates the other
+//  like a DisjunctiveProof of A = xH or B = xH
+//  c = HASH(H, CMChange, CMDelta, T1, T2)
+//
+//  T1, T2, c, c1, c2, s1, s2 --------->
+//                                      c ?= HASH(H, CMChange, CMDelta, T1, T2)
+//                                      c ?= c1 + c2 // mod zkpcp.C.Params().N
+//                                      s1H ?= T1 + c1A
+//                                      s2H ?= T2 + c2B
+//
+// The proofs of both sides are the same points and scalars, uniformly random
+// but for the checks above. The fields are those of a DisjunctiveProof, which
+// it converts to for the checks.
+type EquivOrZeroProof struct {
+	T1 ECPoint
+	T2 ECPoint
+	C  *big.Int
+	C1 *big.Int // the challenge of CMChange = xH
+	C2 *big.Int // the challenge of CMChange - CMDelta = xH
+	S1 *big.Int
+	S2 *big.Int
+}
+
+// equivOrZeroChallenge computes c = HASH(H, CMChange, CMDelta, T1, T2)
+func equivOrZeroChallenge(zkpcp ZKPCurveParams, CMChange, CMDelta, T1, T2 ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "EquivOrZeroProof")
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendPoint("CMChange", CMChange)
+	t.AppendPoint("CMDelta", CMDelta)
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	return t.ChallengeScalar("c")
+}
+
+// NewEquivOrZeroProof generates a proof that CMChange commits to 0 or to the
+// value of CMDelta. For option Left CMChange = rChange * H commits to 0 and
+// rDelta is not used, it may be nil. For option Right CMChange and CMDelta =
+// v * G + rDelta * H commit to the same v. The verifier does not learn the
+// option. It fails if CMChange and the randomness do not fit the option.
+func NewEquivOrZeroProof(zkpcp ZKPCurveParams, CMChange, CMDelta ECPoint, rChange, rDelta *big.Int, option Side) (*EquivOrZeroProof, error) {
+	return NewEquivOrZeroProofWithRand(zkpcp, entropy(), CMChange, CMDelta, rChange, rDelta, option)
+}
+
+// NewEquivOrZeroProofWithRand is the same as NewEquivOrZeroProof, but reads
+// its random values from rnd instead of the EntropySource
+func NewEquivOrZeroProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	CMChange, CMDelta ECPoint, rChange, rDelta *big.Int, option Side) (*EquivOrZeroProof, error) {
+
+	if option != Left && option != Right {
+		return nil, sideError("EquivOrZeroProve", "invalid side provided")
+	}
+	if err := zkpcp.checkScalars("EquivOrZeroProve", "rChange", rChange); err != nil {
+		return nil, err
+	}
+	if !zkpcp.validPoints(CMChange, CMDelta) {
+		return nil, curveError("EquivOrZeroProve")
+	}
+
+	B := zkpcp.Sub(CMChange, CMDelta)
+	x := rChange
+	if option == Left {
+		if !zkpcp.Mult(zkpcp.H, rChange).Equal(CMChange) {
+			return nil, inputError("EquivOrZeroProve", "CMChange is not rChange * H")
+		}
+	} else {
+		if err := zkpcp.checkScalars("EquivOrZeroProve", "rDelta", rDelta); err != nil {
+			return nil, err
+		}
+		x = new(big.Int).Sub(rChange, rDelta)
+		x.Mod(x, zkpcp.C.Params().N)
+		if !zkpcp.Mult(zkpcp.H, x).Equal(B) {
+			return nil, inputError("EquivOrZeroProve", "CMChange and CMDelta do not commit to the same value with rChange and rDelta")
+		}
+	}
+
+	prover, err := disjunctiveCommit(zkpcp, rnd, zkpcp.H, CMChange, zkpcp.H, B, x, option)
+	if err != nil {
+		return nil, err
+	}
+	T1, T2 := prover.commitments()
+	dj := prover.respond(zkpcp, equivOrZeroChallenge(zkpcp, CMChange, CMDelta, T1, T2))
+
+	return (*EquivOrZeroProof)(dj), nil
+}
+
+// Verify checks if EquivOrZeroProof proof shows that CMChange commits to 0 or
+// to the same value as CMDelta
+func (proof *EquivOrZeroProof) Verify(zkpcp ZKPCurveParams, CMChange, CMDelta ECPoint) (bool, error) {
+	if proof == nil {
+		return false, malformedError("EquivOrZeroProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(CMChange, CMDelta) || !zkpcp.onCurve(proof.T1, proof.T2) {
+		return false, curveError("EquivOrZeroProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("EquivOrZeroProof.Verify", "C, C1, C2, S1, S2",
+		proof.C, proof.C1, proof.C2, proof.S1, proof.S2); err != nil {
+		return false, err
+	}
+
+	c := equivOrZeroChallenge(zkpcp, CMChange, CMDelta, proof.T1, proof.T2)
+	if !scalarsEqual(c, proof.C) {
+		return false, challengeError("EquivOrZeroProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.C)
+	}
+
+	// c1 + c2 ?= c and the equations of both sides
+	if err := (*DisjunctiveProof)(proof).verifyResponses(zkpcp,
+		zkpcp.H, CMChange, zkpcp.H, zkpcp.Sub(CMChange, CMDelta), nil); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are CMChange and CMDelta like for
+// Verify
+func (proof *EquivOrZeroProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("EquivOrZeroProof.VerifyArgs", args)
+	CMChange, CMDelta := a.point(), a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CMChange, CMDelta)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *EquivOrZeroProof) String() string {
+	if proof == nil {
+		return "EquivOrZeroProof(nil)"
+	}
+	return proofString("EquivOrZeroProof", "T1", proof.T1, "T2", proof.T2, "C", proof.C,
+		"C1", proof.C1, "C2", proof.C2, "S1", proof.S1, "S2", proof.S2)
+}
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// EquivOrZeroProof proof, which is that of a DisjunctiveProof
+func (proof *EquivOrZeroProof) Bytes() []byte {
+	return (*DisjunctiveProof)(proof).Bytes()
+}
+
+// Size returns the length of Bytes of EquivOrZeroProof proof, which has a fixed size
+func (proof *EquivOrZeroProof) Size() int {
+	return disjunctiveProofLen
+}
+
+// Clone returns a deep copy of EquivOrZeroProof proof that shares no big.Int with it
+func (proof *EquivOrZeroProof) Clone() *EquivOrZeroProof {
+	return (*EquivOrZeroProof)((*DisjunctiveProof)(proof).Clone())
+}
+
+// Equal returns true if EquivOrZeroProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *EquivOrZeroProof) Equal(other *EquivOrZeroProof) bool {
+	return (*DisjunctiveProof)(proof).Equal((*DisjunctiveProof)(other))
+}
+
+// NewEquivOrZeroProofFromBytes returns an EquivOrZeroProof generated from the
+// deserialization of byte slice b
+func NewEquivOrZeroProofFromBytes(b []byte) (*EquivOrZeroProof, error) {
+	if err := checkFixedHeader(b, disjunctiveProofLen, "EquivOrZeroProofFromBytes"); err != nil {
+		return nil, err
+	}
+	proof := new(EquivOrZeroProof)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.T1, err = readFixedPoint(buf, "EquivOrZeroProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if proof.T2, err = readFixedPoint(buf, "EquivOrZeroProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "EquivOrZeroProofFromBytes", &proof.C, &proof.C1, &proof.C2, &proof.S1, &proof.S2); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+type equivOrZeroProofJSON struct {
+	T1 string `json:"t1"`
+	T2 string `json:"t2"`
+	C  string `json:"c"`
+	C1 string `json:"c1"`
+	C2 string `json:"c2"`
+	S1 string `json:"s1"`
+	S2 string `json:"s2"`
+}
+
+// MarshalJSON encodes EquivOrZeroProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *EquivOrZeroProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(equivOrZeroProofJSON{
+		T1: pointToHex(proof.T1),
+		T2: pointToHex(proof.T2),
+		C:  scalarToHex(proof.C),
+		C1: scalarToHex(proof.C1),
+		C2: scalarToHex(proof.C2),
+		S1: scalarToHex(proof.S1),
+		S2: scalarToHex(proof.S2),
+	})
+}
+
+// UnmarshalJSON decodes an EquivOrZeroProof encoded with MarshalJSON. It fails
+// if a point is not on the curve or a scalar is not in [0, N)
+func (proof *EquivOrZeroProof) UnmarshalJSON(b []byte) error {
+	var aux equivOrZeroProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := EquivOrZeroProof{}
+	if p.T1, err = pointFromHex(aux.T1, "t1"); err != nil {
+		return err
+	}
+	if p.T2, err = pointFromHex(aux.T2, "t2"); err != nil {
+		return err
+	}
+	if p.C, err = scalarFromHex(aux.C, "c"); err != nil {
+		return err
+	}
+	if p.C1, err = scalarFromHex(aux.C1, "c1"); err != nil {
+		return err
+	}
+	if p.C2, err = scalarFromHex(aux.C2, "c2"); err != nil {
+		return err
+	}
+	if p.S1, err = scalarFromHex(aux.S1, "s1"); err != nil {
+		return err
+	}
+	if p.S2, err = scalarFromHex(aux.S2, "s2"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
@@ -0,0 +1,199 @@
+package zksigma
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// changeOutputs returns the commitment CMDelta to the inputs minus the other
+// outputs of a transaction with its randomness, a change output for it and one
+// of no change
+func changeOutputs(t *testing.T) (CMDelta, CMChange, CMNone ECPoint, rDelta, rChange, rNone *big.Int) {
+	value := big.NewInt(250)
+	CMDelta, rDelta, err := PedCommit(TestCurve, value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	CMChange, rChange, err = PedCommit(TestCurve, value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	CMNone, rNone, err = PedCommit(TestCurve, big.NewInt(0))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	return CMDelta, CMChange, CMNone, rDelta, rChange, rNone
+}
+
+func TestEquivOrZeroProof(t *testing.T) {
+	CMDelta, CMChange, CMNone, rDelta, rChange, rNone := changeOutputs(t)
+
+	for _, c := range []struct {
+		name    string
+		CM      ECPoint
+		r, rDlt *big.Int
+		option  Side
+	}{
+		{"change", CMChange, rChange, rDelta, Right},
+		{"no change", CMNone, rNone, nil, Left},
+	} {
+		proof, err := NewEquivOrZeroProof(TestCurve, c.CM, CMDelta, c.r, c.rDlt, c.option)
+		if err != nil {
+			t.Fatalf("EquivOrZeroProof for %s failed to generate: %v\n", c.name, err)
+		}
+		if ok, err := proof.Verify(TestCurve, c.CM, CMDelta); !ok || err != nil {
+			t.Fatalf("EquivOrZeroProof for %s failed to verify: %v\n", c.name, err)
+		}
+		decoded, err := NewEquivOrZeroProofFromBytes(proof.Bytes())
+		if err != nil {
+			t.Fatalf("EquivOrZeroProof for %s failed to deserialize: %v\n", c.name, err)
+		}
+		if ok, err := decoded.Verify(TestCurve, c.CM, CMDelta); !ok || err != nil || !decoded.Equal(proof) {
+			t.Fatalf("EquivOrZeroProof for %s failed to verify after deserialization: %v\n", c.name, err)
+		}
+
+		// the proof is for CMDelta and no other commitment
+		other, _, _ := PedCommit(TestCurve, big.NewInt(250))
+		if ok, err := proof.Verify(TestCurve, c.CM, other); ok || !errors.Is(err, ErrChallengeMismatch) {
+			t.Fatalf("EquivOrZeroProof for %s verified for another CMDelta: %v\n", c.name, err)
+		}
+	}
+
+	// CMDelta and CMChange commit to the same value, so the change output of a
+	// transaction without change would have to commit to it too
+	if _, err := NewEquivOrZeroProof(TestCurve, CMChange, CMDelta, rChange, nil, Left); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("EquivOrZeroProof for a change of 250 generated as no change: %v\n", err)
+	}
+	if _, err := NewEquivOrZeroProof(TestCurve, CMNone, CMDelta, rNone, rDelta, Right); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("EquivOrZeroProof for no change generated as the change: %v\n", err)
+	}
+	wrong := PedCommitR(TestCurve, big.NewInt(251), rChange)
+	for _, option := range []Side{Left, Right} {
+		if _, err := NewEquivOrZeroProof(TestCurve, wrong, CMDelta, rChange, rDelta, option); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("EquivOrZeroProof generated for a change of 251 and side %v: %v\n", option, err)
+		}
+	}
+}
+
+func TestEquivOrZeroProofShape(t *testing.T) {
+	CMDelta, CMChange, CMNone, rDelta, rChange, rNone := changeOutputs(t)
+	change, err := NewEquivOrZeroProof(TestCurve, CMChange, CMDelta, rChange, rDelta, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	none, err := NewEquivOrZeroProof(TestCurve, CMNone, CMDelta, rNone, nil, Left)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	// both sides give the same fields of the same sizes, in the same places
+	for name, p := range map[string]*EquivOrZeroProof{"change": change, "no change": none} {
+		for i, s := range []*big.Int{p.C, p.C1, p.C2, p.S1, p.S2} {
+			if s == nil || !inScalarRange(s) {
+				t.Fatalf("EquivOrZeroProof for %s has scalar %d = %v\n", name, i, s)
+			}
+		}
+		if p.T1.IsZero() || p.T2.IsZero() || len(p.Bytes()) != p.Size() {
+			t.Fatalf("EquivOrZeroProof for %s has an unusual shape\n", name)
+		}
+	}
+	if len(change.Bytes()) != len(none.Bytes()) || change.Bytes()[0] != none.Bytes()[0] {
+		t.Fatalf("EquivOrZeroProofs of both sides differ in encoding\n")
+	}
+	changeStats, _ := ProofStats(change)
+	noneStats, _ := ProofStats(none)
+	if changeStats != noneStats {
+		t.Fatalf("EquivOrZeroProofs of both sides have stats %v and %v\n", changeStats, noneStats)
+	}
+
+	// the same random values give the same proof but for the side they answer
+	a, _ := NewEquivOrZeroProofWithRand(TestCurve, newSeededReader("equiv or zero"), CMChange, CMDelta, rChange, rDelta, Right)
+	b, _ := NewEquivOrZeroProofWithRand(TestCurve, newSeededReader("equiv or zero"), CMChange, CMDelta, rChange, rDelta, Right)
+	if !a.Equal(b) {
+		t.Fatalf("EquivOrZeroProofs of the same random values differ\n")
+	}
+}
+
+func TestEquivOrZeroProofForged(t *testing.T) {
+	CMDelta, CMChange, _, rDelta, rChange, _ := changeOutputs(t)
+	proof, err := NewEquivOrZeroProof(TestCurve, CMChange, CMDelta, rChange, rDelta, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	N := TestCurve.C.Params().N
+
+	// a split of the challenge that does not add up to it
+	bad := proof.Clone()
+	bad.C1 = new(big.Int).Mod(new(big.Int).Add(bad.C1, big.NewInt(1)), N)
+	if ok, err := bad.Verify(TestCurve, CMChange, CMDelta); ok || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("EquivOrZeroProof with c1 + c2 != c gives %v\n", err)
+	}
+
+	// one that does, moved from the simulated side to the proved one
+	bad = proof.Clone()
+	bad.C1 = new(big.Int).Mod(new(big.Int).Add(bad.C1, big.NewInt(1)), N)
+	bad.C2 = new(big.Int).Mod(new(big.Int).Sub(bad.C2, big.NewInt(1)), N)
+	var pe *ProofError
+	if ok, err := bad.Verify(TestCurve, CMChange, CMDelta); ok || !errors.As(err, &pe) || pe.Equation != 1 {
+		t.Fatalf("EquivOrZeroProof with another split of c gives %v\n", err)
+	}
+
+	// simulating both sides with c1 and c2 picked first, which gives a c of
+	// its own
+	c1, c2, s1, s2 := scalarFromSeed("forged c1"), scalarFromSeed("forged c2"), scalarFromSeed("forged s1"), scalarFromSeed("forged s2")
+	B := TestCurve.Sub(CMChange, CMDelta)
+	T1 := TestCurve.MultiMult([]ECPoint{TestCurve.H, CMChange}, []*big.Int{s1, new(big.Int).Neg(c1)})
+	T2 := TestCurve.MultiMult([]ECPoint{TestCurve.H, B}, []*big.Int{s2, new(big.Int).Neg(c2)})
+	forged := &EquivOrZeroProof{T1, T2, new(big.Int).Mod(new(big.Int).Add(c1, c2), N), c1, c2, s1, s2}
+	if ok, err := forged.Verify(TestCurve, CMChange, CMDelta); ok || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("EquivOrZeroProof simulated on both sides gives %v\n", err)
+	}
+	forged.C = equivOrZeroChallenge(TestCurve, CMChange, CMDelta, T1, T2)
+	if ok, err := forged.Verify(TestCurve, CMChange, CMDelta); ok || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("EquivOrZeroProof simulated on both sides with the real c gives %v\n", err)
+	}
+
+	// a DisjunctiveProof of the same relations is not an EquivOrZeroProof
+	dj, err := NewDisjunctiveProof(TestCurve, TestCurve.H, CMChange, TestCurve.H, B,
+		new(big.Int).Mod(new(big.Int).Sub(rChange, rDelta), N), Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := (*EquivOrZeroProof)(dj).Verify(TestCurve, CMChange, CMDelta); ok || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("DisjunctiveProof verified as an EquivOrZeroProof: %v\n", err)
+	}
+}
+
+func TestEquivOrZeroProofInputs(t *testing.T) {
+	CMDelta, CMChange, CMNone, rDelta, rChange, rNone := changeOutputs(t)
+
+	if _, err := NewEquivOrZeroProof(TestCurve, CMNone, CMDelta, rNone, nil, Side(2)); !errors.Is(err, ErrInvalidSide) {
+		t.Fatalf("EquivOrZeroProof generated for side 2: %v\n", err)
+	}
+	if _, err := NewEquivOrZeroProof(TestCurve, CMChange, CMDelta, rChange, nil, Right); !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("EquivOrZeroProof generated for the change without rDelta: %v\n", err)
+	}
+	off := ECPoint{big.NewInt(1), big.NewInt(1)}
+	if _, err := NewEquivOrZeroProof(TestCurve, CMChange, off, rChange, rDelta, Right); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("EquivOrZeroProof generated for CMDelta off the curve: %v\n", err)
+	}
+
+	proof, err := NewEquivOrZeroProof(TestCurve, CMChange, CMDelta, rChange, rDelta, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CMChange, off); ok || !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("EquivOrZeroProof verified for CMDelta off the curve: %v\n", err)
+	}
+	if ok, err := (*EquivOrZeroProof)(nil).Verify(TestCurve, CMChange, CMDelta); ok || !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("nil EquivOrZeroProof gives %v\n", err)
+	}
+	if _, err := NewEquivOrZeroProofFromBytes(proof.Bytes()[:proof.Size()-1]); err == nil {
+		t.Fatalf("EquivOrZeroProof decoded from a short encoding\n")
+	}
+	if !bytes.Equal(proof.Clone().Bytes(), proof.Bytes()) {
+		t.Fatalf("EquivOrZeroProof changed in Clone\n")
+	}
+}
@@ -0,0 +1,283 @@
+package zksigma
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// fuzzSeedBytes is how many bytes of a valid proof are flipped one by one to
+// seed the corpus. Past it the encodings of the long proofs only repeat the
+// layout of their first entries, and the seeds of RangeProof alone would take
+// half a minute to verify.
+const fuzzSeedBytes = 1024
+
+// fuzzVerify runs Verify of the proofs decode makes out of arbitrary bytes,
+// against the args of the valid proof name of verifiableProofs. The corpus is
+// seeded with the encoding of that proof and with each of its bytes flipped.
+// Decoding and verifying must never panic, and a garbled proof must fail with
+// an error. ComposedProof has no encoding, so it has no fuzz target.
+func fuzzVerify(f *testing.F, name string, decode func([]byte) (Verifiable, error)) {
+	c := verifiableProofs(f)[name]
+	valid := c.proof.(interface{ Bytes() []byte }).Bytes()
+	f.Add(valid)
+	for i := 0; i < len(valid) && i < fuzzSeedBytes; i++ {
+		mutated := append([]byte{}, valid...)
+		mutated[i] ^= 0xff
+		f.Add(mutated)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		proof, err := decode(b)
+		if err != nil {
+			return
+		}
+		ok, err := proof.VerifyArgs(TestCurve, c.args...)
+		if ok != (err == nil) {
+			t.Fatalf("%s Verify gives %v, %v\n", name, ok, err)
+		}
+
+		// only the encoding of a valid proof verifies, a proof with a byte
+		// changed that still does would have ignored it
+		encoded := proof.(interface{ Bytes() []byte }).Bytes()
+		if ok && !bytes.Equal(encoded, b) {
+			t.Fatalf("%s verified from bytes that do not encode it\n", name)
+		}
+		_ = proof.(interface{ String() string }).String()
+		if size := proof.(interface{ Size() int }).Size(); size != len(encoded) {
+			t.Fatalf("%s has Size %d, but encodes to %d bytes\n", name, size, len(encoded))
+		}
+	})
+}
+
+func FuzzABCVerify(f *testing.F) {
+	fuzzVerify(f, "ABCProof", func(b []byte) (Verifiable, error) { return NewABCProofFromBytes(b) })
+}
+
+func FuzzAggregateRangeVerify(f *testing.F) {
+	fuzzVerify(f, "AggregateRangeProof", func(b []byte) (Verifiable, error) { return NewAggregateRangeProofFromBytes(b) })
+}
+
+func FuzzBitVerify(f *testing.F) {
+	fuzzVerify(f, "BitProof", func(b []byte) (Verifiable, error) { return NewBitProofFromBytes(b) })
+}
+
+func FuzzBitRangeVerify(f *testing.F) {
+	fuzzVerify(f, "BitRangeProof", func(b []byte) (Verifiable, error) { return NewBitRangeProofFromBytes(b) })
+}
+
+func FuzzBoundedRangeVerify(f *testing.F) {
+	fuzzVerify(f, "BoundedRangeProof", func(b []byte) (Verifiable, error) { return NewBoundedRangeProofFromBytes(b) })
+}
+
+func FuzzComparisonVerify(f *testing.F) {
+	fuzzVerify(f, "ComparisonProof", func(b []byte) (Verifiable, error) { return NewComparisonProofFromBytes(b) })
+}
+
+func FuzzConsistencyVerify(f *testing.F) {
+	fuzzVerify(f, "ConsistencyProof", func(b []byte) (Verifiable, error) { return NewConsistencyProofFromBytes(b) })
+}
+
+func FuzzDecryptionVerify(f *testing.F) {
+	fuzzVerify(f, "DecryptionProof", func(b []byte) (Verifiable, error) { return NewDecryptionProofFromBytes(b) })
+}
+
+func FuzzDisjunctiveVerify(f *testing.F) {
+	fuzzVerify(f, "DisjunctiveProof", func(b []byte) (Verifiable, error) { return NewDisjunctiveProofFromBytes(b) })
+}
+
+func FuzzEquivOrZeroVerify(f *testing.F) {
+	fuzzVerify(f, "EquivOrZeroProof", func(b []byte) (Verifiable, error) { return NewEquivOrZeroProofFromBytes(b) })
+}
+
+func FuzzEquivalenceVerify(f *testing.F) {
+	fuzzVerify(f, "EquivalenceProof", func(b []byte) (Verifiable, error) { return NewEquivalenceProofFromBytes(b) })
+}
+
+func FuzzGSPFSVerify(f *testing.F) {
+	fuzzVerify(f, "GSPFSProof", func(b []byte) (Verifiable, error) { return NewGSPFSProofFromBytes(b) })
+}
+
+func FuzzInequalityVerify(f *testing.F) {
+	fuzzVerify(f, "InequalityProof", func(b []byte) (Verifiable, error) { return NewInequalityProofFromBytes(b) })
+}
+
+func FuzzKeyRotationVerify(f *testing.F) {
+	fuzzVerify(f, "KeyRotationProof", func(b []byte) (Verifiable, error) { return NewKeyRotationProofFromBytes(b) })
+}
+
+func FuzzNonZeroVerify(f *testing.F) {
+	fuzzVerify(f, "NonZeroProof", func(b []byte) (Verifiable, error) { return NewNonZeroProofFromBytes(b) })
+}
+
+func FuzzOneOfManyVerify(f *testing.F) {
+	fuzzVerify(f, "OneOfManyProof", func(b []byte) (Verifiable, error) { return NewOneOfManyProofFromBytes(b) })
+}
+
+func FuzzOpenVerify(f *testing.F) {
+	fuzzVerify(f, "OpenProof", func(b []byte) (Verifiable, error) { return NewOpenProofFromBytes(b) })
+}
+
+func FuzzMultiProductVerify(f *testing.F) {
+	fuzzVerify(f, "MultiProductProof", func(b []byte) (Verifiable, error) { return NewMultiProductProofFromBytes(b) })
+}
+
+func FuzzMultiRangeVerify(f *testing.F) {
+	fuzzVerify(f, "MultiRangeProof", func(b []byte) (Verifiable, error) { return NewMultiRangeProofFromBytes(b) })
+}
+
+func FuzzOpenToValueVerify(f *testing.F) {
+	fuzzVerify(f, "OpenToValueProof", func(b []byte) (Verifiable, error) { return NewOpenToValueProofFromBytes(b) })
+}
+
+func FuzzProductVerify(f *testing.F) {
+	fuzzVerify(f, "ProductProof", func(b []byte) (Verifiable, error) { return NewProductProofFromBytes(b) })
+}
+
+func FuzzRangeVerify(f *testing.F) {
+	// one tuple more than there are HPoints used to crash Verify
+	f.Add(tupledRangeProof(f, maxRangeProofTuples+1).Bytes())
+	fuzzVerify(f, "RangeProof", func(b []byte) (Verifiable, error) { return NewRangeProofFromBytes(b) })
+}
+
+func FuzzRerandomizationVerify(f *testing.F) {
+	fuzzVerify(f, "RerandomizationProof", func(b []byte) (Verifiable, error) { return NewRerandomizationProofFromBytes(b) })
+}
+
+func FuzzBalanceVerify(f *testing.F) {
+	fuzzVerify(f, "BalanceProof", func(b []byte) (Verifiable, error) { return NewBalanceProofFromBytes(b) })
+}
+
+func FuzzSameBlindingVerify(f *testing.F) {
+	fuzzVerify(f, "SameBlindingProof", func(b []byte) (Verifiable, error) { return NewSameBlindingProofFromBytes(b) })
+}
+
+func FuzzSameValueVerify(f *testing.F) {
+	fuzzVerify(f, "SameValueProof", func(b []byte) (Verifiable, error) { return NewSameValueProofFromBytes(b) })
+}
+
+func FuzzSetMembershipVerify(f *testing.F) {
+	fuzzVerify(f, "SetMembershipProof", func(b []byte) (Verifiable, error) { return NewSetMembershipProofFromBytes(b) })
+}
+
+func FuzzShuffleVerify(f *testing.F) {
+	fuzzVerify(f, "ShuffleProof", func(b []byte) (Verifiable, error) { return NewShuffleProofFromBytes(b) })
+}
+
+func FuzzSumVerify(f *testing.F) {
+	fuzzVerify(f, "SumProof", func(b []byte) (Verifiable, error) { return NewSumProofFromBytes(b) })
+}
+
+func FuzzVectorOpenVerify(f *testing.F) {
+	fuzzVerify(f, "VectorOpenProof", func(b []byte) (Verifiable, error) { return NewVectorOpenProofFromBytes(b) })
+}
+
+// TestRangeProofFromBytesCrashers decodes the encodings the fuzzer found to
+// crash NewRangeProofFromBytes, or to decode into a proof with nil scalars
+// that crashed Bytes and Verify
+func TestRangeProofFromBytesCrashers(t *testing.T) {
+	crashers := map[string][]byte{
+		"empty":                 nil,
+		"missing sign byte":     {0x00, 0x00, 0x00},
+		"invalid sign byte":     {0x00, 0x00, 0x01, 0x02},
+		"huge point length":     {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"scalar too long":       append([]byte{0x00, 0x00, 0x22}, make([]byte, 0x22)...),
+		"huge number of tuples": {0x00, 0x00, 0x01, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"truncated tuple":       {0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01},
+		"trailing byte":         {0x00, 0x00, 0x01, 0x00, 0x00, 0x00},
+		"no tuples":             {0x00, 0x00, 0x01, 0x00, 0x00},
+	}
+	for name, b := range crashers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("RangeProof %s panics: %v\n", name, r)
+				}
+			}()
+			if _, err := NewRangeProofFromBytes(b); err == nil {
+				t.Fatalf("RangeProof %s decoded\n", name)
+			}
+		}()
+	}
+}
+
+// eachZeroed sets every value reachable from v through exported fields,
+// pointers and slices to its zero value in turn, calls check with the path of
+// the value and restores it
+func eachZeroed(v reflect.Value, path string, check func(path string)) {
+	if v.CanSet() && !v.IsZero() {
+		old := reflect.New(v.Type()).Elem()
+		old.Set(v)
+		v.Set(reflect.Zero(v.Type()))
+		check(path)
+		v.Set(old)
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		if _, ok := v.Interface().(*big.Int); !ok {
+			eachZeroed(v.Elem(), path, check)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			eachZeroed(v.Index(i), fmt.Sprintf("%s[%d]", path, i), check)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.PkgPath == "" {
+				eachZeroed(v.Field(i), path+"."+f.Name, check)
+			}
+		}
+	}
+}
+
+// TestVerifyZeroedFields clears the fields of valid proofs one at a time,
+// down to the coordinates of their points, like a partially filled in or
+// decoded proof. Verify must fail with an error instead of panicking.
+func TestVerifyZeroedFields(t *testing.T) {
+	for name, c := range verifiableProofs(t) {
+		proof := reflect.ValueOf(c.proof).MethodByName("Clone").Call(nil)[0]
+		eachZeroed(proof.Elem(), name, func(path string) {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("%s zeroed panics: %v\n", path, r)
+				}
+			}()
+			ok, err := proof.Interface().(Verifiable).VerifyArgs(TestCurve, c.args...)
+			if ok || err == nil {
+				t.Fatalf("%s zeroed gives %v, %v\n", path, ok, err)
+			}
+		})
+	}
+
+	// the nested proofs of ABCProof are not exported, so eachZeroed does
+	// not reach them
+	abc := verifiableProofs(t)["ABCProof"]
+	nested := map[string]func(p *ABCProof){
+		"disjuncAC":        func(p *ABCProof) { p.disjuncAC = nil },
+		"cTokenProof":      func(p *ABCProof) { p.cTokenProof = nil },
+		"disjuncAC zero":   func(p *ABCProof) { p.disjuncAC = &DisjunctiveProof{} },
+		"cTokenProof zero": func(p *ABCProof) { p.cTokenProof = &ConsistencyProof{} },
+		"j, k, l":          func(p *ABCProof) { p.j, p.k, p.l = nil, nil, nil },
+		"disjuncAC.T1":     func(p *ABCProof) { p.disjuncAC.T1 = ECPoint{} },
+		"cTokenProof.S1":   func(p *ABCProof) { p.cTokenProof.S1 = nil },
+	}
+	for name, clear := range nested {
+		p := abc.proof.(*ABCProof).Clone()
+		clear(p)
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("ABCProof with %s cleared panics: %v\n", name, r)
+				}
+			}()
+			if ok, err := p.Verify(TestCurve, abc.args[0].(ECPoint), abc.args[1].(ECPoint), abc.args[2].(ECPoint)); ok || err == nil {
+				t.Fatalf("ABCProof with %s cleared gives %v, %v\n", name, ok, err)
+			}
+		}()
+	}
+}
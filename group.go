@@ -0,0 +1,77 @@
+package zksigma
+
+import (
+	"math/big"
+)
+
+// On a curve of prime order like secp256k1 or P-256 every point is in the
+// group of G and H, but on a curve with a cofactor h > 1 there are points of
+// a small order dividing h. A public key or token with such a part would
+// confine some of the values of a proof to a small subgroup, so the points of
+// a statement are checked to be in the group of order N, see IsInGroup. The
+// points of a proof only have to be on the curve: every verification equation
+// checks one of them against a sum of multiples of points in the group.
+
+// IsInGroup returns true if p is the point at infinity (Zero) or a point on
+// the curve of zkpcp in the group of order N that G and H generate, that is if
+// N * p is the point at infinity. It does the scalar multiplication even on a
+// curve of prime order, where every valid point is in the group.
+func (p ECPoint) IsInGroup(zkpcp ZKPCurveParams) bool {
+	return p.IsValid(zkpcp) && zkpcp.inGroup(p)
+}
+
+// inGroup is IsInGroup for a valid p, G and H are known to be in the group
+func (zkpcp ZKPCurveParams) inGroup(p ECPoint) bool {
+	if p.Equal(Zero) || p.Equal(zkpcp.G) || p.Equal(zkpcp.H) {
+		return true
+	}
+	return zkpcp.hasOrderN(p)
+}
+
+// hasOrderN returns true if N * p is the point at infinity for a point p on
+// the curve. It checks (N - 1) * p = -p, which is the same, because
+// ScalarMult of some curves reduces its scalar mod N and would turn N * p
+// into 0 * p.
+func (zkpcp ZKPCurveParams) hasOrderN(p ECPoint) bool {
+	k := new(big.Int).Sub(zkpcp.C.Params().N, big.NewInt(1))
+	X, Y := zkpcp.ops().ScalarMult(p.X, p.Y, k.Bytes())
+	P := zkpcp.C.Params().P
+	negY := new(big.Int).Sub(P, p.Y)
+	negY.Mod(negY, P)
+	return X.Cmp(p.X) == 0 && Y.Cmp(negY) == 0
+}
+
+// Cofactor returns the cofactor h of the curve of zkpcp, the number of points
+// on it divided by N. The number of points is in [P + 1 - 2sqrt(P), P + 1 +
+// 2sqrt(P)], so h is the one multiple of N in there if N > 4sqrt(P), else
+// Cofactor returns nil.
+func (zkpcp ZKPCurveParams) Cofactor() *big.Int {
+	params := zkpcp.C.Params()
+	// N > 4sqrt(P) is N^2 > 16P
+	if new(big.Int).Mul(params.N, params.N).Cmp(new(big.Int).Lsh(params.P, 4)) <= 0 {
+		return nil
+	}
+	// h = round((P + 1) / N)
+	h := new(big.Int).Add(params.P, big.NewInt(1))
+	h.Add(h, new(big.Int).Rsh(params.N, 1))
+	return h.Div(h, params.N)
+}
+
+// WithoutGroupCheck returns a copy of zkpcp that only checks the points of a
+// statement to be on the curve, not to be in the group of order N, which
+// saves a scalar multiplication per point. It fails unless the curve has
+// prime order, that is cofactor 1, where the check never fails for a point
+// on the curve. zkpcp itself is not changed.
+func (zkpcp ZKPCurveParams) WithoutGroupCheck() (ZKPCurveParams, error) {
+	if h := zkpcp.Cofactor(); h == nil || h.Cmp(big.NewInt(1)) != 0 {
+		return ZKPCurveParams{}, inputError("WithoutGroupCheck", "the group check is only optional on a curve of prime order")
+	}
+	zkpcp.noGroupCheck = true
+	return zkpcp, nil
+}
+
+// GroupCheck returns true if zkpcp checks the points of a statement to be in
+// the group of order N, which is the default, see WithoutGroupCheck
+func (zkpcp ZKPCurveParams) GroupCheck() bool {
+	return !zkpcp.noGroupCheck
+}
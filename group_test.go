@@ -0,0 +1,170 @@
+package zksigma
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// cofactorCurve is y^2 = x^3 - 3x + 17 over P = 1048571, a curve with
+// 1049476 = 4 * 262369 points, so cofactor 4 and a group of prime order
+// N = 262369. It is far too small for anything but tests. G is 4 * (1, y).
+var cofactorCurve = &elliptic.CurveParams{
+	Name:    "cofactor4",
+	P:       big.NewInt(1048571),
+	N:       big.NewInt(262369),
+	B:       big.NewInt(17),
+	Gx:      big.NewInt(157291),
+	Gy:      big.NewInt(1015516),
+	BitSize: 20,
+}
+
+// lowOrderPoint is the point of order 2 on cofactorCurve
+var lowOrderPoint = ECPoint{big.NewInt(1015058), big.NewInt(0)}
+
+// cofactorParams returns the ZKPCurveParams of cofactorCurve with H = 4 * (2, y)
+func cofactorParams(t testing.TB) ZKPCurveParams {
+	G := ECPoint{cofactorCurve.Gx, cofactorCurve.Gy}
+	zkpcp, err := NewZKPCurveParams(cofactorCurve, G, ECPoint{big.NewInt(504371), big.NewInt(694931)})
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	return zkpcp
+}
+
+func TestIsInGroup(t *testing.T) {
+	zkpcp := cofactorParams(t)
+	if !lowOrderPoint.IsValid(zkpcp) {
+		t.Fatalf("the point of order 2 is not on the test curve\n")
+	}
+	if h := zkpcp.Cofactor(); h == nil || h.Int64() != 4 {
+		t.Fatalf("the test curve has cofactor %v, expected 4\n", h)
+	}
+	P := zkpcp.Mult(zkpcp.G, big.NewInt(5))
+	for name, c := range map[string]struct {
+		p  ECPoint
+		in bool
+	}{
+		"Zero":        {Zero, true},
+		"G":           {zkpcp.G, true},
+		"H":           {zkpcp.H, true},
+		"5 * G":       {P, true},
+		"order 2":     {lowOrderPoint, false},
+		"G + order 2": {zkpcp.Add(zkpcp.G, lowOrderPoint), false},
+		"P + order 2": {zkpcp.Add(P, lowOrderPoint), false},
+		"off curve":   {ECPoint{big.NewInt(1), big.NewInt(1)}, false},
+		"nil":         {ECPoint{}, false},
+	} {
+		if c.p.IsInGroup(zkpcp) != c.in || zkpcp.validPoints(c.p) != c.in {
+			t.Fatalf("IsInGroup of %s is %v, expected %v\n", name, !c.in, c.in)
+		}
+	}
+
+	// on secp256k1 every point on the curve is in the group
+	if h := TestCurve.Cofactor(); h == nil || h.Int64() != 1 {
+		t.Fatalf("TestCurve has cofactor %v, expected 1\n", h)
+	}
+	CM, _, _ := PedCommit(TestCurve, big.NewInt(3))
+	for _, p := range []ECPoint{Zero, TestCurve.G, TestCurve.H, CM} {
+		if !p.IsInGroup(TestCurve) {
+			t.Fatalf("%v is not in the group of TestCurve\n", p)
+		}
+	}
+}
+
+func TestGroupCheck(t *testing.T) {
+	zkpcp := cofactorParams(t)
+	if !zkpcp.GroupCheck() {
+		t.Fatalf("the group check is off by default\n")
+	}
+	if _, err := zkpcp.WithoutGroupCheck(); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("WithoutGroupCheck on a curve with cofactor 4 gives %v\n", err)
+	}
+	if _, err := NewZKPCurveParams(cofactorCurve, zkpcp.Add(zkpcp.G, lowOrderPoint), zkpcp.H); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("NewZKPCurveParams with G not in the group gives %v\n", err)
+	}
+
+	// a public key with a part of order 2
+	value, r, sk := big.NewInt(9), big.NewInt(12345), big.NewInt(777)
+	CM := PedCommitR(zkpcp, value, r)
+	PK := zkpcp.Mult(zkpcp.H, sk)
+	badPK := zkpcp.Add(PK, lowOrderPoint)
+	badCMTok := zkpcp.Mult(badPK, r)
+	if _, err := NewConsistencyProof(zkpcp, CM, badCMTok, badPK, value, r); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("ConsistencyProof for a PK of order 2N gives %v\n", err)
+	}
+	if _, err := NewDisjunctiveProof(zkpcp, zkpcp.G, zkpcp.Mult(zkpcp.G, sk), badPK, zkpcp.H, sk, Left); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("DisjunctiveProof for a base of order 2N gives %v\n", err)
+	}
+	if _, err := NewEquivalenceProof(zkpcp, badPK, zkpcp.Mult(badPK, sk), zkpcp.H, PK, sk); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("EquivalenceProof for a base of order 2N gives %v\n", err)
+	}
+
+	CMTok := zkpcp.Mult(PK, r)
+	proof, err := NewConsistencyProof(zkpcp, CM, CMTok, PK, value, r)
+	if err != nil {
+		t.Fatalf("ConsistencyProof on the test curve failed to generate: %v\n", err)
+	}
+	if ok, err := proof.Verify(zkpcp, CM, CMTok, PK); !ok || err != nil {
+		t.Fatalf("ConsistencyProof on the test curve failed to verify: %v\n", err)
+	}
+	for name, s := range map[string][3]ECPoint{
+		"CM":    {zkpcp.Add(CM, lowOrderPoint), CMTok, PK},
+		"CMTok": {CM, zkpcp.Add(CMTok, lowOrderPoint), PK},
+		"PK":    {CM, CMTok, badPK},
+	} {
+		if ok, err := proof.Verify(zkpcp, s[0], s[1], s[2]); ok || !errors.Is(err, ErrNotOnCurve) {
+			t.Fatalf("ConsistencyProof.Verify for a %s of order 2N gives %v\n", name, err)
+		}
+	}
+
+	// the check is only optional on a curve of prime order
+	for _, c := range []ZKPCurveParams{TestCurve, P256Curve} {
+		without, err := c.WithoutGroupCheck()
+		if err != nil {
+			t.Fatalf("WithoutGroupCheck on %s failed: %v\n", c.C.Params().Name, err)
+		}
+		if without.GroupCheck() || !c.GroupCheck() {
+			t.Fatalf("WithoutGroupCheck on %s does not switch the check off in the copy only\n", c.C.Params().Name)
+		}
+	}
+	without, _ := TestCurve.WithoutGroupCheck()
+	value, r = big.NewInt(21), big.NewInt(4321)
+	CM, PK = PedCommitR(TestCurve, value, r), TestCurve.Mult(TestCurve.H, sk)
+	CMTok = TestCurve.Mult(PK, r)
+	proof, err = NewConsistencyProof(without, CM, CMTok, PK, value, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CM, CMTok, PK); !ok || err != nil {
+		t.Fatalf("ConsistencyProof made WithoutGroupCheck failed to verify: %v\n", err)
+	}
+	if ok, err := proof.Verify(without, CM, CMTok, PK); !ok || err != nil {
+		t.Fatalf("ConsistencyProof failed to verify WithoutGroupCheck: %v\n", err)
+	}
+}
+
+func BenchmarkIsInGroup(b *testing.B) {
+	CM, _, _ := PedCommit(TestCurve, big.NewInt(3))
+	for ii := 0; ii < b.N; ii++ {
+		CM.IsInGroup(TestCurve)
+	}
+}
+
+func BenchmarkConsistencyVerifyGroupCheck(b *testing.B) {
+	value := big.NewInt(42)
+	_, PK, CM, CMTok, r := proverKeyStatement(b, value)
+	proof, _ := NewConsistencyProof(TestCurve, CM, CMTok, PK, value, r)
+	without, _ := TestCurve.WithoutGroupCheck()
+	b.Run("check", func(b *testing.B) {
+		for ii := 0; ii < b.N; ii++ {
+			proof.Verify(TestCurve, CM, CMTok, PK)
+		}
+	})
+	b.Run("without", func(b *testing.B) {
+		for ii := 0; ii < b.N; ii++ {
+			proof.Verify(without, CM, CMTok, PK)
+		}
+	})
+}
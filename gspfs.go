@@ -2,13 +2,16 @@ package zksigma
 
 import (
 	"bytes"
-	"crypto/rand"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/big"
 )
 
 // GSPFSProof is proof of knowledge of x in commitment A(=xG)
 // GSPFS is Generalized Schnorr Proofs with Fiat-Shamir transform.
+// G can be any base, which the proof carries and hashes into the challenge,
+// so a proof for one base is no proof for another.
 //
 //  Public: generator points G and H
 //
@@ -38,85 +41,302 @@ func NewGSPFSProof(zkpcp ZKPCurveParams, A ECPoint, x *big.Int) (*GSPFSProof, er
 	return NewGSPFSProofBase(zkpcp, zkpcp.G, A, x)
 }
 
+// NewGSPFSProofWithRand is the same as NewGSPFSProof, but reads its random
+// values from rnd instead of the EntropySource
+func NewGSPFSProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader, A ECPoint, x *big.Int) (*GSPFSProof, error) {
+	return NewGSPFSProofBaseWithRand(zkpcp, rnd, zkpcp.G, A, x)
+}
+
 // NewGSPFSProofBase is the same as NewGSPFSProof, except it allows you to specify
 // your own base point in parameter base, instead of using the first base point from zkpcp.
+// The base may not be the point at infinity.
 func NewGSPFSProofBase(zkpcp ZKPCurveParams, base, A ECPoint, x *big.Int) (*GSPFSProof, error) {
+	return NewGSPFSProofBaseWithRand(zkpcp, entropy(), base, A, x)
+}
+
+// NewGSPFSProofBaseWithRand is the same as NewGSPFSProofBase, but reads its random
+// values from rnd instead of the EntropySource
+func NewGSPFSProofBaseWithRand(zkpcp ZKPCurveParams, rnd io.Reader, base, A ECPoint, x *big.Int) (*GSPFSProof, error) {
+	return newGSPFSProof(zkpcp, rnd, base, A, x, nil)
+}
+
+// NewGSPFSProofWithMessage is the same as NewGSPFSProofBase, but binds the
+// proof to the application message msg like NewABCProofWithMessage, which
+// makes it a Schnorr signature of msg by the key A = x*base. It only verifies
+// with VerifyWithMessage and the same msg.
+func NewGSPFSProofWithMessage(zkpcp ZKPCurveParams, base, A ECPoint, x *big.Int, msg []byte) (*GSPFSProof, error) {
+	return newGSPFSProof(zkpcp, entropy(), base, A, x, msg)
+}
+
+// newGSPFSProof generates a GSPFSProof bound to msg, which may be nil
+func newGSPFSProof(zkpcp ZKPCurveParams, rnd io.Reader, base, A ECPoint, x *big.Int, msg []byte) (*GSPFSProof, error) {
+	prover, err := gspfsCommit(zkpcp, rnd, base, A, x)
+	if err != nil {
+		return nil, err
+	}
+
+	// generate hashed string challenge
+	c := gspfsTranscript(zkpcp, base, A, prover.uG, msg).ChallengeScalar("c")
+
+	return prover.respond(zkpcp, c), nil
+}
+
+// gspfsProver is a GSPFSProof between the commit and the response phase, it
+// keeps the witness and the random value around
+type gspfsProver struct {
+	base, uG ECPoint
+	x, u     *big.Int
+}
+
+// gspfsCommit checks x and computes the commitment uG of a GSPFSProof
+func gspfsCommit(zkpcp ZKPCurveParams, rnd io.Reader, base, A ECPoint, x *big.Int) (*gspfsProver, error) {
+	if err := zkpcp.checkScalars("GSPFSProve", "x", x); err != nil {
+		return nil, err
+	}
+	if !zkpcp.validPoints(base, A) {
+		return nil, curveError("GSPFSProve")
+	}
+	// every A would be xBase
+	if base.IsZero() {
+		return nil, inputError("GSPFSProve", "base is the point at infinity")
+	}
 	modValue := new(big.Int).Mod(x, zkpcp.C.Params().N)
 
 	// A = xG, G is any base point in this proof
-	cX, cY := zkpcp.C.ScalarMult(base.X, base.Y, modValue.Bytes())
+	cX, cY := zkpcp.ops().ScalarMult(base.X, base.Y, modValue.Bytes())
 	C := ECPoint{cX, cY}
 	if !C.Equal(A) {
-		return nil, &errorProof{"GSPFSProve:", "the point given is not xG"}
+		return nil, inputError("GSPFSProve:", "the point given is not xG")
 	}
 
-	u, err := rand.Int(rand.Reader, zkpcp.C.Params().N)
+	u, err := zkpcp.randScalar(rnd)
 	if err != nil {
 		return nil, err
 	}
 
 	// generate random point uG
-	uG := zkpcp.Mult(base, u)
+	return &gspfsProver{base, zkpcp.Mult(base, u), modValue, u}, nil
+}
 
-	// generate hashed string challenge
-	c := GenerateChallenge(zkpcp, A.Bytes(), uG.Bytes())
+// gspfsTranscript returns the transcript the challenge of a GSPFSProof is
+// computed from
+func gspfsTranscript(zkpcp ZKPCurveParams, base, A, RandCommit ECPoint, msg []byte) *Transcript {
+	t := NewTranscript(zkpcp, "GSPFSProof")
+	t.bindMessage(msg)
+	// proofs from before Transcript did not hash the base
+	if t.h != nil {
+		t.AppendPoint("Base", base)
+	}
+	t.AppendPoint("A", A)
+	t.AppendPoint("RandCommit", RandCommit)
+	return t
+}
 
+// respond finishes the GSPFSProof for challenge c
+func (prover *gspfsProver) respond(zkpcp ZKPCurveParams, c *big.Int) *GSPFSProof {
 	// v = u - c * x
-	v := new(big.Int).Sub(u, new(big.Int).Mul(c, modValue))
+	v := new(big.Int).Sub(prover.u, new(big.Int).Mul(c, prover.x))
 	v = v.Mod(v, zkpcp.C.Params().N)
 
-	return &GSPFSProof{base, uG, v, c}, nil
+	return &GSPFSProof{prover.base, prover.uG, v, c}
 }
 
-// Verify (GSPFSVerify) checks if GSPFSProof proof is a valid proof for commitment A
+// Verify (GSPFSVerify) checks if GSPFSProof proof is a valid proof for
+// commitment A over the base it carries, use VerifyBase to make sure that is
+// the expected one
 func (proof *GSPFSProof) Verify(zkpcp ZKPCurveParams, A ECPoint) (bool, error) {
+	return proof.verify(zkpcp, A, nil)
+}
 
+// VerifyWithMessage is the same as Verify for a GSPFSProof generated by
+// NewGSPFSProofWithMessage, which only verifies for the same msg
+func (proof *GSPFSProof) VerifyWithMessage(zkpcp ZKPCurveParams, A ECPoint, msg []byte) (bool, error) {
+	return proof.verify(zkpcp, A, msg)
+}
+
+// verify is Verify for the proof bound to msg, which may be nil
+func (proof *GSPFSProof) verify(zkpcp ZKPCurveParams, A ECPoint, msg []byte) (bool, error) {
 	if proof == nil {
-		return false, &errorProof{"GSPFSProof.Verify", fmt.Sprintf("passed proof is nil")}
+		return false, malformedError("GSPFSProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(A) || !zkpcp.onCurve(proof.Base, proof.RandCommit) {
+		return false, curveError("GSPFSProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("GSPFSProof.Verify", "HiddenValue, Challenge", proof.HiddenValue, proof.Challenge); err != nil {
+		return false, err
 	}
 
 	// A = xG and RandCommit = uG
-	testC := GenerateChallenge(zkpcp, A.Bytes(), proof.RandCommit.Bytes())
+	testC := gspfsTranscript(zkpcp, proof.Base, A, proof.RandCommit, msg).ChallengeScalar("c")
 
-	if testC.Cmp(proof.Challenge) != 0 {
-		return false, &errorProof{"GSPFSProof.Verify", "calculated challenge and proof's challenge do not agree!"}
+	if !scalarsEqual(testC, proof.Challenge) {
+		return false, challengeError("GSPFSProof.Verify", "calculated challenge and proof's challenge do not agree!", testC, proof.Challenge)
 	}
 
-	// (u - c * x)G, look at HiddenValue from GSPFS.Proof()
-	s := zkpcp.Mult(proof.Base, proof.HiddenValue)
+	if err := proof.verifyResponses(zkpcp, A); err != nil {
+		return false, err
+	}
+	return true, nil
+}
 
-	// cResult = c(xG), we use testC as that follows the proof verficaion process more closely than using Challenge
-	c := zkpcp.Mult(A, proof.Challenge)
+// VerifyBase is Verify, but first checks that proof is a proof over base,
+// which may not be the point at infinity
+func (proof *GSPFSProof) VerifyBase(zkpcp ZKPCurveParams, base, A ECPoint) (bool, error) {
+	if proof == nil {
+		return false, malformedError("GSPFSProof.VerifyBase", "passed proof is nil")
+	}
+	if !zkpcp.validPoints(base) {
+		return false, curveError("GSPFSProof.VerifyBase")
+	}
+	if base.IsZero() {
+		return false, inputError("GSPFSProof.VerifyBase", "base is the point at infinity")
+	}
+	if !samePoint(proof.Base, base) {
+		return false, inputError("GSPFSProof.VerifyBase", "proof is for a different base")
+	}
+	return proof.Verify(zkpcp, A)
+}
 
-	// cxG + (u - cx)G = uG
-	tot := zkpcp.Add(s, c)
+// VerifyArgs implements Verifiable, the only arg is A like for Verify
+func (proof *GSPFSProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("GSPFSProof.VerifyArgs", args)
+	A := a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, A)
+}
 
-	if !proof.RandCommit.Equal(tot) {
-		return false, &errorProof{"GSPFSProof.Verify", "proof's final value and verification final value do not agree!"}
+// verifyResponses checks the verification equation of proof for its
+// challenge, which has to be checked by the caller
+func (proof *GSPFSProof) verifyResponses(zkpcp ZKPCurveParams, A ECPoint) error {
+	// (u - c * x)G, look at HiddenValue from GSPFS.Proof(), plus
+	// cResult = c(xG) gives cxG + (u - cx)G = uG
+	tot := zkpcp.MultiMult([]ECPoint{proof.Base, A}, []*big.Int{proof.HiddenValue, proof.Challenge})
+
+	if !pointsEqual(proof.RandCommit, tot) {
+		return equationError("GSPFSProof.Verify", 1, "proof's final value and verification final value do not agree: uG != sBase + cA", proof.RandCommit, tot)
 	}
-	return true, nil
+	return nil
+}
+
+// gspfsProofLen is the length of a serialized GSPFSProof
+var gspfsProofLen = 1 + 2*fixedPointLen + 2*fixedScalarLen
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *GSPFSProof) String() string {
+	if proof == nil {
+		return "GSPFSProof(nil)"
+	}
+	return proofString("GSPFSProof", "Base", proof.Base, "RandCommit", proof.RandCommit,
+		"HiddenValue", proof.HiddenValue, "Challenge", proof.Challenge)
 }
 
-// Bytes returns a byte slice with a serialized representation of GSPFSProof proof
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// GSPFSProof proof
 func (proof *GSPFSProof) Bytes() []byte {
 	var buf bytes.Buffer
 
-	WriteECPoint(&buf, proof.Base)
-	WriteECPoint(&buf, proof.RandCommit)
-	WriteBigInt(&buf, proof.HiddenValue)
-	WriteBigInt(&buf, proof.Challenge)
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.Base)
+	writeFixedPoint(&buf, proof.RandCommit)
+	writeFixedScalar(&buf, proof.HiddenValue)
+	writeFixedScalar(&buf, proof.Challenge)
 
 	return buf.Bytes()
 }
 
+// Size returns the length of Bytes of GSPFSProof proof, which has a fixed size
+func (proof *GSPFSProof) Size() int {
+	return gspfsProofLen
+}
+
+// Clone returns a deep copy of GSPFSProof proof that shares no big.Int with it
+func (proof *GSPFSProof) Clone() *GSPFSProof {
+	if proof == nil {
+		return nil
+	}
+	return &GSPFSProof{
+		Base:        clonePoint(proof.Base),
+		RandCommit:  clonePoint(proof.RandCommit),
+		HiddenValue: cloneScalar(proof.HiddenValue),
+		Challenge:   cloneScalar(proof.Challenge),
+	}
+}
+
+// Equal returns true if GSPFSProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *GSPFSProof) Equal(other *GSPFSProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.Base, other.Base) &&
+		samePoint(proof.RandCommit, other.RandCommit) &&
+		sameScalar(proof.HiddenValue, other.HiddenValue) &&
+		sameScalar(proof.Challenge, other.Challenge)
+}
+
 // NewGSPFSProofFromBytes returns a GSPFSProof generated from the
 // deserialization of byte slice b
 func NewGSPFSProofFromBytes(b []byte) (*GSPFSProof, error) {
+	if err := checkFixedHeader(b, gspfsProofLen, "GSPFSProofFromBytes"); err != nil {
+		return nil, err
+	}
 	proof := new(GSPFSProof)
-	buf := bytes.NewBuffer(b)
-	proof.Base, _ = ReadECPoint(buf)
-	proof.RandCommit, _ = ReadECPoint(buf)
-	proof.HiddenValue, _ = ReadBigInt(buf)
-	proof.Challenge, _ = ReadBigInt(buf)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.Base, err = readFixedPoint(buf, "GSPFSProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if proof.RandCommit, err = readFixedPoint(buf, "GSPFSProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "GSPFSProofFromBytes", &proof.HiddenValue, &proof.Challenge); err != nil {
+		return nil, err
+	}
 	return proof, nil
 }
+
+type gSPFSProofJSON struct {
+	Base        string `json:"base"`
+	RandCommit  string `json:"randCommit"`
+	HiddenValue string `json:"hiddenValue"`
+	Challenge   string `json:"challenge"`
+}
+
+// MarshalJSON encodes GSPFSProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *GSPFSProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(gSPFSProofJSON{
+		Base:        pointToHex(proof.Base),
+		RandCommit:  pointToHex(proof.RandCommit),
+		HiddenValue: scalarToHex(proof.HiddenValue),
+		Challenge:   scalarToHex(proof.Challenge),
+	})
+}
+
+// UnmarshalJSON decodes a GSPFSProof encoded with MarshalJSON. It fails if a
+// point is not on the curve or a scalar is not in [0, N)
+func (proof *GSPFSProof) UnmarshalJSON(b []byte) error {
+	var aux gSPFSProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := GSPFSProof{}
+	if p.Base, err = pointFromHex(aux.Base, "base"); err != nil {
+		return err
+	}
+	if p.RandCommit, err = pointFromHex(aux.RandCommit, "randCommit"); err != nil {
+		return err
+	}
+	if p.HiddenValue, err = scalarFromHex(aux.HiddenValue, "hiddenValue"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
@@ -1,7 +1,10 @@
 package zksigma
 
 import (
+	"bytes"
 	"crypto/rand"
+	"errors"
+	"math/big"
 	"testing"
 )
 
@@ -47,7 +50,11 @@ func TestGSPFSSerialization(t *testing.T) {
 	Base := TestCurve.G
 	CM := TestCurve.Mult(TestCurve.G, value)
 	proof, err := NewGSPFSProofBase(TestCurve, Base, CM, value)
-	proof, err = NewGSPFSProofFromBytes(proof.Bytes())
+	encoded := proof.Bytes()
+	if len(encoded) != gspfsProofLen || !bytes.Equal(encoded, proof.Bytes()) {
+		t.Fatalf("TestGSPFSSerialization produced a non-deterministic or wrongly sized encoding\n")
+	}
+	proof, err = NewGSPFSProofFromBytes(encoded)
 	if err != nil {
 		t.Fatalf("TestGSPFSSerialization failed to deserialize\n")
 	}
@@ -81,3 +88,85 @@ func BenchmarkGSPFS_Verify(b *testing.B) {
 		proof.Verify(TestCurve, CM)
 	}
 }
+
+func TestGSPFSBase(t *testing.T) {
+	N := TestCurve.C.Params().N
+	x, _ := rand.Int(rand.Reader, N)
+	_, other := KeyGen(TestCurve.C, TestCurve.G)
+	random := TestCurve.Mult(TestCurve.G, other)
+
+	for name, base := range map[string]ECPoint{"G": TestCurve.G, "H": TestCurve.H, "random": random} {
+		A := TestCurve.Mult(base, x)
+		proof, err := NewGSPFSProofBase(TestCurve, base, A, x)
+		if err != nil {
+			t.Fatalf("GSPFSProof over %s failed to generate: %v\n", name, err)
+		}
+		if ok, err := proof.VerifyBase(TestCurve, base, A); !ok || err != nil {
+			t.Fatalf("GSPFSProof over %s failed to verify: %v\n", name, err)
+		}
+	}
+
+	// a proof over G is no proof over H, even with its base replaced
+	A := TestCurve.Mult(TestCurve.G, x)
+	proof, err := NewGSPFSProof(TestCurve, A, x)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.VerifyBase(TestCurve, TestCurve.H, A); ok || !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("GSPFSProof over G verified over H: %v\n", err)
+	}
+	replayed := proof.Clone()
+	replayed.Base = TestCurve.H
+	// RandCommit = sH + cA would make the equation hold for the new base
+	replayed.RandCommit = TestCurve.MultiMult([]ECPoint{TestCurve.H, A}, []*big.Int{proof.HiddenValue, proof.Challenge})
+	if ok, err := replayed.VerifyBase(TestCurve, TestCurve.H, A); ok || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("GSPFSProof moved from G to H gives %v\n", err)
+	}
+
+	// the point at infinity is no base
+	if _, err := NewGSPFSProofBase(TestCurve, Zero, Zero, x); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("GSPFSProof over Zero gives %v\n", err)
+	}
+	if ok, err := proof.VerifyBase(TestCurve, Zero, A); ok || !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("GSPFSProof verified over Zero gives %v\n", err)
+	}
+	zeroBase := proof.Clone()
+	zeroBase.Base = Zero
+	if ok, err := zeroBase.Verify(TestCurve, Zero); ok || !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("GSPFSProof carrying base Zero gives %v\n", err)
+	}
+}
+
+func TestGSPFSProofWithMessage(t *testing.T) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	msg := []byte("transaction 1")
+	defer func(old bool) { LegacyChallenges = old }(LegacyChallenges)
+
+	for _, legacy := range []bool{false, true} {
+		LegacyChallenges = legacy
+
+		proof, err := NewGSPFSProofWithMessage(TestCurve, TestCurve.H, PK, sk, msg)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if ok, err := proof.VerifyWithMessage(TestCurve, PK, msg); !ok || err != nil {
+			t.Fatalf("GSPFSProof with a message failed to verify, legacy %v: %v\n", legacy, err)
+		}
+		if ok, err := proof.VerifyWithMessage(TestCurve, PK, []byte("transaction 2")); ok || !errors.Is(err, ErrChallengeMismatch) {
+			t.Fatalf("GSPFSProof verified for another message, legacy %v: %v\n", legacy, err)
+		}
+		if ok, _ := proof.Verify(TestCurve, PK); ok {
+			t.Fatalf("GSPFSProof with a message verified without it, legacy %v\n", legacy)
+		}
+
+		plain, _ := NewGSPFSProofBaseWithRand(TestCurve, newSeededReader("gspfs"), TestCurve.H, PK, sk)
+		empty, _ := newGSPFSProof(TestCurve, newSeededReader("gspfs"), TestCurve.H, PK, sk, []byte{})
+		if !bytes.Equal(plain.Bytes(), empty.Bytes()) {
+			t.Fatalf("GSPFSProof with an empty message differs from one without, legacy %v\n", legacy)
+		}
+		if ok, err := plain.VerifyWithMessage(TestCurve, PK, nil); !ok || err != nil {
+			t.Fatalf("GSPFSProof failed to verify with a nil message: %v\n", err)
+		}
+	}
+}
@@ -1,43 +1,316 @@
 package zksigma
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/big"
 )
 
-type InequalityProof ABCProof
+// InequalityProof is a proof that two commitments CM1 = v1G + r1H and
+// CM2 = v2G + r2H hide different values. It shows that d = v1 - v2, the value
+// in D = CM1 - CM2, has a multiplicative inverse by committing to inv(d) and
+// proving that d * inv(d) = 1, like ABCProof does.
+//
+//  Public: generator points G and H, CM1, CM2
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows d = v1 - v2, r = r1 - r2
+//  D = CM1 - CM2 = dG + rH             D = CM1 - CM2
+//  select ub at random
+//  B = inv(d)G + ubH
+//  so G = dB + tH with t = -d * ub
+//  select u1, u2, u3 at random
+//  T1 = u1G + u2H
+//  T2 = u1B + u3H
+//  c = HASH(G, H, CM1, CM2, B, T1, T2)
+//  s1 = u1 + c * d
+//  s2 = u2 + c * r
+//  s3 = u3 + c * t
+//
+//  B, T1, T2, c, s1, s2, s3 ---------->
+//                                      c ?= HASH(G, H, CM1, CM2, B, T1, T2)
+//                                      s1G + s2H ?= T1 + cD
+//                                      s1B + s3H ?= T2 + cG
+//
+// If d was 0 the prover would know t with G = tH, the relation between G and H.
+type InequalityProof struct {
+	B         ECPoint  // B = inv(d)G + ubH
+	T1        ECPoint  // T1 = u1G + u2H
+	T2        ECPoint  // T2 = u1B + u3H
+	Challenge *big.Int // c = HASH(G, H, CM1, CM2, B, T1, T2)
+	S1        *big.Int // s1 = u1 + c * d
+	S2        *big.Int // s2 = u2 + c * r
+	S3        *big.Int // s3 = u3 + c * t
+}
+
+// inequalityChallenge computes c = HASH(G, H, CM1, CM2, B, T1, T2)
+func inequalityChallenge(zkpcp ZKPCurveParams, CM1, CM2, B, T1, T2 ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "InequalityProof")
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendPoint("CM1", CM1)
+	t.AppendPoint("CM2", CM2)
+	t.AppendPoint("B", B)
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	return t.ChallengeScalar("c")
+}
+
+// NewInequalityProof generates a proof that CM1 = v1G + r1H and CM2 = v2G + r2H
+// commit to different values. It fails if v1 and v2 are equal modulo N.
+func NewInequalityProof(zkpcp ZKPCurveParams, CM1, CM2 ECPoint, v1, v2, r1, r2 *big.Int) (*InequalityProof, error) {
+	return NewInequalityProofWithRand(zkpcp, entropy(), CM1, CM2, v1, v2, r1, r2)
+}
+
+// NewInequalityProofWithRand is the same as NewInequalityProof, but reads its random
+// values from rnd instead of the EntropySource
+func NewInequalityProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader, CM1, CM2 ECPoint, v1, v2, r1, r2 *big.Int) (*InequalityProof, error) {
+	N := zkpcp.C.Params().N
+
+	if err := zkpcp.checkScalars("InequalityProve", "v1, v2, r1, r2", v1, v2, r1, r2); err != nil {
+		return nil, err
+	}
+
+	d := new(big.Int).Sub(v1, v2)
+	d.Mod(d, N)
+	if d.Sign() == 0 {
+		return nil, inputError("InequalityProve", "v1 and v2 should not be equal")
+	}
+	r := new(big.Int).Sub(r1, r2)
+	r.Mod(r, N)
+
+	if !CM1.Equal(PedCommitR(zkpcp, v1, r1)) || !CM2.Equal(PedCommitR(zkpcp, v2, r2)) {
+		return nil, inputError("InequalityProve", "values and randomness do not produce CM1 and CM2")
+	}
+
+	us, err := zkpcp.randScalars(rnd, 4)
+	if err != nil {
+		return nil, err
+	}
+	ub, u1, u2, u3 := us[0], us[1], us[2], us[3]
+
+	// B = inv(d)G + ubH
+	B := PedCommitR(zkpcp, new(big.Int).ModInverse(d, N), ub)
+	// t = -d * ub
+	t := new(big.Int).Neg(new(big.Int).Mul(d, ub))
+
+	// T1 = u1G + u2H
+	T1 := PedCommitR(zkpcp, u1, u2)
+	// T2 = u1B + u3H
+	T2 := zkpcp.Add(zkpcp.Mult(B, u1), zkpcp.Mult(zkpcp.H, u3))
 
-// InequalityProve generates a proof to show that two commitments, A and B, are not equal
-// Given two commitments A and B that we know the values for - a and b respectively - we
-// can prove that a != b without needed any new commitments, just generate a proof
-// There is no Inequality verify since this generates an ABCProof, so just use ABCVerify
-func NewInequalityProof(zkpcp ZKPCurveParams, A, B, CMTokA, CMTokB ECPoint, a, b, sk *big.Int) (*InequalityProof, error) {
+	c := inequalityChallenge(zkpcp, CM1, CM2, B, T1, T2)
 
-	if a.Cmp(b) == 0 {
-		return nil, &errorProof{"InequalityProve", "a and b should not be equal..."}
+	// s = u + c * x
+	response := func(u, x *big.Int) *big.Int {
+		s := new(big.Int).Add(u, new(big.Int).Mul(c, x))
+		return s.Mod(s, N)
 	}
 
-	// should I check if a > b? I think that shouldn't be a problem
-	// generate a-b for ABCProof, D will be created  commitment
-	value := new(big.Int).Sub(a, b)
-	CM := zkpcp.Sub(A, B)
+	return &InequalityProof{
+		B:         B,
+		T1:        T1,
+		T2:        T2,
+		Challenge: c,
+		S1:        response(u1, d),
+		S2:        response(u2, r),
+		S3:        response(u3, t),
+	}, nil
+}
+
+// Verify checks if InequalityProof ieProof shows that CM1 and CM2 commit to
+// different values
+func (ieProof *InequalityProof) Verify(zkpcp ZKPCurveParams, CM1, CM2 ECPoint) (bool, error) {
+	if ieProof == nil {
+		return false, malformedError("InequalityProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(CM1, CM2) || !zkpcp.onCurve(ieProof.B, ieProof.T1, ieProof.T2) {
+		return false, curveError("InequalityProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("InequalityProof.Verify", "Challenge, S1, S2, S3", ieProof.Challenge, ieProof.S1, ieProof.S2, ieProof.S3); err != nil {
+		return false, err
+	}
+
+	c := inequalityChallenge(zkpcp, CM1, CM2, ieProof.B, ieProof.T1, ieProof.T2)
+	if !scalarsEqual(c, ieProof.Challenge) {
+		return false, challengeError("InequalityProof.Verify", "calculated challenge and proof's challenge do not agree", c, ieProof.Challenge)
+	}
 
-	CMTok := zkpcp.Sub(CMTokA, CMTokB)
+	negC := new(big.Int).Neg(c)
 
-	proof, proofStatus := NewABCProof(zkpcp, CM, CMTok, value, sk, Right)
+	// s1G + s2H ?= T1 + cD, checked as s1G + s2H - cD ?= T1
+	D := zkpcp.Sub(CM1, CM2)
+	lhs := zkpcp.MultiMult([]ECPoint{zkpcp.G, zkpcp.H, D}, []*big.Int{ieProof.S1, ieProof.S2, negC})
+	if !pointsEqual(lhs, ieProof.T1) {
+		return false, equationError("InequalityProof.Verify", 1, "s1G + s2H - cD != T1", lhs, ieProof.T1)
+	}
 
-	if proofStatus != nil {
-		return nil, proofStatus
+	// s1B + s3H ?= T2 + cG, checked as s1B + s3H - cG ?= T2
+	lhs = zkpcp.MultiMult([]ECPoint{ieProof.B, zkpcp.H, zkpcp.G}, []*big.Int{ieProof.S1, ieProof.S3, negC})
+	if !pointsEqual(lhs, ieProof.T2) {
+		return false, equationError("InequalityProof.Verify", 2, "s1B + s3H - cG != T2", lhs, ieProof.T2)
 	}
 
-	return ((*InequalityProof)(proof)), proofStatus
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are CM1 and CM2 like for Verify
+func (ieProof *InequalityProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("InequalityProof.VerifyArgs", args)
+	CM1, CM2 := a.point(), a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return ieProof.Verify(zkpcp, CM1, CM2)
 }
 
-// Verify checks if InequalityProof ieProof with appropriate commits CM and CMTok is correct
-func (ieProof *InequalityProof) Verify(zkpcp ZKPCurveParams, CM, CMTok ECPoint) (bool, error) {
+// String returns a labeled dump of the fields of ieProof for debugging
+func (ieProof *InequalityProof) String() string {
 	if ieProof == nil {
-		return false, &errorProof{"InequalityProof.Verify", fmt.Sprintf("passed proof is nil")}
+		return "InequalityProof(nil)"
 	}
+	return proofString("InequalityProof", "B", ieProof.B, "T1", ieProof.T1, "T2", ieProof.T2,
+		"Challenge", ieProof.Challenge, "S1", ieProof.S1, "S2", ieProof.S2, "S3", ieProof.S3)
+}
+
+// inequalityProofLen is the length of a serialized InequalityProof
+var inequalityProofLen = 1 + 3*fixedPointLen + 4*fixedScalarLen
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// InequalityProof proof
+func (proof *InequalityProof) Bytes() []byte {
+	var buf bytes.Buffer
 
-	return ((*ABCProof)(ieProof)).Verify(zkpcp, CM, CMTok)
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.B)
+	writeFixedPoint(&buf, proof.T1)
+	writeFixedPoint(&buf, proof.T2)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.S1)
+	writeFixedScalar(&buf, proof.S2)
+	writeFixedScalar(&buf, proof.S3)
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of InequalityProof proof, which has a fixed size
+func (proof *InequalityProof) Size() int {
+	return inequalityProofLen
+}
+
+// Clone returns a deep copy of InequalityProof proof that shares no big.Int with it
+func (proof *InequalityProof) Clone() *InequalityProof {
+	if proof == nil {
+		return nil
+	}
+	return &InequalityProof{
+		B:         clonePoint(proof.B),
+		T1:        clonePoint(proof.T1),
+		T2:        clonePoint(proof.T2),
+		Challenge: cloneScalar(proof.Challenge),
+		S1:        cloneScalar(proof.S1),
+		S2:        cloneScalar(proof.S2),
+		S3:        cloneScalar(proof.S3),
+	}
+}
+
+// Equal returns true if InequalityProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *InequalityProof) Equal(other *InequalityProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.B, other.B) &&
+		samePoint(proof.T1, other.T1) &&
+		samePoint(proof.T2, other.T2) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.S1, other.S1) &&
+		sameScalar(proof.S2, other.S2) &&
+		sameScalar(proof.S3, other.S3)
+}
+
+// NewInequalityProofFromBytes returns an InequalityProof generated from the
+// deserialization of byte slice b
+func NewInequalityProofFromBytes(b []byte) (*InequalityProof, error) {
+	if err := checkFixedHeader(b, inequalityProofLen, "InequalityProofFromBytes"); err != nil {
+		return nil, err
+	}
+	proof := new(InequalityProof)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.B, err = readFixedPoint(buf, "InequalityProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if proof.T1, err = readFixedPoint(buf, "InequalityProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if proof.T2, err = readFixedPoint(buf, "InequalityProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "InequalityProofFromBytes", &proof.Challenge, &proof.S1, &proof.S2, &proof.S3); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+type inequalityProofJSON struct {
+	B         string `json:"b"`
+	T1        string `json:"t1"`
+	T2        string `json:"t2"`
+	Challenge string `json:"challenge"`
+	S1        string `json:"s1"`
+	S2        string `json:"s2"`
+	S3        string `json:"s3"`
+}
+
+// MarshalJSON encodes InequalityProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *InequalityProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(inequalityProofJSON{
+		B:         pointToHex(proof.B),
+		T1:        pointToHex(proof.T1),
+		T2:        pointToHex(proof.T2),
+		Challenge: scalarToHex(proof.Challenge),
+		S1:        scalarToHex(proof.S1),
+		S2:        scalarToHex(proof.S2),
+		S3:        scalarToHex(proof.S3),
+	})
+}
+
+// UnmarshalJSON decodes an InequalityProof encoded with MarshalJSON. It fails
+// if a point is not on the curve or a scalar is not in [0, N)
+func (proof *InequalityProof) UnmarshalJSON(b []byte) error {
+	var aux inequalityProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := InequalityProof{}
+	if p.B, err = pointFromHex(aux.B, "b"); err != nil {
+		return err
+	}
+	if p.T1, err = pointFromHex(aux.T1, "t1"); err != nil {
+		return err
+	}
+	if p.T2, err = pointFromHex(aux.T2, "t2"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.S1, err = scalarFromHex(aux.S1, "s1"); err != nil {
+		return err
+	}
+	if p.S2, err = scalarFromHex(aux.S2, "s2"); err != nil {
+		return err
+	}
+	if p.S3, err = scalarFromHex(aux.S3, "s3"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
 }
@@ -8,78 +8,80 @@ import (
 
 func TestInequalityProve(t *testing.T) {
 
-	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
 	a, _ := rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic range"
-	b, _ := rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic range"
-	A, ua, err := PedCommit(TestCurve, a)
+	N := TestCurve.C.Params().N
+
+	for _, b := range []*big.Int{
+		new(big.Int).Add(a, big.NewInt(1)),                       // differ by 1
+		new(big.Int).Sub(a, big.NewInt(1)),                       // differ by -1
+		new(big.Int).Mod(new(big.Int).Add(a, big.NewInt(-1)), N), // differ by N-1
+		new(big.Int).Add(a, big.NewInt(1234567)),
+	} {
+		A, ua, err := PedCommit(TestCurve, a)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		B, ub, err := PedCommit(TestCurve, b)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+
+		proof, err := NewInequalityProof(TestCurve, A, B, a, b, ua, ub)
+		if err != nil {
+			t.Fatalf("InequalityProof failed to generate for %v != %v: %v\n", a, b, err)
+		}
+
+		check, err := proof.Verify(TestCurve, A, B)
+		if !check || err != nil {
+			t.Fatalf("InequalityProof failed to verify for %v != %v: %v\n", a, b, err)
+		}
+
+		// Swapped commitments do not verify
+		check, err = proof.Verify(TestCurve, B, A)
+		if check || err == nil {
+			t.Fatalf("InequalityProof verified for swapped commitments\n")
+		}
+
+		proof, err = NewInequalityProofFromBytes(proof.Bytes())
+		if err != nil {
+			t.Fatalf("InequalityProof failed to deserialize: %v\n", err)
+		}
+		check, err = proof.Verify(TestCurve, A, B)
+		if !check || err != nil {
+			t.Fatalf("InequalityProof failed to verify after deserialization: %v\n", err)
+		}
+	}
+}
 
+func TestInequalityProveEqual(t *testing.T) {
+	a := big.NewInt(42)
+	A, ua, err := PedCommit(TestCurve, a)
 	if err != nil {
 		t.Fatalf("%v\n", err)
 	}
-
-	B, ub, err := PedCommit(TestCurve, b)
+	B, ub, err := PedCommit(TestCurve, a)
 	if err != nil {
 		t.Fatalf("%v\n", err)
 	}
 
-	PK := TestCurve.Mult(TestCurve.H, sk)
-
-	// Even though we generated the values for ua and ub in this test case, we do not
-	// need to know ua or ub, only the commitment tokens are needed
-	CMTokA := TestCurve.Mult(PK, ua)
-	CMTokB := TestCurve.Mult(PK, ub)
-
-	aProof, status := NewInequalityProof(TestCurve, A, B, CMTokA, CMTokB, a, b, sk)
-
-	if status != nil {
-		proofStatus(status.(*errorProof))
-		t.Logf("ABCProof for InequalityProve failed to generate!\n")
-		t.Fatalf("ABCProof for InequalityProve failed\n")
-	}
-
-	check, err := aProof.Verify(TestCurve, TestCurve.Sub(A, B), TestCurve.Sub(CMTokA, CMTokB))
-	if !check || err != nil {
-		t.Logf("ABCProof for InequalityProve failed to verify!\n")
-		t.Fatalf("ABCVerify for InequalityProve failed\n")
-	}
-
-	// Swapped positions of commitments, tokens and values, should work just fine
-	aProof, status = NewInequalityProof(TestCurve, B, A, CMTokB, CMTokA, b, a, sk)
-
-	if status != nil {
-		proofStatus(status.(*errorProof))
-		t.Logf("ABCProof for InequalityProve failed to generate!\n")
-		t.Fatalf("ABCProof for InequalityProve failed\n")
-	}
-
-	check, err = aProof.Verify(TestCurve, TestCurve.Sub(B, A), TestCurve.Sub(CMTokB, CMTokA))
-	if !check || err != nil {
-		t.Logf("ABCProof for InequalityProve failed to verify!\n")
-		t.Fatalf("ABCVerify for InequalityProve failed\n")
+	if _, err := NewInequalityProof(TestCurve, A, B, a, a, ua, ub); err == nil {
+		t.Fatalf("InequalityProof should not generate for equal values\n")
 	}
 
-	// Mismatched commitments and values, a proof does generate but the
-	// verification step will catch the false proof.
-	// Use the -debug1 flag to see this in action
-	aProof, status = NewInequalityProof(TestCurve, A, B, CMTokA, CMTokB, b, a, sk)
-
-	if status != nil {
-		proofStatus(status.(*errorProof))
-		t.Logf("ABCProof for InequalityProve failed to generate!\n")
-		t.Fatalf("ABCProof for InequalityProve failed\n")
+	// a and a + N are the same value on the curve
+	aN := new(big.Int).Add(a, TestCurve.C.Params().N)
+	if _, err := NewInequalityProof(TestCurve, A, B, a, aN, ua, ub); err == nil {
+		t.Fatalf("InequalityProof should not generate for values equal mod N\n")
 	}
 
-	check, err = aProof.Verify(TestCurve, TestCurve.Sub(A, B), TestCurve.Sub(CMTokA, CMTokB))
-	if check || err == nil {
-		t.Logf("ABCProof for InequalityProve failed to verify!\n")
-		t.Fatalf("ABCVerify for InequalityProve failed\n")
+	// Lying about a value is caught before a proof is made
+	if _, err := NewInequalityProof(TestCurve, A, B, a, big.NewInt(43), ua, ub); err == nil {
+		t.Fatalf("InequalityProof should not generate for values that do not open B\n")
 	}
-
 }
 
 func BenchmarkInequalityProve(b *testing.B) {
 
-	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
 	a, _ := rand.Int(rand.Reader, big.NewInt(10000000000))      // "realistic range"
 	bValue, _ := rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic range"
 	A, ua, err := PedCommit(TestCurve, a)
@@ -93,15 +95,22 @@ func BenchmarkInequalityProve(b *testing.B) {
 		b.Fatalf("%v\n", err)
 	}
 
-	PK := TestCurve.Mult(TestCurve.H, sk)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewInequalityProof(TestCurve, A, B, a, bValue, ua, ub)
+	}
+}
+
+func BenchmarkInequalityVerify(b *testing.B) {
 
-	// even though we generated the values for ua and ub in this test case, we do not
-	// need to know ua or ub, only the commitment tokens, which is already used in many other proofs
-	CMTokA := TestCurve.Mult(PK, ua)
-	CMTokB := TestCurve.Mult(PK, ub)
+	a, _ := rand.Int(rand.Reader, big.NewInt(10000000000))      // "realistic range"
+	bValue, _ := rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic range"
+	A, ua, _ := PedCommit(TestCurve, a)
+	B, ub, _ := PedCommit(TestCurve, bValue)
+	proof, _ := NewInequalityProof(TestCurve, A, B, a, bValue, ua, ub)
 
 	b.ResetTimer()
 	for ii := 0; ii < b.N; ii++ {
-		NewInequalityProof(TestCurve, A, B, CMTokA, CMTokB, a, bValue, sk)
+		proof.Verify(TestCurve, A, B)
 	}
 }
@@ -0,0 +1,334 @@
+package zksigma
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The Bytes of a proof do not say which type of proof they are, most of them
+// start with the same version byte. EncodeProof puts a tag byte for the type
+// in front, so InspectProof and VerifyEncoded can decode a proof of any
+// registered type, e.g. one copied from a log as hex.
+
+// firstProofTag is the smallest tag of a ProofType. The tags below it are
+// kept free, so the Bytes of a proof, which start with the version byte 0x01,
+// 0x03 or 0x04 or a small count, are not taken for the typed encoding of one.
+const firstProofTag byte = 0x10
+
+// ProofType is a proof type for EncodeProof, InspectProof and VerifyEncoded
+type ProofType struct {
+	Tag    byte                               // first byte of the typed encoding, at least 0x10
+	Name   string                             // name of the type, e.g. "SumProof"
+	Decode func(b []byte) (Verifiable, error) // NewXFromBytes of the type
+	// Points are the names of the points Verify takes, in order. It is nil if
+	// Verify takes other arguments too, VerifyEncoded does not verify those.
+	Points []string
+}
+
+// encodable is a proof that EncodeProof can encode
+type encodable interface {
+	Verifiable
+	Bytes() []byte
+}
+
+var (
+	proofTypesMu sync.RWMutex
+	proofTypes   = map[byte]ProofType{}
+	proofTags    = map[reflect.Type]byte{}
+)
+
+// builtinProofTypes are the tags of the proof types of this package. A tag
+// must never change or be given to another type, a new type takes the next
+// free one.
+var builtinProofTypes = []struct {
+	tag    byte
+	proof  encodable
+	decode func(b []byte) (Verifiable, error)
+	points []string
+}{
+	{0x10, (*ABCProof)(nil), func(b []byte) (Verifiable, error) { return NewABCProofFromBytes(b) },
+		[]string{"CM", "CMTok", "PK"}},
+	{0x11, (*AggregateRangeProof)(nil), func(b []byte) (Verifiable, error) { return NewAggregateRangeProofFromBytes(b) }, nil},
+	{0x12, (*BalanceProof)(nil), func(b []byte) (Verifiable, error) { return NewBalanceProofFromBytes(b) }, nil},
+	{0x13, (*BitRangeProof)(nil), func(b []byte) (Verifiable, error) { return NewBitRangeProofFromBytes(b) }, nil},
+	{0x14, (*ComparisonProof)(nil), func(b []byte) (Verifiable, error) { return NewComparisonProofFromBytes(b) }, nil},
+	{0x15, (*ConsistencyProof)(nil), func(b []byte) (Verifiable, error) { return NewConsistencyProofFromBytes(b) },
+		[]string{"CM", "CMTok", "PubKey"}},
+	{0x16, (*DecryptionProof)(nil), func(b []byte) (Verifiable, error) { return NewDecryptionProofFromBytes(b) },
+		[]string{"CM", "CMTok", "PK", "claimedValueG"}},
+	{0x17, (*DisjunctiveProof)(nil), func(b []byte) (Verifiable, error) { return NewDisjunctiveProofFromBytes(b) },
+		[]string{"Base1", "Result1", "Base2", "Result2"}},
+	{0x18, (*EquivOrZeroProof)(nil), func(b []byte) (Verifiable, error) { return NewEquivOrZeroProofFromBytes(b) },
+		[]string{"CMChange", "CMDelta"}},
+	{0x19, (*EquivalenceProof)(nil), func(b []byte) (Verifiable, error) { return NewEquivalenceProofFromBytes(b) },
+		[]string{"Base1", "Result1", "Base2", "Result2"}},
+	{0x1a, (*GSPFSProof)(nil), func(b []byte) (Verifiable, error) { return NewGSPFSProofFromBytes(b) },
+		[]string{"A"}},
+	{0x1b, (*InequalityProof)(nil), func(b []byte) (Verifiable, error) { return NewInequalityProofFromBytes(b) },
+		[]string{"CM1", "CM2"}},
+	{0x1c, (*KeyRotationProof)(nil), func(b []byte) (Verifiable, error) { return NewKeyRotationProofFromBytes(b) },
+		[]string{"oldCMTok", "newCMTok", "oldPK", "newPK"}},
+	{0x1d, (*NonZeroProof)(nil), func(b []byte) (Verifiable, error) { return NewNonZeroProofFromBytes(b) },
+		[]string{"CM"}},
+	{0x1e, (*OneOfManyProof)(nil), func(b []byte) (Verifiable, error) { return NewOneOfManyProofFromBytes(b) }, nil},
+	{0x1f, (*OpenProof)(nil), func(b []byte) (Verifiable, error) { return NewOpenProofFromBytes(b) },
+		[]string{"CM"}},
+	{0x20, (*OpenToValueProof)(nil), func(b []byte) (Verifiable, error) { return NewOpenToValueProofFromBytes(b) }, nil},
+	{0x21, (*ProductProof)(nil), func(b []byte) (Verifiable, error) { return NewProductProofFromBytes(b) },
+		[]string{"A", "B", "C"}},
+	{0x22, (*RangeProof)(nil), func(b []byte) (Verifiable, error) { return NewRangeProofFromBytes(b) },
+		[]string{"comm"}},
+	{0x23, (*RerandomizationProof)(nil), func(b []byte) (Verifiable, error) { return NewRerandomizationProofFromBytes(b) },
+		[]string{"CM", "CMPrime"}},
+	{0x24, (*SameBlindingProof)(nil), func(b []byte) (Verifiable, error) { return NewSameBlindingProofFromBytes(b) },
+		[]string{"CMTok1", "CMTok2", "PK1", "PK2"}},
+	{0x25, (*SameValueProof)(nil), func(b []byte) (Verifiable, error) { return NewSameValueProofFromBytes(b) },
+		[]string{"CM1", "CM2"}},
+	{0x26, (*SetMembershipProof)(nil), func(b []byte) (Verifiable, error) { return NewSetMembershipProofFromBytes(b) }, nil},
+	{0x27, (*ShuffleProof)(nil), func(b []byte) (Verifiable, error) { return NewShuffleProofFromBytes(b) }, nil},
+	{0x28, (*SumProof)(nil), func(b []byte) (Verifiable, error) { return NewSumProofFromBytes(b) }, nil},
+	{0x29, (*VectorOpenProof)(nil), func(b []byte) (Verifiable, error) { return NewVectorOpenProofFromBytes(b) },
+		[]string{"C"}},
+	{0x2a, (*MultiProductProof)(nil), func(b []byte) (Verifiable, error) { return NewMultiProductProofFromBytes(b) }, nil},
+	{0x2b, (*BoundedRangeProof)(nil), func(b []byte) (Verifiable, error) { return NewBoundedRangeProofFromBytes(b) }, nil},
+	{0x2c, (*MultiRangeProof)(nil), func(b []byte) (Verifiable, error) { return NewMultiRangeProofFromBytes(b) }, nil},
+	{0x2d, (*BitProof)(nil), func(b []byte) (Verifiable, error) { return NewBitProofFromBytes(b) }, []string{"C"}},
+	{0x2e, (*EntryProof)(nil), func(b []byte) (Verifiable, error) { return NewEntryProofFromBytes(b) },
+		[]string{"CM", "CMTok", "pk"}},
+}
+
+func init() {
+	for _, b := range builtinProofTypes {
+		RegisterProofType(b.tag, b.proof, b.decode, b.points)
+	}
+}
+
+// RegisterProofType makes the type of proof, e.g. (*SumProof)(nil), known to
+// EncodeProof, InspectProof and VerifyEncoded under tag. decode is its
+// NewXFromBytes and points the names of the points its Verify takes, nil if
+// it takes other arguments too. It is meant to be called from an init
+// function, and panics if tag is below 0x10 or taken, the type is registered
+// already or decode is nil.
+func RegisterProofType(tag byte, proof Verifiable, decode func(b []byte) (Verifiable, error), points []string) {
+	proofTypesMu.Lock()
+	defer proofTypesMu.Unlock()
+	typ := reflect.TypeOf(proof)
+	if typ == nil || decode == nil {
+		panic("zksigma: RegisterProofType of a nil proof or decode")
+	}
+	if _, ok := proof.(encodable); !ok {
+		panic(fmt.Sprintf("zksigma: RegisterProofType of %v without Bytes", typ))
+	}
+	if tag < firstProofTag {
+		panic(fmt.Sprintf("zksigma: RegisterProofType with reserved tag 0x%02x", tag))
+	}
+	if pt, ok := proofTypes[tag]; ok {
+		panic(fmt.Sprintf("zksigma: RegisterProofType of %v with the tag 0x%02x of %s", typ, tag, pt.Name))
+	}
+	if _, ok := proofTags[typ]; ok {
+		panic(fmt.Sprintf("zksigma: RegisterProofType called twice for %v", typ))
+	}
+	name := typ.Name()
+	if typ.Kind() == reflect.Ptr {
+		name = typ.Elem().Name()
+	}
+	proofTypes[tag] = ProofType{tag, name, decode, append([]string(nil), points...)}
+	proofTags[typ] = tag
+}
+
+// ProofTypes returns the registered proof types sorted by tag
+func ProofTypes() []ProofType {
+	proofTypesMu.RLock()
+	defer proofTypesMu.RUnlock()
+	types := make([]ProofType, 0, len(proofTypes))
+	for _, pt := range proofTypes {
+		types = append(types, pt)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Tag < types[j].Tag })
+	return types
+}
+
+// EncodeProof returns the typed encoding of proof p: the tag of its
+// ProofType followed by its Bytes
+func EncodeProof(p Verifiable) ([]byte, error) {
+	if v := reflect.ValueOf(p); p == nil || v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, malformedError("EncodeProof", "passed proof is nil")
+	}
+	proofTypesMu.RLock()
+	tag, ok := proofTags[reflect.TypeOf(p)]
+	proofTypesMu.RUnlock()
+	if !ok {
+		return nil, inputError("EncodeProof", fmt.Sprintf("%T is not a registered proof type", p))
+	}
+	return append([]byte{tag}, p.(encodable).Bytes()...), nil
+}
+
+// DecodeProof returns the proof of typed encoding data, see EncodeProof, with
+// its ProofType
+func DecodeProof(data []byte) (Verifiable, ProofType, error) {
+	if len(data) == 0 {
+		return nil, ProofType{}, malformedError("DecodeProof", "no proof type tag")
+	}
+	proofTypesMu.RLock()
+	pt, ok := proofTypes[data[0]]
+	n := len(proofTypes)
+	proofTypesMu.RUnlock()
+	if !ok {
+		if data[0] < firstProofTag {
+			return nil, ProofType{}, malformedError("DecodeProof", fmt.Sprintf(
+				"unknown proof type tag 0x%02x, which looks like the Bytes of a proof instead of its EncodeProof", data[0]))
+		}
+		return nil, ProofType{}, malformedError("DecodeProof", fmt.Sprintf(
+			"unknown proof type tag 0x%02x, ProofTypes has the %d known ones", data[0], n))
+	}
+	p, err := pt.Decode(data[1:])
+	if err != nil {
+		return nil, ProofType{}, wrapError("DecodeProof", fmt.Sprintf("tag 0x%02x is %s, which fails to decode", pt.Tag, pt.Name), err)
+	}
+	return p, pt, nil
+}
+
+// ProofInfo describes the fields of a proof for a person to read, see
+// InspectProof. A field F that is a list has one entry "F[i]" per element
+// instead, and the fields of an element that is not a point, scalar or proof
+// are "F[i].G".
+type ProofInfo struct {
+	Type    string                // name of the proof type, e.g. "SumProof"
+	Size    int                   // length of the Bytes of the proof
+	Points  map[string]ECPoint    // the points of the proof by field
+	Scalars map[string]*big.Int   // the scalars of the proof by field
+	Proofs  map[string]*ProofInfo // the embedded proofs by field
+
+	fields []interface{} // names and values in the order of the proof
+}
+
+// InspectProof decodes the typed encoding data of a proof, see EncodeProof,
+// and returns a ProofInfo of its fields. The proof is not verified, see
+// VerifyEncoded.
+func InspectProof(data []byte) (*ProofInfo, error) {
+	p, pt, err := DecodeProof(data)
+	if err != nil {
+		return nil, err
+	}
+	return newProofInfo(pt.Name, p), nil
+}
+
+// newProofInfo returns the ProofInfo of proof p of type t, with the exported
+// fields of p and the unexported ones listed here
+func newProofInfo(t string, p interface{}) *ProofInfo {
+	info := &ProofInfo{
+		Type:    t,
+		Size:    -1,
+		Points:  map[string]ECPoint{},
+		Scalars: map[string]*big.Int{},
+		Proofs:  map[string]*ProofInfo{},
+	}
+	if s, ok := p.(interface{ Size() int }); ok {
+		info.Size = s.Size()
+	}
+	info.addFields("", reflect.Indirect(reflect.ValueOf(p)))
+	if abc, ok := p.(*ABCProof); ok {
+		for _, f := range []struct {
+			name  string
+			value interface{}
+		}{{"j", abc.j}, {"k", abc.k}, {"l", abc.l}, {"disjuncAC", abc.disjuncAC}, {"cTokenProof", abc.cTokenProof}} {
+			info.add(f.name, reflect.ValueOf(f.value))
+		}
+	}
+	return info
+}
+
+// addFields adds the exported fields of struct v with their names after prefix
+func (info *ProofInfo) addFields(prefix string, v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Type().Field(i); f.PkgPath == "" {
+			info.add(prefix+f.Name, v.Field(i))
+		}
+	}
+}
+
+// add adds field name of value v, unless it is no point, scalar or proof
+func (info *ProofInfo) add(name string, v reflect.Value) {
+	switch f := v.Interface().(type) {
+	case ECPoint:
+		info.Points[name] = f
+		info.fields = append(info.fields, name, f)
+		return
+	case *big.Int:
+		if f != nil {
+			info.Scalars[name] = f
+			info.fields = append(info.fields, name, f)
+		}
+		return
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			info.add(fmt.Sprintf("%s[%d]", name, i), v.Index(i))
+		}
+	case reflect.Struct:
+		info.addFields(name+".", v)
+	case reflect.Ptr:
+		if _, ok := v.Interface().(Verifiable); ok && !v.IsNil() {
+			sub := newProofInfo(v.Type().Elem().Name(), v.Interface())
+			info.Proofs[name] = sub
+			info.fields = append(info.fields, name, sub)
+		}
+	}
+}
+
+// String returns the fields of the proof of info in their order, one per line
+func (info *ProofInfo) String() string {
+	if info == nil {
+		return "ProofInfo(nil)"
+	}
+	return proofString(fmt.Sprintf("%s (%d bytes) ", info.Type, info.Size), info.fields...)
+}
+
+// VerifyEncoded verifies the proof of typed encoding data, see EncodeProof,
+// for the points of statement like VerifyProof. statement has the points that
+// Verify of the proof type takes by their names in ProofType.Points, e.g. "CM"
+// for a NonZeroProof, and no others. Proof types whose Verify takes other
+// arguments too fail with ErrInvalidInput.
+func VerifyEncoded(zkpcp ZKPCurveParams, data []byte, statement map[string]ECPoint) error {
+	p, pt, err := DecodeProof(data)
+	if err != nil {
+		return err
+	}
+	if pt.Points == nil {
+		return inputError("VerifyEncoded", fmt.Sprintf("%s is not verified for points only", pt.Name))
+	}
+	args := make([]interface{}, len(pt.Points))
+	for i, name := range pt.Points {
+		P, ok := statement[name]
+		if !ok {
+			return inputError("VerifyEncoded", fmt.Sprintf("statement of %s has no point %s, it takes %s",
+				pt.Name, name, strings.Join(pt.Points, ", ")))
+		}
+		args[i] = P
+	}
+	if len(statement) != len(pt.Points) {
+		var unused []string
+		for name := range statement {
+			if !containsString(pt.Points, name) {
+				unused = append(unused, name)
+			}
+		}
+		sort.Strings(unused)
+		return inputError("VerifyEncoded", fmt.Sprintf("%s does not take point %s", pt.Name, strings.Join(unused, ", ")))
+	}
+	return VerifyProof(zkpcp, p, args...)
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package zksigma
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// wrappedOpenProof is an OpenProof of another type, registered like a proof
+// type of another package
+type wrappedOpenProof struct {
+	*OpenProof
+}
+
+const wrappedOpenProofTag byte = 0xf0
+
+func init() {
+	RegisterProofType(wrappedOpenProofTag, wrappedOpenProof{}, func(b []byte) (Verifiable, error) {
+		p, err := NewOpenProofFromBytes(b)
+		return wrappedOpenProof{p}, err
+	}, []string{"CM"})
+}
+
+func TestInspectProof(t *testing.T) {
+	cases := verifiableProofs(t)
+	for _, pt := range ProofTypes() {
+		if _, ok := cases[pt.Name]; !ok && pt.Tag != wrappedOpenProofTag {
+			t.Fatalf("%s has no verifiableProofs case\n", pt.Name)
+		}
+	}
+
+	for name, c := range cases {
+		data, err := EncodeProof(c.proof)
+		if name == "ComposedProof" {
+			// its statements are not points and it has no Bytes
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Fatalf("EncodeProof of ComposedProof gives %v\n", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("EncodeProof of %s failed: %v\n", name, err)
+		}
+		info, err := InspectProof(data)
+		if err != nil {
+			t.Fatalf("InspectProof of %s failed: %v\n", name, err)
+		}
+		if info.Type != name || info.Size != len(data)-1 || len(info.Points)+len(info.Scalars)+len(info.Proofs) == 0 {
+			t.Fatalf("InspectProof of %s gives %s\n", name, info)
+		}
+		if !strings.HasPrefix(info.String(), name+" (") {
+			t.Fatalf("ProofInfo of %s has String %s\n", name, info)
+		}
+
+		_, pt, err := DecodeProof(data)
+		if err != nil || pt.Name != name || pt.Tag != data[0] {
+			t.Fatalf("DecodeProof of %s gives %+v, %v\n", name, pt, err)
+		}
+		if pt.Points == nil {
+			if err := VerifyEncoded(TestCurve, data, nil); !errors.Is(err, ErrInvalidInput) {
+				t.Fatalf("VerifyEncoded of %s for points gives %v\n", name, err)
+			}
+			continue
+		}
+		statement := map[string]ECPoint{}
+		for i, point := range pt.Points {
+			statement[point] = c.args[i].(ECPoint)
+		}
+		if err := VerifyEncoded(TestCurve, data, statement); err != nil {
+			t.Fatalf("VerifyEncoded of %s failed: %v\n", name, err)
+		}
+		first := pt.Points[0]
+		statement[first] = TestCurve.Add(statement[first], TestCurve.G)
+		if err := VerifyEncoded(TestCurve, data, statement); err == nil {
+			t.Fatalf("VerifyEncoded of %s verified for another %s\n", name, first)
+		}
+		statement["Extra"] = TestCurve.G
+		if err := VerifyEncoded(TestCurve, data, statement); !errors.Is(err, ErrInvalidInput) || !strings.Contains(err.Error(), "Extra") {
+			t.Fatalf("VerifyEncoded of %s with an extra point gives %v\n", name, err)
+		}
+		delete(statement, first)
+		if err := VerifyEncoded(TestCurve, data, statement); !errors.Is(err, ErrInvalidInput) || !strings.Contains(err.Error(), first) {
+			t.Fatalf("VerifyEncoded of %s without %s gives %v\n", name, first, err)
+		}
+	}
+}
+
+func TestInspectProofFields(t *testing.T) {
+	cases := verifiableProofs(t)
+	inspect := func(name string) *ProofInfo {
+		data, err := EncodeProof(cases[name].proof)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		info, err := InspectProof(data)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		return info
+	}
+
+	// the nested proofs and responses of ABCProof are not exported
+	abc := inspect("ABCProof")
+	for _, s := range []string{"Challenge", "j", "k", "l"} {
+		if abc.Scalars[s] == nil {
+			t.Fatalf("ProofInfo of ABCProof has no scalar %s: %s\n", s, abc)
+		}
+	}
+	if abc.Proofs["disjuncAC"].Type != "DisjunctiveProof" || abc.Proofs["cTokenProof"].Type != "ConsistencyProof" {
+		t.Fatalf("ProofInfo of ABCProof has proofs %v\n", abc.Proofs)
+	}
+	if !abc.Points["CToken"].Equal(cases["ABCProof"].proof.(*ABCProof).CToken) {
+		t.Fatalf("ProofInfo of ABCProof has CToken %v\n", abc.Points["CToken"])
+	}
+
+	// a list gives a field per entry, a list of structs one per field of an entry
+	br := inspect("BitRangeProof")
+	if len(br.Points) != 4 || len(br.Proofs) != 4 || br.Proofs["BitProofs[3]"].Scalars["S2"] == nil {
+		t.Fatalf("ProofInfo of a 4 bit BitRangeProof is %s\n", br)
+	}
+	rp := inspect("RangeProof")
+	if _, ok := rp.Points["ProofTuples[0].C"]; !ok || rp.Scalars["ProofTuples[0].S"] == nil {
+		t.Fatalf("ProofInfo of RangeProof is %s\n", rp)
+	}
+	if cmp := inspect("ComparisonProof"); cmp.Proofs["Difference"].Type != "BitRangeProof" {
+		t.Fatalf("ProofInfo of ComparisonProof is %s\n", cmp)
+	}
+}
+
+func TestDecodeProofErrors(t *testing.T) {
+	CM, r, _ := PedCommit(TestCurve, big.NewInt(5))
+	op, err := NewOpenProof(TestCurve, CM, big.NewInt(5), r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	data, err := EncodeProof(op)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	for name, c := range map[string]struct {
+		data []byte
+		msg  string
+	}{
+		"empty":     {nil, "no proof type tag"},
+		"unknown":   {append([]byte{0xff}, op.Bytes()...), "unknown proof type tag 0xff"},
+		"untagged":  {op.Bytes(), "looks like the Bytes of a proof"},
+		"truncated": {data[:len(data)-1], "is OpenProof"},
+		"other tag": {append([]byte{0x28}, op.Bytes()...), "is SumProof"},
+	} {
+		if _, err := InspectProof(c.data); !errors.Is(err, ErrMalformedProof) || !strings.Contains(err.Error(), c.msg) {
+			t.Fatalf("InspectProof of %s gives %v\n", name, err)
+		}
+		if err := VerifyEncoded(TestCurve, c.data, map[string]ECPoint{"CM": CM}); !errors.Is(err, ErrMalformedProof) {
+			t.Fatalf("VerifyEncoded of %s gives %v\n", name, err)
+		}
+	}
+
+	if _, err := EncodeProof((*OpenProof)(nil)); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("EncodeProof of a nil proof gives %v\n", err)
+	}
+	if _, err := EncodeProof(nil); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("EncodeProof of nil gives %v\n", err)
+	}
+}
+
+func TestRegisterProofType(t *testing.T) {
+	CM, r, _ := PedCommit(TestCurve, big.NewInt(7))
+	op, err := NewOpenProof(TestCurve, CM, big.NewInt(7), r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	data, err := EncodeProof(wrappedOpenProof{op})
+	if err != nil || data[0] != wrappedOpenProofTag {
+		t.Fatalf("EncodeProof of a registered type gives %x, %v\n", data, err)
+	}
+	if info, err := InspectProof(data); err != nil || info.Type != "wrappedOpenProof" || info.Proofs["OpenProof"] == nil {
+		t.Fatalf("InspectProof of a registered type gives %s, %v\n", info, err)
+	}
+	if err := VerifyEncoded(TestCurve, data, map[string]ECPoint{"CM": CM}); err != nil {
+		t.Fatalf("VerifyEncoded of a registered type failed: %v\n", err)
+	}
+
+	decode := func(b []byte) (Verifiable, error) { return NewOpenProofFromBytes(b) }
+	for name, register := range map[string]func(){
+		"twice":    func() { RegisterProofType(0xf1, (*OpenProof)(nil), decode, nil) },
+		"tag":      func() { RegisterProofType(0x1f, (*wrappedOpenProof)(nil), decode, nil) },
+		"reserved": func() { RegisterProofType(0x01, wrappedOpenProof{}, decode, nil) },
+		"nil":      func() { RegisterProofType(0xf1, nil, decode, nil) },
+		"decode":   func() { RegisterProofType(0xf1, wrappedOpenProof{}, nil, nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("RegisterProofType %s does not panic\n", name)
+				}
+			}()
+			register()
+		}()
+	}
+}
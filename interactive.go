@@ -0,0 +1,313 @@
+package zksigma
+
+import (
+	"io"
+	"math/big"
+)
+
+// The proofs are made non-interactive by deriving their challenges from a
+// hash, but ABCProof and DisjunctiveProof can also be run as the three move
+// protocols they come from, where the verifier picks the challenge:
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  prover := NewABCProver(...)         session := NewABCVerifierSession(...)
+//  commit := prover.Commit()
+//  commit ---------------------------->
+//                                      c, err := session.Challenge()
+//  <---------------------------------- c
+//  resp, err := prover.Respond(c)
+//  resp ------------------------------>
+//                                      ok, err := session.Check(commit, resp)
+//
+// NewABCProof and NewDisjunctiveProof run the same prover with the challenges
+// of their transcripts instead. The unknown side of a DisjunctiveProof is
+// simulated with its own challenge before the commitment is sent, so it does
+// not depend on c.
+//
+// A prover must answer only one challenge: the responses to two challenges for
+// the same commitment reveal the witness, which is why the proof is sound.
+// Respond fails the second time it is called. The verifier must draw its
+// challenge only after it got the commitment and may not reuse it for another
+// one. The messages are not bound to anything else, so the protocol only
+// convinces the verifier that runs it and leaves nothing to show to others.
+
+// DisjunctiveCommitment is the first message of an interactive
+// DisjunctiveProof, T1 and T2 in the order the proof has them
+type DisjunctiveCommitment struct {
+	T1, T2 ECPoint
+}
+
+// DisjunctiveResponse is the answer of a DisjunctiveProver to challenge c,
+// which it splits into c = C1 + C2
+type DisjunctiveResponse struct {
+	C1, C2 *big.Int
+	S1, S2 *big.Int
+}
+
+// DisjunctiveProver is the prover of an interactive DisjunctiveProof. It is not
+// safe for concurrent use.
+type DisjunctiveProver struct {
+	zkpcp     ZKPCurveParams
+	p         *disjunctiveProver
+	responded bool
+}
+
+// NewDisjunctiveProver commits to a DisjunctiveProof for the same inputs as
+// NewDisjunctiveProof, which fails in the same cases
+func NewDisjunctiveProver(zkpcp ZKPCurveParams,
+	Base1, Result1, Base2, Result2 ECPoint, x *big.Int, option Side) (*DisjunctiveProver, error) {
+	return NewDisjunctiveProverWithRand(zkpcp, entropy(), Base1, Result1, Base2, Result2, x, option)
+}
+
+// NewDisjunctiveProverWithRand is the same as NewDisjunctiveProver, but reads
+// its random values from rnd instead of the EntropySource
+func NewDisjunctiveProverWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	Base1, Result1, Base2, Result2 ECPoint, x *big.Int, option Side) (*DisjunctiveProver, error) {
+	if !zkpcp.validPoints(Base1, Result1, Base2, Result2) {
+		return nil, curveError("DisjunctiveProve")
+	}
+	p, err := disjunctiveCommit(zkpcp, rnd, Base1, Result1, Base2, Result2, x, option)
+	if err != nil {
+		return nil, err
+	}
+	return &DisjunctiveProver{zkpcp: zkpcp, p: p}, nil
+}
+
+// Commit returns the commitment to send to the verifier
+func (prover *DisjunctiveProver) Commit() DisjunctiveCommitment {
+	T1, T2 := prover.p.commitments()
+	return DisjunctiveCommitment{T1, T2}
+}
+
+// Respond returns the response to the challenge of the verifier. It fails if
+// challenge is not in [0, N) or if it was called before.
+func (prover *DisjunctiveProver) Respond(challenge *big.Int) (*DisjunctiveResponse, error) {
+	if err := prover.zkpcp.checkScalars("DisjunctiveProver.Respond", "challenge", challenge); err != nil {
+		return nil, err
+	}
+	if prover.responded {
+		return nil, inputError("DisjunctiveProver.Respond", "already responded, a second response reveals x")
+	}
+	prover.responded = true
+
+	proof := prover.p.respond(prover.zkpcp, challenge)
+	return &DisjunctiveResponse{proof.C1, proof.C2, proof.S1, proof.S2}, nil
+}
+
+// DisjunctiveVerifierSession is the verifier of an interactive DisjunctiveProof
+// for Base1, Result1, Base2 and Result2. It is not safe for concurrent use.
+type DisjunctiveVerifierSession struct {
+	zkpcp                          ZKPCurveParams
+	rnd                            io.Reader
+	Base1, Result1, Base2, Result2 ECPoint
+	challenge                      *big.Int
+}
+
+// NewDisjunctiveVerifierSession returns a DisjunctiveVerifierSession for the
+// statement that the prover knows x with Result1 = xBase1 or Result2 = xBase2
+func NewDisjunctiveVerifierSession(zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2 ECPoint) (*DisjunctiveVerifierSession, error) {
+	return NewDisjunctiveVerifierSessionWithRand(zkpcp, entropy(), Base1, Result1, Base2, Result2)
+}
+
+// NewDisjunctiveVerifierSessionWithRand is the same as
+// NewDisjunctiveVerifierSession, but draws the challenge from rnd instead of
+// the EntropySource
+func NewDisjunctiveVerifierSessionWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	Base1, Result1, Base2, Result2 ECPoint) (*DisjunctiveVerifierSession, error) {
+	if !zkpcp.validPoints(Base1, Result1, Base2, Result2) {
+		return nil, curveError("NewDisjunctiveVerifierSession")
+	}
+	return &DisjunctiveVerifierSession{zkpcp: zkpcp, rnd: rnd,
+		Base1: Base1, Result1: Result1, Base2: Base2, Result2: Result2}, nil
+}
+
+// Challenge returns the challenge to send to the prover once it committed. It
+// is drawn on the first call, later calls return the same one.
+func (s *DisjunctiveVerifierSession) Challenge() (*big.Int, error) {
+	if s.challenge == nil {
+		c, err := s.zkpcp.randScalar(s.rnd)
+		if err != nil {
+			return nil, err
+		}
+		s.challenge = c
+	}
+	return new(big.Int).Set(s.challenge), nil
+}
+
+// Check returns true if resp answers the challenge of session s for commit,
+// with the same checks as Verify of a DisjunctiveProof except for the hash
+func (s *DisjunctiveVerifierSession) Check(commit DisjunctiveCommitment, resp *DisjunctiveResponse) (bool, error) {
+	if s.challenge == nil {
+		return false, inputError("DisjunctiveVerifierSession.Check", "no challenge was drawn")
+	}
+	if resp == nil || resp.C1 == nil || resp.C2 == nil || resp.S1 == nil || resp.S2 == nil {
+		return false, malformedError("DisjunctiveVerifierSession.Check", "response is missing a scalar")
+	}
+	if !s.zkpcp.onCurve(commit.T1, commit.T2) {
+		return false, curveError("DisjunctiveVerifierSession.Check")
+	}
+	if err := s.zkpcp.canonicalScalars("DisjunctiveVerifierSession.Check", "C1, C2, S1, S2",
+		resp.C1, resp.C2, resp.S1, resp.S2); err != nil {
+		return false, err
+	}
+
+	proof := &DisjunctiveProof{commit.T1, commit.T2, s.challenge, resp.C1, resp.C2, resp.S1, resp.S2}
+	if err := proof.verifyResponses(s.zkpcp, s.Base1, s.Result1, s.Base2, s.Result2, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ABCCommitment is the first message of an interactive ABCProof, the points of
+// the proof and the commitments of its nested DisjunctiveProof and of the
+// ConsistencyProof of C and CToken
+type ABCCommitment struct {
+	B, C, T1, T2, T3, CToken ECPoint
+	Disjunctive              DisjunctiveCommitment
+	CTokenT1, CTokenT2       ECPoint
+}
+
+// ABCResponse is the answer of an ABCProver to a challenge, which answers the
+// nested proofs as well
+type ABCResponse struct {
+	J, K, L            *big.Int
+	Disjunctive        *DisjunctiveResponse
+	CTokenS1, CTokenS2 *big.Int
+}
+
+// ABCProver is the prover of an interactive ABCProof. It is not safe for
+// concurrent use.
+type ABCProver struct {
+	zkpcp     ZKPCurveParams
+	p         *abcProver
+	responded bool
+}
+
+// NewABCProver commits to an ABCProof for the same inputs as NewABCProof, which
+// fails in the same cases
+func NewABCProver(zkpcp ZKPCurveParams, CM, CMTok ECPoint, value, sk *big.Int, option Side) (*ABCProver, error) {
+	return NewABCProverWithRand(zkpcp, entropy(), CM, CMTok, value, sk, option)
+}
+
+// NewABCProverWithRand is the same as NewABCProver, but reads its random values
+// from rnd instead of the EntropySource
+func NewABCProverWithRand(zkpcp ZKPCurveParams, rnd io.Reader, CM, CMTok ECPoint, value, sk *big.Int, option Side) (*ABCProver, error) {
+	p, err := abcCommit(zkpcp, rnd, CM, CMTok, value, sk, option)
+	if err != nil {
+		return nil, err
+	}
+	return &ABCProver{zkpcp: zkpcp, p: p}, nil
+}
+
+// Commit returns the commitment to send to the verifier
+func (prover *ABCProver) Commit() ABCCommitment {
+	p := prover.p
+	djT1, djT2 := p.disjuncAC.commitments()
+	return ABCCommitment{
+		B: p.B, C: p.C, T1: p.T1, T2: p.T2, T3: p.T3, CToken: p.CToken,
+		Disjunctive: DisjunctiveCommitment{djT1, djT2},
+		CTokenT1:    p.cTokenProof.T1, CTokenT2: p.cTokenProof.T2,
+	}
+}
+
+// Respond returns the response to the challenge of the verifier, the nested
+// proofs answer the same challenge. It fails if challenge is not in [0, N) or
+// if it was called before.
+func (prover *ABCProver) Respond(challenge *big.Int) (*ABCResponse, error) {
+	if err := prover.zkpcp.checkScalars("ABCProver.Respond", "challenge", challenge); err != nil {
+		return nil, err
+	}
+	if prover.responded {
+		return nil, inputError("ABCProver.Respond", "already responded, a second response reveals value and sk")
+	}
+	prover.responded = true
+
+	proof := prover.p.respond(prover.zkpcp, challenge, challenge, challenge)
+	dj := proof.disjuncAC
+	return &ABCResponse{
+		J: proof.j, K: proof.k, L: proof.l,
+		Disjunctive: &DisjunctiveResponse{dj.C1, dj.C2, dj.S1, dj.S2},
+		CTokenS1:    proof.cTokenProof.S1, CTokenS2: proof.cTokenProof.S2,
+	}, nil
+}
+
+// ABCVerifierSession is the verifier of an interactive ABCProof for CM, CMTok
+// and the auditor public key PK. It is not safe for concurrent use.
+type ABCVerifierSession struct {
+	zkpcp         ZKPCurveParams
+	rnd           io.Reader
+	CM, CMTok, PK ECPoint
+	challenge     *big.Int
+}
+
+// NewABCVerifierSession returns an ABCVerifierSession for CM, CMTok and PK
+func NewABCVerifierSession(zkpcp ZKPCurveParams, CM, CMTok, PK ECPoint) (*ABCVerifierSession, error) {
+	return NewABCVerifierSessionWithRand(zkpcp, entropy(), CM, CMTok, PK)
+}
+
+// NewABCVerifierSessionWithRand is the same as NewABCVerifierSession, but draws
+// the challenge from rnd instead of the EntropySource
+func NewABCVerifierSessionWithRand(zkpcp ZKPCurveParams, rnd io.Reader, CM, CMTok, PK ECPoint) (*ABCVerifierSession, error) {
+	if !zkpcp.validPoints(CM, CMTok, PK) {
+		return nil, curveError("NewABCVerifierSession")
+	}
+	if CM.IsZero() || CMTok.IsZero() {
+		return nil, inputError("NewABCVerifierSession", "CM and CMTok must not be Zero")
+	}
+	return &ABCVerifierSession{zkpcp: zkpcp, rnd: rnd, CM: CM, CMTok: CMTok, PK: PK}, nil
+}
+
+// Challenge returns the challenge to send to the prover once it committed. It
+// is drawn on the first call, later calls return the same one.
+func (s *ABCVerifierSession) Challenge() (*big.Int, error) {
+	if s.challenge == nil {
+		c, err := s.zkpcp.randScalar(s.rnd)
+		if err != nil {
+			return nil, err
+		}
+		s.challenge = c
+	}
+	return new(big.Int).Set(s.challenge), nil
+}
+
+// Check returns true if resp answers the challenge of session s for commit,
+// with the same checks as Verify of an ABCProof except for the hashes
+func (s *ABCVerifierSession) Check(commit ABCCommitment, resp *ABCResponse) (bool, error) {
+	if s.challenge == nil {
+		return false, inputError("ABCVerifierSession.Check", "no challenge was drawn")
+	}
+	if resp == nil || resp.J == nil || resp.K == nil || resp.L == nil || resp.CTokenS1 == nil || resp.CTokenS2 == nil {
+		return false, malformedError("ABCVerifierSession.Check", "response is missing a scalar")
+	}
+	zkpcp, c := s.zkpcp, s.challenge
+
+	if !zkpcp.onCurve(commit.B, commit.C, commit.T1, commit.T2, commit.T3, commit.CToken, commit.CTokenT1, commit.CTokenT2) {
+		return false, curveError("ABCVerifierSession.Check")
+	}
+	if err := zkpcp.canonicalScalars("ABCVerifierSession.Check", "J, K, L, CTokenS1, CTokenS2",
+		resp.J, resp.K, resp.L, resp.CTokenS1, resp.CTokenS2); err != nil {
+		return false, err
+	}
+
+	// the nested proofs get the same challenge
+	dj, err := NewDisjunctiveVerifierSession(zkpcp, s.CM, s.CMTok, zkpcp.H, zkpcp.Sub(commit.C, zkpcp.G))
+	if err != nil {
+		return false, err
+	}
+	dj.challenge = c
+	if _, err := dj.Check(commit.Disjunctive, resp.Disjunctive); err != nil {
+		return false, subProofError("ABCVerifierSession.Check", "ABCProof for disjuncAC is false", err)
+	}
+	cTokenProof := &ConsistencyProof{commit.CTokenT1, commit.CTokenT2, c, resp.CTokenS1, resp.CTokenS2}
+	if err := cTokenProof.verifyResponses(zkpcp, commit.C, commit.CToken, s.PK, nil); err != nil {
+		return false, subProofError("ABCVerifierSession.Check", "CToken does not match C and PK", err)
+	}
+
+	proof := &ABCProof{B: commit.B, C: commit.C, T1: commit.T1, T2: commit.T2, T3: commit.T3,
+		Challenge: c, j: resp.J, k: resp.K, l: resp.L, CToken: commit.CToken}
+	if err := proof.verifyResponses(zkpcp, s.CM, s.CMTok, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,321 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// extractWitness returns (s - s2) / (c - c2), the witness x of two responses
+// s = u + cx and s2 = u + c2x to the same commitment
+func extractWitness(zkpcp ZKPCurveParams, s, s2, c, c2 *big.Int) *big.Int {
+	N := zkpcp.C.Params().N
+	num := new(big.Int).Sub(s, s2)
+	den := new(big.Int).Sub(c, c2)
+	den.Mod(den, N)
+	num.Mul(num, new(big.Int).ModInverse(den, N))
+	return num.Mod(num, N)
+}
+
+func TestDisjunctiveInteractive(t *testing.T) {
+	x, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	y, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	Base1, Base2 := TestCurve.G, TestCurve.H
+	Result1, Result2 := TestCurve.Mult(Base1, x), TestCurve.Mult(Base2, y)
+
+	for _, c := range []struct {
+		side Side
+		w    *big.Int
+	}{{Left, x}, {Right, y}} {
+		prover, err := NewDisjunctiveProver(TestCurve, Base1, Result1, Base2, Result2, c.w, c.side)
+		if err != nil {
+			t.Fatalf("TestDisjunctiveInteractive - side %v: %v\n", c.side, err)
+		}
+		session, err := NewDisjunctiveVerifierSession(TestCurve, Base1, Result1, Base2, Result2)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		commit := prover.Commit()
+		if _, err := session.Check(commit, &DisjunctiveResponse{}); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("TestDisjunctiveInteractive - Check before Challenge gives %v\n", err)
+		}
+		challenge, err := session.Challenge()
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if again, _ := session.Challenge(); again.Cmp(challenge) != 0 {
+			t.Fatalf("TestDisjunctiveInteractive - the challenge changed\n")
+		}
+		resp, err := prover.Respond(challenge)
+		if err != nil {
+			t.Fatalf("TestDisjunctiveInteractive - side %v failed to respond: %v\n", c.side, err)
+		}
+		if ok, err := session.Check(commit, resp); !ok || err != nil {
+			t.Fatalf("TestDisjunctiveInteractive - side %v did not check out: %v\n", c.side, err)
+		}
+		if _, err := prover.Respond(challenge); err == nil {
+			t.Fatalf("TestDisjunctiveInteractive - side %v responded twice\n", c.side)
+		}
+
+		// the response only fits this challenge and commitment
+		other, _ := NewDisjunctiveVerifierSession(TestCurve, Base1, Result1, Base2, Result2)
+		other.Challenge()
+		if ok, _ := other.Check(commit, resp); ok {
+			t.Fatalf("TestDisjunctiveInteractive - side %v checked out for another challenge\n", c.side)
+		}
+		swapped := DisjunctiveCommitment{commit.T2, commit.T1}
+		if ok, _ := session.Check(swapped, resp); ok {
+			t.Fatalf("TestDisjunctiveInteractive - side %v checked out for another commitment\n", c.side)
+		}
+		bad := *resp
+		bad.S1 = new(big.Int).Add(bad.S1, big.NewInt(1))
+		if ok, err := session.Check(commit, &bad); ok || !errors.Is(err, ErrEquationFailed) {
+			t.Fatalf("TestDisjunctiveInteractive - side %v with a changed response gives %v, %v\n", c.side, ok, err)
+		}
+		if ok, err := session.Check(commit, nil); ok || !errors.Is(err, ErrMalformedProof) {
+			t.Fatalf("TestDisjunctiveInteractive - nil response gives %v, %v\n", ok, err)
+		}
+	}
+
+	prover, _ := NewDisjunctiveProver(TestCurve, Base1, Result1, Base2, Result2, x, Left)
+	if _, err := prover.Respond(TestCurve.C.Params().N); !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("TestDisjunctiveInteractive - challenge N gives %v\n", err)
+	}
+	if _, err := NewDisjunctiveProver(TestCurve, Base1, Result1, Base2, Result2, x, Right); !errors.Is(err, ErrInvalidSide) {
+		t.Fatalf("TestDisjunctiveInteractive - wrong side gives %v\n", err)
+	}
+}
+
+// TestDisjunctiveExtraction checks special soundness: two responses to
+// different challenges for the same commitment reveal the witness, which is
+// only possible for a prover that knows it. The second response has to come
+// from the internal prover, Respond refuses to give it.
+func TestDisjunctiveExtraction(t *testing.T) {
+	x, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	Base1, Base2 := TestCurve.G, TestCurve.H
+	Result1, Result2 := TestCurve.Mult(Base1, x), TestCurve.Mult(Base2, x)
+
+	for _, side := range []Side{Left, Right} {
+		prover, err := NewDisjunctiveProver(TestCurve, Base1, Result1, Base2, Result2, x, side)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		commit := prover.Commit()
+		c, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+		c2, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+		resp, err := prover.Respond(c)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		p2 := prover.p.respond(TestCurve, c2)
+
+		for _, r := range []struct {
+			c    *big.Int
+			resp *DisjunctiveResponse
+		}{{c, resp}, {c2, &DisjunctiveResponse{p2.C1, p2.C2, p2.S1, p2.S2}}} {
+			session, _ := NewDisjunctiveVerifierSession(TestCurve, Base1, Result1, Base2, Result2)
+			session.challenge = r.c
+			if ok, err := session.Check(commit, r.resp); !ok || err != nil {
+				t.Fatalf("TestDisjunctiveExtraction - side %v response did not check out: %v\n", side, err)
+			}
+		}
+
+		// the simulated side keeps its challenge, the proved one answers
+		// the rest of c
+		var w *big.Int
+		if side == Left {
+			if resp.C2.Cmp(p2.C2) != 0 {
+				t.Fatalf("TestDisjunctiveExtraction - the simulated challenge depends on c\n")
+			}
+			w = extractWitness(TestCurve, resp.S1, p2.S1, resp.C1, p2.C1)
+		} else {
+			if resp.C1.Cmp(p2.C1) != 0 {
+				t.Fatalf("TestDisjunctiveExtraction - the simulated challenge depends on c\n")
+			}
+			w = extractWitness(TestCurve, resp.S2, p2.S2, resp.C2, p2.C2)
+		}
+		if w.Cmp(x) != 0 {
+			t.Fatalf("TestDisjunctiveExtraction - side %v extracted %v, not x\n", side, w)
+		}
+	}
+}
+
+func TestABCInteractive(t *testing.T) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+
+	for _, c := range []struct {
+		value *big.Int
+		side  Side
+	}{{big.NewInt(0), Left}, {big.NewInt(42), Right}} {
+		CM, r, _ := PedCommit(TestCurve, c.value)
+		CMTok := TestCurve.Mult(PK, r)
+		prover, err := NewABCProver(TestCurve, CM, CMTok, c.value, sk, c.side)
+		if err != nil {
+			t.Fatalf("TestABCInteractive - value %v: %v\n", c.value, err)
+		}
+		session, err := NewABCVerifierSession(TestCurve, CM, CMTok, PK)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		commit := prover.Commit()
+		if _, err := session.Check(commit, &ABCResponse{}); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("TestABCInteractive - Check before Challenge gives %v\n", err)
+		}
+		challenge, err := session.Challenge()
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		resp, err := prover.Respond(challenge)
+		if err != nil {
+			t.Fatalf("TestABCInteractive - value %v failed to respond: %v\n", c.value, err)
+		}
+		if ok, err := session.Check(commit, resp); !ok || err != nil {
+			t.Fatalf("TestABCInteractive - value %v did not check out: %v\n", c.value, err)
+		}
+		if _, err := prover.Respond(challenge); err == nil {
+			t.Fatalf("TestABCInteractive - value %v responded twice\n", c.value)
+		}
+
+		// every part of the response is checked
+		for i, change := range []func(r *ABCResponse){
+			func(r *ABCResponse) { r.J = new(big.Int).Add(r.J, big.NewInt(1)) },
+			func(r *ABCResponse) { r.K = new(big.Int).Add(r.K, big.NewInt(1)) },
+			func(r *ABCResponse) { r.L = new(big.Int).Add(r.L, big.NewInt(1)) },
+			func(r *ABCResponse) {
+				dj := *r.Disjunctive
+				dj.S2 = new(big.Int).Add(dj.S2, big.NewInt(1))
+				r.Disjunctive = &dj
+			},
+			func(r *ABCResponse) { r.CTokenS2 = new(big.Int).Add(r.CTokenS2, big.NewInt(1)) },
+			func(r *ABCResponse) { r.Disjunctive = nil },
+		} {
+			bad := *resp
+			change(&bad)
+			if ok, err := session.Check(commit, &bad); ok || err == nil {
+				t.Fatalf("TestABCInteractive - value %v checked out with change %d\n", c.value, i)
+			}
+		}
+		badCommit := commit
+		badCommit.CToken = TestCurve.Add(commit.CToken, TestCurve.G)
+		if ok, err := session.Check(badCommit, resp); ok || err == nil {
+			t.Fatalf("TestABCInteractive - value %v checked out with another CToken\n", c.value)
+		}
+		other, _ := NewABCVerifierSession(TestCurve, CM, CMTok, TestCurve.Add(PK, TestCurve.H))
+		other.challenge = challenge
+		if ok, _ := other.Check(commit, resp); ok {
+			t.Fatalf("TestABCInteractive - value %v checked out for another PK\n", c.value)
+		}
+	}
+}
+
+// TestABCExtraction checks that two responses of an ABCProver to the same
+// commitment reveal the value, inv(sk) and through the nested DisjunctiveProof
+// sk or the randomness of C
+func TestABCExtraction(t *testing.T) {
+	N := TestCurve.C.Params().N
+	sk, _ := rand.Int(rand.Reader, N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(42)
+	CM, r, _ := PedCommit(TestCurve, value)
+	CMTok := TestCurve.Mult(PK, r)
+
+	prover, err := NewABCProver(TestCurve, CM, CMTok, value, sk, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	commit := prover.Commit()
+	c, _ := rand.Int(rand.Reader, N)
+	c2, _ := rand.Int(rand.Reader, N)
+	resp, err := prover.Respond(c)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	p2 := prover.p.respond(TestCurve, c2, c2, c2)
+	resp2 := &ABCResponse{p2.j, p2.k, p2.l,
+		&DisjunctiveResponse{p2.disjuncAC.C1, p2.disjuncAC.C2, p2.disjuncAC.S1, p2.disjuncAC.S2},
+		p2.cTokenProof.S1, p2.cTokenProof.S2}
+	for i, rc := range []*big.Int{c, c2} {
+		session, _ := NewABCVerifierSession(TestCurve, CM, CMTok, PK)
+		session.challenge = rc
+		if ok, err := session.Check(commit, []*ABCResponse{resp, resp2}[i]); !ok || err != nil {
+			t.Fatalf("TestABCExtraction - response %d did not check out: %v\n", i, err)
+		}
+	}
+
+	if v := extractWitness(TestCurve, resp.J, resp2.J, c, c2); v.Cmp(value) != 0 {
+		t.Fatalf("TestABCExtraction - extracted value %v\n", v)
+	}
+	isk := extractWitness(TestCurve, resp.K, resp2.K, c, c2)
+	if new(big.Int).Mod(new(big.Int).Mul(isk, sk), N).Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("TestABCExtraction - did not extract inv(sk)\n")
+	}
+	// Right proves the randomness uc of C = G + ucH with C - G = ucH
+	uc := extractWitness(TestCurve, resp.Disjunctive.S2, resp2.Disjunctive.S2, resp.Disjunctive.C2, resp2.Disjunctive.C2)
+	if !TestCurve.Add(TestCurve.G, TestCurve.Mult(TestCurve.H, uc)).Equal(commit.C) {
+		t.Fatalf("TestABCExtraction - did not extract the randomness of C\n")
+	}
+	if ucToken := extractWitness(TestCurve, resp.CTokenS2, resp2.CTokenS2, c, c2); ucToken.Cmp(uc) != 0 {
+		t.Fatalf("TestABCExtraction - the ConsistencyProof of CToken gives another randomness\n")
+	}
+}
+
+// TestInteractiveNonInteractive checks that NewABCProof and
+// NewDisjunctiveProof are the interactive provers with derived challenges:
+// with the same random values both give the same commitment
+func TestInteractiveNonInteractive(t *testing.T) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(7)
+	CM, r, _ := PedCommit(TestCurve, value)
+	CMTok := TestCurve.Mult(PK, r)
+
+	proof, err := NewABCProofWithRand(TestCurve, newSeededReader("abc"), CM, CMTok, value, sk, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	prover, err := NewABCProverWithRand(TestCurve, newSeededReader("abc"), CM, CMTok, value, sk, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	commit := prover.Commit()
+	if !commit.B.Equal(proof.B) || !commit.T1.Equal(proof.T1) || !commit.T3.Equal(proof.T3) ||
+		!commit.Disjunctive.T1.Equal(proof.disjuncAC.T1) || !commit.CTokenT2.Equal(proof.cTokenProof.T2) {
+		t.Fatalf("TestInteractiveNonInteractive - ABCProver commits to something else than NewABCProof\n")
+	}
+
+	djProof, err := NewDisjunctiveProofWithRand(TestCurve, newSeededReader("dj"), TestCurve.H, PK, CM, CMTok, sk, Left)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	djProver, err := NewDisjunctiveProverWithRand(TestCurve, newSeededReader("dj"), TestCurve.H, PK, CM, CMTok, sk, Left)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	resp, err := djProver.Respond(djProof.C)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if djCommit := djProver.Commit(); !djCommit.T1.Equal(djProof.T1) || !djCommit.T2.Equal(djProof.T2) ||
+		resp.C1.Cmp(djProof.C1) != 0 || resp.S2.Cmp(djProof.S2) != 0 {
+		t.Fatalf("TestInteractiveNonInteractive - DisjunctiveProver gives another proof than NewDisjunctiveProof\n")
+	}
+}
+
+func BenchmarkABCInteractive(b *testing.B) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(42)
+	CM, r, _ := PedCommit(TestCurve, value)
+	CMTok := TestCurve.Mult(PK, r)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		prover, _ := NewABCProver(TestCurve, CM, CMTok, value, sk, Right)
+		session, _ := NewABCVerifierSession(TestCurve, CM, CMTok, PK)
+		commit := prover.Commit()
+		c, _ := session.Challenge()
+		resp, _ := prover.Respond(c)
+		session.Check(commit, resp)
+	}
+}
@@ -0,0 +1,298 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// RotateToken returns the token CMTok' = (newSK / oldSK) * CMTok, which is
+// r * newSK * H for a token CMTok = r * oldSK * H, so a token for the old key
+// of an auditor can be moved to its new key without knowing r. The owner of
+// both keys proves the two tokens are related with NewKeyRotationProof.
+func RotateToken(zkpcp ZKPCurveParams, CMTok ECPoint, oldSK, newSK *big.Int) (ECPoint, error) {
+	f, err := rotationFactor(zkpcp, "RotateToken", oldSK, newSK)
+	if err != nil {
+		return Zero, err
+	}
+	if !zkpcp.validPoints(CMTok) {
+		return Zero, curveError("RotateToken")
+	}
+	return zkpcp.Mult(CMTok, f), nil
+}
+
+// rotationFactor checks the keys of a rotation and returns newSK / oldSK mod N
+func rotationFactor(zkpcp ZKPCurveParams, t string, oldSK, newSK *big.Int) (*big.Int, error) {
+	if err := zkpcp.checkScalars(t, "oldSK, newSK", oldSK, newSK); err != nil {
+		return nil, err
+	}
+	if oldSK.Sign() == 0 || newSK.Sign() == 0 {
+		return nil, rangeError(t, "oldSK and newSK must not be zero")
+	}
+	N := zkpcp.C.Params().N
+	f := new(big.Int).ModInverse(oldSK, N)
+	f.Mul(f, newSK)
+	return f.Mod(f, N), nil
+}
+
+// KeyRotationProof is a proof that the token newCMTok is oldCMTok moved from
+// the key oldPK to newPK, as done by RotateToken. It is a Chaum-Pedersen
+// proof that the same factor f = newSK / oldSK takes oldPK to newPK and
+// oldCMTok to newCMTok, so newCMTok decrypts with newSK like oldCMTok does
+// with oldSK. Neither the keys nor the randomness of the token are revealed.
+//
+//  Public: oldPK, newPK, oldCMTok, newCMTok
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows f with newPK = f * oldPK
+//  and newCMTok = f * oldCMTok
+//  selects random u
+//  T1 = u * oldPK
+//  T2 = u * oldCMTok
+//  c = HASH(oldPK, newPK, oldCMTok, newCMTok, T1, T2)
+//  s = u + c * f
+//
+//  T1, T2, c, s ---------------------->
+//                                      c ?= HASH(oldPK, newPK, oldCMTok, newCMTok, T1, T2)
+//                                      s * oldPK ?= T1 + c * newPK
+//                                      s * oldCMTok ?= T2 + c * newCMTok
+type KeyRotationProof struct {
+	T1        ECPoint  // T1 = u * oldPK
+	T2        ECPoint  // T2 = u * oldCMTok
+	Challenge *big.Int // c = HASH(oldPK, newPK, oldCMTok, newCMTok, T1, T2)
+	S         *big.Int // s = u + c * f
+}
+
+// keyRotationChallenge computes c = HASH(oldPK, newPK, oldCMTok, newCMTok, T1, T2)
+func keyRotationChallenge(zkpcp ZKPCurveParams, oldCMTok, newCMTok, oldPK, newPK, T1, T2 ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "KeyRotationProof")
+	t.AppendPoint("oldPK", oldPK)
+	t.AppendPoint("newPK", newPK)
+	t.AppendPoint("oldCMTok", oldCMTok)
+	t.AppendPoint("newCMTok", newCMTok)
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	return t.ChallengeScalar("c")
+}
+
+// NewKeyRotationProof generates a proof that newCMTok is oldCMTok rotated
+// from oldPK = oldSK * H to newPK = newSK * H, e.g. the token returned by
+// RotateToken. It fails if oldSK or newSK is zero, if they are not the keys
+// of oldPK and newPK or if newCMTok is not oldCMTok rotated.
+func NewKeyRotationProof(zkpcp ZKPCurveParams, oldCMTok, newCMTok, oldPK, newPK ECPoint,
+	oldSK, newSK *big.Int) (*KeyRotationProof, error) {
+	return NewKeyRotationProofWithRand(zkpcp, entropy(), oldCMTok, newCMTok, oldPK, newPK, oldSK, newSK)
+}
+
+// NewKeyRotationProofWithRand is the same as NewKeyRotationProof, but reads
+// its random value from rnd instead of the EntropySource
+func NewKeyRotationProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	oldCMTok, newCMTok, oldPK, newPK ECPoint, oldSK, newSK *big.Int) (*KeyRotationProof, error) {
+
+	f, err := rotationFactor(zkpcp, "KeyRotationProve", oldSK, newSK)
+	if err != nil {
+		return nil, err
+	}
+	if !zkpcp.validPoints(oldCMTok, newCMTok, oldPK, newPK) {
+		return nil, curveError("KeyRotationProve")
+	}
+	if !zkpcp.Mult(zkpcp.H, oldSK).Equal(oldPK) || !zkpcp.Mult(zkpcp.H, newSK).Equal(newPK) {
+		return nil, inputError("KeyRotationProve", "oldSK and newSK are not the keys of oldPK and newPK")
+	}
+	// T2 would be the point at infinity, which a proof may not carry
+	if oldCMTok.IsZero() {
+		return nil, inputError("KeyRotationProve", "oldCMTok is the point at infinity")
+	}
+	if !zkpcp.Mult(oldCMTok, f).Equal(newCMTok) {
+		return nil, inputError("KeyRotationProve", "newCMTok is not oldCMTok rotated to newPK")
+	}
+
+	N := zkpcp.C.Params().N
+	u, err := zkpcp.randScalar(rnd)
+	if err != nil {
+		return nil, err
+	}
+
+	T1 := zkpcp.Mult(oldPK, u)
+	T2 := zkpcp.Mult(oldCMTok, u)
+	c := keyRotationChallenge(zkpcp, oldCMTok, newCMTok, oldPK, newPK, T1, T2)
+
+	s := new(big.Int).Add(u, new(big.Int).Mul(c, f))
+	s.Mod(s, N)
+
+	return &KeyRotationProof{T1, T2, c, s}, nil
+}
+
+// Verify checks if KeyRotationProof proof shows that newCMTok is oldCMTok
+// rotated from the key oldPK to newPK. oldPK and newPK must not be Zero.
+func (proof *KeyRotationProof) Verify(zkpcp ZKPCurveParams, oldCMTok, newCMTok, oldPK, newPK ECPoint) (bool, error) {
+	if proof == nil {
+		return false, malformedError("KeyRotationProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(oldCMTok, newCMTok, oldPK, newPK) || !zkpcp.onCurve(proof.T1, proof.T2) {
+		return false, curveError("KeyRotationProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("KeyRotationProof.Verify", "Challenge, S", proof.Challenge, proof.S); err != nil {
+		return false, err
+	}
+	// f = 0 takes any token to newCMTok = Zero for newPK = Zero
+	if oldPK.Equal(Zero) || newPK.Equal(Zero) {
+		return false, inputError("KeyRotationProof.Verify", "oldPK and newPK must not be Zero")
+	}
+
+	c := keyRotationChallenge(zkpcp, oldCMTok, newCMTok, oldPK, newPK, proof.T1, proof.T2)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("KeyRotationProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	negC := new(big.Int).Neg(c)
+
+	// s * oldPK ?= T1 + c * newPK, checked as s * oldPK - c * newPK ?= T1
+	lhs := zkpcp.MultiMult([]ECPoint{oldPK, newPK}, []*big.Int{proof.S, negC})
+	if !pointsEqual(lhs, proof.T1) {
+		return false, equationError("KeyRotationProof.Verify", 1, "s * oldPK - c * newPK != T1", lhs, proof.T1)
+	}
+
+	// s * oldCMTok ?= T2 + c * newCMTok, checked as s * oldCMTok - c * newCMTok ?= T2
+	lhs = zkpcp.MultiMult([]ECPoint{oldCMTok, newCMTok}, []*big.Int{proof.S, negC})
+	if !pointsEqual(lhs, proof.T2) {
+		return false, equationError("KeyRotationProof.Verify", 2, "s * oldCMTok - c * newCMTok != T2", lhs, proof.T2)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are oldCMTok, newCMTok, oldPK and
+// newPK like for Verify
+func (proof *KeyRotationProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("KeyRotationProof.VerifyArgs", args)
+	oldCMTok, newCMTok, oldPK, newPK := a.point(), a.point(), a.point(), a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, oldCMTok, newCMTok, oldPK, newPK)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *KeyRotationProof) String() string {
+	if proof == nil {
+		return "KeyRotationProof(nil)"
+	}
+	return proofString("KeyRotationProof", "T1", proof.T1, "T2", proof.T2,
+		"Challenge", proof.Challenge, "S", proof.S)
+}
+
+// keyRotationProofLen is the length of a serialized KeyRotationProof
+var keyRotationProofLen = 1 + 2*fixedPointLen + 2*fixedScalarLen
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// KeyRotationProof proof
+func (proof *KeyRotationProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.T1)
+	writeFixedPoint(&buf, proof.T2)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.S)
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of KeyRotationProof proof, which has a fixed size
+func (proof *KeyRotationProof) Size() int {
+	return keyRotationProofLen
+}
+
+// Clone returns a deep copy of KeyRotationProof proof that shares no big.Int with it
+func (proof *KeyRotationProof) Clone() *KeyRotationProof {
+	if proof == nil {
+		return nil
+	}
+	return &KeyRotationProof{
+		T1:        clonePoint(proof.T1),
+		T2:        clonePoint(proof.T2),
+		Challenge: cloneScalar(proof.Challenge),
+		S:         cloneScalar(proof.S),
+	}
+}
+
+// Equal returns true if KeyRotationProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *KeyRotationProof) Equal(other *KeyRotationProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.T1, other.T1) &&
+		samePoint(proof.T2, other.T2) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.S, other.S)
+}
+
+// NewKeyRotationProofFromBytes returns a KeyRotationProof generated from the
+// deserialization of byte slice b
+func NewKeyRotationProofFromBytes(b []byte) (*KeyRotationProof, error) {
+	if err := checkFixedHeader(b, keyRotationProofLen, "KeyRotationProofFromBytes"); err != nil {
+		return nil, err
+	}
+	proof := new(KeyRotationProof)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.T1, err = readFixedPoint(buf, "KeyRotationProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if proof.T2, err = readFixedPoint(buf, "KeyRotationProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "KeyRotationProofFromBytes", &proof.Challenge, &proof.S); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+type keyRotationProofJSON struct {
+	T1        string `json:"t1"`
+	T2        string `json:"t2"`
+	Challenge string `json:"challenge"`
+	S         string `json:"s"`
+}
+
+// MarshalJSON encodes KeyRotationProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *KeyRotationProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(keyRotationProofJSON{
+		T1:        pointToHex(proof.T1),
+		T2:        pointToHex(proof.T2),
+		Challenge: scalarToHex(proof.Challenge),
+		S:         scalarToHex(proof.S),
+	})
+}
+
+// UnmarshalJSON decodes a KeyRotationProof encoded with MarshalJSON. It fails
+// if a point is not on the curve or a scalar is not in [0, N)
+func (proof *KeyRotationProof) UnmarshalJSON(b []byte) error {
+	var aux keyRotationProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := KeyRotationProof{}
+	if p.T1, err = pointFromHex(aux.T1, "t1"); err != nil {
+		return err
+	}
+	if p.T2, err = pointFromHex(aux.T2, "t2"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.S, err = scalarFromHex(aux.S, "s"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
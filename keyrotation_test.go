@@ -0,0 +1,166 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestKeyRotationProof(t *testing.T) {
+	N := TestCurve.C.Params().N
+	value := big.NewInt(42)
+	CM, r, _ := PedCommit(TestCurve, value)
+
+	// rotate the token of CM from sks[0] to sks[1] and then to sks[2]
+	var sks [3]*big.Int
+	var PKs, toks [3]ECPoint
+	for i := range sks {
+		sks[i], _ = rand.Int(rand.Reader, N)
+		PKs[i] = TestCurve.Mult(TestCurve.H, sks[i])
+	}
+	toks[0] = TestCurve.Mult(PKs[0], r)
+
+	var proofs [2]*KeyRotationProof
+	for i := range proofs {
+		var err error
+		if toks[i+1], err = RotateToken(TestCurve, toks[i], sks[i], sks[i+1]); err != nil {
+			t.Fatalf("RotateToken failed for hop %d: %v\n", i, err)
+		}
+		if !toks[i+1].Equal(TestCurve.Mult(PKs[i+1], r)) {
+			t.Fatalf("RotateToken hop %d is not r * newPK\n", i)
+		}
+		if proofs[i], err = NewKeyRotationProof(TestCurve, toks[i], toks[i+1], PKs[i], PKs[i+1], sks[i], sks[i+1]); err != nil {
+			t.Fatalf("KeyRotationProof failed to generate for hop %d: %v\n", i, err)
+		}
+	}
+
+	// a verifier follows the chain hop by hop from the first token
+	for i, proof := range proofs {
+		if ok, err := proof.Verify(TestCurve, toks[i], toks[i+1], PKs[i], PKs[i+1]); !ok || err != nil {
+			t.Fatalf("KeyRotationProof failed to verify for hop %d: %v\n", i, err)
+		}
+	}
+	// the hops do not verify out of order or skipped
+	if ok, _ := proofs[1].Verify(TestCurve, toks[0], toks[2], PKs[0], PKs[2]); ok {
+		t.Fatalf("KeyRotationProof of the second hop verified for the whole chain\n")
+	}
+
+	// the last key decrypts the last token to the value of CM
+	proof, V, err := NewDecryptionProof(TestCurve, CM, toks[2], sks[2])
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if !V.Equal(TestCurve.Mult(TestCurve.G, value)) {
+		t.Fatalf("rotated token decrypts to %v instead of %vG\n", V, value)
+	}
+	if ok, err := proof.Verify(TestCurve, CM, toks[2], PKs[2], V); !ok || err != nil {
+		t.Fatalf("DecryptionProof of the rotated token failed to verify: %v\n", err)
+	}
+}
+
+func TestKeyRotationProofWrongFactor(t *testing.T) {
+	N := TestCurve.C.Params().N
+	oldSK, _ := rand.Int(rand.Reader, N)
+	newSK, _ := rand.Int(rand.Reader, N)
+	oldPK, newPK := TestCurve.Mult(TestCurve.H, oldSK), TestCurve.Mult(TestCurve.H, newSK)
+	_, r, _ := PedCommit(TestCurve, big.NewInt(7))
+	oldCMTok := TestCurve.Mult(oldPK, r)
+	newCMTok, err := RotateToken(TestCurve, oldCMTok, oldSK, newSK)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	// a token scaled by another factor than newSK / oldSK
+	other, _ := rand.Int(rand.Reader, N)
+	wrongTok := TestCurve.Mult(oldCMTok, other)
+	if _, err := NewKeyRotationProof(TestCurve, oldCMTok, wrongTok, oldPK, newPK, oldSK, newSK); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("KeyRotationProof for a token scaled by a different factor gives %v\n", err)
+	}
+
+	proof, err := NewKeyRotationProof(TestCurve, oldCMTok, newCMTok, oldPK, newPK, oldSK, newSK)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, oldCMTok, wrongTok, oldPK, newPK); ok || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("KeyRotationProof verified for a token scaled by a different factor: %v\n", err)
+	}
+
+	// with a challenge for the wrong token only the equation gives it away
+	forged := *proof
+	forged.Challenge = keyRotationChallenge(TestCurve, oldCMTok, wrongTok, oldPK, newPK, proof.T1, proof.T2)
+	if ok, err := forged.Verify(TestCurve, oldCMTok, wrongTok, oldPK, newPK); ok || !errors.Is(err, ErrEquationFailed) {
+		t.Fatalf("forged KeyRotationProof gives %v\n", err)
+	}
+
+	// keys that do not match the public keys
+	if _, err := NewKeyRotationProof(TestCurve, oldCMTok, newCMTok, oldPK, newPK, newSK, oldSK); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("KeyRotationProof with swapped keys gives %v\n", err)
+	}
+}
+
+func TestKeyRotationProofEdgeCases(t *testing.T) {
+	N := TestCurve.C.Params().N
+	sk, _ := rand.Int(rand.Reader, N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	_, r, _ := PedCommit(TestCurve, big.NewInt(7))
+	CMTok := TestCurve.Mult(PK, r)
+
+	for _, keys := range [][2]*big.Int{{big.NewInt(0), sk}, {sk, big.NewInt(0)}, {nil, sk}, {sk, N}} {
+		if _, err := RotateToken(TestCurve, CMTok, keys[0], keys[1]); !errors.Is(err, ErrValueOutOfRange) {
+			t.Fatalf("RotateToken with keys %v gives %v, expected ErrValueOutOfRange\n", keys, err)
+		}
+	}
+	if _, err := RotateToken(TestCurve, ECPoint{}, sk, sk); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("RotateToken of a nil point gives %v\n", err)
+	}
+	if _, err := NewKeyRotationProof(TestCurve, Zero, Zero, PK, PK, sk, sk); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("KeyRotationProof of a Zero token gives %v\n", err)
+	}
+
+	// rotating to the same key keeps the token
+	proof, err := NewKeyRotationProof(TestCurve, CMTok, CMTok, PK, PK, sk, sk)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CMTok, CMTok, PK, PK); !ok || err != nil {
+		t.Fatalf("KeyRotationProof to the same key failed to verify: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CMTok, Zero, PK, Zero); ok || !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("KeyRotationProof verified for newPK = Zero: %v\n", err)
+	}
+
+	var nilProof *KeyRotationProof
+	if ok, err := nilProof.Verify(TestCurve, CMTok, CMTok, PK, PK); ok || !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("nil KeyRotationProof verified: %v\n", err)
+	}
+}
+
+func BenchmarkKeyRotationProve(b *testing.B) {
+	N := TestCurve.C.Params().N
+	oldSK, _ := rand.Int(rand.Reader, N)
+	newSK, _ := rand.Int(rand.Reader, N)
+	oldPK, newPK := TestCurve.Mult(TestCurve.H, oldSK), TestCurve.Mult(TestCurve.H, newSK)
+	_, r, _ := PedCommit(TestCurve, big.NewInt(7))
+	oldCMTok := TestCurve.Mult(oldPK, r)
+	newCMTok, _ := RotateToken(TestCurve, oldCMTok, oldSK, newSK)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewKeyRotationProof(TestCurve, oldCMTok, newCMTok, oldPK, newPK, oldSK, newSK)
+	}
+}
+
+func BenchmarkKeyRotationVerify(b *testing.B) {
+	N := TestCurve.C.Params().N
+	oldSK, _ := rand.Int(rand.Reader, N)
+	newSK, _ := rand.Int(rand.Reader, N)
+	oldPK, newPK := TestCurve.Mult(TestCurve.H, oldSK), TestCurve.Mult(TestCurve.H, newSK)
+	_, r, _ := PedCommit(TestCurve, big.NewInt(7))
+	oldCMTok := TestCurve.Mult(oldPK, r)
+	newCMTok, _ := RotateToken(TestCurve, oldCMTok, oldSK, newSK)
+	proof, _ := NewKeyRotationProof(TestCurve, oldCMTok, newCMTok, oldPK, newPK, oldSK, newSK)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, oldCMTok, newCMTok, oldPK, newPK)
+	}
+}
@@ -0,0 +1,524 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/mit-dci/zksigma/wire"
+)
+
+// MaxMultiProductFactors is the number of factors up to which
+// NewMultiProductProof proves a product
+const MaxMultiProductFactors = 8
+
+// MultiProductProof is a proof that a commitment P_n = pG + r'_nH hides the
+// product p = a_1 * ... * a_n of the values in commitments A_i = a_iG + r_iH,
+// for 2 to MaxMultiProductFactors factors, e.g. a fee of amount * rate *
+// duration. The partial products p_k = a_1 * ... * a_k are committed to as
+// P_k = p_kG + r'_kH, with P_1 = A_1, and every link k shows P_k = a_kP_(k-1) +
+// t_kH with the equations of a ProductProof for A_k, P_(k-1) and P_k. All links
+// answer one challenge over the whole chain, so no link can be swapped for one
+// of another proof.
+//
+//  Public: generator points G and H, A_1 ... A_n, P_n
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows a_i, r_i, r'_k, P_1 = A_1 and r'_1 = r_1
+//  P_k = p_kG + r'_kH for 1 < k < n
+//  for every link k = 2 ... n:
+//   t_k = r'_k - a_k * r'_(k-1)
+//   selects random x1, x2, y1, y2, z
+//   T1_k = x1G + x2H
+//   T2_k = y1G + y2H
+//   T3_k = x1P_(k-1) + zH
+//  c = HASH(G, H, n, A_i, P_k, T1_k, T2_k, T3_k)
+//  s1_k = x1 + c * a_k
+//  s2_k = x2 + c * r_k
+//  s3_k = y1 + c * p_(k-1)
+//  s4_k = y2 + c * r'_(k-1)
+//  s5_k = z + c * t_k
+//
+//  P_k, c, T1_k .. s5_k -------------->
+//                                      c ?= HASH(G, H, n, A_i, P_k, T1_k, T2_k, T3_k)
+//                                      for every link k:
+//                                      s1_kG + s2_kH ?= T1_k + cA_k
+//                                      s3_kG + s4_kH ?= T2_k + cP_(k-1)
+//                                      s1_kP_(k-1) + s5_kH ?= T3_k + cP_k
+//
+// A factor of zero makes every partial product from there on zero, so P_n
+// commits to zero like the product.
+type MultiProductProof struct {
+	Partials  []ECPoint          // P_k = p_kG + r'_kH for 1 < k < n
+	Challenge *big.Int           // c = HASH(G, H, n, A_i, P_k, T1_k, T2_k, T3_k)
+	Links     []MultiProductLink // Links[k-2] shows P_k = a_kP_(k-1) + t_kH
+}
+
+// MultiProductLink is the part of a MultiProductProof for one factor a_k and
+// the partial products P_(k-1) and P_k, the responses are for the shared
+// challenge
+type MultiProductLink struct {
+	T1 ECPoint  // T1 = x1G + x2H
+	T2 ECPoint  // T2 = y1G + y2H
+	T3 ECPoint  // T3 = x1P_(k-1) + zH
+	S1 *big.Int // s1 = x1 + c * a_k
+	S2 *big.Int // s2 = x2 + c * r_k
+	S3 *big.Int // s3 = y1 + c * p_(k-1)
+	S4 *big.Int // s4 = y2 + c * r'_(k-1)
+	S5 *big.Int // s5 = z + c * t_k
+}
+
+// multiProductChain returns the partial products P_1 = A_1, P_2, ..., P_n of
+// the chain of a MultiProductProof
+func multiProductChain(factorCMs, partials []ECPoint, resultCM ECPoint) []ECPoint {
+	chain := append([]ECPoint{factorCMs[0]}, partials...)
+	return append(chain, resultCM)
+}
+
+// multiProductChallenge computes c = HASH(G, H, n, A_i, P_k, T1_k, T2_k, T3_k)
+func multiProductChallenge(zkpcp ZKPCurveParams, factorCMs, chain []ECPoint, links []MultiProductLink) *big.Int {
+	t := NewTranscript(zkpcp, "MultiProductProof")
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendScalar("n", big.NewInt(int64(len(factorCMs))))
+	for _, A := range factorCMs {
+		t.AppendPoint("A", A)
+	}
+	for _, P := range chain[1:] {
+		t.AppendPoint("P", P)
+	}
+	for _, link := range links {
+		t.AppendPoint("T1", link.T1)
+		t.AppendPoint("T2", link.T2)
+		t.AppendPoint("T3", link.T3)
+	}
+	return t.ChallengeScalar("c")
+}
+
+// checkMultiProductFactors makes sure that there are 2 to
+// MaxMultiProductFactors factors
+func checkMultiProductFactors(n int, t string) error {
+	if n == 0 {
+		return inputError(t, "no factors")
+	}
+	if n < 2 || n > MaxMultiProductFactors {
+		return inputError(t, fmt.Sprintf("%d factors, expected 2 to %d", n, MaxMultiProductFactors))
+	}
+	return nil
+}
+
+// NewMultiProductProof commits to the n factors and their partial products
+// and generates a proof that the last partial product is the product of all
+// factors. randomnesses has 2n - 1 entries: r_1 ... r_n of the factors, then
+// r'_2 ... r'_n of the partial products a_1 * a_2 up to the whole product. It
+// returns the commitments in the same order, A_1 ... A_n and then P_2 ... P_n,
+// so the last one is the commitment to the product that Verify takes.
+func NewMultiProductProof(zkpcp ZKPCurveParams, factors, randomnesses []*big.Int) ([]ECPoint, *MultiProductProof, error) {
+	return NewMultiProductProofWithRand(zkpcp, entropy(), factors, randomnesses)
+}
+
+// NewMultiProductProofWithRand is the same as NewMultiProductProof, but reads
+// its random values from rnd instead of the EntropySource
+func NewMultiProductProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	factors, randomnesses []*big.Int) ([]ECPoint, *MultiProductProof, error) {
+
+	N := zkpcp.C.Params().N
+	n := len(factors)
+
+	if err := checkMultiProductFactors(n, "MultiProductProve"); err != nil {
+		return nil, nil, err
+	}
+	if len(randomnesses) != 2*n-1 {
+		return nil, nil, inputError("MultiProductProve",
+			fmt.Sprintf("%d randomnesses for %d factors, expected %d", len(randomnesses), n, 2*n-1))
+	}
+	for i, a := range factors {
+		if err := zkpcp.checkScalars("MultiProductProve", fmt.Sprintf("factors[%d]", i), a); err != nil {
+			return nil, nil, err
+		}
+	}
+	for i, r := range randomnesses {
+		if err := zkpcp.checkScalars("MultiProductProve", fmt.Sprintf("randomnesses[%d]", i), r); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// p_k and r'_k of P_k, with p_1 = a_1 and r'_1 = r_1 for P_1 = A_1
+	products := []*big.Int{factors[0]}
+	rs := append([]*big.Int{randomnesses[0]}, randomnesses[n:]...)
+	for k := 1; k < n; k++ {
+		p := new(big.Int).Mul(products[k-1], factors[k])
+		products = append(products, p.Mod(p, N))
+	}
+
+	CMs := make([]ECPoint, 2*n-1)
+	for i, a := range factors {
+		CMs[i] = PedCommitR(zkpcp, a, randomnesses[i])
+	}
+	chain := []ECPoint{CMs[0]}
+	for k := 1; k < n; k++ {
+		CMs[n+k-1] = PedCommitR(zkpcp, products[k], rs[k])
+		chain = append(chain, CMs[n+k-1])
+	}
+
+	// x1, x2, y1, y2 and z of every link
+	us, err := zkpcp.randScalars(rnd, 5*(n-1))
+	if err != nil {
+		return nil, nil, err
+	}
+	links := make([]MultiProductLink, n-1)
+	for k := 1; k < n; k++ {
+		x1, x2, y1, y2, z := us[5*(k-1)], us[5*(k-1)+1], us[5*(k-1)+2], us[5*(k-1)+3], us[5*(k-1)+4]
+		links[k-1] = MultiProductLink{
+			T1: PedCommitR(zkpcp, x1, x2),
+			T2: PedCommitR(zkpcp, y1, y2),
+			T3: zkpcp.Add(zkpcp.Mult(chain[k-1], x1), zkpcp.Mult(zkpcp.H, z)),
+		}
+	}
+
+	c := multiProductChallenge(zkpcp, CMs[:n], chain, links)
+
+	// s = u + c * x
+	response := func(u, x *big.Int) *big.Int {
+		s := new(big.Int).Add(u, new(big.Int).Mul(c, x))
+		return s.Mod(s, N)
+	}
+	for k := 1; k < n; k++ {
+		// t_k = r'_k - a_k * r'_(k-1)
+		t := new(big.Int).Sub(rs[k], new(big.Int).Mul(factors[k], rs[k-1]))
+		t.Mod(t, N)
+		u := us[5*(k-1):]
+		link := &links[k-1]
+		link.S1 = response(u[0], factors[k])
+		link.S2 = response(u[1], randomnesses[k])
+		link.S3 = response(u[2], products[k-1])
+		link.S4 = response(u[3], rs[k-1])
+		link.S5 = response(u[4], t)
+	}
+
+	return CMs, &MultiProductProof{
+		Partials:  clonePoints(chain[1 : n-1]),
+		Challenge: c,
+		Links:     links,
+	}, nil
+}
+
+// Verify checks if MultiProductProof proof shows that resultCM commits to the
+// product of the values in factorCMs
+func (proof *MultiProductProof) Verify(zkpcp ZKPCurveParams, factorCMs []ECPoint, resultCM ECPoint) (bool, error) {
+	if proof == nil {
+		return false, malformedError("MultiProductProof.Verify", "passed proof is nil")
+	}
+
+	n := len(factorCMs)
+	if err := checkMultiProductFactors(n, "MultiProductProof.Verify"); err != nil {
+		return false, err
+	}
+	if len(proof.Partials) != n-2 || len(proof.Links) != n-1 {
+		return false, malformedError("MultiProductProof.Verify",
+			fmt.Sprintf("proof has %d links, expected %d", len(proof.Links), n-1))
+	}
+
+	if !zkpcp.validPoints(factorCMs...) || !zkpcp.validPoints(resultCM) || !zkpcp.onCurve(proof.Partials...) {
+		return false, curveError("MultiProductProof.Verify")
+	}
+	scalars := []*big.Int{proof.Challenge}
+	for _, link := range proof.Links {
+		if !zkpcp.onCurve(link.T1, link.T2, link.T3) {
+			return false, curveError("MultiProductProof.Verify")
+		}
+		scalars = append(scalars, link.S1, link.S2, link.S3, link.S4, link.S5)
+	}
+	if err := zkpcp.canonicalScalars("MultiProductProof.Verify", "scalars[]", scalars...); err != nil {
+		return false, err
+	}
+
+	chain := multiProductChain(factorCMs, proof.Partials, resultCM)
+	c := multiProductChallenge(zkpcp, factorCMs, chain, proof.Links)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("MultiProductProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	negC := new(big.Int).Neg(c)
+	for k := 1; k < n; k++ {
+		link := proof.Links[k-1]
+		A, B, C := factorCMs[k], chain[k-1], chain[k]
+
+		// s1G + s2H ?= T1 + cA_k, checked as s1G + s2H - cA_k ?= T1
+		lhs := zkpcp.MultiMult([]ECPoint{zkpcp.G, zkpcp.H, A}, []*big.Int{link.S1, link.S2, negC})
+		if !pointsEqual(lhs, link.T1) {
+			return false, equationError("MultiProductProof.Verify", 3*k-2,
+				fmt.Sprintf("link %d: s1G + s2H - cA != T1", k+1), lhs, link.T1)
+		}
+
+		// s3G + s4H ?= T2 + cP_(k-1), checked as s3G + s4H - cP_(k-1) ?= T2
+		lhs = zkpcp.MultiMult([]ECPoint{zkpcp.G, zkpcp.H, B}, []*big.Int{link.S3, link.S4, negC})
+		if !pointsEqual(lhs, link.T2) {
+			return false, equationError("MultiProductProof.Verify", 3*k-1,
+				fmt.Sprintf("link %d: s3G + s4H - cP_(k-1) != T2", k+1), lhs, link.T2)
+		}
+
+		// s1P_(k-1) + s5H ?= T3 + cP_k, checked as s1P_(k-1) + s5H - cP_k ?= T3
+		lhs = zkpcp.MultiMult([]ECPoint{B, zkpcp.H, C}, []*big.Int{link.S1, link.S5, negC})
+		if !pointsEqual(lhs, link.T3) {
+			return false, equationError("MultiProductProof.Verify", 3*k,
+				fmt.Sprintf("link %d: s1P_(k-1) + s5H - cP_k != T3", k+1), lhs, link.T3)
+		}
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are factorCMs and resultCM like for
+// Verify
+func (proof *MultiProductProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("MultiProductProof.VerifyArgs", args)
+	factorCMs, resultCM := a.points(), a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, factorCMs, resultCM)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *MultiProductProof) String() string {
+	if proof == nil {
+		return "MultiProductProof(nil)"
+	}
+	var fields []interface{}
+	for i, P := range proof.Partials {
+		fields = append(fields, fmt.Sprintf("Partials[%d]", i), P)
+	}
+	fields = append(fields, "Challenge", proof.Challenge)
+	for i, link := range proof.Links {
+		fields = append(fields, fmt.Sprintf("Links[%d].T1", i), link.T1, fmt.Sprintf("Links[%d].T2", i), link.T2,
+			fmt.Sprintf("Links[%d].T3", i), link.T3, fmt.Sprintf("Links[%d].S1", i), link.S1,
+			fmt.Sprintf("Links[%d].S2", i), link.S2, fmt.Sprintf("Links[%d].S3", i), link.S3,
+			fmt.Sprintf("Links[%d].S4", i), link.S4, fmt.Sprintf("Links[%d].S5", i), link.S5)
+	}
+	return proofString("MultiProductProof", fields...)
+}
+
+// multiProductLinkLen is the length of a serialized MultiProductLink
+var multiProductLinkLen = 3*fixedPointLen + 5*fixedScalarLen
+
+// Bytes returns a byte slice with a serialized representation of
+// MultiProductProof proof, which grows linearly with the number of factors
+func (proof *MultiProductProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	wire.WriteVarInt(&buf, uint64(len(proof.Links)+1))
+	writeFixedScalar(&buf, proof.Challenge)
+	for _, P := range proof.Partials {
+		writeFixedPoint(&buf, P)
+	}
+	for _, link := range proof.Links {
+		writeFixedPoint(&buf, link.T1)
+		writeFixedPoint(&buf, link.T2)
+		writeFixedPoint(&buf, link.T3)
+		writeFixedScalar(&buf, link.S1)
+		writeFixedScalar(&buf, link.S2)
+		writeFixedScalar(&buf, link.S3)
+		writeFixedScalar(&buf, link.S4)
+		writeFixedScalar(&buf, link.S5)
+	}
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of MultiProductProof proof without serializing it
+func (proof *MultiProductProof) Size() int {
+	n := len(proof.Links) + 1
+	return 1 + wire.VarIntSerializeSize(uint64(n)) + fixedScalarLen +
+		len(proof.Partials)*fixedPointLen + len(proof.Links)*multiProductLinkLen
+}
+
+// Clone returns a deep copy of MultiProductProof proof that shares no big.Int with it
+func (proof *MultiProductProof) Clone() *MultiProductProof {
+	if proof == nil {
+		return nil
+	}
+	c := &MultiProductProof{
+		Partials:  clonePoints(proof.Partials),
+		Challenge: cloneScalar(proof.Challenge),
+	}
+	if proof.Links != nil {
+		c.Links = make([]MultiProductLink, len(proof.Links))
+		for i, link := range proof.Links {
+			c.Links[i] = MultiProductLink{
+				T1: clonePoint(link.T1),
+				T2: clonePoint(link.T2),
+				T3: clonePoint(link.T3),
+				S1: cloneScalar(link.S1),
+				S2: cloneScalar(link.S2),
+				S3: cloneScalar(link.S3),
+				S4: cloneScalar(link.S4),
+				S5: cloneScalar(link.S5),
+			}
+		}
+	}
+	return c
+}
+
+// Equal returns true if MultiProductProof proof and other have the same values
+// in all their fields. It is not constant time.
+func (proof *MultiProductProof) Equal(other *MultiProductProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	if !samePoints(proof.Partials, other.Partials) || !sameScalar(proof.Challenge, other.Challenge) ||
+		len(proof.Links) != len(other.Links) {
+		return false
+	}
+	for i, link := range proof.Links {
+		o := other.Links[i]
+		if !samePoint(link.T1, o.T1) || !samePoint(link.T2, o.T2) || !samePoint(link.T3, o.T3) ||
+			!sameScalar(link.S1, o.S1) || !sameScalar(link.S2, o.S2) || !sameScalar(link.S3, o.S3) ||
+			!sameScalar(link.S4, o.S4) || !sameScalar(link.S5, o.S5) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewMultiProductProofFromBytes returns a MultiProductProof generated from the
+// deserialization of byte slice b
+func NewMultiProductProofFromBytes(b []byte) (*MultiProductProof, error) {
+	if len(b) > 0 && b[0] != proofEncodingVersion {
+		return nil, malformedError("MultiProductProofFromBytes", fmt.Sprintf("unsupported encoding version %d", b[0]))
+	}
+	if len(b) == 0 {
+		return nil, malformedError("MultiProductProofFromBytes", "truncated buffer")
+	}
+	buf := bytes.NewBuffer(b[1:])
+	n, err := wire.ReadVarInt(buf)
+	if err != nil {
+		return nil, err
+	}
+	if n < 2 || n > MaxMultiProductFactors {
+		return nil, malformedError("MultiProductProofFromBytes", fmt.Sprintf("%d factors, expected 2 to %d", n, MaxMultiProductFactors))
+	}
+	if uint64(buf.Len()) != uint64(fixedScalarLen)+(n-2)*uint64(fixedPointLen)+(n-1)*uint64(multiProductLinkLen) {
+		return nil, malformedError("MultiProductProofFromBytes", "buffer length does not match number of factors")
+	}
+
+	proof := &MultiProductProof{
+		Partials: make([]ECPoint, n-2),
+		Links:    make([]MultiProductLink, n-1),
+	}
+	if proof.Challenge, err = readFixedScalar(buf, "MultiProductProofFromBytes"); err != nil {
+		return nil, err
+	}
+	for i := range proof.Partials {
+		if proof.Partials[i], err = readFixedPoint(buf, "MultiProductProofFromBytes"); err != nil {
+			return nil, err
+		}
+	}
+	for i := range proof.Links {
+		link := &proof.Links[i]
+		if link.T1, err = readFixedPoint(buf, "MultiProductProofFromBytes"); err != nil {
+			return nil, err
+		}
+		if link.T2, err = readFixedPoint(buf, "MultiProductProofFromBytes"); err != nil {
+			return nil, err
+		}
+		if link.T3, err = readFixedPoint(buf, "MultiProductProofFromBytes"); err != nil {
+			return nil, err
+		}
+		if err = readFixedScalars(buf, "MultiProductProofFromBytes", &link.S1, &link.S2, &link.S3, &link.S4, &link.S5); err != nil {
+			return nil, err
+		}
+	}
+	return proof, nil
+}
+
+type multiProductLinkJSON struct {
+	T1 string `json:"t1"`
+	T2 string `json:"t2"`
+	T3 string `json:"t3"`
+	S1 string `json:"s1"`
+	S2 string `json:"s2"`
+	S3 string `json:"s3"`
+	S4 string `json:"s4"`
+	S5 string `json:"s5"`
+}
+
+type multiProductProofJSON struct {
+	Partials  []string               `json:"partials"`
+	Challenge string                 `json:"challenge"`
+	Links     []multiProductLinkJSON `json:"links"`
+}
+
+// MarshalJSON encodes MultiProductProof proof with points as compressed hex
+// and scalars as big-endian hex
+func (proof *MultiProductProof) MarshalJSON() ([]byte, error) {
+	links := make([]multiProductLinkJSON, len(proof.Links))
+	for i, e := range proof.Links {
+		links[i] = multiProductLinkJSON{
+			T1: pointToHex(e.T1),
+			T2: pointToHex(e.T2),
+			T3: pointToHex(e.T3),
+			S1: scalarToHex(e.S1),
+			S2: scalarToHex(e.S2),
+			S3: scalarToHex(e.S3),
+			S4: scalarToHex(e.S4),
+			S5: scalarToHex(e.S5),
+		}
+	}
+	return json.Marshal(multiProductProofJSON{
+		Partials:  pointsToHex(proof.Partials),
+		Challenge: scalarToHex(proof.Challenge),
+		Links:     links,
+	})
+}
+
+// UnmarshalJSON decodes a MultiProductProof encoded with MarshalJSON. It fails
+// if a point is not on the curve or a scalar is not in [0, N)
+func (proof *MultiProductProof) UnmarshalJSON(b []byte) error {
+	var aux multiProductProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := MultiProductProof{}
+	if p.Partials, err = pointsFromHex(aux.Partials, "partials"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	p.Links = make([]MultiProductLink, len(aux.Links))
+	for i, e := range aux.Links {
+		field := fmt.Sprintf("links[%d]", i)
+		if p.Links[i].T1, err = pointFromHex(e.T1, field+".t1"); err != nil {
+			return err
+		}
+		if p.Links[i].T2, err = pointFromHex(e.T2, field+".t2"); err != nil {
+			return err
+		}
+		if p.Links[i].T3, err = pointFromHex(e.T3, field+".t3"); err != nil {
+			return err
+		}
+		if p.Links[i].S1, err = scalarFromHex(e.S1, field+".s1"); err != nil {
+			return err
+		}
+		if p.Links[i].S2, err = scalarFromHex(e.S2, field+".s2"); err != nil {
+			return err
+		}
+		if p.Links[i].S3, err = scalarFromHex(e.S3, field+".s3"); err != nil {
+			return err
+		}
+		if p.Links[i].S4, err = scalarFromHex(e.S4, field+".s4"); err != nil {
+			return err
+		}
+		if p.Links[i].S5, err = scalarFromHex(e.S5, field+".s5"); err != nil {
+			return err
+		}
+	}
+	*proof = p
+	return nil
+}
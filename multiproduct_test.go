@@ -0,0 +1,151 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// multiProductRandomnesses returns the 2n - 1 random values for n factors
+func multiProductRandomnesses(n int) []*big.Int {
+	rs := make([]*big.Int, 2*n-1)
+	for i := range rs {
+		rs[i], _ = rand.Int(rand.Reader, TestCurve.C.Params().N)
+	}
+	return rs
+}
+
+func TestMultiProductProof(t *testing.T) {
+	a, _ := rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic range"
+	eight := make([]*big.Int, MaxMultiProductFactors)
+	for i := range eight {
+		eight[i] = big.NewInt(int64(i + 2))
+	}
+
+	for name, factors := range map[string][]*big.Int{
+		"three":     {big.NewInt(3), big.NewInt(4), a},
+		"two":       {a, big.NewInt(7)},
+		"one":       {big.NewInt(5), big.NewInt(1), big.NewInt(9)}, // a factor is 1
+		"zero":      {big.NewInt(5), big.NewInt(0), big.NewInt(9)}, // a factor is 0
+		"zero last": {big.NewInt(5), big.NewInt(9), big.NewInt(0)},
+		"eight":     eight,
+	} {
+		n := len(factors)
+		rs := multiProductRandomnesses(n)
+		CMs, proof, err := NewMultiProductProof(TestCurve, factors, rs)
+		if err != nil {
+			t.Fatalf("MultiProductProof failed to generate for %s factors: %v\n", name, err)
+		}
+		if len(CMs) != 2*n-1 || len(proof.Partials) != n-2 || len(proof.Links) != n-1 {
+			t.Fatalf("MultiProductProof for %s factors has %d commitments, %d partials and %d links\n",
+				name, len(CMs), len(proof.Partials), len(proof.Links))
+		}
+
+		product := big.NewInt(1)
+		for _, f := range factors {
+			product.Mul(product, f)
+		}
+		if !Open(TestCurve, product, rs[2*n-2], CMs[2*n-2]) {
+			t.Fatalf("MultiProductProof for %s factors returned a result that does not commit to the product\n", name)
+		}
+
+		check, err := proof.Verify(TestCurve, CMs[:n], CMs[2*n-2])
+		if !check || err != nil {
+			t.Fatalf("MultiProductProof failed to verify for %s factors: %v\n", name, err)
+		}
+
+		proof, err = NewMultiProductProofFromBytes(proof.Bytes())
+		if err != nil {
+			t.Fatalf("MultiProductProof failed to deserialize: %v\n", err)
+		}
+		check, err = proof.Verify(TestCurve, CMs[:n], CMs[2*n-2])
+		if !check || err != nil {
+			t.Fatalf("MultiProductProof failed to verify after deserialization: %v\n", err)
+		}
+	}
+}
+
+func TestMultiProductProofWrongProduct(t *testing.T) {
+	factors := []*big.Int{big.NewInt(3), big.NewInt(4), big.NewInt(5)}
+	rs := multiProductRandomnesses(3)
+	CMs, proof, err := NewMultiProductProof(TestCurve, factors, rs)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	// the result commits to 61 != 3 * 4 * 5 with the same randomness
+	wrong := PedCommitR(TestCurve, big.NewInt(61), rs[4])
+	if check, err := proof.Verify(TestCurve, CMs[:3], wrong); check || err == nil {
+		t.Fatalf("MultiProductProof verified for a wrong product\n")
+	}
+
+	// P_2 commits to 13 != 3 * 4, with the same challenge the first link fails
+	cheat := *proof
+	cheat.Partials = []ECPoint{PedCommitR(TestCurve, big.NewInt(13), rs[3])}
+	if check, err := cheat.Verify(TestCurve, CMs[:3], CMs[4]); check || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("MultiProductProof verified with a tampered intermediate commitment: %v\n", err)
+	}
+
+	// a proof for 3 * 4 * 5 is not one for 3 * 5 * 4
+	swapped := []ECPoint{CMs[0], CMs[2], CMs[1]}
+	if check, err := proof.Verify(TestCurve, swapped, CMs[4]); check || err == nil {
+		t.Fatalf("MultiProductProof verified for swapped factors\n")
+	}
+
+	cheat = *proof
+	cheat.Links = append([]MultiProductLink(nil), proof.Links...)
+	cheat.Links[1].S1 = new(big.Int).Add(proof.Links[1].S1, big.NewInt(1))
+	if check, err := cheat.Verify(TestCurve, CMs[:3], CMs[4]); check || !errors.Is(err, ErrEquationFailed) {
+		t.Fatalf("MultiProductProof verified with a tampered response: %v\n", err)
+	}
+
+	// more or fewer factors than the proof has links
+	if check, err := proof.Verify(TestCurve, CMs[:2], CMs[4]); check || !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("MultiProductProof verified for 2 of 3 factors: %v\n", err)
+	}
+}
+
+func TestMultiProductProofInputs(t *testing.T) {
+	nine := make([]*big.Int, MaxMultiProductFactors+1)
+	for i := range nine {
+		nine[i] = big.NewInt(int64(i + 2))
+	}
+	for name, factors := range map[string][]*big.Int{
+		"no":   nil,
+		"one":  {big.NewInt(3)},
+		"nine": nine,
+	} {
+		var rs []*big.Int
+		if len(factors) > 0 {
+			rs = multiProductRandomnesses(len(factors))
+		}
+		if _, _, err := NewMultiProductProof(TestCurve, factors, rs); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("MultiProductProof for %s factors gives %v\n", name, err)
+		}
+	}
+	factors := []*big.Int{big.NewInt(3), big.NewInt(4)}
+	if _, _, err := NewMultiProductProof(TestCurve, factors, multiProductRandomnesses(2)[:2]); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("MultiProductProof for 2 randomnesses of 2 factors gives %v\n", err)
+	}
+}
+
+func BenchmarkMultiProductProve(b *testing.B) {
+	factors := []*big.Int{big.NewInt(3), big.NewInt(4), big.NewInt(5)}
+	rs := multiProductRandomnesses(3)
+
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewMultiProductProof(TestCurve, factors, rs)
+	}
+}
+
+func BenchmarkMultiProductVerify(b *testing.B) {
+	factors := []*big.Int{big.NewInt(3), big.NewInt(4), big.NewInt(5)}
+	CMs, proof, _ := NewMultiProductProof(TestCurve, factors, multiProductRandomnesses(3))
+
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CMs[:3], CMs[4])
+	}
+}
@@ -0,0 +1,478 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/mit-dci/zksigma/wire"
+)
+
+// MultiRangeProof is a proof that each of the commitments A_j = v_jG + r_jH
+// hides a value v_j in [0, 2^bits). Like BitRangeProof it commits to every bit
+// of every value and shows that the commitment hides 0 or 1, but all of these
+// OR proofs answer one challenge, and the commitments T0 and T1 of each are
+// recomputed by the verifier instead of being sent. A bit takes a point and
+// three scalars instead of the point and DisjunctiveProof of a BitRangeProof,
+// which is half the size.
+//
+//  Public: generator points G and H, A_1 ... A_n, bits
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows v_j, r_j with A_j = v_jG + r_jH
+//  for every value j and bit i < bits:
+//   r_ji like for BitRangeProof
+//   C_ji = b_jiG + r_jiH
+//   selects random u, c', s'
+//   if b_ji = 0:
+//    T0 = uH, T1 = s'H - c'(C_ji - G)
+//   if b_ji = 1:
+//    T0 = s'H - c'C_ji, T1 = uH
+//  c = HASH(G, H, n, bits, A_j, C_ji, T0_ji, T1_ji)
+//  for every bit c0 + c1 = c with c'
+//  for the faked side, s0 and s1 are
+//  s' and s = u + (c - c')r_ji
+//
+//  c, C_ji, c0_ji, s0_ji, s1_ji ------>
+//                                      T0_ji = s0_jiH - c0_jiC_ji
+//                                      T1_ji = s1_jiH - (c - c0_ji)(C_ji - G)
+//                                      c ?= HASH(G, H, n, bits, A_j, C_ji, T0_ji, T1_ji)
+//                                      sum(2^i * C_ji) ?= A_j for every j
+type MultiRangeProof struct {
+	Challenge *big.Int          // c = HASH(G, H, n, bits, A_j, C_ji, T0_ji, T1_ji)
+	Values    [][]MultiRangeBit // Values[j][i] is bit i of value j
+}
+
+// MultiRangeBit is the part of a MultiRangeProof for one bit of one value, an
+// OR proof that C = rH or C - G = rH for the shared challenge c
+type MultiRangeBit struct {
+	C  ECPoint  // C = bG + rH
+	C0 *big.Int // c0, the challenge of C = rH, c - c0 is the one of C - G = rH
+	S0 *big.Int // s0 with s0H = T0 + c0C
+	S1 *big.Int // s1 with s1H = T1 + (c - c0)(C - G)
+}
+
+// multiRangeChallenge computes c = HASH(G, H, n, bits, A_j, C_ji, T0_ji,
+// T1_ji), the T are in the order of the bits of proof
+func multiRangeChallenge(zkpcp ZKPCurveParams, CMs []ECPoint, bits int, values [][]MultiRangeBit, T0s, T1s []ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "MultiRangeProof")
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendScalar("n", big.NewInt(int64(len(CMs))))
+	t.AppendScalar("bits", big.NewInt(int64(bits)))
+	for _, A := range CMs {
+		t.AppendPoint("A", A)
+	}
+	for j, value := range values {
+		for i, b := range value {
+			t.AppendPoint("C", b.C)
+			t.AppendPoint("T0", T0s[j*bits+i])
+			t.AppendPoint("T1", T1s[j*bits+i])
+		}
+	}
+	return t.ChallengeScalar("c")
+}
+
+// NewMultiRangeProof generates a proof that each of CMs[j] = values[j]*G +
+// randomnesses[j]*H commits to a value in [0, 2^bits). It fails if one of the
+// values is negative or not below 2^bits.
+func NewMultiRangeProof(zkpcp ZKPCurveParams, CMs []ECPoint, values, randomnesses []*big.Int, bits int) (*MultiRangeProof, error) {
+	return NewMultiRangeProofWithRand(zkpcp, entropy(), CMs, values, randomnesses, bits)
+}
+
+// NewMultiRangeProofWithRand is the same as NewMultiRangeProof, but reads its
+// random values from rnd instead of the EntropySource
+func NewMultiRangeProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	CMs []ECPoint, values, randomnesses []*big.Int, bits int) (*MultiRangeProof, error) {
+
+	if err := checkRangeBits(zkpcp, bits, "MultiRangeProve"); err != nil {
+		return nil, err
+	}
+	n := len(CMs)
+	if n == 0 {
+		return nil, inputError("MultiRangeProve", "no commitments")
+	}
+	if len(values) != n || len(randomnesses) != n {
+		return nil, inputError("MultiRangeProve",
+			fmt.Sprintf("%d commitments, %d values and %d randomnesses", n, len(values), len(randomnesses)))
+	}
+	for j := range values {
+		if err := zkpcp.checkScalars("MultiRangeProve", fmt.Sprintf("values[%d], randomnesses[%d]", j, j),
+			values[j], randomnesses[j]); err != nil {
+			return nil, err
+		}
+		if values[j].BitLen() > bits {
+			return nil, inputError("MultiRangeProve", fmt.Sprintf("values[%d] is not in [0, 2^%d)", j, bits))
+		}
+	}
+	if !zkpcp.validPoints(CMs...) {
+		return nil, curveError("MultiRangeProve")
+	}
+	for j, CM := range CMs {
+		if !CM.Equal(PedCommitR(zkpcp, values[j], randomnesses[j])) {
+			return nil, inputError("MultiRangeProve",
+				fmt.Sprintf("values[%d] and randomnesses[%d] do not produce CMs[%d]", j, j, j))
+		}
+	}
+
+	N := zkpcp.C.Params().N
+	// r_ji and u, c', s' of every bit, drawn value by value
+	rs, us := make([][]*big.Int, n), make([][]*big.Int, n)
+	for j := range values {
+		var err error
+		if rs[j], err = zkpcp.bitRandomness(rnd, randomnesses[j], bits); err != nil {
+			return nil, err
+		}
+		if us[j], err = zkpcp.randScalars(rnd, 3*bits); err != nil {
+			return nil, err
+		}
+	}
+
+	proof := &MultiRangeProof{Values: make([][]MultiRangeBit, n)}
+	T0s, T1s := make([]ECPoint, n*bits), make([]ECPoint, n*bits)
+	for j, v := range values {
+		proof.Values[j] = make([]MultiRangeBit, bits)
+		for i := 0; i < bits; i++ {
+			C := PedCommitR(zkpcp, big.NewInt(int64(v.Bit(i))), rs[j][i])
+			proof.Values[j][i].C = C
+
+			// T = s'H - c'X for the faked side, T = uH for the real one
+			u, cf, sf := us[j][3*i], us[j][3*i+1], us[j][3*i+2]
+			negCf := new(big.Int).Sub(N, cf)
+			if v.Bit(i) == 0 {
+				T0s[j*bits+i] = zkpcp.Mult(zkpcp.H, u)
+				T1s[j*bits+i] = zkpcp.MultiMult([]ECPoint{zkpcp.H, zkpcp.Sub(C, zkpcp.G)}, []*big.Int{sf, negCf})
+			} else {
+				T0s[j*bits+i] = zkpcp.MultiMult([]ECPoint{zkpcp.H, C}, []*big.Int{sf, negCf})
+				T1s[j*bits+i] = zkpcp.Mult(zkpcp.H, u)
+			}
+		}
+	}
+
+	c := multiRangeChallenge(zkpcp, CMs, bits, proof.Values, T0s, T1s)
+	proof.Challenge = c
+
+	for j, v := range values {
+		for i := 0; i < bits; i++ {
+			u, cf, sf := us[j][3*i], us[j][3*i+1], us[j][3*i+2]
+			// s = u + (c - c')r for the real side
+			cr := new(big.Int).Sub(c, cf)
+			cr.Mod(cr, N)
+			s := new(big.Int).Add(u, new(big.Int).Mul(cr, rs[j][i]))
+			s.Mod(s, N)
+			b := &proof.Values[j][i]
+			if v.Bit(i) == 0 {
+				b.C0, b.S0, b.S1 = cr, s, sf
+			} else {
+				b.C0, b.S0, b.S1 = cf, sf, s
+			}
+		}
+	}
+
+	return proof, nil
+}
+
+// Verify checks if MultiRangeProof proof shows that each of CMs commits to a
+// value in [0, 2^bits). A proof generated for a different number of bits or
+// other commitments is rejected.
+func (proof *MultiRangeProof) Verify(zkpcp ZKPCurveParams, CMs []ECPoint, bits int) (bool, error) {
+	if proof == nil {
+		return false, malformedError("MultiRangeProof.Verify", "passed proof is nil")
+	}
+
+	if err := checkRangeBits(zkpcp, bits, "MultiRangeProof.Verify"); err != nil {
+		return false, err
+	}
+	n := len(CMs)
+	if n == 0 {
+		return false, inputError("MultiRangeProof.Verify", "no commitments")
+	}
+	if len(proof.Values) != n {
+		return false, malformedError("MultiRangeProof.Verify",
+			fmt.Sprintf("proof has %d values, expected %d", len(proof.Values), n))
+	}
+	for j, value := range proof.Values {
+		if len(value) != bits {
+			return false, malformedError("MultiRangeProof.Verify",
+				fmt.Sprintf("value %d has %d bits, expected %d", j, len(value), bits))
+		}
+	}
+
+	if !zkpcp.validPoints(CMs...) {
+		return false, curveError("MultiRangeProof.Verify")
+	}
+	scalars := []*big.Int{proof.Challenge}
+	for _, value := range proof.Values {
+		for _, b := range value {
+			if !zkpcp.onCurve(b.C) {
+				return false, curveError("MultiRangeProof.Verify")
+			}
+			scalars = append(scalars, b.C0, b.S0, b.S1)
+		}
+	}
+	if err := zkpcp.canonicalScalars("MultiRangeProof.Verify", "scalars[]", scalars...); err != nil {
+		return false, err
+	}
+
+	N := zkpcp.C.Params().N
+	T0s, T1s := make([]ECPoint, n*bits), make([]ECPoint, n*bits)
+	for j, value := range proof.Values {
+		for i, b := range value {
+			// T0 = s0H - c0C and T1 = s1H - (c - c0)(C - G)
+			negC0 := new(big.Int).Sub(N, b.C0)
+			negC1 := new(big.Int).Sub(b.C0, proof.Challenge)
+			negC1.Mod(negC1, N)
+			T0s[j*bits+i] = zkpcp.MultiMult([]ECPoint{zkpcp.H, b.C}, []*big.Int{b.S0, negC0})
+			T1s[j*bits+i] = zkpcp.MultiMult([]ECPoint{zkpcp.H, zkpcp.Sub(b.C, zkpcp.G)}, []*big.Int{b.S1, negC1})
+		}
+	}
+	c := multiRangeChallenge(zkpcp, CMs, bits, proof.Values, T0s, T1s)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("MultiRangeProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	for j, value := range proof.Values {
+		total := Zero
+		for i, b := range value {
+			total = zkpcp.Add(total, zkpcp.Mult(b.C, new(big.Int).Lsh(big.NewInt(1), uint(i))))
+		}
+		if !pointsEqual(total, CMs[j]) {
+			return false, equationError("MultiRangeProof.Verify", j+1,
+				fmt.Sprintf("value %d: sum(2^i * C_i) != CM", j), total, CMs[j])
+		}
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are CMs and bits as int like for
+// Verify
+func (proof *MultiRangeProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("MultiRangeProof.VerifyArgs", args)
+	CMs, bits := a.points(), a.int()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CMs, bits)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *MultiRangeProof) String() string {
+	if proof == nil {
+		return "MultiRangeProof(nil)"
+	}
+	fields := []interface{}{"Challenge", proof.Challenge}
+	for j, value := range proof.Values {
+		for i, b := range value {
+			name := fmt.Sprintf("Values[%d][%d]", j, i)
+			fields = append(fields, name+".C", b.C, name+".C0", b.C0, name+".S0", b.S0, name+".S1", b.S1)
+		}
+	}
+	return proofString("MultiRangeProof", fields...)
+}
+
+// multiRangeBitLen is the length of a serialized MultiRangeBit
+var multiRangeBitLen = fixedPointLen + 3*fixedScalarLen
+
+// multiRangeBits returns the number of bits of the values of proof, which all
+// have the same number for a proof that can verify
+func (proof *MultiRangeProof) multiRangeBits() int {
+	if len(proof.Values) == 0 {
+		return 0
+	}
+	return len(proof.Values[0])
+}
+
+// Bytes returns a byte slice with a serialized representation of
+// MultiRangeProof proof. Every value has to have the same number of bits.
+func (proof *MultiRangeProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	wire.WriteVarInt(&buf, uint64(len(proof.Values)))
+	wire.WriteVarInt(&buf, uint64(proof.multiRangeBits()))
+	writeFixedScalar(&buf, proof.Challenge)
+	for _, value := range proof.Values {
+		for _, b := range value {
+			writeFixedPoint(&buf, b.C)
+			writeFixedScalar(&buf, b.C0)
+			writeFixedScalar(&buf, b.S0)
+			writeFixedScalar(&buf, b.S1)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of MultiRangeProof proof without serializing it
+func (proof *MultiRangeProof) Size() int {
+	n, bits := len(proof.Values), proof.multiRangeBits()
+	return 1 + wire.VarIntSerializeSize(uint64(n)) + wire.VarIntSerializeSize(uint64(bits)) +
+		fixedScalarLen + n*bits*multiRangeBitLen
+}
+
+// Clone returns a deep copy of MultiRangeProof proof that shares no big.Int with it
+func (proof *MultiRangeProof) Clone() *MultiRangeProof {
+	if proof == nil {
+		return nil
+	}
+	c := &MultiRangeProof{
+		Challenge: cloneScalar(proof.Challenge),
+	}
+	if proof.Values != nil {
+		c.Values = make([][]MultiRangeBit, len(proof.Values))
+		for j, value := range proof.Values {
+			if value == nil {
+				continue
+			}
+			c.Values[j] = make([]MultiRangeBit, len(value))
+			for i, b := range value {
+				c.Values[j][i] = MultiRangeBit{
+					C:  clonePoint(b.C),
+					C0: cloneScalar(b.C0),
+					S0: cloneScalar(b.S0),
+					S1: cloneScalar(b.S1),
+				}
+			}
+		}
+	}
+	return c
+}
+
+// Equal returns true if MultiRangeProof proof and other have the same values
+// in all their fields. It is not constant time.
+func (proof *MultiRangeProof) Equal(other *MultiRangeProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	if !sameScalar(proof.Challenge, other.Challenge) || len(proof.Values) != len(other.Values) {
+		return false
+	}
+	for j, value := range proof.Values {
+		if len(value) != len(other.Values[j]) {
+			return false
+		}
+		for i, b := range value {
+			o := other.Values[j][i]
+			if !samePoint(b.C, o.C) || !sameScalar(b.C0, o.C0) || !sameScalar(b.S0, o.S0) || !sameScalar(b.S1, o.S1) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NewMultiRangeProofFromBytes returns a MultiRangeProof generated from the
+// deserialization of byte slice b
+func NewMultiRangeProofFromBytes(b []byte) (*MultiRangeProof, error) {
+	if len(b) > 0 && b[0] != proofEncodingVersion {
+		return nil, malformedError("MultiRangeProofFromBytes", fmt.Sprintf("unsupported encoding version %d", b[0]))
+	}
+	if len(b) == 0 {
+		return nil, malformedError("MultiRangeProofFromBytes", "truncated buffer")
+	}
+	buf := bytes.NewBuffer(b[1:])
+	n, err := wire.ReadVarInt(buf)
+	if err != nil {
+		return nil, err
+	}
+	bits, err := wire.ReadVarInt(buf)
+	if err != nil {
+		return nil, err
+	}
+	// n and bits are at least 1, so neither can be above the length
+	size := uint64(buf.Len())
+	if n == 0 || bits == 0 || n > size || bits > size ||
+		size != uint64(fixedScalarLen)+n*bits*uint64(multiRangeBitLen) {
+		return nil, malformedError("MultiRangeProofFromBytes", "buffer length does not match number of values and bits")
+	}
+
+	proof := &MultiRangeProof{Values: make([][]MultiRangeBit, n)}
+	if proof.Challenge, err = readFixedScalar(buf, "MultiRangeProofFromBytes"); err != nil {
+		return nil, err
+	}
+	for j := range proof.Values {
+		proof.Values[j] = make([]MultiRangeBit, bits)
+		for i := range proof.Values[j] {
+			b := &proof.Values[j][i]
+			if b.C, err = readFixedPoint(buf, "MultiRangeProofFromBytes"); err != nil {
+				return nil, err
+			}
+			if err = readFixedScalars(buf, "MultiRangeProofFromBytes", &b.C0, &b.S0, &b.S1); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return proof, nil
+}
+
+type multiRangeBitJSON struct {
+	C  string `json:"c"`
+	C0 string `json:"c0"`
+	S0 string `json:"s0"`
+	S1 string `json:"s1"`
+}
+
+type multiRangeProofJSON struct {
+	Challenge string                `json:"challenge"`
+	Values    [][]multiRangeBitJSON `json:"values"`
+}
+
+// MarshalJSON encodes MultiRangeProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *MultiRangeProof) MarshalJSON() ([]byte, error) {
+	values := make([][]multiRangeBitJSON, len(proof.Values))
+	for j, value := range proof.Values {
+		values[j] = make([]multiRangeBitJSON, len(value))
+		for i, b := range value {
+			values[j][i] = multiRangeBitJSON{
+				C:  pointToHex(b.C),
+				C0: scalarToHex(b.C0),
+				S0: scalarToHex(b.S0),
+				S1: scalarToHex(b.S1),
+			}
+		}
+	}
+	return json.Marshal(multiRangeProofJSON{
+		Challenge: scalarToHex(proof.Challenge),
+		Values:    values,
+	})
+}
+
+// UnmarshalJSON decodes a MultiRangeProof encoded with MarshalJSON. It fails
+// if a point is not on the curve or a scalar is not in [0, N)
+func (proof *MultiRangeProof) UnmarshalJSON(b []byte) error {
+	var aux multiRangeProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := MultiRangeProof{}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	p.Values = make([][]MultiRangeBit, len(aux.Values))
+	for j, value := range aux.Values {
+		p.Values[j] = make([]MultiRangeBit, len(value))
+		for i, e := range value {
+			field := fmt.Sprintf("values[%d][%d]", j, i)
+			if p.Values[j][i].C, err = pointFromHex(e.C, field+".c"); err != nil {
+				return err
+			}
+			if p.Values[j][i].C0, err = scalarFromHex(e.C0, field+".c0"); err != nil {
+				return err
+			}
+			if p.Values[j][i].S0, err = scalarFromHex(e.S0, field+".s0"); err != nil {
+				return err
+			}
+			if p.Values[j][i].S1, err = scalarFromHex(e.S1, field+".s1"); err != nil {
+				return err
+			}
+		}
+	}
+	*proof = p
+	return nil
+}
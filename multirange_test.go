@@ -0,0 +1,178 @@
+package zksigma
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// multiRangeCommit commits to values with fresh randomness
+func multiRangeCommit(t testing.TB, values []*big.Int) ([]ECPoint, []*big.Int) {
+	CMs, rs := make([]ECPoint, len(values)), make([]*big.Int, len(values))
+	for i, v := range values {
+		var err error
+		if CMs[i], rs[i], err = PedCommit(TestCurve, v); err != nil {
+			t.Fatalf("%v\n", err)
+		}
+	}
+	return CMs, rs
+}
+
+func TestMultiRangeProof(t *testing.T) {
+	bits := 8
+	max := big.NewInt(255) // 2^bits - 1
+
+	for _, values := range [][]*big.Int{
+		{big.NewInt(42)},
+		{big.NewInt(0), max},
+		{big.NewInt(1), big.NewInt(2), big.NewInt(100), max, big.NewInt(0)},
+	} {
+		CMs, rs := multiRangeCommit(t, values)
+		proof, err := NewMultiRangeProof(TestCurve, CMs, values, rs, bits)
+		if err != nil {
+			t.Fatalf("MultiRangeProof failed to generate for %v: %v\n", values, err)
+		}
+		if ok, err := proof.Verify(TestCurve, CMs, bits); !ok || err != nil {
+			t.Fatalf("MultiRangeProof failed to verify for %v: %v\n", values, err)
+		}
+
+		proof, err = NewMultiRangeProofFromBytes(proof.Bytes())
+		if err != nil {
+			t.Fatalf("MultiRangeProof failed to deserialize: %v\n", err)
+		}
+		if ok, err := proof.Verify(TestCurve, CMs, bits); !ok || err != nil {
+			t.Fatalf("MultiRangeProof failed to verify after deserialization: %v\n", err)
+		}
+	}
+}
+
+func TestMultiRangeProofOneBadValue(t *testing.T) {
+	bits := 8
+	values := []*big.Int{big.NewInt(3), big.NewInt(200), big.NewInt(256), big.NewInt(7)}
+	CMs, rs := multiRangeCommit(t, values)
+	if _, err := NewMultiRangeProof(TestCurve, CMs, values, rs, bits); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("MultiRangeProof generated with 256 among its values: %v\n", err)
+	}
+
+	// a proof of the good values does not verify with the commitment to 256 in
+	// place of one of them
+	good := []*big.Int{values[0], values[1], big.NewInt(17), values[3]}
+	goodCMs := append(append([]ECPoint{}, CMs[:2]...), PedCommitR(TestCurve, good[2], rs[2]), CMs[3])
+	proof, err := NewMultiRangeProof(TestCurve, goodCMs, good, rs, bits)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, goodCMs, bits); !ok || err != nil {
+		t.Fatalf("MultiRangeProof failed to verify: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CMs, bits); ok || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("MultiRangeProof verified with a commitment to 256: %v, %v\n", ok, err)
+	}
+
+	// nor does one that commits to 256 with 8 bits by a bit of 2
+	N := TestCurve.C.Params().N
+	forged := proof.Clone()
+	top := &forged.Values[2][bits-1]
+	top.C = TestCurve.Add(top.C, TestCurve.G)
+	if ok, err := forged.Verify(TestCurve, goodCMs, bits); ok || err == nil {
+		t.Fatalf("MultiRangeProof verified with a bit commitment to 2\n")
+	}
+	top.C0 = new(big.Int).Mod(new(big.Int).Add(top.C0, big.NewInt(1)), N)
+	if ok, err := forged.Verify(TestCurve, goodCMs, bits); ok || err == nil {
+		t.Fatalf("MultiRangeProof verified with a changed c0\n")
+	}
+}
+
+func TestMultiRangeProofFails(t *testing.T) {
+	bits := 8
+	values := []*big.Int{big.NewInt(5), big.NewInt(9)}
+	CMs, rs := multiRangeCommit(t, values)
+	proof, err := NewMultiRangeProof(TestCurve, CMs, values, rs, bits)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	if ok, err := proof.Verify(TestCurve, CMs, bits-1); ok || !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("MultiRangeProof verified for the wrong number of bits: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CMs[:1], bits); ok || !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("MultiRangeProof verified for one commitment less: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, []ECPoint{CMs[1], CMs[0]}, bits); ok || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("MultiRangeProof verified for swapped commitments: %v\n", err)
+	}
+	if _, err := proof.Verify(TestCurve, nil, bits); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("MultiRangeProof.Verify without commitments gives %v\n", err)
+	}
+	tampered := proof.Clone()
+	tampered.Values[1][3].S1 = new(big.Int).Add(tampered.Values[1][3].S1, big.NewInt(1))
+	if ok, err := tampered.Verify(TestCurve, CMs, bits); ok || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("MultiRangeProof verified with a changed s1: %v\n", err)
+	}
+	var nilProof *MultiRangeProof
+	if _, err := nilProof.Verify(TestCurve, CMs, bits); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("Verify of a nil MultiRangeProof gives %v\n", err)
+	}
+
+	if _, err := NewMultiRangeProof(TestCurve, nil, nil, nil, bits); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("MultiRangeProof generated without values: %v\n", err)
+	}
+	if _, err := NewMultiRangeProof(TestCurve, CMs, values, rs[:1], bits); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("MultiRangeProof generated with a randomness missing: %v\n", err)
+	}
+	if _, err := NewMultiRangeProof(TestCurve, CMs, values, []*big.Int{rs[1], rs[0]}, bits); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("MultiRangeProof generated for the wrong randomness: %v\n", err)
+	}
+
+	b := proof.Bytes()
+	if len(b) != proof.Size() {
+		t.Fatalf("Size is %d, Bytes has length %d\n", proof.Size(), len(b))
+	}
+	for _, bad := range [][]byte{nil, b[:len(b)-1], append(b[:len(b):len(b)], 0), {proofEncodingVersion, 0, 0}} {
+		if _, err := NewMultiRangeProofFromBytes(bad); err == nil {
+			t.Fatalf("NewMultiRangeProofFromBytes accepted %d bytes\n", len(bad))
+		}
+	}
+}
+
+// TestMultiRangeProofSize makes sure a MultiRangeProof is smaller than the
+// BitRangeProofs of its values by at least 40%
+func TestMultiRangeProofSize(t *testing.T) {
+	bits := 32
+	values := []*big.Int{big.NewInt(1), big.NewInt(1 << 20), big.NewInt(123456789), big.NewInt(7)}
+	CMs, rs := multiRangeCommit(t, values)
+	proof, err := NewMultiRangeProof(TestCurve, CMs, values, rs, bits)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	separate := 0
+	for i, v := range values {
+		br, err := NewBitRangeProof(TestCurve, CMs[i], v, rs[i], bits)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		separate += br.Size()
+	}
+	if 10*proof.Size() > 6*separate {
+		t.Fatalf("MultiRangeProof takes %d bytes, %d BitRangeProofs %d\n", proof.Size(), len(values), separate)
+	}
+}
+
+func BenchmarkMultiRangeProve_4x32(b *testing.B) {
+	values := []*big.Int{big.NewInt(1), big.NewInt(1 << 20), big.NewInt(123456789), big.NewInt(7)}
+	CMs, rs := multiRangeCommit(b, values)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewMultiRangeProof(TestCurve, CMs, values, rs, 32)
+	}
+}
+
+func BenchmarkMultiRangeVerify_4x32(b *testing.B) {
+	values := []*big.Int{big.NewInt(1), big.NewInt(1 << 20), big.NewInt(123456789), big.NewInt(7)}
+	CMs, rs := multiRangeCommit(b, values)
+	proof, _ := NewMultiRangeProof(TestCurve, CMs, values, rs, 32)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CMs, 32)
+	}
+}
@@ -0,0 +1,310 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// NonZeroProof is a proof that a commitment CM = vG + rH hides a value v that
+// is not 0. The prover commits to inv(v) in B and shows that v * inv(v) = 1,
+// that is G = vB + tH, which is the check ABCProof does without the secret key
+// and CMTok.
+//
+//  Public: generator points G and H, CM
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows v, r with CM = vG + rH
+//  select ub at random
+//  B = inv(v)G + ubH
+//  so G = vB + tH with t = -v * ub
+//  select u1, u2, u3 at random
+//  T1 = u1G + u2H
+//  T2 = u1B + u3H
+//  c = HASH(G, H, CM, B, T1, T2)
+//  s1 = u1 + c * v
+//  s2 = u2 + c * r
+//  s3 = u3 + c * t
+//
+//  B, T1, T2, c, s1, s2, s3 ---------->
+//                                      c ?= HASH(G, H, CM, B, T1, T2)
+//                                      s1G + s2H ?= T1 + cCM
+//                                      s1B + s3H ?= T2 + cG
+//
+// If v was 0 the prover would know t with G = tH, the relation between G and H.
+type NonZeroProof struct {
+	B         ECPoint  // B = inv(v)G + ubH
+	T1        ECPoint  // T1 = u1G + u2H
+	T2        ECPoint  // T2 = u1B + u3H
+	Challenge *big.Int // c = HASH(G, H, CM, B, T1, T2)
+	S1        *big.Int // s1 = u1 + c * v
+	S2        *big.Int // s2 = u2 + c * r
+	S3        *big.Int // s3 = u3 + c * t
+}
+
+// nonZeroChallenge computes c = HASH(G, H, CM, B, T1, T2)
+func nonZeroChallenge(zkpcp ZKPCurveParams, CM, B, T1, T2 ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "NonZeroProof")
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendPoint("CM", CM)
+	t.AppendPoint("B", B)
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	return t.ChallengeScalar("c")
+}
+
+// NewNonZeroProof generates a proof that CM = vG + rH commits to a value that
+// is not 0. It fails if v is 0.
+func NewNonZeroProof(zkpcp ZKPCurveParams, CM ECPoint, v, r *big.Int) (*NonZeroProof, error) {
+	return NewNonZeroProofWithRand(zkpcp, entropy(), CM, v, r)
+}
+
+// NewNonZeroProofWithRand is the same as NewNonZeroProof, but reads its random
+// values from rnd instead of the EntropySource
+func NewNonZeroProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader, CM ECPoint, v, r *big.Int) (*NonZeroProof, error) {
+	N := zkpcp.C.Params().N
+
+	if err := zkpcp.checkScalars("NonZeroProve", "v, r", v, r); err != nil {
+		return nil, err
+	}
+	if v.Sign() == 0 {
+		return nil, inputError("NonZeroProve", "v is zero")
+	}
+	if !zkpcp.validPoints(CM) {
+		return nil, curveError("NonZeroProve")
+	}
+	if !CM.Equal(PedCommitR(zkpcp, v, r)) {
+		return nil, inputError("NonZeroProve", "v and r do not produce CM")
+	}
+
+	us, err := zkpcp.randScalars(rnd, 4)
+	if err != nil {
+		return nil, err
+	}
+	ub, u1, u2, u3 := us[0], us[1], us[2], us[3]
+
+	// B = inv(v)G + ubH
+	B := PedCommitR(zkpcp, new(big.Int).ModInverse(v, N), ub)
+	// t = -v * ub
+	t := new(big.Int).Neg(new(big.Int).Mul(v, ub))
+
+	// T1 = u1G + u2H
+	T1 := PedCommitR(zkpcp, u1, u2)
+	// T2 = u1B + u3H
+	T2 := zkpcp.Add(zkpcp.Mult(B, u1), zkpcp.Mult(zkpcp.H, u3))
+
+	c := nonZeroChallenge(zkpcp, CM, B, T1, T2)
+
+	// s = u + c * x
+	response := func(u, x *big.Int) *big.Int {
+		s := new(big.Int).Add(u, new(big.Int).Mul(c, x))
+		return s.Mod(s, N)
+	}
+
+	return &NonZeroProof{
+		B:         B,
+		T1:        T1,
+		T2:        T2,
+		Challenge: c,
+		S1:        response(u1, v),
+		S2:        response(u2, r),
+		S3:        response(u3, t),
+	}, nil
+}
+
+// Verify checks if NonZeroProof proof shows that CM commits to a value that
+// is not 0
+func (proof *NonZeroProof) Verify(zkpcp ZKPCurveParams, CM ECPoint) (bool, error) {
+	if proof == nil {
+		return false, malformedError("NonZeroProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(CM) || !zkpcp.onCurve(proof.B, proof.T1, proof.T2) {
+		return false, curveError("NonZeroProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("NonZeroProof.Verify", "Challenge, S1, S2, S3", proof.Challenge, proof.S1, proof.S2, proof.S3); err != nil {
+		return false, err
+	}
+
+	c := nonZeroChallenge(zkpcp, CM, proof.B, proof.T1, proof.T2)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("NonZeroProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	negC := new(big.Int).Neg(c)
+
+	// s1G + s2H ?= T1 + cCM, checked as s1G + s2H - cCM ?= T1
+	lhs := zkpcp.MultiMult([]ECPoint{zkpcp.G, zkpcp.H, CM}, []*big.Int{proof.S1, proof.S2, negC})
+	if !pointsEqual(lhs, proof.T1) {
+		return false, equationError("NonZeroProof.Verify", 1, "s1G + s2H - cCM != T1", lhs, proof.T1)
+	}
+
+	// s1B + s3H ?= T2 + cG, checked as s1B + s3H - cG ?= T2
+	lhs = zkpcp.MultiMult([]ECPoint{proof.B, zkpcp.H, zkpcp.G}, []*big.Int{proof.S1, proof.S3, negC})
+	if !pointsEqual(lhs, proof.T2) {
+		return false, equationError("NonZeroProof.Verify", 2, "s1B + s3H - cG != T2", lhs, proof.T2)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, the only arg is CM like for Verify
+func (proof *NonZeroProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("NonZeroProof.VerifyArgs", args)
+	CM := a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CM)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *NonZeroProof) String() string {
+	if proof == nil {
+		return "NonZeroProof(nil)"
+	}
+	return proofString("NonZeroProof", "B", proof.B, "T1", proof.T1, "T2", proof.T2,
+		"Challenge", proof.Challenge, "S1", proof.S1, "S2", proof.S2, "S3", proof.S3)
+}
+
+// nonZeroProofLen is the length of a serialized NonZeroProof
+var nonZeroProofLen = 1 + 3*fixedPointLen + 4*fixedScalarLen
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// NonZeroProof proof
+func (proof *NonZeroProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.B)
+	writeFixedPoint(&buf, proof.T1)
+	writeFixedPoint(&buf, proof.T2)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.S1)
+	writeFixedScalar(&buf, proof.S2)
+	writeFixedScalar(&buf, proof.S3)
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of NonZeroProof proof, which has a fixed size
+func (proof *NonZeroProof) Size() int {
+	return nonZeroProofLen
+}
+
+// Clone returns a deep copy of NonZeroProof proof that shares no big.Int with it
+func (proof *NonZeroProof) Clone() *NonZeroProof {
+	if proof == nil {
+		return nil
+	}
+	return &NonZeroProof{
+		B:         clonePoint(proof.B),
+		T1:        clonePoint(proof.T1),
+		T2:        clonePoint(proof.T2),
+		Challenge: cloneScalar(proof.Challenge),
+		S1:        cloneScalar(proof.S1),
+		S2:        cloneScalar(proof.S2),
+		S3:        cloneScalar(proof.S3),
+	}
+}
+
+// Equal returns true if NonZeroProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *NonZeroProof) Equal(other *NonZeroProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.B, other.B) &&
+		samePoint(proof.T1, other.T1) &&
+		samePoint(proof.T2, other.T2) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.S1, other.S1) &&
+		sameScalar(proof.S2, other.S2) &&
+		sameScalar(proof.S3, other.S3)
+}
+
+// NewNonZeroProofFromBytes returns a NonZeroProof generated from the
+// deserialization of byte slice b
+func NewNonZeroProofFromBytes(b []byte) (*NonZeroProof, error) {
+	if err := checkFixedHeader(b, nonZeroProofLen, "NonZeroProofFromBytes"); err != nil {
+		return nil, err
+	}
+	proof := new(NonZeroProof)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.B, err = readFixedPoint(buf, "NonZeroProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if proof.T1, err = readFixedPoint(buf, "NonZeroProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if proof.T2, err = readFixedPoint(buf, "NonZeroProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "NonZeroProofFromBytes", &proof.Challenge, &proof.S1, &proof.S2, &proof.S3); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+type nonZeroProofJSON struct {
+	B         string `json:"b"`
+	T1        string `json:"t1"`
+	T2        string `json:"t2"`
+	Challenge string `json:"challenge"`
+	S1        string `json:"s1"`
+	S2        string `json:"s2"`
+	S3        string `json:"s3"`
+}
+
+// MarshalJSON encodes NonZeroProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *NonZeroProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(nonZeroProofJSON{
+		B:         pointToHex(proof.B),
+		T1:        pointToHex(proof.T1),
+		T2:        pointToHex(proof.T2),
+		Challenge: scalarToHex(proof.Challenge),
+		S1:        scalarToHex(proof.S1),
+		S2:        scalarToHex(proof.S2),
+		S3:        scalarToHex(proof.S3),
+	})
+}
+
+// UnmarshalJSON decodes a NonZeroProof encoded with MarshalJSON. It fails if a
+// point is not on the curve or a scalar is not in [0, N)
+func (proof *NonZeroProof) UnmarshalJSON(b []byte) error {
+	var aux nonZeroProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := NonZeroProof{}
+	if p.B, err = pointFromHex(aux.B, "b"); err != nil {
+		return err
+	}
+	if p.T1, err = pointFromHex(aux.T1, "t1"); err != nil {
+		return err
+	}
+	if p.T2, err = pointFromHex(aux.T2, "t2"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.S1, err = scalarFromHex(aux.S1, "s1"); err != nil {
+		return err
+	}
+	if p.S2, err = scalarFromHex(aux.S2, "s2"); err != nil {
+		return err
+	}
+	if p.S3, err = scalarFromHex(aux.S3, "s3"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
@@ -0,0 +1,115 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestNonZeroProof(t *testing.T) {
+	N := TestCurve.C.Params().N
+	a, _ := rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic range"
+
+	for _, v := range []*big.Int{
+		big.NewInt(1),
+		new(big.Int).Sub(N, big.NewInt(1)),
+		new(big.Int).Add(a, big.NewInt(1)),
+	} {
+		CM, r, err := PedCommit(TestCurve, v)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+
+		proof, err := NewNonZeroProof(TestCurve, CM, v, r)
+		if err != nil {
+			t.Fatalf("NonZeroProof failed to generate for %v: %v\n", v, err)
+		}
+		if ok, err := proof.Verify(TestCurve, CM); !ok || err != nil {
+			t.Fatalf("NonZeroProof failed to verify for %v: %v\n", v, err)
+		}
+
+		other, _, _ := PedCommit(TestCurve, v)
+		if ok, err := proof.Verify(TestCurve, other); ok || err == nil {
+			t.Fatalf("NonZeroProof verified for another commitment\n")
+		}
+	}
+}
+
+func TestNonZeroProofZero(t *testing.T) {
+	N := TestCurve.C.Params().N
+	r, _ := rand.Int(rand.Reader, N)
+	CM := PedCommitR(TestCurve, big.NewInt(0), r)
+
+	if _, err := NewNonZeroProof(TestCurve, CM, big.NewInt(0), r); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("NonZeroProof for 0 gives %v, expected ErrInvalidInput\n", err)
+	}
+	// N is 0 mod N
+	if _, err := NewNonZeroProof(TestCurve, CM, N, r); err == nil {
+		t.Fatalf("NonZeroProof generated for N\n")
+	}
+	if _, err := NewNonZeroProof(TestCurve, CM, big.NewInt(1), r); err == nil {
+		t.Fatalf("NonZeroProof generated for a value that CM does not hide\n")
+	}
+}
+
+func TestNonZeroProofForged(t *testing.T) {
+	N := TestCurve.C.Params().N
+	r, _ := rand.Int(rand.Reader, N)
+	CM := PedCommitR(TestCurve, big.NewInt(0), r)
+
+	// follow the prover for v = 0, there is no inverse to put in B and no t
+	// with G = vB + tH
+	us := make([]*big.Int, 4)
+	for i := range us {
+		us[i], _ = rand.Int(rand.Reader, N)
+	}
+	ub, u1, u2, u3 := us[0], us[1], us[2], us[3]
+	B := TestCurve.Mult(TestCurve.H, ub)
+	T1 := PedCommitR(TestCurve, u1, u2)
+	T2 := TestCurve.Add(TestCurve.Mult(B, u1), TestCurve.Mult(TestCurve.H, u3))
+	c := nonZeroChallenge(TestCurve, CM, B, T1, T2)
+	response := func(u, x *big.Int) *big.Int {
+		s := new(big.Int).Add(u, new(big.Int).Mul(c, x))
+		return s.Mod(s, N)
+	}
+	forged := &NonZeroProof{B, T1, T2, c, response(u1, big.NewInt(0)), response(u2, r), u3}
+
+	ok, err := forged.Verify(TestCurve, CM)
+	var perr *ProofError
+	if ok || !errors.As(err, &perr) || perr.Code != ErrCodeEquationFailed || perr.Equation != 2 {
+		t.Fatalf("forged NonZeroProof gives %v, %v, expected equation 2 to fail\n", ok, err)
+	}
+
+	// a proof for a nonzero value does not carry over to a commitment to 0
+	CM1, r1, _ := PedCommit(TestCurve, big.NewInt(1))
+	proof, err := NewNonZeroProof(TestCurve, CM1, big.NewInt(1), r1)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CM); ok || err == nil {
+		t.Fatalf("NonZeroProof verified for a commitment to 0\n")
+	}
+
+	var nilProof *NonZeroProof
+	if ok, err := nilProof.Verify(TestCurve, CM); ok || err == nil {
+		t.Fatalf("nil NonZeroProof verified\n")
+	}
+}
+
+func BenchmarkNonZeroProve(b *testing.B) {
+	CM, r, _ := PedCommit(TestCurve, big.NewInt(5))
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewNonZeroProof(TestCurve, CM, big.NewInt(5), r)
+	}
+}
+
+func BenchmarkNonZeroVerify(b *testing.B) {
+	CM, r, _ := PedCommit(TestCurve, big.NewInt(5))
+	proof, _ := NewNonZeroProof(TestCurve, CM, big.NewInt(5), r)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CM)
+	}
+}
@@ -0,0 +1,373 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/mit-dci/zksigma/wire"
+)
+
+// OneOfManyProof is a proof that you know x with Result_k = xBase_k for one
+// index k of n base and result pairs, without revealing k. It generalizes the
+// two sides of DisjunctiveProof to any number of branches: all branches but
+// k are simulated with a chosen challenge, and the challenge of branch k is
+// whatever is left of the hash, so the prover can only answer for a branch it
+// knows the discrete log of.
+//
+//  Public: points Base_1, ..., Base_n and Result_1, ..., Result_n
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows x with Result_k = xBase_k
+//  for i != k:
+//    selects random c_i, s_i
+//    T_i = s_iBase_i - c_iResult_i
+//  selects random u
+//  T_k = uBase_k
+//  c = HASH(Base_1, Result_1, ..., Base_n, Result_n, T_1, ..., T_n)
+//  c_k = c - sum(c_i for i != k)
+//  s_k = u + c_k * x
+//
+//  T_i, c, c_i, s_i ------------------>
+//                                      c ?= HASH(Base_1, Result_1, ..., T_1, ..., T_n)
+//                                      c ?= sum(c_i) // mod zkpcp.C.Params().N
+//                                      s_iBase_i ?= T_i + c_iResult_i for all i
+//
+// The proof is one point and two scalars per branch, plus the challenge.
+type OneOfManyProof struct {
+	T  []ECPoint  // T_i, one per branch
+	C  *big.Int   // c = HASH(Base_1, Result_1, ..., T_1, ..., T_n)
+	Cs []*big.Int // c_i, they add up to c
+	S  []*big.Int // s_i
+}
+
+// oneOfManyChallenge computes c = HASH(Base_1, Result_1, ..., Base_n, Result_n, T_1, ..., T_n)
+func oneOfManyChallenge(zkpcp ZKPCurveParams, bases, results, T []ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "OneOfManyProof")
+	for i := range bases {
+		t.AppendPoint("Base", bases[i])
+		t.AppendPoint("Result", results[i])
+	}
+	for _, Ti := range T {
+		t.AppendPoint("T", Ti)
+	}
+	return t.ChallengeScalar("c")
+}
+
+// NewOneOfManyProof generates a proof that witness is the discrete log of
+// results[i] with respect to bases[i] for some i. knownIndex is the branch
+// witness belongs to, the verifier does not learn it.
+func NewOneOfManyProof(zkpcp ZKPCurveParams, bases, results []ECPoint, knownIndex int, witness *big.Int) (*OneOfManyProof, error) {
+	return NewOneOfManyProofWithRand(zkpcp, entropy(), bases, results, knownIndex, witness)
+}
+
+// NewOneOfManyProofWithRand is the same as NewOneOfManyProof, but reads its
+// random values from rnd instead of the EntropySource
+func NewOneOfManyProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	bases, results []ECPoint, knownIndex int, witness *big.Int) (*OneOfManyProof, error) {
+
+	challenge := func(T []ECPoint) *big.Int { return oneOfManyChallenge(zkpcp, bases, results, T) }
+	return proveOneOfMany(zkpcp, rnd, "OneOfManyProve", bases, results, knownIndex, witness, challenge)
+}
+
+// proveOneOfMany generates a OneOfManyProof whose challenge is computed from
+// the T_i by challenge, so proofs built on top of it can hash their own
+// statement. t names the caller in errors.
+func proveOneOfMany(zkpcp ZKPCurveParams, rnd io.Reader, t string,
+	bases, results []ECPoint, knownIndex int, witness *big.Int, challenge func(T []ECPoint) *big.Int) (*OneOfManyProof, error) {
+
+	N := zkpcp.C.Params().N
+
+	if len(bases) == 0 {
+		return nil, inputError(t, "no branches")
+	}
+	if len(results) != len(bases) {
+		return nil, inputError(t, "bases and results must have the same length")
+	}
+	if knownIndex < 0 || knownIndex >= len(bases) {
+		return nil, inputError(t, fmt.Sprintf("knownIndex %d out of range", knownIndex))
+	}
+	if err := zkpcp.checkScalars(t, "witness", witness); err != nil {
+		return nil, err
+	}
+	if !zkpcp.validPoints(bases...) || !zkpcp.validPoints(results...) {
+		return nil, curveError(t)
+	}
+	if !zkpcp.Mult(bases[knownIndex], witness).Equal(results[knownIndex]) {
+		return nil, inputError(t, "base and result to be proved not related by witness")
+	}
+
+	proof := &OneOfManyProof{
+		T:  make([]ECPoint, len(bases)),
+		Cs: make([]*big.Int, len(bases)),
+		S:  make([]*big.Int, len(bases)),
+	}
+
+	// c_i and s_i of every branch but knownIndex in order, then u
+	rs, err := zkpcp.randScalars(rnd, 2*len(bases)-1)
+	if err != nil {
+		return nil, err
+	}
+
+	// simulate every branch but knownIndex, summing up their challenges
+	simulatedC := new(big.Int)
+	for i := range bases {
+		if i == knownIndex {
+			continue
+		}
+		c, s := rs[0], rs[1]
+		rs = rs[2:]
+		// T_i = s_iBase_i - c_iResult_i
+		proof.T[i] = zkpcp.Sub(zkpcp.Mult(bases[i], s), zkpcp.Mult(results[i], c))
+		proof.Cs[i], proof.S[i] = c, s
+		simulatedC.Add(simulatedC, c)
+	}
+
+	u := rs[0]
+	proof.T[knownIndex] = zkpcp.Mult(bases[knownIndex], u)
+
+	proof.C = challenge(proof.T)
+
+	// c_k = c - sum(c_i for i != k)
+	ck := new(big.Int).Sub(proof.C, simulatedC)
+	ck.Mod(ck, N)
+
+	// s_k = u + c_k * x
+	sk := new(big.Int).Add(u, new(big.Int).Mul(ck, witness))
+	sk.Mod(sk, N)
+
+	proof.Cs[knownIndex], proof.S[knownIndex] = ck, sk
+
+	return proof, nil
+}
+
+// Verify checks if OneOfManyProof proof shows that the prover knows the
+// discrete log of results[i] with respect to bases[i] for some i
+func (proof *OneOfManyProof) Verify(zkpcp ZKPCurveParams, bases, results []ECPoint) (bool, error) {
+	return proof.verify(zkpcp, "OneOfManyProof.Verify", bases, results, func(T []ECPoint) *big.Int {
+		return oneOfManyChallenge(zkpcp, bases, results, T)
+	})
+}
+
+// verify is Verify with the challenge computed from the T_i by challenge, see
+// proveOneOfMany. t names the caller in errors.
+func (proof *OneOfManyProof) verify(zkpcp ZKPCurveParams, t string, bases, results []ECPoint, challenge func(T []ECPoint) *big.Int) (bool, error) {
+	if proof == nil {
+		return false, malformedError(t, fmt.Sprintf("passed proof is nil"))
+	}
+
+	if len(bases) == 0 {
+		return false, inputError(t, "no branches")
+	}
+	if len(results) != len(bases) {
+		return false, inputError(t, "bases and results must have the same length")
+	}
+	if len(proof.T) != len(bases) || len(proof.Cs) != len(bases) || len(proof.S) != len(bases) {
+		return false, malformedError(t, "proof does not have one branch per base")
+	}
+
+	if !zkpcp.validPoints(bases...) || !zkpcp.validPoints(results...) || !zkpcp.onCurve(proof.T...) {
+		return false, curveError(t)
+	}
+	if err := zkpcp.canonicalScalars(t, "C", proof.C); err != nil {
+		return false, err
+	}
+	if err := zkpcp.canonicalScalars(t, "Cs[]", proof.Cs...); err != nil {
+		return false, err
+	}
+	if err := zkpcp.canonicalScalars(t, "S[]", proof.S...); err != nil {
+		return false, err
+	}
+
+	c := challenge(proof.T)
+	if !scalarsEqual(c, proof.C) {
+		return false, challengeError(t, "calculated challenge and proof's challenge do not agree", c, proof.C)
+	}
+
+	// c ?= sum(c_i)
+	totalC := new(big.Int)
+	for i, ci := range proof.Cs {
+		if ci == nil || proof.S[i] == nil {
+			return false, malformedError(t, fmt.Sprintf("branch %d is missing a scalar", i))
+		}
+		totalC.Add(totalC, ci)
+	}
+	totalC.Mod(totalC, zkpcp.C.Params().N)
+	if !scalarsEqual(totalC, proof.C) {
+		return false, challengeError(t, "challenges of the branches do not add up to the challenge", totalC, proof.C)
+	}
+
+	// s_iBase_i ?= T_i + c_iResult_i, checked as s_iBase_i - c_iResult_i ?= T_i
+	for i := range bases {
+		lhs := zkpcp.MultiMult([]ECPoint{bases[i], results[i]}, []*big.Int{proof.S[i], new(big.Int).Neg(proof.Cs[i])})
+		if !pointsEqual(lhs, proof.T[i]) {
+			return false, equationError(t, i+1, fmt.Sprintf("s_%dBase_%d - c_%dResult_%d != T_%d", i, i, i, i, i), lhs, proof.T[i])
+		}
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are bases as []ECPoint and results as
+// []ECPoint like for Verify
+func (proof *OneOfManyProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("OneOfManyProof.VerifyArgs", args)
+	bases, results := a.points(), a.points()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, bases, results)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *OneOfManyProof) String() string {
+	if proof == nil {
+		return "OneOfManyProof(nil)"
+	}
+	fields := []interface{}{"C", proof.C}
+	for i, Ti := range proof.T {
+		fields = append(fields, fmt.Sprintf("T[%d]", i), Ti)
+	}
+	for i, ci := range proof.Cs {
+		fields = append(fields, fmt.Sprintf("Cs[%d]", i), ci)
+	}
+	for i, si := range proof.S {
+		fields = append(fields, fmt.Sprintf("S[%d]", i), si)
+	}
+	return proofString("OneOfManyProof", fields...)
+}
+
+// oneOfManyBranchLen is the length of one serialized branch T_i, c_i, s_i
+var oneOfManyBranchLen = fixedPointLen + 2*fixedScalarLen
+
+// Bytes returns a byte slice with a serialized representation of
+// OneOfManyProof proof, which grows linearly with the number of branches
+func (proof *OneOfManyProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	wire.WriteVarInt(&buf, uint64(len(proof.T)))
+	writeFixedScalar(&buf, proof.C)
+	for i := range proof.T {
+		writeFixedPoint(&buf, proof.T[i])
+		writeFixedScalar(&buf, proof.Cs[i])
+		writeFixedScalar(&buf, proof.S[i])
+	}
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of OneOfManyProof proof without serializing it
+func (proof *OneOfManyProof) Size() int {
+	n := len(proof.T)
+	return 1 + wire.VarIntSerializeSize(uint64(n)) + fixedScalarLen + n*oneOfManyBranchLen
+}
+
+// Clone returns a deep copy of OneOfManyProof proof that shares no big.Int with it
+func (proof *OneOfManyProof) Clone() *OneOfManyProof {
+	if proof == nil {
+		return nil
+	}
+	return &OneOfManyProof{
+		T:  clonePoints(proof.T),
+		C:  cloneScalar(proof.C),
+		Cs: cloneScalars(proof.Cs),
+		S:  cloneScalars(proof.S),
+	}
+}
+
+// Equal returns true if OneOfManyProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *OneOfManyProof) Equal(other *OneOfManyProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoints(proof.T, other.T) &&
+		sameScalar(proof.C, other.C) &&
+		sameScalars(proof.Cs, other.Cs) &&
+		sameScalars(proof.S, other.S)
+}
+
+// NewOneOfManyProofFromBytes returns a OneOfManyProof generated from the
+// deserialization of byte slice b
+func NewOneOfManyProofFromBytes(b []byte) (*OneOfManyProof, error) {
+	if len(b) > 0 && b[0] != proofEncodingVersion {
+		return nil, malformedError("OneOfManyProofFromBytes", fmt.Sprintf("unsupported encoding version %d", b[0]))
+	}
+	if len(b) == 0 {
+		return nil, malformedError("OneOfManyProofFromBytes", "truncated buffer")
+	}
+	buf := bytes.NewBuffer(b[1:])
+	n, err := wire.ReadVarInt(buf)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 || n > uint64(buf.Len()/oneOfManyBranchLen) ||
+		uint64(buf.Len()) != uint64(fixedScalarLen)+n*uint64(oneOfManyBranchLen) {
+		return nil, malformedError("OneOfManyProofFromBytes", "buffer length does not match number of branches")
+	}
+
+	proof := &OneOfManyProof{
+		T:  make([]ECPoint, n),
+		Cs: make([]*big.Int, n),
+		S:  make([]*big.Int, n),
+	}
+	if proof.C, err = readFixedScalar(buf, "OneOfManyProofFromBytes"); err != nil {
+		return nil, err
+	}
+	for i := range proof.T {
+		if proof.T[i], err = readFixedPoint(buf, "OneOfManyProofFromBytes"); err != nil {
+			return nil, err
+		}
+		if err = readFixedScalars(buf, "OneOfManyProofFromBytes", &proof.Cs[i], &proof.S[i]); err != nil {
+			return nil, err
+		}
+	}
+	return proof, nil
+}
+
+type oneOfManyProofJSON struct {
+	T  []string `json:"t"`
+	C  string   `json:"c"`
+	Cs []string `json:"cs"`
+	S  []string `json:"s"`
+}
+
+// MarshalJSON encodes OneOfManyProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *OneOfManyProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(oneOfManyProofJSON{
+		T:  pointsToHex(proof.T),
+		C:  scalarToHex(proof.C),
+		Cs: scalarsToHex(proof.Cs),
+		S:  scalarsToHex(proof.S),
+	})
+}
+
+// UnmarshalJSON decodes an OneOfManyProof encoded with MarshalJSON. It fails
+// if a point is not on the curve or a scalar is not in [0, N)
+func (proof *OneOfManyProof) UnmarshalJSON(b []byte) error {
+	var aux oneOfManyProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := OneOfManyProof{}
+	if p.T, err = pointsFromHex(aux.T, "t"); err != nil {
+		return err
+	}
+	if p.C, err = scalarFromHex(aux.C, "c"); err != nil {
+		return err
+	}
+	if p.Cs, err = scalarsFromHex(aux.Cs, "cs"); err != nil {
+		return err
+	}
+	if p.S, err = scalarsFromHex(aux.S, "s"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
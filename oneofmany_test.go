@@ -0,0 +1,204 @@
+package zksigma
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// denominations returns bases and results for a OneOfManyProof that CM is a
+// commitment to one of values: the ith result is CM - values[i]G, whose
+// discrete log with respect to H is the randomness of CM if CM hides values[i]
+func denominations(zkpcp ZKPCurveParams, CM ECPoint, values []*big.Int) ([]ECPoint, []ECPoint) {
+	bases := make([]ECPoint, len(values))
+	results := make([]ECPoint, len(values))
+	for i, v := range values {
+		bases[i] = zkpcp.H
+		results[i] = zkpcp.Sub(CM, zkpcp.Mult(zkpcp.G, v))
+	}
+	return bases, results
+}
+
+func TestOneOfManyProof(t *testing.T) {
+	// n = 2 proves the same statements as DisjunctiveProof
+	x := big.NewInt(100)
+	y := big.NewInt(101)
+	bases := []ECPoint{TestCurve.G, TestCurve.H}
+	results := []ECPoint{TestCurve.Mult(TestCurve.G, x), TestCurve.Mult(TestCurve.H, y)}
+	for i, w := range []*big.Int{x, y} {
+		proof, err := NewOneOfManyProof(TestCurve, bases, results, i, w)
+		if err != nil {
+			t.Fatalf("TestOneOfManyProof - n = 2, index %d: %v\n", i, err)
+		}
+		if ok, err := proof.Verify(TestCurve, bases, results); !ok || err != nil {
+			t.Fatalf("TestOneOfManyProof - n = 2, index %d did not verify: %v\n", i, err)
+		}
+		if ok, _ := proof.Verify(TestCurve, results, bases); ok {
+			t.Fatalf("TestOneOfManyProof - n = 2, index %d verified for swapped bases and results\n", i)
+		}
+	}
+
+	// n = 1 is a plain proof of knowledge of x, whose one challenge is c
+	single, err := NewOneOfManyProof(TestCurve, bases[:1], results[:1], 0, x)
+	if err != nil {
+		t.Fatalf("TestOneOfManyProof - n = 1: %v\n", err)
+	}
+	if ok, err := single.Verify(TestCurve, bases[:1], results[:1]); !ok || err != nil || single.Cs[0].Cmp(single.C) != 0 {
+		t.Fatalf("TestOneOfManyProof - n = 1 did not verify: %v\n", err)
+	}
+	if _, err := NewOneOfManyProof(TestCurve, bases[:1], results[:1], 1, x); err == nil {
+		t.Fatalf("TestOneOfManyProof - n = 1 proved index 1\n")
+	}
+
+	// n = 16, the commitment is one of 16 allowed denominations
+	values := make([]*big.Int, 16)
+	for i := range values {
+		values[i] = big.NewInt(int64(1) << uint(i))
+	}
+	for _, k := range []int{0, 7, 15} {
+		CM, r, err := PedCommit(TestCurve, values[k])
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		bases, results := denominations(TestCurve, CM, values)
+		proof, err := NewOneOfManyProof(TestCurve, bases, results, k, r)
+		if err != nil {
+			t.Fatalf("TestOneOfManyProof - n = 16, index %d: %v\n", k, err)
+		}
+		if ok, err := proof.Verify(TestCurve, bases, results); !ok || err != nil {
+			t.Fatalf("TestOneOfManyProof - n = 16, index %d did not verify: %v\n", k, err)
+		}
+
+		CM2 := PedCommitR(TestCurve, big.NewInt(3), r)
+		bases2, results2 := denominations(TestCurve, CM2, values)
+		if ok, _ := proof.Verify(TestCurve, bases2, results2); ok {
+			t.Fatalf("TestOneOfManyProof - n = 16 verified for a commitment to 3\n")
+		}
+		if _, err := NewOneOfManyProof(TestCurve, bases2, results2, 1, r); err == nil {
+			t.Fatalf("TestOneOfManyProof - proved that a commitment to 3 is 2\n")
+		}
+
+		b := proof.Bytes()
+		if len(b) != 1+1+fixedScalarLen+16*oneOfManyBranchLen {
+			t.Fatalf("TestOneOfManyProof - n = 16 serializes to %d bytes\n", len(b))
+		}
+		decoded, err := NewOneOfManyProofFromBytes(b)
+		if err != nil {
+			t.Fatalf("TestOneOfManyProof - failed to decode: %v\n", err)
+		}
+		if ok, err := decoded.Verify(TestCurve, bases, results); !ok || err != nil {
+			t.Fatalf("TestOneOfManyProof - decoded proof did not verify: %v\n", err)
+		}
+		if !bytes.Equal(decoded.Bytes(), b) {
+			t.Fatalf("TestOneOfManyProof - decoding and encoding again changes the proof\n")
+		}
+		for _, short := range [][]byte{nil, b[:1], b[:len(b)-1], append(b, 0)} {
+			if _, err := NewOneOfManyProofFromBytes(short); err == nil {
+				t.Fatalf("TestOneOfManyProof - decoded %d bytes\n", len(short))
+			}
+		}
+	}
+}
+
+func TestOneOfManyProofCorrupted(t *testing.T) {
+	N := TestCurve.C.Params().N
+	values := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(5), big.NewInt(10)}
+	CM, r, _ := PedCommit(TestCurve, values[2])
+	bases, results := denominations(TestCurve, CM, values)
+	proof, err := NewOneOfManyProof(TestCurve, bases, results, 2, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	// a changed sub-challenge no longer adds up to the challenge
+	for i := range proof.Cs {
+		q := *proof
+		q.Cs = append([]*big.Int{}, proof.Cs...)
+		q.Cs[i] = new(big.Int).Add(q.Cs[i], big.NewInt(1))
+		if ok, _ := q.Verify(TestCurve, bases, results); ok {
+			t.Fatalf("TestOneOfManyProofCorrupted - verified with sub-challenge %d changed\n", i)
+		}
+
+		// moving challenge between two branches keeps the sum, but breaks
+		// the equations
+		j := (i + 1) % len(proof.Cs)
+		q.Cs[j] = new(big.Int).Sub(q.Cs[j], big.NewInt(1))
+		q.Cs[j].Mod(q.Cs[j], N)
+		if ok, _ := q.Verify(TestCurve, bases, results); ok {
+			t.Fatalf("TestOneOfManyProofCorrupted - verified with challenge moved from %d to %d\n", j, i)
+		}
+	}
+
+	q := *proof
+	q.S = append([]*big.Int{}, proof.S...)
+	q.S[0] = nil
+	if ok, err := q.Verify(TestCurve, bases, results); ok || err == nil {
+		t.Fatalf("TestOneOfManyProofCorrupted - verified with a nil response\n")
+	}
+	q = *proof
+	q.T = proof.T[1:]
+	if ok, err := q.Verify(TestCurve, bases, results); ok || err == nil {
+		t.Fatalf("TestOneOfManyProofCorrupted - verified with a branch missing\n")
+	}
+	if ok, err := proof.Verify(TestCurve, bases[1:], results[1:]); ok || err == nil {
+		t.Fatalf("TestOneOfManyProofCorrupted - verified for fewer branches\n")
+	}
+}
+
+func TestOneOfManyProofErrors(t *testing.T) {
+	x, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	bases := []ECPoint{TestCurve.G, TestCurve.H}
+	results := []ECPoint{TestCurve.Mult(TestCurve.G, x), TestCurve.Mult(TestCurve.G, x)}
+
+	cases := []struct {
+		name           string
+		bases, results []ECPoint
+		knownIndex     int
+		witness        *big.Int
+	}{
+		{"no branches", nil, nil, 0, x},
+		{"mismatched lengths", bases, results[:1], 0, x},
+		{"negative index", bases, results, -1, x},
+		{"index out of range", bases, results, 2, x},
+		{"wrong witness", bases, results, 1, x},
+		{"nil witness", bases, results, 0, nil},
+	}
+	for _, c := range cases {
+		if _, err := NewOneOfManyProof(TestCurve, c.bases, c.results, c.knownIndex, c.witness); err == nil {
+			t.Fatalf("TestOneOfManyProofErrors - %s should fail\n", c.name)
+		}
+	}
+
+	var nilProof *OneOfManyProof
+	if ok, err := nilProof.Verify(TestCurve, bases, results); ok || err == nil {
+		t.Fatalf("TestOneOfManyProofErrors - nil proof verified\n")
+	}
+}
+
+func BenchmarkOneOfManyProve16(b *testing.B) {
+	values := make([]*big.Int, 16)
+	for i := range values {
+		values[i] = big.NewInt(int64(i))
+	}
+	CM, r, _ := PedCommit(TestCurve, values[5])
+	bases, results := denominations(TestCurve, CM, values)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewOneOfManyProof(TestCurve, bases, results, 5, r)
+	}
+}
+
+func BenchmarkOneOfManyVerify16(b *testing.B) {
+	values := make([]*big.Int, 16)
+	for i := range values {
+		values[i] = big.NewInt(int64(i))
+	}
+	CM, r, _ := PedCommit(TestCurve, values[5])
+	bases, results := denominations(TestCurve, CM, values)
+	proof, _ := NewOneOfManyProof(TestCurve, bases, results, 5, r)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, bases, results)
+	}
+}
@@ -0,0 +1,240 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// OpenProof is a proof of knowledge of the opening (v, r) of a Pedersen
+// commitment CM = vG + rH, without revealing v or r.
+//
+//  Public: generator points G and H, CM
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows v, r with CM = vG + rH        learns CM
+//  selects random u1, u2
+//  T = u1G + u2H
+//  c = HASH(G, H, CM, T)
+//  s1 = u1 + c * v
+//  s2 = u2 + c * r
+//
+//  T, c, s1, s2 ---------------------->
+//                                      c ?= HASH(G, H, CM, T)
+//                                      cCM + T ?= s1G + s2H
+type OpenProof struct {
+	T         ECPoint  // T = u1G + u2H
+	Challenge *big.Int // c = HASH(G, H, CM, T)
+	S1        *big.Int // s1 = u1 + c * v
+	S2        *big.Int // s2 = u2 + c * r
+}
+
+// openChallenge computes c = HASH(G, H, CM, T)
+func openChallenge(zkpcp ZKPCurveParams, CM, T ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "OpenProof")
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendPoint("CM", CM)
+	t.AppendPoint("T", T)
+	return t.ChallengeScalar("c")
+}
+
+// NewOpenProof generates a proof that the prover knows v and r such that
+// CM = vG + rH. The point at infinity can not be proved.
+func NewOpenProof(zkpcp ZKPCurveParams, CM ECPoint, v, r *big.Int) (*OpenProof, error) {
+	return NewOpenProofWithRand(zkpcp, entropy(), CM, v, r)
+}
+
+// NewOpenProofWithRand is the same as NewOpenProof, but reads its random
+// values from rnd instead of the EntropySource
+func NewOpenProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader, CM ECPoint, v, r *big.Int) (*OpenProof, error) {
+	if !zkpcp.validPoints(CM) {
+		return nil, curveError("OpenProve")
+	}
+	if CM.Equal(Zero) {
+		return nil, inputError("OpenProve", "CM is the point at infinity")
+	}
+	if err := zkpcp.checkScalars("OpenProve", "v, r", v, r); err != nil {
+		return nil, err
+	}
+	if !Open(zkpcp, v, r, CM) {
+		return nil, inputError("OpenProve", "v and r do not produce CM")
+	}
+
+	us, err := zkpcp.randScalars(rnd, 2)
+	if err != nil {
+		return nil, err
+	}
+	u1, u2 := us[0], us[1]
+
+	T := PedCommitR(zkpcp, u1, u2)
+	c := openChallenge(zkpcp, CM, T)
+
+	s1 := new(big.Int).Add(u1, new(big.Int).Mul(c, v))
+	s1.Mod(s1, zkpcp.C.Params().N)
+	s2 := new(big.Int).Add(u2, new(big.Int).Mul(c, r))
+	s2.Mod(s2, zkpcp.C.Params().N)
+
+	return &OpenProof{T, c, s1, s2}, nil
+}
+
+// Verify checks if OpenProof proof shows knowledge of the opening of CM
+func (proof *OpenProof) Verify(zkpcp ZKPCurveParams, CM ECPoint) (bool, error) {
+	if proof == nil {
+		return false, malformedError("OpenProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(CM) || !zkpcp.onCurve(proof.T) {
+		return false, curveError("OpenProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("OpenProof.Verify", "Challenge, S1, S2", proof.Challenge, proof.S1, proof.S2); err != nil {
+		return false, err
+	}
+
+	if CM.Equal(Zero) {
+		return false, inputError("OpenProof.Verify", "CM is the point at infinity")
+	}
+
+	c := openChallenge(zkpcp, CM, proof.T)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("OpenProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	// cCM + T ?= s1G + s2H, checked as s1G + s2H - cCM ?= T
+	lhs := zkpcp.MultiMult([]ECPoint{zkpcp.G, zkpcp.H, CM}, []*big.Int{proof.S1, proof.S2, new(big.Int).Neg(c)})
+	if !pointsEqual(lhs, proof.T) {
+		return false, equationError("OpenProof.Verify", 1, "s1G + s2H - cCM != T", lhs, proof.T)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, the only arg is CM like for Verify
+func (proof *OpenProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("OpenProof.VerifyArgs", args)
+	CM := a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CM)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *OpenProof) String() string {
+	if proof == nil {
+		return "OpenProof(nil)"
+	}
+	return proofString("OpenProof", "T", proof.T, "Challenge", proof.Challenge,
+		"S1", proof.S1, "S2", proof.S2)
+}
+
+// openProofLen is the length of a serialized OpenProof
+var openProofLen = 1 + fixedPointLen + 3*fixedScalarLen
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// OpenProof proof
+func (proof *OpenProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.T)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.S1)
+	writeFixedScalar(&buf, proof.S2)
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of OpenProof proof, which has a fixed size
+func (proof *OpenProof) Size() int {
+	return openProofLen
+}
+
+// Clone returns a deep copy of OpenProof proof that shares no big.Int with it
+func (proof *OpenProof) Clone() *OpenProof {
+	if proof == nil {
+		return nil
+	}
+	return &OpenProof{
+		T:         clonePoint(proof.T),
+		Challenge: cloneScalar(proof.Challenge),
+		S1:        cloneScalar(proof.S1),
+		S2:        cloneScalar(proof.S2),
+	}
+}
+
+// Equal returns true if OpenProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *OpenProof) Equal(other *OpenProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.T, other.T) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.S1, other.S1) &&
+		sameScalar(proof.S2, other.S2)
+}
+
+// NewOpenProofFromBytes returns an OpenProof generated from the
+// deserialization of byte slice b
+func NewOpenProofFromBytes(b []byte) (*OpenProof, error) {
+	if err := checkFixedHeader(b, openProofLen, "OpenProofFromBytes"); err != nil {
+		return nil, err
+	}
+	proof := new(OpenProof)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.T, err = readFixedPoint(buf, "OpenProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "OpenProofFromBytes", &proof.Challenge, &proof.S1, &proof.S2); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+type openProofJSON struct {
+	T         string `json:"t"`
+	Challenge string `json:"challenge"`
+	S1        string `json:"s1"`
+	S2        string `json:"s2"`
+}
+
+// MarshalJSON encodes OpenProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *OpenProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(openProofJSON{
+		T:         pointToHex(proof.T),
+		Challenge: scalarToHex(proof.Challenge),
+		S1:        scalarToHex(proof.S1),
+		S2:        scalarToHex(proof.S2),
+	})
+}
+
+// UnmarshalJSON decodes an OpenProof encoded with MarshalJSON. It fails if a
+// point is not on the curve or a scalar is not in [0, N)
+func (proof *OpenProof) UnmarshalJSON(b []byte) error {
+	var aux openProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := OpenProof{}
+	if p.T, err = pointFromHex(aux.T, "t"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.S1, err = scalarFromHex(aux.S1, "s1"); err != nil {
+		return err
+	}
+	if p.S2, err = scalarFromHex(aux.S2, "s2"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
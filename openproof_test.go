@@ -0,0 +1,108 @@
+package zksigma
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestOpenProof(t *testing.T) {
+	v, _ := rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic range"
+	CM, r, err := PedCommit(TestCurve, v)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	proof, err := NewOpenProof(TestCurve, CM, v, r)
+	if err != nil {
+		t.Fatalf("OpenProof failed to generate: %v\n", err)
+	}
+	ok, err := proof.Verify(TestCurve, CM)
+	if !ok || err != nil {
+		t.Fatalf("OpenProof failed to verify: %v\n", err)
+	}
+
+	if ok, _ := proof.Verify(TestCurve, TestCurve.Add(CM, TestCurve.G)); ok {
+		t.Fatalf("OpenProof verified for a different commitment\n")
+	}
+
+	tampered := *proof
+	tampered.S1 = new(big.Int).Add(proof.S1, big.NewInt(1))
+	if ok, err := tampered.Verify(TestCurve, CM); ok || err == nil ||
+		!strings.Contains(err.Error(), "s1G + s2H - cCM != T (") {
+		t.Fatalf("OpenProof with a wrong response should fail the equation check: %v\n", err)
+	}
+
+	encoded := proof.Bytes()
+	if len(encoded) != openProofLen || !bytes.Equal(encoded, proof.Bytes()) {
+		t.Fatalf("OpenProof produced a non-deterministic or wrongly sized encoding\n")
+	}
+	proof, err = NewOpenProofFromBytes(encoded)
+	if err != nil {
+		t.Fatalf("OpenProof failed to deserialize: %v\n", err)
+	}
+	ok, err = proof.Verify(TestCurve, CM)
+	if !ok || err != nil {
+		t.Fatalf("OpenProof failed to verify after deserialization: %v\n", err)
+	}
+}
+
+func TestOpenProofWrongRandomness(t *testing.T) {
+	v := big.NewInt(1000)
+	CM, r, err := PedCommit(TestCurve, v)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	wrongR := new(big.Int).Add(r, big.NewInt(1))
+
+	if _, err := NewOpenProof(TestCurve, CM, v, wrongR); err == nil {
+		t.Fatalf("OpenProof generated with the wrong randomness\n")
+	}
+
+	// A proof for the commitment that the wrong randomness does open is no
+	// good for CM
+	proof, err := NewOpenProof(TestCurve, PedCommitR(TestCurve, v, wrongR), v, wrongR)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, _ := proof.Verify(TestCurve, CM); ok {
+		t.Fatalf("OpenProof verified with the wrong randomness\n")
+	}
+}
+
+func TestOpenProofIdentity(t *testing.T) {
+	if _, err := NewOpenProof(TestCurve, Zero, big.NewInt(0), big.NewInt(0)); err == nil {
+		t.Fatalf("OpenProof generated for the point at infinity\n")
+	}
+
+	v := big.NewInt(5)
+	CM, r, _ := PedCommit(TestCurve, v)
+	proof, err := NewOpenProof(TestCurve, CM, v, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, Zero); ok || err == nil {
+		t.Fatalf("OpenProof verified for the point at infinity\n")
+	}
+}
+
+func BenchmarkOpenProve(b *testing.B) {
+	v := big.NewInt(1000)
+	CM, r, _ := PedCommit(TestCurve, v)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewOpenProof(TestCurve, CM, v, r)
+	}
+}
+
+func BenchmarkOpenVerify(b *testing.B) {
+	v := big.NewInt(1000)
+	CM, r, _ := PedCommit(TestCurve, v)
+	proof, _ := NewOpenProof(TestCurve, CM, v, r)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CM)
+	}
+}
@@ -0,0 +1,231 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// OpenToValueProof is a proof that a commitment CM opens to a value v known to
+// the verifier, without revealing the randomness r of CM. It is a Schnorr proof
+// of knowledge of r with CM - vG = rH.
+//
+//  Public: generator points G and H, CM, v
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows r with CM = vG + rH           D = CM - vG
+//  selects random u
+//  T = uH
+//  c = HASH(G, H, CM, v, T)
+//  s = u + c * r
+//
+//  T, c, s --------------------------->
+//                                      c ?= HASH(G, H, CM, v, T)
+//                                      sH ?= T + cD
+type OpenToValueProof struct {
+	T         ECPoint  // T = uH
+	Challenge *big.Int // c = HASH(G, H, CM, v, T)
+	S         *big.Int // s = u + c * r
+}
+
+// openToValueChallenge computes c = HASH(G, H, CM, v, T)
+func openToValueChallenge(zkpcp ZKPCurveParams, CM ECPoint, claimedValue *big.Int, T ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "OpenToValueProof")
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendPoint("CM", CM)
+	t.AppendScalar("v", claimedValue)
+	t.AppendPoint("T", T)
+	return t.ChallengeScalar("c")
+}
+
+// NewOpenToValueProof generates a proof that CM = claimedValue * G + r * H. It
+// fails if claimedValue and r do not open CM.
+func NewOpenToValueProof(zkpcp ZKPCurveParams, CM ECPoint, claimedValue, r *big.Int) (*OpenToValueProof, error) {
+	return NewOpenToValueProofWithRand(zkpcp, entropy(), CM, claimedValue, r)
+}
+
+// NewOpenToValueProofWithRand is the same as NewOpenToValueProof, but reads
+// its random value from rnd instead of the EntropySource
+func NewOpenToValueProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	CM ECPoint, claimedValue, r *big.Int) (*OpenToValueProof, error) {
+
+	N := zkpcp.C.Params().N
+
+	if err := zkpcp.checkScalars("OpenToValueProve", "claimedValue, r", claimedValue, r); err != nil {
+		return nil, err
+	}
+	if !zkpcp.validPoints(CM) {
+		return nil, curveError("OpenToValueProve")
+	}
+	if !CM.Equal(PedCommitR(zkpcp, claimedValue, r)) {
+		return nil, inputError("OpenToValueProve", "claimedValue and r do not produce CM")
+	}
+
+	u, err := zkpcp.randScalar(rnd)
+	if err != nil {
+		return nil, err
+	}
+
+	T := zkpcp.Mult(zkpcp.H, u)
+	c := openToValueChallenge(zkpcp, CM, claimedValue, T)
+
+	s := new(big.Int).Add(u, new(big.Int).Mul(c, r))
+	s.Mod(s, N)
+
+	return &OpenToValueProof{T, c, s}, nil
+}
+
+// Verify checks if OpenToValueProof proof shows that CM opens to claimedValue,
+// which has to be in [0, N)
+func (proof *OpenToValueProof) Verify(zkpcp ZKPCurveParams, CM ECPoint, claimedValue *big.Int) (bool, error) {
+	if proof == nil {
+		return false, malformedError("OpenToValueProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if err := zkpcp.checkScalars("OpenToValueProof.Verify", "claimedValue", claimedValue); err != nil {
+		return false, err
+	}
+	if !zkpcp.validPoints(CM) || !zkpcp.onCurve(proof.T) {
+		return false, curveError("OpenToValueProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("OpenToValueProof.Verify", "Challenge, S", proof.Challenge, proof.S); err != nil {
+		return false, err
+	}
+
+	c := openToValueChallenge(zkpcp, CM, claimedValue, proof.T)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("OpenToValueProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	// sH ?= T + cD with D = CM - vG, checked as sH - cCM + cvG ?= T
+	cv := new(big.Int).Mul(c, claimedValue)
+	lhs := zkpcp.MultiMult([]ECPoint{zkpcp.H, CM, zkpcp.G}, []*big.Int{proof.S, new(big.Int).Neg(c), cv})
+	if !pointsEqual(lhs, proof.T) {
+		return false, equationError("OpenToValueProof.Verify", 1, "sH - cD != T", lhs, proof.T)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are CM and claimedValue as *big.Int
+// like for Verify
+func (proof *OpenToValueProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("OpenToValueProof.VerifyArgs", args)
+	CM, claimedValue := a.point(), a.scalar()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CM, claimedValue)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *OpenToValueProof) String() string {
+	if proof == nil {
+		return "OpenToValueProof(nil)"
+	}
+	return proofString("OpenToValueProof", "T", proof.T, "Challenge", proof.Challenge, "S", proof.S)
+}
+
+// openToValueProofLen is the length of a serialized OpenToValueProof
+var openToValueProofLen = 1 + fixedPointLen + 2*fixedScalarLen
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// OpenToValueProof proof
+func (proof *OpenToValueProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.T)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.S)
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of OpenToValueProof proof, which has a fixed size
+func (proof *OpenToValueProof) Size() int {
+	return openToValueProofLen
+}
+
+// Clone returns a deep copy of OpenToValueProof proof that shares no big.Int with it
+func (proof *OpenToValueProof) Clone() *OpenToValueProof {
+	if proof == nil {
+		return nil
+	}
+	return &OpenToValueProof{
+		T:         clonePoint(proof.T),
+		Challenge: cloneScalar(proof.Challenge),
+		S:         cloneScalar(proof.S),
+	}
+}
+
+// Equal returns true if OpenToValueProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *OpenToValueProof) Equal(other *OpenToValueProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.T, other.T) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.S, other.S)
+}
+
+// NewOpenToValueProofFromBytes returns an OpenToValueProof generated from the
+// deserialization of byte slice b
+func NewOpenToValueProofFromBytes(b []byte) (*OpenToValueProof, error) {
+	if err := checkFixedHeader(b, openToValueProofLen, "OpenToValueProofFromBytes"); err != nil {
+		return nil, err
+	}
+	proof := new(OpenToValueProof)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.T, err = readFixedPoint(buf, "OpenToValueProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "OpenToValueProofFromBytes", &proof.Challenge, &proof.S); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+type openToValueProofJSON struct {
+	T         string `json:"t"`
+	Challenge string `json:"challenge"`
+	S         string `json:"s"`
+}
+
+// MarshalJSON encodes OpenToValueProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *OpenToValueProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(openToValueProofJSON{
+		T:         pointToHex(proof.T),
+		Challenge: scalarToHex(proof.Challenge),
+		S:         scalarToHex(proof.S),
+	})
+}
+
+// UnmarshalJSON decodes an OpenToValueProof encoded with MarshalJSON. It fails
+// if a point is not on the curve or a scalar is not in [0, N)
+func (proof *OpenToValueProof) UnmarshalJSON(b []byte) error {
+	var aux openToValueProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := OpenToValueProof{}
+	if p.T, err = pointFromHex(aux.T, "t"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.S, err = scalarFromHex(aux.S, "s"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
@@ -0,0 +1,85 @@
+package zksigma
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestOpenToValueProof(t *testing.T) {
+	for _, value := range []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(1000),
+		new(big.Int).Sub(TestCurve.C.Params().N, big.NewInt(1))} {
+		CM, r, err := PedCommit(TestCurve, value)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+
+		proof, err := NewOpenToValueProof(TestCurve, CM, value, r)
+		if err != nil {
+			t.Fatalf("OpenToValueProof failed to generate for %v: %v\n", value, err)
+		}
+		if ok, err := proof.Verify(TestCurve, CM, value); !ok || err != nil {
+			t.Fatalf("OpenToValueProof failed to verify for %v: %v\n", value, err)
+		}
+
+		// off by one in either direction
+		for _, delta := range []int64{1, -1} {
+			other := new(big.Int).Add(value, big.NewInt(delta))
+			other.Mod(other, TestCurve.C.Params().N)
+			if ok, err := proof.Verify(TestCurve, CM, other); ok || err == nil {
+				t.Fatalf("OpenToValueProof for %v verified for %v\n", value, other)
+			}
+			if _, err := NewOpenToValueProof(TestCurve, CM, other, r); !errors.Is(err, ErrInvalidInput) {
+				t.Fatalf("OpenToValueProof generated for %v instead of %v: %v\n", other, value, err)
+			}
+		}
+	}
+}
+
+func TestOpenToValueProofOutOfRange(t *testing.T) {
+	N := TestCurve.C.Params().N
+	value := big.NewInt(5)
+	CM, r, _ := PedCommit(TestCurve, value)
+	proof, err := NewOpenToValueProof(TestCurve, CM, value, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	// N + 5 is 5 mod N, but claims a value CM does not open to
+	for _, v := range []*big.Int{N, new(big.Int).Add(N, value), big.NewInt(-1), nil} {
+		if _, err := NewOpenToValueProof(TestCurve, CM, v, r); !errors.Is(err, ErrValueOutOfRange) {
+			t.Fatalf("OpenToValueProof for %v gives %v, expected ErrValueOutOfRange\n", v, err)
+		}
+		if ok, err := proof.Verify(TestCurve, CM, v); ok || !errors.Is(err, ErrValueOutOfRange) {
+			t.Fatalf("OpenToValueProof verified for %v: %v\n", v, err)
+		}
+	}
+
+	tampered := *proof
+	tampered.S = new(big.Int).Add(proof.S, big.NewInt(1))
+	if ok, err := tampered.Verify(TestCurve, CM, value); ok || !errors.Is(err, ErrEquationFailed) {
+		t.Fatalf("OpenToValueProof with a tampered response gives %v\n", err)
+	}
+
+	var nilProof *OpenToValueProof
+	if ok, err := nilProof.Verify(TestCurve, CM, value); ok || err == nil {
+		t.Fatalf("nil OpenToValueProof verified\n")
+	}
+}
+
+func BenchmarkOpenToValueProve(b *testing.B) {
+	CM, r, _ := PedCommit(TestCurve, big.NewInt(5))
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewOpenToValueProof(TestCurve, CM, big.NewInt(5), r)
+	}
+}
+
+func BenchmarkOpenToValueVerify(b *testing.B) {
+	CM, r, _ := PedCommit(TestCurve, big.NewInt(5))
+	proof, _ := NewOpenToValueProof(TestCurve, CM, big.NewInt(5), r)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CM, big.NewInt(5))
+	}
+}
@@ -0,0 +1,333 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// ProductProof is a proof that three commitments A = aG + raH, B = bG + rbH
+// and C = cG + rcH hide values with c = a * b. With t = rc - a * rb we have
+// C = aB + tH, so the prover shows that it knows the openings of A and B and
+// that C is a multiple of B by the same a that A commits to.
+//
+//  Public: generator points G and H, A, B, C
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows a, ra, b, rb, rc
+//  t = rc - a * rb
+//  selects random x1, x2, y1, y2, z
+//  T1 = x1G + x2H
+//  T2 = y1G + y2H
+//  T3 = x1B + zH
+//  c = HASH(G, H, A, B, C, T1, T2, T3)
+//  s1 = x1 + c * a
+//  s2 = x2 + c * ra
+//  s3 = y1 + c * b
+//  s4 = y2 + c * rb
+//  s5 = z + c * t
+//
+//  T1, T2, T3, c, s1..s5 ------------->
+//                                      c ?= HASH(G, H, A, B, C, T1, T2, T3)
+//                                      s1G + s2H ?= T1 + cA
+//                                      s3G + s4H ?= T2 + cB
+//                                      s1B + s5H ?= T3 + cC
+type ProductProof struct {
+	T1        ECPoint  // T1 = x1G + x2H
+	T2        ECPoint  // T2 = y1G + y2H
+	T3        ECPoint  // T3 = x1B + zH
+	Challenge *big.Int // c = HASH(G, H, A, B, C, T1, T2, T3)
+	S1        *big.Int // s1 = x1 + c * a
+	S2        *big.Int // s2 = x2 + c * ra
+	S3        *big.Int // s3 = y1 + c * b
+	S4        *big.Int // s4 = y2 + c * rb
+	S5        *big.Int // s5 = z + c * t
+}
+
+// productChallenge computes c = HASH(G, H, A, B, C, T1, T2, T3)
+func productChallenge(zkpcp ZKPCurveParams, A, B, C, T1, T2, T3 ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "ProductProof")
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendPoint("A", A)
+	t.AppendPoint("B", B)
+	t.AppendPoint("C", C)
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	t.AppendPoint("T3", T3)
+	return t.ChallengeScalar("c")
+}
+
+// NewProductProof commits to a, b and a * b with randomness ra, rb and rc and
+// generates a proof that the third commitment hides the product of the first
+// two. It returns the proof and the commitments A, B and C.
+func NewProductProof(zkpcp ZKPCurveParams, a, b, ra, rb, rc *big.Int) (*ProductProof, ECPoint, ECPoint, ECPoint, error) {
+	return NewProductProofWithRand(zkpcp, entropy(), a, b, ra, rb, rc)
+}
+
+// NewProductProofWithRand is the same as NewProductProof, but reads its random
+// values from rnd instead of the EntropySource
+func NewProductProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader, a, b, ra, rb, rc *big.Int) (*ProductProof, ECPoint, ECPoint, ECPoint, error) {
+	N := zkpcp.C.Params().N
+
+	if err := zkpcp.checkScalars("ProductProve", "a, b, ra, rb, rc", a, b, ra, rb, rc); err != nil {
+		return nil, Zero, Zero, Zero, err
+	}
+
+	A := PedCommitR(zkpcp, a, ra)
+	B := PedCommitR(zkpcp, b, rb)
+	C := PedCommitR(zkpcp, new(big.Int).Mul(a, b), rc)
+
+	// t = rc - a * rb
+	t := new(big.Int).Sub(rc, new(big.Int).Mul(a, rb))
+	t.Mod(t, N)
+
+	us, err := zkpcp.randScalars(rnd, 5)
+	if err != nil {
+		return nil, Zero, Zero, Zero, err
+	}
+	x1, x2, y1, y2, z := us[0], us[1], us[2], us[3], us[4]
+
+	T1 := PedCommitR(zkpcp, x1, x2)
+	T2 := PedCommitR(zkpcp, y1, y2)
+	T3 := zkpcp.Add(zkpcp.Mult(B, x1), zkpcp.Mult(zkpcp.H, z))
+
+	c := productChallenge(zkpcp, A, B, C, T1, T2, T3)
+
+	// s = u + c * x
+	response := func(u, x *big.Int) *big.Int {
+		s := new(big.Int).Add(u, new(big.Int).Mul(c, x))
+		return s.Mod(s, N)
+	}
+
+	return &ProductProof{
+		T1:        T1,
+		T2:        T2,
+		T3:        T3,
+		Challenge: c,
+		S1:        response(x1, a),
+		S2:        response(x2, ra),
+		S3:        response(y1, b),
+		S4:        response(y2, rb),
+		S5:        response(z, t),
+	}, A, B, C, nil
+}
+
+// Verify checks if ProductProof proof shows that C commits to the product of
+// the values in A and B
+func (proof *ProductProof) Verify(zkpcp ZKPCurveParams, A, B, C ECPoint) (bool, error) {
+	if proof == nil {
+		return false, malformedError("ProductProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(A, B, C) || !zkpcp.onCurve(proof.T1, proof.T2, proof.T3) {
+		return false, curveError("ProductProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("ProductProof.Verify", "Challenge, S1, S2, S3, S4, S5", proof.Challenge, proof.S1, proof.S2, proof.S3, proof.S4, proof.S5); err != nil {
+		return false, err
+	}
+
+	c := productChallenge(zkpcp, A, B, C, proof.T1, proof.T2, proof.T3)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("ProductProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	negC := new(big.Int).Neg(c)
+
+	// s1G + s2H ?= T1 + cA, checked as s1G + s2H - cA ?= T1
+	lhs := zkpcp.MultiMult([]ECPoint{zkpcp.G, zkpcp.H, A}, []*big.Int{proof.S1, proof.S2, negC})
+	if !pointsEqual(lhs, proof.T1) {
+		return false, equationError("ProductProof.Verify", 1, "s1G + s2H - cA != T1", lhs, proof.T1)
+	}
+
+	// s3G + s4H ?= T2 + cB, checked as s3G + s4H - cB ?= T2
+	lhs = zkpcp.MultiMult([]ECPoint{zkpcp.G, zkpcp.H, B}, []*big.Int{proof.S3, proof.S4, negC})
+	if !pointsEqual(lhs, proof.T2) {
+		return false, equationError("ProductProof.Verify", 2, "s3G + s4H - cB != T2", lhs, proof.T2)
+	}
+
+	// s1B + s5H ?= T3 + cC, checked as s1B + s5H - cC ?= T3
+	lhs = zkpcp.MultiMult([]ECPoint{B, zkpcp.H, C}, []*big.Int{proof.S1, proof.S5, negC})
+	if !pointsEqual(lhs, proof.T3) {
+		return false, equationError("ProductProof.Verify", 3, "s1B + s5H - cC != T3", lhs, proof.T3)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are A, B and C like for Verify
+func (proof *ProductProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("ProductProof.VerifyArgs", args)
+	A, B, C := a.point(), a.point(), a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, A, B, C)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *ProductProof) String() string {
+	if proof == nil {
+		return "ProductProof(nil)"
+	}
+	return proofString("ProductProof", "T1", proof.T1, "T2", proof.T2, "T3", proof.T3,
+		"Challenge", proof.Challenge, "S1", proof.S1, "S2", proof.S2, "S3", proof.S3,
+		"S4", proof.S4, "S5", proof.S5)
+}
+
+// productProofLen is the length of a serialized ProductProof
+var productProofLen = 1 + 3*fixedPointLen + 6*fixedScalarLen
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// ProductProof proof
+func (proof *ProductProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.T1)
+	writeFixedPoint(&buf, proof.T2)
+	writeFixedPoint(&buf, proof.T3)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.S1)
+	writeFixedScalar(&buf, proof.S2)
+	writeFixedScalar(&buf, proof.S3)
+	writeFixedScalar(&buf, proof.S4)
+	writeFixedScalar(&buf, proof.S5)
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of ProductProof proof, which has a fixed size
+func (proof *ProductProof) Size() int {
+	return productProofLen
+}
+
+// Clone returns a deep copy of ProductProof proof that shares no big.Int with it
+func (proof *ProductProof) Clone() *ProductProof {
+	if proof == nil {
+		return nil
+	}
+	return &ProductProof{
+		T1:        clonePoint(proof.T1),
+		T2:        clonePoint(proof.T2),
+		T3:        clonePoint(proof.T3),
+		Challenge: cloneScalar(proof.Challenge),
+		S1:        cloneScalar(proof.S1),
+		S2:        cloneScalar(proof.S2),
+		S3:        cloneScalar(proof.S3),
+		S4:        cloneScalar(proof.S4),
+		S5:        cloneScalar(proof.S5),
+	}
+}
+
+// Equal returns true if ProductProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *ProductProof) Equal(other *ProductProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.T1, other.T1) &&
+		samePoint(proof.T2, other.T2) &&
+		samePoint(proof.T3, other.T3) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.S1, other.S1) &&
+		sameScalar(proof.S2, other.S2) &&
+		sameScalar(proof.S3, other.S3) &&
+		sameScalar(proof.S4, other.S4) &&
+		sameScalar(proof.S5, other.S5)
+}
+
+// NewProductProofFromBytes returns a ProductProof generated from the
+// deserialization of byte slice b
+func NewProductProofFromBytes(b []byte) (*ProductProof, error) {
+	if err := checkFixedHeader(b, productProofLen, "ProductProofFromBytes"); err != nil {
+		return nil, err
+	}
+	proof := new(ProductProof)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.T1, err = readFixedPoint(buf, "ProductProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if proof.T2, err = readFixedPoint(buf, "ProductProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if proof.T3, err = readFixedPoint(buf, "ProductProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "ProductProofFromBytes", &proof.Challenge, &proof.S1, &proof.S2, &proof.S3, &proof.S4, &proof.S5); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+type productProofJSON struct {
+	T1        string `json:"t1"`
+	T2        string `json:"t2"`
+	T3        string `json:"t3"`
+	Challenge string `json:"challenge"`
+	S1        string `json:"s1"`
+	S2        string `json:"s2"`
+	S3        string `json:"s3"`
+	S4        string `json:"s4"`
+	S5        string `json:"s5"`
+}
+
+// MarshalJSON encodes ProductProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *ProductProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(productProofJSON{
+		T1:        pointToHex(proof.T1),
+		T2:        pointToHex(proof.T2),
+		T3:        pointToHex(proof.T3),
+		Challenge: scalarToHex(proof.Challenge),
+		S1:        scalarToHex(proof.S1),
+		S2:        scalarToHex(proof.S2),
+		S3:        scalarToHex(proof.S3),
+		S4:        scalarToHex(proof.S4),
+		S5:        scalarToHex(proof.S5),
+	})
+}
+
+// UnmarshalJSON decodes a ProductProof encoded with MarshalJSON. It fails if a
+// point is not on the curve or a scalar is not in [0, N)
+func (proof *ProductProof) UnmarshalJSON(b []byte) error {
+	var aux productProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := ProductProof{}
+	if p.T1, err = pointFromHex(aux.T1, "t1"); err != nil {
+		return err
+	}
+	if p.T2, err = pointFromHex(aux.T2, "t2"); err != nil {
+		return err
+	}
+	if p.T3, err = pointFromHex(aux.T3, "t3"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.S1, err = scalarFromHex(aux.S1, "s1"); err != nil {
+		return err
+	}
+	if p.S2, err = scalarFromHex(aux.S2, "s2"); err != nil {
+		return err
+	}
+	if p.S3, err = scalarFromHex(aux.S3, "s3"); err != nil {
+		return err
+	}
+	if p.S4, err = scalarFromHex(aux.S4, "s4"); err != nil {
+		return err
+	}
+	if p.S5, err = scalarFromHex(aux.S5, "s5"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
@@ -0,0 +1,106 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestProductProof(t *testing.T) {
+	N := TestCurve.C.Params().N
+	a, _ := rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic range"
+	b, _ := rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic range"
+
+	for _, values := range [][2]*big.Int{
+		{a, b},
+		{big.NewInt(0), b}, // a is zero
+		{a, big.NewInt(0)}, // b is zero
+		{new(big.Int).Sub(N, big.NewInt(1)), new(big.Int).Sub(N, big.NewInt(1))},
+	} {
+		ra, _ := rand.Int(rand.Reader, N)
+		rb, _ := rand.Int(rand.Reader, N)
+		rc, _ := rand.Int(rand.Reader, N)
+
+		proof, A, B, C, err := NewProductProof(TestCurve, values[0], values[1], ra, rb, rc)
+		if err != nil {
+			t.Fatalf("ProductProof failed to generate for %v * %v: %v\n", values[0], values[1], err)
+		}
+
+		if !Open(TestCurve, new(big.Int).Mul(values[0], values[1]), rc, C) {
+			t.Fatalf("ProductProof returned a C that does not commit to a * b\n")
+		}
+
+		check, err := proof.Verify(TestCurve, A, B, C)
+		if !check || err != nil {
+			t.Fatalf("ProductProof failed to verify for %v * %v: %v\n", values[0], values[1], err)
+		}
+
+		proof, err = NewProductProofFromBytes(proof.Bytes())
+		if err != nil {
+			t.Fatalf("ProductProof failed to deserialize: %v\n", err)
+		}
+		check, err = proof.Verify(TestCurve, A, B, C)
+		if !check || err != nil {
+			t.Fatalf("ProductProof failed to verify after deserialization: %v\n", err)
+		}
+	}
+}
+
+func TestProductProofWrongProduct(t *testing.T) {
+	N := TestCurve.C.Params().N
+	a, b := big.NewInt(6), big.NewInt(7)
+	ra, _ := rand.Int(rand.Reader, N)
+	rb, _ := rand.Int(rand.Reader, N)
+	rc, _ := rand.Int(rand.Reader, N)
+
+	proof, A, B, _, err := NewProductProof(TestCurve, a, b, ra, rb, rc)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	// C commits to 43 != 6 * 7 with the same randomness
+	wrongC := PedCommitR(TestCurve, big.NewInt(43), rc)
+	if check, err := proof.Verify(TestCurve, A, B, wrongC); check || err == nil {
+		t.Fatalf("ProductProof verified for c != a * b\n")
+	}
+
+	// Swapping A and B changes the statement
+	_, _, _, C, _ := NewProductProof(TestCurve, a, b, ra, rb, rc)
+	if check, err := proof.Verify(TestCurve, B, A, C); check || err == nil {
+		t.Fatalf("ProductProof verified for swapped commitments\n")
+	}
+
+	// Lying about c in the responses does not help either
+	cheat := *proof
+	cheat.S5 = new(big.Int).Add(proof.S5, big.NewInt(1))
+	if check, err := cheat.Verify(TestCurve, A, B, C); check || err == nil {
+		t.Fatalf("ProductProof verified with a tampered response\n")
+	}
+}
+
+func BenchmarkProductProve(b *testing.B) {
+	x, _ := rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic range"
+	y, _ := rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic range"
+	ra, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	rb, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	rc, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewProductProof(TestCurve, x, y, ra, rb, rc)
+	}
+}
+
+func BenchmarkProductVerify(b *testing.B) {
+	x, _ := rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic range"
+	y, _ := rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic range"
+	ra, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	rb, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	rc, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	proof, A, B, C, _ := NewProductProof(TestCurve, x, y, ra, rb, rc)
+
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, A, B, C)
+	}
+}
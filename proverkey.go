@@ -0,0 +1,110 @@
+package zksigma
+
+import (
+	"io"
+	"math/big"
+	"sync"
+)
+
+// ProverKey is the auditor secret key sk of many ABC and consistency proofs,
+// e.g. those of a wallet, with what the proofs compute from it done once:
+// inv(sk), PK = skH and on secp256k1 a fixed base table of PK, so the three to
+// four multiplications of PK in every proof are as cheap as those of G or H.
+// Building the table takes about as long as a few proofs, so a ProverKey pays
+// off once it is reused. The proofs are the same as those of NewABCProof and
+// NewConsistencyProof for sk and PK.
+//
+// A ProverKey can be used from several goroutines at once. Zeroize overwrites
+// sk and inv(sk) once it is no longer needed, after that it fails to prove.
+type ProverKey struct {
+	zkpcp ZKPCurveParams // with the table of PK
+
+	mu     sync.RWMutex
+	key    abcKey
+	erased bool
+}
+
+// NewProverKey returns the ProverKey of auditor secret key sk, which must not
+// be zero
+func NewProverKey(zkpcp ZKPCurveParams, sk *big.Int) (*ProverKey, error) {
+	if err := zkpcp.checkScalars("NewProverKey", "sk", sk); err != nil {
+		return nil, err
+	}
+	if sk.Sign() == 0 {
+		return nil, rangeError("NewProverKey", "sk is zero")
+	}
+	key := newABCKey(zkpcp, new(big.Int).Set(sk), new(big.Int).ModInverse(sk, zkpcp.C.Params().N))
+	return &ProverKey{zkpcp: zkpcp.withTables(key.PK), key: key}, nil
+}
+
+// PublicKey returns PK = skH of ProverKey pk
+func (pk *ProverKey) PublicKey() ECPoint {
+	return clonePoint(pk.key.PK)
+}
+
+// NewABCProof is the same as NewABCProof(zkpcp, CM, CMTok, value, sk, option)
+// for the zkpcp and sk of ProverKey pk
+func (pk *ProverKey) NewABCProof(CM, CMTok ECPoint, value *big.Int, option Side) (*ABCProof, error) {
+	return pk.NewABCProofWithRand(entropy(), CM, CMTok, value, option)
+}
+
+// NewABCProofWithRand is the same as NewABCProof, but reads its random values
+// from rnd instead of the EntropySource
+func (pk *ProverKey) NewABCProofWithRand(rnd io.Reader, CM, CMTok ECPoint, value *big.Int, option Side) (*ABCProof, error) {
+	pk.mu.RLock()
+	defer pk.mu.RUnlock()
+	if pk.erased {
+		return nil, inputError("ProverKey.NewABCProof", "key is zeroized")
+	}
+	if err := pk.zkpcp.checkScalars("ABCProof", "value", value); err != nil {
+		return nil, err
+	}
+	if !pk.zkpcp.validPoints(CM, CMTok) {
+		return nil, curveError("ABCProof")
+	}
+
+	var inv *big.Int
+	if option == Right && value.Sign() != 0 {
+		inv = new(big.Int).ModInverse(value, pk.zkpcp.C.Params().N)
+	}
+	prover, err := abcCommitKey(pk.zkpcp, rnd, CM, CMTok, value, inv, pk.key, option)
+	if err != nil {
+		return nil, err
+	}
+	return prover.prove(pk.zkpcp, CM, CMTok, nil), nil
+}
+
+// NewConsistencyProof is the same as NewConsistencyProof(zkpcp, CM, CMTok, PK,
+// value, randomness) for the zkpcp and PK of ProverKey pk
+func (pk *ProverKey) NewConsistencyProof(CM, CMTok ECPoint, value, randomness *big.Int) (*ConsistencyProof, error) {
+	return pk.NewConsistencyProofWithRand(entropy(), CM, CMTok, value, randomness)
+}
+
+// NewConsistencyProofWithRand is the same as NewConsistencyProof, but reads
+// its random values from rnd instead of the EntropySource
+func (pk *ProverKey) NewConsistencyProofWithRand(rnd io.Reader, CM, CMTok ECPoint, value, randomness *big.Int) (*ConsistencyProof, error) {
+	pk.mu.RLock()
+	defer pk.mu.RUnlock()
+	if pk.erased {
+		return nil, inputError("ProverKey.NewConsistencyProof", "key is zeroized")
+	}
+	return newConsistencyProof(pk.zkpcp, rnd, CM, CMTok, pk.key.PK, value, randomness, nil)
+}
+
+// Zeroize overwrites sk and inv(sk) of ProverKey pk. It waits for the proofs
+// in progress, later ones fail with ErrInvalidInput.
+func (pk *ProverKey) Zeroize() {
+	pk.mu.Lock()
+	defer pk.mu.Unlock()
+	zeroizeInt(pk.key.sk)
+	zeroizeInt(pk.key.isk)
+	pk.erased = true
+}
+
+// String returns ProverKey pk with its PK but without sk
+func (pk *ProverKey) String() string {
+	return "ProverKey{PK: " + pk.key.PK.String() + ", sk: <redacted>}"
+}
+
+// GoString is String, so %#v does not show sk either
+func (pk *ProverKey) GoString() string { return pk.String() }
@@ -0,0 +1,217 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// proverKeyStatement returns an auditor key with a commitment to value and
+// its token
+func proverKeyStatement(t testing.TB, value *big.Int) (sk *big.Int, PK, CM, CMTok ECPoint, r *big.Int) {
+	sk, _ = rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK = TestCurve.Mult(TestCurve.H, sk)
+	CM, r, err := PedCommit(TestCurve, value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	return sk, PK, CM, TestCurve.Mult(PK, r), r
+}
+
+func TestProverKey(t *testing.T) {
+	for _, c := range []struct {
+		value  *big.Int
+		option Side
+	}{{big.NewInt(0), Left}, {big.NewInt(42), Right}} {
+		sk, PK, CM, CMTok, r := proverKeyStatement(t, c.value)
+		key, err := NewProverKey(TestCurve, sk)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if !key.PublicKey().Equal(PK) {
+			t.Fatalf("ProverKey has PK %v, expected %v\n", key.PublicKey(), PK)
+		}
+
+		// the same random values give the same proofs as without the key
+		seed := fmt.Sprintf("prover key %v", c.option)
+		plain, err := NewABCProofWithRand(TestCurve, newSeededReader(seed), CM, CMTok, c.value, sk, c.option)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		keyed, err := key.NewABCProofWithRand(newSeededReader(seed), CM, CMTok, c.value, c.option)
+		if err != nil {
+			t.Fatalf("ABCProof of a ProverKey for side %v failed to generate: %v\n", c.option, err)
+		}
+		if !keyed.Equal(plain) {
+			t.Fatalf("ABCProof of a ProverKey for side %v differs from NewABCProof\n", c.option)
+		}
+		if ok, err := keyed.Verify(TestCurve, CM, CMTok, PK); !ok || err != nil {
+			t.Fatalf("ABCProof of a ProverKey for side %v failed to verify: %v\n", c.option, err)
+		}
+		proof, err := key.NewABCProof(CM, CMTok, c.value, c.option)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if ok, err := proof.Verify(TestCurve, CM, CMTok, PK); !ok || err != nil {
+			t.Fatalf("ABCProof of a ProverKey for side %v failed to verify: %v\n", c.option, err)
+		}
+
+		plainCon, err := NewConsistencyProofWithRand(TestCurve, newSeededReader(seed), CM, CMTok, PK, c.value, r)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		keyedCon, err := key.NewConsistencyProofWithRand(newSeededReader(seed), CM, CMTok, c.value, r)
+		if err != nil {
+			t.Fatalf("ConsistencyProof of a ProverKey failed to generate: %v\n", err)
+		}
+		if !keyedCon.Equal(plainCon) {
+			t.Fatalf("ConsistencyProof of a ProverKey differs from NewConsistencyProof\n")
+		}
+		con, err := key.NewConsistencyProof(CM, CMTok, c.value, r)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if ok, err := con.Verify(TestCurve, CM, CMTok, PK); !ok || err != nil {
+			t.Fatalf("ConsistencyProof of a ProverKey failed to verify: %v\n", err)
+		}
+	}
+}
+
+func TestProverKeyInputs(t *testing.T) {
+	value := big.NewInt(7)
+	sk, _, CM, CMTok, r := proverKeyStatement(t, value)
+	N := TestCurve.C.Params().N
+
+	for name, s := range map[string]*big.Int{"zero": big.NewInt(0), "N": N, "nil": nil} {
+		if _, err := NewProverKey(TestCurve, s); !errors.Is(err, ErrValueOutOfRange) {
+			t.Fatalf("NewProverKey of sk %s gives %v\n", name, err)
+		}
+	}
+
+	key, err := NewProverKey(TestCurve, sk)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if _, err := key.NewABCProof(CM, CMTok, value, Left); !errors.Is(err, ErrInvalidSide) {
+		t.Fatalf("ABCProof of a ProverKey for the wrong side gives %v\n", err)
+	}
+	if _, err := key.NewABCProof(CM, CMTok, N, Right); !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("ABCProof of a ProverKey for value N gives %v\n", err)
+	}
+	if _, err := key.NewConsistencyProof(CM, CMTok, value, new(big.Int).Add(r, big.NewInt(1))); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("ConsistencyProof of a ProverKey for the wrong randomness gives %v\n", err)
+	}
+
+	// the key has a copy of sk, which String does not show
+	sk.SetInt64(1)
+	if _, err := key.NewABCProof(CM, CMTok, value, Right); err != nil {
+		t.Fatalf("ABCProof of a ProverKey fails after sk changed: %v\n", err)
+	}
+	for _, s := range []string{key.String(), fmt.Sprintf("%#v", key), fmt.Sprintf("%v", key)} {
+		if !strings.Contains(s, "<redacted>") || strings.Contains(s, key.key.sk.Text(16)) {
+			t.Fatalf("ProverKey prints as %s\n", s)
+		}
+	}
+
+	key.Zeroize()
+	if key.key.sk.Sign() != 0 || key.key.isk.Sign() != 0 {
+		t.Fatalf("ProverKey keeps sk after Zeroize\n")
+	}
+	if _, err := key.NewABCProof(CM, CMTok, value, Right); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("ABCProof of a zeroized ProverKey gives %v\n", err)
+	}
+	if _, err := key.NewConsistencyProof(CM, CMTok, value, r); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("ConsistencyProof of a zeroized ProverKey gives %v\n", err)
+	}
+}
+
+func TestProverKeyConcurrent(t *testing.T) {
+	value := big.NewInt(1000)
+	sk, PK, CM, CMTok, r := proverKeyStatement(t, value)
+	key, err := NewProverKey(TestCurve, sk)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for ii := 0; ii < 8; ii++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for jj := 0; jj < 4; jj++ {
+				abc, err := key.NewABCProof(CM, CMTok, value, Right)
+				if err == nil {
+					_, err = abc.Verify(TestCurve, CM, CMTok, PK)
+				}
+				if err == nil {
+					var con *ConsistencyProof
+					if con, err = key.NewConsistencyProof(CM, CMTok, value, r); err == nil {
+						_, err = con.Verify(TestCurve, CM, CMTok, PK)
+					}
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	// Zeroize waits for the proofs in progress, every proof either finishes
+	// valid or fails for the zeroized key
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		key.Zeroize()
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if !errors.Is(err, ErrInvalidInput) || !strings.Contains(err.Error(), "zeroized") {
+			t.Fatalf("concurrent proof of a ProverKey failed: %v\n", err)
+		}
+	}
+}
+
+func BenchmarkABCProveProverKey(b *testing.B) {
+	value := big.NewInt(42)
+	sk, _, CM, CMTok, _ := proverKeyStatement(b, value)
+	key, _ := NewProverKey(TestCurve, sk)
+	b.Run("plain", func(b *testing.B) {
+		for ii := 0; ii < b.N; ii++ {
+			NewABCProof(TestCurve, CM, CMTok, value, sk, Right)
+		}
+	})
+	b.Run("key", func(b *testing.B) {
+		for ii := 0; ii < b.N; ii++ {
+			key.NewABCProof(CM, CMTok, value, Right)
+		}
+	})
+}
+
+func BenchmarkConsistencyProveProverKey(b *testing.B) {
+	value := big.NewInt(42)
+	sk, PK, CM, CMTok, r := proverKeyStatement(b, value)
+	key, _ := NewProverKey(TestCurve, sk)
+	b.Run("plain", func(b *testing.B) {
+		for ii := 0; ii < b.N; ii++ {
+			NewConsistencyProof(TestCurve, CM, CMTok, PK, value, r)
+		}
+	})
+	b.Run("key", func(b *testing.B) {
+		for ii := 0; ii < b.N; ii++ {
+			key.NewConsistencyProof(CM, CMTok, value, r)
+		}
+	})
+}
+
+func BenchmarkNewProverKey(b *testing.B) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	for ii := 0; ii < b.N; ii++ {
+		NewProverKey(TestCurve, sk)
+	}
+}
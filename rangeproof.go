@@ -2,11 +2,12 @@ package zksigma
 
 import (
 	"bytes"
-	"crypto/rand"
-	"crypto/sha256"
+	"context"
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/big"
-	"sync"
 
 	"github.com/mit-dci/zksigma/wire"
 )
@@ -39,20 +40,27 @@ type RangeProof struct {
 	ProofTuples    []rangeProofTuple
 }
 
+// maxRangeProofTuples is the number of HPoints of a ZKPCurveParams, so a
+// RangeProof has at most that many tuples, one for each bit
+const maxRangeProofTuples = 64
+
 type proverInternalData struct {
 	Rpoints  []ECPoint
 	Bpoints  []ECPoint
 	kScalars []*big.Int
 	vScalars []*big.Int
+	jScalars []*big.Int
 }
 
-// proofGenA takes in a waitgroup, index and bit
+// proofGenA takes in an index and bit
 // returns an Rpoint and Cpoint, and the k value bigint
-func proofGenA(zkpcp ZKPCurveParams,
-	wg *sync.WaitGroup, idx int, bit bool, s *proverInternalData) error {
+// it does nothing once ctx is done
+func proofGenA(ctx context.Context, zkpcp ZKPCurveParams,
+	idx int, bit bool, s *proverInternalData) error {
 
-	defer wg.Done()
-	var err error
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	//	R := s.Rpoints[idx]
 	//	B := s.Bpoints[idx]
@@ -60,78 +68,64 @@ func proofGenA(zkpcp ZKPCurveParams,
 	//	v := stuff.vScalars[index]
 
 	if !bit { // If bit is 0, just make a random R = k*H
-		s.kScalars[idx], err = rand.Int(rand.Reader, zkpcp.C.Params().N) // random k
-		if err != nil {
-			return err
-		}
+		// kScalars[idx] is a random k
 		s.Rpoints[idx] = zkpcp.Mult(zkpcp.H, s.kScalars[idx]) // R is k * H
 	} else { // if bit is 1, actually do stuff
 
-		// get a random ri
-		s.vScalars[idx], err = rand.Int(rand.Reader, zkpcp.C.Params().N)
-		if err != nil {
-			return err
-		}
+		// vScalars[idx] is a random ri
 		// get R as H*ri... what is KC..?
 		s.Rpoints[idx] = zkpcp.Mult(zkpcp.H, s.vScalars[idx])
 
 		// B is htothe[index] plus partial R
 		s.Bpoints[idx].X, s.Bpoints[idx].Y =
-			zkpcp.C.Add(zkpcp.HPoints[idx].X, zkpcp.HPoints[idx].Y,
+			zkpcp.ops().Add(zkpcp.HPoints[idx].X, zkpcp.HPoints[idx].Y,
 				s.Rpoints[idx].X, s.Rpoints[idx].Y)
 
-			// random k
-		s.kScalars[idx], err = rand.Int(rand.Reader, zkpcp.C.Params().N)
-		if err != nil {
-			return err
-		}
+		// kScalars[idx] is a random k
 
 		// make k*H for hashing
 		temp := zkpcp.Mult(zkpcp.H, s.kScalars[idx])
 
-		// Hash of temp point (why the whole thing..?
-		hash := sha256.Sum256(append(temp.X.Bytes(), temp.Y.Bytes()...))
-		ei := new(big.Int).SetBytes(hash[:])
-		ei.Mod(ei, zkpcp.C.Params().N)
+		// challenge of the ring of this bit
+		ei := rangeBitChallenge(zkpcp, idx, temp)
 		s.Rpoints[idx].X, s.Rpoints[idx].Y =
-			zkpcp.C.ScalarMult(s.Bpoints[idx].X, s.Bpoints[idx].Y, ei.Bytes())
+			zkpcp.ops().ScalarMult(s.Bpoints[idx].X, s.Bpoints[idx].Y, ei.Bytes())
 	}
 	//	fmt.Printf("loop %d\n", idx)
 
 	return nil
 }
 
-// proofGenB takes waitgroup, index, bit, along with the data to operate on
-func proofGenB(zkpcp ZKPCurveParams,
-	wg *sync.WaitGroup, idx int, bit bool, e0 *big.Int, data *proverInternalData) error {
+// proofGenB takes index, bit, along with the data to operate on
+// it does nothing once ctx is done
+func proofGenB(ctx context.Context, zkpcp ZKPCurveParams,
+	idx int, bit bool, e0 *big.Int, data *proverInternalData) error {
 
-	defer wg.Done()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	if !bit {
-		// choose a random value from the integers mod prime
-		j, err := rand.Int(rand.Reader, zkpcp.C.Params().N)
-		if err != nil {
-			return err
-		}
+		// a random value from the integers mod prime
+		j := data.jScalars[idx]
 
 		m2 := new(big.Int).Exp(big.NewInt(2), big.NewInt(int64(idx)), zkpcp.C.Params().N)
 		//		m2 := big.NewInt(1 << uint(idx))
 		em2 := new(big.Int).Mul(e0, m2)
 		em2.Mod(em2, zkpcp.C.Params().N)
 
-		rhsX, rhsY := zkpcp.C.ScalarBaseMult(em2.Bytes())
+		rhsX, rhsY := zkpcp.ops().ScalarBaseMult(em2.Bytes())
 
 		lhs := zkpcp.Mult(zkpcp.H, j)
 
-		totX, totY := zkpcp.C.Add(lhs.X, lhs.Y, rhsX, rhsY)
+		totX, totY := zkpcp.ops().Add(lhs.X, lhs.Y, rhsX, rhsY)
 
-		hash := sha256.Sum256(append(totX.Bytes(), totY.Bytes()...))
-		ei := new(big.Int).SetBytes(hash[:]) // get ei
-		ei.Mod(ei, zkpcp.C.Params().N)
+		ei := rangeBitChallenge(zkpcp, idx, ECPoint{totX, totY}) // get ei
 
 		inverseEI := new(big.Int).ModInverse(ei, zkpcp.C.Params().N)
 
 		data.vScalars[idx] = new(big.Int).Mul(inverseEI, data.kScalars[idx])
+		data.vScalars[idx].Mod(data.vScalars[idx], zkpcp.C.Params().N)
 
 		// set the C point for this index to R* inv ei
 		data.Bpoints[idx] = zkpcp.Mult(data.Rpoints[idx], inverseEI)
@@ -139,12 +133,14 @@ func proofGenB(zkpcp ZKPCurveParams,
 		// s = k + (kValues[i] * e0) * inverse ei
 		data.kScalars[idx] = j.Add(
 			j, new(big.Int).Mul(data.kScalars[idx], new(big.Int).Mul(e0, inverseEI)))
+		data.kScalars[idx].Mod(data.kScalars[idx], zkpcp.C.Params().N)
 
 	} else { // bit is 1, don't do anything
 		// s is k + e0*v
 
 		data.kScalars[idx] = new(big.Int).Add(
 			data.kScalars[idx], new(big.Int).Mul(e0, data.vScalars[idx]))
+		data.kScalars[idx].Mod(data.kScalars[idx], zkpcp.C.Params().N)
 	}
 
 	return nil
@@ -152,8 +148,60 @@ func proofGenB(zkpcp ZKPCurveParams,
 
 // NewRangeProof generates a range proof for the given value
 func NewRangeProof(zkpcp ZKPCurveParams, value *big.Int) (*RangeProof, *big.Int, error) {
+	return NewRangeProofWithRand(zkpcp, entropy(), value)
+}
+
+// NewRangeProofWithRand is the same as NewRangeProof, but reads its random
+// values from rnd instead of the EntropySource
+func NewRangeProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader, value *big.Int) (*RangeProof, *big.Int, error) {
+	return newRangeProof(context.Background(), zkpcp, rnd, value, 0)
+}
+
+// NewRangeProofWithWorkers is the same as NewRangeProofWithRand, but does the
+// work of the bits on a pool of workers goroutines, or GOMAXPROCS of them if
+// workers is 0. All random values are still read from rnd up front, so the
+// proof is the same for any number of workers.
+func NewRangeProofWithWorkers(zkpcp ZKPCurveParams, rnd io.Reader, value *big.Int, workers int) (*RangeProof, *big.Int, error) {
+	if workers < 0 {
+		return nil, nil, inputError("RangeProve", fmt.Sprintf("negative number of workers %d", workers))
+	}
+	return newRangeProof(context.Background(), zkpcp, rnd, value, workers)
+}
+
+// NewRangeProofBatchCtx generates a RangeProof for each of values like
+// NewRangeProof, and returns the proofs and their randomness. It stops once
+// ctx is done, between two proofs or between the bits of one, and then
+// returns the proofs and randomness for the values before it with an
+// ErrCanceled error wrapping ctx.Err(). The rest of the batch can be proved
+// later starting at index len(proofs).
+func NewRangeProofBatchCtx(ctx context.Context, zkpcp ZKPCurveParams, values []*big.Int) ([]*RangeProof, []*big.Int, error) {
+	proofs := make([]*RangeProof, 0, len(values))
+	rs := make([]*big.Int, 0, len(values))
+	for i, v := range values {
+		if err := ctx.Err(); err != nil {
+			return proofs, rs, canceledError("NewRangeProofBatch", i, len(values), err)
+		}
+		proof, r, err := newRangeProof(ctx, zkpcp, entropy(), v, 0)
+		if err != nil {
+			if err == ctx.Err() {
+				return proofs, rs, canceledError("NewRangeProofBatch", i, len(values), err)
+			}
+			return proofs, rs, wrapError("NewRangeProofBatch", fmt.Sprintf("proof %d failed: %v", i, err), err)
+		}
+		proofs, rs = append(proofs, proof), append(rs, r)
+	}
+	return proofs, rs, nil
+}
+
+// newRangeProof is NewRangeProofWithWorkers, it returns the error of ctx if
+// ctx is done before the proof is
+func newRangeProof(ctx context.Context, zkpcp ZKPCurveParams, rnd io.Reader, value *big.Int, workers int) (*RangeProof, *big.Int, error) {
 	proof := RangeProof{}
 
+	if err := zkpcp.checkScalars("RangeProve", "value", value); err != nil {
+		return nil, nil, err
+	}
+
 	// extend or truncate our value to 64 bits, which is the range we are proving
 	// If our value is in range, then sum of commitments would equal original commitment
 	// else, because of truncation, it will be deemed out of range not be equal
@@ -175,46 +223,52 @@ func NewRangeProof(zkpcp ZKPCurveParams, value *big.Int) (*RangeProof, *big.Int,
 	stuff.Rpoints = make([]ECPoint, proofSize)
 	stuff.Bpoints = make([]ECPoint, proofSize)
 	stuff.vScalars = make([]*big.Int, proofSize)
+	stuff.jScalars = make([]*big.Int, proofSize)
+
+	// proofGenA and proofGenB run concurrently, so draw all random values
+	// here to read them from rnd in a fixed order
+	us, err := zkpcp.randScalars(rnd, 3*proofSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := 0; i < proofSize; i++ {
+		stuff.kScalars[i], stuff.vScalars[i], stuff.jScalars[i] = us[3*i], us[3*i+1], us[3*i+2]
+	}
 
 	vTotal := big.NewInt(0)
 	proof.ProofTuples = make([]rangeProofTuple, proofSize)
 
 	//	 do the loop bValue times
-	var wg sync.WaitGroup
-	wg.Add(proofSize)
-	for i := 0; i < proofSize; i++ {
-		// TODO: Check errors
-		go proofGenA(zkpcp, &wg, i, value.Bit(i) == 1, stuff)
+	if _, err := runAll(proofSize, workers, func(i int) error {
+		return proofGenA(ctx, zkpcp, i, value.Bit(i) == 1, stuff)
+	}); err != nil {
+		return nil, nil, err
 	}
-	wg.Wait()
-
-	// hash concat of all R values
-	rHash := sha256.New()
-	for _, rvalue := range stuff.Rpoints {
-		rHash.Write(rvalue.X.Bytes())
-		rHash.Write(rvalue.Y.Bytes())
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
 	}
-	hashed := rHash.Sum(nil)
 
-	e0 := new(big.Int).SetBytes(hashed[:])
-	e0.Mod(e0, zkpcp.C.Params().N)
+	// hash of all R values
+	e0 := rangeChallenge(zkpcp, stuff.Rpoints)
 
 	var AggregatePoint ECPoint
 	AggregatePoint.X = new(big.Int)
 	AggregatePoint.Y = new(big.Int)
 
 	// go through all 64 part B
-	wg.Add(proofSize)
-	for i := 0; i < proofSize; i++ {
-		// TODO: Check errors
-		go proofGenB(zkpcp,
-			&wg, i, value.Bit(i) == 1, e0, stuff)
+	if _, err := runAll(proofSize, workers, func(i int) error {
+		return proofGenB(ctx, zkpcp, i, value.Bit(i) == 1, e0, stuff)
+	}); err != nil {
+		return nil, nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
 	}
-	wg.Wait()
 
 	for i := 0; i < proofSize; i++ {
 		//		add up to get vTotal scalar
 		vTotal.Add(vTotal, stuff.vScalars[i])
+		vTotal.Mod(vTotal, zkpcp.C.Params().N)
 
 		// add points to get AggregatePoint
 		AggregatePoint = zkpcp.Add(AggregatePoint, stuff.Bpoints[i])
@@ -230,6 +284,44 @@ func NewRangeProof(zkpcp ZKPCurveParams, value *big.Int) (*RangeProof, *big.Int,
 	return &proof, vTotal, nil
 }
 
+// rangeChallenge is the challenge e0 of a RangeProof, which hashes the points
+// R of the rings of all bits. With LegacyChallenges it is the digest of their
+// raw coordinates mod N, the way it was before Transcript.
+func rangeChallenge(zkpcp ZKPCurveParams, Rpoints []ECPoint) *big.Int {
+	if LegacyChallenges {
+		h := zkpcp.newHash()
+		for _, R := range Rpoints {
+			h.Write(R.X.Bytes())
+			h.Write(R.Y.Bytes())
+		}
+		// reduced mod N, a digest of SHA-512 is wider than N
+		e0 := new(big.Int).SetBytes(h.Sum(nil))
+		return e0.Mod(e0, zkpcp.C.Params().N)
+	}
+	t := NewTranscript(zkpcp, "RangeProof")
+	for _, R := range Rpoints {
+		t.AppendPoint("R", R)
+	}
+	return t.ChallengeScalar("e0")
+}
+
+// rangeBitChallenge is the challenge e_i of the ring of bit idx of a
+// RangeProof for its point P. It is hashed under the index of the bit, so the
+// rings do not share challenges. With LegacyChallenges it is the digest of the
+// raw coordinates of P mod N.
+func rangeBitChallenge(zkpcp ZKPCurveParams, idx int, P ECPoint) *big.Int {
+	if LegacyChallenges {
+		e := new(big.Int).SetBytes(zkpcp.digest(append(P.X.Bytes(), P.Y.Bytes()...)))
+		return e.Mod(e, zkpcp.C.Params().N)
+	}
+	var bit [4]byte
+	binary.BigEndian.PutUint32(bit[:], uint32(idx))
+	t := NewTranscript(zkpcp, "RangeProof")
+	t.AppendMessage("bit", bit[:])
+	t.AppendPoint("R", P)
+	return t.ChallengeScalar("e")
+}
+
 type verifyTuple struct {
 	index  int
 	Rpoint ECPoint
@@ -239,18 +331,12 @@ type verifyTuple struct {
 func verifyGen(zkpcp ZKPCurveParams,
 	idx int, proofE *big.Int, rpt rangeProofTuple, retbox chan verifyTuple) {
 
-	lhs := zkpcp.Mult(zkpcp.H, rpt.S)
-
 	rhs2 := zkpcp.Add(rpt.C, zkpcp.Neg(zkpcp.HPoints[idx]))
 
-	rhsXYNeg := zkpcp.Neg(zkpcp.Mult(rhs2, proofE))
-
 	//s_i * G - e_0 * (C_i - 2^i * H)
-	tot := zkpcp.Add(lhs, rhsXYNeg)
+	tot := zkpcp.MultiMult([]ECPoint{zkpcp.H, rhs2}, []*big.Int{rpt.S, new(big.Int).Neg(proofE)})
 
-	hash := sha256.Sum256(append(tot.X.Bytes(), tot.Y.Bytes()...))
-
-	e1 := new(big.Int).SetBytes(hash[:])
+	e1 := rangeBitChallenge(zkpcp, idx, tot)
 
 	var result verifyTuple
 	result.index = idx
@@ -261,7 +347,32 @@ func verifyGen(zkpcp ZKPCurveParams,
 
 func (proof *RangeProof) Verify(zkpcp ZKPCurveParams, comm ECPoint) (bool, error) {
 	if proof == nil {
-		return false, &errorProof{"RangeProof.Verify", fmt.Sprintf("passed proof is nil")}
+		return false, malformedError("RangeProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+	if proof.ProofE == nil {
+		return false, malformedError("RangeProof.Verify", "proof is missing its challenge")
+	}
+	// every tuple is checked against the HPoint of its bit
+	if len(proof.ProofTuples) == 0 || len(proof.ProofTuples) > len(zkpcp.HPoints) {
+		return false, malformedError("RangeProof.Verify",
+			fmt.Sprintf("%d tuples, expected 1 to %d", len(proof.ProofTuples), len(zkpcp.HPoints)))
+	}
+
+	if !zkpcp.validPoints(comm) || !zkpcp.onCurve(proof.ProofAggregate) {
+		return false, curveError("RangeProof.Verify")
+	}
+	for _, t := range proof.ProofTuples {
+		if !zkpcp.onCurve(t.C) {
+			return false, curveError("RangeProof.Verify")
+		}
+	}
+	if err := zkpcp.canonicalScalars("RangeProof.Verify", "ProofE", proof.ProofE); err != nil {
+		return false, err
+	}
+	for i, t := range proof.ProofTuples {
+		if err := zkpcp.canonicalScalars("RangeProof.Verify", fmt.Sprintf("ProofTuples[%d].S", i), t.S); err != nil {
+			return false, err
+		}
 	}
 
 	proofs := proof.ProofTuples
@@ -277,10 +388,10 @@ func (proof *RangeProof) Verify(zkpcp ZKPCurveParams, comm ECPoint) (bool, error
 	for i := 0; i < proofLength; i++ {
 		// check that proofs are non-nil
 		if proof.ProofTuples[i].C.X == nil {
-			return false, &errorProof{"RangeProof.Verify", fmt.Sprintf("entry %d has nil point", i)}
+			return false, malformedError("RangeProof.Verify", fmt.Sprintf("entry %d has nil point", i))
 		}
 		if proof.ProofTuples[i].S == nil {
-			return false, &errorProof{"RangeProof.Verify", fmt.Sprintf("entry %d has nil scalar", i)}
+			return false, malformedError("RangeProof.Verify", fmt.Sprintf("entry %d has nil scalar", i))
 
 		}
 
@@ -299,26 +410,42 @@ func (proof *RangeProof) Verify(zkpcp ZKPCurveParams, comm ECPoint) (bool, error
 		totalPoint = zkpcp.Add(totalPoint, proof.ProofTuples[i].C)
 	}
 
-	rHash := sha256.New()
-	for _, rpoint := range Rpoints {
-		rHash.Write(rpoint.X.Bytes())
-		rHash.Write(rpoint.Y.Bytes())
+	if e0 := rangeChallenge(zkpcp, Rpoints); !scalarsEqual(proof.ProofE, e0) {
+		return false, challengeError("RangeProof.Verify", "calculatedE0 does not match", e0, proof.ProofE)
 	}
-	calculatedE0 := rHash.Sum(nil)
 
-	if proof.ProofE.Cmp(new(big.Int).SetBytes(calculatedE0[:])) != 0 {
-		return false, &errorProof{"RangeProof.Verify", fmt.Sprintf("calculatedE0 does not match")}
+	if !pointsEqual(totalPoint, proof.ProofAggregate) {
+		return false, equationError("RangeProof.Verify", 1, "ProofAggregate does not match totalPoint", proof.ProofAggregate, totalPoint)
 	}
 
-	if !totalPoint.Equal(proof.ProofAggregate) {
-		return false, &errorProof{"RangeProof.Verify", fmt.Sprintf("ProofAggregate does not match totalPoint")}
+	if !pointsEqual(comm, totalPoint) {
+		return false, equationError("RangeProof.Verify", 2, "ProofAggregate does not match commitment", totalPoint, comm)
 	}
 
-	if !comm.Equal(totalPoint) {
-		return false, &errorProof{"RangeProof.Verify", fmt.Sprintf("ProofAggregate does not match commitment")}
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, the only arg is comm like for Verify
+func (proof *RangeProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("RangeProof.VerifyArgs", args)
+	comm := a.point()
+	if err := a.done(); err != nil {
+		return false, err
 	}
+	return proof.Verify(zkpcp, comm)
+}
 
-	return true, nil
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *RangeProof) String() string {
+	if proof == nil {
+		return "RangeProof(nil)"
+	}
+	fields := []interface{}{"ProofAggregate", proof.ProofAggregate, "ProofE", proof.ProofE}
+	for i, t := range proof.ProofTuples {
+		fields = append(fields, fmt.Sprintf("ProofTuples[%d].C", i), t.C,
+			fmt.Sprintf("ProofTuples[%d].S", i), t.S)
+	}
+	return proofString("RangeProof", fields...)
 }
 
 // Bytes returns a byte slice with a serialized representation of RangeProof proof
@@ -336,21 +463,164 @@ func (proof *RangeProof) Bytes() []byte {
 	return buf.Bytes()
 }
 
+// Size returns the length of Bytes of RangeProof proof, which depends on the
+// lengths of its points and scalars
+func (proof *RangeProof) Size() int {
+	return len(proof.Bytes())
+}
+
+// Clone returns a deep copy of RangeProof proof that shares no big.Int with it
+func (proof *RangeProof) Clone() *RangeProof {
+	if proof == nil {
+		return nil
+	}
+	c := &RangeProof{
+		ProofAggregate: clonePoint(proof.ProofAggregate),
+		ProofE:         cloneScalar(proof.ProofE),
+	}
+	if proof.ProofTuples != nil {
+		c.ProofTuples = make([]rangeProofTuple, len(proof.ProofTuples))
+		for i, t := range proof.ProofTuples {
+			c.ProofTuples[i] = rangeProofTuple{clonePoint(t.C), cloneScalar(t.S)}
+		}
+	}
+	return c
+}
+
+// Equal returns true if RangeProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *RangeProof) Equal(other *RangeProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	if !samePoint(proof.ProofAggregate, other.ProofAggregate) || !sameScalar(proof.ProofE, other.ProofE) ||
+		len(proof.ProofTuples) != len(other.ProofTuples) {
+		return false
+	}
+	for i, t := range proof.ProofTuples {
+		if !samePoint(t.C, other.ProofTuples[i].C) || !sameScalar(t.S, other.ProofTuples[i].S) {
+			return false
+		}
+	}
+	return true
+}
+
 // NewRangeProofFromBytes returns a RangeProof generated from the
 // deserialization of byte slice b
 func NewRangeProofFromBytes(b []byte) (*RangeProof, error) {
 	proof := new(RangeProof)
 	buf := bytes.NewBuffer(b)
 
-	proof.ProofAggregate, _ = ReadECPoint(buf)
-	proof.ProofE, _ = ReadBigInt(buf)
-	numTuples, _ := wire.ReadVarInt(buf)
+	var err error
+	if proof.ProofAggregate, err = ReadECPoint(buf); err != nil {
+		return nil, malformedError("RangeProofFromBytes", err.Error())
+	}
+	if proof.ProofE, err = ReadBigInt(buf); err != nil {
+		return nil, malformedError("RangeProofFromBytes", err.Error())
+	}
+	numTuples, err := wire.ReadVarInt(buf)
+	if err != nil {
+		return nil, malformedError("RangeProofFromBytes", err.Error())
+	}
+	if numTuples == 0 || numTuples > maxRangeProofTuples {
+		return nil, malformedError("RangeProofFromBytes",
+			fmt.Sprintf("%d tuples, expected 1 to %d", numTuples, maxRangeProofTuples))
+	}
+	// a tuple takes at least the two lengths of C and the length and sign
+	// of S, so more tuples than that can not fit
+	if numTuples > uint64(buf.Len()/4) {
+		return nil, malformedError("RangeProofFromBytes", "buffer too short for number of tuples")
+	}
 	proof.ProofTuples = make([]rangeProofTuple, numTuples)
 	for i := uint64(0); i < numTuples; i++ {
 		proof.ProofTuples[i] = rangeProofTuple{}
-		proof.ProofTuples[i].C, _ = ReadECPoint(buf)
-		proof.ProofTuples[i].S, _ = ReadBigInt(buf)
+		if proof.ProofTuples[i].C, err = ReadECPoint(buf); err != nil {
+			return nil, malformedError("RangeProofFromBytes", err.Error())
+		}
+		if proof.ProofTuples[i].S, err = ReadBigInt(buf); err != nil {
+			return nil, malformedError("RangeProofFromBytes", err.Error())
+		}
+	}
+	if buf.Len() != 0 {
+		return nil, malformedError("RangeProofFromBytes", fmt.Sprintf("%d trailing bytes", buf.Len()))
+	}
+
+	// the scalars are written with their sign and the points as plain
+	// coordinates, so they may be out of range or padded with zeros
+	N := encodingN
+	if proof.ProofE.Sign() < 0 || proof.ProofE.Cmp(N) >= 0 {
+		return nil, nonCanonicalError("RangeProofFromBytes", "ProofE is not in [0, N)")
+	}
+	for i, t := range proof.ProofTuples {
+		if t.S.Sign() < 0 || t.S.Cmp(N) >= 0 {
+			return nil, nonCanonicalError("RangeProofFromBytes", fmt.Sprintf("entry %d has a scalar not in [0, N)", i))
+		}
+	}
+	if !bytes.Equal(proof.Bytes(), b) {
+		return nil, malformedError("RangeProofFromBytes", "non-canonical encoding")
 	}
 
 	return proof, nil
 }
+
+type rangeProofTupleJSON struct {
+	C string `json:"c"`
+	S string `json:"s"`
+}
+
+type rangeProofJSON struct {
+	ProofAggregate string                `json:"proofAggregate"`
+	ProofE         string                `json:"proofE"`
+	ProofTuples    []rangeProofTupleJSON `json:"proofTuples"`
+}
+
+// MarshalJSON encodes RangeProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *RangeProof) MarshalJSON() ([]byte, error) {
+	proofTuples := make([]rangeProofTupleJSON, len(proof.ProofTuples))
+	for i, e := range proof.ProofTuples {
+		proofTuples[i] = rangeProofTupleJSON{
+			C: pointToHex(e.C),
+			S: scalarToHex(e.S),
+		}
+	}
+	return json.Marshal(rangeProofJSON{
+		ProofAggregate: pointToHex(proof.ProofAggregate),
+		ProofE:         scalarToHex(proof.ProofE),
+		ProofTuples:    proofTuples,
+	})
+}
+
+// UnmarshalJSON decodes a RangeProof encoded with MarshalJSON. It fails if a
+// point is not on the curve, a scalar is not in [0, N) or there are no or more
+// than maxRangeProofTuples tuples
+func (proof *RangeProof) UnmarshalJSON(b []byte) error {
+	var aux rangeProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := RangeProof{}
+	if p.ProofAggregate, err = pointFromHex(aux.ProofAggregate, "proofAggregate"); err != nil {
+		return err
+	}
+	if p.ProofE, err = scalarFromHex(aux.ProofE, "proofE"); err != nil {
+		return err
+	}
+	if len(aux.ProofTuples) == 0 || len(aux.ProofTuples) > maxRangeProofTuples {
+		return malformedError("UnmarshalJSON",
+			fmt.Sprintf("%d proofTuples, expected 1 to %d", len(aux.ProofTuples), maxRangeProofTuples))
+	}
+	p.ProofTuples = make([]rangeProofTuple, len(aux.ProofTuples))
+	for i, e := range aux.ProofTuples {
+		field := fmt.Sprintf("proofTuples[%d]", i)
+		if p.ProofTuples[i].C, err = pointFromHex(e.C, field+".c"); err != nil {
+			return err
+		}
+		if p.ProofTuples[i].S, err = scalarFromHex(e.S, field+".s"); err != nil {
+			return err
+		}
+	}
+	*proof = p
+	return nil
+}
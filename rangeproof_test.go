@@ -1,9 +1,18 @@
 package zksigma
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
+	"encoding/json"
+	"errors"
 	"math/big"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/mit-dci/zksigma/wire"
 )
 
 // Copy-pasted from original apl implementation by Willy (github.com/wrv)
@@ -13,6 +22,14 @@ func TestRangeProver_Verify(t *testing.T) {
 	if err != nil {
 		t.Fatalf("TestRangeProver_Verify failed to generate proof\n")
 	}
+	if !inScalarRange(rp) {
+		t.Fatalf("RangeProof randomness %v is outside of [0, N)\n", rp)
+	}
+	for i, tuple := range proof.ProofTuples {
+		if !inScalarRange(tuple.S) {
+			t.Fatalf("RangeProof tuple %d has s = %v outside of [0, N)\n", i, tuple.S)
+		}
+	}
 	comm := PedCommitR(TestCurve, value, rp)
 	if !comm.Equal(proof.ProofAggregate) {
 		t.Error("Error computing the randomnesses used -- commitments did not check out when supposed to")
@@ -49,6 +66,117 @@ func TestRangeProverSerialization(t *testing.T) {
 	}
 }
 
+// tupledRangeProof returns a RangeProof with n tuples, the ones of a valid
+// proof repeated
+func tupledRangeProof(tb testing.TB, n int) *RangeProof {
+	proof, _, err := NewRangeProof(TestCurve, big.NewInt(5))
+	if err != nil {
+		tb.Fatalf("%v\n", err)
+	}
+	tuples := proof.ProofTuples
+	proof.ProofTuples = nil
+	for i := 0; i < n; i++ {
+		proof.ProofTuples = append(proof.ProofTuples, tuples[i%len(tuples)])
+	}
+	return proof
+}
+
+// TestRangeProofTupleCount makes sure a proof with more tuples than there are
+// HPoints, or none, is rejected instead of crashing a verifying goroutine
+func TestRangeProofTupleCount(t *testing.T) {
+	for _, n := range []int{0, maxRangeProofTuples + 1, 2 * maxRangeProofTuples} {
+		proof := tupledRangeProof(t, n)
+		if ok, err := proof.Verify(TestCurve, proof.ProofAggregate); ok || !errors.Is(err, ErrMalformedProof) {
+			t.Fatalf("RangeProof with %d tuples gives %v\n", n, err)
+		}
+		if _, err := NewRangeProofFromBytes(proof.Bytes()); !errors.Is(err, ErrMalformedProof) {
+			t.Fatalf("NewRangeProofFromBytes with %d tuples gives %v\n", n, err)
+		}
+		js, err := json.Marshal(proof)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if err := json.Unmarshal(js, new(RangeProof)); !errors.Is(err, ErrMalformedProof) {
+			t.Fatalf("UnmarshalJSON with %d tuples gives %v\n", n, err)
+		}
+	}
+}
+
+// TestRangeProofNonCanonical makes sure a scalar outside of [0, N) and a
+// padded scalar do not decode, although both would verify like the reduced one
+func TestRangeProofNonCanonical(t *testing.T) {
+	proof, _, err := NewRangeProof(TestCurve, big.NewInt(1000))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	shifted := proof.Clone()
+	S := shifted.ProofTuples[3].S
+	S.Sub(S, TestCurve.C.Params().N)
+	if _, err := NewRangeProofFromBytes(shifted.Bytes()); !errors.Is(err, ErrNonCanonicalScalar) {
+		t.Fatalf("RangeProof with s - N decodes: %v\n", err)
+	}
+
+	// ProofE = 1 encoded as 00 01 and as 00 00 01 after the sign byte
+	small := proof.Clone()
+	small.ProofE = big.NewInt(1)
+	encoded := small.Bytes()
+	if _, err := NewRangeProofFromBytes(encoded); err != nil {
+		t.Fatalf("RangeProof failed to decode: %v\n", err)
+	}
+	var padded bytes.Buffer
+	WriteECPoint(&padded, small.ProofAggregate)
+	rest := encoded[padded.Len()+3:]
+	wire.WriteVarBytes(&padded, []byte{0x00, 0x00, 0x01})
+	padded.Write(rest)
+	if _, err := NewRangeProofFromBytes(padded.Bytes()); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("RangeProof with a padded ProofE decodes: %v\n", err)
+	}
+}
+
+func TestRangeProofBatchCtx(t *testing.T) {
+	before := runtime.NumGoroutine()
+	values := []*big.Int{big.NewInt(3), big.NewInt(1000), big.NewInt(0), big.NewInt(77)}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	proofs, rs, err := NewRangeProofBatchCtx(canceled, TestCurve, values)
+	checkCanceled(t, "NewRangeProofBatchCtx with a canceled context", err, context.Canceled)
+	if len(proofs) != 0 || len(rs) != 0 {
+		t.Fatalf("NewRangeProofBatchCtx with a canceled context returned %d proofs\n", len(proofs))
+	}
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, _, err = NewRangeProofBatchCtx(expired, TestCurve, values)
+	checkCanceled(t, "NewRangeProofBatchCtx after the deadline", err, context.DeadlineExceeded)
+
+	// a proof checks the context once before it and once for each bit and
+	// after each of its two rounds, so this cancels in the bits of the second
+	perProof := 1 + 40 + 1 + 40 + 1
+	proofs, rs, err = NewRangeProofBatchCtx(newCancelAfter(perProof+20, context.Canceled), TestCurve, values)
+	checkCanceled(t, "NewRangeProofBatchCtx canceled in the middle", err, context.Canceled)
+	if len(proofs) != 1 || len(rs) != 1 || !strings.Contains(err.Error(), "after 1 of 4") {
+		t.Fatalf("NewRangeProofBatchCtx canceled in the middle returned %d proofs: %v\n", len(proofs), err)
+	}
+	rest, restRs, err := NewRangeProofBatchCtx(context.Background(), TestCurve, values[1:])
+	if err != nil {
+		t.Fatalf("NewRangeProofBatchCtx failed to resume: %v\n", err)
+	}
+	proofs, rs = append(proofs, rest...), append(rs, restRs...)
+	for i, proof := range proofs {
+		if ok, err := proof.Verify(TestCurve, PedCommitR(TestCurve, values[i], rs[i])); !ok || err != nil {
+			t.Fatalf("RangeProof %d of a resumed batch failed to verify: %v\n", i, err)
+		}
+	}
+
+	tooBig := []*big.Int{big.NewInt(1), new(big.Int).Lsh(big.NewInt(1), 41)}
+	if proofs, _, err := NewRangeProofBatchCtx(context.Background(), TestCurve, tooBig); len(proofs) != 1 ||
+		err == nil || errors.Is(err, ErrCanceled) || !strings.Contains(err.Error(), "proof 1 failed") {
+		t.Fatalf("NewRangeProofBatchCtx with a value out of range gives %d proofs, %v\n", len(proofs), err)
+	}
+	checkGoroutines(t, before)
+}
+
 func TestOutOfRangeRangeProver_Verify(t *testing.T) {
 	min := new(big.Int).Exp(new(big.Int).SetInt64(2), new(big.Int).SetInt64(64), nil)
 
@@ -62,3 +190,39 @@ func TestOutOfRangeRangeProver_Verify(t *testing.T) {
 		t.Error("Computing the range proof shouldn't work but it did")
 	}
 }
+
+func TestRangeProofWithWorkers(t *testing.T) {
+	value := big.NewInt(987654321)
+	want, wantR, err := NewRangeProofWithRand(TestCurve, newSeededReader("range workers"), value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	for _, workers := range []int{0, 1, 3, 40, 64} {
+		proof, r, err := NewRangeProofWithWorkers(TestCurve, newSeededReader("range workers"), value, workers)
+		if err != nil {
+			t.Fatalf("RangeProof with %d workers failed to generate: %v\n", workers, err)
+		}
+		if !bytes.Equal(proof.Bytes(), want.Bytes()) || r.Cmp(wantR) != 0 {
+			t.Fatalf("RangeProof with %d workers differs from the one of NewRangeProofWithRand\n", workers)
+		}
+		if ok, err := proof.Verify(TestCurve, PedCommitR(TestCurve, value, r)); !ok || err != nil {
+			t.Fatalf("RangeProof with %d workers failed to verify: %v\n", workers, err)
+		}
+	}
+	if _, _, err := NewRangeProofWithWorkers(TestCurve, newSeededReader("range workers"), value, -1); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("RangeProof with -1 workers gives %v\n", err)
+	}
+}
+
+// benchmarkRangeProve proves a RangeProof of its 40 bits on workers
+// goroutines, 0 for GOMAXPROCS of them
+func benchmarkRangeProve(b *testing.B, workers int) {
+	value := big.NewInt(987654321)
+	for ii := 0; ii < b.N; ii++ {
+		NewRangeProofWithWorkers(TestCurve, rand.Reader, value, workers)
+	}
+}
+
+func BenchmarkRangeProve_1(b *testing.B) { benchmarkRangeProve(b, 1) }
+func BenchmarkRangeProve_4(b *testing.B) { benchmarkRangeProve(b, 4) }
+func BenchmarkRangeProve_0(b *testing.B) { benchmarkRangeProve(b, 0) }
@@ -0,0 +1,262 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// RerandomizeCommitment returns CM' = CM + r'H for a new random r', which
+// commits to the same value as CM but can not be linked to it by anyone who
+// does not know r'. It returns CM' and r', with which the owner can prove the
+// two commitments are related using NewRerandomizationProof.
+func RerandomizeCommitment(zkpcp ZKPCurveParams, CM ECPoint) (ECPoint, *big.Int, error) {
+	if !zkpcp.validPoints(CM) {
+		return Zero, nil, curveError("RerandomizeCommitment")
+	}
+	deltaR, err := zkpcp.randScalar(entropy())
+	if err != nil {
+		return Zero, nil, err
+	}
+	return RerandomizeCommitmentR(zkpcp, CM, deltaR), deltaR, nil
+}
+
+// RerandomizeCommitmentR returns CM' = CM + r'H for a given r', like PedCommitR
+// is a commitment with a given random value. r' is reduced mod N, and r' = 0
+// gives back CM, which NewRerandomizationProof still proves.
+func RerandomizeCommitmentR(zkpcp ZKPCurveParams, CM ECPoint, extraRandomness *big.Int) ECPoint {
+	return zkpcp.Add(CM, zkpcp.Mult(zkpcp.H, extraRandomness))
+}
+
+// RerandomizeWithProof re-randomizes CM like RerandomizeCommitment and proves
+// it with NewRerandomizationProof. It returns CM', the r' it drew and the proof.
+func RerandomizeWithProof(zkpcp ZKPCurveParams, CM ECPoint) (ECPoint, *big.Int, *RerandomizationProof, error) {
+	CMPrime, deltaR, err := RerandomizeCommitment(zkpcp, CM)
+	if err != nil {
+		return Zero, nil, nil, err
+	}
+	proof, err := NewRerandomizationProof(zkpcp, CM, CMPrime, deltaR)
+	if err != nil {
+		return Zero, nil, nil, err
+	}
+	return CMPrime, deltaR, proof, nil
+}
+
+// RerandomizationProof is a proof that CM' is a re-randomization of CM, so
+// both commit to the same value. It is a Schnorr proof of knowledge of r' with
+// CM' - CM = r'H, which only exists if the G parts of CM and CM' agree.
+//
+//  Public: generator points G and H, CM, CM'
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows r' with CM' = CM + r'H        D = CM' - CM
+//  selects random u
+//  T = uH
+//  c = HASH(G, H, CM, CM', T)
+//  s = u + c * r'
+//
+//  T, c, s --------------------------->
+//                                      c ?= HASH(G, H, CM, CM', T)
+//                                      sH ?= T + cD
+type RerandomizationProof struct {
+	T         ECPoint  // T = uH
+	Challenge *big.Int // c = HASH(G, H, CM, CM', T)
+	S         *big.Int // s = u + c * r'
+}
+
+// rerandomizationChallenge computes c = HASH(G, H, CM, CM', T)
+func rerandomizationChallenge(zkpcp ZKPCurveParams, CM, CMPrime, T ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "RerandomizationProof")
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendPoint("CM", CM)
+	t.AppendPoint("CMPrime", CMPrime)
+	t.AppendPoint("T", T)
+	return t.ChallengeScalar("c")
+}
+
+// NewRerandomizationProof generates a proof that CMPrime = CM + deltaR * H,
+// e.g. for the commitment and randomness returned by RerandomizeCommitment
+func NewRerandomizationProof(zkpcp ZKPCurveParams, CM, CMPrime ECPoint, deltaR *big.Int) (*RerandomizationProof, error) {
+	return NewRerandomizationProofWithRand(zkpcp, entropy(), CM, CMPrime, deltaR)
+}
+
+// NewRerandomizationProofWithRand is the same as NewRerandomizationProof, but
+// reads its random value from rnd instead of the EntropySource
+func NewRerandomizationProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	CM, CMPrime ECPoint, deltaR *big.Int) (*RerandomizationProof, error) {
+
+	N := zkpcp.C.Params().N
+
+	if err := zkpcp.checkScalars("RerandomizationProve", "deltaR", deltaR); err != nil {
+		return nil, err
+	}
+	if !zkpcp.validPoints(CM, CMPrime) {
+		return nil, curveError("RerandomizationProve")
+	}
+	if !RerandomizeCommitmentR(zkpcp, CM, deltaR).Equal(CMPrime) {
+		return nil, inputError("RerandomizationProve", "CMPrime is not CM + deltaR * H")
+	}
+
+	u, err := zkpcp.randScalar(rnd)
+	if err != nil {
+		return nil, err
+	}
+
+	T := zkpcp.Mult(zkpcp.H, u)
+	c := rerandomizationChallenge(zkpcp, CM, CMPrime, T)
+
+	s := new(big.Int).Add(u, new(big.Int).Mul(c, deltaR))
+	s.Mod(s, N)
+
+	return &RerandomizationProof{T, c, s}, nil
+}
+
+// Verify checks if RerandomizationProof proof shows that CMPrime is a
+// re-randomization of CM
+func (proof *RerandomizationProof) Verify(zkpcp ZKPCurveParams, CM, CMPrime ECPoint) (bool, error) {
+	if proof == nil {
+		return false, malformedError("RerandomizationProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(CM, CMPrime) || !zkpcp.onCurve(proof.T) {
+		return false, curveError("RerandomizationProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("RerandomizationProof.Verify", "Challenge, S", proof.Challenge, proof.S); err != nil {
+		return false, err
+	}
+
+	c := rerandomizationChallenge(zkpcp, CM, CMPrime, proof.T)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("RerandomizationProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	// sH ?= T + cD, checked as sH - cD ?= T
+	lhs := zkpcp.MultiMult([]ECPoint{zkpcp.H, zkpcp.Sub(CMPrime, CM)}, []*big.Int{proof.S, new(big.Int).Neg(c)})
+	if !pointsEqual(lhs, proof.T) {
+		return false, equationError("RerandomizationProof.Verify", 1, "sH - cD != T", lhs, proof.T)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are CM and CMPrime like for Verify
+func (proof *RerandomizationProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("RerandomizationProof.VerifyArgs", args)
+	CM, CMPrime := a.point(), a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CM, CMPrime)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *RerandomizationProof) String() string {
+	if proof == nil {
+		return "RerandomizationProof(nil)"
+	}
+	return proofString("RerandomizationProof", "T", proof.T, "Challenge", proof.Challenge, "S", proof.S)
+}
+
+// rerandomizationProofLen is the length of a serialized RerandomizationProof
+var rerandomizationProofLen = 1 + fixedPointLen + 2*fixedScalarLen
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// RerandomizationProof proof
+func (proof *RerandomizationProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.T)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.S)
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of RerandomizationProof proof, which has a fixed size
+func (proof *RerandomizationProof) Size() int {
+	return rerandomizationProofLen
+}
+
+// Clone returns a deep copy of RerandomizationProof proof that shares no big.Int with it
+func (proof *RerandomizationProof) Clone() *RerandomizationProof {
+	if proof == nil {
+		return nil
+	}
+	return &RerandomizationProof{
+		T:         clonePoint(proof.T),
+		Challenge: cloneScalar(proof.Challenge),
+		S:         cloneScalar(proof.S),
+	}
+}
+
+// Equal returns true if RerandomizationProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *RerandomizationProof) Equal(other *RerandomizationProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.T, other.T) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.S, other.S)
+}
+
+// NewRerandomizationProofFromBytes returns a RerandomizationProof generated
+// from the deserialization of byte slice b
+func NewRerandomizationProofFromBytes(b []byte) (*RerandomizationProof, error) {
+	if err := checkFixedHeader(b, rerandomizationProofLen, "RerandomizationProofFromBytes"); err != nil {
+		return nil, err
+	}
+	proof := new(RerandomizationProof)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.T, err = readFixedPoint(buf, "RerandomizationProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "RerandomizationProofFromBytes", &proof.Challenge, &proof.S); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+type rerandomizationProofJSON struct {
+	T         string `json:"t"`
+	Challenge string `json:"challenge"`
+	S         string `json:"s"`
+}
+
+// MarshalJSON encodes RerandomizationProof proof with points as compressed hex
+// and scalars as big-endian hex
+func (proof *RerandomizationProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rerandomizationProofJSON{
+		T:         pointToHex(proof.T),
+		Challenge: scalarToHex(proof.Challenge),
+		S:         scalarToHex(proof.S),
+	})
+}
+
+// UnmarshalJSON decodes a RerandomizationProof encoded with MarshalJSON. It
+// fails if a point is not on the curve or a scalar is not in [0, N)
+func (proof *RerandomizationProof) UnmarshalJSON(b []byte) error {
+	var aux rerandomizationProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := RerandomizationProof{}
+	if p.T, err = pointFromHex(aux.T, "t"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.S, err = scalarFromHex(aux.S, "s"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
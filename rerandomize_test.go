@@ -0,0 +1,155 @@
+package zksigma
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestRerandomizationProof(t *testing.T) {
+	value := big.NewInt(1000)
+	CM, r, err := PedCommit(TestCurve, value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	// three hops, each proved on its own
+	hops := []ECPoint{CM}
+	total := new(big.Int).Set(r)
+	for ii := 0; ii < 3; ii++ {
+		prev := hops[len(hops)-1]
+		next, deltaR, err := RerandomizeCommitment(TestCurve, prev)
+		if err != nil {
+			t.Fatalf("RerandomizeCommitment failed: %v\n", err)
+		}
+		if next.Equal(prev) {
+			t.Fatalf("RerandomizeCommitment returned the same commitment\n")
+		}
+		proof, err := NewRerandomizationProof(TestCurve, prev, next, deltaR)
+		if err != nil {
+			t.Fatalf("RerandomizationProof failed to generate for hop %d: %v\n", ii, err)
+		}
+		if ok, err := proof.Verify(TestCurve, prev, next); !ok || err != nil {
+			t.Fatalf("RerandomizationProof failed to verify for hop %d: %v\n", ii, err)
+		}
+		if ok, err := proof.Verify(TestCurve, next, prev); ok || err == nil {
+			t.Fatalf("RerandomizationProof verified with CM and CMPrime swapped\n")
+		}
+
+		hops = append(hops, next)
+		total.Add(total, deltaR)
+	}
+
+	// the last hop still opens to value with the summed randomness
+	if !Open(TestCurve, value, total, hops[len(hops)-1]) {
+		t.Fatalf("re-randomized commitment does not open to the original value\n")
+	}
+}
+
+func TestRerandomizeCommitmentR(t *testing.T) {
+	value, r := big.NewInt(1000), scalarFromSeed("rerandomize r")
+	CM := PedCommitR(TestCurve, value, r)
+	s := scalarFromSeed("rerandomize s")
+
+	CMPrime := RerandomizeCommitmentR(TestCurve, CM, s)
+	if !CMPrime.Equal(PedCommitR(TestCurve, value, new(big.Int).Add(r, s))) {
+		t.Fatalf("RerandomizeCommitmentR does not add sH to CM\n")
+	}
+	proof, err := NewRerandomizationProof(TestCurve, CM, CMPrime, s)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CM, CMPrime); !ok || err != nil {
+		t.Fatalf("RerandomizationProof of RerandomizeCommitmentR did not verify: %v\n", err)
+	}
+
+	// s = 0 leaves CM as it is, and that is still proved
+	if same := RerandomizeCommitmentR(TestCurve, CM, big.NewInt(0)); !same.Equal(CM) {
+		t.Fatalf("RerandomizeCommitmentR with s = 0 changed CM\n")
+	}
+	noop, err := NewRerandomizationProof(TestCurve, CM, CM, big.NewInt(0))
+	if err != nil {
+		t.Fatalf("RerandomizationProof failed for s = 0: %v\n", err)
+	}
+	if ok, err := noop.Verify(TestCurve, CM, CM); !ok || err != nil {
+		t.Fatalf("RerandomizationProof for s = 0 did not verify: %v\n", err)
+	}
+	// the challenge binds CM and CM', so it is no proof for another pair
+	if ok, err := noop.Verify(TestCurve, CMPrime, CMPrime); ok || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("RerandomizationProof for s = 0 verified for another commitment: %v\n", err)
+	}
+
+	// the prover can draw s itself and gets it back
+	drawn, deltaR, proof, err := RerandomizeWithProof(TestCurve, CM)
+	if err != nil {
+		t.Fatalf("RerandomizeWithProof failed: %v\n", err)
+	}
+	if !drawn.Equal(RerandomizeCommitmentR(TestCurve, CM, deltaR)) || drawn.Equal(CM) {
+		t.Fatalf("RerandomizeWithProof does not return CM + r'H for its r'\n")
+	}
+	if ok, err := proof.Verify(TestCurve, CM, drawn); !ok || err != nil {
+		t.Fatalf("proof of RerandomizeWithProof did not verify: %v\n", err)
+	}
+	if _, _, proof, err := RerandomizeWithProof(TestCurve, ECPoint{big.NewInt(1), big.NewInt(1)}); proof != nil || !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("RerandomizeWithProof of a CM not on the curve gives %v\n", err)
+	}
+}
+
+func TestRerandomizationProofUnrelated(t *testing.T) {
+	CM, _, _ := PedCommit(TestCurve, big.NewInt(5))
+	other, _, _ := PedCommit(TestCurve, big.NewInt(6))
+	same, _, _ := PedCommit(TestCurve, big.NewInt(5))
+	CMPrime, deltaR, err := RerandomizeCommitment(TestCurve, CM)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	for _, unrelated := range []ECPoint{other, same} {
+		if _, err := NewRerandomizationProof(TestCurve, CM, unrelated, deltaR); err == nil {
+			t.Fatalf("RerandomizationProof generated for an unrelated commitment\n")
+		}
+	}
+
+	proof, err := NewRerandomizationProof(TestCurve, CM, CMPrime, deltaR)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	for _, unrelated := range []ECPoint{other, same} {
+		if ok, err := proof.Verify(TestCurve, CM, unrelated); ok || err == nil {
+			t.Fatalf("RerandomizationProof verified for an unrelated commitment\n")
+		}
+		if ok, err := proof.Verify(TestCurve, unrelated, CMPrime); ok || err == nil {
+			t.Fatalf("RerandomizationProof verified from an unrelated commitment\n")
+		}
+	}
+
+	// a proof for CM' = CM + r'H can not be made for another value
+	shifted := TestCurve.Add(CMPrime, TestCurve.G)
+	if _, err := NewRerandomizationProof(TestCurve, CM, shifted, deltaR); err == nil {
+		t.Fatalf("RerandomizationProof generated for a commitment to another value\n")
+	}
+
+	var nilProof *RerandomizationProof
+	if ok, err := nilProof.Verify(TestCurve, CM, CMPrime); ok || err == nil {
+		t.Fatalf("nil RerandomizationProof verified\n")
+	}
+}
+
+func BenchmarkRerandomizationProve(b *testing.B) {
+	CM, _, _ := PedCommit(TestCurve, big.NewInt(5))
+	CMPrime, deltaR, _ := RerandomizeCommitment(TestCurve, CM)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewRerandomizationProof(TestCurve, CM, CMPrime, deltaR)
+	}
+}
+
+func BenchmarkRerandomizationVerify(b *testing.B) {
+	CM, _, _ := PedCommit(TestCurve, big.NewInt(5))
+	CMPrime, deltaR, _ := RerandomizeCommitment(TestCurve, CM)
+	proof, _ := NewRerandomizationProof(TestCurve, CM, CMPrime, deltaR)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CM, CMPrime)
+	}
+}
@@ -0,0 +1,40 @@
+package zksigma
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestFixedEncodings checks the proofs with an encoding of fixed length: it
+// has that length on either preset curve, decodes to a proof that verifies
+// for the same statement, and encodes to the same bytes again
+func TestFixedEncodings(t *testing.T) {
+	sizes := map[string]int{
+		"BalanceProof":         balanceProofLen,
+		"DecryptionProof":      decryptionProofLen,
+		"KeyRotationProof":     keyRotationProofLen,
+		"NonZeroProof":         nonZeroProofLen,
+		"OpenToValueProof":     openToValueProofLen,
+		"RerandomizationProof": rerandomizationProofLen,
+		"SameBlindingProof":    sameBlindingProofLen,
+		"SameValueProof":       sameValueProofLen,
+	}
+	for curve, zkpcp := range map[string]ZKPCurveParams{"secp256k1": TestCurve, "P-256": P256Curve} {
+		cases := verifiableProofsOn(t, zkpcp)
+		for name, size := range sizes {
+			c := cases[name]
+			b := c.proof.(encodable).Bytes()
+			data, _ := EncodeProof(c.proof)
+			decoded, pt, err := DecodeProof(data)
+			if err != nil || pt.Name != name {
+				t.Fatalf("%s on %s failed to deserialize: %v\n", name, curve, err)
+			}
+			if ok, err := decoded.VerifyArgs(zkpcp, c.args...); !ok || err != nil {
+				t.Fatalf("%s on %s failed to verify after deserialization: %v\n", name, curve, err)
+			}
+			if len(b) != size || !bytes.Equal(decoded.(encodable).Bytes(), b) {
+				t.Fatalf("%s on %s produced a wrongly sized or unstable encoding\n", name, curve)
+			}
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// SameBlindingProof is a proof that the tokens CMTok1 and CMTok2 of a
+// commitment for two auditors use the same randomness r, e.g. for a
+// transaction audited by both. It is a Chaum-Pedersen proof that r is the
+// discrete log of CMTok1 to the base PK1 and of CMTok2 to PK2, without
+// revealing r.
+//
+//  Public: PK1, PK2, CMTok1, CMTok2
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows r with CMTok1 = r * PK1
+//  and CMTok2 = r * PK2
+//  selects random u
+//  T1 = u * PK1
+//  T2 = u * PK2
+//  c = HASH(PK1, PK2, CMTok1, CMTok2, T1, T2)
+//  s = u + c * r
+//
+//  T1, T2, c, s ---------------------->
+//                                      c ?= HASH(PK1, PK2, CMTok1, CMTok2, T1, T2)
+//                                      s * PK1 ?= T1 + c * CMTok1
+//                                      s * PK2 ?= T2 + c * CMTok2
+type SameBlindingProof struct {
+	T1        ECPoint  // T1 = u * PK1
+	T2        ECPoint  // T2 = u * PK2
+	Challenge *big.Int // c = HASH(PK1, PK2, CMTok1, CMTok2, T1, T2)
+	S         *big.Int // s = u + c * r
+}
+
+// sameBlindingChallenge computes c = HASH(msg, PK1, PK2, CMTok1, CMTok2, T1, T2)
+func sameBlindingChallenge(zkpcp ZKPCurveParams, CMTok1, CMTok2, PK1, PK2, T1, T2 ECPoint, msg []byte) *big.Int {
+	t := NewTranscript(zkpcp, "SameBlindingProof")
+	t.bindMessage(msg)
+	t.AppendPoint("PK1", PK1)
+	t.AppendPoint("PK2", PK2)
+	t.AppendPoint("CMTok1", CMTok1)
+	t.AppendPoint("CMTok2", CMTok2)
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	return t.ChallengeScalar("c")
+}
+
+// NewSameBlindingProof generates a proof that CMTok1 = r * PK1 and CMTok2 =
+// r * PK2 use the same r. It fails if r is zero or does not give both tokens.
+func NewSameBlindingProof(zkpcp ZKPCurveParams, CMTok1, CMTok2, PK1, PK2 ECPoint, r *big.Int) (*SameBlindingProof, error) {
+	return newSameBlindingProof(zkpcp, entropy(), CMTok1, CMTok2, PK1, PK2, r, nil)
+}
+
+// NewSameBlindingProofWithRand is the same as NewSameBlindingProof, but reads
+// its random value from rnd instead of the EntropySource
+func NewSameBlindingProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	CMTok1, CMTok2, PK1, PK2 ECPoint, r *big.Int) (*SameBlindingProof, error) {
+	return newSameBlindingProof(zkpcp, rnd, CMTok1, CMTok2, PK1, PK2, r, nil)
+}
+
+// NewSameBlindingProofWithMessage is the same as NewSameBlindingProof, but
+// binds the proof to the application message msg like NewABCProofWithMessage.
+// Passing the encoding of the commitment CM = vG + rH the tokens belong to,
+// e.g. from CM.MarshalBinary, ties the proof to CM, so it can not be reused
+// for the same tokens next to another commitment. It only verifies with
+// VerifyWithMessage and the same msg.
+func NewSameBlindingProofWithMessage(zkpcp ZKPCurveParams,
+	CMTok1, CMTok2, PK1, PK2 ECPoint, r *big.Int, msg []byte) (*SameBlindingProof, error) {
+	return newSameBlindingProof(zkpcp, entropy(), CMTok1, CMTok2, PK1, PK2, r, msg)
+}
+
+// newSameBlindingProof generates a SameBlindingProof bound to msg, which may be nil
+func newSameBlindingProof(zkpcp ZKPCurveParams, rnd io.Reader,
+	CMTok1, CMTok2, PK1, PK2 ECPoint, r *big.Int, msg []byte) (*SameBlindingProof, error) {
+
+	if err := zkpcp.checkScalars("SameBlindingProve", "r", r); err != nil {
+		return nil, err
+	}
+	// r = 0 gives Zero for both tokens, whatever the keys
+	if r.Sign() == 0 {
+		return nil, rangeError("SameBlindingProve", "r must not be zero")
+	}
+	if !zkpcp.validPoints(CMTok1, CMTok2, PK1, PK2) {
+		return nil, curveError("SameBlindingProve")
+	}
+	// T1 or T2 would be the point at infinity, which a proof may not carry
+	if PK1.IsZero() || PK2.IsZero() {
+		return nil, inputError("SameBlindingProve", "PK1 and PK2 must not be Zero")
+	}
+	if !zkpcp.Mult(PK1, r).Equal(CMTok1) {
+		return nil, inputError("SameBlindingProve", "CMTok1 is not r * PK1")
+	}
+	if !zkpcp.Mult(PK2, r).Equal(CMTok2) {
+		return nil, inputError("SameBlindingProve", "CMTok2 is not r * PK2")
+	}
+
+	u, err := zkpcp.randScalar(rnd)
+	if err != nil {
+		return nil, err
+	}
+
+	T1 := zkpcp.Mult(PK1, u)
+	T2 := zkpcp.Mult(PK2, u)
+	c := sameBlindingChallenge(zkpcp, CMTok1, CMTok2, PK1, PK2, T1, T2, msg)
+
+	s := new(big.Int).Add(u, new(big.Int).Mul(c, r))
+	s.Mod(s, zkpcp.C.Params().N)
+
+	return &SameBlindingProof{T1, T2, c, s}, nil
+}
+
+// Verify checks if SameBlindingProof proof shows that CMTok1 = r * PK1 and
+// CMTok2 = r * PK2 for the same r. PK1 and PK2 must not be Zero.
+func (proof *SameBlindingProof) Verify(zkpcp ZKPCurveParams, CMTok1, CMTok2, PK1, PK2 ECPoint) (bool, error) {
+	return proof.verify(zkpcp, CMTok1, CMTok2, PK1, PK2, nil)
+}
+
+// VerifyWithMessage is the same as Verify for a SameBlindingProof generated by
+// NewSameBlindingProofWithMessage, which only verifies for the same msg
+func (proof *SameBlindingProof) VerifyWithMessage(zkpcp ZKPCurveParams,
+	CMTok1, CMTok2, PK1, PK2 ECPoint, msg []byte) (bool, error) {
+	return proof.verify(zkpcp, CMTok1, CMTok2, PK1, PK2, msg)
+}
+
+// verify is Verify for the proof bound to msg, which may be nil
+func (proof *SameBlindingProof) verify(zkpcp ZKPCurveParams, CMTok1, CMTok2, PK1, PK2 ECPoint, msg []byte) (bool, error) {
+	if proof == nil {
+		return false, malformedError("SameBlindingProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(CMTok1, CMTok2, PK1, PK2) || !zkpcp.onCurve(proof.T1, proof.T2) {
+		return false, curveError("SameBlindingProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("SameBlindingProof.Verify", "Challenge, S", proof.Challenge, proof.S); err != nil {
+		return false, err
+	}
+	// every r takes PK = Zero to the token Zero
+	if PK1.IsZero() || PK2.IsZero() {
+		return false, inputError("SameBlindingProof.Verify", "PK1 and PK2 must not be Zero")
+	}
+
+	c := sameBlindingChallenge(zkpcp, CMTok1, CMTok2, PK1, PK2, proof.T1, proof.T2, msg)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("SameBlindingProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	negC := new(big.Int).Neg(c)
+
+	// s * PK1 ?= T1 + c * CMTok1, checked as s * PK1 - c * CMTok1 ?= T1
+	lhs := zkpcp.MultiMult([]ECPoint{PK1, CMTok1}, []*big.Int{proof.S, negC})
+	if !pointsEqual(lhs, proof.T1) {
+		return false, equationError("SameBlindingProof.Verify", 1, "s * PK1 - c * CMTok1 != T1", lhs, proof.T1)
+	}
+
+	// s * PK2 ?= T2 + c * CMTok2, checked as s * PK2 - c * CMTok2 ?= T2
+	lhs = zkpcp.MultiMult([]ECPoint{PK2, CMTok2}, []*big.Int{proof.S, negC})
+	if !pointsEqual(lhs, proof.T2) {
+		return false, equationError("SameBlindingProof.Verify", 2, "s * PK2 - c * CMTok2 != T2", lhs, proof.T2)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are CMTok1, CMTok2, PK1 and PK2 like
+// for Verify
+func (proof *SameBlindingProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("SameBlindingProof.VerifyArgs", args)
+	CMTok1, CMTok2, PK1, PK2 := a.point(), a.point(), a.point(), a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CMTok1, CMTok2, PK1, PK2)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *SameBlindingProof) String() string {
+	if proof == nil {
+		return "SameBlindingProof(nil)"
+	}
+	return proofString("SameBlindingProof", "T1", proof.T1, "T2", proof.T2,
+		"Challenge", proof.Challenge, "S", proof.S)
+}
+
+// sameBlindingProofLen is the length of a serialized SameBlindingProof
+var sameBlindingProofLen = 1 + 2*fixedPointLen + 2*fixedScalarLen
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// SameBlindingProof proof
+func (proof *SameBlindingProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.T1)
+	writeFixedPoint(&buf, proof.T2)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.S)
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of SameBlindingProof proof, which has a fixed size
+func (proof *SameBlindingProof) Size() int {
+	return sameBlindingProofLen
+}
+
+// Clone returns a deep copy of SameBlindingProof proof that shares no big.Int with it
+func (proof *SameBlindingProof) Clone() *SameBlindingProof {
+	if proof == nil {
+		return nil
+	}
+	return &SameBlindingProof{
+		T1:        clonePoint(proof.T1),
+		T2:        clonePoint(proof.T2),
+		Challenge: cloneScalar(proof.Challenge),
+		S:         cloneScalar(proof.S),
+	}
+}
+
+// Equal returns true if SameBlindingProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *SameBlindingProof) Equal(other *SameBlindingProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.T1, other.T1) &&
+		samePoint(proof.T2, other.T2) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.S, other.S)
+}
+
+// NewSameBlindingProofFromBytes returns a SameBlindingProof generated from the
+// deserialization of byte slice b
+func NewSameBlindingProofFromBytes(b []byte) (*SameBlindingProof, error) {
+	if err := checkFixedHeader(b, sameBlindingProofLen, "SameBlindingProofFromBytes"); err != nil {
+		return nil, err
+	}
+	proof := new(SameBlindingProof)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.T1, err = readFixedPoint(buf, "SameBlindingProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if proof.T2, err = readFixedPoint(buf, "SameBlindingProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "SameBlindingProofFromBytes", &proof.Challenge, &proof.S); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+type sameBlindingProofJSON struct {
+	T1        string `json:"t1"`
+	T2        string `json:"t2"`
+	Challenge string `json:"challenge"`
+	S         string `json:"s"`
+}
+
+// MarshalJSON encodes SameBlindingProof proof with points as compressed hex
+// and scalars as big-endian hex
+func (proof *SameBlindingProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sameBlindingProofJSON{
+		T1:        pointToHex(proof.T1),
+		T2:        pointToHex(proof.T2),
+		Challenge: scalarToHex(proof.Challenge),
+		S:         scalarToHex(proof.S),
+	})
+}
+
+// UnmarshalJSON decodes a SameBlindingProof encoded with MarshalJSON. It fails
+// if a point is not on the curve or a scalar is not in [0, N)
+func (proof *SameBlindingProof) UnmarshalJSON(b []byte) error {
+	var aux sameBlindingProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := SameBlindingProof{}
+	if p.T1, err = pointFromHex(aux.T1, "t1"); err != nil {
+		return err
+	}
+	if p.T2, err = pointFromHex(aux.T2, "t2"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.S, err = scalarFromHex(aux.S, "s"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
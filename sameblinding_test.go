@@ -0,0 +1,143 @@
+package zksigma
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// sameBlindingTokens returns two auditor keys and the tokens of a commitment CM
+// with randomness r for both
+func sameBlindingTokens(t *testing.T) (CM, CMTok1, CMTok2, PK1, PK2 ECPoint, r *big.Int) {
+	PK1 = TestCurve.Mult(TestCurve.H, scalarFromSeed("same blinding sk1"))
+	PK2 = TestCurve.Mult(TestCurve.H, scalarFromSeed("same blinding sk2"))
+	CM, r, err := PedCommit(TestCurve, big.NewInt(42))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	return CM, TestCurve.Mult(PK1, r), TestCurve.Mult(PK2, r), PK1, PK2, r
+}
+
+func TestSameBlindingProof(t *testing.T) {
+	_, CMTok1, CMTok2, PK1, PK2, r := sameBlindingTokens(t)
+
+	proof, err := NewSameBlindingProof(TestCurve, CMTok1, CMTok2, PK1, PK2, r)
+	if err != nil {
+		t.Fatalf("SameBlindingProof failed to generate: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CMTok1, CMTok2, PK1, PK2); !ok || err != nil {
+		t.Fatalf("SameBlindingProof failed to verify: %v\n", err)
+	}
+
+	// one auditor twice has the same token twice
+	proof, err = NewSameBlindingProof(TestCurve, CMTok1, CMTok1, PK1, PK1, r)
+	if err != nil {
+		t.Fatalf("SameBlindingProof failed to generate for PK1 == PK2: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CMTok1, CMTok1, PK1, PK1); !ok || err != nil {
+		t.Fatalf("SameBlindingProof failed to verify for PK1 == PK2: %v\n", err)
+	}
+	if !proof.T1.Equal(proof.T2) {
+		t.Fatalf("SameBlindingProof for PK1 == PK2 has T1 != T2\n")
+	}
+}
+
+func TestSameBlindingProofSwapped(t *testing.T) {
+	_, CMTok1, CMTok2, PK1, PK2, r := sameBlindingTokens(t)
+	proof, err := NewSameBlindingProof(TestCurve, CMTok1, CMTok2, PK1, PK2, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	// the tokens swapped, for the keys or with them, are a different statement
+	swapped := [][4]ECPoint{
+		{CMTok2, CMTok1, PK1, PK2},
+		{CMTok2, CMTok1, PK2, PK1},
+		{CMTok1, CMTok2, PK2, PK1},
+	}
+	for i, s := range swapped {
+		if ok, err := proof.Verify(TestCurve, s[0], s[1], s[2], s[3]); ok || !errors.Is(err, ErrChallengeMismatch) {
+			t.Fatalf("SameBlindingProof verified for swapped statement %d: %v\n", i, err)
+		}
+	}
+	// and they can not be proved with r for the keys in the order they were
+	if _, err := NewSameBlindingProof(TestCurve, CMTok2, CMTok1, PK1, PK2, r); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("SameBlindingProof generated for swapped tokens: %v\n", err)
+	}
+
+	// a token with another r for the second auditor
+	_, r2, _ := PedCommit(TestCurve, big.NewInt(42))
+	other := TestCurve.Mult(PK2, r2)
+	if _, err := NewSameBlindingProof(TestCurve, CMTok1, other, PK1, PK2, r); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("SameBlindingProof generated for tokens of two r: %v\n", err)
+	}
+	if ok, _ := proof.Verify(TestCurve, CMTok1, other, PK1, PK2); ok {
+		t.Fatalf("SameBlindingProof verified for tokens of two r\n")
+	}
+	if _, err := NewSameBlindingProof(TestCurve, TestCurve.Mult(PK1, r2), CMTok2, PK1, PK2, r); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("SameBlindingProof generated for a wrong CMTok1: %v\n", err)
+	}
+}
+
+func TestSameBlindingProofInputs(t *testing.T) {
+	_, CMTok1, CMTok2, PK1, PK2, r := sameBlindingTokens(t)
+
+	if _, err := NewSameBlindingProof(TestCurve, Zero, Zero, PK1, PK2, big.NewInt(0)); !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("SameBlindingProof generated for r = 0: %v\n", err)
+	}
+	if _, err := NewSameBlindingProof(TestCurve, Zero, CMTok2, Zero, PK2, r); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("SameBlindingProof generated for PK1 = Zero: %v\n", err)
+	}
+
+	proof, err := NewSameBlindingProof(TestCurve, CMTok1, CMTok2, PK1, PK2, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, Zero, CMTok2, Zero, PK2); ok || !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("SameBlindingProof verified for PK1 = Zero: %v\n", err)
+	}
+	if ok, err := (*SameBlindingProof)(nil).Verify(TestCurve, CMTok1, CMTok2, PK1, PK2); ok || !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("nil SameBlindingProof gives %v\n", err)
+	}
+
+	bad := proof.Clone()
+	bad.S = new(big.Int).Add(bad.S, big.NewInt(1))
+	var pe *ProofError
+	if ok, err := bad.Verify(TestCurve, CMTok1, CMTok2, PK1, PK2); ok || !errors.As(err, &pe) || pe.Equation != 1 {
+		t.Fatalf("SameBlindingProof with a wrong S gives %v\n", err)
+	}
+}
+
+func TestSameBlindingProofWithMessage(t *testing.T) {
+	CM, CMTok1, CMTok2, PK1, PK2, r := sameBlindingTokens(t)
+	msg, err := CM.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	proof, err := NewSameBlindingProofWithMessage(TestCurve, CMTok1, CMTok2, PK1, PK2, r, msg)
+	if err != nil {
+		t.Fatalf("SameBlindingProof failed to generate with message: %v\n", err)
+	}
+	if ok, err := proof.VerifyWithMessage(TestCurve, CMTok1, CMTok2, PK1, PK2, msg); !ok || err != nil {
+		t.Fatalf("SameBlindingProof failed to verify with message: %v\n", err)
+	}
+
+	// the proof is for the tokens of CM and no other commitment
+	otherCM, _ := TestCurve.Add(CM, TestCurve.G).MarshalBinary()
+	if ok, err := proof.VerifyWithMessage(TestCurve, CMTok1, CMTok2, PK1, PK2, otherCM); ok || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("SameBlindingProof verified for another commitment: %v\n", err)
+	}
+	if ok, _ := proof.Verify(TestCurve, CMTok1, CMTok2, PK1, PK2); ok {
+		t.Fatalf("SameBlindingProof with message verified without it\n")
+	}
+
+	// a nil message is no message
+	proof, err = NewSameBlindingProofWithMessage(TestCurve, CMTok1, CMTok2, PK1, PK2, r, nil)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CMTok1, CMTok2, PK1, PK2); !ok || err != nil {
+		t.Fatalf("SameBlindingProof with nil message failed to verify: %v\n", err)
+	}
+}
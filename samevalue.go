@@ -0,0 +1,234 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// SameValueProof is a proof that two commitments CM1 = vG + r1H and
+// CM2 = vG + r2H commit to the same value v with different randomness, e.g. the
+// commitments of a sender and a receiver to one amount. It is a Schnorr proof
+// of knowledge of r1 - r2 with CM1 - CM2 = (r1 - r2)H, which only exists if the
+// G parts of CM1 and CM2 cancel out. Neither v nor r1 and r2 are revealed.
+//
+//  Public: generator points G and H, CM1, CM2
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows v, r1, r2 with                D = CM1 - CM2
+//  CM1 = vG + r1H, CM2 = vG + r2H
+//  selects random u
+//  T = uH
+//  c = HASH(G, H, CM1, CM2, T)
+//  s = u + c * (r1 - r2)
+//
+//  T, c, s --------------------------->
+//                                      c ?= HASH(G, H, CM1, CM2, T)
+//                                      sH ?= T + cD
+type SameValueProof struct {
+	T         ECPoint  // T = uH
+	Challenge *big.Int // c = HASH(G, H, CM1, CM2, T)
+	S         *big.Int // s = u + c * (r1 - r2)
+}
+
+// sameValueChallenge computes c = HASH(G, H, CM1, CM2, T)
+func sameValueChallenge(zkpcp ZKPCurveParams, CM1, CM2, T ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "SameValueProof")
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendPoint("CM1", CM1)
+	t.AppendPoint("CM2", CM2)
+	t.AppendPoint("T", T)
+	return t.ChallengeScalar("c")
+}
+
+// NewSameValueProof generates a proof that CM1 = vG + r1H and CM2 = vG + r2H
+// commit to the same value v. It fails if CM1 or CM2 does not open to v with
+// its randomness.
+func NewSameValueProof(zkpcp ZKPCurveParams, CM1, CM2 ECPoint, v, r1, r2 *big.Int) (*SameValueProof, error) {
+	return NewSameValueProofWithRand(zkpcp, entropy(), CM1, CM2, v, r1, r2)
+}
+
+// NewSameValueProofWithRand is the same as NewSameValueProof, but reads its
+// random value from rnd instead of the EntropySource
+func NewSameValueProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	CM1, CM2 ECPoint, v, r1, r2 *big.Int) (*SameValueProof, error) {
+
+	N := zkpcp.C.Params().N
+
+	if err := zkpcp.checkScalars("SameValueProve", "v, r1, r2", v, r1, r2); err != nil {
+		return nil, err
+	}
+	if !zkpcp.validPoints(CM1, CM2) {
+		return nil, curveError("SameValueProve")
+	}
+	if !PedCommitR(zkpcp, v, r1).Equal(CM1) {
+		return nil, inputError("SameValueProve", "CM1 is not vG + r1H")
+	}
+	if !PedCommitR(zkpcp, v, r2).Equal(CM2) {
+		return nil, inputError("SameValueProve", "CM2 is not vG + r2H")
+	}
+
+	u, err := zkpcp.randScalar(rnd)
+	if err != nil {
+		return nil, err
+	}
+
+	T := zkpcp.Mult(zkpcp.H, u)
+	c := sameValueChallenge(zkpcp, CM1, CM2, T)
+
+	deltaR := new(big.Int).Sub(r1, r2)
+	s := new(big.Int).Add(u, new(big.Int).Mul(c, deltaR))
+	s.Mod(s, N)
+
+	return &SameValueProof{T, c, s}, nil
+}
+
+// Verify checks if SameValueProof proof shows that CM1 and CM2 commit to the
+// same value
+func (proof *SameValueProof) Verify(zkpcp ZKPCurveParams, CM1, CM2 ECPoint) (bool, error) {
+	if proof == nil {
+		return false, malformedError("SameValueProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if !zkpcp.validPoints(CM1, CM2) || !zkpcp.onCurve(proof.T) {
+		return false, curveError("SameValueProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("SameValueProof.Verify", "Challenge, S", proof.Challenge, proof.S); err != nil {
+		return false, err
+	}
+
+	c := sameValueChallenge(zkpcp, CM1, CM2, proof.T)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("SameValueProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	// sH ?= T + cD, checked as sH - cD ?= T
+	lhs := zkpcp.MultiMult([]ECPoint{zkpcp.H, zkpcp.Sub(CM1, CM2)}, []*big.Int{proof.S, new(big.Int).Neg(c)})
+	if !pointsEqual(lhs, proof.T) {
+		return false, equationError("SameValueProof.Verify", 1, "sH - cD != T", lhs, proof.T)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are CM1 and CM2 like for Verify
+func (proof *SameValueProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("SameValueProof.VerifyArgs", args)
+	CM1, CM2 := a.point(), a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CM1, CM2)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *SameValueProof) String() string {
+	if proof == nil {
+		return "SameValueProof(nil)"
+	}
+	return proofString("SameValueProof", "T", proof.T, "Challenge", proof.Challenge, "S", proof.S)
+}
+
+// sameValueProofLen is the length of a serialized SameValueProof
+var sameValueProofLen = 1 + fixedPointLen + 2*fixedScalarLen
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// SameValueProof proof
+func (proof *SameValueProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.T)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.S)
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of SameValueProof proof, which has a fixed size
+func (proof *SameValueProof) Size() int {
+	return sameValueProofLen
+}
+
+// Clone returns a deep copy of SameValueProof proof that shares no big.Int with it
+func (proof *SameValueProof) Clone() *SameValueProof {
+	if proof == nil {
+		return nil
+	}
+	return &SameValueProof{
+		T:         clonePoint(proof.T),
+		Challenge: cloneScalar(proof.Challenge),
+		S:         cloneScalar(proof.S),
+	}
+}
+
+// Equal returns true if SameValueProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *SameValueProof) Equal(other *SameValueProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.T, other.T) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.S, other.S)
+}
+
+// NewSameValueProofFromBytes returns a SameValueProof generated from the
+// deserialization of byte slice b
+func NewSameValueProofFromBytes(b []byte) (*SameValueProof, error) {
+	if err := checkFixedHeader(b, sameValueProofLen, "SameValueProofFromBytes"); err != nil {
+		return nil, err
+	}
+	proof := new(SameValueProof)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.T, err = readFixedPoint(buf, "SameValueProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "SameValueProofFromBytes", &proof.Challenge, &proof.S); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+type sameValueProofJSON struct {
+	T         string `json:"t"`
+	Challenge string `json:"challenge"`
+	S         string `json:"s"`
+}
+
+// MarshalJSON encodes SameValueProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *SameValueProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sameValueProofJSON{
+		T:         pointToHex(proof.T),
+		Challenge: scalarToHex(proof.Challenge),
+		S:         scalarToHex(proof.S),
+	})
+}
+
+// UnmarshalJSON decodes a SameValueProof encoded with MarshalJSON. It fails if
+// a point is not on the curve or a scalar is not in [0, N)
+func (proof *SameValueProof) UnmarshalJSON(b []byte) error {
+	var aux sameValueProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := SameValueProof{}
+	if p.T, err = pointFromHex(aux.T, "t"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.S, err = scalarFromHex(aux.S, "s"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
@@ -0,0 +1,115 @@
+package zksigma
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestSameValueProof(t *testing.T) {
+	value := big.NewInt(1000)
+	CM1, r1, err := PedCommit(TestCurve, value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	CM2, r2, err := PedCommit(TestCurve, value)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	proof, err := NewSameValueProof(TestCurve, CM1, CM2, value, r1, r2)
+	if err != nil {
+		t.Fatalf("SameValueProof failed to generate: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CM1, CM2); !ok || err != nil {
+		t.Fatalf("SameValueProof failed to verify: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CM2, CM1); ok || err == nil {
+		t.Fatalf("SameValueProof verified with CM1 and CM2 swapped\n")
+	}
+
+	// with the same randomness the commitments are the same and D is Zero
+	proof, err = NewSameValueProof(TestCurve, CM1, CM1, value, r1, r1)
+	if err != nil {
+		t.Fatalf("SameValueProof failed to generate for CM1 == CM2: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CM1, CM1); !ok || err != nil {
+		t.Fatalf("SameValueProof failed to verify for CM1 == CM2: %v\n", err)
+	}
+}
+
+func TestSameValueProofZero(t *testing.T) {
+	// with v = 0 both commitments are multiples of H
+	zero := big.NewInt(0)
+	CM1, r1, _ := PedCommit(TestCurve, zero)
+	CM2, r2, _ := PedCommit(TestCurve, zero)
+	proof, err := NewSameValueProof(TestCurve, CM1, CM2, zero, r1, r2)
+	if err != nil {
+		t.Fatalf("SameValueProof failed to generate for v = 0: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CM1, CM2); !ok || err != nil {
+		t.Fatalf("SameValueProof failed to verify for v = 0: %v\n", err)
+	}
+	one := PedCommitR(TestCurve, big.NewInt(1), r2)
+	if ok, _ := proof.Verify(TestCurve, CM1, one); ok {
+		t.Fatalf("SameValueProof for v = 0 verified against a commitment to 1\n")
+	}
+	if _, err := NewSameValueProof(TestCurve, CM1, one, zero, r1, r2); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("SameValueProof generated for 0 and 1: %v\n", err)
+	}
+}
+
+func TestSameValueProofDifferentValues(t *testing.T) {
+	value := big.NewInt(1000)
+	CM1, r1, _ := PedCommit(TestCurve, value)
+	CM2, r2, _ := PedCommit(TestCurve, big.NewInt(1001))
+
+	for _, v := range []*big.Int{value, big.NewInt(1001)} {
+		if _, err := NewSameValueProof(TestCurve, CM1, CM2, v, r1, r2); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("SameValueProof for different values with v = %v gives %v\n", v, err)
+		}
+	}
+
+	// a proof for CM1 and a commitment to the same value does not carry over
+	same, rSame, _ := PedCommit(TestCurve, value)
+	proof, err := NewSameValueProof(TestCurve, CM1, same, value, r1, rSame)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CM1, CM2); ok || err == nil {
+		t.Fatalf("SameValueProof verified for commitments to different values\n")
+	}
+
+	// with a challenge computed for CM2 only the equation gives it away
+	forged := *proof
+	forged.Challenge = sameValueChallenge(TestCurve, CM1, CM2, proof.T)
+	if ok, err := forged.Verify(TestCurve, CM1, CM2); ok || !errors.Is(err, ErrEquationFailed) {
+		t.Fatalf("forged SameValueProof gives %v\n", err)
+	}
+
+	var nilProof *SameValueProof
+	if ok, err := nilProof.Verify(TestCurve, CM1, same); ok || !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("nil SameValueProof verified: %v\n", err)
+	}
+}
+
+func BenchmarkSameValueProve(b *testing.B) {
+	value := big.NewInt(5)
+	CM1, r1, _ := PedCommit(TestCurve, value)
+	CM2, r2, _ := PedCommit(TestCurve, value)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewSameValueProof(TestCurve, CM1, CM2, value, r1, r2)
+	}
+}
+
+func BenchmarkSameValueVerify(b *testing.B) {
+	value := big.NewInt(5)
+	CM1, r1, _ := PedCommit(TestCurve, value)
+	CM2, r2, _ := PedCommit(TestCurve, value)
+	proof, _ := NewSameValueProof(TestCurve, CM1, CM2, value, r1, r2)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CM1, CM2)
+	}
+}
@@ -0,0 +1,110 @@
+package zksigma
+
+import (
+	"math/big"
+)
+
+// VerifierSession verifies many proofs about the same commitment CM and token
+// CMTok, e.g. all proofs of one account on an audit server. It hashes the part
+// of the transcripts that only depends on CM and CMTok once, and on secp256k1
+// builds fixed base tables for CM and CMTok so multiplying them is as cheap as
+// multiplying G or H. Building the tables takes about as long as verifying a
+// few dozen proofs, so sessions only pay off if they are reused.
+//
+// A VerifierSession is never changed after NewVerifierSession returns, so it
+// can be used from several goroutines at once.
+type VerifierSession struct {
+	zkpcp     ZKPCurveParams // with the tables of CM and CMTok
+	CM, CMTok ECPoint
+
+	abcPrefix         *Transcript // G, H, CM, CMTok
+	consistencyPrefix *Transcript // G, H, CM, CMTok
+	disjunctivePrefix *Transcript // CM, CMTok
+}
+
+// NewVerifierSession returns a VerifierSession for proofs about CM and CMTok
+func NewVerifierSession(zkpcp ZKPCurveParams, CM, CMTok ECPoint) (*VerifierSession, error) {
+	if !zkpcp.validPoints(CM, CMTok) {
+		return nil, curveError("NewVerifierSession")
+	}
+
+	s := &VerifierSession{
+		zkpcp:             zkpcp.withTables(CM, CMTok),
+		CM:                CM,
+		CMTok:             CMTok,
+		abcPrefix:         newTranscript(zkpcp, "ABCProof", false),
+		consistencyPrefix: newTranscript(zkpcp, "ConsistencyProof", false),
+		disjunctivePrefix: newTranscript(zkpcp, "DisjunctiveProof", false),
+	}
+	for _, t := range []*Transcript{s.abcPrefix, s.consistencyPrefix} {
+		t.AppendPoint("G", zkpcp.G)
+		t.AppendPoint("H", zkpcp.H)
+		t.AppendPoint("CM", CM)
+		t.AppendPoint("CMTok", CMTok)
+	}
+	s.disjunctivePrefix.AppendPoint("Base1", CM)
+	s.disjunctivePrefix.AppendPoint("Result1", CMTok)
+	return s, nil
+}
+
+// VerifyABC is the same as p.Verify(zkpcp, CM, CMTok, PK) for the CM and CMTok
+// of session s
+func (s *VerifierSession) VerifyABC(p *ABCProof, PK ECPoint) (bool, error) {
+	return p.verify(s.zkpcp, s.CM, s.CMTok, PK, nil, s, nil)
+}
+
+// VerifyConsistency is the same as p.Verify(zkpcp, CM, CMTok, PubKey) for the
+// CM and CMTok of session s
+func (s *VerifierSession) VerifyConsistency(p *ConsistencyProof, PubKey ECPoint) (bool, error) {
+	return p.verify(s.zkpcp, s.CM, s.CMTok, PubKey, nil, s, nil)
+}
+
+// VerifyDisjunctive is the same as p.Verify(zkpcp, CM, CMTok, Base2, Result2)
+// for the CM and CMTok of session s, so it checks that CMTok = xCM or
+// Result2 = xBase2
+func (s *VerifierSession) VerifyDisjunctive(p *DisjunctiveProof, Base2, Result2 ECPoint) (bool, error) {
+	return p.verify(s.zkpcp, s.CM, s.CMTok, Base2, Result2, nil, s, nil)
+}
+
+// The challenge functions below compute the same challenges as abcChallenge,
+// consistencyChallenge and disjunctiveChallenge, but start from copies of the
+// prefixes of the session and only add the rest of the transcript. They can be
+// called on a nil session, with LegacyChallenges set, for a proof bound to a
+// message or with a trace tr, then the whole transcript is hashed.
+
+func (s *VerifierSession) abcChallenge(zkpcp ZKPCurveParams, CM, CMTok, B, C, T1, T2, CToken, T3 ECPoint, msg []byte, tr *VerifyTrace) *big.Int {
+	if s == nil || LegacyChallenges || len(msg) != 0 || tr != nil {
+		return tr.challenge(abcTranscript(zkpcp, CM, CMTok, B, C, T1, T2, CToken, T3, msg, tr))
+	}
+	t := s.abcPrefix.clone()
+	t.AppendPoint("B", B)
+	t.AppendPoint("C", C)
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	t.AppendPoint("CToken", CToken)
+	t.AppendPoint("T3", T3)
+	return t.ChallengeScalar("c")
+}
+
+func (s *VerifierSession) consistencyChallenge(zkpcp ZKPCurveParams, CM, CMTok, PubKey, T1, T2 ECPoint, msg []byte, tr *VerifyTrace) *big.Int {
+	if s == nil || LegacyChallenges || len(msg) != 0 || tr != nil {
+		return tr.challenge(consistencyTranscript(zkpcp, CM, CMTok, PubKey, T1, T2, msg, tr))
+	}
+	t := s.consistencyPrefix.clone()
+	t.AppendPoint("PubKey", PubKey)
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	return t.ChallengeScalar("c")
+}
+
+func (s *VerifierSession) disjunctiveChallenge(zkpcp ZKPCurveParams, Base1, Result1, Base2, Result2, T1, T2 ECPoint, msg []byte, tr *VerifyTrace) *big.Int {
+	if s == nil || LegacyChallenges || len(msg) != 0 || tr != nil || !Base1.Equal(s.CM) || !Result1.Equal(s.CMTok) {
+		return tr.challenge(disjunctiveTranscript(zkpcp, Base1, Result1, Base2, Result2, T1, T2, msg, tr))
+	}
+	t := s.disjunctivePrefix.clone()
+	t.AppendPoint("Base2", Base2)
+	t.AppendPoint("Result2", Result2)
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	return t.ChallengeScalar("c")
+}
@@ -0,0 +1,194 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+// makeSessionProofs returns n ABCProofs, a ConsistencyProof and a
+// DisjunctiveProof that are all about the same CM and CMTok
+func makeSessionProofs(tb testing.TB, zkpcp ZKPCurveParams, n int) (CM, CMTok, PK ECPoint,
+	abcs []*ABCProof, con *ConsistencyProof, dj *DisjunctiveProof) {
+
+	sk, _ := rand.Int(rand.Reader, zkpcp.C.Params().N)
+	PK = zkpcp.Mult(zkpcp.H, sk)
+	value := big.NewInt(1000)
+	CM, r, err := PedCommit(zkpcp, value)
+	if err != nil {
+		tb.Fatalf("%v\n", err)
+	}
+	CMTok = zkpcp.Mult(PK, r)
+
+	abcs = make([]*ABCProof, n)
+	for ii := range abcs {
+		if abcs[ii], err = NewABCProof(zkpcp, CM, CMTok, value, sk, Right); err != nil {
+			tb.Fatalf("%v\n", err)
+		}
+	}
+	if con, err = NewConsistencyProof(zkpcp, CM, CMTok, PK, value, r); err != nil {
+		tb.Fatalf("%v\n", err)
+	}
+	// CMTok = inv(sk)CM does not hold, but PK = skH does
+	if dj, err = NewDisjunctiveProof(zkpcp, CM, CMTok, zkpcp.H, PK, sk, Right); err != nil {
+		tb.Fatalf("%v\n", err)
+	}
+	return CM, CMTok, PK, abcs, con, dj
+}
+
+func TestVerifierSession(t *testing.T) {
+	for _, zkpcp := range []ZKPCurveParams{TestCurve, secp256k1Curve(t), P256Curve} {
+		CM, CMTok, PK, abcs, con, dj := makeSessionProofs(t, zkpcp, 2)
+		s, err := NewVerifierSession(zkpcp, CM, CMTok)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+
+		for _, p := range abcs {
+			if ok, err := s.VerifyABC(p, PK); !ok || err != nil {
+				t.Fatalf("VerifierSession rejected a valid ABCProof: %v\n", err)
+			}
+		}
+		if ok, err := s.VerifyConsistency(con, PK); !ok || err != nil {
+			t.Fatalf("VerifierSession rejected a valid ConsistencyProof: %v\n", err)
+		}
+		if ok, err := s.VerifyDisjunctive(dj, zkpcp.H, PK); !ok || err != nil {
+			t.Fatalf("VerifierSession rejected a valid DisjunctiveProof: %v\n", err)
+		}
+
+		// the session has to reject everything Verify rejects
+		bad := *abcs[0]
+		bad.l = new(big.Int).Add(bad.l, big.NewInt(1))
+		if ok, err := s.VerifyABC(&bad, PK); ok || err == nil {
+			t.Fatalf("VerifierSession accepted an ABCProof with a bad response\n")
+		}
+		badChallenge := *abcs[0]
+		badChallenge.Challenge = new(big.Int).Add(bad.Challenge, big.NewInt(1))
+		if ok, err := s.VerifyABC(&badChallenge, PK); ok || err == nil {
+			t.Fatalf("VerifierSession accepted an ABCProof with a bad challenge\n")
+		}
+		if ok, err := s.VerifyConsistency(con, zkpcp.H); ok || err == nil {
+			t.Fatalf("VerifierSession accepted a ConsistencyProof for the wrong PubKey\n")
+		}
+		if ok, err := s.VerifyDisjunctive(dj, zkpcp.G, PK); ok || err == nil {
+			t.Fatalf("VerifierSession accepted a DisjunctiveProof for the wrong Base2\n")
+		}
+		if ok, err := s.VerifyABC(nil, PK); ok || err == nil {
+			t.Fatalf("VerifierSession accepted a nil ABCProof\n")
+		}
+
+		// proofs about another CM do not verify in this session
+		otherCM, otherCMTok, otherPK, others, _, _ := makeSessionProofs(t, zkpcp, 1)
+		if ok, _ := others[0].Verify(zkpcp, otherCM, otherCMTok, otherPK); !ok {
+			t.Fatalf("ABCProof about another CM does not verify on its own\n")
+		}
+		if ok, err := s.VerifyABC(others[0], otherPK); ok || err == nil {
+			t.Fatalf("VerifierSession accepted an ABCProof about another CM\n")
+		}
+	}
+
+	if _, err := NewVerifierSession(TestCurve, ECPoint{big.NewInt(1), big.NewInt(2)}, Zero); err == nil {
+		t.Fatalf("NewVerifierSession accepted a point that is not on the curve\n")
+	}
+}
+
+// TestVerifierSessionChallenges checks that the cached prefixes give the same
+// challenges as hashing the whole transcript
+func TestVerifierSessionChallenges(t *testing.T) {
+	points := make([]ECPoint, 6)
+	for i := range points {
+		x, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+		points[i] = TestCurve.Mult(TestCurve.G, x)
+	}
+	CM, CMTok, PK, T1, T2, T3 := points[0], points[1], points[2], points[3], points[4], points[5]
+	s, err := NewVerifierSession(TestCurve, CM, CMTok)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	for _, session := range []*VerifierSession{s, nil} {
+		for _, msg := range [][]byte{nil, []byte("tx")} {
+			if c := session.abcChallenge(TestCurve, CM, CMTok, T1, T2, T3, PK, T1, T2, msg, nil); c.Cmp(abcChallenge(TestCurve, CM, CMTok, T1, T2, T3, PK, T1, T2, msg)) != 0 {
+				t.Fatalf("abcChallenge of the session differs\n")
+			}
+			if c := session.consistencyChallenge(TestCurve, CM, CMTok, PK, T1, T2, msg, nil); c.Cmp(
+				consistencyChallenge(TestCurve, CM, CMTok, PK, T1, T2, msg)) != 0 {
+				t.Fatalf("consistencyChallenge of the session differs\n")
+			}
+			for _, base1 := range []ECPoint{CM, T3} {
+				if c := session.disjunctiveChallenge(TestCurve, base1, CMTok, TestCurve.H, PK, T1, T2, msg, nil); c.Cmp(
+					disjunctiveChallenge(TestCurve, base1, CMTok, TestCurve.H, PK, T1, T2, msg)) != 0 {
+					t.Fatalf("disjunctiveChallenge of the session differs\n")
+				}
+			}
+		}
+	}
+
+	// the tables of the session multiply like ScalarMult does
+	x, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	for _, p := range []ECPoint{CM, CMTok} {
+		X, Y := TestCurve.C.ScalarMult(p.X, p.Y, x.Bytes())
+		if _, _, ok := s.zkpcp.tableFor(p); !ok {
+			t.Fatalf("VerifierSession has no table for %v\n", p)
+		}
+		if got := s.zkpcp.Mult(p, x); !got.Equal(ECPoint{X, Y}) {
+			t.Fatalf("Mult with the tables of the session gives %v, expected %v\n", got, ECPoint{X, Y})
+		}
+	}
+	if _, _, ok := TestCurve.tableFor(CM); ok {
+		t.Fatalf("NewVerifierSession changed the tables of TestCurve\n")
+	}
+}
+
+// TestVerifierSessionConcurrent verifies proofs from many goroutines sharing
+// one session. Run it with -race.
+func TestVerifierSessionConcurrent(t *testing.T) {
+	CM, CMTok, PK, abcs, con, dj := makeSessionProofs(t, TestCurve, 4)
+	s, err := NewVerifierSession(TestCurve, CM, CMTok)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(p *ABCProof) {
+			defer wg.Done()
+			if _, err := s.VerifyABC(p, PK); err != nil {
+				errs <- err
+			}
+			if _, err := s.VerifyConsistency(con, PK); err != nil {
+				errs <- err
+			}
+			if _, err := s.VerifyDisjunctive(dj, TestCurve.H, PK); err != nil {
+				errs <- err
+			}
+		}(abcs[i%len(abcs)])
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent VerifierSession failed: %v\n", err)
+	}
+}
+
+func BenchmarkVerifierSessionABC_1000(b *testing.B) {
+	CM, CMTok, PK, abcs, _, _ := makeSessionProofs(b, TestCurve, 1000)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		s, _ := NewVerifierSession(TestCurve, CM, CMTok)
+		for _, p := range abcs {
+			s.VerifyABC(p, PK)
+		}
+	}
+}
+
+func BenchmarkVerifyABCShared_1000(b *testing.B) {
+	CM, CMTok, PK, abcs, _, _ := makeSessionProofs(b, TestCurve, 1000)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		for _, p := range abcs {
+			p.Verify(TestCurve, CM, CMTok, PK)
+		}
+	}
+}
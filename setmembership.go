@@ -0,0 +1,231 @@
+package zksigma
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+)
+
+// SetMembershipProof is a proof that a commitment CM = vG + rH hides one of
+// the values s_1, ..., s_n of a public set, without revealing which. It is a
+// OneOfManyProof that one of CM - s_iG is a commitment to zero, i.e. rH, with
+// a challenge that hashes CM and the whole set, so neither can be swapped for
+// another one the branches happen to fit.
+//
+//  Public: generator points G and H, CM, set s_1 < ... < s_n
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows v = s_k and r with
+//  CM = vG + rH
+//  D_i = CM - s_iG                     D_i = CM - s_iG
+//  OneOfManyProof of r with D_k = rH,
+//  bases H, results D_i and
+//  c = HASH(G, H, CM, s_1, ..., s_n, T_1, ..., T_n)
+//
+//  OneOfManyProof -------------------->
+//                                      OneOfManyProof ?= true for H, D_i and
+//                                      c = HASH(G, H, CM, s_1, ..., s_n, T_1, ..., T_n)
+//
+// The set is sorted before it is used, so the order it is passed in does not
+// matter, and a set with a value twice is rejected.
+type SetMembershipProof struct {
+	Branches *OneOfManyProof // one branch per value of the sorted set
+}
+
+// sortedSet returns a sorted copy of set, which has to be a non-empty set of
+// scalars without duplicates
+func (zkpcp ZKPCurveParams) sortedSet(t string, set []*big.Int) ([]*big.Int, error) {
+	if len(set) == 0 {
+		return nil, inputError(t, "empty set")
+	}
+	for i, s := range set {
+		if err := zkpcp.checkScalars(t, fmt.Sprintf("set[%d]", i), s); err != nil {
+			return nil, err
+		}
+	}
+	sorted := append([]*big.Int(nil), set...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Cmp(sorted[i-1]) == 0 {
+			return nil, inputError(t, fmt.Sprintf("set has %v twice", sorted[i]))
+		}
+	}
+	return sorted, nil
+}
+
+// setMembershipBranches returns the bases H and results D_i = CM - s_iG of the
+// branches for CM and the sorted set
+func setMembershipBranches(zkpcp ZKPCurveParams, CM ECPoint, sorted []*big.Int) ([]ECPoint, []ECPoint) {
+	bases := make([]ECPoint, len(sorted))
+	results := make([]ECPoint, len(sorted))
+	for i, s := range sorted {
+		bases[i] = zkpcp.H
+		results[i] = zkpcp.Sub(CM, zkpcp.Mult(zkpcp.G, s))
+	}
+	return bases, results
+}
+
+// setMembershipChallenge computes c = HASH(G, H, CM, s_1, ..., s_n, T_1, ..., T_n)
+// for the sorted set
+func setMembershipChallenge(zkpcp ZKPCurveParams, CM ECPoint, sorted []*big.Int, T []ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "SetMembershipProof")
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendPoint("CM", CM)
+	for _, s := range sorted {
+		t.AppendScalar("s", s)
+	}
+	for _, Ti := range T {
+		t.AppendPoint("T", Ti)
+	}
+	return t.ChallengeScalar("c")
+}
+
+// NewSetMembershipProof generates a proof that CM = value*G + r*H commits to
+// one of the values of set. It fails if value is not in set, if CM does not
+// open to value with r or if set has a value twice.
+func NewSetMembershipProof(zkpcp ZKPCurveParams, CM ECPoint, value, r *big.Int, set []*big.Int) (*SetMembershipProof, error) {
+	return NewSetMembershipProofWithRand(zkpcp, entropy(), CM, value, r, set)
+}
+
+// NewSetMembershipProofWithRand is the same as NewSetMembershipProof, but
+// reads its random values from rnd instead of the EntropySource
+func NewSetMembershipProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	CM ECPoint, value, r *big.Int, set []*big.Int) (*SetMembershipProof, error) {
+
+	if err := zkpcp.checkScalars("SetMembershipProve", "value, r", value, r); err != nil {
+		return nil, err
+	}
+	sorted, err := zkpcp.sortedSet("SetMembershipProve", set)
+	if err != nil {
+		return nil, err
+	}
+	if !zkpcp.validPoints(CM) {
+		return nil, curveError("SetMembershipProve")
+	}
+	if !CM.Equal(PedCommitR(zkpcp, value, r)) {
+		return nil, inputError("SetMembershipProve", "value and randomness do not produce CM")
+	}
+	k := sort.Search(len(sorted), func(i int) bool { return sorted[i].Cmp(value) >= 0 })
+	if k == len(sorted) || sorted[k].Cmp(value) != 0 {
+		return nil, inputError("SetMembershipProve", "value is not in the set")
+	}
+
+	bases, results := setMembershipBranches(zkpcp, CM, sorted)
+	challenge := func(T []ECPoint) *big.Int { return setMembershipChallenge(zkpcp, CM, sorted, T) }
+	branches, err := proveOneOfMany(zkpcp, rnd, "SetMembershipProve", bases, results, k, r, challenge)
+	if err != nil {
+		return nil, err
+	}
+	return &SetMembershipProof{branches}, nil
+}
+
+// Verify checks if SetMembershipProof proof shows that CM commits to one of the
+// values of set, in any order
+func (proof *SetMembershipProof) Verify(zkpcp ZKPCurveParams, CM ECPoint, set []*big.Int) (bool, error) {
+	if proof == nil || proof.Branches == nil {
+		return false, malformedError("SetMembershipProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	sorted, err := zkpcp.sortedSet("SetMembershipProof.Verify", set)
+	if err != nil {
+		return false, err
+	}
+	if !zkpcp.validPoints(CM) {
+		return false, curveError("SetMembershipProof.Verify")
+	}
+
+	bases, results := setMembershipBranches(zkpcp, CM, sorted)
+	return proof.Branches.verify(zkpcp, "SetMembershipProof.Verify", bases, results, func(T []ECPoint) *big.Int {
+		return setMembershipChallenge(zkpcp, CM, sorted, T)
+	})
+}
+
+// VerifyArgs implements Verifiable, args are CM and set as []*big.Int like for
+// Verify
+func (proof *SetMembershipProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("SetMembershipProof.VerifyArgs", args)
+	CM, set := a.point(), a.scalars()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CM, set)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *SetMembershipProof) String() string {
+	if proof == nil {
+		return "SetMembershipProof(nil)"
+	}
+	return proofString("SetMembershipProof", "Branches", proof.Branches)
+}
+
+// Bytes returns a byte slice with a serialized representation of
+// SetMembershipProof proof, which is the one of its OneOfManyProof
+func (proof *SetMembershipProof) Bytes() []byte {
+	return proof.Branches.Bytes()
+}
+
+// Size returns the length of Bytes of SetMembershipProof proof without serializing it
+func (proof *SetMembershipProof) Size() int {
+	return proof.Branches.Size()
+}
+
+// Clone returns a deep copy of SetMembershipProof proof that shares no big.Int with it
+func (proof *SetMembershipProof) Clone() *SetMembershipProof {
+	if proof == nil {
+		return nil
+	}
+	return &SetMembershipProof{
+		Branches: proof.Branches.Clone(),
+	}
+}
+
+// Equal returns true if SetMembershipProof proof and other have the same values in all
+// their fields, nested proofs included. It is not constant time.
+func (proof *SetMembershipProof) Equal(other *SetMembershipProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return proof.Branches.Equal(other.Branches)
+}
+
+// NewSetMembershipProofFromBytes returns a SetMembershipProof generated from
+// the deserialization of byte slice b
+func NewSetMembershipProofFromBytes(b []byte) (*SetMembershipProof, error) {
+	branches, err := NewOneOfManyProofFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return &SetMembershipProof{branches}, nil
+}
+
+type setMembershipProofJSON struct {
+	Branches *OneOfManyProof `json:"branches"`
+}
+
+// MarshalJSON encodes SetMembershipProof proof as its OneOfManyProof Branches
+func (proof *SetMembershipProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(setMembershipProofJSON{
+		Branches: proof.Branches,
+	})
+}
+
+// UnmarshalJSON decodes a SetMembershipProof encoded with MarshalJSON. It
+// fails if a point is not on the curve, a scalar is not in [0, N) or a nested
+// proof is missing
+func (proof *SetMembershipProof) UnmarshalJSON(b []byte) error {
+	var aux setMembershipProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	if aux.Branches == nil {
+		return malformedError("UnmarshalJSON", "branches is missing")
+	}
+	p := SetMembershipProof{Branches: aux.Branches}
+	*proof = p
+	return nil
+}
@@ -0,0 +1,181 @@
+package zksigma
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// coins returns the values of set s as *big.Int
+func coins(s ...int64) []*big.Int {
+	set := make([]*big.Int, len(s))
+	for i, v := range s {
+		set[i] = big.NewInt(v)
+	}
+	return set
+}
+
+func TestSetMembershipProof(t *testing.T) {
+	set := coins(1, 5, 10, 50, 100)
+	for _, v := range set {
+		CM, r, err := PedCommit(TestCurve, v)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		proof, err := NewSetMembershipProof(TestCurve, CM, v, r, set)
+		if err != nil {
+			t.Fatalf("SetMembershipProof for %v failed to generate: %v\n", v, err)
+		}
+		if ok, err := proof.Verify(TestCurve, CM, set); !ok || err != nil {
+			t.Fatalf("SetMembershipProof for %v failed to verify: %v\n", v, err)
+		}
+		if len(proof.Branches.T) != len(set) {
+			t.Fatalf("SetMembershipProof for %v has %d branches\n", v, len(proof.Branches.T))
+		}
+
+		decoded, err := NewSetMembershipProofFromBytes(proof.Bytes())
+		if err != nil {
+			t.Fatalf("SetMembershipProof failed to deserialize: %v\n", err)
+		}
+		if ok, err := decoded.Verify(TestCurve, CM, set); !ok || err != nil {
+			t.Fatalf("SetMembershipProof failed to verify after deserialization: %v\n", err)
+		}
+		if !bytes.Equal(decoded.Bytes(), proof.Bytes()) || proof.Size() != len(proof.Bytes()) {
+			t.Fatalf("SetMembershipProof produced an unstable encoding\n")
+		}
+
+		// a commitment to the same value with other randomness is another statement
+		other := PedCommitR(TestCurve, v, new(big.Int).Add(r, big.NewInt(1)))
+		if ok, _ := proof.Verify(TestCurve, other, set); ok {
+			t.Fatalf("SetMembershipProof verified for another commitment\n")
+		}
+	}
+
+	// a singleton set is an OpenToValueProof in disguise
+	CM, r, err := PedCommit(TestCurve, big.NewInt(50))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	proof, err := NewSetMembershipProof(TestCurve, CM, big.NewInt(50), r, coins(50))
+	if err != nil {
+		t.Fatalf("SetMembershipProof for a singleton set failed to generate: %v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, CM, coins(50)); !ok || err != nil {
+		t.Fatalf("SetMembershipProof for a singleton set failed to verify: %v\n", err)
+	}
+	if ok, _ := proof.Verify(TestCurve, CM, coins(5)); ok {
+		t.Fatalf("SetMembershipProof for {50} verified for {5}\n")
+	}
+}
+
+func TestSetMembershipProofNotInSet(t *testing.T) {
+	set := coins(1, 5, 10, 50, 100)
+	CM, r, err := PedCommit(TestCurve, big.NewInt(20))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if _, err := NewSetMembershipProof(TestCurve, CM, big.NewInt(20), r, set); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("SetMembershipProof for a value not in the set gives %v\n", err)
+	}
+	// nor can the prover claim a value of the set that CM does not hide
+	if _, err := NewSetMembershipProof(TestCurve, CM, big.NewInt(10), r, set); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("SetMembershipProof for a value CM does not hide gives %v\n", err)
+	}
+
+	// a proof for the set does not carry over to a set without the value
+	CM, r, err = PedCommit(TestCurve, big.NewInt(10))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	proof, err := NewSetMembershipProof(TestCurve, CM, big.NewInt(10), r, set)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	for _, other := range [][]*big.Int{coins(1, 5, 11, 50, 100), coins(1, 5, 50, 100), coins(1, 5, 10, 50, 100, 500)} {
+		if ok, _ := proof.Verify(TestCurve, CM, other); ok {
+			t.Fatalf("SetMembershipProof for %v verified for %v\n", set, other)
+		}
+	}
+
+	bad := map[string][]*big.Int{
+		"no values":   nil,
+		"a duplicate": coins(1, 5, 10, 5),
+	}
+	for name, set := range bad {
+		if _, err := NewSetMembershipProof(TestCurve, CM, big.NewInt(10), r, set); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("SetMembershipProof with %s gives %v\n", name, err)
+		}
+		if ok, err := proof.Verify(TestCurve, CM, set); ok || !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("SetMembershipProof verified with %s gives %v\n", name, err)
+		}
+	}
+	if _, err := NewSetMembershipProof(TestCurve, CM, big.NewInt(10), r, coins(10, -1)); !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("SetMembershipProof with a negative value in the set gives %v\n", err)
+	}
+}
+
+func TestSetMembershipProofOrder(t *testing.T) {
+	// the set is sorted, its order does not matter
+	CM, r, err := PedCommit(TestCurve, big.NewInt(5))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	set := coins(100, 1, 50, 5, 10)
+	proof, err := NewSetMembershipProof(TestCurve, CM, big.NewInt(5), r, set)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	for _, reordered := range [][]*big.Int{set, coins(1, 5, 10, 50, 100), coins(10, 50, 100, 5, 1)} {
+		if ok, err := proof.Verify(TestCurve, CM, reordered); !ok || err != nil {
+			t.Fatalf("SetMembershipProof does not verify for %v: %v\n", reordered, err)
+		}
+	}
+	if set[0].Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("NewSetMembershipProof reordered the set of the caller\n")
+	}
+
+	// the branches are those of the sorted set, so swapping two is detected
+	q := proof.Clone()
+	q.Branches.T[0], q.Branches.T[1] = q.Branches.T[1], q.Branches.T[0]
+	q.Branches.Cs[0], q.Branches.Cs[1] = q.Branches.Cs[1], q.Branches.Cs[0]
+	q.Branches.S[0], q.Branches.S[1] = q.Branches.S[1], q.Branches.S[0]
+	if ok, _ := q.Verify(TestCurve, CM, set); ok {
+		t.Fatalf("SetMembershipProof verified with swapped branches\n")
+	}
+
+	// nor is it a OneOfManyProof for the same branches, whose challenge has
+	// no set in it
+	bases, results := denominations(TestCurve, CM, coins(1, 5, 10, 50, 100))
+	if ok, err := proof.Branches.Verify(TestCurve, bases, results); ok || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("the branches of a SetMembershipProof verify as a OneOfManyProof: %v\n", err)
+	}
+}
+
+func BenchmarkSetMembershipProve(b *testing.B) {
+	set := coins(1, 5, 10, 50, 100)
+	CM, r, err := PedCommit(TestCurve, big.NewInt(10))
+	if err != nil {
+		b.Fatalf("%v\n", err)
+	}
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewSetMembershipProof(TestCurve, CM, big.NewInt(10), r, set)
+	}
+}
+
+func BenchmarkSetMembershipVerify(b *testing.B) {
+	set := coins(1, 5, 10, 50, 100)
+	CM, r, err := PedCommit(TestCurve, big.NewInt(10))
+	if err != nil {
+		b.Fatalf("%v\n", err)
+	}
+	proof, err := NewSetMembershipProof(TestCurve, CM, big.NewInt(10), r, set)
+	if err != nil {
+		b.Fatalf("%v\n", err)
+	}
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CM, set)
+	}
+}
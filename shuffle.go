@@ -0,0 +1,663 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/mit-dci/zksigma/wire"
+)
+
+// ShuffleProof is a proof that outputs B_1, ..., B_n are inputs A_1, ..., A_n
+// in another order, each re-randomized with B_i = A_pi(i) + r_iH, without
+// revealing the permutation pi or the r_i. It is the proof of a shuffle of
+// Terelius and Wikstrom for Pedersen commitments instead of ciphertexts.
+//
+// The prover commits to the permutation matrix column by column, c_pi(i) =
+// rho_pi(i)H + h_i, with generators H_0, h_1, ..., h_n derived from the curve
+// that nobody knows the discrete logs of. The verifier checks that the c_k
+// add up to the sum of the h_i and that sum(u_kc_k) commits to a permutation
+// of the random challenges u_k, which is the case if the product of its
+// entries is prod(u_k). The chain CHat_i = rhoHat_iH + u'_iCHat_(i-1) from
+// CHat_0 = H_0 proves that product, and the same u'_i = u_pi(i) relate the
+// inputs and outputs: sum(u_kA_k) = sum(u'_iB_i) - r'H.
+//
+//  Public: points A_1, ..., A_n and B_1, ..., B_n
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows pi and r_i with B_i = A_pi(i) + r_iH
+//  selects random rho_k
+//  c_pi(i) = rho_pi(i)H + h_i
+//  u_1, ..., u_n = HASH(A_k, B_k, c_k)
+//  u'_i = u_pi(i)
+//  selects random rhoHat_i
+//  CHat_i = rhoHat_iH + u'_iCHat_(i-1)
+//  selects random w_1, ..., w_4, wHat_i, w'_i
+//  T_1 = w_1H
+//  T_2 = w_2H
+//  T_3 = w_3H + sum(w'_ih_i)
+//  T_4 = sum(w'_iB_i) - w_4H
+//  THat_i = wHat_iH + w'_iCHat_(i-1)
+//  c = HASH(u_1, ..., u_n, CHat_i, T_1, ..., T_4, THat_i)
+//  s_1 = w_1 - c * sum(rho_k)
+//  s_2 = w_2 - c * sum(rhoHat_i * prod(u'_j for j > i))
+//  s_3 = w_3 - c * sum(rho_ku_k)
+//  s_4 = w_4 - c * sum(u'_ir_i)
+//  sHat_i = wHat_i - c * rhoHat_i
+//  s'_i = w'_i - c * u'_i
+//
+//  c_k, CHat_i, T_1, ..., T_4, THat_i,
+//  c, s_1, ..., s_4, sHat_i, s'_i ------->
+//                                      u_1, ..., u_n = HASH(A_k, B_k, c_k)
+//                                      c ?= HASH(u_1, ..., u_n, CHat_i, T_1, ..., T_4, THat_i)
+//                                      T_1 ?= c(sum(c_k) - sum(h_i)) + s_1H
+//                                      T_2 ?= c(CHat_n - prod(u_k)H_0) + s_2H
+//                                      T_3 ?= c*sum(u_kc_k) + s_3H + sum(s'_ih_i)
+//                                      T_4 ?= c*sum(u_kA_k) - s_4H + sum(s'_iB_i)
+//                                      THat_i ?= cCHat_i + sHat_iH + s'_iCHat_(i-1) for all i
+//
+// The proof is three points and two scalars per commitment, plus four points
+// and five scalars. The u_k are drawn one after the other from the same
+// Transcript, so ShuffleProof ignores LegacyChallenges, which would make them
+// all the same.
+type ShuffleProof struct {
+	C         []ECPoint  // c_k, the commitment to the permutation
+	CHat      []ECPoint  // CHat_i, the chain with CHat_n = prod(u_k)H_0 + rhoHat*H
+	T1        ECPoint    // T_1 = w_1H
+	T2        ECPoint    // T_2 = w_2H
+	T3        ECPoint    // T_3 = w_3H + sum(w'_ih_i)
+	T4        ECPoint    // T_4 = sum(w'_iB_i) - w_4H
+	THat      []ECPoint  // THat_i = wHat_iH + w'_iCHat_(i-1)
+	Challenge *big.Int   // c = HASH(u_1, ..., u_n, CHat_i, T_1, ..., T_4, THat_i)
+	S1        *big.Int   // s_1 = w_1 - c * sum(rho_k)
+	S2        *big.Int   // s_2 = w_2 - c * sum(rhoHat_i * prod(u'_j for j > i))
+	S3        *big.Int   // s_3 = w_3 - c * sum(rho_ku_k)
+	S4        *big.Int   // s_4 = w_4 - c * sum(u'_ir_i)
+	SHat      []*big.Int // sHat_i = wHat_i - c * rhoHat_i
+	SPrime    []*big.Int // s'_i = w'_i - c * u'_i
+}
+
+// shuffleGenerators returns H_0 and h_1, ..., h_n of ShuffleProof, the points
+// hashToPoint gives for "zksigma ShuffleProof" and the 4 byte big-endian index
+func shuffleGenerators(zkpcp ZKPCurveParams, n int) (ECPoint, []ECPoint, error) {
+	gens, err := hashGenerators(zkpcp.C, "zksigma ShuffleProof", 0, n+1)
+	if err != nil {
+		return Zero, nil, wrapError("ShuffleProof", "no generator found", err)
+	}
+	return gens[0], gens[1:], nil
+}
+
+// shuffleTranscript starts the Transcript of a ShuffleProof and draws
+// u_1, ..., u_n = HASH(A_k, B_k, c_k) from it
+func shuffleTranscript(zkpcp ZKPCurveParams, inputs, outputs, C []ECPoint) (*Transcript, []*big.Int) {
+	t := newTranscript(zkpcp, "ShuffleProof", false)
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	for i := range inputs {
+		t.AppendPoint("A", inputs[i])
+		t.AppendPoint("B", outputs[i])
+	}
+	for _, ck := range C {
+		t.AppendPoint("c", ck)
+	}
+	u := make([]*big.Int, len(inputs))
+	for k := range u {
+		u[k] = t.ChallengeScalar("u")
+	}
+	return t, u
+}
+
+// shuffleChallenge computes c = HASH(u_1, ..., u_n, CHat_i, T_1, ..., T_4, THat_i)
+// from t after the u_k are drawn
+func shuffleChallenge(t *Transcript, CHat []ECPoint, T1, T2, T3, T4 ECPoint, THat []ECPoint) *big.Int {
+	for _, p := range CHat {
+		t.AppendPoint("CHat", p)
+	}
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	t.AppendPoint("T3", T3)
+	t.AppendPoint("T4", T4)
+	for _, p := range THat {
+		t.AppendPoint("THat", p)
+	}
+	return t.ChallengeScalar("c")
+}
+
+// NewShuffleProof generates a proof that outputs[i] = inputs[perm[i]] +
+// deltaRs[i] * H for all i, e.g. after shuffling the inputs and putting each
+// through RerandomizeCommitment. The verifier learns neither perm nor deltaRs.
+func NewShuffleProof(zkpcp ZKPCurveParams, inputs, outputs []ECPoint, perm []int, deltaRs []*big.Int) (*ShuffleProof, error) {
+	return NewShuffleProofWithRand(zkpcp, entropy(), inputs, outputs, perm, deltaRs)
+}
+
+// NewShuffleProofWithRand is the same as NewShuffleProof, but reads its
+// random values from rnd instead of the EntropySource
+func NewShuffleProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	inputs, outputs []ECPoint, perm []int, deltaRs []*big.Int) (*ShuffleProof, error) {
+
+	N := zkpcp.C.Params().N
+	n := len(inputs)
+
+	if n == 0 {
+		return nil, inputError("ShuffleProve", "no commitments")
+	}
+	if len(outputs) != n || len(perm) != n || len(deltaRs) != n {
+		return nil, inputError("ShuffleProve", "inputs, outputs, perm and deltaRs must have the same length")
+	}
+	seen := make([]bool, n)
+	for _, k := range perm {
+		if k < 0 || k >= n || seen[k] {
+			return nil, inputError("ShuffleProve", fmt.Sprintf("perm is not a permutation of 0 to %d", n-1))
+		}
+		seen[k] = true
+	}
+	for i, r := range deltaRs {
+		if err := zkpcp.checkScalars("ShuffleProve", fmt.Sprintf("deltaRs[%d]", i), r); err != nil {
+			return nil, err
+		}
+	}
+	if !zkpcp.validPoints(inputs...) || !zkpcp.validPoints(outputs...) {
+		return nil, curveError("ShuffleProve")
+	}
+	for i := range outputs {
+		if !zkpcp.Add(inputs[perm[i]], zkpcp.Mult(zkpcp.H, deltaRs[i])).Equal(outputs[i]) {
+			return nil, inputError("ShuffleProve", fmt.Sprintf("outputs[%d] is not inputs[%d] + deltaRs[%d] * H", i, perm[i], i))
+		}
+	}
+
+	H0, h, err := shuffleGenerators(zkpcp, n)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &ShuffleProof{
+		C:      make([]ECPoint, n),
+		CHat:   make([]ECPoint, n),
+		THat:   make([]ECPoint, n),
+		SHat:   make([]*big.Int, n),
+		SPrime: make([]*big.Int, n),
+	}
+
+	// c_pi(i) = rho_pi(i)H + h_i
+	rho, err := zkpcp.randScalars(rnd, n)
+	if err != nil {
+		return nil, err
+	}
+	for i, k := range perm {
+		proof.C[k] = zkpcp.Add(zkpcp.Mult(zkpcp.H, rho[k]), h[i])
+	}
+
+	t, u := shuffleTranscript(zkpcp, inputs, outputs, proof.C)
+	uPrime := make([]*big.Int, n)
+	for i, k := range perm {
+		uPrime[i] = u[k]
+	}
+
+	// CHat_i = rhoHat_iH + u'_iCHat_(i-1)
+	rhoHat, err := zkpcp.randScalars(rnd, n)
+	if err != nil {
+		return nil, err
+	}
+	prev := H0
+	for i := range proof.CHat {
+		proof.CHat[i] = zkpcp.MultiMult([]ECPoint{zkpcp.H, prev}, []*big.Int{rhoHat[i], uPrime[i]})
+		prev = proof.CHat[i]
+	}
+
+	w, err := zkpcp.randScalars(rnd, 4)
+	if err != nil {
+		return nil, err
+	}
+	wHat, err := zkpcp.randScalars(rnd, n)
+	if err != nil {
+		return nil, err
+	}
+	wPrime, err := zkpcp.randScalars(rnd, n)
+	if err != nil {
+		return nil, err
+	}
+
+	proof.T1 = zkpcp.Mult(zkpcp.H, w[0])
+	proof.T2 = zkpcp.Mult(zkpcp.H, w[1])
+	proof.T3 = zkpcp.MultiMult(append([]ECPoint{zkpcp.H}, h...), append([]*big.Int{w[2]}, wPrime...))
+	proof.T4 = zkpcp.MultiMult(append([]ECPoint{zkpcp.H}, outputs...),
+		append([]*big.Int{new(big.Int).Neg(w[3])}, wPrime...))
+	prev = H0
+	for i := range proof.THat {
+		proof.THat[i] = zkpcp.MultiMult([]ECPoint{zkpcp.H, prev}, []*big.Int{wHat[i], wPrime[i]})
+		prev = proof.CHat[i]
+	}
+
+	proof.Challenge = shuffleChallenge(t, proof.CHat, proof.T1, proof.T2, proof.T3, proof.T4, proof.THat)
+	c := proof.Challenge
+
+	// the randomness of sum(c_k), CHat_n, sum(u_kc_k) and sum(u'_iB_i) - sum(u_kA_k)
+	rhoSum, rhoHatSum, rhoU, rPrime := new(big.Int), new(big.Int), new(big.Int), new(big.Int)
+	v := big.NewInt(1) // prod(u'_j for j > i)
+	for i := n - 1; i >= 0; i-- {
+		rhoHatSum.Add(rhoHatSum, new(big.Int).Mul(rhoHat[i], v))
+		v.Mul(v, uPrime[i])
+		v.Mod(v, N)
+	}
+	for k := range rho {
+		rhoSum.Add(rhoSum, rho[k])
+		rhoU.Add(rhoU, new(big.Int).Mul(rho[k], u[k]))
+		rPrime.Add(rPrime, new(big.Int).Mul(uPrime[k], deltaRs[k]))
+	}
+
+	// s = w - c * witness
+	respond := func(w, witness *big.Int) *big.Int {
+		s := new(big.Int).Sub(w, new(big.Int).Mul(c, witness))
+		return s.Mod(s, N)
+	}
+	proof.S1 = respond(w[0], rhoSum)
+	proof.S2 = respond(w[1], rhoHatSum)
+	proof.S3 = respond(w[2], rhoU)
+	proof.S4 = respond(w[3], rPrime)
+	for i := range proof.SHat {
+		proof.SHat[i] = respond(wHat[i], rhoHat[i])
+		proof.SPrime[i] = respond(wPrime[i], uPrime[i])
+	}
+
+	return proof, nil
+}
+
+// Verify checks if ShuffleProof proof shows that outputs are inputs in some
+// order, each re-randomized by adding a multiple of H
+func (proof *ShuffleProof) Verify(zkpcp ZKPCurveParams, inputs, outputs []ECPoint) (bool, error) {
+	if proof == nil {
+		return false, malformedError("ShuffleProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	n := len(inputs)
+	if n == 0 {
+		return false, inputError("ShuffleProof.Verify", "no commitments")
+	}
+	if len(outputs) != n {
+		return false, inputError("ShuffleProof.Verify", "inputs and outputs must have the same length")
+	}
+	if len(proof.C) != n || len(proof.CHat) != n || len(proof.THat) != n ||
+		len(proof.SHat) != n || len(proof.SPrime) != n {
+		return false, malformedError("ShuffleProof.Verify", "proof does not have one entry per commitment")
+	}
+	if proof.Challenge == nil || proof.S1 == nil || proof.S2 == nil || proof.S3 == nil || proof.S4 == nil {
+		return false, malformedError("ShuffleProof.Verify", "proof is missing a scalar")
+	}
+	for i := range proof.SHat {
+		if proof.SHat[i] == nil || proof.SPrime[i] == nil {
+			return false, malformedError("ShuffleProof.Verify", fmt.Sprintf("entry %d is missing a scalar", i))
+		}
+	}
+
+	if !zkpcp.validPoints(inputs...) || !zkpcp.validPoints(outputs...) ||
+		!zkpcp.onCurve(proof.C...) || !zkpcp.onCurve(proof.CHat...) || !zkpcp.onCurve(proof.THat...) ||
+		!zkpcp.onCurve(proof.T1, proof.T2, proof.T3, proof.T4) {
+		return false, curveError("ShuffleProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("ShuffleProof.Verify", "Challenge, S1, S2, S3, S4", proof.Challenge, proof.S1, proof.S2, proof.S3, proof.S4); err != nil {
+		return false, err
+	}
+	if err := zkpcp.canonicalScalars("ShuffleProof.Verify", "SHat[]", proof.SHat...); err != nil {
+		return false, err
+	}
+	if err := zkpcp.canonicalScalars("ShuffleProof.Verify", "SPrime[]", proof.SPrime...); err != nil {
+		return false, err
+	}
+
+	H0, h, err := shuffleGenerators(zkpcp, n)
+	if err != nil {
+		return false, err
+	}
+
+	t, u := shuffleTranscript(zkpcp, inputs, outputs, proof.C)
+	c := shuffleChallenge(t, proof.CHat, proof.T1, proof.T2, proof.T3, proof.T4, proof.THat)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("ShuffleProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	N := zkpcp.C.Params().N
+	negC := new(big.Int).Neg(c)
+	cs := make([]*big.Int, n)
+	negCs := make([]*big.Int, n)
+	cu := make([]*big.Int, n)
+	uProd := big.NewInt(1)
+	for k := range u {
+		cs[k], negCs[k] = c, negC
+		cu[k] = new(big.Int).Mul(c, u[k])
+		uProd.Mul(uProd, u[k])
+		uProd.Mod(uProd, N)
+	}
+
+	// T_1 ?= c(sum(c_k) - sum(h_i)) + s_1H
+	points := append(append(append([]ECPoint{}, proof.C...), h...), zkpcp.H)
+	scalars := append(append(append([]*big.Int{}, cs...), negCs...), proof.S1)
+	rhs := zkpcp.MultiMult(points, scalars)
+	if !pointsEqual(proof.T1, rhs) {
+		return false, equationError("ShuffleProof.Verify", 1, "T_1 != c(sum(c_k) - sum(h_i)) + s_1H", proof.T1, rhs)
+	}
+
+	// T_2 ?= c(CHat_n - prod(u_k)H_0) + s_2H
+	rhs = zkpcp.MultiMult([]ECPoint{proof.CHat[n-1], H0, zkpcp.H},
+		[]*big.Int{c, new(big.Int).Mul(negC, uProd), proof.S2})
+	if !pointsEqual(proof.T2, rhs) {
+		return false, equationError("ShuffleProof.Verify", 2, "T_2 != c(CHat_n - prod(u_k)H_0) + s_2H", proof.T2, rhs)
+	}
+
+	// T_3 ?= c*sum(u_kc_k) + s_3H + sum(s'_ih_i)
+	points = append(append(append([]ECPoint{}, proof.C...), h...), zkpcp.H)
+	scalars = append(append(append([]*big.Int{}, cu...), proof.SPrime...), proof.S3)
+	rhs = zkpcp.MultiMult(points, scalars)
+	if !pointsEqual(proof.T3, rhs) {
+		return false, equationError("ShuffleProof.Verify", 3, "T_3 != c*sum(u_kc_k) + s_3H + sum(s'_ih_i)", proof.T3, rhs)
+	}
+
+	// T_4 ?= c*sum(u_kA_k) - s_4H + sum(s'_iB_i)
+	points = append(append(append([]ECPoint{}, inputs...), outputs...), zkpcp.H)
+	scalars = append(append(append([]*big.Int{}, cu...), proof.SPrime...), new(big.Int).Neg(proof.S4))
+	rhs = zkpcp.MultiMult(points, scalars)
+	if !pointsEqual(proof.T4, rhs) {
+		return false, equationError("ShuffleProof.Verify", 4, "T_4 != c*sum(u_kA_k) - s_4H + sum(s'_iB_i)", proof.T4, rhs)
+	}
+
+	// THat_i ?= cCHat_i + sHat_iH + s'_iCHat_(i-1)
+	prev := H0
+	for i := range proof.THat {
+		rhs = zkpcp.MultiMult([]ECPoint{proof.CHat[i], zkpcp.H, prev}, []*big.Int{c, proof.SHat[i], proof.SPrime[i]})
+		if !pointsEqual(proof.THat[i], rhs) {
+			return false, equationError("ShuffleProof.Verify", 5+i, fmt.Sprintf("THat_%d != cCHat_%d + sHat_%dH + s'_%dCHat_%d", i, i, i, i, i-1), proof.THat[i], rhs)
+		}
+		prev = proof.CHat[i]
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are inputs as []ECPoint and outputs
+// as []ECPoint like for Verify
+func (proof *ShuffleProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("ShuffleProof.VerifyArgs", args)
+	inputs, outputs := a.points(), a.points()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, inputs, outputs)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *ShuffleProof) String() string {
+	if proof == nil {
+		return "ShuffleProof(nil)"
+	}
+	var fields []interface{}
+	for i, p := range proof.C {
+		fields = append(fields, fmt.Sprintf("C[%d]", i), p)
+	}
+	for i, p := range proof.CHat {
+		fields = append(fields, fmt.Sprintf("CHat[%d]", i), p)
+	}
+	fields = append(fields, "T1", proof.T1, "T2", proof.T2, "T3", proof.T3, "T4", proof.T4)
+	for i, p := range proof.THat {
+		fields = append(fields, fmt.Sprintf("THat[%d]", i), p)
+	}
+	fields = append(fields, "Challenge", proof.Challenge,
+		"S1", proof.S1, "S2", proof.S2, "S3", proof.S3, "S4", proof.S4)
+	for i, s := range proof.SHat {
+		fields = append(fields, fmt.Sprintf("SHat[%d]", i), s)
+	}
+	for i, s := range proof.SPrime {
+		fields = append(fields, fmt.Sprintf("SPrime[%d]", i), s)
+	}
+	return proofString("ShuffleProof", fields...)
+}
+
+var (
+	// shuffleEntryLen is the length of c_k, CHat_i, THat_i, sHat_i and s'_i serialized
+	shuffleEntryLen = 3*fixedPointLen + 2*fixedScalarLen
+	// shuffleFixedLen is the length of T_1, ..., T_4, c and s_1, ..., s_4 serialized
+	shuffleFixedLen = 4*fixedPointLen + 5*fixedScalarLen
+)
+
+// Bytes returns a byte slice with a serialized representation of ShuffleProof
+// proof, which grows linearly with the number of commitments
+func (proof *ShuffleProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	wire.WriteVarInt(&buf, uint64(len(proof.C)))
+	for _, ps := range [][]ECPoint{proof.C, proof.CHat} {
+		for _, p := range ps {
+			writeFixedPoint(&buf, p)
+		}
+	}
+	writeFixedPoint(&buf, proof.T1)
+	writeFixedPoint(&buf, proof.T2)
+	writeFixedPoint(&buf, proof.T3)
+	writeFixedPoint(&buf, proof.T4)
+	for _, p := range proof.THat {
+		writeFixedPoint(&buf, p)
+	}
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.S1)
+	writeFixedScalar(&buf, proof.S2)
+	writeFixedScalar(&buf, proof.S3)
+	writeFixedScalar(&buf, proof.S4)
+	for _, ss := range [][]*big.Int{proof.SHat, proof.SPrime} {
+		for _, s := range ss {
+			writeFixedScalar(&buf, s)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of ShuffleProof proof without serializing it
+func (proof *ShuffleProof) Size() int {
+	n := len(proof.C)
+	return 1 + wire.VarIntSerializeSize(uint64(n)) + shuffleFixedLen + n*shuffleEntryLen
+}
+
+// Clone returns a deep copy of ShuffleProof proof that shares no big.Int with it
+func (proof *ShuffleProof) Clone() *ShuffleProof {
+	if proof == nil {
+		return nil
+	}
+	return &ShuffleProof{
+		C:         clonePoints(proof.C),
+		CHat:      clonePoints(proof.CHat),
+		T1:        clonePoint(proof.T1),
+		T2:        clonePoint(proof.T2),
+		T3:        clonePoint(proof.T3),
+		T4:        clonePoint(proof.T4),
+		THat:      clonePoints(proof.THat),
+		Challenge: cloneScalar(proof.Challenge),
+		S1:        cloneScalar(proof.S1),
+		S2:        cloneScalar(proof.S2),
+		S3:        cloneScalar(proof.S3),
+		S4:        cloneScalar(proof.S4),
+		SHat:      cloneScalars(proof.SHat),
+		SPrime:    cloneScalars(proof.SPrime),
+	}
+}
+
+// Equal returns true if ShuffleProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *ShuffleProof) Equal(other *ShuffleProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoints(proof.C, other.C) &&
+		samePoints(proof.CHat, other.CHat) &&
+		samePoint(proof.T1, other.T1) &&
+		samePoint(proof.T2, other.T2) &&
+		samePoint(proof.T3, other.T3) &&
+		samePoint(proof.T4, other.T4) &&
+		samePoints(proof.THat, other.THat) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.S1, other.S1) &&
+		sameScalar(proof.S2, other.S2) &&
+		sameScalar(proof.S3, other.S3) &&
+		sameScalar(proof.S4, other.S4) &&
+		sameScalars(proof.SHat, other.SHat) &&
+		sameScalars(proof.SPrime, other.SPrime)
+}
+
+// NewShuffleProofFromBytes returns a ShuffleProof generated from the
+// deserialization of byte slice b
+func NewShuffleProofFromBytes(b []byte) (*ShuffleProof, error) {
+	if len(b) > 0 && b[0] != proofEncodingVersion {
+		return nil, malformedError("ShuffleProofFromBytes", fmt.Sprintf("unsupported encoding version %d", b[0]))
+	}
+	if len(b) == 0 {
+		return nil, malformedError("ShuffleProofFromBytes", "truncated buffer")
+	}
+	buf := bytes.NewBuffer(b[1:])
+	n, err := wire.ReadVarInt(buf)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 || n > uint64(buf.Len()/shuffleEntryLen) ||
+		uint64(buf.Len()) != uint64(shuffleFixedLen)+n*uint64(shuffleEntryLen) {
+		return nil, malformedError("ShuffleProofFromBytes", "buffer length does not match number of commitments")
+	}
+
+	readPoints := func() ([]ECPoint, error) {
+		ps := make([]ECPoint, n)
+		for i := range ps {
+			if ps[i], err = readFixedPoint(buf, "ShuffleProofFromBytes"); err != nil {
+				return nil, err
+			}
+		}
+		return ps, nil
+	}
+	readScalars := func() ([]*big.Int, error) {
+		ss := make([]*big.Int, n)
+		for i := range ss {
+			if ss[i], err = readFixedScalar(buf, "ShuffleProofFromBytes"); err != nil {
+				return nil, err
+			}
+		}
+		return ss, nil
+	}
+
+	proof := &ShuffleProof{}
+	if proof.C, err = readPoints(); err != nil {
+		return nil, err
+	}
+	if proof.CHat, err = readPoints(); err != nil {
+		return nil, err
+	}
+	for _, p := range []*ECPoint{&proof.T1, &proof.T2, &proof.T3, &proof.T4} {
+		if *p, err = readFixedPoint(buf, "ShuffleProofFromBytes"); err != nil {
+			return nil, err
+		}
+	}
+	if proof.THat, err = readPoints(); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "ShuffleProofFromBytes", &proof.Challenge, &proof.S1, &proof.S2, &proof.S3, &proof.S4); err != nil {
+		return nil, err
+	}
+	if proof.SHat, err = readScalars(); err != nil {
+		return nil, err
+	}
+	if proof.SPrime, err = readScalars(); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+type shuffleProofJSON struct {
+	C         []string `json:"c"`
+	CHat      []string `json:"cHat"`
+	T1        string   `json:"t1"`
+	T2        string   `json:"t2"`
+	T3        string   `json:"t3"`
+	T4        string   `json:"t4"`
+	THat      []string `json:"tHat"`
+	Challenge string   `json:"challenge"`
+	S1        string   `json:"s1"`
+	S2        string   `json:"s2"`
+	S3        string   `json:"s3"`
+	S4        string   `json:"s4"`
+	SHat      []string `json:"sHat"`
+	SPrime    []string `json:"sPrime"`
+}
+
+// MarshalJSON encodes ShuffleProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *ShuffleProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(shuffleProofJSON{
+		C:         pointsToHex(proof.C),
+		CHat:      pointsToHex(proof.CHat),
+		T1:        pointToHex(proof.T1),
+		T2:        pointToHex(proof.T2),
+		T3:        pointToHex(proof.T3),
+		T4:        pointToHex(proof.T4),
+		THat:      pointsToHex(proof.THat),
+		Challenge: scalarToHex(proof.Challenge),
+		S1:        scalarToHex(proof.S1),
+		S2:        scalarToHex(proof.S2),
+		S3:        scalarToHex(proof.S3),
+		S4:        scalarToHex(proof.S4),
+		SHat:      scalarsToHex(proof.SHat),
+		SPrime:    scalarsToHex(proof.SPrime),
+	})
+}
+
+// UnmarshalJSON decodes a ShuffleProof encoded with MarshalJSON. It fails if a
+// point is not on the curve or a scalar is not in [0, N)
+func (proof *ShuffleProof) UnmarshalJSON(b []byte) error {
+	var aux shuffleProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := ShuffleProof{}
+	if p.C, err = pointsFromHex(aux.C, "c"); err != nil {
+		return err
+	}
+	if p.CHat, err = pointsFromHex(aux.CHat, "cHat"); err != nil {
+		return err
+	}
+	if p.T1, err = pointFromHex(aux.T1, "t1"); err != nil {
+		return err
+	}
+	if p.T2, err = pointFromHex(aux.T2, "t2"); err != nil {
+		return err
+	}
+	if p.T3, err = pointFromHex(aux.T3, "t3"); err != nil {
+		return err
+	}
+	if p.T4, err = pointFromHex(aux.T4, "t4"); err != nil {
+		return err
+	}
+	if p.THat, err = pointsFromHex(aux.THat, "tHat"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.S1, err = scalarFromHex(aux.S1, "s1"); err != nil {
+		return err
+	}
+	if p.S2, err = scalarFromHex(aux.S2, "s2"); err != nil {
+		return err
+	}
+	if p.S3, err = scalarFromHex(aux.S3, "s3"); err != nil {
+		return err
+	}
+	if p.S4, err = scalarFromHex(aux.S4, "s4"); err != nil {
+		return err
+	}
+	if p.SHat, err = scalarsFromHex(aux.SHat, "sHat"); err != nil {
+		return err
+	}
+	if p.SPrime, err = scalarsFromHex(aux.SPrime, "sPrime"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
@@ -0,0 +1,200 @@
+package zksigma
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+// shuffled commits to 1, ..., n and returns the commitments with their
+// shuffle by perm, each output re-randomized, and the re-randomizations
+func shuffled(t testing.TB, zkpcp ZKPCurveParams, perm []int) ([]ECPoint, []ECPoint, []*big.Int) {
+	inputs := make([]ECPoint, len(perm))
+	for i := range inputs {
+		CM, _, err := PedCommit(zkpcp, big.NewInt(int64(i+1)))
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		inputs[i] = CM
+	}
+	outputs := make([]ECPoint, len(perm))
+	deltaRs := make([]*big.Int, len(perm))
+	for i, k := range perm {
+		CM, deltaR, err := RerandomizeCommitment(zkpcp, inputs[k])
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		outputs[i], deltaRs[i] = CM, deltaR
+	}
+	return inputs, outputs, deltaRs
+}
+
+func TestShuffleProof(t *testing.T) {
+	perms := map[string][]int{
+		"single":   {0},
+		"identity": {0, 1, 2, 3, 4},
+		"reversal": {7, 6, 5, 4, 3, 2, 1, 0},
+		"rotation": {1, 2, 3, 0},
+	}
+	for name, perm := range perms {
+		inputs, outputs, deltaRs := shuffled(t, TestCurve, perm)
+		proof, err := NewShuffleProof(TestCurve, inputs, outputs, perm, deltaRs)
+		if err != nil {
+			t.Fatalf("TestShuffleProof - %s: %v\n", name, err)
+		}
+		if ok, err := proof.Verify(TestCurve, inputs, outputs); !ok || err != nil {
+			t.Fatalf("TestShuffleProof - %s did not verify: %v\n", name, err)
+		}
+		if len(perm) > 1 {
+			if ok, _ := proof.Verify(TestCurve, outputs, inputs); ok {
+				t.Fatalf("TestShuffleProof - %s verified with inputs and outputs swapped\n", name)
+			}
+		}
+
+		b := proof.Bytes()
+		if len(b) != proof.Size() {
+			t.Fatalf("TestShuffleProof - %s serializes to %d bytes, Size is %d\n", name, len(b), proof.Size())
+		}
+		decoded, err := NewShuffleProofFromBytes(b)
+		if err != nil {
+			t.Fatalf("TestShuffleProof - %s failed to decode: %v\n", name, err)
+		}
+		if ok, err := decoded.Verify(TestCurve, inputs, outputs); !ok || err != nil {
+			t.Fatalf("TestShuffleProof - decoded %s did not verify: %v\n", name, err)
+		}
+		if !bytes.Equal(decoded.Bytes(), b) {
+			t.Fatalf("TestShuffleProof - decoding and encoding %s again changes the proof\n", name)
+		}
+		for _, short := range [][]byte{nil, b[:1], b[:len(b)-1], append(b, 0)} {
+			if _, err := NewShuffleProofFromBytes(short); err == nil {
+				t.Fatalf("TestShuffleProof - decoded %d bytes of %s\n", len(short), name)
+			}
+		}
+	}
+
+	// a proof made under LegacyChallenges verifies without it, the
+	// challenges of ShuffleProof never use it
+	defer func(old bool) { LegacyChallenges = old }(LegacyChallenges)
+	perm := []int{2, 0, 1}
+	inputs, outputs, deltaRs := shuffled(t, TestCurve, perm)
+	LegacyChallenges = true
+	proof, err := NewShuffleProof(TestCurve, inputs, outputs, perm, deltaRs)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	LegacyChallenges = false
+	if ok, err := proof.Verify(TestCurve, inputs, outputs); !ok || err != nil {
+		t.Fatalf("TestShuffleProof - proof made with LegacyChallenges did not verify: %v\n", err)
+	}
+}
+
+func TestShuffleProofTampered(t *testing.T) {
+	perm := []int{3, 1, 0, 2}
+	inputs, outputs, deltaRs := shuffled(t, TestCurve, perm)
+	proof, err := NewShuffleProof(TestCurve, inputs, outputs, perm, deltaRs)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	// a single output changed, to another commitment to the same value too
+	for i := range outputs {
+		for _, other := range []ECPoint{TestCurve.Add(outputs[i], TestCurve.G), TestCurve.Add(outputs[i], TestCurve.H)} {
+			tampered := append([]ECPoint{}, outputs...)
+			tampered[i] = other
+			if ok, err := proof.Verify(TestCurve, inputs, tampered); ok || err == nil {
+				t.Fatalf("TestShuffleProofTampered - verified with output %d changed\n", i)
+			}
+		}
+	}
+
+	// two outputs swapped are still a shuffle, but not the proved one
+	swapped := append([]ECPoint{}, outputs...)
+	swapped[0], swapped[1] = swapped[1], swapped[0]
+	if ok, _ := proof.Verify(TestCurve, inputs, swapped); ok {
+		t.Fatalf("TestShuffleProofTampered - verified with outputs swapped\n")
+	}
+
+	// no output may be dropped or repeated
+	repeated := append([]ECPoint{}, outputs...)
+	repeated[1] = repeated[0]
+	if ok, _ := proof.Verify(TestCurve, inputs, repeated); ok {
+		t.Fatalf("TestShuffleProofTampered - verified with an output repeated\n")
+	}
+	if ok, err := proof.Verify(TestCurve, inputs[1:], outputs[1:]); ok || err == nil {
+		t.Fatalf("TestShuffleProofTampered - verified for fewer commitments\n")
+	}
+
+	// a changed response
+	for i := range proof.SPrime {
+		q := *proof
+		q.SPrime = append([]*big.Int{}, proof.SPrime...)
+		q.SPrime[i] = new(big.Int).Add(q.SPrime[i], big.NewInt(1))
+		if ok, _ := q.Verify(TestCurve, inputs, outputs); ok {
+			t.Fatalf("TestShuffleProofTampered - verified with s'_%d changed\n", i)
+		}
+	}
+	q := *proof
+	q.SHat = append([]*big.Int{}, proof.SHat...)
+	q.SHat[2] = nil
+	if ok, err := q.Verify(TestCurve, inputs, outputs); ok || err == nil {
+		t.Fatalf("TestShuffleProofTampered - verified with a nil response\n")
+	}
+	q = *proof
+	q.CHat = proof.CHat[1:]
+	if ok, err := q.Verify(TestCurve, inputs, outputs); ok || err == nil {
+		t.Fatalf("TestShuffleProofTampered - verified with an entry missing\n")
+	}
+}
+
+func TestShuffleProofErrors(t *testing.T) {
+	perm := []int{1, 2, 0}
+	inputs, outputs, deltaRs := shuffled(t, TestCurve, perm)
+
+	cases := []struct {
+		name            string
+		inputs, outputs []ECPoint
+		perm            []int
+		deltaRs         []*big.Int
+	}{
+		{"no commitments", nil, nil, nil, nil},
+		{"mismatched outputs", inputs, outputs[:2], perm, deltaRs},
+		{"mismatched perm", inputs, outputs, perm[:2], deltaRs},
+		{"mismatched deltaRs", inputs, outputs, perm, deltaRs[:2]},
+		{"repeated index", inputs, outputs, []int{1, 1, 0}, deltaRs},
+		{"negative index", inputs, outputs, []int{1, -1, 0}, deltaRs},
+		{"index out of range", inputs, outputs, []int{1, 3, 0}, deltaRs},
+		{"wrong permutation", inputs, outputs, []int{2, 1, 0}, deltaRs},
+		{"wrong deltaR", inputs, outputs, perm, []*big.Int{deltaRs[0], deltaRs[2], deltaRs[1]}},
+		{"nil deltaR", inputs, outputs, perm, []*big.Int{deltaRs[0], nil, deltaRs[2]}},
+		{"deltaR out of range", inputs, outputs, perm, []*big.Int{deltaRs[0], TestCurve.C.Params().N, deltaRs[2]}},
+	}
+	for _, c := range cases {
+		if _, err := NewShuffleProof(TestCurve, c.inputs, c.outputs, c.perm, c.deltaRs); err == nil {
+			t.Fatalf("TestShuffleProofErrors - %s should fail\n", c.name)
+		}
+	}
+
+	var nilProof *ShuffleProof
+	if ok, err := nilProof.Verify(TestCurve, inputs, outputs); ok || err == nil {
+		t.Fatalf("TestShuffleProofErrors - nil proof verified\n")
+	}
+}
+
+func BenchmarkShuffleProve16(b *testing.B) {
+	perm := []int{15, 3, 8, 0, 12, 1, 6, 9, 14, 2, 11, 5, 7, 13, 4, 10}
+	inputs, outputs, deltaRs := shuffled(b, TestCurve, perm)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewShuffleProof(TestCurve, inputs, outputs, perm, deltaRs)
+	}
+}
+
+func BenchmarkShuffleVerify16(b *testing.B) {
+	perm := []int{15, 3, 8, 0, 12, 1, 6, 9, 14, 2, 11, 5, 7, 13, 4, 10}
+	inputs, outputs, deltaRs := shuffled(b, TestCurve, perm)
+	proof, _ := NewShuffleProof(TestCurve, inputs, outputs, perm, deltaRs)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, inputs, outputs)
+	}
+}
@@ -0,0 +1,425 @@
+package zksigma
+
+import (
+	"math/big"
+)
+
+// Every proof whose statement is known before proving can be generated from
+// its statement and witness with NewXProofFromStatement and checked against
+// the statement alone with VerifyStatement. The statements of the proofs that
+// can be part of a ComposedProof are in compose.go. ProductProof, RangeProof
+// and DecryptionProof compute part of their statement while proving and only
+// have their positional constructors.
+
+// AggregateRangeStatement is the statement of an AggregateRangeProof, see
+// NewAggregateRangeProof
+type AggregateRangeStatement struct {
+	CMs  []ECPoint
+	Bits int
+}
+
+// BalanceStatement is the statement of a BalanceProof, see NewBalanceProof.
+// Signs are the signs of Terms in LHS = sign_1 * Terms_1 + ... + sign_n * Terms_n.
+type BalanceStatement struct {
+	LHS   ECPoint
+	Terms []ECPoint
+	Signs []int
+}
+
+// BitRangeStatement is the statement of a BitRangeProof, see NewBitRangeProof
+type BitRangeStatement struct {
+	CM   ECPoint
+	Bits int
+}
+
+// ComparisonStatement is the statement of a ComparisonProof, see
+// NewComparisonProof
+type ComparisonStatement struct {
+	CMA, CMB ECPoint
+	Bits     int
+}
+
+// EquivOrZeroStatement is the statement of an EquivOrZeroProof, see
+// NewEquivOrZeroProof
+type EquivOrZeroStatement struct {
+	CMChange, CMDelta ECPoint
+}
+
+// InequalityStatement is the statement of an InequalityProof, see
+// NewInequalityProof
+type InequalityStatement struct {
+	CM1, CM2 ECPoint
+}
+
+// KeyRotationStatement is the statement of a KeyRotationProof, see
+// NewKeyRotationProof
+type KeyRotationStatement struct {
+	OldCMTok, NewCMTok ECPoint
+	OldPK, NewPK       ECPoint
+}
+
+// NonZeroStatement is the statement of a NonZeroProof, see NewNonZeroProof
+type NonZeroStatement struct {
+	CM ECPoint
+}
+
+// OneOfManyStatement is the statement of a OneOfManyProof, see
+// NewOneOfManyProof
+type OneOfManyStatement struct {
+	Bases, Results []ECPoint
+}
+
+// OpenStatement is the statement of an OpenProof, see NewOpenProof
+type OpenStatement struct {
+	CM ECPoint
+}
+
+// OpenToValueStatement is the statement of an OpenToValueProof, see
+// NewOpenToValueProof. Value is the claimed value of CM, which is public.
+type OpenToValueStatement struct {
+	CM    ECPoint
+	Value *big.Int
+}
+
+// RerandomizationStatement is the statement of a RerandomizationProof, see
+// NewRerandomizationProof
+type RerandomizationStatement struct {
+	CM, CMPrime ECPoint
+}
+
+// SameBlindingStatement is the statement of a SameBlindingProof, see
+// NewSameBlindingProof
+type SameBlindingStatement struct {
+	CMTok1, CMTok2, PK1, PK2 ECPoint
+}
+
+// SameValueStatement is the statement of a SameValueProof, see
+// NewSameValueProof
+type SameValueStatement struct {
+	CM1, CM2 ECPoint
+}
+
+// SetMembershipStatement is the statement of a SetMembershipProof, see
+// NewSetMembershipProof
+type SetMembershipStatement struct {
+	CM  ECPoint
+	Set []*big.Int
+}
+
+// ShuffleStatement is the statement of a ShuffleProof, see NewShuffleProof
+type ShuffleStatement struct {
+	Inputs, Outputs []ECPoint
+}
+
+// SumStatement is the statement of a SumProof, see NewSumProof. Total is the
+// public sum of the values of CMs.
+type SumStatement struct {
+	CMs   []ECPoint
+	Total *big.Int
+}
+
+// VectorOpenStatement is the statement of a VectorOpenProof, see
+// NewVectorOpenProof
+type VectorOpenStatement struct {
+	C ECPoint
+}
+
+// ========== ABCStatement ==========
+
+// checkKey makes sure SK of witness w is the secret key of PubKey, which a
+// standalone ABCProof computes itself
+func (s ABCStatement) checkKey(zkpcp ZKPCurveParams, w ABCWitness) error {
+	if err := zkpcp.checkScalars("ABCProof", "SK", w.SK); err != nil {
+		return err
+	}
+	if w.SK.Sign() == 0 {
+		return rangeError("ABCProof", "sk is zero")
+	}
+	if !zkpcp.validPoints(s.PubKey) {
+		return curveError("ABCProof")
+	}
+	if !zkpcp.Mult(zkpcp.H, w.SK).Equal(s.PubKey) {
+		return inputError("ABCProof", "SK is not the secret key of PubKey")
+	}
+	return nil
+}
+
+// NewABCProofFromStatement is NewABCProof for the CM and CMTok of stmt. It
+// fails if the SK of wit is not the secret key of PubKey.
+func NewABCProofFromStatement(zkpcp ZKPCurveParams, stmt ABCStatement, wit ABCWitness, option Side) (*ABCProof, error) {
+	if err := stmt.checkKey(zkpcp, wit); err != nil {
+		return nil, err
+	}
+	return NewABCProof(zkpcp, stmt.CM, stmt.CMTok, wit.Value, wit.SK, option)
+}
+
+// VerifyStatement is Verify for the CM, CMTok and PubKey of stmt
+func (aProof *ABCProof) VerifyStatement(zkpcp ZKPCurveParams, stmt ABCStatement) (bool, error) {
+	return aProof.Verify(zkpcp, stmt.CM, stmt.CMTok, stmt.PubKey)
+}
+
+// ========== ConsistencyStatement ==========
+
+// NewConsistencyProofFromStatement is NewConsistencyProof for stmt and wit
+func NewConsistencyProofFromStatement(zkpcp ZKPCurveParams, stmt ConsistencyStatement, wit ConsistencyWitness) (*ConsistencyProof, error) {
+	return NewConsistencyProof(zkpcp, stmt.CM, stmt.CMTok, stmt.PubKey, wit.Value, wit.Randomness)
+}
+
+// VerifyStatement is Verify for the CM, CMTok and PubKey of stmt
+func (conProof *ConsistencyProof) VerifyStatement(zkpcp ZKPCurveParams, stmt ConsistencyStatement) (bool, error) {
+	return conProof.Verify(zkpcp, stmt.CM, stmt.CMTok, stmt.PubKey)
+}
+
+// ========== DisjunctiveStatement ==========
+
+// NewDisjunctiveProofFromStatement is NewDisjunctiveProof for stmt and wit,
+// option is the relation of stmt the X of wit belongs to
+func NewDisjunctiveProofFromStatement(zkpcp ZKPCurveParams, stmt DisjunctiveStatement, wit DisjunctiveWitness, option Side) (*DisjunctiveProof, error) {
+	return NewDisjunctiveProof(zkpcp, stmt.Base1, stmt.Result1, stmt.Base2, stmt.Result2, wit.X, option)
+}
+
+// VerifyStatement is Verify for the bases and results of stmt
+func (djProof *DisjunctiveProof) VerifyStatement(zkpcp ZKPCurveParams, stmt DisjunctiveStatement) (bool, error) {
+	return djProof.Verify(zkpcp, stmt.Base1, stmt.Result1, stmt.Base2, stmt.Result2)
+}
+
+// ========== EquivalenceStatement ==========
+
+// NewEquivalenceProofFromStatement is NewEquivalenceProof for stmt and wit
+func NewEquivalenceProofFromStatement(zkpcp ZKPCurveParams, stmt EquivalenceStatement, wit EquivalenceWitness) (*EquivalenceProof, error) {
+	return NewEquivalenceProof(zkpcp, stmt.Base1, stmt.Result1, stmt.Base2, stmt.Result2, wit.X)
+}
+
+// VerifyStatement is Verify for the bases and results of stmt
+func (eqProof *EquivalenceProof) VerifyStatement(zkpcp ZKPCurveParams, stmt EquivalenceStatement) (bool, error) {
+	return eqProof.Verify(zkpcp, stmt.Base1, stmt.Result1, stmt.Base2, stmt.Result2)
+}
+
+// ========== GSPFSStatement ==========
+
+// NewGSPFSProofFromStatement is NewGSPFSProofBase for stmt and wit
+func NewGSPFSProofFromStatement(zkpcp ZKPCurveParams, stmt GSPFSStatement, wit GSPFSWitness) (*GSPFSProof, error) {
+	return NewGSPFSProofBase(zkpcp, stmt.Base, stmt.A, wit.X)
+}
+
+// VerifyStatement is VerifyBase for the Base and A of stmt
+func (proof *GSPFSProof) VerifyStatement(zkpcp ZKPCurveParams, stmt GSPFSStatement) (bool, error) {
+	return proof.VerifyBase(zkpcp, stmt.Base, stmt.A)
+}
+
+// ========== AggregateRangeStatement ==========
+
+// NewAggregateRangeProofFromStatement is NewAggregateRangeProof for stmt and wit
+func NewAggregateRangeProofFromStatement(zkpcp ZKPCurveParams, stmt AggregateRangeStatement, wit AggregateRangeWitness) (*AggregateRangeProof, error) {
+	return NewAggregateRangeProof(zkpcp, stmt.CMs, wit.Values, wit.Randomnesses, stmt.Bits)
+}
+
+// VerifyStatement is Verify for the CMs and Bits of stmt
+func (proof *AggregateRangeProof) VerifyStatement(zkpcp ZKPCurveParams, stmt AggregateRangeStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.CMs, stmt.Bits)
+}
+
+// ========== BalanceStatement ==========
+
+// NewBalanceProofFromStatement is NewBalanceProof for stmt and wit
+func NewBalanceProofFromStatement(zkpcp ZKPCurveParams, stmt BalanceStatement, wit BalanceWitness) (*BalanceProof, error) {
+	return NewBalanceProof(zkpcp, stmt.LHS, stmt.Terms, stmt.Signs, wit.LHSR, wit.Rs)
+}
+
+// VerifyStatement is Verify for the LHS, Terms and Signs of stmt
+func (proof *BalanceProof) VerifyStatement(zkpcp ZKPCurveParams, stmt BalanceStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.LHS, stmt.Terms, stmt.Signs)
+}
+
+// ========== BitRangeStatement ==========
+
+// NewBitRangeProofFromStatement is NewBitRangeProof for stmt and wit
+func NewBitRangeProofFromStatement(zkpcp ZKPCurveParams, stmt BitRangeStatement, wit BitRangeWitness) (*BitRangeProof, error) {
+	return NewBitRangeProof(zkpcp, stmt.CM, wit.Value, wit.Randomness, stmt.Bits)
+}
+
+// VerifyStatement is Verify for the CM and Bits of stmt
+func (proof *BitRangeProof) VerifyStatement(zkpcp ZKPCurveParams, stmt BitRangeStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.CM, stmt.Bits)
+}
+
+// ========== ComparisonStatement ==========
+
+// NewComparisonProofFromStatement is NewComparisonProof for stmt and wit
+func NewComparisonProofFromStatement(zkpcp ZKPCurveParams, stmt ComparisonStatement, wit ComparisonWitness) (*ComparisonProof, error) {
+	return NewComparisonProof(zkpcp, stmt.CMA, stmt.CMB, wit.A, wit.B, wit.RA, wit.RB, stmt.Bits)
+}
+
+// VerifyStatement is Verify for the CMA, CMB and Bits of stmt
+func (proof *ComparisonProof) VerifyStatement(zkpcp ZKPCurveParams, stmt ComparisonStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.CMA, stmt.CMB, stmt.Bits)
+}
+
+// ========== EquivOrZeroStatement ==========
+
+// NewEquivOrZeroProofFromStatement is NewEquivOrZeroProof for stmt and wit
+func NewEquivOrZeroProofFromStatement(zkpcp ZKPCurveParams, stmt EquivOrZeroStatement, wit EquivOrZeroWitness, option Side) (*EquivOrZeroProof, error) {
+	return NewEquivOrZeroProof(zkpcp, stmt.CMChange, stmt.CMDelta, wit.RChange, wit.RDelta, option)
+}
+
+// VerifyStatement is Verify for the commitments of stmt
+func (proof *EquivOrZeroProof) VerifyStatement(zkpcp ZKPCurveParams, stmt EquivOrZeroStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.CMChange, stmt.CMDelta)
+}
+
+// ========== InequalityStatement ==========
+
+// NewInequalityProofFromStatement is NewInequalityProof for stmt and wit
+func NewInequalityProofFromStatement(zkpcp ZKPCurveParams, stmt InequalityStatement, wit InequalityWitness) (*InequalityProof, error) {
+	return NewInequalityProof(zkpcp, stmt.CM1, stmt.CM2, wit.V1, wit.V2, wit.R1, wit.R2)
+}
+
+// VerifyStatement is Verify for the CM1 and CM2 of stmt
+func (ieProof *InequalityProof) VerifyStatement(zkpcp ZKPCurveParams, stmt InequalityStatement) (bool, error) {
+	return ieProof.Verify(zkpcp, stmt.CM1, stmt.CM2)
+}
+
+// ========== KeyRotationStatement ==========
+
+// NewKeyRotationProofFromStatement is NewKeyRotationProof for stmt and wit
+func NewKeyRotationProofFromStatement(zkpcp ZKPCurveParams, stmt KeyRotationStatement, wit KeyRotationWitness) (*KeyRotationProof, error) {
+	return NewKeyRotationProof(zkpcp, stmt.OldCMTok, stmt.NewCMTok, stmt.OldPK, stmt.NewPK, wit.OldSK, wit.NewSK)
+}
+
+// VerifyStatement is Verify for the tokens and keys of stmt
+func (proof *KeyRotationProof) VerifyStatement(zkpcp ZKPCurveParams, stmt KeyRotationStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.OldCMTok, stmt.NewCMTok, stmt.OldPK, stmt.NewPK)
+}
+
+// ========== NonZeroStatement ==========
+
+// NewNonZeroProofFromStatement is NewNonZeroProof for stmt and wit
+func NewNonZeroProofFromStatement(zkpcp ZKPCurveParams, stmt NonZeroStatement, wit NonZeroWitness) (*NonZeroProof, error) {
+	return NewNonZeroProof(zkpcp, stmt.CM, wit.V, wit.R)
+}
+
+// VerifyStatement is Verify for the CM of stmt
+func (proof *NonZeroProof) VerifyStatement(zkpcp ZKPCurveParams, stmt NonZeroStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.CM)
+}
+
+// ========== OneOfManyStatement ==========
+
+// NewOneOfManyProofFromStatement is NewOneOfManyProof for stmt and wit
+func NewOneOfManyProofFromStatement(zkpcp ZKPCurveParams, stmt OneOfManyStatement, wit OneOfManyWitness) (*OneOfManyProof, error) {
+	return NewOneOfManyProof(zkpcp, stmt.Bases, stmt.Results, wit.KnownIndex, wit.X)
+}
+
+// VerifyStatement is Verify for the bases and results of stmt
+func (proof *OneOfManyProof) VerifyStatement(zkpcp ZKPCurveParams, stmt OneOfManyStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.Bases, stmt.Results)
+}
+
+// ========== OpenStatement ==========
+
+// NewOpenProofFromStatement is NewOpenProof for stmt and wit
+func NewOpenProofFromStatement(zkpcp ZKPCurveParams, stmt OpenStatement, wit OpenWitness) (*OpenProof, error) {
+	return NewOpenProof(zkpcp, stmt.CM, wit.V, wit.R)
+}
+
+// VerifyStatement is Verify for the CM of stmt
+func (proof *OpenProof) VerifyStatement(zkpcp ZKPCurveParams, stmt OpenStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.CM)
+}
+
+// ========== OpenToValueStatement ==========
+
+// NewOpenToValueProofFromStatement is NewOpenToValueProof for stmt and wit
+func NewOpenToValueProofFromStatement(zkpcp ZKPCurveParams, stmt OpenToValueStatement, wit OpenToValueWitness) (*OpenToValueProof, error) {
+	return NewOpenToValueProof(zkpcp, stmt.CM, stmt.Value, wit.R)
+}
+
+// VerifyStatement is Verify for the CM and Value of stmt
+func (proof *OpenToValueProof) VerifyStatement(zkpcp ZKPCurveParams, stmt OpenToValueStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.CM, stmt.Value)
+}
+
+// ========== RerandomizationStatement ==========
+
+// NewRerandomizationProofFromStatement is NewRerandomizationProof for stmt
+// and wit
+func NewRerandomizationProofFromStatement(zkpcp ZKPCurveParams, stmt RerandomizationStatement, wit RerandomizationWitness) (*RerandomizationProof, error) {
+	return NewRerandomizationProof(zkpcp, stmt.CM, stmt.CMPrime, wit.DeltaR)
+}
+
+// VerifyStatement is Verify for the CM and CMPrime of stmt
+func (proof *RerandomizationProof) VerifyStatement(zkpcp ZKPCurveParams, stmt RerandomizationStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.CM, stmt.CMPrime)
+}
+
+// ========== SameBlindingStatement ==========
+
+// NewSameBlindingProofFromStatement is NewSameBlindingProof for stmt and wit
+func NewSameBlindingProofFromStatement(zkpcp ZKPCurveParams, stmt SameBlindingStatement, wit SameBlindingWitness) (*SameBlindingProof, error) {
+	return NewSameBlindingProof(zkpcp, stmt.CMTok1, stmt.CMTok2, stmt.PK1, stmt.PK2, wit.R)
+}
+
+// VerifyStatement is Verify for the tokens and keys of stmt
+func (proof *SameBlindingProof) VerifyStatement(zkpcp ZKPCurveParams, stmt SameBlindingStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.CMTok1, stmt.CMTok2, stmt.PK1, stmt.PK2)
+}
+
+// ========== SameValueStatement ==========
+
+// NewSameValueProofFromStatement is NewSameValueProof for stmt and wit
+func NewSameValueProofFromStatement(zkpcp ZKPCurveParams, stmt SameValueStatement, wit SameValueWitness) (*SameValueProof, error) {
+	return NewSameValueProof(zkpcp, stmt.CM1, stmt.CM2, wit.V, wit.R1, wit.R2)
+}
+
+// VerifyStatement is Verify for the CM1 and CM2 of stmt
+func (proof *SameValueProof) VerifyStatement(zkpcp ZKPCurveParams, stmt SameValueStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.CM1, stmt.CM2)
+}
+
+// ========== SetMembershipStatement ==========
+
+// NewSetMembershipProofFromStatement is NewSetMembershipProof for stmt and wit
+func NewSetMembershipProofFromStatement(zkpcp ZKPCurveParams, stmt SetMembershipStatement, wit SetMembershipWitness) (*SetMembershipProof, error) {
+	return NewSetMembershipProof(zkpcp, stmt.CM, wit.Value, wit.R, stmt.Set)
+}
+
+// VerifyStatement is Verify for the CM and Set of stmt
+func (proof *SetMembershipProof) VerifyStatement(zkpcp ZKPCurveParams, stmt SetMembershipStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.CM, stmt.Set)
+}
+
+// ========== ShuffleStatement ==========
+
+// NewShuffleProofFromStatement is NewShuffleProof for stmt and wit
+func NewShuffleProofFromStatement(zkpcp ZKPCurveParams, stmt ShuffleStatement, wit ShuffleWitness) (*ShuffleProof, error) {
+	return NewShuffleProof(zkpcp, stmt.Inputs, stmt.Outputs, wit.Perm, wit.DeltaRs)
+}
+
+// VerifyStatement is Verify for the inputs and outputs of stmt
+func (proof *ShuffleProof) VerifyStatement(zkpcp ZKPCurveParams, stmt ShuffleStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.Inputs, stmt.Outputs)
+}
+
+// ========== SumStatement ==========
+
+// NewSumProofFromStatement is NewSumProof for stmt and wit
+func NewSumProofFromStatement(zkpcp ZKPCurveParams, stmt SumStatement, wit SumWitness) (*SumProof, error) {
+	return NewSumProof(zkpcp, stmt.CMs, wit.Values, wit.Randomnesses, stmt.Total)
+}
+
+// VerifyStatement is Verify for the CMs and Total of stmt
+func (proof *SumProof) VerifyStatement(zkpcp ZKPCurveParams, stmt SumStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.CMs, stmt.Total)
+}
+
+// ========== VectorOpenStatement ==========
+
+// NewVectorOpenProofFromStatement is NewVectorOpenProof for stmt and wit
+func NewVectorOpenProofFromStatement(zkpcp ZKPCurveParams, stmt VectorOpenStatement, wit VectorOpenWitness) (*VectorOpenProof, error) {
+	return NewVectorOpenProof(zkpcp, stmt.C, wit.Values, wit.R)
+}
+
+// VerifyStatement is Verify for the C of stmt
+func (proof *VectorOpenProof) VerifyStatement(zkpcp ZKPCurveParams, stmt VectorOpenStatement) (bool, error) {
+	return proof.Verify(zkpcp, stmt.C)
+}
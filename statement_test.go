@@ -0,0 +1,229 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// statementCase proves a statement from its witness and verifies the proof
+// for the statement and for one that differs in a public value
+type statementCase func() (ok, other func() (bool, error), err error)
+
+func TestProofFromStatement(t *testing.T) {
+	N := TestCurve.C.Params().N
+	sk, _ := rand.Int(rand.Reader, N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(10)
+	CM, r, _ := PedCommit(TestCurve, value)
+	CMTok := TestCurve.Mult(PK, r)
+	CM2, r2, _ := PedCommit(TestCurve, big.NewInt(3))
+	sameCM, sameR, _ := PedCommit(TestCurve, value)
+	CMPrime, deltaR, _ := RerandomizeCommitment(TestCurve, CM)
+	x := big.NewInt(77)
+	A := TestCurve.Mult(TestCurve.G, x)
+	AH := TestCurve.Mult(TestCurve.H, x)
+	newSK := big.NewInt(5)
+	newPK := TestCurve.Mult(TestCurve.H, newSK)
+	newCMTok, _ := RotateToken(TestCurve, CMTok, sk, newSK)
+	inputs, outputs, deltaRs := shuffled(t, TestCurve, []int{2, 0, 1})
+	CMs, values, randomnesses, total := makeSum(t, 3)
+	vecCM, _ := VectorPedCommitR(TestCurve, []*big.Int{value, x}, r)
+	other := TestCurve.Add(CM, TestCurve.G)
+
+	cases := map[string]statementCase{
+		"ABCProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := ABCStatement{CM: CM, CMTok: CMTok, PubKey: PK}
+			p, err := NewABCProofFromStatement(TestCurve, stmt, ABCWitness{Value: value, SK: sk}, Right)
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, ABCStatement{other, CMTok, PK}) }, err
+		},
+		"ConsistencyProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := ConsistencyStatement{CM: CM, CMTok: CMTok, PubKey: PK}
+			p, err := NewConsistencyProofFromStatement(TestCurve, stmt, ConsistencyWitness{value, r})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, ConsistencyStatement{other, CMTok, PK}) }, err
+		},
+		"DisjunctiveProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := DisjunctiveStatement{TestCurve.G, A, TestCurve.H, CM}
+			p, err := NewDisjunctiveProofFromStatement(TestCurve, stmt, DisjunctiveWitness{x}, Left)
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) {
+					return p.VerifyStatement(TestCurve, DisjunctiveStatement{TestCurve.G, other, TestCurve.H, CM})
+				}, err
+		},
+		"EquivalenceProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := EquivalenceStatement{TestCurve.G, A, TestCurve.H, AH}
+			p, err := NewEquivalenceProofFromStatement(TestCurve, stmt, EquivalenceWitness{x})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) {
+					return p.VerifyStatement(TestCurve, EquivalenceStatement{TestCurve.G, A, TestCurve.H, other})
+				}, err
+		},
+		"GSPFSProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := GSPFSStatement{TestCurve.H, AH}
+			p, err := NewGSPFSProofFromStatement(TestCurve, stmt, GSPFSWitness{x})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, GSPFSStatement{TestCurve.G, AH}) }, err
+		},
+		"AggregateRangeProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := AggregateRangeStatement{[]ECPoint{CM, CM2}, 4}
+			p, err := NewAggregateRangeProofFromStatement(TestCurve, stmt, AggregateRangeWitness{[]*big.Int{value, big.NewInt(3)}, []*big.Int{r, r2}})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) {
+					return p.VerifyStatement(TestCurve, AggregateRangeStatement{[]ECPoint{CM, other}, 4})
+				}, err
+		},
+		"BalanceProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := BalanceStatement{CM, []ECPoint{sameCM}, []int{1}}
+			p, err := NewBalanceProofFromStatement(TestCurve, stmt, BalanceWitness{r, []*big.Int{sameR}})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) {
+					return p.VerifyStatement(TestCurve, BalanceStatement{CM, []ECPoint{sameCM}, []int{-1}})
+				}, err
+		},
+		"BitRangeProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := BitRangeStatement{CM, 4}
+			p, err := NewBitRangeProofFromStatement(TestCurve, stmt, BitRangeWitness{value, r})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, BitRangeStatement{CM, 5}) }, err
+		},
+		"ComparisonProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := ComparisonStatement{CM, CM2, 8}
+			p, err := NewComparisonProofFromStatement(TestCurve, stmt, ComparisonWitness{value, big.NewInt(3), r, r2})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, ComparisonStatement{CM, other, 8}) }, err
+		},
+		"InequalityProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := InequalityStatement{CM, CM2}
+			p, err := NewInequalityProofFromStatement(TestCurve, stmt, InequalityWitness{value, big.NewInt(3), r, r2})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, InequalityStatement{CM, sameCM}) }, err
+		},
+		"KeyRotationProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := KeyRotationStatement{CMTok, newCMTok, PK, newPK}
+			p, err := NewKeyRotationProofFromStatement(TestCurve, stmt, KeyRotationWitness{sk, newSK})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) {
+					return p.VerifyStatement(TestCurve, KeyRotationStatement{CMTok, other, PK, newPK})
+				}, err
+		},
+		"SameBlindingProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := SameBlindingStatement{CMTok, TestCurve.Mult(newPK, r), PK, newPK}
+			p, err := NewSameBlindingProofFromStatement(TestCurve, stmt, SameBlindingWitness{r})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) {
+					return p.VerifyStatement(TestCurve, SameBlindingStatement{CMTok, other, PK, newPK})
+				}, err
+		},
+		"EquivOrZeroProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := EquivOrZeroStatement{CM, sameCM}
+			p, err := NewEquivOrZeroProofFromStatement(TestCurve, stmt, EquivOrZeroWitness{r, sameR}, Right)
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, EquivOrZeroStatement{CM, CM2}) }, err
+		},
+		"NonZeroProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := NonZeroStatement{CM}
+			p, err := NewNonZeroProofFromStatement(TestCurve, stmt, NonZeroWitness{value, r})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, NonZeroStatement{other}) }, err
+		},
+		"OneOfManyProof": func() (func() (bool, error), func() (bool, error), error) {
+			bases, results := denominations(TestCurve, CM, []*big.Int{big.NewInt(1), value, big.NewInt(100)})
+			stmt := OneOfManyStatement{bases, results}
+			p, err := NewOneOfManyProofFromStatement(TestCurve, stmt, OneOfManyWitness{1, r})
+			wrong, _ := denominations(TestCurve, other, []*big.Int{big.NewInt(1), value, big.NewInt(100)})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, OneOfManyStatement{bases, wrong}) }, err
+		},
+		"SetMembershipProof": func() (func() (bool, error), func() (bool, error), error) {
+			set := []*big.Int{big.NewInt(1), value, big.NewInt(100)}
+			stmt := SetMembershipStatement{CM, set}
+			p, err := NewSetMembershipProofFromStatement(TestCurve, stmt, SetMembershipWitness{value, r})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) {
+					return p.VerifyStatement(TestCurve, SetMembershipStatement{CM, []*big.Int{big.NewInt(1), big.NewInt(100)}})
+				}, err
+		},
+		"OpenProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := OpenStatement{CM}
+			p, err := NewOpenProofFromStatement(TestCurve, stmt, OpenWitness{value, r})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, OpenStatement{other}) }, err
+		},
+		"OpenToValueProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := OpenToValueStatement{CM, value}
+			p, err := NewOpenToValueProofFromStatement(TestCurve, stmt, OpenToValueWitness{r})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, OpenToValueStatement{CM, big.NewInt(11)}) }, err
+		},
+		"RerandomizationProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := RerandomizationStatement{CM, CMPrime}
+			p, err := NewRerandomizationProofFromStatement(TestCurve, stmt, RerandomizationWitness{deltaR})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, RerandomizationStatement{CM, other}) }, err
+		},
+		"SameValueProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := SameValueStatement{CM, sameCM}
+			p, err := NewSameValueProofFromStatement(TestCurve, stmt, SameValueWitness{value, r, sameR})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, SameValueStatement{CM, CM2}) }, err
+		},
+		"ShuffleProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := ShuffleStatement{inputs, outputs}
+			p, err := NewShuffleProofFromStatement(TestCurve, stmt, ShuffleWitness{[]int{2, 0, 1}, deltaRs})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, ShuffleStatement{inputs, inputs}) }, err
+		},
+		"SumProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := SumStatement{CMs, total}
+			p, err := NewSumProofFromStatement(TestCurve, stmt, SumWitness{values, randomnesses})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) {
+					return p.VerifyStatement(TestCurve, SumStatement{CMs, new(big.Int).Add(total, big.NewInt(1))})
+				}, err
+		},
+		"VectorOpenProof": func() (func() (bool, error), func() (bool, error), error) {
+			stmt := VectorOpenStatement{vecCM}
+			p, err := NewVectorOpenProofFromStatement(TestCurve, stmt, VectorOpenWitness{[]*big.Int{value, x}, r})
+			return func() (bool, error) { return p.VerifyStatement(TestCurve, stmt) },
+				func() (bool, error) { return p.VerifyStatement(TestCurve, VectorOpenStatement{other}) }, err
+		},
+	}
+
+	for name, c := range cases {
+		verify, verifyOther, err := c()
+		if err != nil {
+			t.Fatalf("%s failed to generate from its statement: %v\n", name, err)
+		}
+		if ok, err := verify(); !ok || err != nil {
+			t.Fatalf("%s failed to verify for its statement: %v\n", name, err)
+		}
+		if ok, err := verifyOther(); ok || err == nil {
+			t.Fatalf("%s verified for a different statement\n", name)
+		}
+	}
+}
+
+func TestABCProofFromStatementKey(t *testing.T) {
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(10)
+	CM, r, _ := PedCommit(TestCurve, value)
+	stmt := ABCStatement{CM: CM, CMTok: TestCurve.Mult(PK, r), PubKey: PK}
+
+	other, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	if _, err := NewABCProofFromStatement(TestCurve, stmt, ABCWitness{value, other}, Right); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("ABCProof for a witness with the wrong key gives %v\n", err)
+	}
+	for _, bad := range []*big.Int{nil, big.NewInt(0)} {
+		if _, err := NewABCProofFromStatement(TestCurve, stmt, ABCWitness{value, bad}, Right); !errors.Is(err, ErrValueOutOfRange) {
+			t.Fatalf("ABCProof for SK = %v gives %v\n", bad, err)
+		}
+	}
+	noKey := ABCStatement{CM: stmt.CM, CMTok: stmt.CMTok}
+	if _, err := NewABCProofFromStatement(TestCurve, noKey, ABCWitness{value, sk}, Right); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("ABCProof for a statement without PubKey gives %v\n", err)
+	}
+}
@@ -0,0 +1,163 @@
+package zksigma
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ProofStatistics tells how big a proof is and how much work it is to verify,
+// for comparing the proofs with other proof systems
+type ProofStatistics struct {
+	Points      int // number of points in the proof
+	Scalars     int // number of scalars in the proof
+	ScalarMults int // estimated number of scalar multiplications done by Verify
+}
+
+func (s ProofStatistics) add(o ProofStatistics) ProofStatistics {
+	return ProofStatistics{s.Points + o.Points, s.Scalars + o.Scalars, s.ScalarMults + o.ScalarMults}
+}
+
+func (s ProofStatistics) times(n int) ProofStatistics {
+	return ProofStatistics{n * s.Points, n * s.Scalars, n * s.ScalarMults}
+}
+
+// proofShapes maps the proof types of fixed size to their statistics. The
+// scalar multiplications are the terms of the verification equations, nested
+// proofs included. Update it together with the fields and Verify of a proof.
+var proofShapes = map[reflect.Type]ProofStatistics{
+	// B, C, T1, T2, T3, CToken and c, j, k, l, plus disjuncAC and cTokenProof
+	reflect.TypeOf(&ABCProof{}): ProofStatistics{6, 4, 6}.add(disjunctiveShape).add(consistencyShape),
+
+	reflect.TypeOf(&BalanceProof{}):         {1, 2, 2},
+	reflect.TypeOf(&BitProof{}):             disjunctiveShape,
+	reflect.TypeOf(&ConsistencyProof{}):     consistencyShape,
+	reflect.TypeOf(&DecryptionProof{}):      {2, 2, 4},
+	reflect.TypeOf(&DisjunctiveProof{}):     disjunctiveShape,
+	reflect.TypeOf(&EquivOrZeroProof{}):     disjunctiveShape,
+	reflect.TypeOf(&EquivalenceProof{}):     {2, 2, 4},
+	reflect.TypeOf(&GSPFSProof{}):           {2, 2, 2},
+	reflect.TypeOf(&InequalityProof{}):      {3, 4, 6},
+	reflect.TypeOf(&KeyRotationProof{}):     {2, 2, 4},
+	reflect.TypeOf(&NonZeroProof{}):         {3, 4, 6},
+	reflect.TypeOf(&OpenProof{}):            {1, 3, 3},
+	reflect.TypeOf(&OpenToValueProof{}):     {1, 2, 3},
+	reflect.TypeOf(&ProductProof{}):         {3, 6, 9},
+	reflect.TypeOf(&RerandomizationProof{}): {1, 2, 2},
+	reflect.TypeOf(&SameBlindingProof{}):    {2, 2, 4},
+	reflect.TypeOf(&SameValueProof{}):       {1, 2, 2},
+	// the total is multiplied by G as well
+	reflect.TypeOf(&SumProof{}): {1, 2, 3},
+}
+
+var (
+	consistencyShape = ProofStatistics{2, 3, 5}
+	disjunctiveShape = ProofStatistics{2, 5, 4}
+)
+
+// ProofStats returns the ProofStatistics of proof p, which has to be one of
+// the proof types of this package or a ComposedProof of them. For proofs that
+// grow with their statement, like BitRangeProof, they are for the statement
+// of p.
+func ProofStats(p interface{}) (ProofStatistics, error) {
+	if s, ok := proofShapes[reflect.TypeOf(p)]; ok {
+		return s, nil
+	}
+
+	switch proof := p.(type) {
+	case *BitRangeProof:
+		if proof == nil {
+			break
+		}
+		// a commitment C per bit, its DisjunctiveProof and 2^i * C for the sum
+		return ProofStatistics{1, 0, 1}.add(disjunctiveShape).times(len(proof.BitCommits)), nil
+	case *OneOfManyProof:
+		if proof == nil {
+			break
+		}
+		// a T_i, c_i and s_i per branch and the shared c
+		return ProofStatistics{1, 2, 2}.times(len(proof.T)).add(ProofStatistics{0, 1, 0}), nil
+	case *ShuffleProof:
+		if proof == nil {
+			break
+		}
+		// c_k, CHat_i, THat_i, sHat_i and s'_i per commitment with two terms
+		// in each of the equations for T_1, T_3 and T_4 and three for THat_i,
+		// and T_1, ..., T_4, c, s_1, ..., s_4 with the other six terms
+		return ProofStatistics{3, 2, 9}.times(len(proof.C)).add(ProofStatistics{4, 5, 6}), nil
+	case *VectorOpenProof:
+		if proof == nil {
+			break
+		}
+		// an s_i and a term s_iH_i per value, and T, c, s with sH and cC
+		return ProofStatistics{0, 1, 1}.times(len(proof.S)).add(ProofStatistics{1, 2, 2}), nil
+	case *MultiProductProof:
+		if proof == nil {
+			break
+		}
+		// the equations of a ProductProof per link, a P_k per link but the
+		// last and the shared c
+		link := ProofStatistics{3, 5, 9}.times(len(proof.Links))
+		return link.add(ProofStatistics{len(proof.Partials), 1, 0}), nil
+	case *MultiRangeProof:
+		if proof == nil {
+			break
+		}
+		// C, c0, s0, s1 per bit with s0H and c0C for T0, s1H and c1(C - G)
+		// for T1 and 2^iC in the sum, and the shared c
+		bits := 0
+		for _, value := range proof.Values {
+			bits += len(value)
+		}
+		return ProofStatistics{1, 3, 5}.times(bits).add(ProofStatistics{0, 1, 0}), nil
+	case *RangeProof:
+		if proof == nil {
+			break
+		}
+		// a C_i and s_i per tuple, verifyGen multiplies three times, and
+		// ProofAggregate and ProofE
+		return ProofStatistics{1, 1, 3}.times(len(proof.ProofTuples)).add(ProofStatistics{1, 1, 0}), nil
+	case *SetMembershipProof:
+		if proof == nil || proof.Branches == nil {
+			break
+		}
+		// the OneOfManyProof plus s_iG for D_i per value
+		s, err := ProofStats(proof.Branches)
+		return s.add(ProofStatistics{0, 0, 1}.times(len(proof.Branches.T))), err
+	case *ComparisonProof:
+		if proof == nil || proof.Difference == nil {
+			break
+		}
+		return ProofStats(proof.Difference)
+	case *BoundedRangeProof:
+		if proof == nil || proof.Lower == nil || proof.Upper == nil {
+			break
+		}
+		lower, err := ProofStats(proof.Lower)
+		if err != nil {
+			return ProofStatistics{}, err
+		}
+		upper, err := ProofStats(proof.Upper)
+		return lower.add(upper), err
+	case *AggregateRangeProof:
+		if proof == nil || proof.Sum == nil {
+			break
+		}
+		return ProofStats(proof.Sum)
+	case *ComposedProof:
+		if proof == nil {
+			break
+		}
+		stats := ProofStatistics{0, 1, 0}
+		for i, sub := range proof.Proofs {
+			s, err := ProofStats(sub)
+			if err != nil {
+				return ProofStatistics{}, wrapError("ProofStats", fmt.Sprintf("proof %d: %v", i, err), err)
+			}
+			stats = stats.add(s)
+		}
+		return stats, nil
+	default:
+		return ProofStatistics{}, inputError("ProofStats", fmt.Sprintf("unknown proof type %T", p))
+	}
+	return ProofStatistics{}, malformedError("ProofStats", fmt.Sprintf("passed %T is nil", p))
+}
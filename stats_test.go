@@ -0,0 +1,160 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// TestProofSizes makes sure Size matches Bytes and pins the sizes and
+// statistics of the proofs, so a change of an encoding does not go unnoticed
+func TestProofSizes(t *testing.T) {
+	N := TestCurve.C.Params().N
+	sk, _ := rand.Int(rand.Reader, N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(30)
+	CM, r, _ := PedCommit(TestCurve, value)
+	CMTok := TestCurve.Mult(PK, r)
+	CM2, r2, _ := PedCommit(TestCurve, big.NewInt(12))
+	x, _ := rand.Int(rand.Reader, N)
+	A := TestCurve.Mult(TestCurve.G, x)
+	AH := TestCurve.Mult(TestCurve.H, x)
+	CMPrime, deltaR, _ := RerandomizeCommitment(TestCurve, CM)
+	bases := []ECPoint{TestCurve.G, TestCurve.H, PK, CM}
+	results := []ECPoint{TestCurve.G, TestCurve.H, TestCurve.Mult(PK, x), CM2}
+	vecValues := []*big.Int{value, big.NewInt(12), x}
+	vecCM, _ := VectorPedCommitR(TestCurve, vecValues, r)
+	newSK, _ := rand.Int(rand.Reader, N)
+	newPK := TestCurve.Mult(TestCurve.H, newSK)
+	newCMTok, _ := RotateToken(TestCurve, CMTok, sk, newSK)
+	sameCM, sameR, _ := PedCommit(TestCurve, value)
+	set := []*big.Int{big.NewInt(1), big.NewInt(5), value, big.NewInt(50)}
+
+	type sizedProof interface {
+		Size() int
+		Bytes() []byte
+	}
+	must := func(p sizedProof, err error) sizedProof {
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		return p
+	}
+	decryption := func(zkpcp ZKPCurveParams, CM, CMTok ECPoint, sk *big.Int) (sizedProof, error) {
+		p, _, err := NewDecryptionProof(zkpcp, CM, CMTok, sk)
+		return p, err
+	}
+	bit, _, err := NewBitProof(TestCurve, big.NewInt(1), r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	product, _, _, _, err := NewProductProof(TestCurve, big.NewInt(3), big.NewInt(4), r, r2, sk)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	_, multiProduct, err := NewMultiProductProof(TestCurve, []*big.Int{big.NewInt(3), big.NewInt(4), big.NewInt(5)}, []*big.Int{r, r2, sk, r2, r})
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	cases := []struct {
+		name  string
+		proof sizedProof
+		size  int
+		stats ProofStatistics
+		// versions is the number of version bytes of a fixed size proof,
+		// one per proof including the nested ones, or -1 if the proof has
+		// no fixed size
+		versions int
+	}{
+		{"ABCProof", must(NewABCProof(TestCurve, CM, CMTok, value, sk, Right)), 717, ProofStatistics{10, 12, 15}, 3},
+		{"ConsistencyProof", must(NewConsistencyProof(TestCurve, CM, CMTok, PK, value, r)), 163, ProofStatistics{2, 3, 5}, 1},
+		{"DisjunctiveProof", must(NewDisjunctiveProof(TestCurve, TestCurve.G, A, TestCurve.H, AH, x, Left)), 227, ProofStatistics{2, 5, 4}, 1},
+		{"BitProof", bit, 227, ProofStatistics{2, 5, 4}, 1},
+		{"EquivalenceProof", must(NewEquivalenceProof(TestCurve, TestCurve.G, A, TestCurve.H, AH, x)), 131, ProofStatistics{2, 2, 4}, 1},
+		{"GSPFSProof", must(NewGSPFSProof(TestCurve, A, x)), 131, ProofStatistics{2, 2, 2}, 1},
+		{"KeyRotationProof", must(NewKeyRotationProof(TestCurve, CMTok, newCMTok, PK, newPK, sk, newSK)), 131, ProofStatistics{2, 2, 4}, 1},
+		{"SameBlindingProof", must(NewSameBlindingProof(TestCurve, CMTok, TestCurve.Mult(newPK, r), PK, newPK, r)), 131, ProofStatistics{2, 2, 4}, 1},
+		{"InequalityProof", must(NewInequalityProof(TestCurve, CM, CM2, value, big.NewInt(12), r, r2)), 228, ProofStatistics{3, 4, 6}, 1},
+		{"NonZeroProof", must(NewNonZeroProof(TestCurve, CM, value, r)), 228, ProofStatistics{3, 4, 6}, 1},
+		{"OpenProof", must(NewOpenProof(TestCurve, CM, value, r)), 130, ProofStatistics{1, 3, 3}, 1},
+		{"OpenToValueProof", must(NewOpenToValueProof(TestCurve, CM, value, r)), 98, ProofStatistics{1, 2, 3}, 1},
+		{"DecryptionProof", must(decryption(TestCurve, CM, CMTok, sk)), 131, ProofStatistics{2, 2, 4}, 1},
+		{"ProductProof", product, 292, ProofStatistics{3, 6, 9}, 1},
+		{"MultiProductProof", multiProduct, 585, ProofStatistics{7, 11, 18}, -1},
+		{"RerandomizationProof", must(NewRerandomizationProof(TestCurve, CM, CMPrime, deltaR)), 98, ProofStatistics{1, 2, 2}, 1},
+		{"SameValueProof", must(NewSameValueProof(TestCurve, CM, sameCM, value, r, sameR)), 98, ProofStatistics{1, 2, 2}, 1},
+		{"EquivOrZeroProof", must(NewEquivOrZeroProof(TestCurve, CM, sameCM, r, sameR, Right)), 227, ProofStatistics{2, 5, 4}, 1},
+		{"BalanceProof", must(NewBalanceProof(TestCurve, CM, []ECPoint{sameCM}, []int{1}, r, []*big.Int{sameR})), 98, ProofStatistics{1, 2, 2}, 1},
+		{"SumProof", must(NewSumProof(TestCurve, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(12)}, []*big.Int{r, r2}, big.NewInt(42))), 98, ProofStatistics{1, 2, 3}, 1},
+		{"BitRangeProof", must(NewBitRangeProof(TestCurve, CM, value, r, 8)), 2081, ProofStatistics{24, 40, 40}, -1},
+		{"OneOfManyProof", must(NewOneOfManyProof(TestCurve, bases, results, 2, x)), 422, ProofStatistics{4, 9, 8}, -1},
+		{"ComparisonProof", must(NewComparisonProof(TestCurve, CM, CM2, value, big.NewInt(12), r, r2, 8)), 2081, ProofStatistics{24, 40, 40}, -1},
+		{"BoundedRangeProof", must(NewBoundedRangeProof(TestCurve, CM, value, r, big.NewInt(20), big.NewInt(40))), 2602, ProofStatistics{30, 50, 50}, -1},
+		{"MultiRangeProof", must(NewMultiRangeProof(TestCurve, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(12)}, []*big.Int{r, r2}, 8)), 2099, ProofStatistics{16, 49, 80}, -1},
+		{"AggregateRangeProof", must(NewAggregateRangeProof(TestCurve, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(12)}, []*big.Int{r, r2}, 8)), 2081, ProofStatistics{24, 40, 40}, -1},
+		{"SetMembershipProof", must(NewSetMembershipProof(TestCurve, CM, value, r, set)), 422, ProofStatistics{4, 9, 12}, -1},
+		{"ShuffleProof", must(NewShuffleProof(TestCurve, []ECPoint{CM, CM2, A}, []ECPoint{A, CMPrime, CM2}, []int{2, 0, 1},
+			[]*big.Int{big.NewInt(0), deltaR, big.NewInt(0)})), 783, ProofStatistics{13, 11, 33}, -1},
+		{"VectorOpenProof", must(NewVectorOpenProof(TestCurve, vecCM, vecValues, r)), 195, ProofStatistics{1, 5, 5}, -1},
+	}
+
+	for _, c := range cases {
+		if c.proof.Size() != len(c.proof.Bytes()) {
+			t.Fatalf("Size of %s is %d, but Bytes has %d\n", c.name, c.proof.Size(), len(c.proof.Bytes()))
+		}
+		if c.proof.Size() != c.size {
+			t.Fatalf("%s serializes to %d bytes, expected %d\n", c.name, c.proof.Size(), c.size)
+		}
+		stats, err := ProofStats(c.proof)
+		if err != nil || stats != c.stats {
+			t.Fatalf("ProofStats of %s is %+v, %v, expected %+v\n", c.name, stats, err, c.stats)
+		}
+		if c.versions >= 0 && c.versions+stats.Points*fixedPointLen+stats.Scalars*fixedScalarLen != c.size {
+			t.Fatalf("ProofStats of %s does not add up to its fixed size\n", c.name)
+		}
+	}
+}
+
+func TestProofStats(t *testing.T) {
+	rp, _, err := NewRangeProof(TestCurve, big.NewInt(30))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if rp.Size() != len(rp.Bytes()) {
+		t.Fatalf("Size of RangeProof is %d, but Bytes has %d\n", rp.Size(), len(rp.Bytes()))
+	}
+	// a RangeProof has 40 tuples
+	if stats, err := ProofStats(rp); err != nil || stats != (ProofStatistics{41, 41, 120}) {
+		t.Fatalf("ProofStats of RangeProof is %+v, %v\n", stats, err)
+	}
+
+	sk, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(30)
+	CM, r, _ := PedCommit(TestCurve, value)
+	CMTok := TestCurve.Mult(PK, r)
+	composed, err := NewComposedProof(TestCurve,
+		ABCStatement{CM: CM, CMTok: CMTok, PubKey: PK}.WithWitness(ABCWitness{Value: value, SK: sk}, Right),
+		ConsistencyStatement{CM: CM, CMTok: CMTok, PubKey: PK}.WithWitness(ConsistencyWitness{Value: value, Randomness: r}))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if stats, err := ProofStats(composed); err != nil || stats != (ProofStatistics{12, 16, 20}) {
+		t.Fatalf("ProofStats of ComposedProof is %+v, %v\n", stats, err)
+	}
+
+	if _, err := ProofStats(&ComposedProof{Proofs: []SubProof{nil}}); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("ProofStats of a ComposedProof with an unknown proof gives %v\n", err)
+	}
+	if _, err := ProofStats("ABCProof"); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("ProofStats of a string gives %v\n", err)
+	}
+	if _, err := ProofStats((*BitRangeProof)(nil)); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("ProofStats of a nil BitRangeProof gives %v\n", err)
+	}
+	if stats, err := ProofStats((*OpenProof)(nil)); err != nil || stats != (ProofStatistics{1, 3, 3}) {
+		t.Fatalf("ProofStats of a nil OpenProof is %+v, %v\n", stats, err)
+	}
+}
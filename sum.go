@@ -0,0 +1,327 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// SumProof is a proof that commitments CM_i = v_iG + r_iH hide values that add
+// up to a public total, without opening any of them. With R = sum(r_i) the sum
+// of the commitments minus total * G is RH, so the prover shows that it knows
+// the discrete log of that point with respect to H.
+//
+// The values are added mod N, a total of t also verifies for values that add
+// up to t + kN. Values that are proved to be in a small range (BitRangeProof)
+// can not wrap around, otherwise the total means nothing.
+//
+//  Public: generator points G and H, CM_1 ... CM_n, total
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows v_i, r_i with sum(v_i) = total
+//  R = sum(r_i)                        A = sum(CM_i) - total * G
+//  selects random u
+//  T = uH
+//  c = HASH(G, H, CM_1, ..., CM_n, total, T)
+//  s = u + c * R
+//
+//  T, c, s --------------------------->
+//                                      c ?= HASH(G, H, CM_1, ..., CM_n, total, T)
+//                                      sH ?= T + cA
+type SumProof struct {
+	T         ECPoint  // T = uH
+	Challenge *big.Int // c = HASH(G, H, CM_1, ..., CM_n, total, T)
+	S         *big.Int // s = u + c * R
+}
+
+// sumChallenge computes c = HASH(G, H, CM_1, ..., CM_n, total, T)
+func sumChallenge(zkpcp ZKPCurveParams, CMs []ECPoint, total *big.Int, T ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "SumProof")
+	t.AppendPoint("G", zkpcp.G)
+	t.AppendPoint("H", zkpcp.H)
+	for _, CM := range CMs {
+		t.AppendPoint("CM", CM)
+	}
+	t.AppendScalar("total", total)
+	t.AppendPoint("T", T)
+	return t.ChallengeScalar("c")
+}
+
+// sumPoint computes A = sum(CM_i) - total * G
+func sumPoint(zkpcp ZKPCurveParams, CMs []ECPoint, total *big.Int) ECPoint {
+	A := zkpcp.Mult(zkpcp.G, new(big.Int).Neg(total))
+	for _, CM := range CMs {
+		A = zkpcp.Add(A, CM)
+	}
+	return A
+}
+
+// NewSumProof generates a proof that the values of CMs add up to total mod N,
+// where values and randomnesses open CMs. The values have to be in [0, N),
+// total may be any integer.
+func NewSumProof(zkpcp ZKPCurveParams, CMs []ECPoint, values, randomnesses []*big.Int, total *big.Int) (*SumProof, error) {
+	return NewSumProofWithRand(zkpcp, entropy(), CMs, values, randomnesses, total)
+}
+
+// NewSumProofWithRand is the same as NewSumProof, but reads its random values
+// from rnd instead of the EntropySource
+func NewSumProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	CMs []ECPoint, values, randomnesses []*big.Int, total *big.Int) (*SumProof, error) {
+
+	N := zkpcp.C.Params().N
+
+	if len(CMs) == 0 {
+		return nil, inputError("SumProve", "no commitments to sum")
+	}
+	if total == nil {
+		return nil, rangeError("SumProve", "total is nil")
+	}
+	if len(values) != len(CMs) || len(randomnesses) != len(CMs) {
+		return nil, inputError("SumProve", "CMs, values and randomnesses must have the same length")
+	}
+	for i := range values {
+		if err := zkpcp.checkScalars("SumProve", fmt.Sprintf("value %d, randomness %d", i, i),
+			values[i], randomnesses[i]); err != nil {
+			return nil, err
+		}
+	}
+	if !zkpcp.validPoints(CMs...) {
+		return nil, curveError("SumProve")
+	}
+
+	sum := new(big.Int)
+	R := new(big.Int)
+	for i, CM := range CMs {
+		if !Open(zkpcp, values[i], randomnesses[i], CM) {
+			return nil, inputError("SumProve", fmt.Sprintf("value and randomness %d do not produce CM", i))
+		}
+		sum.Add(sum, values[i])
+		R.Add(R, randomnesses[i])
+	}
+	if sum.Sub(sum, total).Mod(sum, N).Sign() != 0 {
+		return nil, inputError("SumProve", "values do not add up to total")
+	}
+	R.Mod(R, N)
+
+	u, err := zkpcp.randScalar(rnd)
+	if err != nil {
+		return nil, err
+	}
+
+	T := zkpcp.Mult(zkpcp.H, u)
+	c := sumChallenge(zkpcp, CMs, total, T)
+
+	s := new(big.Int).Add(u, new(big.Int).Mul(c, R))
+	s.Mod(s, N)
+
+	return &SumProof{T, c, s}, nil
+}
+
+// NewSumZeroProof generates a SumProof for a total of 0, like the row of a
+// confidential transaction whose commitments balance, from the sum
+// totalRandomness of their randomness alone: it shows that the sum of CMs is
+// totalRandomness * H. CMs may be empty, its sum is Zero and totalRandomness
+// has to be 0 then. Verify it with VerifySumZero.
+func NewSumZeroProof(zkpcp ZKPCurveParams, CMs []ECPoint, totalRandomness *big.Int) (*SumProof, error) {
+	return NewSumZeroProofWithRand(zkpcp, entropy(), CMs, totalRandomness)
+}
+
+// NewSumZeroProofWithRand is the same as NewSumZeroProof, but reads its random
+// values from rnd instead of the EntropySource
+func NewSumZeroProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader, CMs []ECPoint, totalRandomness *big.Int) (*SumProof, error) {
+	if err := zkpcp.checkScalars("SumZeroProve", "totalRandomness", totalRandomness); err != nil {
+		return nil, err
+	}
+	if !zkpcp.validPoints(CMs...) {
+		return nil, curveError("SumZeroProve")
+	}
+	total := new(big.Int)
+	if !sumPoint(zkpcp, CMs, total).Equal(zkpcp.Mult(zkpcp.H, totalRandomness)) {
+		return nil, inputError("SumZeroProve", "commitments do not sum to totalRandomness * H")
+	}
+
+	u, err := zkpcp.randScalar(rnd)
+	if err != nil {
+		return nil, err
+	}
+
+	T := zkpcp.Mult(zkpcp.H, u)
+	c := sumChallenge(zkpcp, CMs, total, T)
+
+	s := new(big.Int).Add(u, new(big.Int).Mul(c, totalRandomness))
+	s.Mod(s, zkpcp.C.Params().N)
+
+	return &SumProof{T, c, s}, nil
+}
+
+// Verify checks if SumProof proof shows that the values of CMs add up to total
+// mod N
+func (proof *SumProof) Verify(zkpcp ZKPCurveParams, CMs []ECPoint, total *big.Int) (bool, error) {
+	if proof == nil {
+		return false, malformedError("SumProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+
+	if len(CMs) == 0 {
+		return false, inputError("SumProof.Verify", "no commitments to sum")
+	}
+	if total == nil {
+		return false, inputError("SumProof.Verify", "total is nil")
+	}
+	return proof.verify(zkpcp, "SumProof.Verify", CMs, total)
+}
+
+// VerifySumZero checks if SumProof proof shows that the values of CMs add up
+// to 0 mod N, so their sum is a multiple of H. It is Verify with a total of 0,
+// and also accepts an empty CMs like NewSumZeroProof.
+func (proof *SumProof) VerifySumZero(zkpcp ZKPCurveParams, CMs []ECPoint) (bool, error) {
+	if proof == nil {
+		return false, malformedError("SumProof.VerifySumZero", fmt.Sprintf("passed proof is nil"))
+	}
+	return proof.verify(zkpcp, "SumProof.VerifySumZero", CMs, new(big.Int))
+}
+
+// verify checks proof for CMs and total for Verify and VerifySumZero, t is the
+// name of the one calling it for the errors
+func (proof *SumProof) verify(zkpcp ZKPCurveParams, t string, CMs []ECPoint, total *big.Int) (bool, error) {
+	if !zkpcp.validPoints(CMs...) || !zkpcp.onCurve(proof.T) {
+		return false, curveError(t)
+	}
+	if err := zkpcp.canonicalScalars(t, "Challenge, S", proof.Challenge, proof.S); err != nil {
+		return false, err
+	}
+
+	c := sumChallenge(zkpcp, CMs, total, proof.T)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError(t, "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	// sH ?= T + cA, checked as sH - cA ?= T
+	lhs := zkpcp.MultiMult([]ECPoint{zkpcp.H, sumPoint(zkpcp, CMs, total)}, []*big.Int{proof.S, new(big.Int).Neg(c)})
+	if !pointsEqual(lhs, proof.T) {
+		return false, equationError(t, 1, "sH - cA != T", lhs, proof.T)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, args are CMs as []ECPoint and total as
+// *big.Int like for Verify
+func (proof *SumProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("SumProof.VerifyArgs", args)
+	CMs, total := a.points(), a.scalar()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, CMs, total)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *SumProof) String() string {
+	if proof == nil {
+		return "SumProof(nil)"
+	}
+	return proofString("SumProof", "T", proof.T, "Challenge", proof.Challenge, "S", proof.S)
+}
+
+// sumProofLen is the length of a serialized SumProof
+var sumProofLen = 1 + fixedPointLen + 2*fixedScalarLen
+
+// Bytes returns a byte slice with a fixed-size serialized representation of
+// SumProof proof
+func (proof *SumProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	writeFixedPoint(&buf, proof.T)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.S)
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of SumProof proof, which has a fixed size
+func (proof *SumProof) Size() int {
+	return sumProofLen
+}
+
+// Clone returns a deep copy of SumProof proof that shares no big.Int with it
+func (proof *SumProof) Clone() *SumProof {
+	if proof == nil {
+		return nil
+	}
+	return &SumProof{
+		T:         clonePoint(proof.T),
+		Challenge: cloneScalar(proof.Challenge),
+		S:         cloneScalar(proof.S),
+	}
+}
+
+// Equal returns true if SumProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *SumProof) Equal(other *SumProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.T, other.T) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalar(proof.S, other.S)
+}
+
+// NewSumProofFromBytes returns a SumProof generated from the deserialization of
+// byte slice b
+func NewSumProofFromBytes(b []byte) (*SumProof, error) {
+	if err := checkFixedHeader(b, sumProofLen, "SumProofFromBytes"); err != nil {
+		return nil, err
+	}
+	proof := new(SumProof)
+	buf := bytes.NewBuffer(b[1:])
+	var err error
+	if proof.T, err = readFixedPoint(buf, "SumProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "SumProofFromBytes", &proof.Challenge, &proof.S); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+type sumProofJSON struct {
+	T         string `json:"t"`
+	Challenge string `json:"challenge"`
+	S         string `json:"s"`
+}
+
+// MarshalJSON encodes SumProof proof with points as compressed hex and scalars
+// as big-endian hex
+func (proof *SumProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sumProofJSON{
+		T:         pointToHex(proof.T),
+		Challenge: scalarToHex(proof.Challenge),
+		S:         scalarToHex(proof.S),
+	})
+}
+
+// UnmarshalJSON decodes a SumProof encoded with MarshalJSON. It fails if a
+// point is not on the curve or a scalar is not in [0, N)
+func (proof *SumProof) UnmarshalJSON(b []byte) error {
+	var aux sumProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := SumProof{}
+	if p.T, err = pointFromHex(aux.T, "t"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.S, err = scalarFromHex(aux.S, "s"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
@@ -0,0 +1,241 @@
+package zksigma
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// makeSum commits to n random values and returns the commitments, their
+// openings and the total
+func makeSum(tb testing.TB, n int) ([]ECPoint, []*big.Int, []*big.Int, *big.Int) {
+	CMs := make([]ECPoint, n)
+	values := make([]*big.Int, n)
+	randomnesses := make([]*big.Int, n)
+	total := new(big.Int)
+	for ii := 0; ii < n; ii++ {
+		values[ii], _ = rand.Int(rand.Reader, big.NewInt(10000000000)) // "realistic range"
+		CM, r, err := PedCommit(TestCurve, values[ii])
+		if err != nil {
+			tb.Fatalf("%v\n", err)
+		}
+		CMs[ii], randomnesses[ii] = CM, r
+		total.Add(total, values[ii])
+	}
+	return CMs, values, randomnesses, total
+}
+
+func TestSumProof(t *testing.T) {
+	for _, n := range []int{1, 2, 100} {
+		CMs, values, randomnesses, total := makeSum(t, n)
+
+		proof, err := NewSumProof(TestCurve, CMs, values, randomnesses, total)
+		if err != nil {
+			t.Fatalf("SumProof failed to generate for %d commitments: %v\n", n, err)
+		}
+		if ok, err := proof.Verify(TestCurve, CMs, total); !ok || err != nil {
+			t.Fatalf("SumProof failed to verify for %d commitments: %v\n", n, err)
+		}
+
+		if ok, err := proof.Verify(TestCurve, CMs, new(big.Int).Add(total, big.NewInt(1))); ok || err == nil {
+			t.Fatalf("SumProof verified for the wrong total\n")
+		}
+		if ok, err := proof.Verify(TestCurve, append(CMs, TestCurve.G), total); ok || err == nil {
+			t.Fatalf("SumProof verified with an extra commitment\n")
+		}
+		if _, err := NewSumProof(TestCurve, CMs, values, randomnesses, new(big.Int).Sub(total, big.NewInt(1))); err == nil {
+			t.Fatalf("SumProof generated for the wrong total\n")
+		}
+
+		encoded := proof.Bytes()
+		if len(encoded) != sumProofLen || !bytes.Equal(encoded, proof.Bytes()) {
+			t.Fatalf("SumProof produced a non-deterministic or wrongly sized encoding\n")
+		}
+		decoded, err := NewSumProofFromBytes(encoded)
+		if err != nil {
+			t.Fatalf("SumProof failed to deserialize: %v\n", err)
+		}
+		if ok, err := decoded.Verify(TestCurve, CMs, total); !ok || err != nil {
+			t.Fatalf("SumProof failed to verify after deserialization: %v\n", err)
+		}
+	}
+}
+
+// TestSumProofWraps checks that totals are taken mod N
+func TestSumProofWraps(t *testing.T) {
+	N := TestCurve.C.Params().N
+	large := new(big.Int).Sub(N, big.NewInt(1))
+	values := []*big.Int{large, big.NewInt(5)}
+	CMs := make([]ECPoint, len(values))
+	randomnesses := make([]*big.Int, len(values))
+	for i, v := range values {
+		CMs[i], randomnesses[i], _ = PedCommit(TestCurve, v)
+	}
+
+	// N - 1 + 5 = 4 mod N
+	proof, err := NewSumProof(TestCurve, CMs, values, randomnesses, big.NewInt(4))
+	if err != nil {
+		t.Fatalf("SumProof failed to generate for a total that wraps: %v\n", err)
+	}
+	for _, total := range []*big.Int{big.NewInt(4), new(big.Int).Add(N, big.NewInt(4))} {
+		if ok, err := proof.Verify(TestCurve, CMs, total); !ok || err != nil {
+			t.Fatalf("SumProof failed to verify for total %v: %v\n", total, err)
+		}
+	}
+}
+
+func TestSumProofErrors(t *testing.T) {
+	CMs, values, randomnesses, total := makeSum(t, 3)
+
+	if _, err := NewSumProof(TestCurve, nil, nil, nil, big.NewInt(0)); err == nil ||
+		!strings.Contains(err.Error(), "no commitments") {
+		t.Fatalf("SumProof should not generate for no commitments, got %v\n", err)
+	}
+	if _, err := NewSumProof(TestCurve, CMs, values[1:], randomnesses, total); err == nil ||
+		!strings.Contains(err.Error(), "same length") {
+		t.Fatalf("SumProof should not generate for too few values, got %v\n", err)
+	}
+	if _, err := NewSumProof(TestCurve, CMs, values, randomnesses[:2], total); err == nil ||
+		!strings.Contains(err.Error(), "same length") {
+		t.Fatalf("SumProof should not generate for too few randomnesses, got %v\n", err)
+	}
+	randomnesses[1] = new(big.Int).Add(randomnesses[1], big.NewInt(1))
+	if _, err := NewSumProof(TestCurve, CMs, values, randomnesses, total); err == nil {
+		t.Fatalf("SumProof generated for a wrong opening\n")
+	}
+
+	proof, err := NewSumProof(TestCurve, CMs[:1], values[:1], randomnesses[:1], values[0])
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, nil, values[0]); ok || err == nil {
+		t.Fatalf("SumProof verified for no commitments\n")
+	}
+}
+
+// zeroRow commits to values that add up to 0 mod N, the last one is minus the
+// sum of the others, and returns the commitments and the sum of their
+// randomness
+func zeroRow(t *testing.T, n int) ([]ECPoint, *big.Int) {
+	N := TestCurve.C.Params().N
+	CMs, R, sum := make([]ECPoint, n), new(big.Int), new(big.Int)
+	for i := range CMs {
+		v := big.NewInt(int64(10 * (i + 1)))
+		if i == n-1 {
+			v.Mod(new(big.Int).Neg(sum), N)
+		}
+		sum.Add(sum, v)
+		CM, r, err := PedCommit(TestCurve, v)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		CMs[i] = CM
+		R.Add(R, r)
+	}
+	return CMs, R.Mod(R, N)
+}
+
+func TestSumZeroProof(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 5} {
+		CMs, R := zeroRow(t, n)
+		proof, err := NewSumZeroProof(TestCurve, CMs, R)
+		if err != nil {
+			t.Fatalf("SumZeroProof failed to generate for %d commitments: %v\n", n, err)
+		}
+		if ok, err := proof.VerifySumZero(TestCurve, CMs); !ok || err != nil {
+			t.Fatalf("SumZeroProof failed to verify for %d commitments: %v\n", n, err)
+		}
+		decoded, err := NewSumProofFromBytes(proof.Bytes())
+		if err != nil {
+			t.Fatalf("SumZeroProof failed to deserialize: %v\n", err)
+		}
+		if ok, err := decoded.VerifySumZero(TestCurve, CMs); !ok || err != nil {
+			t.Fatalf("SumZeroProof failed to verify after deserialization: %v\n", err)
+		}
+		if n == 0 {
+			continue
+		}
+		// it is a SumProof for a total of 0
+		if ok, err := proof.Verify(TestCurve, CMs, big.NewInt(0)); !ok || err != nil {
+			t.Fatalf("SumZeroProof of %d commitments is not a SumProof for 0: %v\n", n, err)
+		}
+
+		// shifting any one value by 1 breaks it
+		for i := range CMs {
+			shifted := append([]ECPoint{}, CMs...)
+			shifted[i] = TestCurve.Add(shifted[i], TestCurve.G)
+			if ok, err := proof.VerifySumZero(TestCurve, shifted); ok || !errors.Is(err, ErrChallengeMismatch) {
+				t.Fatalf("SumZeroProof verified with value %d of %d shifted by 1: %v\n", i, n, err)
+			}
+			if _, err := NewSumZeroProof(TestCurve, shifted, R); !errors.Is(err, ErrInvalidInput) {
+				t.Fatalf("SumZeroProof generated with value %d of %d shifted by 1: %v\n", i, n, err)
+			}
+		}
+	}
+
+	// a SumProof of values adding up to 0 verifies with VerifySumZero too
+	N := TestCurve.C.Params().N
+	values := []*big.Int{big.NewInt(7), new(big.Int).Sub(N, big.NewInt(7))}
+	CMs, rs := make([]ECPoint, 2), make([]*big.Int, 2)
+	for i, v := range values {
+		CMs[i], rs[i], _ = PedCommit(TestCurve, v)
+	}
+	proof, err := NewSumProof(TestCurve, CMs, values, rs, big.NewInt(0))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.VerifySumZero(TestCurve, CMs); !ok || err != nil {
+		t.Fatalf("SumProof for 0 failed VerifySumZero: %v\n", err)
+	}
+}
+
+func TestSumZeroProofErrors(t *testing.T) {
+	CMs, R := zeroRow(t, 3)
+	if _, err := NewSumZeroProof(TestCurve, CMs, nil); !errors.Is(err, ErrValueOutOfRange) {
+		t.Fatalf("SumZeroProof generated without randomness: %v\n", err)
+	}
+	if _, err := NewSumZeroProof(TestCurve, CMs, new(big.Int).Add(R, big.NewInt(1))); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("SumZeroProof generated for the wrong randomness: %v\n", err)
+	}
+	if _, err := NewSumZeroProof(TestCurve, nil, big.NewInt(1)); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("SumZeroProof generated for no commitments and randomness 1: %v\n", err)
+	}
+	if _, err := NewSumZeroProof(TestCurve, []ECPoint{{big.NewInt(1), big.NewInt(1)}}, R); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("SumZeroProof generated for a point not on the curve: %v\n", err)
+	}
+
+	proof, err := NewSumZeroProof(TestCurve, CMs, R)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.VerifySumZero(TestCurve, CMs[:2]); ok || err == nil {
+		t.Fatalf("SumZeroProof verified for a commitment less\n")
+	}
+	if ok, err := proof.Verify(TestCurve, CMs, big.NewInt(1)); ok || err == nil {
+		t.Fatalf("SumZeroProof verified for a total of 1\n")
+	}
+	var nilProof *SumProof
+	if _, err := nilProof.VerifySumZero(TestCurve, CMs); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("VerifySumZero of a nil SumProof gives %v\n", err)
+	}
+}
+
+func BenchmarkSumProve_100(b *testing.B) {
+	CMs, values, randomnesses, total := makeSum(b, 100)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewSumProof(TestCurve, CMs, values, randomnesses, total)
+	}
+}
+
+func BenchmarkSumVerify_100(b *testing.B) {
+	CMs, values, randomnesses, total := makeSum(b, 100)
+	proof, _ := NewSumProof(TestCurve, CMs, values, randomnesses, total)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, CMs, total)
+	}
+}
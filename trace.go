@@ -0,0 +1,187 @@
+package zksigma
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// VerifyTrace is a record of everything a verification computed, for bug
+// reports about proofs that do not verify. It has the statement and proof
+// points and scalars, the bytes the challenge was hashed from, both sides of
+// every verification equation and the traces of the nested proofs, and can be
+// marshaled to JSON. Verification uses no secrets, so a trace is safe to log.
+//
+// Points are hex encoded like ECPoint.MarshalText and scalars as big-endian
+// hex. A trace ends at the check that failed, so its last equation or nested
+// trace is the one to look at, see Failed.
+type VerifyTrace struct {
+	Proof     string             `json:"proof"`
+	Points    map[string]ECPoint `json:"points"`
+	Scalars   map[string]string  `json:"scalars"`
+	Preimage  string             `json:"preimage,omitempty"`  // hex of the bytes hashed into the challenge
+	Challenge string             `json:"challenge,omitempty"` // calculated from Preimage
+	Equations []TraceEquation    `json:"equations,omitempty"`
+	Nested    []*VerifyTrace     `json:"nested,omitempty"`
+	Verified  bool               `json:"verified"`
+	Error     string             `json:"error,omitempty"`
+
+	preimage []byte
+}
+
+// TraceEquation is a verification equation in a VerifyTrace, it holds if LHS
+// and RHS are the same point
+type TraceEquation struct {
+	Number   int     `json:"number"` // as in ProofError.Equation
+	Equation string  `json:"equation"`
+	LHS      ECPoint `json:"lhs"`
+	RHS      ECPoint `json:"rhs"`
+	OK       bool    `json:"ok"`
+}
+
+// newTrace returns an empty VerifyTrace for a proof of type proof
+func newTrace(proof string) *VerifyTrace {
+	return &VerifyTrace{
+		Proof:   proof,
+		Points:  make(map[string]ECPoint),
+		Scalars: make(map[string]string),
+	}
+}
+
+// Failed returns the trace of the proof whose check failed and the equation
+// that does not hold, which is nil if it was another check, e.g. the
+// challenge. It returns nil, nil if tr verified.
+func (tr *VerifyTrace) Failed() (*VerifyTrace, *TraceEquation) {
+	if tr == nil || tr.Verified {
+		return nil, nil
+	}
+	for _, nested := range tr.Nested {
+		if f, eq := nested.Failed(); f != nil {
+			return f, eq
+		}
+	}
+	for ii := range tr.Equations {
+		if !tr.Equations[ii].OK {
+			return tr, &tr.Equations[ii]
+		}
+	}
+	return tr, nil
+}
+
+// The methods below record into a trace and do nothing on a nil one, so the
+// verify functions call them unconditionally.
+
+// points records ps under the comma separated names
+func (tr *VerifyTrace) points(names string, ps ...ECPoint) {
+	if tr == nil {
+		return
+	}
+	for ii, name := range strings.Split(names, ", ") {
+		tr.Points[name] = ps[ii]
+	}
+}
+
+// scalars records ss under the comma separated names
+func (tr *VerifyTrace) scalars(names string, ss ...*big.Int) {
+	if tr == nil {
+		return
+	}
+	for ii, name := range strings.Split(names, ", ") {
+		tr.Scalars[name] = scalarString(ss[ii])
+	}
+}
+
+// transcript returns NewTranscript(zkpcp, domain), which keeps the bytes it
+// hashes for challenge if tr is not nil
+func (tr *VerifyTrace) transcript(zkpcp ZKPCurveParams, domain string) *Transcript {
+	t := NewTranscript(zkpcp, domain)
+	if tr == nil || t.h == nil {
+		return t
+	}
+	// hash the domain again, now with the trace
+	t.h.Reset()
+	t.trace = &tr.preimage
+	t.append("zksigma", []byte(domain))
+	return t
+}
+
+// challenge returns the challenge of transcript t, made by tr.transcript, and
+// records it and its preimage
+func (tr *VerifyTrace) challenge(t *Transcript) *big.Int {
+	if tr != nil && t.h == nil {
+		for _, m := range t.legacy {
+			tr.preimage = append(tr.preimage, m...)
+		}
+	}
+	c := t.ChallengeScalar("c")
+	if tr != nil {
+		tr.Preimage = hex.EncodeToString(tr.preimage)
+		tr.Challenge = scalarString(c)
+	}
+	return c
+}
+
+// equation checks equation number n of proof type t, which holds if lhs and
+// rhs are the same point, records it and returns the equationError if it does
+// not hold. eq is written like for equationError, as the failing equation.
+func (tr *VerifyTrace) equation(t string, n int, eq string, lhs, rhs ECPoint) error {
+	ok := pointsEqual(lhs, rhs)
+	if tr != nil {
+		tr.Equations = append(tr.Equations, TraceEquation{n, strings.Replace(eq, "!=", "?=", 1), lhs, rhs, ok})
+	}
+	if !ok {
+		return equationError(t, n, eq, lhs, rhs)
+	}
+	return nil
+}
+
+// nest returns a new trace for a nested proof of type proof, or nil if tr is
+// nil
+func (tr *VerifyTrace) nest(proof string) *VerifyTrace {
+	if tr == nil {
+		return nil
+	}
+	nested := newTrace(proof)
+	tr.Nested = append(tr.Nested, nested)
+	return nested
+}
+
+// result records the outcome err of the verification
+func (tr *VerifyTrace) result(err error) {
+	if tr == nil {
+		return
+	}
+	tr.Verified = err == nil
+	if err != nil {
+		tr.Error = err.Error()
+	}
+}
+
+// String returns the trace as indented lines for debugging
+func (tr *VerifyTrace) String() string {
+	if tr == nil {
+		return "VerifyTrace(nil)"
+	}
+	var b strings.Builder
+	tr.write(&b, "")
+	return b.String()
+}
+
+// write writes tr to b with every line prefixed by indent
+func (tr *VerifyTrace) write(b *strings.Builder, indent string) {
+	fmt.Fprintf(b, "%s%s verified: %v", indent, tr.Proof, tr.Verified)
+	if tr.Error != "" {
+		fmt.Fprintf(b, " (%s)", tr.Error)
+	}
+	b.WriteString("\n")
+	if tr.Challenge != "" {
+		fmt.Fprintf(b, "%s  challenge %s of %d bytes\n", indent, tr.Challenge, len(tr.Preimage)/2)
+	}
+	for _, eq := range tr.Equations {
+		fmt.Fprintf(b, "%s  %d. %s: %v\n%s     lhs %v\n%s     rhs %v\n", indent, eq.Number, eq.Equation, eq.OK, indent, eq.LHS, indent, eq.RHS)
+	}
+	for _, nested := range tr.Nested {
+		nested.write(b, indent+"  ")
+	}
+}
@@ -0,0 +1,163 @@
+package zksigma
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// traceABC returns an ABCProof for a random commitment and the statement it
+// verifies for
+func traceABC(t *testing.T) (proof *ABCProof, CM, CMTok, PK ECPoint) {
+	sk := scalarFromSeed("trace sk")
+	PK = TestCurve.Mult(TestCurve.H, sk)
+	CM, ua, err := PedCommit(TestCurve, big.NewInt(25))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	CMTok = TestCurve.Mult(PK, ua)
+	proof, err = NewABCProof(TestCurve, CM, CMTok, big.NewInt(25), sk, Right)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	return proof, CM, CMTok, PK
+}
+
+// checkPreimage fails if the challenge of tr is not the one of its preimage
+func checkPreimage(t *testing.T, tr *VerifyTrace) {
+	b, err := hex.DecodeString(tr.Preimage)
+	if err != nil {
+		t.Fatalf("%s has preimage %q: %v\n", tr.Proof, tr.Preimage, err)
+	}
+	var c *big.Int
+	if LegacyChallenges {
+		c = GenerateChallenge(TestCurve, b)
+	} else {
+		sum := sha256.Sum256(b)
+		c = reduceDigest(TestCurve, sum[:])
+	}
+	if scalarString(c) != tr.Challenge {
+		t.Fatalf("%s has challenge %s, its preimage hashes to %x\n", tr.Proof, tr.Challenge, c)
+	}
+}
+
+func TestVerifyTrace(t *testing.T) {
+	defer func(old bool) { LegacyChallenges = old }(LegacyChallenges)
+	for _, legacy := range []bool{false, true} {
+		LegacyChallenges = legacy
+		proof, CM, CMTok, PK := traceABC(t)
+
+		tr, err := proof.VerifyWithTrace(TestCurve, CM, CMTok, PK)
+		if err != nil || !tr.Verified || tr.Error != "" {
+			t.Fatalf("ABCProof does not verify with trace: %v\n", err)
+		}
+		if f, eq := tr.Failed(); f != nil || eq != nil {
+			t.Fatalf("verified trace has failed %v\n", f)
+		}
+		if len(tr.Nested) != 2 || tr.Nested[0].Proof != "DisjunctiveProof" || tr.Nested[1].Proof != "ConsistencyProof" {
+			t.Fatalf("ABCProof trace has nested traces %v\n", tr.Nested)
+		}
+		for _, nested := range append([]*VerifyTrace{tr}, tr.Nested...) {
+			if !nested.Verified || len(nested.Equations) != 2 {
+				t.Fatalf("%s trace has %d equations\n", nested.Proof, len(nested.Equations))
+			}
+			for _, eq := range nested.Equations {
+				if !eq.OK || !eq.LHS.Equal(eq.RHS) {
+					t.Fatalf("%s equation %d does not hold in trace\n", nested.Proof, eq.Number)
+				}
+			}
+			checkPreimage(t, nested)
+			if nested.Challenge != nested.Scalars["Challenge"] && nested.Challenge != nested.Scalars["C"] {
+				t.Fatalf("%s trace has challenge %s and scalars %v\n", nested.Proof, nested.Challenge, nested.Scalars)
+			}
+		}
+		if !tr.Points["CM"].Equal(CM) || !tr.Points["T2"].Equal(proof.T2) || tr.Scalars["l"] != scalarString(proof.l) {
+			t.Fatalf("ABCProof trace does not have the points and scalars of the proof\n")
+		}
+
+		// the JSON of a trace decodes to the same trace
+		b, err := json.Marshal(tr)
+		if err != nil {
+			t.Fatalf("trace does not marshal: %v\n", err)
+		}
+		var decoded VerifyTrace
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			t.Fatalf("trace does not unmarshal: %v\n", err)
+		}
+		again, _ := json.Marshal(&decoded)
+		if !bytes.Equal(b, again) {
+			t.Fatalf("trace changes in JSON:\n%s\n%s\n", b, again)
+		}
+	}
+}
+
+func TestVerifyTraceCorrupted(t *testing.T) {
+	proof, CM, CMTok, PK := traceABC(t)
+
+	// l is only in the second equation, the challenge still matches
+	bad := proof.Clone()
+	bad.l = new(big.Int).Add(bad.l, big.NewInt(1))
+	tr, err := bad.VerifyWithTrace(TestCurve, CM, CMTok, PK)
+	var pe *ProofError
+	if !errors.As(err, &pe) || pe.Equation != 2 || tr.Verified {
+		t.Fatalf("ABCProof with a wrong l gives %v\n", err)
+	}
+	if _, verr := bad.Verify(TestCurve, CM, CMTok, PK); verr.Error() != err.Error() || tr.Error != err.Error() {
+		t.Fatalf("VerifyWithTrace gives %v, Verify %v\n", err, verr)
+	}
+	f, eq := tr.Failed()
+	if f != tr || eq == nil || eq.Number != 2 || eq.OK || eq.Equation != "jB + lH - cC ?= T2" {
+		t.Fatalf("trace of ABCProof with a wrong l fails at %v\n", eq)
+	}
+	if eq.LHS.Equal(eq.RHS) || !eq.RHS.Equal(bad.T2) || !tr.Equations[0].OK {
+		t.Fatalf("trace of ABCProof with a wrong l has equations %v\n", tr.Equations)
+	}
+	// one more H on the left is exactly the difference
+	if !TestCurve.Sub(eq.LHS, TestCurve.H).Equal(eq.RHS) {
+		t.Fatalf("trace of ABCProof with a wrong l does not differ by H\n")
+	}
+
+	// a nested proof that fails is where the trace points to, and no
+	// checks after it are in the trace
+	bad = proof.Clone()
+	bad.disjuncAC.S1 = new(big.Int).Add(bad.disjuncAC.S1, big.NewInt(1))
+	tr, err = bad.VerifyWithTrace(TestCurve, CM, CMTok, PK)
+	if !errors.Is(err, ErrEquationFailed) {
+		t.Fatalf("ABCProof with a wrong nested S1 gives %v\n", err)
+	}
+	f, eq = tr.Failed()
+	if f != tr.Nested[0] || f.Proof != "DisjunctiveProof" || f.Verified || f.Error == "" || eq == nil || eq.Number != 1 {
+		t.Fatalf("trace of ABCProof with a wrong nested S1 fails at %v\n", f)
+	}
+	if len(tr.Nested) != 1 || len(tr.Equations) != 0 {
+		t.Fatalf("trace of ABCProof goes on after the failing DisjunctiveProof\n")
+	}
+
+	// a changed point gives another challenge, before any equation
+	bad = proof.Clone()
+	bad.cTokenProof.T2 = TestCurve.Add(bad.cTokenProof.T2, TestCurve.G)
+	tr, err = bad.cTokenProof.VerifyWithTrace(TestCurve, bad.C, bad.CToken, PK)
+	if !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("ConsistencyProof with a wrong T2 gives %v\n", err)
+	}
+	f, eq = tr.Failed()
+	if f != tr || eq != nil || len(tr.Equations) != 0 || tr.Challenge == tr.Scalars["Challenge"] {
+		t.Fatalf("trace of ConsistencyProof with a wrong T2 fails at %v\n", eq)
+	}
+	checkPreimage(t, tr)
+
+	// a trace ends at a proof that is not on the curve
+	bad = proof.Clone()
+	bad.disjuncAC.T1 = ECPoint{big.NewInt(1), big.NewInt(1)}
+	tr, err = bad.disjuncAC.VerifyWithTrace(TestCurve, CM, CMTok, TestCurve.H, TestCurve.Sub(bad.C, TestCurve.G))
+	if !errors.Is(err, ErrNotOnCurve) || tr.Verified || len(tr.Points) != 0 {
+		t.Fatalf("DisjunctiveProof off the curve gives %v and trace %v\n", err, tr)
+	}
+	if _, err := (*ABCProof)(nil).VerifyWithTrace(TestCurve, CM, CMTok, PK); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("nil ABCProof gives %v\n", err)
+	}
+}
@@ -0,0 +1,237 @@
+package zksigma
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"math/big"
+)
+
+// LegacyChallenges makes the proofs derive their challenges with
+// GenerateChallenge from the plain concatenation of their transcripts, the way
+// they did before Transcript. Turn it on to generate or verify proofs made by
+// older versions. Those challenges are not domain separated, so proofs of
+// different types with inputs that line up share a challenge.
+var LegacyChallenges = false
+
+// Transcript is the Fiat-Shamir transcript of a proof. Every message is
+// hashed as len(label) || label || len(data) || data with 4 byte big-endian
+// lengths into a running SHA-256, or the ChallengeHash of the curve, so nothing
+// is concatenated up front and messages can not be shifted into each other.
+// The first message is the domain, so transcripts of different proof types
+// never agree. With a ChallengeHash other than SHA-256 the second one is its
+// name under the label "hash".
+//
+// A Transcript made while LegacyChallenges is set ignores the domain and the
+// labels, and ChallengeScalar returns GenerateChallenge of the raw messages.
+type Transcript struct {
+	zkpcp  ZKPCurveParams
+	width  int       // bytes of a coordinate or scalar
+	h      hash.Hash // nil in legacy mode
+	legacy [][]byte  // messages in legacy mode
+	domain string
+	trace  *[]byte // everything hashed until the challenge, if traced
+
+	// scratch space for the encodings of the messages and the digest, so
+	// adding to a transcript does not allocate
+	head [32]byte
+	enc  [2 * 66]byte
+	sum  [64]byte // the digest of SHA-512, the largest ChallengeHash
+}
+
+// NewTranscript returns a Transcript for a proof of type domain over the curve of
+// zkpcp
+func NewTranscript(zkpcp ZKPCurveParams, domain string) *Transcript {
+	return newTranscript(zkpcp, domain, LegacyChallenges)
+}
+
+// newTranscript is NewTranscript with the mode given by legacy
+func newTranscript(zkpcp ZKPCurveParams, domain string, legacy bool) *Transcript {
+	t := &Transcript{
+		zkpcp:  zkpcp,
+		width:  (zkpcp.C.Params().BitSize + 7) / 8,
+		domain: domain,
+	}
+	if legacy {
+		return t
+	}
+	t.h = zkpcp.newHash()
+	t.append("zksigma", []byte(domain))
+	if zkpcp.challengeHash != 0 {
+		t.append("hash", []byte(zkpcp.challengeHash.String()))
+	}
+	return t
+}
+
+// append hashes the message data labeled label
+func (t *Transcript) append(label string, data []byte) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(label)))
+	head := append(append(t.head[:0], n[:]...), label...)
+	binary.BigEndian.PutUint32(n[:], uint32(len(data)))
+	head = append(head, n[:]...)
+	t.h.Write(head)
+	t.h.Write(data)
+	if t.trace != nil {
+		*t.trace = append(append(*t.trace, head...), data...)
+	}
+}
+
+// bindMessage adds the application message msg, e.g. a transaction ID, to the
+// transcript, so the challenge depends on it. In legacy mode it is prefixed
+// with its 4 byte big-endian length. An empty msg adds nothing, so proofs
+// without a message are the same as before.
+func (t *Transcript) bindMessage(msg []byte) {
+	if len(msg) == 0 {
+		return
+	}
+	if t.h == nil {
+		var n [4]byte
+		binary.BigEndian.PutUint32(n[:], uint32(len(msg)))
+		t.legacy = append(t.legacy, append(n[:], msg...))
+		return
+	}
+	t.append("msg", msg)
+}
+
+// AppendMessage adds data to the transcript under label
+func (t *Transcript) AppendMessage(label string, data []byte) {
+	if t.h == nil {
+		t.legacy = append(t.legacy, data)
+		return
+	}
+	t.append(label, data)
+}
+
+// AppendPoint adds p to the transcript under label. Both coordinates are
+// padded to the size of the field, so a point is always encoded the same way.
+func (t *Transcript) AppendPoint(label string, p ECPoint) {
+	if t.h == nil {
+		t.legacy = append(t.legacy, p.Bytes())
+		return
+	}
+	t.append(label, appendFixedWidth(appendFixedWidth(t.enc[:0], p.X, t.width), p.Y, t.width))
+}
+
+// AppendScalar adds s mod N to the transcript under label
+func (t *Transcript) AppendScalar(label string, s *big.Int) {
+	v, N := s, t.zkpcp.C.Params().N
+	if s == nil || s.Sign() < 0 || s.Cmp(N) >= 0 {
+		v = new(big.Int)
+		if s != nil {
+			v.Mod(s, N)
+		}
+	}
+	if t.h == nil {
+		t.legacy = append(t.legacy, v.Bytes())
+		return
+	}
+	t.append(label, appendFixedWidth(t.enc[:0], v, t.width))
+}
+
+// ChallengeScalar returns a challenge mod N derived from everything added so
+// far and label. The challenge is added to the transcript, so a second
+// challenge differs from the first. The digest is expanded and reduced like
+// in GenerateChallengeModN, unless NarrowChallenges is set for the curve.
+func (t *Transcript) ChallengeScalar(label string) *big.Int {
+	if t.h == nil {
+		return GenerateChallenge(t.zkpcp, t.legacy...)
+	}
+	t.append(label, nil)
+	sum := t.h.Sum(t.sum[:0])
+	t.trace = nil
+	t.append(label, sum)
+	if t.zkpcp.NarrowChallenges {
+		return new(big.Int).Mod(new(big.Int).SetBytes(sum), t.zkpcp.C.Params().N)
+	}
+	return reduceDigest(t.zkpcp, sum)
+}
+
+// appendTranscript adds the state of transcript sub to t, so everything sub
+// was given is bound into the challenges of t
+func (t *Transcript) appendTranscript(label string, sub *Transcript) {
+	t.append(label, sub.h.Sum(nil))
+}
+
+// clone returns a copy of t that can be added to without changing t
+func (t *Transcript) clone() *Transcript {
+	c := *t
+	if t.h == nil {
+		c.legacy = append([][]byte{}, t.legacy...)
+		return &c
+	}
+	state, err := t.h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		panic(err) // WithChallengeHash only takes hashes that can marshal their state
+	}
+	c.h = t.zkpcp.newHash()
+	if err := c.h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		panic(err) // state came from the same hash
+	}
+	return &c
+}
+
+// minChallengeHashSize is the shortest digest WithChallengeHash takes, in
+// bytes, so the challenges keep 128 bits of security
+const minChallengeHashSize = 32
+
+// WithChallengeHash returns a copy of zkpcp that derives the challenges of its
+// proofs with h instead of SHA-256, e.g. crypto.SHA512_256, or
+// crypto.BLAKE2b_256 with golang.org/x/crypto/blake2b linked in. zkpcp itself
+// is not changed. Transcript, GenerateChallenge and GenerateChallengeModN all
+// use it, and RangeProof hashes its ring with it. It fails if h is not linked
+// into the binary, has a digest shorter than 32 bytes or can not marshal its
+// state, which the transcripts need to be cloned.
+//
+// The proofs do not carry the hash, a verifier has to use a ZKPCurveParams with
+// the same ChallengeHash as the prover. Under any other hash the challenges
+// differ and the proofs fail with ErrChallengeMismatch.
+func (zkpcp ZKPCurveParams) WithChallengeHash(h crypto.Hash) (ZKPCurveParams, error) {
+	if !h.Available() {
+		return ZKPCurveParams{}, inputError("WithChallengeHash", fmt.Sprintf("hash %v is not linked into the binary", h))
+	}
+	if h.Size() < minChallengeHashSize {
+		return ZKPCurveParams{}, inputError("WithChallengeHash",
+			fmt.Sprintf("hash %v has a digest of %d bytes, less than %d", h, h.Size(), minChallengeHashSize))
+	}
+	if _, ok := h.New().(encoding.BinaryMarshaler); !ok {
+		return ZKPCurveParams{}, inputError("WithChallengeHash", fmt.Sprintf("hash %v can not marshal its state", h))
+	}
+	if h == crypto.SHA256 {
+		h = 0
+	}
+	zkpcp.challengeHash = h
+	return zkpcp, nil
+}
+
+// ChallengeHash returns the hash zkpcp derives its challenges with, which is
+// crypto.SHA256 unless WithChallengeHash picked another one
+func (zkpcp ZKPCurveParams) ChallengeHash() crypto.Hash {
+	if zkpcp.challengeHash == 0 {
+		return crypto.SHA256
+	}
+	return zkpcp.challengeHash
+}
+
+// newHash returns a new hash.Hash of the ChallengeHash of zkpcp
+func (zkpcp ZKPCurveParams) newHash() hash.Hash {
+	if zkpcp.challengeHash == 0 {
+		return sha256.New()
+	}
+	return zkpcp.challengeHash.New()
+}
+
+// digest returns the ChallengeHash of data, for the legacy challenges of
+// RangeProof
+func (zkpcp ZKPCurveParams) digest(data []byte) []byte {
+	if zkpcp.challengeHash == 0 {
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}
+	h := zkpcp.challengeHash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,564 @@
+package zksigma
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// TestTranscriptVectors checks the challenge derivation against vectors
+// computed independently from the encoding described at Transcript
+func TestTranscriptVectors(t *testing.T) {
+	defer func(old bool) { LegacyChallenges = old }(LegacyChallenges)
+	LegacyChallenges = false
+
+	vectors := func(zkpcp ZKPCurveParams) (*big.Int, *big.Int) {
+		tr := NewTranscript(zkpcp, "test")
+		tr.AppendPoint("G", zkpcp.G)
+		tr.AppendScalar("x", big.NewInt(5))
+		tr.AppendMessage("m", []byte("zksigma"))
+		return tr.ChallengeScalar("c1"), tr.ChallengeScalar("c2")
+	}
+	c1, c2 := vectors(TestCurve)
+	narrow := TestCurve
+	narrow.NarrowChallenges = true
+	n1, n2 := vectors(narrow)
+
+	for _, v := range []struct {
+		c      *big.Int
+		expect string
+	}{
+		{c1, "d75da4b0329802837d3c49d33c14821f0bf6f5ecc57a9a7b342b04658ca4e3cb"},
+		{c2, "f34abe7716230a7699ca032c5a539ed7ed9a22e73c074a7facb22ca1f0702ae1"},
+		{n1, "c307af9361f580e76a2be726569dbef33d90792078a9a7d97bf1bba19238c544"},
+		{n2, "7f878b7029e3e51038509844aa12b9acbcec2c02dc127552c2e0359040386dda"},
+	} {
+		if got := scalarToHex(v.c); got != v.expect {
+			t.Errorf("TestTranscriptVectors - got challenge %s, expected %s\n", got, v.expect)
+		}
+	}
+	if c1.Cmp(c2) == 0 {
+		t.Fatalf("TestTranscriptVectors - two challenges of one transcript are equal\n")
+	}
+
+	// the same messages split, labeled or named differently
+	challenge := func(domain string, msgs ...string) *big.Int {
+		tr := NewTranscript(TestCurve, domain)
+		for i := 0; i < len(msgs); i += 2 {
+			tr.AppendMessage(msgs[i], []byte(msgs[i+1]))
+		}
+		return tr.ChallengeScalar("c")
+	}
+	base := challenge("test", "a", "bc")
+	for _, other := range []*big.Int{
+		challenge("test", "a", "b", "a", "c"),
+		challenge("test", "ab", "c"),
+		challenge("test", "b", "bc"),
+		challenge("other", "a", "bc"),
+		challenge("test", "a", "bc", "a", ""),
+	} {
+		if base.Cmp(other) == 0 {
+			t.Fatalf("TestTranscriptVectors - different transcripts give the same challenge\n")
+		}
+	}
+	if base.Cmp(challenge("test", "a", "bc")) != 0 {
+		t.Fatalf("TestTranscriptVectors - challenge is not deterministic\n")
+	}
+
+	// a clone continues from the same state without changing the original
+	tr := NewTranscript(TestCurve, "test")
+	tr.AppendMessage("a", []byte("bc"))
+	clone := tr.clone()
+	clone.AppendMessage("d", nil)
+	if tr.ChallengeScalar("c").Cmp(base) != 0 {
+		t.Fatalf("TestTranscriptVectors - adding to a clone changed the original\n")
+	}
+	if clone.ChallengeScalar("c").Cmp(base) == 0 {
+		t.Fatalf("TestTranscriptVectors - clone ignores what was added to it\n")
+	}
+}
+
+func TestTranscriptDomains(t *testing.T) {
+	defer func(old bool) { LegacyChallenges = old }(LegacyChallenges)
+	x, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	A := TestCurve.Mult(TestCurve.G, x)
+	AH := TestCurve.Mult(TestCurve.H, x)
+
+	// a DisjunctiveProof and an EquivalenceProof hash the same points in
+	// the same order, only the domain tells them apart
+	for _, legacy := range []bool{true, false} {
+		LegacyChallenges = legacy
+		dj := disjunctiveChallenge(TestCurve, TestCurve.G, A, TestCurve.H, AH, A, AH, nil)
+		eq := equivalenceTranscript(TestCurve, TestCurve.G, A, TestCurve.H, AH, A, AH, nil).ChallengeScalar("c")
+		if (dj.Cmp(eq) == 0) != legacy {
+			t.Fatalf("TestTranscriptDomains - LegacyChallenges %v: challenges equal is %v\n", legacy, dj.Cmp(eq) == 0)
+		}
+		// and so does the e0 of a RangeProof with rings for these points
+		e0 := rangeChallenge(TestCurve, []ECPoint{TestCurve.G, A, TestCurve.H, AH, A, AH})
+		if (e0.Cmp(dj) == 0) != legacy || (e0.Cmp(eq) == 0) != legacy {
+			t.Fatalf("TestTranscriptDomains - LegacyChallenges %v: RangeProof challenge equal is %v\n", legacy, e0.Cmp(dj) == 0)
+		}
+		// the e_i of a bit hashes one point, like GSPFSProof without a base
+		ei := rangeBitChallenge(TestCurve, 0, A)
+		if gs := GenerateChallenge(TestCurve, A.Bytes()); (ei.Cmp(gs) == 0) != legacy {
+			t.Fatalf("TestTranscriptDomains - LegacyChallenges %v: RangeProof bit challenge equal is %v\n", legacy, ei.Cmp(gs) == 0)
+		}
+		if !legacy && (ei.Cmp(rangeBitChallenge(TestCurve, 1, A)) == 0 || ei.Cmp(rangeChallenge(TestCurve, []ECPoint{A})) == 0) {
+			t.Fatalf("TestTranscriptDomains - RangeProof challenges of different bits or of e0 agree\n")
+		}
+	}
+
+	LegacyChallenges = true
+	tr := NewTranscript(TestCurve, "test")
+	tr.AppendPoint("G", TestCurve.G)
+	tr.AppendScalar("x", big.NewInt(5))
+	tr.AppendMessage("m", []byte("zksigma"))
+	expect := GenerateChallenge(TestCurve, TestCurve.G.Bytes(), big.NewInt(5).Bytes(), []byte("zksigma"))
+	if tr.ChallengeScalar("c").Cmp(expect) != 0 {
+		t.Fatalf("TestTranscriptDomains - legacy transcript does not match GenerateChallenge\n")
+	}
+}
+
+// TestGenerateChallengeModN checks GenerateChallengeModN against vectors
+// computed independently and makes sure its challenges cover all of [0, N),
+// where GenerateChallenge only reaches [0, 2^256) for the larger order of P-384
+func TestGenerateChallengeModN(t *testing.T) {
+	p384 := ZKPCurveParams{C: elliptic.P384()}
+	for _, v := range []struct {
+		zkpcp  ZKPCurveParams
+		expect string
+	}{
+		{TestCurve, "402059d753687611211d1d494d881eb737dce5305fe3a942d6906fd02175c905"},
+		{p384, "85f89ea95e5842a7ea2e277bbaa3ba910c055c4378ed12361dae4f395e1ad88a71e698cf128c16e55a011b51e6e80a6b"},
+	} {
+		if got := fmt.Sprintf("%0*x", v.zkpcp.C.Params().BitSize/4, GenerateChallengeModN(v.zkpcp, []byte("zks"), []byte("igma"))); got != v.expect {
+			t.Errorf("TestGenerateChallengeModN - got challenge %s on %s, expected %s\n", got, v.zkpcp.C.Params().Name, v.expect)
+		}
+	}
+
+	// the top bit of the challenges is set close to half of the time on
+	// P-384, whose order is just below 2^384, and never by GenerateChallenge
+	const samples = 2000
+	N := p384.C.Params().N
+	top, narrowTop := 0, 0
+	for i := 0; i < samples; i++ {
+		msg := []byte(fmt.Sprintf("sample %d", i))
+		c := GenerateChallengeModN(p384, msg)
+		if c.Sign() < 0 || c.Cmp(N) >= 0 {
+			t.Fatalf("TestGenerateChallengeModN - challenge %x is not in [0, N)\n", c)
+		}
+		if c.BitLen() == N.BitLen() {
+			top++
+		}
+		if GenerateChallenge(p384, msg).BitLen() == N.BitLen() {
+			narrowTop++
+		}
+	}
+	// 4.5 standard deviations of a fair coin
+	if top < samples/2-100 || top > samples/2+100 {
+		t.Fatalf("TestGenerateChallengeModN - top bit set in %d of %d challenges\n", top, samples)
+	}
+	if narrowTop != 0 {
+		t.Fatalf("TestGenerateChallengeModN - GenerateChallenge reached the top bit\n")
+	}
+
+	// the low bits of secp256k1 challenges are balanced too
+	ones := 0
+	for i := 0; i < samples; i++ {
+		ones += int(GenerateChallengeModN(TestCurve, []byte(fmt.Sprintf("sample %d", i))).Bit(0))
+	}
+	if ones < samples/2-100 || ones > samples/2+100 {
+		t.Fatalf("TestGenerateChallengeModN - low bit set in %d of %d challenges\n", ones, samples)
+	}
+}
+
+// TestNarrowChallenges checks that proofs made with NarrowChallenges only
+// verify with NarrowChallenges and the other way around
+func TestNarrowChallenges(t *testing.T) {
+	narrow := TestCurve
+	narrow.NarrowChallenges = true
+	x, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+	A := TestCurve.Mult(TestCurve.G, x)
+
+	for _, c := range []struct{ prove, verify ZKPCurveParams }{{TestCurve, narrow}, {narrow, TestCurve}} {
+		proof, err := NewGSPFSProof(c.prove, A, x)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		if ok, err := proof.Verify(c.prove, A); !ok || err != nil {
+			t.Fatalf("GSPFSProof with NarrowChallenges %v did not verify: %v\n", c.prove.NarrowChallenges, err)
+		}
+		if ok, _ := proof.Verify(c.verify, A); ok {
+			t.Fatalf("GSPFSProof made with NarrowChallenges %v verified with %v\n", c.prove.NarrowChallenges, c.verify.NarrowChallenges)
+		}
+	}
+}
+
+// challengeHashes returns TestCurve with every ChallengeHash the standard
+// library has
+func challengeHashes(t *testing.T) map[crypto.Hash]ZKPCurveParams {
+	curves := make(map[crypto.Hash]ZKPCurveParams)
+	for _, h := range []crypto.Hash{crypto.SHA256, crypto.SHA512_256, crypto.SHA384, crypto.SHA512} {
+		zkpcp, err := TestCurve.WithChallengeHash(h)
+		if err != nil {
+			t.Fatalf("WithChallengeHash(%v) failed: %v\n", h, err)
+		}
+		if zkpcp.ChallengeHash() != h {
+			t.Fatalf("WithChallengeHash(%v) gives ChallengeHash %v\n", h, zkpcp.ChallengeHash())
+		}
+		curves[h] = zkpcp
+	}
+	return curves
+}
+
+// TestChallengeHash checks that proofs made under one ChallengeHash verify
+// under it and fail with ErrChallengeMismatch under every other one
+func TestChallengeHash(t *testing.T) {
+	curves := challengeHashes(t)
+	if TestCurve.ChallengeHash() != crypto.SHA256 || curves[crypto.SHA256].challengeHash != 0 {
+		t.Fatalf("the default ChallengeHash should be SHA-256\n")
+	}
+
+	N := TestCurve.C.Params().N
+	sk, _ := rand.Int(rand.Reader, N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(5)
+	r, _ := rand.Int(rand.Reader, N)
+	CM := PedCommitR(TestCurve, value, r)
+	CMTok := TestCurve.Mult(PK, r)
+	x, _ := rand.Int(rand.Reader, N)
+	A := TestCurve.Mult(TestCurve.G, x)
+
+	for h, prove := range curves {
+		gspfs, err := NewGSPFSProof(prove, A, x)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		abc, err := NewABCProof(prove, CM, CMTok, value, sk, Right)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		rp, rpR, err := NewRangeProof(prove, value)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		rpCM := PedCommitR(TestCurve, value, rpR)
+
+		for h2, verify := range curves {
+			session, err := NewVerifierSession(verify, CM, CMTok)
+			if err != nil {
+				t.Fatalf("%v\n", err)
+			}
+			results := map[string]func() (bool, error){
+				"GSPFSProof":      func() (bool, error) { return gspfs.Verify(verify, A) },
+				"ABCProof":        func() (bool, error) { return abc.Verify(verify, CM, CMTok, PK) },
+				"VerifierSession": func() (bool, error) { return session.VerifyABC(abc, PK) },
+				"RangeProof":      func() (bool, error) { return rp.Verify(verify, rpCM) },
+			}
+			for name, f := range results {
+				ok, err := f()
+				if h == h2 && (!ok || err != nil) {
+					t.Fatalf("%s made under %v failed to verify: %v\n", name, h, err)
+				}
+				if h != h2 && (ok || !errors.Is(err, ErrChallengeMismatch)) {
+					t.Fatalf("%s made under %v verified under %v: %v\n", name, h, h2, err)
+				}
+			}
+		}
+	}
+
+	// the legacy challenges use the hash too
+	defer func(old bool) { LegacyChallenges = old }(LegacyChallenges)
+	LegacyChallenges = true
+	proof, err := NewGSPFSProof(curves[crypto.SHA512], A, x)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(TestCurve, A); ok || !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("legacy GSPFSProof made under SHA-512 verified under SHA-256: %v\n", err)
+	}
+	if ok, err := proof.Verify(curves[crypto.SHA512], A); !ok || err != nil {
+		t.Fatalf("legacy GSPFSProof made under SHA-512 failed to verify: %v\n", err)
+	}
+}
+
+// TestChallengeHashVectors checks the challenges of every ChallengeHash
+// against vectors computed independently from the encoding described at
+// Transcript, with the name of the hash as the second message. The ones of
+// GenerateChallenge are the digest of its inputs mod N, which SHA-384 and
+// SHA-512 are longer than.
+func TestChallengeHashVectors(t *testing.T) {
+	defer func(old bool) { LegacyChallenges = old }(LegacyChallenges)
+	LegacyChallenges = false
+
+	curves := challengeHashes(t)
+	for _, v := range []struct {
+		h                           crypto.Hash
+		transcript, modN, challenge string
+	}{
+		{crypto.SHA256, "3c52661a06bcc6d31ce7fac3bbdc0e4372f08fba1af2857c66e0a08a1054d317", "402059d753687611211d1d494d881eb737dce5305fe3a942d6906fd02175c905",
+			"db66e6c8ff1a4353a1abfa80efb12cea5dfdfd3e2a56cb22b711dfbd9c75698c"},
+		{crypto.SHA512_256, "07db1bd60dfbf228644de4cb579953279670ded3e8946ff71b7f2c6779e7912a", "6966b2f85771a9724a0efb45ed3683b5336c8d8785f1e56978c5b2696cdf91",
+			"9531dcf4a6677ad4216fd2de948057ae1746dd3c87b77e2e64321f12301caccc"},
+		{crypto.SHA384, "e5e00b08402659607ee8517acd848605dd9a6fbd766df8c2d3ce4f3f9d057f36", "fbeff955186c250fe6a8b223790debdac33ef374e0475f70afb8c2a8aa68bad5",
+			"46154bf652e999acd5bce02c28bb8e1b2a2e60908606c7f8400dd2a701b7912d"},
+		{crypto.SHA512, "6827b5b1e42dbbe6c9ae14daefe793dbbb77929863c3a0420751c864a425d14b", "a8a2e80daca0c1d7fcc17fdbf70ad08e3db5f8a25a0549b24e21bce15dda2825",
+			"239ea804c1db3ad0e3035c517efacc6534e0a3748555ff657ab055e3398041ad"},
+	} {
+		tr := NewTranscript(curves[v.h], "test")
+		tr.AppendPoint("G", TestCurve.G)
+		tr.AppendMessage("m", []byte("zksigma"))
+		if got := scalarToHex(tr.ChallengeScalar("c")); got != v.transcript {
+			t.Errorf("TestChallengeHashVectors - %v: got challenge %s, expected %s\n", v.h, got, v.transcript)
+		}
+		if got := scalarToHex(GenerateChallengeModN(curves[v.h], []byte("zksigma"))); got != v.modN {
+			t.Errorf("TestChallengeHashVectors - %v: got GenerateChallengeModN %s, expected %s\n", v.h, got, v.modN)
+		}
+		if got := scalarToHex(GenerateChallenge(curves[v.h], []byte("zk"), []byte("sigma"))); got != v.challenge {
+			t.Errorf("TestChallengeHashVectors - %v: got GenerateChallenge %s, expected %s\n", v.h, got, v.challenge)
+		}
+	}
+}
+
+// TestWithChallengeHash checks that WithChallengeHash only takes hashes that
+// are linked in, wide enough and can be cloned, and leaves zkpcp as it is
+func TestWithChallengeHash(t *testing.T) {
+	for _, h := range []crypto.Hash{crypto.SHA224, crypto.SHA1, crypto.MD5, crypto.Hash(0), crypto.Hash(999)} {
+		if _, err := TestCurve.WithChallengeHash(h); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("WithChallengeHash(%v) should fail with ErrInvalidInput, got %v\n", h, err)
+		}
+	}
+	// BLAKE2b only works with golang.org/x/crypto/blake2b linked in
+	if _, err := TestCurve.WithChallengeHash(crypto.BLAKE2b_256); (err == nil) != crypto.BLAKE2b_256.Available() {
+		t.Fatalf("WithChallengeHash(BLAKE2b-256) with the hash available %v gives %v\n", crypto.BLAKE2b_256.Available(), err)
+	}
+
+	zkpcp, err := TestCurve.WithChallengeHash(crypto.SHA512)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if TestCurve.ChallengeHash() != crypto.SHA256 {
+		t.Fatalf("WithChallengeHash changed the curve it was called on\n")
+	}
+	if back, _ := zkpcp.WithChallengeHash(crypto.SHA256); back.challengeHash != 0 {
+		t.Fatalf("WithChallengeHash(SHA-256) does not give back the default curve\n")
+	}
+}
+
+// TestLegacyChallenges checks that proofs made with LegacyChallenges, like
+// proofs from before Transcript, only verify with LegacyChallenges and the
+// other way around
+func TestLegacyChallenges(t *testing.T) {
+	defer func(old bool) { LegacyChallenges = old }(LegacyChallenges)
+
+	N := TestCurve.C.Params().N
+	sk, _ := rand.Int(rand.Reader, N)
+	PK := TestCurve.Mult(TestCurve.H, sk)
+	value := big.NewInt(5)
+	r, _ := rand.Int(rand.Reader, N)
+	CM := PedCommitR(TestCurve, value, r)
+	CMTok := TestCurve.Mult(PK, r)
+	CM2, r2, _ := PedCommit(TestCurve, big.NewInt(6))
+	x, _ := rand.Int(rand.Reader, N)
+	A := TestCurve.Mult(TestCurve.G, x)
+	AH := TestCurve.Mult(TestCurve.H, x)
+
+	// every case proves something and returns a function verifying it
+	cases := map[string]func() (func() (bool, error), error){
+		"ABCProof": func() (func() (bool, error), error) {
+			p, err := NewABCProof(TestCurve, CM, CMTok, value, sk, Right)
+			return func() (bool, error) { return p.Verify(TestCurve, CM, CMTok, PK) }, err
+		},
+		"ConsistencyProof": func() (func() (bool, error), error) {
+			p, err := NewConsistencyProof(TestCurve, CM, CMTok, PK, value, r)
+			return func() (bool, error) { return p.Verify(TestCurve, CM, CMTok, PK) }, err
+		},
+		"DisjunctiveProof": func() (func() (bool, error), error) {
+			p, err := NewDisjunctiveProof(TestCurve, TestCurve.G, A, TestCurve.H, AH, x, Left)
+			return func() (bool, error) { return p.Verify(TestCurve, TestCurve.G, A, TestCurve.H, AH) }, err
+		},
+		"EquivalenceProof": func() (func() (bool, error), error) {
+			p, err := NewEquivalenceProof(TestCurve, TestCurve.G, A, TestCurve.H, AH, x)
+			return func() (bool, error) { return p.Verify(TestCurve, TestCurve.G, A, TestCurve.H, AH) }, err
+		},
+		"GSPFSProof": func() (func() (bool, error), error) {
+			p, err := NewGSPFSProof(TestCurve, A, x)
+			return func() (bool, error) { return p.Verify(TestCurve, A) }, err
+		},
+		"InequalityProof": func() (func() (bool, error), error) {
+			p, err := NewInequalityProof(TestCurve, CM, CM2, value, big.NewInt(6), r, r2)
+			return func() (bool, error) { return p.Verify(TestCurve, CM, CM2) }, err
+		},
+		"OpenProof": func() (func() (bool, error), error) {
+			p, err := NewOpenProof(TestCurve, CM, value, r)
+			return func() (bool, error) { return p.Verify(TestCurve, CM) }, err
+		},
+		"ProductProof": func() (func() (bool, error), error) {
+			p, pA, pB, pC, err := NewProductProof(TestCurve, value, big.NewInt(6), r, r2, x)
+			return func() (bool, error) { return p.Verify(TestCurve, pA, pB, pC) }, err
+		},
+		"MultiProductProof": func() (func() (bool, error), error) {
+			CMs, p, err := NewMultiProductProof(TestCurve, []*big.Int{value, big.NewInt(6), big.NewInt(7)}, []*big.Int{r, r2, x, r2, r})
+			return func() (bool, error) { return p.Verify(TestCurve, CMs[:3], CMs[4]) }, err
+		},
+		"MultiRangeProof": func() (func() (bool, error), error) {
+			p, err := NewMultiRangeProof(TestCurve, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(6)}, []*big.Int{r, r2}, 4)
+			return func() (bool, error) { return p.Verify(TestCurve, []ECPoint{CM, CM2}, 4) }, err
+		},
+		"SumProof": func() (func() (bool, error), error) {
+			p, err := NewSumProof(TestCurve, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(6)}, []*big.Int{r, r2}, big.NewInt(11))
+			return func() (bool, error) { return p.Verify(TestCurve, []ECPoint{CM, CM2}, big.NewInt(11)) }, err
+		},
+		"OneOfManyProof": func() (func() (bool, error), error) {
+			p, err := NewOneOfManyProof(TestCurve, []ECPoint{TestCurve.G, TestCurve.H}, []ECPoint{CM, AH}, 1, x)
+			return func() (bool, error) {
+				return p.Verify(TestCurve, []ECPoint{TestCurve.G, TestCurve.H}, []ECPoint{CM, AH})
+			}, err
+		},
+		"NonZeroProof": func() (func() (bool, error), error) {
+			p, err := NewNonZeroProof(TestCurve, CM, value, r)
+			return func() (bool, error) { return p.Verify(TestCurve, CM) }, err
+		},
+		"VectorOpenProof": func() (func() (bool, error), error) {
+			vecCM, _ := VectorPedCommitR(TestCurve, []*big.Int{value, x}, r)
+			p, err := NewVectorOpenProof(TestCurve, vecCM, []*big.Int{value, x}, r)
+			return func() (bool, error) { return p.Verify(TestCurve, vecCM) }, err
+		},
+		"OpenToValueProof": func() (func() (bool, error), error) {
+			p, err := NewOpenToValueProof(TestCurve, CM, value, r)
+			return func() (bool, error) { return p.Verify(TestCurve, CM, value) }, err
+		},
+		"KeyRotationProof": func() (func() (bool, error), error) {
+			newPK := TestCurve.Mult(TestCurve.H, x)
+			newCMTok, _ := RotateToken(TestCurve, CMTok, sk, x)
+			p, err := NewKeyRotationProof(TestCurve, CMTok, newCMTok, PK, newPK, sk, x)
+			return func() (bool, error) { return p.Verify(TestCurve, CMTok, newCMTok, PK, newPK) }, err
+		},
+		"SameBlindingProof": func() (func() (bool, error), error) {
+			PK2 := TestCurve.Mult(TestCurve.H, x)
+			CMTok2 := TestCurve.Mult(PK2, r)
+			p, err := NewSameBlindingProof(TestCurve, CMTok, CMTok2, PK, PK2, r)
+			return func() (bool, error) { return p.Verify(TestCurve, CMTok, CMTok2, PK, PK2) }, err
+		},
+		"SameValueProof": func() (func() (bool, error), error) {
+			sameCM, sameR, _ := PedCommit(TestCurve, value)
+			p, err := NewSameValueProof(TestCurve, CM, sameCM, value, r, sameR)
+			return func() (bool, error) { return p.Verify(TestCurve, CM, sameCM) }, err
+		},
+		"EquivOrZeroProof": func() (func() (bool, error), error) {
+			sameCM, sameR, _ := PedCommit(TestCurve, value)
+			p, err := NewEquivOrZeroProof(TestCurve, CM, sameCM, r, sameR, Right)
+			return func() (bool, error) { return p.Verify(TestCurve, CM, sameCM) }, err
+		},
+		"BalanceProof": func() (func() (bool, error), error) {
+			sameCM, sameR, _ := PedCommit(TestCurve, value)
+			p, err := NewBalanceProof(TestCurve, CM, []ECPoint{sameCM}, []int{1}, r, []*big.Int{sameR})
+			return func() (bool, error) { return p.Verify(TestCurve, CM, []ECPoint{sameCM}, []int{1}) }, err
+		},
+		"SetMembershipProof": func() (func() (bool, error), error) {
+			set := []*big.Int{big.NewInt(1), value, big.NewInt(10)}
+			p, err := NewSetMembershipProof(TestCurve, CM, value, r, set)
+			return func() (bool, error) { return p.Verify(TestCurve, CM, set) }, err
+		},
+		"DecryptionProof": func() (func() (bool, error), error) {
+			p, V, err := NewDecryptionProof(TestCurve, CM, CMTok, sk)
+			return func() (bool, error) { return p.Verify(TestCurve, CM, CMTok, PK, V) }, err
+		},
+		"BitProof": func() (func() (bool, error), error) {
+			p, C, err := NewBitProof(TestCurve, big.NewInt(1), r)
+			return func() (bool, error) { return p.Verify(TestCurve, C) }, err
+		},
+		"RangeProof": func() (func() (bool, error), error) {
+			p, rpR, err := NewRangeProof(TestCurve, value)
+			return func() (bool, error) { return p.Verify(TestCurve, PedCommitR(TestCurve, value, rpR)) }, err
+		},
+		"BitRangeProof": func() (func() (bool, error), error) {
+			p, err := NewBitRangeProof(TestCurve, CM, value, r, 4)
+			return func() (bool, error) { return p.Verify(TestCurve, CM, 4) }, err
+		},
+		"ComposedProof": func() (func() (bool, error), error) {
+			abcStmt := ABCStatement{CM: CM, CMTok: CMTok, PubKey: PK}
+			gsStmt := GSPFSStatement{Base: TestCurve.G, A: A}
+			statements := []Statement{abcStmt, gsStmt}
+			p, err := NewComposedProof(TestCurve,
+				abcStmt.WithWitness(ABCWitness{Value: value, SK: sk}, Right), gsStmt.WithWitness(GSPFSWitness{x}))
+			return func() (bool, error) { return p.Verify(TestCurve, statements...) }, err
+		},
+		"VerifierSession": func() (func() (bool, error), error) {
+			p, err := NewABCProof(TestCurve, CM, CMTok, value, sk, Right)
+			session, _ := NewVerifierSession(TestCurve, CM, CMTok)
+			return func() (bool, error) { return session.VerifyABC(p, PK) }, err
+		},
+	}
+
+	for name, prove := range cases {
+		for _, legacy := range []bool{false, true} {
+			LegacyChallenges = legacy
+			verify, err := prove()
+			if err != nil {
+				t.Fatalf("%s with LegacyChallenges %v failed: %v\n", name, legacy, err)
+			}
+			if ok, err := verify(); !ok || err != nil {
+				t.Fatalf("%s with LegacyChallenges %v did not verify: %v\n", name, legacy, err)
+			}
+			LegacyChallenges = !legacy
+			if ok, _ := verify(); ok {
+				t.Fatalf("%s made with LegacyChallenges %v verified with %v\n", name, legacy, !legacy)
+			}
+		}
+	}
+}
+
+func TestTranscriptAllocs(t *testing.T) {
+	tr := NewTranscript(TestCurve, "allocs")
+	P := TestCurve.Mult(TestCurve.G, big.NewInt(7))
+	s := big.NewInt(12345)
+	if allocs := testing.AllocsPerRun(20, func() {
+		tr.AppendPoint("P", P)
+		tr.AppendScalar("s", s)
+	}); allocs > 0 {
+		t.Fatalf("TestTranscriptAllocs - appending allocates %v times\n", allocs)
+	}
+	// the challenge and the scratch space of reducing it mod N
+	if allocs := testing.AllocsPerRun(20, func() { tr.ChallengeScalar("c") }); allocs > 4 {
+		t.Fatalf("TestTranscriptAllocs - ChallengeScalar allocates %v times\n", allocs)
+	}
+}
+
+func BenchmarkTranscriptChallenge(b *testing.B) {
+	points := []ECPoint{TestCurve.G, TestCurve.H, TestCurve.G, TestCurve.H, TestCurve.G, TestCurve.H}
+	b.ReportAllocs()
+	for ii := 0; ii < b.N; ii++ {
+		t := newTranscript(TestCurve, "bench", false)
+		for _, p := range points {
+			t.AppendPoint("P", p)
+		}
+		t.ChallengeScalar("c")
+	}
+}
+
+func BenchmarkGenerateChallenge(b *testing.B) {
+	points := []ECPoint{TestCurve.G, TestCurve.H, TestCurve.G, TestCurve.H, TestCurve.G, TestCurve.H}
+	for ii := 0; ii < b.N; ii++ {
+		arr := make([][]byte, len(points))
+		for i, p := range points {
+			arr[i] = p.Bytes()
+		}
+		GenerateChallenge(TestCurve, arr...)
+	}
+}
+
+func BenchmarkGenerateChallengeModN(b *testing.B) {
+	points := []ECPoint{TestCurve.G, TestCurve.H, TestCurve.G, TestCurve.H, TestCurve.G, TestCurve.H}
+	for ii := 0; ii < b.N; ii++ {
+		arr := make([][]byte, len(points))
+		for i, p := range points {
+			arr[i] = p.Bytes()
+		}
+		GenerateChallengeModN(TestCurve, arr...)
+	}
+}
@@ -0,0 +1,318 @@
+package zksigma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/mit-dci/zksigma/wire"
+)
+
+// TransferAmountBits is the size of the amounts of an AuditableTransfer, whose
+// BitRangeProof shows that the amount is in [0, 2^TransferAmountBits)
+const TransferAmountBits = 64
+
+// AuditableTransfer bundles the commitments and proofs of moving a hidden
+// amount from a sender to a receiver in a way an auditor can check, like a row
+// of two columns in zkLedger:
+//
+//  SenderCM    = -amount * G + rH     SenderTok   = r * senderPK
+//  ReceiverCM  =  amount * G - rH     ReceiverTok = -r * receiverPK
+//  AuditTok    = -r * auditorPK
+//
+// The two commitments add up to Zero, so no value is created. The sender
+// proves with an ABCProof for its own key that it knows the secret key of
+// senderPK and the amount of its commitment. For the receiver a
+// ConsistencyProof shows that ReceiverTok uses the randomness of ReceiverCM,
+// so the receiver can open it, an EquivalenceProof that AuditTok uses the
+// same randomness, so the auditor can open it too, and a BitRangeProof that
+// the amount is not negative.
+//
+// Verify them all at once, in the right order and with the right arguments,
+// with VerifyTransfer.
+type AuditableTransfer struct {
+	SenderCM    ECPoint // -amount * G + rH
+	SenderTok   ECPoint // r * senderPK
+	ReceiverCM  ECPoint // amount * G - rH
+	ReceiverTok ECPoint // -r * receiverPK
+	AuditTok    ECPoint // -r * auditorPK
+
+	SenderABC           *ABCProof         // for SenderCM, SenderTok and senderPK
+	ReceiverConsistency *ConsistencyProof // ReceiverTok uses the randomness of ReceiverCM
+	ReceiverAudit       *EquivalenceProof // ReceiverTok and AuditTok use the same randomness
+	ReceiverRange       *BitRangeProof    // ReceiverCM commits to an amount in [0, 2^TransferAmountBits)
+}
+
+// NewAuditableTransfer generates the commitments and proofs of a transfer of
+// amount from the owner of senderSK to receiverPK, which the owner of
+// auditorPK can audit. amount has to be in [0, 2^TransferAmountBits).
+func NewAuditableTransfer(zkpcp ZKPCurveParams, senderSK *big.Int, receiverPK, auditorPK ECPoint, amount *big.Int) (*AuditableTransfer, error) {
+	return NewAuditableTransferWithRand(zkpcp, entropy(), senderSK, receiverPK, auditorPK, amount)
+}
+
+// NewAuditableTransferWithRand is the same as NewAuditableTransfer, but reads
+// its random values from rnd instead of the EntropySource
+func NewAuditableTransferWithRand(zkpcp ZKPCurveParams, rnd io.Reader,
+	senderSK *big.Int, receiverPK, auditorPK ECPoint, amount *big.Int) (*AuditableTransfer, error) {
+
+	if err := zkpcp.checkScalars("NewAuditableTransfer", "senderSK, amount", senderSK, amount); err != nil {
+		return nil, err
+	}
+	if senderSK.Sign() == 0 {
+		return nil, rangeError("NewAuditableTransfer", "senderSK is zero")
+	}
+	if amount.BitLen() > TransferAmountBits {
+		return nil, rangeError("NewAuditableTransfer", fmt.Sprintf("amount does not fit in %d bits", TransferAmountBits))
+	}
+	if !zkpcp.validPoints(receiverPK, auditorPK) {
+		return nil, curveError("NewAuditableTransfer")
+	}
+
+	N := zkpcp.C.Params().N
+	r, err := zkpcp.randScalar(rnd)
+	if err != nil {
+		return nil, err
+	}
+	// the sender commits to -amount with r, the receiver to amount with -r
+	senderValue := new(big.Int).Mod(new(big.Int).Neg(amount), N)
+	negR := new(big.Int).Mod(new(big.Int).Neg(r), N)
+	senderPK := zkpcp.Mult(zkpcp.H, senderSK)
+
+	tx := &AuditableTransfer{
+		SenderCM:    PedCommitR(zkpcp, senderValue, r),
+		SenderTok:   zkpcp.Mult(senderPK, r),
+		ReceiverCM:  PedCommitR(zkpcp, amount, negR),
+		ReceiverTok: zkpcp.Mult(receiverPK, negR),
+		AuditTok:    zkpcp.Mult(auditorPK, negR),
+	}
+
+	// the sender value is 0 only for a transfer of nothing, which has no
+	// inverse for the Right side
+	side := Right
+	if senderValue.Sign() == 0 {
+		side = Left
+	}
+	if tx.SenderABC, err = NewABCProofWithRand(zkpcp, rnd, tx.SenderCM, tx.SenderTok, senderValue, senderSK, side); err != nil {
+		return nil, subProofError("NewAuditableTransfer", fmt.Sprintf("sender ABCProof: %v", err), err)
+	}
+	if tx.ReceiverConsistency, err = NewConsistencyProofWithRand(zkpcp, rnd,
+		tx.ReceiverCM, tx.ReceiverTok, receiverPK, amount, negR); err != nil {
+		return nil, subProofError("NewAuditableTransfer", fmt.Sprintf("receiver ConsistencyProof: %v", err), err)
+	}
+	if tx.ReceiverAudit, err = NewEquivalenceProofWithRand(zkpcp, rnd,
+		receiverPK, tx.ReceiverTok, auditorPK, tx.AuditTok, negR); err != nil {
+		return nil, subProofError("NewAuditableTransfer", fmt.Sprintf("auditor EquivalenceProof: %v", err), err)
+	}
+	if tx.ReceiverRange, err = NewBitRangeProofWithRand(zkpcp, rnd, tx.ReceiverCM, amount, negR, TransferAmountBits); err != nil {
+		return nil, subProofError("NewAuditableTransfer", fmt.Sprintf("receiver BitRangeProof: %v", err), err)
+	}
+
+	return tx, nil
+}
+
+// VerifyTransfer checks every commitment and proof of AuditableTransfer tx for
+// the given keys, in this order:
+//
+//  balance    SenderCM + ReceiverCM = Zero
+//  sender     SenderABC for SenderCM, SenderTok and senderPK
+//  receiver   ReceiverConsistency for ReceiverCM, ReceiverTok and receiverPK
+//  auditor    ReceiverAudit for ReceiverTok and AuditTok with receiverPK and auditorPK
+//  range      ReceiverRange for ReceiverCM
+//
+// It returns true with a nil error, or false with an error for the first stage
+// that failed. The error message starts with the name of the stage, and the
+// error wraps the one of the failed proof, so it keeps its Code.
+func VerifyTransfer(zkpcp ZKPCurveParams, tx *AuditableTransfer, senderPK, receiverPK, auditorPK ECPoint) (bool, error) {
+	if tx == nil {
+		return false, malformedError("VerifyTransfer", fmt.Sprintf("passed transfer is nil"))
+	}
+	if tx.SenderABC == nil || tx.ReceiverConsistency == nil || tx.ReceiverAudit == nil || tx.ReceiverRange == nil {
+		return false, malformedError("VerifyTransfer", "transfer is missing a proof")
+	}
+	if !zkpcp.validPoints(senderPK, receiverPK, auditorPK) ||
+		!zkpcp.onCurve(tx.SenderCM, tx.SenderTok, tx.ReceiverCM, tx.ReceiverTok, tx.AuditTok) {
+		return false, curveError("VerifyTransfer")
+	}
+
+	if sum := zkpcp.Add(tx.SenderCM, tx.ReceiverCM); !pointsEqual(sum, Zero) {
+		return false, equationError("VerifyTransfer", 1, "balance: SenderCM + ReceiverCM != Zero", sum, Zero)
+	}
+
+	stages := []struct {
+		name   string
+		verify func() (bool, error)
+	}{
+		{"sender", func() (bool, error) {
+			return tx.SenderABC.Verify(zkpcp, tx.SenderCM, tx.SenderTok, senderPK)
+		}},
+		{"receiver", func() (bool, error) {
+			return tx.ReceiverConsistency.Verify(zkpcp, tx.ReceiverCM, tx.ReceiverTok, receiverPK)
+		}},
+		{"auditor", func() (bool, error) {
+			return tx.ReceiverAudit.Verify(zkpcp, receiverPK, tx.ReceiverTok, auditorPK, tx.AuditTok)
+		}},
+		{"range", func() (bool, error) {
+			return tx.ReceiverRange.Verify(zkpcp, tx.ReceiverCM, TransferAmountBits)
+		}},
+	}
+	for _, s := range stages {
+		if ok, err := s.verify(); !ok {
+			return false, subProofError("VerifyTransfer", fmt.Sprintf("%s: %v", s.name, err), err)
+		}
+	}
+
+	return true, nil
+}
+
+// Bytes returns a byte slice with a serialized representation of
+// AuditableTransfer tx: its points followed by the encoding of each proof,
+// prefixed by its length
+func (tx *AuditableTransfer) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	for _, p := range []ECPoint{tx.SenderCM, tx.SenderTok, tx.ReceiverCM, tx.ReceiverTok, tx.AuditTok} {
+		writeFixedPoint(&buf, p)
+	}
+	wire.WriteVarBytes(&buf, tx.SenderABC.Bytes())
+	wire.WriteVarBytes(&buf, tx.ReceiverConsistency.Bytes())
+	wire.WriteVarBytes(&buf, tx.ReceiverAudit.Bytes())
+	wire.WriteVarBytes(&buf, tx.ReceiverRange.Bytes())
+
+	return buf.Bytes()
+}
+
+// NewAuditableTransferFromBytes returns an AuditableTransfer generated from
+// the deserialization of byte slice b
+func NewAuditableTransferFromBytes(b []byte) (*AuditableTransfer, error) {
+	if len(b) == 0 {
+		return nil, malformedError("AuditableTransferFromBytes", "truncated buffer")
+	}
+	if b[0] != proofEncodingVersion {
+		return nil, malformedError("AuditableTransferFromBytes", fmt.Sprintf("unsupported encoding version %d", b[0]))
+	}
+	buf := bytes.NewBuffer(b[1:])
+
+	tx := new(AuditableTransfer)
+	var err error
+	for _, p := range []*ECPoint{&tx.SenderCM, &tx.SenderTok, &tx.ReceiverCM, &tx.ReceiverTok, &tx.AuditTok} {
+		if *p, err = readFixedPoint(buf, "AuditableTransferFromBytes"); err != nil {
+			return nil, err
+		}
+	}
+
+	// next reads the encoding of the next proof
+	next := func(name string) ([]byte, error) {
+		pb, err := wire.ReadVarBytes(buf, uint32(buf.Len()), name)
+		if err != nil {
+			return nil, malformedError("AuditableTransferFromBytes", fmt.Sprintf("truncated buffer: %v", err))
+		}
+		return pb, nil
+	}
+	pb, err := next("SenderABC")
+	if err != nil {
+		return nil, err
+	}
+	if tx.SenderABC, err = NewABCProofFromBytes(pb); err != nil {
+		return nil, wrapError("AuditableTransferFromBytes", fmt.Sprintf("SenderABC: %v", err), err)
+	}
+	if pb, err = next("ReceiverConsistency"); err != nil {
+		return nil, err
+	}
+	if tx.ReceiverConsistency, err = NewConsistencyProofFromBytes(pb); err != nil {
+		return nil, wrapError("AuditableTransferFromBytes", fmt.Sprintf("ReceiverConsistency: %v", err), err)
+	}
+	if pb, err = next("ReceiverAudit"); err != nil {
+		return nil, err
+	}
+	if tx.ReceiverAudit, err = NewEquivalenceProofFromBytes(pb); err != nil {
+		return nil, wrapError("AuditableTransferFromBytes", fmt.Sprintf("ReceiverAudit: %v", err), err)
+	}
+	if pb, err = next("ReceiverRange"); err != nil {
+		return nil, err
+	}
+	if tx.ReceiverRange, err = NewBitRangeProofFromBytes(pb); err != nil {
+		return nil, wrapError("AuditableTransferFromBytes", fmt.Sprintf("ReceiverRange: %v", err), err)
+	}
+	if buf.Len() != 0 {
+		return nil, malformedError("AuditableTransferFromBytes", fmt.Sprintf("%d trailing bytes", buf.Len()))
+	}
+
+	return tx, nil
+}
+
+type auditableTransferJSON struct {
+	SenderCM            string            `json:"senderCM"`
+	SenderTok           string            `json:"senderTok"`
+	ReceiverCM          string            `json:"receiverCM"`
+	ReceiverTok         string            `json:"receiverTok"`
+	AuditTok            string            `json:"auditTok"`
+	SenderABC           *ABCProof         `json:"senderABC"`
+	ReceiverConsistency *ConsistencyProof `json:"receiverConsistency"`
+	ReceiverAudit       *EquivalenceProof `json:"receiverAudit"`
+	ReceiverRange       *BitRangeProof    `json:"receiverRange"`
+}
+
+// MarshalJSON encodes AuditableTransfer tx with points as compressed hex and
+// its proofs with their own MarshalJSON
+func (tx *AuditableTransfer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(auditableTransferJSON{
+		SenderCM:            pointToHex(tx.SenderCM),
+		SenderTok:           pointToHex(tx.SenderTok),
+		ReceiverCM:          pointToHex(tx.ReceiverCM),
+		ReceiverTok:         pointToHex(tx.ReceiverTok),
+		AuditTok:            pointToHex(tx.AuditTok),
+		SenderABC:           tx.SenderABC,
+		ReceiverConsistency: tx.ReceiverConsistency,
+		ReceiverAudit:       tx.ReceiverAudit,
+		ReceiverRange:       tx.ReceiverRange,
+	})
+}
+
+// UnmarshalJSON decodes an AuditableTransfer encoded with MarshalJSON. It
+// fails if a point is not on the curve, a scalar is not in [0, N) or a nested
+// proof is missing
+func (tx *AuditableTransfer) UnmarshalJSON(b []byte) error {
+	var aux auditableTransferJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	if aux.SenderABC == nil {
+		return malformedError("UnmarshalJSON", "senderABC is missing")
+	}
+	if aux.ReceiverConsistency == nil {
+		return malformedError("UnmarshalJSON", "receiverConsistency is missing")
+	}
+	if aux.ReceiverAudit == nil {
+		return malformedError("UnmarshalJSON", "receiverAudit is missing")
+	}
+	if aux.ReceiverRange == nil {
+		return malformedError("UnmarshalJSON", "receiverRange is missing")
+	}
+	var err error
+	p := AuditableTransfer{
+		SenderABC:           aux.SenderABC,
+		ReceiverConsistency: aux.ReceiverConsistency,
+		ReceiverAudit:       aux.ReceiverAudit,
+		ReceiverRange:       aux.ReceiverRange,
+	}
+	if p.SenderCM, err = pointFromHex(aux.SenderCM, "senderCM"); err != nil {
+		return err
+	}
+	if p.SenderTok, err = pointFromHex(aux.SenderTok, "senderTok"); err != nil {
+		return err
+	}
+	if p.ReceiverCM, err = pointFromHex(aux.ReceiverCM, "receiverCM"); err != nil {
+		return err
+	}
+	if p.ReceiverTok, err = pointFromHex(aux.ReceiverTok, "receiverTok"); err != nil {
+		return err
+	}
+	if p.AuditTok, err = pointFromHex(aux.AuditTok, "auditTok"); err != nil {
+		return err
+	}
+	*tx = p
+	return nil
+}
@@ -0,0 +1,221 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// transferKeys are the secret keys of the sender, receiver and auditor of a
+// transfer, in that order, and their public keys
+type transferKeys struct {
+	sk                              [3]*big.Int
+	senderPK, receiverPK, auditorPK ECPoint
+}
+
+func newTransferKeys(t testing.TB) transferKeys {
+	var k transferKeys
+	for i := range k.sk {
+		sk, err := rand.Int(rand.Reader, TestCurve.C.Params().N)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		k.sk[i] = sk
+	}
+	k.senderPK = TestCurve.Mult(TestCurve.H, k.sk[0])
+	k.receiverPK = TestCurve.Mult(TestCurve.H, k.sk[1])
+	k.auditorPK = TestCurve.Mult(TestCurve.H, k.sk[2])
+	return k
+}
+
+// opened returns C - inv(sk) * tok, which is vG for a commitment C = vG + rH
+// and a token tok = r * skH
+func opened(C, tok ECPoint, sk *big.Int) ECPoint {
+	return TestCurve.Sub(C, TestCurve.Mult(tok, new(big.Int).ModInverse(sk, TestCurve.C.Params().N)))
+}
+
+func TestAuditableTransfer(t *testing.T) {
+	k := newTransferKeys(t)
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), TransferAmountBits), big.NewInt(1))
+	for _, amount := range []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(1000), max} {
+		tx, err := NewAuditableTransfer(TestCurve, k.sk[0], k.receiverPK, k.auditorPK, amount)
+		if err != nil {
+			t.Fatalf("TestAuditableTransfer - %v: %v\n", amount, err)
+		}
+		if ok, err := VerifyTransfer(TestCurve, tx, k.senderPK, k.receiverPK, k.auditorPK); !ok || err != nil {
+			t.Fatalf("TestAuditableTransfer - %v did not verify: %v\n", amount, err)
+		}
+
+		// the receiver and the auditor can both see the amount
+		amountG := TestCurve.Mult(TestCurve.G, amount)
+		if !opened(tx.ReceiverCM, tx.ReceiverTok, k.sk[1]).Equal(amountG) {
+			t.Fatalf("TestAuditableTransfer - receiver can not open %v\n", amount)
+		}
+		if !opened(tx.ReceiverCM, tx.AuditTok, k.sk[2]).Equal(amountG) {
+			t.Fatalf("TestAuditableTransfer - auditor can not open %v\n", amount)
+		}
+		if !opened(tx.SenderCM, tx.SenderTok, k.sk[0]).Equal(TestCurve.Neg(amountG)) {
+			t.Fatalf("TestAuditableTransfer - sender commitment does not open to -%v\n", amount)
+		}
+
+		decoded, err := NewAuditableTransferFromBytes(tx.Bytes())
+		if err != nil {
+			t.Fatalf("TestAuditableTransfer - %v failed to decode: %v\n", amount, err)
+		}
+		if ok, err := VerifyTransfer(TestCurve, decoded, k.senderPK, k.receiverPK, k.auditorPK); !ok || err != nil {
+			t.Fatalf("TestAuditableTransfer - decoded %v did not verify: %v\n", amount, err)
+		}
+
+		js, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatalf("TestAuditableTransfer - %v failed to marshal: %v\n", amount, err)
+		}
+		decoded = new(AuditableTransfer)
+		if err := json.Unmarshal(js, decoded); err != nil {
+			t.Fatalf("TestAuditableTransfer - %v failed to unmarshal: %v\n", amount, err)
+		}
+		if ok, err := VerifyTransfer(TestCurve, decoded, k.senderPK, k.receiverPK, k.auditorPK); !ok || err != nil {
+			t.Fatalf("TestAuditableTransfer - %v did not verify after JSON round trip: %v\n", amount, err)
+		}
+	}
+
+	// sending nothing needs the Left side of the ABCProof, and sending
+	// something the Right one
+	zero, _ := NewAuditableTransfer(TestCurve, k.sk[0], k.receiverPK, k.auditorPK, big.NewInt(0))
+	ucH := TestCurve.Mult(zero.SenderABC.CToken, new(big.Int).ModInverse(k.sk[0], TestCurve.C.Params().N))
+	if !TestCurve.Sub(zero.SenderABC.C, ucH).IsZero() {
+		t.Fatalf("TestAuditableTransfer - ABCProof of an amount of 0 is not on the Left side\n")
+	}
+}
+
+// transferStage returns the stage of VerifyTransfer that err is for
+func transferStage(err error) string {
+	msg := strings.TrimPrefix(err.Error(), "VerifyTransfer - ")
+	return msg[:strings.Index(msg, ":")]
+}
+
+func TestAuditableTransferTampered(t *testing.T) {
+	k := newTransferKeys(t)
+	tx, err := NewAuditableTransfer(TestCurve, k.sk[0], k.receiverPK, k.auditorPK, big.NewInt(25))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	other, err := NewAuditableTransfer(TestCurve, k.sk[0], k.receiverPK, k.auditorPK, big.NewInt(26))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	wrongKey := TestCurve.Mult(TestCurve.H, big.NewInt(12345))
+
+	cases := []struct {
+		name                            string
+		tamper                          func(tx *AuditableTransfer)
+		senderPK, receiverPK, auditorPK ECPoint
+		stage                           string
+	}{
+		{"receiver commitment", func(tx *AuditableTransfer) { tx.ReceiverCM = TestCurve.Add(tx.ReceiverCM, TestCurve.G) },
+			k.senderPK, k.receiverPK, k.auditorPK, "balance"},
+		{"both commitments", func(tx *AuditableTransfer) {
+			tx.ReceiverCM = TestCurve.Add(tx.ReceiverCM, TestCurve.G)
+			tx.SenderCM = TestCurve.Sub(tx.SenderCM, TestCurve.G)
+		}, k.senderPK, k.receiverPK, k.auditorPK, "sender"},
+		{"receiver token", func(tx *AuditableTransfer) { tx.ReceiverTok = TestCurve.Add(tx.ReceiverTok, TestCurve.H) },
+			k.senderPK, k.receiverPK, k.auditorPK, "receiver"},
+		{"auditor token", func(tx *AuditableTransfer) { tx.AuditTok = TestCurve.Add(tx.AuditTok, TestCurve.H) },
+			k.senderPK, k.receiverPK, k.auditorPK, "auditor"},
+		{"range proof of another transfer", func(tx *AuditableTransfer) { tx.ReceiverRange = other.ReceiverRange },
+			k.senderPK, k.receiverPK, k.auditorPK, "range"},
+		{"sender key", func(tx *AuditableTransfer) {}, wrongKey, k.receiverPK, k.auditorPK, "sender"},
+		{"receiver key", func(tx *AuditableTransfer) {}, k.senderPK, wrongKey, k.auditorPK, "receiver"},
+		{"auditor key", func(tx *AuditableTransfer) {}, k.senderPK, k.receiverPK, wrongKey, "auditor"},
+	}
+	for _, c := range cases {
+		q := *tx
+		c.tamper(&q)
+		ok, err := VerifyTransfer(TestCurve, &q, c.senderPK, c.receiverPK, c.auditorPK)
+		if ok || err == nil {
+			t.Fatalf("TestAuditableTransferTampered - verified with %s changed\n", c.name)
+		}
+		if stage := transferStage(err); stage != c.stage {
+			t.Fatalf("TestAuditableTransferTampered - %s fails at %s, expected %s: %v\n", c.name, stage, c.stage, err)
+		}
+		var pe *ProofError
+		if !errors.As(err, &pe) || pe.Type != "VerifyTransfer" {
+			t.Fatalf("TestAuditableTransferTampered - %s gives %v\n", c.name, err)
+		}
+	}
+
+	// the auditor of a transfer to another auditor can not open it
+	if opened(tx.ReceiverCM, tx.AuditTok, big.NewInt(12345)).Equal(TestCurve.Mult(TestCurve.G, big.NewInt(25))) {
+		t.Fatalf("TestAuditableTransferTampered - wrong auditor key opens the commitment\n")
+	}
+
+	q := *tx
+	q.ReceiverAudit = nil
+	if ok, err := VerifyTransfer(TestCurve, &q, k.senderPK, k.receiverPK, k.auditorPK); ok || !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("TestAuditableTransferTampered - transfer without a proof gives %v, %v\n", ok, err)
+	}
+	if ok, err := VerifyTransfer(TestCurve, nil, k.senderPK, k.receiverPK, k.auditorPK); ok || !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("TestAuditableTransferTampered - nil transfer gives %v, %v\n", ok, err)
+	}
+	q = *tx
+	q.AuditTok = ECPoint{}
+	if ok, err := VerifyTransfer(TestCurve, &q, k.senderPK, k.receiverPK, k.auditorPK); ok || !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("TestAuditableTransferTampered - transfer with a nil point gives %v, %v\n", ok, err)
+	}
+}
+
+func TestAuditableTransferErrors(t *testing.T) {
+	k := newTransferKeys(t)
+	N := TestCurve.C.Params().N
+	cases := []struct {
+		name                  string
+		senderSK, amount      *big.Int
+		receiverPK, auditorPK ECPoint
+	}{
+		{"amount too large", k.sk[0], new(big.Int).Lsh(big.NewInt(1), TransferAmountBits), k.receiverPK, k.auditorPK},
+		{"negative amount", k.sk[0], big.NewInt(-1), k.receiverPK, k.auditorPK},
+		{"nil amount", k.sk[0], nil, k.receiverPK, k.auditorPK},
+		{"zero senderSK", big.NewInt(0), big.NewInt(5), k.receiverPK, k.auditorPK},
+		{"senderSK out of range", N, big.NewInt(5), k.receiverPK, k.auditorPK},
+	}
+	for _, c := range cases {
+		if _, err := NewAuditableTransfer(TestCurve, c.senderSK, c.receiverPK, c.auditorPK, c.amount); !errors.Is(err, ErrValueOutOfRange) {
+			t.Fatalf("TestAuditableTransferErrors - %s gives %v\n", c.name, err)
+		}
+	}
+	if _, err := NewAuditableTransfer(TestCurve, k.sk[0], ECPoint{}, k.auditorPK, big.NewInt(5)); !errors.Is(err, ErrNotOnCurve) {
+		t.Fatalf("TestAuditableTransferErrors - nil receiver key gives %v\n", err)
+	}
+
+	tx, err := NewAuditableTransfer(TestCurve, k.sk[0], k.receiverPK, k.auditorPK, big.NewInt(5))
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	b := tx.Bytes()
+	for _, bad := range [][]byte{nil, {0xff}, b[:1], b[:len(b)-1], append(b, 0)} {
+		if _, err := NewAuditableTransferFromBytes(bad); !errors.Is(err, ErrMalformedProof) {
+			t.Fatalf("TestAuditableTransferErrors - decoding %d bytes gives %v\n", len(bad), err)
+		}
+	}
+}
+
+func BenchmarkNewAuditableTransfer(b *testing.B) {
+	k := newTransferKeys(b)
+	amount := big.NewInt(1000)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewAuditableTransfer(TestCurve, k.sk[0], k.receiverPK, k.auditorPK, amount)
+	}
+}
+
+func BenchmarkVerifyTransfer(b *testing.B) {
+	k := newTransferKeys(b)
+	tx, _ := NewAuditableTransfer(TestCurve, k.sk[0], k.receiverPK, k.auditorPK, big.NewInt(1000))
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		VerifyTransfer(TestCurve, tx, k.senderPK, k.receiverPK, k.auditorPK)
+	}
+}
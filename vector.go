@@ -0,0 +1,408 @@
+package zksigma
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/mit-dci/zksigma/wire"
+)
+
+// MaxGeneratorVector is the length up to which GeneratorVector derives the
+// generators of vector commitments on demand. Longer vectors need the
+// generators to be derived up front with ExtendGeneratorVector.
+const MaxGeneratorVector = 64
+
+// vectorGenerators caches the generators of vector commitments per curve, they
+// only depend on the curve
+var vectorGenerators = struct {
+	sync.Mutex
+	gens map[*elliptic.CurveParams][]ECPoint
+}{gens: make(map[*elliptic.CurveParams][]ECPoint)}
+
+// GeneratorVector returns the generators H_1, ..., H_n of vector commitments
+// on the curve of zkpcp. They are hashed to the curve from
+// "zksigma VectorPedCommit" and the index, so nobody knows their discrete logs
+// with respect to each other or to G and H. They are derived once and cached,
+// every call returns copies of its own.
+// n above MaxGeneratorVector fails unless ExtendGeneratorVector derived that
+// many before.
+func GeneratorVector(zkpcp ZKPCurveParams, n int) ([]ECPoint, error) {
+	if n < 0 {
+		return nil, inputError("GeneratorVector", fmt.Sprintf("negative length %d", n))
+	}
+	vectorGenerators.Lock()
+	cached := len(vectorGenerators.gens[zkpcp.C.Params()])
+	vectorGenerators.Unlock()
+	if n > cached && n > MaxGeneratorVector {
+		return nil, inputError("GeneratorVector",
+			fmt.Sprintf("%d generators, more than %d need ExtendGeneratorVector", n, MaxGeneratorVector))
+	}
+	return generatorVector(zkpcp, n)
+}
+
+// ExtendGeneratorVector derives the first n generators of vector commitments
+// on the curve of zkpcp, so GeneratorVector and the vector commitments work
+// for up to n values
+func ExtendGeneratorVector(zkpcp ZKPCurveParams, n int) error {
+	_, err := generatorVector(zkpcp, n)
+	return err
+}
+
+// generatorVector returns the first n generators, deriving the ones that are
+// not cached yet
+func generatorVector(zkpcp ZKPCurveParams, n int) ([]ECPoint, error) {
+	key := zkpcp.C.Params()
+	vectorGenerators.Lock()
+	defer vectorGenerators.Unlock()
+
+	gens := vectorGenerators.gens[key]
+	if len(gens) < n {
+		more, err := hashGenerators(zkpcp.C, "zksigma VectorPedCommit", len(gens), n-len(gens))
+		if err != nil {
+			return nil, wrapError("GeneratorVector", "no generator found", err)
+		}
+		gens = append(append([]ECPoint{}, gens...), more...)
+		vectorGenerators.gens[key] = gens
+	}
+	return clonePoints(gens[:n]), nil
+}
+
+// VectorPedCommit generates a Pedersen commitment
+// C = values[0]H_1 + ... + values[n-1]H_n + rH to all of values at once, with
+// the generators of GeneratorVector. It returns the randomness r generated for
+// the commitment. Like PedCommit it commits to the values mod N. Zeros at the
+// end add nothing to C, so C does not fix the length of the vector.
+func VectorPedCommit(zkpcp ZKPCurveParams, values []*big.Int) (ECPoint, *big.Int, error) {
+	r, err := zkpcp.randScalar(entropy())
+	if err != nil {
+		return Zero, nil, err
+	}
+	C, err := VectorPedCommitR(zkpcp, values, r)
+	if err != nil {
+		return Zero, nil, err
+	}
+	return C, r, nil
+}
+
+// VectorPedCommitR generates a vector Pedersen commitment to values with the
+// given random value
+func VectorPedCommitR(zkpcp ZKPCurveParams, values []*big.Int, randomValue *big.Int) (ECPoint, error) {
+	if len(values) == 0 {
+		return Zero, inputError("VectorPedCommit", "no values")
+	}
+	if randomValue == nil {
+		return Zero, rangeError("VectorPedCommit", "randomValue is nil")
+	}
+	gens, err := GeneratorVector(zkpcp, len(values))
+	if err != nil {
+		return Zero, err
+	}
+	N := zkpcp.C.Params().N
+	scalars := make([]*big.Int, len(values)+1)
+	for i, v := range values {
+		if v == nil {
+			return Zero, rangeError("VectorPedCommit", fmt.Sprintf("values[%d] is nil", i))
+		}
+		scalars[i] = new(big.Int).Mod(v, N)
+	}
+	scalars[len(values)] = new(big.Int).Mod(randomValue, N)
+	return zkpcp.MultiMult(append(gens, zkpcp.H), scalars), nil
+}
+
+// VectorOpenProof is a proof of knowledge of the opening (v_1, ..., v_n, r) of
+// a vector Pedersen commitment C = v_1H_1 + ... + v_nH_n + rH, without
+// revealing any of them. It is OpenProof with a response per value:
+//
+//  Public: generator points H and H_1, ..., H_n, C
+//
+//  Prover                              Verifier
+//  ======                              ========
+//  knows v_i, r with C = sum(v_iH_i) + rH
+//  selects random u_1, ..., u_n, u
+//  T = sum(u_iH_i) + uH
+//  c = HASH(H, n, C, T)
+//  s_i = u_i + c * v_i
+//  s = u + c * r
+//
+//  T, c, s_1, ..., s_n, s ------------>
+//                                      c ?= HASH(H, n, C, T)
+//                                      cC + T ?= sum(s_iH_i) + sH
+//
+// The proof has one scalar per value, the generators are not part of it.
+type VectorOpenProof struct {
+	T         ECPoint    // T = sum(u_iH_i) + uH
+	Challenge *big.Int   // c = HASH(H, n, C, T)
+	S         []*big.Int // s_i = u_i + c * v_i
+	SR        *big.Int   // s = u + c * r
+}
+
+// vectorOpenChallenge computes c = HASH(H, n, C, T)
+func vectorOpenChallenge(zkpcp ZKPCurveParams, n int, C, T ECPoint) *big.Int {
+	t := NewTranscript(zkpcp, "VectorOpenProof")
+	t.AppendPoint("H", zkpcp.H)
+	t.AppendScalar("n", big.NewInt(int64(n)))
+	t.AppendPoint("C", C)
+	t.AppendPoint("T", T)
+	return t.ChallengeScalar("c")
+}
+
+// NewVectorOpenProof generates a proof that the prover knows values and r with
+// C = VectorPedCommitR(values, r)
+func NewVectorOpenProof(zkpcp ZKPCurveParams, C ECPoint, values []*big.Int, r *big.Int) (*VectorOpenProof, error) {
+	return NewVectorOpenProofWithRand(zkpcp, entropy(), C, values, r)
+}
+
+// NewVectorOpenProofWithRand is the same as NewVectorOpenProof, but reads its
+// random values from rnd instead of the EntropySource
+func NewVectorOpenProofWithRand(zkpcp ZKPCurveParams, rnd io.Reader, C ECPoint, values []*big.Int, r *big.Int) (*VectorOpenProof, error) {
+	N := zkpcp.C.Params().N
+
+	if len(values) == 0 {
+		return nil, inputError("VectorOpenProve", "no values")
+	}
+	for i, v := range values {
+		if err := zkpcp.checkScalars("VectorOpenProve", fmt.Sprintf("values[%d]", i), v); err != nil {
+			return nil, err
+		}
+	}
+	if err := zkpcp.checkScalars("VectorOpenProve", "r", r); err != nil {
+		return nil, err
+	}
+	if !zkpcp.validPoints(C) {
+		return nil, curveError("VectorOpenProve")
+	}
+	gens, err := GeneratorVector(zkpcp, len(values))
+	if err != nil {
+		return nil, err
+	}
+	if check, _ := VectorPedCommitR(zkpcp, values, r); !check.Equal(C) {
+		return nil, inputError("VectorOpenProve", "values and r do not produce C")
+	}
+
+	// u_1, ..., u_n and u
+	us, err := zkpcp.randScalars(rnd, len(values)+1)
+	if err != nil {
+		return nil, err
+	}
+
+	T := zkpcp.MultiMult(append(gens, zkpcp.H), us)
+	c := vectorOpenChallenge(zkpcp, len(values), C, T)
+
+	// s = u + c * witness
+	respond := func(u, witness *big.Int) *big.Int {
+		s := new(big.Int).Add(u, new(big.Int).Mul(c, witness))
+		return s.Mod(s, N)
+	}
+	proof := &VectorOpenProof{T: T, Challenge: c, S: make([]*big.Int, len(values))}
+	for i, v := range values {
+		proof.S[i] = respond(us[i], v)
+	}
+	proof.SR = respond(us[len(values)], r)
+
+	return proof, nil
+}
+
+// Verify checks if VectorOpenProof proof shows knowledge of the opening of C,
+// which is a commitment to as many values as the proof has responses
+func (proof *VectorOpenProof) Verify(zkpcp ZKPCurveParams, C ECPoint) (bool, error) {
+	if proof == nil {
+		return false, malformedError("VectorOpenProof.Verify", fmt.Sprintf("passed proof is nil"))
+	}
+	if len(proof.S) == 0 {
+		return false, malformedError("VectorOpenProof.Verify", "proof has no responses")
+	}
+	if proof.SR == nil {
+		return false, malformedError("VectorOpenProof.Verify", "proof is missing a scalar")
+	}
+	for i, s := range proof.S {
+		if s == nil {
+			return false, malformedError("VectorOpenProof.Verify", fmt.Sprintf("response %d is missing", i))
+		}
+	}
+
+	if !zkpcp.validPoints(C) || !zkpcp.onCurve(proof.T) {
+		return false, curveError("VectorOpenProof.Verify")
+	}
+	if err := zkpcp.canonicalScalars("VectorOpenProof.Verify", "Challenge, SR", proof.Challenge, proof.SR); err != nil {
+		return false, err
+	}
+	if err := zkpcp.canonicalScalars("VectorOpenProof.Verify", "S[]", proof.S...); err != nil {
+		return false, err
+	}
+
+	gens, err := GeneratorVector(zkpcp, len(proof.S))
+	if err != nil {
+		return false, err
+	}
+
+	c := vectorOpenChallenge(zkpcp, len(proof.S), C, proof.T)
+	if !scalarsEqual(c, proof.Challenge) {
+		return false, challengeError("VectorOpenProof.Verify", "calculated challenge and proof's challenge do not agree", c, proof.Challenge)
+	}
+
+	// cC + T ?= sum(s_iH_i) + sH, checked as sum(s_iH_i) + sH - cC ?= T
+	points := append(gens, zkpcp.H, C)
+	scalars := append(append([]*big.Int{}, proof.S...), proof.SR, new(big.Int).Neg(c))
+	lhs := zkpcp.MultiMult(points, scalars)
+	if !pointsEqual(lhs, proof.T) {
+		return false, equationError("VectorOpenProof.Verify", 1, "sum(s_iH_i) + sH - cC != T", lhs, proof.T)
+	}
+
+	return true, nil
+}
+
+// VerifyArgs implements Verifiable, the only arg is C like for Verify
+func (proof *VectorOpenProof) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	a := newVerifyArgs("VectorOpenProof.VerifyArgs", args)
+	C := a.point()
+	if err := a.done(); err != nil {
+		return false, err
+	}
+	return proof.Verify(zkpcp, C)
+}
+
+// String returns a labeled dump of the fields of proof for debugging
+func (proof *VectorOpenProof) String() string {
+	if proof == nil {
+		return "VectorOpenProof(nil)"
+	}
+	fields := []interface{}{"T", proof.T, "Challenge", proof.Challenge}
+	for i, s := range proof.S {
+		fields = append(fields, fmt.Sprintf("S[%d]", i), s)
+	}
+	fields = append(fields, "SR", proof.SR)
+	return proofString("VectorOpenProof", fields...)
+}
+
+// vectorOpenFixedLen is the length of T, c and s serialized
+var vectorOpenFixedLen = fixedPointLen + 2*fixedScalarLen
+
+// Bytes returns a byte slice with a serialized representation of
+// VectorOpenProof proof, which grows linearly with the number of values
+func (proof *VectorOpenProof) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(proofEncodingVersion)
+	wire.WriteVarInt(&buf, uint64(len(proof.S)))
+	writeFixedPoint(&buf, proof.T)
+	writeFixedScalar(&buf, proof.Challenge)
+	writeFixedScalar(&buf, proof.SR)
+	for _, s := range proof.S {
+		writeFixedScalar(&buf, s)
+	}
+
+	return buf.Bytes()
+}
+
+// Size returns the length of Bytes of VectorOpenProof proof without serializing it
+func (proof *VectorOpenProof) Size() int {
+	n := len(proof.S)
+	return 1 + wire.VarIntSerializeSize(uint64(n)) + vectorOpenFixedLen + n*fixedScalarLen
+}
+
+// Clone returns a deep copy of VectorOpenProof proof that shares no big.Int with it
+func (proof *VectorOpenProof) Clone() *VectorOpenProof {
+	if proof == nil {
+		return nil
+	}
+	return &VectorOpenProof{
+		T:         clonePoint(proof.T),
+		Challenge: cloneScalar(proof.Challenge),
+		S:         cloneScalars(proof.S),
+		SR:        cloneScalar(proof.SR),
+	}
+}
+
+// Equal returns true if VectorOpenProof proof and other have the same values in all
+// their fields. It is not constant time.
+func (proof *VectorOpenProof) Equal(other *VectorOpenProof) bool {
+	if proof == nil || other == nil {
+		return proof == other
+	}
+	return samePoint(proof.T, other.T) &&
+		sameScalar(proof.Challenge, other.Challenge) &&
+		sameScalars(proof.S, other.S) &&
+		sameScalar(proof.SR, other.SR)
+}
+
+// NewVectorOpenProofFromBytes returns a VectorOpenProof generated from the
+// deserialization of byte slice b
+func NewVectorOpenProofFromBytes(b []byte) (*VectorOpenProof, error) {
+	if len(b) > 0 && b[0] != proofEncodingVersion {
+		return nil, malformedError("VectorOpenProofFromBytes", fmt.Sprintf("unsupported encoding version %d", b[0]))
+	}
+	if len(b) == 0 {
+		return nil, malformedError("VectorOpenProofFromBytes", "truncated buffer")
+	}
+	buf := bytes.NewBuffer(b[1:])
+	n, err := wire.ReadVarInt(buf)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 || n > uint64(buf.Len()/fixedScalarLen) ||
+		uint64(buf.Len()) != uint64(vectorOpenFixedLen)+n*uint64(fixedScalarLen) {
+		return nil, malformedError("VectorOpenProofFromBytes", "buffer length does not match number of values")
+	}
+
+	proof := &VectorOpenProof{S: make([]*big.Int, n)}
+	if proof.T, err = readFixedPoint(buf, "VectorOpenProofFromBytes"); err != nil {
+		return nil, err
+	}
+	if err = readFixedScalars(buf, "VectorOpenProofFromBytes", &proof.Challenge, &proof.SR); err != nil {
+		return nil, err
+	}
+	for i := range proof.S {
+		if proof.S[i], err = readFixedScalar(buf, "VectorOpenProofFromBytes"); err != nil {
+			return nil, err
+		}
+	}
+	return proof, nil
+}
+
+type vectorOpenProofJSON struct {
+	T         string   `json:"t"`
+	Challenge string   `json:"challenge"`
+	S         []string `json:"s"`
+	SR        string   `json:"sr"`
+}
+
+// MarshalJSON encodes VectorOpenProof proof with points as compressed hex and
+// scalars as big-endian hex
+func (proof *VectorOpenProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(vectorOpenProofJSON{
+		T:         pointToHex(proof.T),
+		Challenge: scalarToHex(proof.Challenge),
+		S:         scalarsToHex(proof.S),
+		SR:        scalarToHex(proof.SR),
+	})
+}
+
+// UnmarshalJSON decodes a VectorOpenProof encoded with MarshalJSON. It fails
+// if a point is not on the curve or a scalar is not in [0, N)
+func (proof *VectorOpenProof) UnmarshalJSON(b []byte) error {
+	var aux vectorOpenProofJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var err error
+	p := VectorOpenProof{}
+	if p.T, err = pointFromHex(aux.T, "t"); err != nil {
+		return err
+	}
+	if p.Challenge, err = scalarFromHex(aux.Challenge, "challenge"); err != nil {
+		return err
+	}
+	if p.S, err = scalarsFromHex(aux.S, "s"); err != nil {
+		return err
+	}
+	if p.SR, err = scalarFromHex(aux.SR, "sr"); err != nil {
+		return err
+	}
+	*proof = p
+	return nil
+}
@@ -0,0 +1,320 @@
+package zksigma
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestVectorPedCommit(t *testing.T) {
+	values := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4)}
+	r := big.NewInt(99)
+	C, err := VectorPedCommitR(TestCurve, values, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	// same values and randomness, same commitment
+	again, _ := VectorPedCommitR(TestCurve, []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4)}, r)
+	if !C.Equal(again) {
+		t.Fatalf("TestVectorPedCommit - committing twice gives different commitments\n")
+	}
+
+	others := map[string][]*big.Int{
+		"changed value":   {big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(5)},
+		"swapped values":  {big.NewInt(2), big.NewInt(1), big.NewInt(3), big.NewInt(4)},
+		"rotated values":  {big.NewInt(4), big.NewInt(1), big.NewInt(2), big.NewInt(3)},
+		"value dropped":   {big.NewInt(1), big.NewInt(2), big.NewInt(3)},
+		"value appended":  {big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4), big.NewInt(1)},
+		"sum in one slot": {big.NewInt(10), big.NewInt(0), big.NewInt(0), big.NewInt(0)},
+	}
+	for name, vs := range others {
+		other, err := VectorPedCommitR(TestCurve, vs, r)
+		if err != nil {
+			t.Fatalf("TestVectorPedCommit - %s: %v\n", name, err)
+		}
+		if other.Equal(C) {
+			t.Fatalf("TestVectorPedCommit - %s gives the same commitment\n", name)
+		}
+	}
+
+	// a zero adds nothing, so C does not fix the length of the vector
+	padded, _ := VectorPedCommitR(TestCurve, append(values, big.NewInt(0)), r)
+	if !padded.Equal(C) {
+		t.Fatalf("TestVectorPedCommit - appending a zero changes the commitment\n")
+	}
+
+	// a single value is a Pedersen commitment with H_1 instead of G
+	gens, _ := GeneratorVector(TestCurve, 1)
+	single, _ := VectorPedCommitR(TestCurve, values[:1], r)
+	if !single.Equal(TestCurve.Add(TestCurve.Mult(gens[0], values[0]), TestCurve.Mult(TestCurve.H, r))) {
+		t.Fatalf("TestVectorPedCommit - commitment to one value is not vH_1 + rH\n")
+	}
+
+	// it is homomorphic like PedCommit
+	sum, _ := VectorPedCommitR(TestCurve, []*big.Int{big.NewInt(2), big.NewInt(4), big.NewInt(6), big.NewInt(8)}, big.NewInt(198))
+	if !sum.Equal(TestCurve.Add(C, C)) {
+		t.Fatalf("TestVectorPedCommit - sum of commitments is not the commitment to the sum\n")
+	}
+
+	// fresh randomness hides equal vectors
+	C1, r1, err := VectorPedCommit(TestCurve, values)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	C2, _, _ := VectorPedCommit(TestCurve, values)
+	if C1.Equal(C2) {
+		t.Fatalf("TestVectorPedCommit - two commitments to the same vector are equal\n")
+	}
+	if check, _ := VectorPedCommitR(TestCurve, values, r1); !check.Equal(C1) {
+		t.Fatalf("TestVectorPedCommit - returned randomness does not open the commitment\n")
+	}
+
+	if _, _, err := VectorPedCommit(TestCurve, nil); err == nil {
+		t.Fatalf("TestVectorPedCommit - committed to no values\n")
+	}
+	if _, err := VectorPedCommitR(TestCurve, []*big.Int{big.NewInt(1), nil}, r); err == nil {
+		t.Fatalf("TestVectorPedCommit - committed to a nil value\n")
+	}
+	if _, err := VectorPedCommitR(TestCurve, values, nil); err == nil {
+		t.Fatalf("TestVectorPedCommit - committed with nil randomness\n")
+	}
+}
+
+func TestGeneratorVector(t *testing.T) {
+	gens, err := GeneratorVector(TestCurve, MaxGeneratorVector)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if len(gens) != MaxGeneratorVector {
+		t.Fatalf("TestGeneratorVector - got %d generators, expected %d\n", len(gens), MaxGeneratorVector)
+	}
+	seen := map[string]bool{
+		string(TestCurve.G.Bytes()): true,
+		string(TestCurve.H.Bytes()): true,
+	}
+	for i, g := range gens {
+		if !TestCurve.C.IsOnCurve(g.X, g.Y) {
+			t.Fatalf("TestGeneratorVector - H_%d is not on the curve\n", i+1)
+		}
+		if seen[string(g.Bytes())] {
+			t.Fatalf("TestGeneratorVector - H_%d is G, H or another generator\n", i+1)
+		}
+		seen[string(g.Bytes())] = true
+	}
+
+	// shorter vectors are prefixes, and callers can not change the cache
+	short, _ := GeneratorVector(TestCurve, 3)
+	for i := range short {
+		if !short[i].Equal(gens[i]) {
+			t.Fatalf("TestGeneratorVector - H_%d differs for a shorter vector\n", i+1)
+		}
+	}
+	short[0] = TestCurve.G
+	if again, _ := GeneratorVector(TestCurve, 1); again[0].Equal(TestCurve.G) {
+		t.Fatalf("TestGeneratorVector - changing the returned slice changed the cache\n")
+	}
+
+	// each curve has its own generators
+	p256, _ := GeneratorVector(P256Curve, 1)
+	if !P256Curve.C.IsOnCurve(p256[0].X, p256[0].Y) || p256[0].Equal(gens[0]) {
+		t.Fatalf("TestGeneratorVector - P-256 does not get its own generators\n")
+	}
+
+	if _, err := GeneratorVector(TestCurve, -1); err == nil {
+		t.Fatalf("TestGeneratorVector - negative length did not fail\n")
+	}
+	if gens, err := GeneratorVector(TestCurve, 0); err != nil || len(gens) != 0 {
+		t.Fatalf("TestGeneratorVector - zero length gives %d generators: %v\n", len(gens), err)
+	}
+}
+
+func TestGeneratorVectorExtend(t *testing.T) {
+	// P-256 here, so no other test has extended its cache
+	n := MaxGeneratorVector + 1
+	values := make([]*big.Int, n)
+	for i := range values {
+		values[i] = big.NewInt(int64(i))
+	}
+	if _, err := GeneratorVector(P256Curve, n); err == nil {
+		t.Fatalf("TestGeneratorVectorExtend - got %d generators without extending\n", n)
+	}
+	if _, _, err := VectorPedCommit(P256Curve, values); err == nil {
+		t.Fatalf("TestGeneratorVectorExtend - committed to %d values without extending\n", n)
+	}
+
+	if err := ExtendGeneratorVector(P256Curve, n); err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	gens, err := GeneratorVector(P256Curve, n)
+	if err != nil || len(gens) != n {
+		t.Fatalf("TestGeneratorVectorExtend - got %d generators after extending: %v\n", len(gens), err)
+	}
+	C, r, err := VectorPedCommit(P256Curve, values)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	proof, err := NewVectorOpenProof(P256Curve, C, values, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if ok, err := proof.Verify(P256Curve, C); !ok || err != nil {
+		t.Fatalf("TestGeneratorVectorExtend - proof for %d values did not verify: %v\n", n, err)
+	}
+	if _, err := GeneratorVector(P256Curve, n+1); err == nil {
+		t.Fatalf("TestGeneratorVectorExtend - got more generators than extended to\n")
+	}
+}
+
+func TestVectorOpenProof(t *testing.T) {
+	for _, n := range []int{1, 2, 7, MaxGeneratorVector} {
+		values := make([]*big.Int, n)
+		for i := range values {
+			values[i] = big.NewInt(int64(3*i + 1))
+		}
+		C, r, err := VectorPedCommit(TestCurve, values)
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+		proof, err := NewVectorOpenProof(TestCurve, C, values, r)
+		if err != nil {
+			t.Fatalf("TestVectorOpenProof - %d values: %v\n", n, err)
+		}
+		if ok, err := proof.Verify(TestCurve, C); !ok || err != nil {
+			t.Fatalf("TestVectorOpenProof - %d values did not verify: %v\n", n, err)
+		}
+		if ok, _ := proof.Verify(TestCurve, TestCurve.Add(C, TestCurve.H)); ok {
+			t.Fatalf("TestVectorOpenProof - %d values verified for another commitment\n", n)
+		}
+
+		b := proof.Bytes()
+		if len(b) != proof.Size() {
+			t.Fatalf("TestVectorOpenProof - %d values serialize to %d bytes, Size is %d\n", n, len(b), proof.Size())
+		}
+		decoded, err := NewVectorOpenProofFromBytes(b)
+		if err != nil {
+			t.Fatalf("TestVectorOpenProof - %d values failed to decode: %v\n", n, err)
+		}
+		if ok, err := decoded.Verify(TestCurve, C); !ok || err != nil {
+			t.Fatalf("TestVectorOpenProof - decoded proof for %d values did not verify: %v\n", n, err)
+		}
+		if !bytes.Equal(decoded.Bytes(), b) {
+			t.Fatalf("TestVectorOpenProof - decoding and encoding %d values again changes the proof\n", n)
+		}
+		for _, short := range [][]byte{nil, b[:1], b[:len(b)-1], append(b, 0)} {
+			if _, err := NewVectorOpenProofFromBytes(short); err == nil {
+				t.Fatalf("TestVectorOpenProof - decoded %d bytes of %d values\n", len(short), n)
+			}
+		}
+	}
+}
+
+func TestVectorOpenProofTampered(t *testing.T) {
+	values := []*big.Int{big.NewInt(5), big.NewInt(6), big.NewInt(7)}
+	C, r, _ := VectorPedCommit(TestCurve, values)
+	proof, err := NewVectorOpenProof(TestCurve, C, values, r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	for i := range proof.S {
+		q := *proof
+		q.S = append([]*big.Int{}, proof.S...)
+		q.S[i] = new(big.Int).Add(q.S[i], big.NewInt(1))
+		if ok, err := q.Verify(TestCurve, C); ok || err == nil {
+			t.Fatalf("TestVectorOpenProofTampered - verified with s_%d changed\n", i+1)
+		}
+	}
+
+	// the responses of two values swapped open the permuted vector, which
+	// is another commitment
+	q := *proof
+	q.S = []*big.Int{proof.S[1], proof.S[0], proof.S[2]}
+	if ok, _ := q.Verify(TestCurve, C); ok {
+		t.Fatalf("TestVectorOpenProofTampered - verified with responses swapped\n")
+	}
+
+	// fewer responses are a proof for a shorter vector
+	q = *proof
+	q.S = proof.S[:2]
+	if ok, err := q.Verify(TestCurve, C); ok || err == nil {
+		t.Fatalf("TestVectorOpenProofTampered - verified with a response dropped\n")
+	}
+	q = *proof
+	q.S = []*big.Int{proof.S[0], nil, proof.S[2]}
+	if ok, err := q.Verify(TestCurve, C); ok || err == nil {
+		t.Fatalf("TestVectorOpenProofTampered - verified with a nil response\n")
+	}
+	q = *proof
+	q.S = nil
+	if ok, err := q.Verify(TestCurve, C); ok || err == nil {
+		t.Fatalf("TestVectorOpenProofTampered - verified without responses\n")
+	}
+	q = *proof
+	q.SR = new(big.Int).Add(proof.SR, big.NewInt(1))
+	if ok, err := q.Verify(TestCurve, C); ok || err == nil {
+		t.Fatalf("TestVectorOpenProofTampered - verified with s changed\n")
+	}
+
+	var nilProof *VectorOpenProof
+	if ok, err := nilProof.Verify(TestCurve, C); ok || err == nil {
+		t.Fatalf("TestVectorOpenProofTampered - nil proof verified\n")
+	}
+}
+
+func TestVectorOpenProofErrors(t *testing.T) {
+	values := []*big.Int{big.NewInt(5), big.NewInt(6)}
+	C, r, _ := VectorPedCommit(TestCurve, values)
+	N := TestCurve.C.Params().N
+
+	cases := []struct {
+		name   string
+		values []*big.Int
+		r      *big.Int
+	}{
+		{"no values", nil, r},
+		{"wrong value", []*big.Int{big.NewInt(5), big.NewInt(7)}, r},
+		{"swapped values", []*big.Int{big.NewInt(6), big.NewInt(5)}, r},
+		{"too few values", values[:1], r},
+		{"wrong r", values, new(big.Int).Add(r, big.NewInt(1))},
+		{"nil value", []*big.Int{big.NewInt(5), nil}, r},
+		{"value out of range", []*big.Int{big.NewInt(5), N}, r},
+		{"nil r", values, nil},
+	}
+	for _, c := range cases {
+		if _, err := NewVectorOpenProof(TestCurve, C, c.values, c.r); err == nil {
+			t.Fatalf("TestVectorOpenProofErrors - %s should fail\n", c.name)
+		}
+	}
+	long := make([]*big.Int, MaxGeneratorVector+1)
+	for i := range long {
+		long[i] = big.NewInt(0)
+	}
+	if _, err := NewVectorOpenProof(TestCurve, C, long, r); err == nil {
+		t.Fatalf("TestVectorOpenProofErrors - proved more than %d values\n", MaxGeneratorVector)
+	}
+}
+
+func BenchmarkVectorPedCommit64(b *testing.B) {
+	values := make([]*big.Int, MaxGeneratorVector)
+	for i := range values {
+		values[i] = big.NewInt(int64(i))
+	}
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		VectorPedCommit(TestCurve, values)
+	}
+}
+
+func BenchmarkVectorOpenVerify64(b *testing.B) {
+	values := make([]*big.Int, MaxGeneratorVector)
+	for i := range values {
+		values[i] = big.NewInt(int64(i))
+	}
+	C, r, _ := VectorPedCommit(TestCurve, values)
+	proof, _ := NewVectorOpenProof(TestCurve, C, values, r)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		proof.Verify(TestCurve, C)
+	}
+}
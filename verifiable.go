@@ -0,0 +1,144 @@
+package zksigma
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Verifiable is implemented by every proof type, so proofs of different types
+// can be verified by the same code, e.g. a queue of proofs with their public
+// inputs. VerifyArgs is the same as the Verify method of the proof with args
+// in place of the arguments after zkpcp, see the VerifyArgs of each type. An
+// argument of the wrong type or a wrong number of them fails with
+// ErrInvalidInput.
+type Verifiable interface {
+	VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error)
+}
+
+// VerifyProof verifies p for args with VerifyArgs and returns nil only if it
+// is valid. Unlike the (bool, error) of Verify there is only one thing to
+// check. A proof that reports to be valid together with an error, or to be
+// invalid without one, gives an error too.
+func VerifyProof(zkpcp ZKPCurveParams, p Verifiable, args ...interface{}) error {
+	if p == nil {
+		return malformedError("VerifyProof", "passed proof is nil")
+	}
+	ok, err := p.VerifyArgs(zkpcp, args...)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return malformedError("VerifyProof", fmt.Sprintf("%T is invalid without an error", p))
+	}
+	return nil
+}
+
+// verifyArgs takes the arguments of VerifyArgs apart. The first argument
+// missing or of the wrong type is kept in err, later calls return zero values.
+type verifyArgs struct {
+	t    string
+	args []interface{}
+	err  error
+}
+
+func newVerifyArgs(t string, args []interface{}) *verifyArgs {
+	return &verifyArgs{t: t, args: args}
+}
+
+// next returns the next argument, which should be a want
+func (a *verifyArgs) next(want string) interface{} {
+	if a.err != nil {
+		return nil
+	}
+	if len(a.args) == 0 {
+		a.err = inputError(a.t, fmt.Sprintf("missing argument, expected %s", want))
+		return nil
+	}
+	arg := a.args[0]
+	a.args = a.args[1:]
+	return arg
+}
+
+// wrongType records that arg is not a want
+func (a *verifyArgs) wrongType(arg interface{}, want string) {
+	if a.err == nil {
+		a.err = inputError(a.t, fmt.Sprintf("argument is %T, expected %s", arg, want))
+	}
+}
+
+func (a *verifyArgs) point() ECPoint {
+	arg := a.next("ECPoint")
+	p, ok := arg.(ECPoint)
+	if !ok {
+		a.wrongType(arg, "ECPoint")
+	}
+	return p
+}
+
+func (a *verifyArgs) points() []ECPoint {
+	arg := a.next("[]ECPoint")
+	ps, ok := arg.([]ECPoint)
+	if !ok {
+		a.wrongType(arg, "[]ECPoint")
+	}
+	return ps
+}
+
+func (a *verifyArgs) scalar() *big.Int {
+	arg := a.next("*big.Int")
+	s, ok := arg.(*big.Int)
+	if !ok {
+		a.wrongType(arg, "*big.Int")
+	}
+	return s
+}
+
+func (a *verifyArgs) scalars() []*big.Int {
+	arg := a.next("[]*big.Int")
+	ss, ok := arg.([]*big.Int)
+	if !ok {
+		a.wrongType(arg, "[]*big.Int")
+	}
+	return ss
+}
+
+func (a *verifyArgs) int() int {
+	arg := a.next("int")
+	n, ok := arg.(int)
+	if !ok {
+		a.wrongType(arg, "int")
+	}
+	return n
+}
+
+func (a *verifyArgs) ints() []int {
+	arg := a.next("[]int")
+	ns, ok := arg.([]int)
+	if !ok {
+		a.wrongType(arg, "[]int")
+	}
+	return ns
+}
+
+// statements returns all remaining arguments, which have to be Statements
+func (a *verifyArgs) statements() []Statement {
+	var ss []Statement
+	for a.err == nil && len(a.args) != 0 {
+		arg := a.next("Statement")
+		s, ok := arg.(Statement)
+		if !ok {
+			a.wrongType(arg, "Statement")
+		}
+		ss = append(ss, s)
+	}
+	return ss
+}
+
+// done returns the error of the first bad argument, or an error if there are
+// too many of them
+func (a *verifyArgs) done() error {
+	if a.err == nil && len(a.args) != 0 {
+		a.err = inputError(a.t, fmt.Sprintf("%d arguments too many", len(a.args)))
+	}
+	return a.err
+}
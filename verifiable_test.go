@@ -0,0 +1,495 @@
+package zksigma
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// verifiableCase is a proof with the args it is valid for
+type verifiableCase struct {
+	proof Verifiable
+	args  []interface{}
+}
+
+// verifiableProofs returns a valid proof of every type with its args on
+// TestCurve
+func verifiableProofs(t testing.TB) map[string]verifiableCase {
+	return verifiableProofsOn(t, TestCurve)
+}
+
+// verifiableProofsOn returns a valid proof of every type with its args on the
+// curve of zkpcp
+func verifiableProofsOn(t testing.TB, zkpcp ZKPCurveParams) map[string]verifiableCase {
+	N := zkpcp.C.Params().N
+	sk, _ := rand.Int(rand.Reader, N)
+	PK := zkpcp.Mult(zkpcp.H, sk)
+	value := big.NewInt(5)
+	CM, r, _ := PedCommit(zkpcp, value)
+	CMTok := zkpcp.Mult(PK, r)
+	CM2, r2, _ := PedCommit(zkpcp, big.NewInt(6))
+	x, _ := rand.Int(rand.Reader, N)
+	A := zkpcp.Mult(zkpcp.G, x)
+	AH := zkpcp.Mult(zkpcp.H, x)
+	CMPrime, deltaR, _ := RerandomizeCommitment(zkpcp, CM)
+
+	must := func(err error) {
+		if err != nil {
+			t.Fatalf("%v\n", err)
+		}
+	}
+	abc, err := NewABCProof(zkpcp, CM, CMTok, value, sk, Right)
+	must(err)
+	con, err := NewConsistencyProof(zkpcp, CM, CMTok, PK, value, r)
+	must(err)
+	dj, err := NewDisjunctiveProof(zkpcp, zkpcp.G, A, zkpcp.H, AH, x, Left)
+	must(err)
+	eq, err := NewEquivalenceProof(zkpcp, zkpcp.G, A, zkpcp.H, AH, x)
+	must(err)
+	gs, err := NewGSPFSProof(zkpcp, A, x)
+	must(err)
+	ie, err := NewInequalityProof(zkpcp, CM, CM2, value, big.NewInt(6), r, r2)
+	must(err)
+	nz, err := NewNonZeroProof(zkpcp, CM, value, r)
+	must(err)
+	op, err := NewOpenProof(zkpcp, CM, value, r)
+	must(err)
+	ov, err := NewOpenToValueProof(zkpcp, CM, value, r)
+	must(err)
+	pp, PA, PB, PC, err := NewProductProof(zkpcp, big.NewInt(3), big.NewInt(4), r, r2, sk)
+	must(err)
+	mpCMs, mp, err := NewMultiProductProof(zkpcp, []*big.Int{big.NewInt(3), big.NewInt(4), big.NewInt(5)}, []*big.Int{r, r2, sk, r2, r})
+	must(err)
+	rr, err := NewRerandomizationProof(zkpcp, CM, CMPrime, deltaR)
+	must(err)
+	sp, err := NewSumProof(zkpcp, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(6)}, []*big.Int{r, r2}, big.NewInt(11))
+	must(err)
+	bit, bitC, err := NewBitProof(zkpcp, big.NewInt(1), r)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	br, err := NewBitRangeProof(zkpcp, CM, value, r, 4)
+	must(err)
+	om, err := NewOneOfManyProof(zkpcp, []ECPoint{zkpcp.G, zkpcp.H}, []ECPoint{CM, AH}, 1, x)
+	must(err)
+	rp, rpR, err := NewRangeProof(zkpcp, value)
+	must(err)
+	cmp, err := NewComparisonProof(zkpcp, CM2, CM, big.NewInt(6), value, r2, r, 4)
+	must(err)
+	bd, err := NewBoundedRangeProof(zkpcp, CM, value, r, big.NewInt(2), big.NewInt(9))
+	must(err)
+	mr, err := NewMultiRangeProof(zkpcp, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(6)}, []*big.Int{r, r2}, 4)
+	must(err)
+	agg, err := NewAggregateRangeProof(zkpcp, []ECPoint{CM, CM2}, []*big.Int{value, big.NewInt(6)}, []*big.Int{r, r2}, 4)
+	must(err)
+	dec, V, err := NewDecryptionProof(zkpcp, CM, CMTok, sk)
+	must(err)
+	shOut := []ECPoint{zkpcp.Add(CM2, zkpcp.Mult(zkpcp.H, r)), CMPrime}
+	sh, err := NewShuffleProof(zkpcp, []ECPoint{CM, CM2}, shOut, []int{1, 0}, []*big.Int{r, deltaR})
+	must(err)
+	vecCM, _ := VectorPedCommitR(zkpcp, []*big.Int{value, x}, r)
+	vo, err := NewVectorOpenProof(zkpcp, vecCM, []*big.Int{value, x}, r)
+	must(err)
+	newPK := zkpcp.Mult(zkpcp.H, x)
+	newCMTok, _ := RotateToken(zkpcp, CMTok, sk, x)
+	kr, err := NewKeyRotationProof(zkpcp, CMTok, newCMTok, PK, newPK, sk, x)
+	must(err)
+	CMTok2 := zkpcp.Mult(newPK, r)
+	sb, err := NewSameBlindingProof(zkpcp, CMTok, CMTok2, PK, newPK, r)
+	must(err)
+	sameCM, sameR, _ := PedCommit(zkpcp, value)
+	sv, err := NewSameValueProof(zkpcp, CM, sameCM, value, r, sameR)
+	must(err)
+	ez, err := NewEquivOrZeroProof(zkpcp, CM, sameCM, r, sameR, Right)
+	must(err)
+	bp, err := NewBalanceProof(zkpcp, CM, []ECPoint{sameCM}, []int{1}, r, []*big.Int{sameR})
+	must(err)
+	set := []*big.Int{big.NewInt(1), value, big.NewInt(10)}
+	sm, err := NewSetMembershipProof(zkpcp, CM, value, r, set)
+	must(err)
+	ent, err := NewEntryProof(zkpcp, value, r, nil, PK, false)
+	must(err)
+	abcStmt := ABCStatement{CM: CM, CMTok: CMTok, PubKey: PK}
+	gsStmt := GSPFSStatement{Base: zkpcp.G, A: A}
+	statements := []Statement{abcStmt, gsStmt}
+	cp, err := NewComposedProof(zkpcp,
+		abcStmt.WithWitness(ABCWitness{Value: value, SK: sk}, Right), gsStmt.WithWitness(GSPFSWitness{x}))
+	must(err)
+
+	return map[string]verifiableCase{
+		"ABCProof":             {abc, []interface{}{CM, CMTok, PK}},
+		"ConsistencyProof":     {con, []interface{}{CM, CMTok, PK}},
+		"DisjunctiveProof":     {dj, []interface{}{zkpcp.G, A, zkpcp.H, AH}},
+		"EquivalenceProof":     {eq, []interface{}{zkpcp.G, A, zkpcp.H, AH}},
+		"GSPFSProof":           {gs, []interface{}{A}},
+		"InequalityProof":      {ie, []interface{}{CM, CM2}},
+		"NonZeroProof":         {nz, []interface{}{CM}},
+		"OpenProof":            {op, []interface{}{CM}},
+		"OpenToValueProof":     {ov, []interface{}{CM, value}},
+		"ProductProof":         {pp, []interface{}{PA, PB, PC}},
+		"MultiProductProof":    {mp, []interface{}{mpCMs[:3], mpCMs[4]}},
+		"RerandomizationProof": {rr, []interface{}{CM, CMPrime}},
+		"SumProof":             {sp, []interface{}{[]ECPoint{CM, CM2}, big.NewInt(11)}},
+		"BitProof":             {bit, []interface{}{bitC}},
+		"BitRangeProof":        {br, []interface{}{CM, 4}},
+		"OneOfManyProof":       {om, []interface{}{[]ECPoint{zkpcp.G, zkpcp.H}, []ECPoint{CM, AH}}},
+		"RangeProof":           {rp, []interface{}{PedCommitR(zkpcp, value, rpR)}},
+		"ComparisonProof":      {cmp, []interface{}{CM2, CM, 4}},
+		"BoundedRangeProof":    {bd, []interface{}{CM, big.NewInt(2), big.NewInt(9)}},
+		"DecryptionProof":      {dec, []interface{}{CM, CMTok, PK, V}},
+		"ComposedProof":        {cp, []interface{}{statements[0], statements[1]}},
+		"ShuffleProof":         {sh, []interface{}{[]ECPoint{CM, CM2}, shOut}},
+		"VectorOpenProof":      {vo, []interface{}{vecCM}},
+		"KeyRotationProof":     {kr, []interface{}{CMTok, newCMTok, PK, newPK}},
+		"SameBlindingProof":    {sb, []interface{}{CMTok, CMTok2, PK, newPK}},
+		"SameValueProof":       {sv, []interface{}{CM, sameCM}},
+		"EquivOrZeroProof":     {ez, []interface{}{CM, sameCM}},
+		"BalanceProof":         {bp, []interface{}{CM, []ECPoint{sameCM}, []int{1}}},
+		"SetMembershipProof":   {sm, []interface{}{CM, set}},
+		"EntryProof":           {ent, []interface{}{CM, CMTok, PK}},
+		"AggregateRangeProof":  {agg, []interface{}{[]ECPoint{CM, CM2}, 4}},
+		"MultiRangeProof":      {mr, []interface{}{[]ECPoint{CM, CM2}, 4}},
+	}
+}
+
+// otherArg returns an argument of the same type as arg with another value
+func otherArg(arg interface{}) interface{} {
+	switch a := arg.(type) {
+	case ECPoint:
+		return TestCurve.Add(a, TestCurve.G)
+	case []ECPoint:
+		ps := append([]ECPoint{}, a...)
+		ps[0] = TestCurve.Add(ps[0], TestCurve.G)
+		return ps
+	case *big.Int:
+		return new(big.Int).Add(a, big.NewInt(1))
+	case []*big.Int:
+		ss := append([]*big.Int{}, a...)
+		ss[0] = new(big.Int).Add(ss[0], big.NewInt(1))
+		return ss
+	case int:
+		return a + 1
+	case []int:
+		ns := append([]int{}, a...)
+		ns[0] = -ns[0]
+		return ns
+	case GSPFSStatement:
+		a.A = TestCurve.Add(a.A, TestCurve.G)
+		return a
+	case ABCStatement:
+		a.CM = TestCurve.Add(a.CM, TestCurve.G)
+		return a
+	}
+	return nil
+}
+
+func TestVerifiable(t *testing.T) {
+	cases := verifiableProofs(t)
+	for name, c := range cases {
+		if ok, err := c.proof.VerifyArgs(TestCurve, c.args...); !ok || err != nil {
+			t.Fatalf("%s failed to verify through Verifiable: %v\n", name, err)
+		}
+		if err := VerifyProof(TestCurve, c.proof, c.args...); err != nil {
+			t.Fatalf("%s failed VerifyProof: %v\n", name, err)
+		}
+
+		// every argument changed on its own makes the proof invalid, and
+		// Verify always reports why
+		for i := range c.args {
+			args := append([]interface{}{}, c.args...)
+			args[i] = otherArg(args[i])
+			ok, err := c.proof.VerifyArgs(TestCurve, args...)
+			if ok || err == nil {
+				t.Fatalf("%s with argument %d changed gives %v, %v\n", name, i, ok, err)
+			}
+			if err := VerifyProof(TestCurve, c.proof, args...); err == nil {
+				t.Fatalf("%s with argument %d changed passed VerifyProof\n", name, i)
+			}
+		}
+
+		if len(c.args) > 1 || name == "ComposedProof" {
+			if err := VerifyProof(TestCurve, c.proof, c.args[:len(c.args)-1]...); err == nil {
+				t.Fatalf("%s passed VerifyProof with an argument missing\n", name)
+			}
+		}
+		if err := VerifyProof(TestCurve, c.proof, append(c.args, TestCurve.G)...); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("%s with an extra argument gives %v, expected ErrInvalidInput\n", name, err)
+		}
+		wrong := append([]interface{}{}, c.args...)
+		wrong[len(wrong)-1] = "x"
+		if err := VerifyProof(TestCurve, c.proof, wrong...); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("%s with an argument of the wrong type gives %v, expected ErrInvalidInput\n", name, err)
+		}
+
+		// a nil proof of the type
+		nilProof := reflect.Zero(reflect.TypeOf(c.proof)).Interface().(Verifiable)
+		if err := VerifyProof(TestCurve, nilProof, c.args...); !errors.Is(err, ErrMalformedProof) {
+			t.Fatalf("nil %s gives %v, expected ErrMalformedProof\n", name, err)
+		}
+	}
+
+	if err := VerifyProof(TestCurve, nil); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("VerifyProof of nil gives %v\n", err)
+	}
+}
+
+// jsonStrings calls f with every string in the decoded JSON value v and a
+// function that replaces it, the "type" of a sub proof is left out
+func jsonStrings(v interface{}, f func(s string, set func(string))) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, e := range x {
+			if s, ok := e.(string); ok && k != "type" {
+				f(s, func(r string) { x[k] = r })
+			} else {
+				jsonStrings(e, f)
+			}
+		}
+	case []interface{}:
+		for i, e := range x {
+			if s, ok := e.(string); ok {
+				f(s, func(r string) { x[i] = r })
+			} else {
+				jsonStrings(e, f)
+			}
+		}
+	}
+}
+
+func TestVerifiableJSON(t *testing.T) {
+	N := hex.EncodeToString(TestCurve.C.Params().N.Bytes())
+	offCurve := "02" + strings.Repeat("00", fixedScalarLen-1) + "05" // x = 5 has no y
+	for name, c := range verifiableProofs(t) {
+		b, err := json.Marshal(c.proof)
+		if err != nil {
+			t.Fatalf("%s failed to marshal: %v\n", name, err)
+		}
+		decoded := reflect.New(reflect.TypeOf(c.proof).Elem()).Interface().(Verifiable)
+		if err := json.Unmarshal(b, decoded); err != nil {
+			t.Fatalf("%s failed to unmarshal: %v\n", name, err)
+		}
+		if ok, err := decoded.VerifyArgs(TestCurve, c.args...); !ok || err != nil {
+			t.Fatalf("%s failed to verify after JSON round trip: %v\n", name, err)
+		}
+
+		// every point is checked to be on the curve and every scalar to be
+		// in [0, N), however deep it is nested
+		var fields interface{}
+		json.Unmarshal(b, &fields)
+		jsonStrings(fields, func(s string, set func(string)) {
+			bad, code := N, ErrNonCanonicalScalar
+			if len(s) == 2*fixedPointLen {
+				bad, code = offCurve, ErrNotOnCurve
+			}
+			set(bad)
+			tampered, _ := json.Marshal(fields)
+			set(s)
+			proof := reflect.New(reflect.TypeOf(c.proof).Elem()).Interface()
+			if err := json.Unmarshal(tampered, proof); !errors.Is(err, code) {
+				t.Fatalf("%s with %s for %s unmarshals with %v\n", name, shortHex(bad), shortHex(s), err)
+			}
+		})
+	}
+}
+
+// TestVerifiableP256 round-trips a proof of every type on P256Curve through
+// its binary and JSON encodings, which name the curve of every point
+func TestVerifiableP256(t *testing.T) {
+	for name, c := range verifiableProofsOn(t, P256Curve) {
+		if ok, err := c.proof.VerifyArgs(P256Curve, c.args...); !ok || err != nil {
+			t.Fatalf("%s on P256Curve did not verify: %v\n", name, err)
+		}
+
+		// ComposedProof has no binary encoding
+		if name != "ComposedProof" {
+			b, err := EncodeProof(c.proof)
+			if err != nil {
+				t.Fatalf("%s on P256Curve failed to encode: %v\n", name, err)
+			}
+			decoded, _, err := DecodeProof(b)
+			if err != nil {
+				t.Fatalf("%s on P256Curve failed to decode: %v\n", name, err)
+			}
+			if ok, err := decoded.VerifyArgs(P256Curve, c.args...); !ok || err != nil {
+				t.Fatalf("%s on P256Curve failed to verify after binary round trip: %v\n", name, err)
+			}
+			if again, _ := EncodeProof(decoded); !bytes.Equal(again, b) {
+				t.Fatalf("%s on P256Curve does not encode the same after decoding\n", name)
+			}
+		}
+
+		js, err := json.Marshal(c.proof)
+		if err != nil {
+			t.Fatalf("%s on P256Curve failed to marshal: %v\n", name, err)
+		}
+		decoded := reflect.New(reflect.TypeOf(c.proof).Elem()).Interface().(Verifiable)
+		if err := json.Unmarshal(js, decoded); err != nil {
+			t.Fatalf("%s on P256Curve failed to unmarshal: %v\n", name, err)
+		}
+		if ok, err := decoded.VerifyArgs(P256Curve, c.args...); !ok || err != nil {
+			t.Fatalf("%s on P256Curve failed to verify after JSON round trip: %v\n", name, err)
+		}
+	}
+}
+
+// jsonKeys calls f with every key of an object in the decoded JSON value v,
+// however deep it is nested, after removing it from its object. The key is
+// put back when f returns.
+func jsonKeys(v interface{}, f func(key string)) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, e := range x {
+			delete(x, k)
+			f(k)
+			x[k] = e
+			jsonKeys(e, f)
+		}
+	case []interface{}:
+		for _, e := range x {
+			jsonKeys(e, f)
+		}
+	}
+}
+
+// TestVerifiableJSONMissingFields decodes proofs with one field left out of
+// their JSON, which leaves a nil scalar, a nil point or a nil nested proof if
+// UnmarshalJSON lets it through. Verify must fail with an error instead of
+// panicking, unless the field had its zero value anyway.
+func TestVerifiableJSONMissingFields(t *testing.T) {
+	for name, c := range verifiableProofs(t) {
+		b, _ := json.Marshal(c.proof)
+		var fields interface{}
+		json.Unmarshal(b, &fields)
+		jsonKeys(fields, func(key string) {
+			missing, _ := json.Marshal(fields)
+			proof := reflect.New(reflect.TypeOf(c.proof).Elem()).Interface().(Verifiable)
+			if err := json.Unmarshal(missing, proof); err != nil {
+				return
+			}
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("%s without %s panics: %v\n", name, key, r)
+				}
+			}()
+			ok, err := proof.VerifyArgs(TestCurve, c.args...)
+			if ok != (err == nil) {
+				t.Fatalf("%s without %s gives %v, %v\n", name, key, ok, err)
+			}
+			if again, _ := json.Marshal(proof); ok && !bytes.Equal(again, b) {
+				t.Fatalf("%s verified without %s\n", name, key)
+			}
+		})
+
+		for _, empty := range []string{"{}", "null"} {
+			proof := reflect.New(reflect.TypeOf(c.proof).Elem()).Interface().(Verifiable)
+			if err := json.Unmarshal([]byte(empty), proof); err != nil {
+				continue
+			}
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Fatalf("%s from %s panics: %v\n", name, empty, r)
+					}
+				}()
+				if ok, err := proof.VerifyArgs(TestCurve, c.args...); ok || err == nil {
+					t.Fatalf("%s from %s gives %v, %v\n", name, empty, ok, err)
+				}
+			}()
+		}
+	}
+}
+
+// jsonArrays calls f with the path of every array in the decoded JSON value
+// v, however deep it is nested, and a function that replaces the array in its
+// parent. The array is put back when f returns.
+func jsonArrays(v interface{}, path string, f func(path string, a []interface{}, set func([]interface{}))) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, e := range x {
+			if a, ok := e.([]interface{}); ok {
+				f(path+"."+k, a, func(r []interface{}) { x[k] = r })
+				x[k] = a
+			}
+			jsonArrays(e, path+"."+k, f)
+		}
+	case []interface{}:
+		for i, e := range x {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			if a, ok := e.([]interface{}); ok {
+				f(p, a, func(r []interface{}) { x[i] = r })
+				x[i] = a
+			}
+			jsonArrays(e, p, f)
+		}
+	}
+}
+
+// TestVerifiableJSONExtraElements decodes proofs with an array of their JSON
+// grown by one element, and by more than the maxRangeProofTuples HPoints a
+// RangeProof has. Verify must fail with an error instead of panicking, or
+// indexing past the generators in a goroutine.
+func TestVerifiableJSONExtraElements(t *testing.T) {
+	for name, c := range verifiableProofs(t) {
+		b, _ := json.Marshal(c.proof)
+		var fields interface{}
+		json.Unmarshal(b, &fields)
+		jsonArrays(fields, "", func(path string, a []interface{}, set func([]interface{})) {
+			if len(a) == 0 {
+				return
+			}
+			for _, extra := range []int{1, maxRangeProofTuples + 1} {
+				grown := append([]interface{}{}, a...)
+				for i := 0; i < extra; i++ {
+					grown = append(grown, a[len(a)-1])
+				}
+				set(grown)
+				longer, _ := json.Marshal(fields)
+				set(a)
+				proof := reflect.New(reflect.TypeOf(c.proof).Elem()).Interface().(Verifiable)
+				if err := json.Unmarshal(longer, proof); err != nil {
+					continue
+				}
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Fatalf("%s with %d more in %s panics: %v\n", name, extra, path, r)
+						}
+					}()
+					if ok, err := proof.VerifyArgs(TestCurve, c.args...); ok || err == nil {
+						t.Fatalf("%s with %d more in %s gives %v, %v\n", name, extra, path, ok, err)
+					}
+				}()
+			}
+		})
+	}
+}
+
+// badVerifiable breaks the (bool, error) convention of Verify
+type badVerifiable struct {
+	ok  bool
+	err error
+}
+
+func (b badVerifiable) VerifyArgs(zkpcp ZKPCurveParams, args ...interface{}) (bool, error) {
+	return b.ok, b.err
+}
+
+func TestVerifyProofInvariants(t *testing.T) {
+	if err := VerifyProof(TestCurve, badVerifiable{false, nil}); !errors.Is(err, ErrMalformedProof) {
+		t.Fatalf("VerifyProof of an invalid proof without an error gives %v\n", err)
+	}
+	failed := inputError("bad", "some reason")
+	if err := VerifyProof(TestCurve, badVerifiable{true, failed}); err != failed {
+		t.Fatalf("VerifyProof of a valid proof with an error gives %v\n", err)
+	}
+	if err := VerifyProof(TestCurve, badVerifiable{true, nil}); err != nil {
+		t.Fatalf("VerifyProof of a valid proof gives %v\n", err)
+	}
+}
@@ -0,0 +1,450 @@
+package zksigma
+
+import (
+	"math/big"
+	"strings"
+)
+
+// The witness of every proof is kept in its own type, away from the public
+// values of its statement, so that a statement can be handed to a verifier
+// without the secrets it was proved with. A witness prints as its field names
+// only, with every value redacted, and Zeroize clears its secrets once the
+// proof is done.
+//
+// Zeroize only reaches the big.Ints of the witness itself. Copies made while
+// proving, or by the caller before, are not cleared.
+
+// zeroizeInt overwrites the words of x and sets it to 0
+func zeroizeInt(x *big.Int) {
+	if x == nil {
+		return
+	}
+	words := x.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	x.SetInt64(0)
+}
+
+// zeroizeInts calls zeroizeInt for every element of xs
+func zeroizeInts(xs []*big.Int) {
+	for _, x := range xs {
+		zeroizeInt(x)
+	}
+}
+
+// redacted returns the String of witness type t with fields, whose values are
+// not shown
+func redacted(t string, fields ...string) string {
+	return t + "{" + strings.Join(fields, ": <redacted>, ") + ": <redacted>}"
+}
+
+// ABCWitness is the witness of an ABCStatement: the value of CM and the
+// secret key of PubKey
+type ABCWitness struct {
+	Value, SK *big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *ABCWitness) Zeroize() {
+	zeroizeInt(w.Value)
+	zeroizeInt(w.SK)
+}
+
+// String returns the fields of witness w without their values
+func (w ABCWitness) String() string { return redacted("ABCWitness", "Value", "SK") }
+
+// GoString is String, so %#v does not show the values either
+func (w ABCWitness) GoString() string { return w.String() }
+
+// ConsistencyWitness is the witness of a ConsistencyStatement: the value and
+// randomness of CM
+type ConsistencyWitness struct {
+	Value, Randomness *big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *ConsistencyWitness) Zeroize() {
+	zeroizeInt(w.Value)
+	zeroizeInt(w.Randomness)
+}
+
+// String returns the fields of witness w without their values
+func (w ConsistencyWitness) String() string {
+	return redacted("ConsistencyWitness", "Value", "Randomness")
+}
+
+// GoString is String, so %#v does not show the values either
+func (w ConsistencyWitness) GoString() string { return w.String() }
+
+// DisjunctiveWitness is the witness of a DisjunctiveStatement: the x of one of
+// its relations
+type DisjunctiveWitness struct {
+	X *big.Int
+}
+
+// Zeroize overwrites the value of witness w
+func (w *DisjunctiveWitness) Zeroize() { zeroizeInt(w.X) }
+
+// String returns the fields of witness w without their values
+func (w DisjunctiveWitness) String() string { return redacted("DisjunctiveWitness", "X") }
+
+// GoString is String, so %#v does not show the values either
+func (w DisjunctiveWitness) GoString() string { return w.String() }
+
+// EquivalenceWitness is the witness of an EquivalenceStatement: the x of both
+// of its relations
+type EquivalenceWitness struct {
+	X *big.Int
+}
+
+// Zeroize overwrites the value of witness w
+func (w *EquivalenceWitness) Zeroize() { zeroizeInt(w.X) }
+
+// String returns the fields of witness w without their values
+func (w EquivalenceWitness) String() string { return redacted("EquivalenceWitness", "X") }
+
+// GoString is String, so %#v does not show the values either
+func (w EquivalenceWitness) GoString() string { return w.String() }
+
+// GSPFSWitness is the witness of a GSPFSStatement: the x with A = x * Base
+type GSPFSWitness struct {
+	X *big.Int
+}
+
+// Zeroize overwrites the value of witness w
+func (w *GSPFSWitness) Zeroize() { zeroizeInt(w.X) }
+
+// String returns the fields of witness w without their values
+func (w GSPFSWitness) String() string { return redacted("GSPFSWitness", "X") }
+
+// GoString is String, so %#v does not show the values either
+func (w GSPFSWitness) GoString() string { return w.String() }
+
+// AggregateRangeWitness is the witness of an AggregateRangeStatement: the
+// values and randomness of the commitments
+type AggregateRangeWitness struct {
+	Values, Randomnesses []*big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *AggregateRangeWitness) Zeroize() {
+	zeroizeInts(w.Values)
+	zeroizeInts(w.Randomnesses)
+}
+
+// String returns the fields of witness w without their values
+func (w AggregateRangeWitness) String() string {
+	return redacted("AggregateRangeWitness", "Values", "Randomnesses")
+}
+
+// GoString is String, so %#v does not show the values either
+func (w AggregateRangeWitness) GoString() string { return w.String() }
+
+// BalanceWitness is the witness of a BalanceStatement: the randomness of LHS
+// and of the terms
+type BalanceWitness struct {
+	LHSR *big.Int
+	Rs   []*big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *BalanceWitness) Zeroize() {
+	zeroizeInt(w.LHSR)
+	zeroizeInts(w.Rs)
+}
+
+// String returns the fields of witness w without their values
+func (w BalanceWitness) String() string { return redacted("BalanceWitness", "LHSR", "Rs") }
+
+// GoString is String, so %#v does not show the values either
+func (w BalanceWitness) GoString() string { return w.String() }
+
+// BitRangeWitness is the witness of a BitRangeStatement: the value and
+// randomness of CM
+type BitRangeWitness struct {
+	Value, Randomness *big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *BitRangeWitness) Zeroize() {
+	zeroizeInt(w.Value)
+	zeroizeInt(w.Randomness)
+}
+
+// String returns the fields of witness w without their values
+func (w BitRangeWitness) String() string { return redacted("BitRangeWitness", "Value", "Randomness") }
+
+// GoString is String, so %#v does not show the values either
+func (w BitRangeWitness) GoString() string { return w.String() }
+
+// ComparisonWitness is the witness of a ComparisonStatement: the values and
+// randomness of CMA and CMB
+type ComparisonWitness struct {
+	A, B, RA, RB *big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *ComparisonWitness) Zeroize() {
+	zeroizeInts([]*big.Int{w.A, w.B, w.RA, w.RB})
+}
+
+// String returns the fields of witness w without their values
+func (w ComparisonWitness) String() string {
+	return redacted("ComparisonWitness", "A", "B", "RA", "RB")
+}
+
+// GoString is String, so %#v does not show the values either
+func (w ComparisonWitness) GoString() string { return w.String() }
+
+// EquivOrZeroWitness is the witness of an EquivOrZeroStatement: the
+// randomness of CMChange and, for the side that it equals CMDelta, of CMDelta
+type EquivOrZeroWitness struct {
+	RChange, RDelta *big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *EquivOrZeroWitness) Zeroize() {
+	zeroizeInts([]*big.Int{w.RChange, w.RDelta})
+}
+
+// String returns the fields of witness w without their values
+func (w EquivOrZeroWitness) String() string {
+	return redacted("EquivOrZeroWitness", "RChange", "RDelta")
+}
+
+// GoString is String, so %#v does not show the values either
+func (w EquivOrZeroWitness) GoString() string { return w.String() }
+
+// InequalityWitness is the witness of an InequalityStatement: the values and
+// randomness of CM1 and CM2
+type InequalityWitness struct {
+	V1, V2, R1, R2 *big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *InequalityWitness) Zeroize() {
+	zeroizeInts([]*big.Int{w.V1, w.V2, w.R1, w.R2})
+}
+
+// String returns the fields of witness w without their values
+func (w InequalityWitness) String() string {
+	return redacted("InequalityWitness", "V1", "V2", "R1", "R2")
+}
+
+// GoString is String, so %#v does not show the values either
+func (w InequalityWitness) GoString() string { return w.String() }
+
+// KeyRotationWitness is the witness of a KeyRotationStatement: the secret keys
+// of OldPK and NewPK
+type KeyRotationWitness struct {
+	OldSK, NewSK *big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *KeyRotationWitness) Zeroize() {
+	zeroizeInt(w.OldSK)
+	zeroizeInt(w.NewSK)
+}
+
+// String returns the fields of witness w without their values
+func (w KeyRotationWitness) String() string { return redacted("KeyRotationWitness", "OldSK", "NewSK") }
+
+// GoString is String, so %#v does not show the values either
+func (w KeyRotationWitness) GoString() string { return w.String() }
+
+// NonZeroWitness is the witness of a NonZeroStatement: the value and
+// randomness of CM
+type NonZeroWitness struct {
+	V, R *big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *NonZeroWitness) Zeroize() {
+	zeroizeInt(w.V)
+	zeroizeInt(w.R)
+}
+
+// String returns the fields of witness w without their values
+func (w NonZeroWitness) String() string { return redacted("NonZeroWitness", "V", "R") }
+
+// GoString is String, so %#v does not show the values either
+func (w NonZeroWitness) GoString() string { return w.String() }
+
+// OneOfManyWitness is the witness of a OneOfManyStatement: the index of the
+// known relation and its x
+type OneOfManyWitness struct {
+	KnownIndex int
+	X          *big.Int
+}
+
+// Zeroize overwrites the values of witness w, KnownIndex included
+func (w *OneOfManyWitness) Zeroize() {
+	w.KnownIndex = 0
+	zeroizeInt(w.X)
+}
+
+// String returns the fields of witness w without their values
+func (w OneOfManyWitness) String() string { return redacted("OneOfManyWitness", "KnownIndex", "X") }
+
+// GoString is String, so %#v does not show the values either
+func (w OneOfManyWitness) GoString() string { return w.String() }
+
+// OpenWitness is the witness of an OpenStatement: the value and randomness of
+// CM
+type OpenWitness struct {
+	V, R *big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *OpenWitness) Zeroize() {
+	zeroizeInt(w.V)
+	zeroizeInt(w.R)
+}
+
+// String returns the fields of witness w without their values
+func (w OpenWitness) String() string { return redacted("OpenWitness", "V", "R") }
+
+// GoString is String, so %#v does not show the values either
+func (w OpenWitness) GoString() string { return w.String() }
+
+// OpenToValueWitness is the witness of an OpenToValueStatement: the randomness
+// of CM, its value is public
+type OpenToValueWitness struct {
+	R *big.Int
+}
+
+// Zeroize overwrites the value of witness w
+func (w *OpenToValueWitness) Zeroize() { zeroizeInt(w.R) }
+
+// String returns the fields of witness w without their values
+func (w OpenToValueWitness) String() string { return redacted("OpenToValueWitness", "R") }
+
+// GoString is String, so %#v does not show the values either
+func (w OpenToValueWitness) GoString() string { return w.String() }
+
+// RerandomizationWitness is the witness of a RerandomizationStatement: the
+// randomness added to CM
+type RerandomizationWitness struct {
+	DeltaR *big.Int
+}
+
+// Zeroize overwrites the value of witness w
+func (w *RerandomizationWitness) Zeroize() { zeroizeInt(w.DeltaR) }
+
+// String returns the fields of witness w without their values
+func (w RerandomizationWitness) String() string {
+	return redacted("RerandomizationWitness", "DeltaR")
+}
+
+// GoString is String, so %#v does not show the values either
+func (w RerandomizationWitness) GoString() string { return w.String() }
+
+// SameBlindingWitness is the witness of a SameBlindingStatement: the
+// randomness of both tokens
+type SameBlindingWitness struct {
+	R *big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *SameBlindingWitness) Zeroize() {
+	zeroizeInts([]*big.Int{w.R})
+}
+
+// String returns the fields of witness w without their values
+func (w SameBlindingWitness) String() string { return redacted("SameBlindingWitness", "R") }
+
+// GoString is String, so %#v does not show the values either
+func (w SameBlindingWitness) GoString() string { return w.String() }
+
+// SameValueWitness is the witness of a SameValueStatement: the value of both
+// commitments and their randomness
+type SameValueWitness struct {
+	V, R1, R2 *big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *SameValueWitness) Zeroize() {
+	zeroizeInts([]*big.Int{w.V, w.R1, w.R2})
+}
+
+// String returns the fields of witness w without their values
+func (w SameValueWitness) String() string { return redacted("SameValueWitness", "V", "R1", "R2") }
+
+// GoString is String, so %#v does not show the values either
+func (w SameValueWitness) GoString() string { return w.String() }
+
+// SetMembershipWitness is the witness of a SetMembershipStatement: the value
+// and randomness of CM
+type SetMembershipWitness struct {
+	Value, R *big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *SetMembershipWitness) Zeroize() {
+	zeroizeInts([]*big.Int{w.Value, w.R})
+}
+
+// String returns the fields of witness w without their values
+func (w SetMembershipWitness) String() string { return redacted("SetMembershipWitness", "Value", "R") }
+
+// GoString is String, so %#v does not show the values either
+func (w SetMembershipWitness) GoString() string { return w.String() }
+
+// ShuffleWitness is the witness of a ShuffleStatement: the permutation of the
+// inputs and the randomness added to each of them
+type ShuffleWitness struct {
+	Perm    []int
+	DeltaRs []*big.Int
+}
+
+// Zeroize overwrites the values of witness w, Perm included
+func (w *ShuffleWitness) Zeroize() {
+	for i := range w.Perm {
+		w.Perm[i] = 0
+	}
+	zeroizeInts(w.DeltaRs)
+}
+
+// String returns the fields of witness w without their values
+func (w ShuffleWitness) String() string { return redacted("ShuffleWitness", "Perm", "DeltaRs") }
+
+// GoString is String, so %#v does not show the values either
+func (w ShuffleWitness) GoString() string { return w.String() }
+
+// SumWitness is the witness of a SumStatement: the values and randomness of
+// the commitments
+type SumWitness struct {
+	Values, Randomnesses []*big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *SumWitness) Zeroize() {
+	zeroizeInts(w.Values)
+	zeroizeInts(w.Randomnesses)
+}
+
+// String returns the fields of witness w without their values
+func (w SumWitness) String() string { return redacted("SumWitness", "Values", "Randomnesses") }
+
+// GoString is String, so %#v does not show the values either
+func (w SumWitness) GoString() string { return w.String() }
+
+// VectorOpenWitness is the witness of a VectorOpenStatement: the values and
+// randomness of C
+type VectorOpenWitness struct {
+	Values []*big.Int
+	R      *big.Int
+}
+
+// Zeroize overwrites the values of witness w
+func (w *VectorOpenWitness) Zeroize() {
+	zeroizeInts(w.Values)
+	zeroizeInt(w.R)
+}
+
+// String returns the fields of witness w without their values
+func (w VectorOpenWitness) String() string { return redacted("VectorOpenWitness", "Values", "R") }
+
+// GoString is String, so %#v does not show the values either
+func (w VectorOpenWitness) GoString() string { return w.String() }
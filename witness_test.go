@@ -0,0 +1,133 @@
+package zksigma
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// witness is what every witness type implements
+type witness interface {
+	fmt.Stringer
+	Zeroize()
+}
+
+// newWitnesses returns one witness of every type, each secret in it a random
+// scalar of full size
+func newWitnesses() map[string]witness {
+	s := func() *big.Int {
+		x, _ := rand.Int(rand.Reader, TestCurve.C.Params().N)
+		return x.SetBit(x, 250, 1)
+	}
+	return map[string]witness{
+		"ABCWitness":             &ABCWitness{s(), s()},
+		"ConsistencyWitness":     &ConsistencyWitness{s(), s()},
+		"DisjunctiveWitness":     &DisjunctiveWitness{s()},
+		"EquivalenceWitness":     &EquivalenceWitness{s()},
+		"GSPFSWitness":           &GSPFSWitness{s()},
+		"AggregateRangeWitness":  &AggregateRangeWitness{[]*big.Int{s(), s()}, []*big.Int{s(), s()}},
+		"BalanceWitness":         &BalanceWitness{s(), []*big.Int{s(), s()}},
+		"BitRangeWitness":        &BitRangeWitness{s(), s()},
+		"ComparisonWitness":      &ComparisonWitness{s(), s(), s(), s()},
+		"InequalityWitness":      &InequalityWitness{s(), s(), s(), s()},
+		"KeyRotationWitness":     &KeyRotationWitness{s(), s()},
+		"NonZeroWitness":         &NonZeroWitness{s(), s()},
+		"OneOfManyWitness":       &OneOfManyWitness{3, s()},
+		"OpenWitness":            &OpenWitness{s(), s()},
+		"OpenToValueWitness":     &OpenToValueWitness{s()},
+		"RerandomizationWitness": &RerandomizationWitness{s()},
+		"SameBlindingWitness":    &SameBlindingWitness{s()},
+		"SameValueWitness":       &SameValueWitness{s(), s(), s()},
+		"EquivOrZeroWitness":     &EquivOrZeroWitness{s(), s()},
+		"SetMembershipWitness":   &SetMembershipWitness{s(), s()},
+		"ShuffleWitness":         &ShuffleWitness{[]int{2, 0, 1}, []*big.Int{s(), s(), s()}},
+		"SumWitness":             &SumWitness{[]*big.Int{s(), s()}, []*big.Int{s(), s()}},
+		"VectorOpenWitness":      &VectorOpenWitness{[]*big.Int{s(), s()}, s()},
+	}
+}
+
+func TestWitnessZeroize(t *testing.T) {
+	for name, w := range newWitnesses() {
+		_, scalars := proofFields(reflect.ValueOf(w))
+		if len(scalars) == 0 {
+			t.Fatalf("%s has no scalars\n", name)
+		}
+		// keep the words of every scalar, which Zeroize has to overwrite in
+		// place rather than just drop
+		words := make([][]big.Word, len(scalars))
+		for ii, x := range scalars {
+			words[ii] = x.Bits()
+		}
+
+		w.Zeroize()
+
+		for ii, x := range scalars {
+			if x.Sign() != 0 {
+				t.Fatalf("scalar %d of %s is %v after Zeroize\n", ii, name, x)
+			}
+			for _, word := range words[ii] {
+				if word != 0 {
+					t.Fatalf("the memory of scalar %d of %s is not cleared by Zeroize\n", ii, name)
+				}
+			}
+		}
+	}
+
+	// the secret ints go too
+	oneOfMany := &OneOfManyWitness{KnownIndex: 3}
+	oneOfMany.Zeroize()
+	if oneOfMany.KnownIndex != 0 {
+		t.Fatalf("OneOfManyWitness keeps KnownIndex %d after Zeroize\n", oneOfMany.KnownIndex)
+	}
+	perm := []int{2, 0, 1}
+	(&ShuffleWitness{Perm: perm}).Zeroize()
+	if !reflect.DeepEqual(perm, []int{0, 0, 0}) {
+		t.Fatalf("ShuffleWitness keeps Perm %v after Zeroize\n", perm)
+	}
+
+	// a witness with missing values can be zeroized too
+	for _, w := range newWitnesses() {
+		reflect.New(reflect.TypeOf(w).Elem()).Interface().(witness).Zeroize()
+	}
+}
+
+func TestWitnessString(t *testing.T) {
+	for name, w := range newWitnesses() {
+		_, scalars := proofFields(reflect.ValueOf(w))
+		value := reflect.ValueOf(w).Elem().Interface()
+		for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+			for _, v := range []interface{}{w, value} {
+				out := fmt.Sprintf(format, v)
+				if !strings.HasPrefix(out, name+"{") || !strings.Contains(out, "<redacted>") {
+					t.Fatalf("%s printed with %s gives %s\n", name, format, out)
+				}
+				for _, x := range scalars {
+					if strings.Contains(out, x.String()) || strings.Contains(out, x.Text(16)) {
+						t.Fatalf("%s printed with %s shows a secret: %s\n", name, format, out)
+					}
+				}
+			}
+		}
+
+		// every field is named, so a witness missing one is easy to spot
+		out := w.String()
+		typ := reflect.TypeOf(w).Elem()
+		for ii := 0; ii < typ.NumField(); ii++ {
+			if !strings.Contains(out, typ.Field(ii).Name+": <redacted>") {
+				t.Fatalf("%s does not name field %s: %s\n", name, typ.Field(ii).Name, out)
+			}
+		}
+	}
+
+	// nested in a statement or a slice the values stay hidden
+	x := big.NewInt(0x5ec7e7)
+	nested := []interface{}{[]GSPFSWitness{{x}}, struct{ W *GSPFSWitness }{&GSPFSWitness{x}}}
+	for _, v := range nested {
+		if out := fmt.Sprintf("%v", v); strings.Contains(out, x.String()) {
+			t.Fatalf("nested witness shows its value: %s\n", out)
+		}
+	}
+}
@@ -16,7 +16,9 @@ More info on Github
 package zksigma
 
 import (
+	"crypto/elliptic"
 	"crypto/sha256"
+	"encoding/binary"
 	"math/big"
 
 	"github.com/mit-dci/zksigma/btcec"
@@ -37,23 +39,193 @@ const (
 // passed into the proof functions. We just test with the same params that ZKLedger uses.
 var TestCurve ZKPCurveParams
 
-func generateH2tothe() []ECPoint {
-	Hslice := make([]ECPoint, 64)
+// ZKCurve is the default ZKPCurveParams, secp256k1 with the same generators
+// as TestCurve. Code that only ever needs one curve can call the proof
+// functions as methods on it, e.g. ZKCurve.NewABCProof(...).
+var ZKCurve ZKPCurveParams
+
+// Secp256k1Curve is the preset ZKPCurveParams for secp256k1 from NewZKCurve,
+// ZKCurve and TestCurve are the same
+var Secp256k1Curve ZKPCurveParams
+
+// P256Curve is the preset ZKPCurveParams for NIST P-256 from NewZKCurve. Its
+// proofs encode like the ones on secp256k1, with 0x12 or 0x13 as the prefix
+// byte of their points, so they decode without naming the curve.
+var P256Curve ZKPCurveParams
+
+// btcecH is the point btcec precomputes ScalarBaseMultH for, Mult uses the
+// table when it is asked to multiply this point on secp256k1
+var btcecH ECPoint
+
+// NewZKPCurveParams returns the ZKPCurveParams for curve with generators G and
+// H. Nobody should know the discrete log of H relative to G, or commitments
+// are not binding, see NewZKCurve for an H derived that way. On a curve with a
+// cofactor G and H must be in the group of order N. It keeps copies of G and
+// H, not the coordinates passed in.
+func NewZKPCurveParams(curve elliptic.Curve, G, H ECPoint) (ZKPCurveParams, error) {
+	zkpcp := ZKPCurveParams{C: curve, G: clonePoint(G), H: clonePoint(H), N: curve.Params().N}
+	if !zkpcp.onCurve(G, H) {
+		return ZKPCurveParams{}, inputError("NewZKPCurveParams", "generators must be points on the curve")
+	}
+	if G.Equal(H) {
+		return ZKPCurveParams{}, inputError("NewZKPCurveParams", "G and H must be different")
+	}
+	if !zkpcp.hasOrderN(G) || !zkpcp.hasOrderN(H) {
+		return ZKPCurveParams{}, inputError("NewZKPCurveParams", "generators must have order N")
+	}
+	zkpcp.tables = newBaseTables(curve)
+	zkpcp.HPoints = generateH2tothe(zkpcp)
+	return zkpcp, nil
+}
+
+// Generators returns copies of the generators G and H of zkpcp, which can be
+// changed without changing zkpcp or the copies of it that share its points
+func (zkpcp ZKPCurveParams) Generators() (G, H ECPoint) {
+	return clonePoint(zkpcp.G), clonePoint(zkpcp.H)
+}
+
+// NewZKCurve returns the ZKPCurveParams for curve with its base point as G and
+// the H from DeriveH, so nobody knows the discrete log of H relative to G
+func NewZKCurve(curve elliptic.Curve) (ZKPCurveParams, error) {
+	H, err := DeriveH(curve)
+	if err != nil {
+		return ZKPCurveParams{}, err
+	}
+	return NewZKPCurveParams(curve, ECPoint{curve.Params().Gx, curve.Params().Gy}, H)
+}
+
+// generateHDomain separates the points of GenerateH from the other points
+// hashToPoint finds
+const generateHDomain = "zksigma/GenerateH"
+
+// GenerateH derives a generator H for curve from seed, for users who want an H
+// of their own instead of the one of DeriveH. It is hashToPoint of
+// "zksigma/GenerateH" || compressed base point G || seed: x is SHA-256 of that
+// followed by a 4 byte big-endian counter i = 0, 1, ..., cut or widened to the
+// size of the field, until it is the x coordinate of a point, and H is the
+// point with even y. Anybody can check with VerifyHDerivation that H came from
+// seed, so nobody knows its discrete log relative to G. It fails if it would
+// give the point at infinity or a point that does not have order N.
+func GenerateH(curve elliptic.Curve, seed []byte) (ECPoint, error) {
+	G := ECPoint{curve.Params().Gx, curve.Params().Gy}
+	H, err := hashToPoint(curve, append(append([]byte(generateHDomain), compressPoint(curve, G)...), seed...))
+	if err != nil {
+		return Zero, err
+	}
+	if H.Equal(Zero) || H.Equal(G) || !(ZKPCurveParams{C: curve}).hasOrderN(H) {
+		return Zero, inputError("GenerateH", "seed does not give a generator of order N")
+	}
+	return H, nil
+}
+
+// VerifyHDerivation returns true if H is the point GenerateH derives for curve
+// from seed. The H of NewZKCurve comes from DeriveH, which needs no seed, and is
+// checked by comparing it with DeriveH(curve).
+func VerifyHDerivation(curve elliptic.Curve, H ECPoint, seed []byte) bool {
+	if H.X == nil || H.Y == nil || H.Equal(Zero) || !curve.IsOnCurve(H.X, H.Y) {
+		return false
+	}
+	derived, err := GenerateH(curve, seed)
+	return err == nil && derived.Equal(H)
+}
+
+// maxDeriveHTries is how many x coordinates DeriveH tries, about half of them
+// are on the curve
+const maxDeriveHTries = 256
+
+// DeriveH derives a second generator H for curve by try-and-increment: x is
+// SHA-256 of the big-endian bytes of Gx + i for i = 0, 1, ..., cut or widened to
+// the size of the field, until it is the x coordinate of a point, and H is the
+// point with even y. Nobody knows the discrete log of a point found by hashing,
+// and Gx is fixed by the curve, so there is no seed that could have been picked
+// to know it. On secp256k1 this is the H btcec has its tables for.
+func DeriveH(curve elliptic.Curve) (ECPoint, error) {
+	byteLen := (curve.Params().BitSize + 7) / 8
+	for i := int64(0); i < maxDeriveHTries; i++ {
+		seed := new(big.Int).Add(curve.Params().Gx, big.NewInt(i)).Bytes()
+		if p, err := decompressPoint(curve, append([]byte{0x02}, hashToField(seed, byteLen, curve.Params().BitSize)...)); err == nil {
+			return p, nil
+		}
+	}
+	return Zero, inputError("DeriveH", "no point found")
+}
+
+// hashToPoint returns the point with even y whose x coordinate is hashToField
+// of seed || i, for the first 4 byte big-endian i that gives one. Like for the
+// H of DeriveH nobody knows its discrete log.
+func hashToPoint(curve elliptic.Curve, seed []byte) (ECPoint, error) {
+	byteLen := (curve.Params().BitSize + 7) / 8
+	try := make([]byte, len(seed)+4)
+	copy(try, seed)
+	for i := uint32(0); i < maxDeriveHTries; i++ {
+		binary.BigEndian.PutUint32(try[len(seed):], i)
+		if p, err := decompressPoint(curve, append([]byte{0x02}, hashToField(try, byteLen, curve.Params().BitSize)...)); err == nil {
+			return p, nil
+		}
+	}
+	return Zero, inputError("hashToPoint", "no point found")
+}
+
+// hashGenerators returns the n points hashToPoint gives for domain followed by
+// the 4 byte big-endian indices start to start + n - 1
+func hashGenerators(curve elliptic.Curve, domain string, start, n int) ([]ECPoint, error) {
+	seed := append([]byte(domain), 0, 0, 0, 0)
+	gens := make([]ECPoint, n)
+	for i := range gens {
+		binary.BigEndian.PutUint32(seed[len(domain):], uint32(start+i))
+		p, err := hashToPoint(curve, seed)
+		if err != nil {
+			return nil, err
+		}
+		gens[i] = p
+	}
+	return gens, nil
+}
+
+// hashToField returns byteLen bytes with at most bits bits set from seed: the
+// SHA-256 digest of seed, followed by SHA-256(seed || j) for j = 1, 2, ... on
+// fields wider than 256 bits
+func hashToField(seed []byte, byteLen, bits int) []byte {
+	digest := sha256.Sum256(seed)
+	x := digest[:]
+	for j := byte(1); len(x) < byteLen; j++ {
+		block := sha256.Sum256(append(seed[:len(seed):len(seed)], j))
+		x = append(x, block[:]...)
+	}
+	x = x[:byteLen]
+	x[0] &= 0xff >> uint(8*byteLen-bits)
+	return x
+}
+
+func generateH2tothe(zkpcp ZKPCurveParams) []ECPoint {
+	Hslice := make([]ECPoint, maxRangeProofTuples)
 	for i := range Hslice {
-		m := big.NewInt(1 << uint(i))
-		Hslice[i].X, Hslice[i].Y = TestCurve.C.ScalarBaseMult(m.Bytes())
+		m := new(big.Int).Lsh(big.NewInt(1), uint(i))
+		Hslice[i] = zkpcp.Mult(zkpcp.G, m)
 	}
 	return Hslice
 }
 
 func init() {
-	s256 := sha256.New()
-	hashedString := s256.Sum([]byte("This is the new random point in zksigma"))
-	HX, HY := btcec.S256().ScalarMult(btcec.S256().Gx, btcec.S256().Gy, hashedString)
-	TestCurve = ZKPCurveParams{
-		C: btcec.S256(),
-		G: ECPoint{btcec.S256().Gx, btcec.S256().Gy},
-		H: ECPoint{HX, HY},
-	}
-	TestCurve.HPoints = generateH2tothe()
+	// H is the point btcec precomputes ScalarBaseMultH for, so that Mult(H, x)
+	// really is x*H. It is derived by hashing Gx + 2 to the curve (see
+	// btcec/gensecp256k1.go), which is the H DeriveH finds.
+	HX, HY := btcec.S256().ScalarBaseMultH([]byte{1})
+	btcecH = ECPoint{HX, HY}
+
+	var err error
+	Secp256k1Curve, err = NewZKCurve(btcec.S256())
+	if err != nil {
+		panic(err)
+	}
+	if !Secp256k1Curve.H.Equal(btcecH) {
+		panic("zksigma: derived H is not the H of btcec")
+	}
+	TestCurve = Secp256k1Curve
+	ZKCurve = Secp256k1Curve
+
+	P256Curve, err = NewZKCurve(elliptic.P256())
+	if err != nil {
+		panic(err)
+	}
 }